@@ -15,33 +15,49 @@ import (
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	"cloud.google.com/go/profiler"
-	"contrib.go.opencensus.io/integrations/ocsql"
 	"github.com/go-redis/redis/v7"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blob"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/dcensus"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/frontend"
+	"golang.org/x/pkgsite/internal/health"
+	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/migrations"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/proxydatasource"
 	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/source"
+	dbmigrations "golang.org/x/pkgsite/migrations"
 )
 
+// healthProbeTimeout is how long each dependency has to respond to a probe
+// from /healthz or /readyz.
+const healthProbeTimeout = 2 * time.Second
+
 var (
 	queueName      = config.GetEnv("GO_DISCOVERY_FRONTEND_TASK_QUEUE", "")
+	stmtCacheSize  = config.GetEnv("GO_DISCOVERY_DATABASE_STATEMENT_CACHE_SIZE", "100")
+	slowQueryMS    = config.GetEnv("GO_DISCOVERY_DATABASE_SLOW_QUERY_MS", "0")
+	explainFrac    = config.GetEnv("GO_DISCOVERY_DATABASE_EXPLAIN_FRACTION", "0")
 	staticPath     = flag.String("static", "content/static", "path to folder containing static files served")
 	thirdPartyPath = flag.String("third_party", "third_party", "path to folder containing third-party libraries")
 	devMode        = flag.Bool("dev", false, "enable developer mode (reload templates on each page load, serve non-minified JS/CSS, etc.)")
+	migrateDB      = flag.Bool("migrate", false, "apply pending database migrations at startup")
 	proxyURL       = flag.String("proxy_url", "https://proxy.golang.org", "Uses the module proxy referred to by this URL "+
 		"for direct proxy mode and frontend fetches")
 	directProxy = flag.Bool("direct_proxy", false, "if set to true, uses the module proxy referred to by this URL "+
 		"as a direct backend, bypassing the database")
+	basePath = flag.String("base-path", "", "path under which the site is served, like /godoc, "+
+		"for when a reverse proxy forwards requests for that path unchanged")
 )
 
 func main() {
@@ -52,6 +68,23 @@ func main() {
 		log.Fatal(ctx, err)
 	}
 	cfg.Dump(os.Stderr)
+	if cfg.LicensePolicy != "" {
+		p, err := licenses.ReadPolicyFile(cfg.LicensePolicy)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		licenses.SetPolicy(p)
+	}
+	if cfg.SourceHosts != "" {
+		hosts, err := source.ReadHostsFile(cfg.SourceHosts)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		if err := source.SetHosts(hosts); err != nil {
+			log.Fatal(ctx, err)
+		}
+	}
+	internal.SetServedPrefixes(cfg.ServedPrefixes)
 	if cfg.UseProfiler {
 		if err := profiler.Start(profiler.Config{}); err != nil {
 			log.Fatalf(ctx, "profiler.Start: %v", err)
@@ -61,26 +94,66 @@ func main() {
 		ds         internal.DataSource
 		exp        internal.ExperimentSource
 		fetchQueue queue.Queue
+		probes     []health.Probe
 	)
 	proxyClient, err := proxy.New(*proxyURL)
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	if err := proxyClient.SetPrivate(proxy.PrivateConfig{
+		Patterns:  cfg.PrivatePatterns,
+		ProxyURL:  cfg.PrivateProxyURL,
+		NetrcFile: cfg.PrivateProxyNetrc,
+		Header:    cfg.PrivateProxyHeader,
+	}); err != nil {
+		log.Fatal(ctx, err)
+	}
+	probes = append(probes, health.Proxy(proxyClient))
+	var cacheClient *redis.Client
+	if cfg.RedisCacheHost != "" {
+		cacheClient = redis.NewClient(&redis.Options{
+			Addr: cfg.RedisCacheHost + ":" + cfg.RedisCachePort,
+		})
+	}
 	if *directProxy {
 		ds = proxydatasource.New(proxyClient)
 		exp = internal.NewLocalExperimentSource(readLocalExperiments(ctx))
 	} else {
-		// Wrap the postgres driver with OpenCensus instrumentation.
-		ocDriver, err := ocsql.Register("postgres", ocsql.WithAllTraceOptions())
+		// Queries are traced by the database package.
+		ddb, err := openDB(ctx, cfg, "postgres")
 		if err != nil {
-			log.Fatalf(ctx, "unable to register the ocsql driver: %v\n", err)
+			log.Fatal(ctx, err)
+		}
+		if *migrateDB {
+			if _, err := migrations.Up(ctx, ddb, dbmigrations.FS); err != nil {
+				log.Fatal(ctx, err)
+			}
 		}
-		ddb, err := openDB(ctx, cfg, ocDriver)
+		n, err := strconv.Atoi(stmtCacheSize)
 		if err != nil {
-			log.Fatal(ctx, err)
+			log.Fatalf(ctx, "GO_DISCOVERY_DATABASE_STATEMENT_CACHE_SIZE: %v", err)
 		}
+		ddb.EnableStatementCache(n)
+		ddb.RecordPoolStats(ctx, 30*time.Second)
+		probes = append(probes, health.Postgres(ddb))
 		db := postgres.New(ddb)
 		defer db.Close()
+		if cfg.BlobURL != "" {
+			bucket, err := blob.Open(ctx, cfg.BlobURL)
+			if err != nil {
+				log.Fatal(ctx, err)
+			}
+			db.SetBlobBucket(bucket, cfg.BlobDocThresholdKB<<10)
+		}
+		logSlowQueries(ctx, ddb, db)
+		ddb.AddQueryHook(func(ctx context.Context, _ string, _ []interface{}, d time.Duration, _ error) {
+			middleware.RecordPhase(ctx, middleware.PhaseDB, d)
+		})
+		if cacheClient != nil {
+			// Modules fetched by the frontend's queue change pages too, so
+			// remove them from the cache as the worker does.
+			db.AddModuleHook(cache.New(cacheClient).InvalidateModule)
+		}
 		ds = db
 		exp = db
 		sourceClient := source.NewClient(config.SourceTimeout)
@@ -93,38 +166,55 @@ func main() {
 		})
 	}
 	server, err := frontend.NewServer(frontend.ServerConfig{
-		DataSource:           ds,
-		Queue:                fetchQueue,
-		CompletionClient:     haClient,
-		TaskIDChangeInterval: config.TaskIDChangeIntervalFrontend,
-		StaticPath:           *staticPath,
-		ThirdPartyPath:       *thirdPartyPath,
-		DevMode:              *devMode,
-		AppVersionLabel:      cfg.AppVersionLabel(),
+		DataSource:            ds,
+		Queue:                 fetchQueue,
+		ProxyClient:           proxyClient,
+		CompletionClient:      haClient,
+		TaskIDChangeInterval:  config.TaskIDChangeIntervalFrontend,
+		StaticPath:            *staticPath,
+		ThirdPartyPath:        *thirdPartyPath,
+		BasePath:              *basePath,
+		DevMode:               *devMode,
+		AppVersionLabel:       cfg.AppVersionLabel(),
+		SearchQuerySampleRate: cfg.SearchQuerySampleRate,
+		Crawl:                 cfg.Crawl,
 	})
 	if err != nil {
 		log.Fatalf(ctx, "frontend.NewServer: %v", err)
 	}
 	router := dcensus.NewRouter(frontend.TagRoute)
-	var cacheClient *redis.Client
-	if cfg.RedisCacheHost != "" {
-		cacheClient = redis.NewClient(&redis.Options{
-			Addr: cfg.RedisCacheHost + ":" + cfg.RedisCachePort,
-		})
+	server.Install(func(route string, h http.Handler) {
+		router.Handle(route, middleware.LatencyRoute(route, h))
+	}, cacheClient)
+	if haClient != nil {
+		probes = append(probes, health.Redis("redis-ha", haClient))
 	}
-	server.Install(router.Handle, cacheClient)
+	if cacheClient != nil {
+		probes = append(probes, health.Redis("redis-cache", cacheClient))
+	}
+	health.New(healthProbeTimeout, probes...).Install(router.Handle)
 	views := append(dcensus.ServerViews,
 		postgres.SearchLatencyDistribution,
 		postgres.SearchResponseCount,
+		postgres.SearcherOutcomeCount,
 		frontend.FrontendFetchLatencyDistribution,
 		frontend.FrontendFetchResponseCount,
 		middleware.CacheResultCount,
 		middleware.CacheErrorCount,
+		middleware.CoalesceResultCount,
 		middleware.QuotaResultCount,
+		middleware.ExperimentRequestCount,
+		middleware.PanicCount,
+		middleware.PhaseLatencyDistribution,
 	)
+	views = append(views, proxy.ProxyViews...)
+	views = append(views, database.Views...)
 	if err := dcensus.Init(cfg, views...); err != nil {
 		log.Fatal(ctx, err)
 	}
+	if err := dtrace.Init(ctx, cfg, "frontend"); err != nil {
+		log.Fatal(ctx, err)
+	}
 	// We are not currently forwarding any ports on AppEngine, so serving debug
 	// information is broken.
 	if !cfg.OnAppEngine() {
@@ -138,29 +228,63 @@ func main() {
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	rateLimitedHandler, err := server.RateLimitedHandler()
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	// Without redis, each instance keeps its own quota buckets.
+	quota := middleware.Quota(cfg.Quota, cacheClient, rateLimitedHandler)
 	requestLogger := getLogger(ctx, cfg)
 	experimenter, err := middleware.NewExperimenter(ctx, 1*time.Minute, exp, requestLogger)
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
 	mw := middleware.Chain(
-		middleware.RequestLog(requestLogger),
-		middleware.AcceptMethods(http.MethodGet), // accept only GETs
-		middleware.Quota(cfg.Quota),
+		middleware.BasePath(*basePath), // must come first, so that everything else sees the paths of the site
+		middleware.RequestLog(requestLogger, cfg.RequestLogSampleRate),
+		middleware.Latency(), // must come before the middleware whose time it records
+		middleware.ServerTiming(cfg.ServerTiming, cfg.ServerTimingKey),
+		middleware.AcceptMethods(http.MethodGet),       // accept only GETs
 		middleware.GodocURL(),                          // potentially redirects so should be early in chain
+		middleware.Language(server.MatchLanguage),      // must come before anything that renders or caches a page
 		middleware.SecureHeaders(),                     // must come before any caching for nonces to work
+		quota,                                          // must come after SecureHeaders for the nonce of its page
+		middleware.ETag(cfg.AppVersionLabel()),         // must come after SecureHeaders and before LatestVersion
 		middleware.LatestVersion(server.LatestVersion), // must come before caching for version badge to work
 		middleware.Panic(panicHandler),
 		middleware.Timeout(54*time.Second),
 		middleware.Experiment(experimenter),
+		middleware.ExperimentOverrides(cfg.ExperimentOverrideKey), // must come after Experiment
 	)
 	addr := cfg.HostAddr("localhost:8080")
 	log.Infof(ctx, "Listening on addr %s", addr)
 	log.Fatal(ctx, http.ListenAndServe(addr, mw(router)))
 }
 
+// newQueue returns the queue selected by cfg.QueueBackend. The frontend
+// processes the tasks of an in-memory queue itself; the tasks of other queues
+// are processed by the worker.
 func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB) queue.Queue {
-	if !cfg.OnAppEngine() {
+	process := func(ctx context.Context, modulePath, version string) (int, error) {
+		return frontend.FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, db, cfg.AppVersionLabel())
+	}
+	switch cfg.QueueBackend {
+	case "postgres":
+		return queue.NewPostgres(ctx, db, nil, process, nil)
+	case "redis":
+		if cfg.RedisQueueHost == "" {
+			log.Fatal(ctx, "missing Redis host: must set GO_DISCOVERY_REDIS_QUEUE_HOST env var")
+		}
+		client := redis.NewClient(&redis.Options{
+			Addr: cfg.RedisQueueHost + ":" + cfg.RedisQueuePort,
+		})
+		q, err := queue.NewRedis(ctx, client, "", nil, process, nil)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		return q
+	}
+	if cfg.QueueBackend == "inmemory" || (cfg.QueueBackend == "" && !cfg.OnAppEngine()) {
 		experiments, err := db.GetExperiments(ctx)
 		if err != nil {
 			log.Fatal(ctx, err)
@@ -171,8 +295,7 @@ func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client
 				set[e.Name] = true
 			}
 		}
-		return queue.NewInMemory(ctx, proxyClient, sourceClient, db, 10,
-			frontend.FetchAndUpdateState, experiment.NewSet(set), cfg.AppVersionLabel())
+		return queue.NewInMemory(ctx, queue.NewLimiter(10), process, experiment.NewSet(set))
 	}
 	client, err := cloudtasks.NewClient(ctx)
 	if err != nil {
@@ -204,6 +327,28 @@ func openDB(ctx context.Context, cfg *config.Config, driver string) (_ *database
 		cfg.DBHost, err, cfg.DBSecondaryHost)
 	return database.Open(driver, ci, cfg.InstanceID)
 }
+
+// logSlowQueries configures ddb to record slow queries in db, if a slow query
+// threshold is set.
+func logSlowQueries(ctx context.Context, ddb *database.DB, db *postgres.DB) {
+	ms, err := strconv.Atoi(slowQueryMS)
+	if err != nil {
+		log.Fatalf(ctx, "GO_DISCOVERY_DATABASE_SLOW_QUERY_MS: %v", err)
+	}
+	if ms <= 0 {
+		return
+	}
+	frac, err := strconv.ParseFloat(explainFrac, 64)
+	if err != nil {
+		log.Fatalf(ctx, "GO_DISCOVERY_DATABASE_EXPLAIN_FRACTION: %v", err)
+	}
+	ddb.LogSlowQueries(database.SlowQueryOptions{
+		Threshold:       time.Duration(ms) * time.Millisecond,
+		ExplainFraction: frac,
+		Record:          db.RecordSlowQuery,
+	})
+}
+
 func getLogger(ctx context.Context, cfg *config.Config) middleware.Logger {
 	if cfg.OnAppEngine() {
 		logger, err := log.UseStackdriver(ctx, cfg, "frontend-log")
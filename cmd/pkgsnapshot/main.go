@@ -0,0 +1,181 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The pkgsnapshot command copies module versions between discovery databases
+// without a module proxy. The export subcommand writes the selected module
+// versions to a snapshot file, and the import subcommand inserts the module
+// versions of a snapshot file into a database, so that an instance of pkgsite
+// with no access to a proxy can serve them.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blob"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/snapshot"
+
+	_ "github.com/lib/pq"
+)
+
+var (
+	dbURI    = flag.String("database", "postgres://postgres@localhost:5432/discovery-db?sslmode=disable", "URI of the database to export from or import into")
+	listFile = flag.String("list", "", "for export, a file with one MODULE[@VERSION] per line, in addition to those given as arguments")
+	blobURL  = flag.String("blob", "", "for export, URL of the blob bucket that holds documentation stored out of the database (see GO_DISCOVERY_BLOB_URL)")
+)
+
+func main() {
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "usage: %s [flags] export FILE MODULE[@VERSION]...\n", os.Args[0])
+		fmt.Fprintf(out, "       %s [flags] import FILE\n", os.Args[0])
+		fmt.Fprintln(out, "A MODULE without a VERSION stands for its latest version.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	ddb, err := database.Open("postgres", *dbURI, "")
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	defer ddb.Close()
+	db := postgres.New(ddb)
+	if *blobURL != "" {
+		bucket, err := blob.Open(ctx, *blobURL)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		// Imported documentation stays in the database.
+		db.SetBlobBucket(bucket, 0)
+	}
+
+	switch cmd, file := flag.Arg(0), flag.Arg(1); cmd {
+	case "export":
+		mods := flag.Args()[2:]
+		if *listFile != "" {
+			more, err := readList(*listFile)
+			if err != nil {
+				log.Fatal(ctx, err)
+			}
+			mods = append(mods, more...)
+		}
+		if len(mods) == 0 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		if err := export(ctx, db, file, mods); err != nil {
+			log.Fatal(ctx, err)
+		}
+		fmt.Printf("exported %d module versions to %s\n", len(mods), file)
+	case "import":
+		n, err := importFile(ctx, db, file)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		fmt.Printf("imported %d module versions from %s\n", n, file)
+	default:
+		log.Fatalf(ctx, "unknown command %q", cmd)
+	}
+}
+
+// export writes the module versions in mods, each of the form
+// MODULE[@VERSION], from db to a snapshot in file.
+func export(ctx context.Context, db *postgres.DB, file string, mods []string) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err2 := f.Close(); err == nil {
+			err = err2
+		}
+	}()
+	w, err := snapshot.NewWriter(f)
+	if err != nil {
+		return err
+	}
+	for _, mv := range mods {
+		modulePath, version := parseModuleVersion(mv)
+		m, err := db.ExportModule(ctx, modulePath, version)
+		if err != nil {
+			return err
+		}
+		if err := w.Write(m); err != nil {
+			return err
+		}
+		log.Infof(ctx, "exported %s@%s", m.ModulePath, m.Version)
+	}
+	return w.Close()
+}
+
+// importFile inserts the module versions of the snapshot in file into db,
+// and returns how many there were.
+func importFile(ctx context.Context, db *postgres.DB, file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	r, err := snapshot.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		m, err := r.Next()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := db.ImportModule(ctx, m); err != nil {
+			return n, err
+		}
+		log.Infof(ctx, "imported %s@%s", m.ModulePath, m.Version)
+		n++
+	}
+}
+
+// parseModuleVersion splits MODULE[@VERSION] into its module path and
+// version, which is internal.LatestVersion if it is missing.
+func parseModuleVersion(mv string) (modulePath, version string) {
+	if i := strings.LastIndexByte(mv, '@'); i >= 0 {
+		return mv[:i], mv[i+1:]
+	}
+	return mv, internal.LatestVersion
+}
+
+// readList returns the non-empty lines of file that are not comments.
+func readList(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mods []string
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		mods = append(mods, line)
+	}
+	return mods, scan.Err()
+}
@@ -9,39 +9,77 @@ package main
 import (
 	"bufio"
 	"context"
+	"crypto/rand"
 	"flag"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
 	"cloud.google.com/go/errorreporting"
 	"cloud.google.com/go/profiler"
 	"github.com/go-redis/redis/v7"
+	"go.opencensus.io/plugin/ochttp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/auth"
+	"golang.org/x/pkgsite/internal/blob"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/checksum"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/dcensus"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/pkgsite/internal/fetch/vet"
+	"golang.org/x/pkgsite/internal/health"
 	"golang.org/x/pkgsite/internal/index"
 	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/vuln"
 	"golang.org/x/pkgsite/internal/worker"
+	dbmigrations "golang.org/x/pkgsite/migrations"
 
+	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/migrations"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
-
-	"contrib.go.opencensus.io/integrations/ocsql"
 )
 
+// healthProbeTimeout is how long each dependency has to respond to a probe
+// from /healthz or /readyz.
+const healthProbeTimeout = 2 * time.Second
+
 var (
-	timeout    = config.GetEnv("GO_DISCOVERY_WORKER_TIMEOUT_MINUTES", "10")
-	queueName  = config.GetEnv("GO_DISCOVERY_WORKER_TASK_QUEUE", "")
-	workers    = flag.Int("workers", 10, "number of concurrent requests to the fetch service, when running locally")
-	staticPath = flag.String("static", "content/static", "path to folder containing static files served")
+	timeout      = config.GetEnv("GO_DISCOVERY_WORKER_TIMEOUT_MINUTES", "10")
+	queueName    = config.GetEnv("GO_DISCOVERY_WORKER_TASK_QUEUE", "")
+	parallelism  = config.GetEnv("GO_DISCOVERY_WORKER_PACKAGE_PARALLELISM", "4")
+	memoryBudget = config.GetEnv("GO_DISCOVERY_WORKER_MEMORY_BUDGET_MB", "0")
+	largeModules = config.GetEnv("GO_DISCOVERY_WORKER_LARGE_MODULE_CONCURRENCY", "1")
+	minFetches   = config.GetEnv("GO_DISCOVERY_WORKER_MIN_FETCHES", "2")
+	maxFetches   = config.GetEnv("GO_DISCOVERY_WORKER_MAX_FETCHES", "10")
+	rssLimit     = config.GetEnv("GO_DISCOVERY_WORKER_RSS_LIMIT_MB", "0")
+	sumDBURL     = config.GetEnv("GO_DISCOVERY_WORKER_SUMDB_URL", checksum.DefaultURL)
+	sumDBKey     = config.GetEnv("GO_DISCOVERY_WORKER_SUMDB_KEY", checksum.DefaultKey)
+	proxyTimeout = config.GetEnv("GO_DISCOVERY_WORKER_PROXY_TIMEOUTS", "")
+	cacheDir     = config.GetEnv("GO_DISCOVERY_WORKER_PROXY_CACHE_DIR", "")
+	cacheSize    = config.GetEnv("GO_DISCOVERY_WORKER_PROXY_CACHE_MB", "10240")
+	stmtCache    = config.GetEnv("GO_DISCOVERY_DATABASE_STATEMENT_CACHE_SIZE", "100")
+	slowQueryMS  = config.GetEnv("GO_DISCOVERY_DATABASE_SLOW_QUERY_MS", "0")
+	explainFrac  = config.GetEnv("GO_DISCOVERY_DATABASE_EXPLAIN_FRACTION", "0")
+	staticPath   = flag.String("static", "content/static", "path to folder containing static files served")
+	migrateDB    = flag.Bool("migrate", false, "apply pending database migrations at startup")
+
+	// shutdownTimeout is how long in-flight fetches have to finish once the
+	// worker is told to stop. App Engine waits 30 seconds after sending
+	// SIGTERM before it kills the instance.
+	shutdownTimeout = config.GetEnv("GO_DISCOVERY_WORKER_SHUTDOWN_TIMEOUT_SECONDS", "25")
 )
 
 func main() {
@@ -55,6 +93,24 @@ func main() {
 	}
 	cfg.Dump(os.Stderr)
 
+	if cfg.LicensePolicy != "" {
+		p, err := licenses.ReadPolicyFile(cfg.LicensePolicy)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		licenses.SetPolicy(p)
+	}
+	if cfg.SourceHosts != "" {
+		hosts, err := source.ReadHostsFile(cfg.SourceHosts)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		if err := source.SetHosts(hosts); err != nil {
+			log.Fatal(ctx, err)
+		}
+	}
+	internal.SetServedPrefixes(cfg.ServedPrefixes)
+
 	if cfg.UseProfiler {
 		if err := profiler.Start(profiler.Config{}); err != nil {
 			log.Fatalf(ctx, "profiler.Start: %v", err)
@@ -62,18 +118,32 @@ func main() {
 	}
 
 	readProxyRemoved(ctx)
+	if sumDBURL != "off" {
+		worker.SumDB = checksum.New(sumDBURL, sumDBKey, &http.Client{
+			Transport: dtrace.Transport(&ochttp.Transport{}),
+			Timeout:   30 * time.Second,
+		})
+		worker.SumDB.SetNoSumDB(cfg.NoSumDBPatterns)
+	}
 
-	// Wrap the postgres driver with OpenCensus instrumentation.
-	driverName, err := ocsql.Register("postgres", ocsql.WithAllTraceOptions())
+	// Queries are traced by the database package.
+	ddb, err := database.Open("postgres", cfg.DBConnInfo(), cfg.InstanceID)
 	if err != nil {
-		log.Fatalf(ctx, "unable to register the ocsql driver: %v\n", err)
+		log.Fatalf(ctx, "database.Open: %v", err)
+	}
+	if *migrateDB {
+		if _, err := migrations.Up(ctx, ddb, dbmigrations.FS); err != nil {
+			log.Fatal(ctx, err)
+		}
 	}
-	ddb, err := database.Open(driverName, cfg.DBConnInfo(), cfg.InstanceID)
+	n, err := strconv.Atoi(stmtCache)
 	if err != nil {
-		log.Fatalf(ctx, "database.Open: %v", err)
+		log.Fatalf(ctx, "GO_DISCOVERY_DATABASE_STATEMENT_CACHE_SIZE: %v", err)
 	}
+	ddb.EnableStatementCache(n)
+	ddb.RecordPoolStats(ctx, 30*time.Second)
 	db := postgres.New(ddb)
-	defer db.Close()
+	logSlowQueries(ctx, ddb, db)
 
 	populateExcluded(ctx, db)
 
@@ -85,33 +155,118 @@ func main() {
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	vulnClient, err := vuln.NewClient(cfg.VulnDBURL)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	if err := proxyClient.SetTimeouts(proxyTimeout); err != nil {
+		log.Fatal(ctx, err)
+	}
+	if err := proxyClient.SetPrivate(proxy.PrivateConfig{
+		Patterns:  cfg.PrivatePatterns,
+		ProxyURL:  cfg.PrivateProxyURL,
+		NetrcFile: cfg.PrivateProxyNetrc,
+		Header:    cfg.PrivateProxyHeader,
+	}); err != nil {
+		log.Fatal(ctx, err)
+	}
+	if cacheDir != "" {
+		cacheMB, err := strconv.Atoi(cacheSize)
+		if err != nil {
+			log.Fatalf(ctx, "strconv.Atoi(%q): %v", cacheSize, err)
+		}
+		if err := proxyClient.EnableDiskCache(cacheDir, int64(cacheMB)<<20); err != nil {
+			log.Fatal(ctx, err)
+		}
+	}
+	if cfg.BlobURL != "" {
+		bucket, err := blob.Open(ctx, cfg.BlobURL)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		db.SetBlobBucket(bucket, cfg.BlobDocThresholdKB<<10)
+		if cfg.BlobZips {
+			proxyClient.SetZipBucket(bucket)
+		}
+	}
 	sourceClient := source.NewClient(config.SourceTimeout)
-	fetchQueue := newQueue(ctx, cfg, proxyClient, sourceClient, db)
+	fetchLimiter := newFetchLimiter(ctx, ddb)
+	// The queue processes its tasks through the server, so that they are
+	// subject to the same checks as those of the /fetch handler. The server
+	// needs the queue, so it is created afterwards; until then, tasks wait.
+	var server *worker.Server
+	serverReady := make(chan struct{})
+	process := func(ctx context.Context, modulePath, version string) (int, error) {
+		select {
+		case <-serverReady:
+		case <-ctx.Done():
+			return http.StatusServiceUnavailable, ctx.Err()
+		}
+		return server.ProcessFetch(ctx, modulePath, version)
+	}
+	fetchQueue := newQueue(ctx, cfg, db, fetchLimiter, process)
 	reportingClient := reportingClient(ctx, cfg)
 	redisHAClient := getHARedis(ctx, cfg)
 	redisCacheClient := getCacheRedis(ctx, cfg)
-	server, err := worker.NewServer(cfg, worker.ServerConfig{
-		DB:                   db,
-		IndexClient:          indexClient,
-		ProxyClient:          proxyClient,
-		SourceClient:         sourceClient,
-		RedisHAClient:        redisHAClient,
-		RedisCacheClient:     redisCacheClient,
-		Queue:                fetchQueue,
-		ReportingClient:      reportingClient,
-		TaskIDChangeInterval: config.TaskIDChangeIntervalWorker,
-		StaticPath:           *staticPath,
+	if redisCacheClient != nil {
+		// Remove the cached pages of modules as they are processed, so
+		// that new versions appear right away.
+		db.AddModuleHook(cache.New(redisCacheClient).InvalidateModule)
+	}
+	memoryBudgetMB, err := strconv.Atoi(memoryBudget)
+	if err != nil {
+		log.Fatalf(ctx, "strconv.Atoi(%q): %v", memoryBudget, err)
+	}
+	largeModuleConcurrency, err := strconv.Atoi(largeModules)
+	if err != nil {
+		log.Fatalf(ctx, "strconv.Atoi(%q): %v", largeModules, err)
+	}
+	adminAuth, csrfKey := getAdminAuth(ctx, cfg)
+	server, err = worker.NewServer(cfg, worker.ServerConfig{
+		DB:                     db,
+		IndexClient:            indexClient,
+		VulnClient:             vulnClient,
+		ProxyClient:            proxyClient,
+		SourceClient:           sourceClient,
+		RedisHAClient:          redisHAClient,
+		RedisCacheClient:       redisCacheClient,
+		Queue:                  fetchQueue,
+		ReportingClient:        reportingClient,
+		TaskIDChangeInterval:   config.TaskIDChangeIntervalWorker,
+		StaticPath:             *staticPath,
+		MemoryBudgetMB:         memoryBudgetMB,
+		LargeModuleConcurrency: largeModuleConcurrency,
+		FetchLimiter:           fetchLimiter,
+		AdminAuth:              adminAuth,
+		CSRFKey:                csrfKey,
 	})
 	if err != nil {
 		log.Fatal(ctx, err)
 	}
+	close(serverReady)
 	router := dcensus.NewRouter(nil)
 	server.Install(router.Handle)
+	probes := []health.Probe{health.Postgres(ddb), health.Proxy(proxyClient)}
+	if redisHAClient != nil {
+		probes = append(probes, health.Redis("redis-ha", redisHAClient))
+	}
+	if redisCacheClient != nil {
+		probes = append(probes, health.Redis("redis-cache", redisCacheClient))
+	}
+	health.New(healthProbeTimeout, probes...).Install(router.Handle)
 
 	views := append(dcensus.ClientViews, dcensus.ServerViews...)
+	views = append(views, worker.IndexPollViews...)
+	views = append(views, worker.FetchLimit)
+	views = append(views, middleware.PanicCount)
+	views = append(views, proxy.ProxyViews...)
+	views = append(views, database.Views...)
 	if err := dcensus.Init(cfg, views...); err != nil {
 		log.Fatal(ctx, err)
 	}
+	if err := dtrace.Init(ctx, cfg, "worker"); err != nil {
+		log.Fatal(ctx, err)
+	}
 	// We are not currently forwarding any ports on AppEngine, so serving debug
 	// information is broken.
 	if !cfg.OnAppEngine() {
@@ -126,6 +281,11 @@ func main() {
 	if err != nil {
 		log.Fatalf(ctx, "strconv.Atoi(%q): %v", timeout, err)
 	}
+	fetch.PackageParallelism, err = strconv.Atoi(parallelism)
+	if err != nil {
+		log.Fatalf(ctx, "strconv.Atoi(%q): %v", parallelism, err)
+	}
+	fetch.Analyzers = []fetch.Analyzer{vet.Analyzer}
 	requestLogger := logger(ctx, cfg)
 
 	experimenter, err := middleware.NewExperimenter(ctx, 1*time.Minute, db, requestLogger)
@@ -133,19 +293,94 @@ func main() {
 		log.Fatal(ctx, err)
 	}
 	mw := middleware.Chain(
-		middleware.RequestLog(requestLogger),
+		middleware.RequestLog(requestLogger, cfg.RequestLogSampleRate),
+		middleware.Panic(nil),
 		middleware.Timeout(time.Duration(handlerTimeout)*time.Minute),
 		middleware.Experiment(experimenter),
 	)
 	http.Handle("/", mw(router))
 
+	shutdownSecs, err := strconv.Atoi(shutdownTimeout)
+	if err != nil {
+		log.Fatalf(ctx, "strconv.Atoi(%q): %v", shutdownTimeout, err)
+	}
 	addr := cfg.HostAddr("localhost:8000")
-	log.Infof(ctx, "Listening on addr %s", addr)
-	log.Fatal(ctx, http.ListenAndServe(addr, nil))
+	httpServer := &http.Server{Addr: addr}
+	go func() {
+		log.Infof(ctx, "Listening on addr %s", addr)
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(ctx, err)
+		}
+	}()
+	waitForShutdown(ctx, time.Duration(shutdownSecs)*time.Second, server, httpServer, db)
 }
 
-func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB) queue.Queue {
-	if !cfg.OnAppEngine() {
+// waitForShutdown waits for a signal to stop, such as the SIGTERM sent to an
+// instance before it is shut down during a deploy. It then stops the worker
+// from accepting fetch tasks, lets the fetches in progress finish until
+// timeout has passed, and closes the HTTP server and the database.
+func waitForShutdown(ctx context.Context, timeout time.Duration, server *worker.Server, httpServer *http.Server, db *postgres.DB) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	sig := <-sigs
+	log.Infof(ctx, "received %s; shutting down within %s", sig, timeout)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error(ctx, err)
+	}
+	// The fetches are done or canceled, so the remaining requests should
+	// finish quickly.
+	sctx, scancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer scancel()
+	if err := httpServer.Shutdown(sctx); err != nil {
+		log.Errorf(ctx, "http.Server.Shutdown: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Errorf(ctx, "closing database: %v", err)
+	}
+	log.Infof(ctx, "shut down")
+}
+
+// newFetchLimiter returns a limiter for the fetches of the worker, whose limit
+// is adjusted between GO_DISCOVERY_WORKER_MIN_FETCHES and
+// GO_DISCOVERY_WORKER_MAX_FETCHES to the pressure on the connection pool of
+// ddb and on memory.
+func newFetchLimiter(ctx context.Context, ddb *database.DB) *queue.Limiter {
+	minN, err := strconv.Atoi(minFetches)
+	if err != nil {
+		log.Fatalf(ctx, "GO_DISCOVERY_WORKER_MIN_FETCHES: %v", err)
+	}
+	maxN, err := strconv.Atoi(maxFetches)
+	if err != nil {
+		log.Fatalf(ctx, "GO_DISCOVERY_WORKER_MAX_FETCHES: %v", err)
+	}
+	rssMB, err := strconv.Atoi(rssLimit)
+	if err != nil {
+		log.Fatalf(ctx, "GO_DISCOVERY_WORKER_RSS_LIMIT_MB: %v", err)
+	}
+	if minN > maxN {
+		log.Fatalf(ctx, "GO_DISCOVERY_WORKER_MIN_FETCHES (%d) is greater than GO_DISCOVERY_WORKER_MAX_FETCHES (%d)", minN, maxN)
+	}
+	limiter := queue.NewLimiter(maxN)
+	go worker.NewConcurrencyController(limiter, ddb, minN, rssMB).Run(ctx)
+	return limiter
+}
+
+// newQueue returns the queue selected by cfg.QueueBackend. Except with Cloud
+// Tasks, which sends the tasks to the /fetch handler, the worker processes
+// the tasks of the queue itself with process, as many at once as limiter
+// allows.
+func newQueue(ctx context.Context, cfg *config.Config, db *postgres.DB, limiter *queue.Limiter, process queue.ProcessFunc) queue.Queue {
+	backend := cfg.QueueBackend
+	if backend == "" {
+		backend = "inmemory"
+		if cfg.OnAppEngine() {
+			backend = "gcp"
+		}
+	}
+	if backend != "gcp" {
 		experiments, err := db.GetExperiments(ctx)
 		if err != nil {
 			log.Fatal(ctx, err)
@@ -156,8 +391,22 @@ func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client
 				set[e.Name] = true
 			}
 		}
-		return queue.NewInMemory(ctx, proxyClient, sourceClient, db, *workers,
-			worker.FetchAndUpdateState, experiment.NewSet(set), cfg.AppVersionLabel())
+		switch backend {
+		case "postgres":
+			return queue.NewPostgres(ctx, db, limiter, process, experiment.NewSet(set))
+		case "redis":
+			client := getRedis(ctx, cfg.RedisQueueHost, cfg.RedisQueuePort, 0, 0)
+			if client == nil {
+				log.Fatal(ctx, "missing Redis host: must set GO_DISCOVERY_REDIS_QUEUE_HOST env var")
+			}
+			q, err := queue.NewRedis(ctx, client, queueConsumer(cfg), limiter, process, experiment.NewSet(set))
+			if err != nil {
+				log.Fatal(ctx, err)
+			}
+			return q
+		default:
+			return queue.NewInMemory(ctx, limiter, process, experiment.NewSet(set))
+		}
 	}
 	if queueName == "" {
 		log.Fatal(ctx, "missing queue: must set GO_DISCOVERY_WORKER_TASK_QUEUE env var")
@@ -169,6 +418,20 @@ func newQueue(ctx context.Context, cfg *config.Config, proxyClient *proxy.Client
 	return queue.NewGCP(cfg, client, queueName)
 }
 
+// queueConsumer returns the name of the worker in the consumer group of a
+// Redis queue. It must stay the same across restarts, so that the worker
+// resumes the tasks it did not finish.
+func queueConsumer(cfg *config.Config) string {
+	if cfg.InstanceID != "" {
+		return cfg.InstanceID
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		return "worker"
+	}
+	return host
+}
+
 func getHARedis(ctx context.Context, cfg *config.Config) *redis.Client {
 	// We update completions with one big pipeline, so we need long write
 	// timeouts. ReadTimeout is increased only to be consistent with
@@ -212,6 +475,34 @@ func reportingClient(ctx context.Context, cfg *config.Config) *errorreporting.Cl
 	return reporter
 }
 
+// getAdminAuth returns the configuration of the authentication of the admin
+// endpoints, and the key of their CSRF tokens. On App Engine, it fails if no
+// way of authenticating is configured; elsewhere, the endpoints are then open
+// to everyone.
+func getAdminAuth(ctx context.Context, cfg *config.Config) (middleware.AdminAuthConfig, []byte) {
+	ac := middleware.AdminAuthConfig{Tokens: cfg.AdminTokens}
+	if cfg.IAPAudience != "" {
+		ac.VerifyIAP = auth.NewIAPVerifier(cfg.IAPAudience).Verify
+	}
+	if ac.VerifyIAP == nil && len(ac.Tokens) == 0 {
+		if cfg.OnAppEngine() {
+			log.Fatal(ctx, "admin endpoints need GO_DISCOVERY_IAP_AUDIENCE or GO_DISCOVERY_ADMIN_TOKENS")
+		}
+		log.Infof(ctx, "no admin authentication configured; admin endpoints are open")
+		ac.Insecure = true
+	}
+	key := []byte(cfg.CSRFKey)
+	if len(key) == 0 {
+		// CSRF tokens from one instance will not be accepted by others.
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatal(ctx, err)
+		}
+		log.Infof(ctx, "GO_DISCOVERY_CSRF_KEY is not set; using a random CSRF key")
+	}
+	return ac, key
+}
+
 func logger(ctx context.Context, cfg *config.Config) middleware.Logger {
 	if cfg.OnAppEngine() {
 		logger, err := log.UseStackdriver(ctx, cfg, "worker-log")
@@ -275,6 +566,9 @@ func populateExcluded(ctx context.Context, db *postgres.DB) {
 			if err := db.InsertExcludedPrefix(ctx, prefix, user, reason); err != nil {
 				log.Fatalf(ctx, "db.InsertExcludedPrefix(%q, %q, %q): %v", prefix, user, reason, err)
 			}
+			if err := db.Audit(ctx, user, "exclude", prefix, nil, map[string]string{"reason": reason}); err != nil {
+				log.Errorf(ctx, "db.Audit: %v", err)
+			}
 		}
 	}
 }
@@ -301,3 +595,24 @@ func readFileLines(filename string) ([]string, error) {
 	}
 	return lines, nil
 }
+
+// logSlowQueries configures ddb to record slow queries in db, if a slow query
+// threshold is set.
+func logSlowQueries(ctx context.Context, ddb *database.DB, db *postgres.DB) {
+	ms, err := strconv.Atoi(slowQueryMS)
+	if err != nil {
+		log.Fatalf(ctx, "GO_DISCOVERY_DATABASE_SLOW_QUERY_MS: %v", err)
+	}
+	if ms <= 0 {
+		return
+	}
+	frac, err := strconv.ParseFloat(explainFrac, 64)
+	if err != nil {
+		log.Fatalf(ctx, "GO_DISCOVERY_DATABASE_EXPLAIN_FRACTION: %v", err)
+	}
+	ddb.LogSlowQueries(database.SlowQueryOptions{
+		Threshold:       time.Duration(ms) * time.Millisecond,
+		ExplainFraction: frac,
+		Record:          db.RecordSlowQuery,
+	})
+}
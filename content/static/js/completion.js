@@ -19,6 +19,9 @@ document.addEventListener('DOMContentLoaded', () => {
   // in terms of classes rather than IDs. See also
   // https://github.com/TarekRaafat/autoComplete.js/issues/82
   const completeInput = document.querySelector('#AutoComplete');
+  // The site may be served under a base path, which prefixes the paths of
+  // its URLs.
+  const basePath = document.documentElement.dataset.basePath;
   const parentForm = document.querySelector('#AutoComplete-parent');
   const hideCompletion = () => {
     parentForm.setAttribute('aria-expanded', false);
@@ -153,7 +156,7 @@ document.addEventListener('DOMContentLoaded', () => {
     data: {
       src: async () => {
         const query = completeInput.value;
-        const source = await fetch(`/autocomplete?q=${query}`);
+        const source = await fetch(`${basePath}/autocomplete?q=${query}`);
         return await source.json();
       },
       // The string we're completing is stored in the 'PackagePath' field of
@@ -181,7 +184,7 @@ document.addEventListener('DOMContentLoaded', () => {
       if (feedback.selection.value.PackagePath) {
         // Navigate directly to the package.
         // TODO (b/149016238): update ARIA attributes to reflect this.
-        window.location.href = '/' + feedback.selection.value.PackagePath;
+        window.location.href = basePath + '/' + feedback.selection.value.PackagePath;
       }
     },
   });
@@ -34,7 +34,7 @@ $jscomp.generator.Generator_=function(a){this.next=function(b){return a.next_(b)
 $jscomp.asyncExecutePromiseGenerator=function(a){function b(b){return a.next(b)}function d(b){return a.throw(b)}return new Promise(function(e,c){function f(a){a.done?e(a.value):Promise.resolve(a.value).then(b,d).then(f,c)}f(a.next())})};$jscomp.asyncExecutePromiseGeneratorFunction=function(a){return $jscomp.asyncExecutePromiseGenerator(a())};$jscomp.asyncExecutePromiseGeneratorProgram=function(a){return $jscomp.asyncExecutePromiseGenerator(new $jscomp.generator.Generator_(new $jscomp.generator.Engine_(a)))};
 $jscomp.polyfill("globalThis",function(a){return a||$jscomp.global},"es_next","es3");$jscomp.polyfill("Array.from",function(a){return a?a:function(a,d,e){d=null!=d?d:function(a){return a};var b=[],f="undefined"!=typeof Symbol&&Symbol.iterator&&a[Symbol.iterator];if("function"==typeof f){a=f.call(a);for(var g=0;!(f=a.next()).done;)b.push(d.call(e,f.value,g++))}else for(f=a.length,g=0;g<f;g++)b.push(d.call(e,a[g],g));return b}},"es6","es3");
 $jscomp.findInternal=function(a,b,d){a instanceof String&&(a=String(a));for(var e=a.length,c=0;c<e;c++){var f=a[c];if(b.call(d,f,c,a))return{i:c,v:f}}return{i:-1,v:void 0}};$jscomp.polyfill("Array.prototype.findIndex",function(a){return a?a:function(a,d){return $jscomp.findInternal(this,a,d).i}},"es6","es3");$jscomp.polyfill("Array.prototype.find",function(a){return a?a:function(a,d){return $jscomp.findInternal(this,a,d).v}},"es6","es3");
-document.addEventListener("DOMContentLoaded",function(){var a=document.querySelector("#AutoComplete"),b=document.querySelector("#AutoComplete-parent"),d=function(){b.setAttribute("aria-expanded",!1);a.removeAttribute("aria-activedescendant")},e=function(){b.setAttribute("aria-expanded",!0)};a.addEventListener("blur",d);a.addEventListener("focus",e);new autoComplete({data:{src:function(){var b,d;return $jscomp.asyncExecutePromiseGeneratorProgram(function(c){return 1==c.nextAddress?(b=a.value,c.yield(fetch("/autocomplete?q="+
+document.addEventListener("DOMContentLoaded",function(){var k=document.documentElement.dataset.basePath,a=document.querySelector("#AutoComplete"),b=document.querySelector("#AutoComplete-parent"),d=function(){b.setAttribute("aria-expanded",!1);a.removeAttribute("aria-activedescendant")},e=function(){b.setAttribute("aria-expanded",!0)};a.addEventListener("blur",d);a.addEventListener("focus",e);new autoComplete({data:{src:function(){var b,d;return $jscomp.asyncExecutePromiseGeneratorProgram(function(c){return 1==c.nextAddress?(b=a.value,c.yield(fetch(k+"/autocomplete?q="+
 b),2)):3!=c.nextAddress?(d=c.yieldResult,c.yield(d.json(),3)):c.return(c.yieldResult)})},key:["PackagePath"],cache:!1},threshold:1,debounce:100,resultsList:{render:!0,container:function(a){a.setAttribute("id","AutoComplete-list");a.classList.add("AutoComplete-list");a.setAttribute("role","listbox")},destination:document.querySelector("#AutoComplete-parent"),position:"beforeend",element:"ul",navigation:function(a,b,g,p,h){var c=Array.from(g.childNodes),f=void 0,n=function(a){f&&f.removeAttribute("aria-selected");
 f=a;f.setAttribute("aria-selected","true");a=c.findIndex(function(a){return a===f});b.setAttribute("aria-activedescendant","AutoComplete-item-"+a)},m=function(a,c){p({event:a,query:b.value,matches:h.matches,results:h.list.map(function(a){return a.value}),selection:h.list.find(function(a){return a.index===Number(c.getAttribute("data-id"))})});d()};b.onkeydown=function(a){if(0<c.length)switch(a.keyCode){case 38:e();var g=c[c.length-1];f&&f.previousSibling&&(g=f.previousSibling);n(g);a.preventDefault();
-break;case 40:e();g=c[0];f&&f.nextSibling&&(g=f.nextSibling);n(g);a.preventDefault();break;case 13:f&&(a.preventDefault(),m(a,f));break;case 27:d();b.value="";break;default:e()}};c.forEach(function(a,b){a.setAttribute("id","AutoComplete-item-"+b);a.onmousedown=function(a){m(a,a.currentTarget);a.preventDefault()}})}},highlight:!0,selector:"#AutoComplete",onSelection:function(a){a.selection.value.PackagePath&&(window.location.href="/"+a.selection.value.PackagePath)}})});
+break;case 40:e();g=c[0];f&&f.nextSibling&&(g=f.nextSibling);n(g);a.preventDefault();break;case 13:f&&(a.preventDefault(),m(a,f));break;case 27:d();b.value="";break;default:e()}};c.forEach(function(a,b){a.setAttribute("id","AutoComplete-item-"+b);a.onmousedown=function(a){m(a,a.currentTarget);a.preventDefault()}})}},highlight:!0,selector:"#AutoComplete",onSelection:function(a){a.selection.value.PackagePath&&(window.location.href=k+"/"+a.selection.value.PackagePath)}})});
@@ -36,19 +36,22 @@ let jumpListItems; // All the identifiers in the doc; computed only once.
 // collectJumpListItems returns a list of items, one for each identifier in the
 // documentation on the current page.
 //
-// It uses the data-kind attribute generated in the documentation HTML to find
-// the identifiers and their id attributes.
+// It uses the index of sections and symbols that the server embeds in the
+// page, if there is one. Otherwise, it uses the data-kind attribute generated
+// in the documentation HTML to find the identifiers and their id attributes.
 //
 // If there are no data-kind attributes, then we have older doc; fall back to
 // a less precise method.
 function collectJumpListItems() {
-  let items = [];
-  const doc = document.querySelector('.Documentation');
-  for (const el of doc.querySelectorAll('[data-kind]')) {
-    items.push(newJumpListItem(el));
-  }
+  let items = collectJumpListItemsFromIndex();
   if (items.length == 0) {
-    items = collectJumpListItemsFallback(doc);
+    const doc = document.querySelector('.Documentation');
+    for (const el of doc.querySelectorAll('[data-kind]')) {
+      items.push(newJumpListItem(el));
+    }
+    if (items.length == 0) {
+      items = collectJumpListItemsFallback(doc);
+    }
   }
   // Clicking on any of the links closes the dialog.
   for (const item of items) {
@@ -63,6 +66,31 @@ function collectJumpListItems() {
   return items;
 }
 
+// collectJumpListItemsFromIndex returns a list of items for the sections and
+// symbols in the index embedded in the page (.JumpDialog-index), or an empty
+// list if there is no index. Unlike the methods below, it only looks up the
+// elements of the symbols by id, instead of visiting every element of the
+// documentation, which is slow for large packages.
+function collectJumpListItemsFromIndex() {
+  const items = [];
+  const script = document.querySelector('.JumpDialog-index');
+  if (!script) {
+    return items;
+  }
+  const index = JSON.parse(script.textContent);
+  for (const section of index.sections || []) {
+    items.push(newSectionJumpListItem(section.id, section.name));
+  }
+  for (const symbol of index.symbols || []) {
+    // Skip symbols without an element, so that every item leads somewhere.
+    const el = document.getElementById(symbol);
+    if (el) {
+      items.push(newJumpListItem(el));
+    }
+  }
+  return items;
+}
+
 function collectJumpListItemsFallback(doc) {
   const items = [];
   // A map from id to bool, to dedup DOM ids. The doc DOM has duplicate ids (b/143456059).
@@ -103,6 +131,20 @@ function newJumpListItem(el) {
   };
 }
 
+// newSectionJumpListItem creates a new item for the section of the
+// documentation with the given id and name.
+function newSectionJumpListItem(id, name) {
+  const a = document.createElement('a');
+  a.setAttribute('href', '#' + id);
+  a.setAttribute('tabindex', '-1');
+  return {
+    link: a,
+    name: name,
+    kind: 'section',
+    lower: name.toLowerCase(), // for sorting
+  };
+}
+
 // guessKind tries to guess the kind of el by looking around the DOM.
 // Fixing b/143456714 would make this unnecessary.
 function guessKind(el) {
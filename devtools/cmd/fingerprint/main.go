@@ -0,0 +1,54 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The fingerprint command writes the manifest of the fingerprinted names of
+// the static CSS and JavaScript files of the frontend, which it serves at
+// URLs that change with their contents. Run it after changing any of them.
+// With -check, it checks that the manifest is up to date instead.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/pkgsite/internal/assets"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+var (
+	staticPath = flag.String("static", "content/static", "path to the static directory")
+	check      = flag.Bool("check", false, "check that the manifest is up to date instead of writing it")
+)
+
+func main() {
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "usage: %s [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx := context.Background()
+	if *check {
+		m, err := assets.Read(*staticPath)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		if err := m.Check(*staticPath); err != nil {
+			log.Fatal(ctx, err)
+		}
+		fmt.Printf("checked %s\n", assets.ManifestFile)
+		return
+	}
+	m, err := assets.Build(*staticPath)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	if err := assets.Write(*staticPath, m); err != nil {
+		log.Fatal(ctx, err)
+	}
+	fmt.Printf("wrote %d files to %s\n", len(m), assets.ManifestFile)
+}
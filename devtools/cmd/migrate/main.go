@@ -0,0 +1,90 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The migrate command applies or reverts migrations of a discovery database.
+// It is meant for local development; deployed binaries apply migrations at
+// startup when given the -migrate flag.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/migrations"
+	dbmigrations "golang.org/x/pkgsite/migrations"
+
+	_ "github.com/lib/pq"
+)
+
+var dbURI = flag.String("database", "postgres://postgres@localhost:5432/discovery-db?sslmode=disable", "URI of the database to migrate")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] up | down [N] | force N | version\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	db, err := database.Open("postgres", *dbURI, "")
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	defer db.Close()
+
+	switch cmd := flag.Arg(0); cmd {
+	case "up":
+		migs, err := migrations.Up(ctx, db, dbmigrations.FS)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		fmt.Printf("applied %d migrations\n", len(migs))
+	case "down":
+		n := 1
+		if flag.NArg() > 1 {
+			n = intArg(ctx, 1)
+		}
+		migs, err := migrations.Down(ctx, db, dbmigrations.FS, n)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		fmt.Printf("reverted %d migrations\n", len(migs))
+	case "force":
+		if flag.NArg() < 2 {
+			log.Fatalf(ctx, "force needs a version")
+		}
+		if err := migrations.Force(ctx, db, uint(intArg(ctx, 1))); err != nil {
+			log.Fatal(ctx, err)
+		}
+	case "version":
+		v, dirty, err := migrations.Version(ctx, db)
+		if err != nil {
+			log.Fatal(ctx, err)
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", v)
+		} else {
+			fmt.Println(v)
+		}
+	default:
+		log.Fatalf(ctx, "unknown command %q", cmd)
+	}
+}
+
+func intArg(ctx context.Context, i int) int {
+	n, err := strconv.Atoi(flag.Arg(i))
+	if err != nil || n < 0 {
+		log.Fatalf(ctx, "invalid number %q", flag.Arg(i))
+	}
+	return n
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The seeddb command fills a local database with the module versions listed
+// in a seed file, by fetching them from the module proxy and processing them
+// as the worker does. The default seed file covers the kinds of modules that
+// pkg.go.dev serves, so that the frontend can be developed against realistic
+// data.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/worker"
+
+	_ "github.com/lib/pq"
+)
+
+var seedFile = flag.String("seed", "devtools/cmd/seeddb/seed.txt", "file with one MODULE@VERSION per line")
+
+func main() {
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "usage: %s [flags]\n", os.Args[0])
+		fmt.Fprintln(out, "The database, proxy and app version are read from the GO_DISCOVERY_ environment variables, as by the worker.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx := context.Background()
+	cfg, err := config.Init(ctx)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	mods, err := readSeedFile(*seedFile)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	ddb, err := database.Open("postgres", cfg.DBConnInfo(), cfg.InstanceID)
+	if err != nil {
+		log.Fatalf(ctx, "database.Open: %v", err)
+	}
+	defer ddb.Close()
+	db := postgres.New(ddb)
+	proxyClient, err := proxy.New(cfg.ProxyURL)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	sourceClient := source.NewClient(config.SourceTimeout)
+
+	// Process the modules with the experiments that are rolled out in the
+	// database, as a local worker would.
+	experiments, err := db.GetExperiments(ctx)
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	set := map[string]bool{}
+	for _, e := range experiments {
+		if e.Rollout > 0 {
+			set[e.Name] = true
+		}
+	}
+	ctx = experiment.NewContext(ctx, experiment.NewSet(set))
+
+	var failed []string
+	for _, mv := range mods {
+		modulePath, version := splitModuleVersion(mv)
+		code, err := worker.FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, db, cfg.AppVersionLabel())
+		if err != nil && code >= http.StatusInternalServerError {
+			log.Errorf(ctx, "%s: %v", mv, err)
+			failed = append(failed, mv)
+			continue
+		}
+		// Other statuses, like that of a module without packages, are
+		// recorded in the database like any other, so they are not failures.
+		log.Infof(ctx, "%s: status %d", mv, code)
+	}
+	fmt.Printf("seeded %d of %d module versions\n", len(mods)-len(failed), len(mods))
+	if len(failed) > 0 {
+		fmt.Printf("failed: %s\n", strings.Join(failed, " "))
+		os.Exit(1)
+	}
+}
+
+// readSeedFile returns the non-empty lines of file that are not comments.
+// Each must be of the form MODULE@VERSION.
+func readSeedFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var mods []string
+	scan := bufio.NewScanner(f)
+	for n := 1; scan.Scan(); n++ {
+		line := strings.TrimSpace(scan.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.Contains(line, "@") {
+			return nil, fmt.Errorf("%s:%d: %q has no version; seed versions must be pinned", file, n, line)
+		}
+		mods = append(mods, line)
+	}
+	return mods, scan.Err()
+}
+
+// splitModuleVersion splits MODULE@VERSION into its module path and version.
+func splitModuleVersion(mv string) (modulePath, version string) {
+	i := strings.LastIndexByte(mv, '@')
+	return mv[:i], mv[i+1:]
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The tempdb command runs a temporary Postgres cluster with a migrated
+// discovery-db database, for local development without a Postgres server or
+// docker. It prints the environment variables that point the frontend and
+// worker at the database, and removes the cluster when interrupted.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/migrations"
+	"golang.org/x/pkgsite/internal/testing/dbtest"
+	dbmigrations "golang.org/x/pkgsite/migrations"
+
+	_ "github.com/lib/pq"
+)
+
+const dbName = "discovery-db"
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Set GO_DISCOVERY_POSTGRES_BIN to the directory of initdb and pg_ctl if they are not found.")
+	}
+	flag.Parse()
+
+	ctx := context.Background()
+	c, err := dbtest.StartCluster()
+	if err != nil {
+		log.Fatal(ctx, err)
+	}
+	if err := run(ctx, c); err != nil {
+		c.Stop()
+		log.Fatal(ctx, err)
+	}
+	fmt.Printf("Postgres is running on port %d. To use it, run\n\n", c.Port)
+	fmt.Printf("\texport GO_DISCOVERY_DATABASE_HOST=localhost GO_DISCOVERY_DATABASE_PORT=%d GO_DISCOVERY_DATABASE_USER=postgres GO_DISCOVERY_DATABASE_PASSWORD=\n\n", c.Port)
+	fmt.Println("Press Ctrl-C to stop it and delete its data.")
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	if err := c.Stop(); err != nil {
+		log.Fatal(ctx, err)
+	}
+}
+
+// run creates the discovery database in c and applies the migrations to it.
+func run(ctx context.Context, c *dbtest.Cluster) error {
+	c.Setenv()
+	if err := dbtest.CreateDBIfNotExists(dbName); err != nil {
+		return err
+	}
+	db, err := database.Open("postgres", dbtest.DBConnURI(dbName), "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migs, err := migrations.Up(ctx, db, dbmigrations.FS)
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "applied %d migrations", len(migs))
+	return nil
+}
@@ -43,6 +43,12 @@ main() {
   # TODO: once this is not an experiment, add it to the line above.
   $cmd $JSDIR/completion.min.js $JSDIR/completion.js
   $cmd $JSDIR/jump.min.js       third_party/dialog-polyfill/dialog-polyfill.js $JSDIR/jump.js
+  # The frontend serves the compiled files at fingerprinted URLs.
+  if [[ $cmd = compile ]]; then
+    go run ./devtools/cmd/fingerprint
+  else
+    go run ./devtools/cmd/fingerprint -check
+  fi
 }
 
 main $@
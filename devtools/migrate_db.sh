@@ -12,12 +12,11 @@ Usage: $0 [up|down|force|version] {#}"
 EOUSAGE
 }
 
-# Redirect stderr to stdout because migrate outputs to stderr, and we want
+# Redirect stderr to stdout because migrate logs to stderr, and we want
 # to be able to use ordinary output redirection.
 case "$1" in
   up|down|force|version)
-    migrate \
-      -source file:migrations \
+    go run ./devtools/cmd/migrate \
       -database "postgres://postgres@localhost:5432/discovery-db?sslmode=disable" \
       "$@" 2>&1
     ;;
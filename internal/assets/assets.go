@@ -0,0 +1,152 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package assets fingerprints the static CSS and JavaScript files of the
+// frontend, so that they can be served at URLs that change with their
+// contents and cached forever.
+//
+// The fingerprinted name of a file has a hash of its contents before its
+// extension, as in css/stylesheet.0123456789.css. The names are computed at
+// build time, by devtools/cmd/fingerprint, and recorded in a manifest file in
+// the static directory, which the frontend reads when it starts.
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// ManifestFile is the name of the manifest file in the static directory.
+const ManifestFile = "manifest.json"
+
+// Dirs are the directories of the static directory whose CSS and JavaScript
+// files are fingerprinted.
+var Dirs = []string{"css", "js"}
+
+// hashLen is the number of hex digits of the hash in a fingerprinted name.
+const hashLen = 10
+
+// A Manifest maps the paths of static files, relative to the static
+// directory and separated by slashes, like css/stylesheet.css, to their
+// fingerprinted paths.
+type Manifest map[string]string
+
+// Fingerprint returns the fingerprinted name of a file with the given name and
+// contents.
+func Fingerprint(name string, contents []byte) string {
+	sum := sha256.Sum256(contents)
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:])[:hashLen] + ext
+}
+
+// Build returns the manifest of the CSS and JavaScript files in Dirs of
+// staticPath, from their current contents.
+func Build(staticPath string) (_ Manifest, err error) {
+	defer derrors.Wrap(&err, "assets.Build(%q)", staticPath)
+
+	m := Manifest{}
+	for _, dir := range Dirs {
+		root := filepath.Join(staticPath, dir)
+		err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && file == root {
+					return nil
+				}
+				return err
+			}
+			if ext := filepath.Ext(file); info.IsDir() || (ext != ".css" && ext != ".js") {
+				return nil
+			}
+			rel, err := filepath.Rel(staticPath, file)
+			if err != nil {
+				return err
+			}
+			contents, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			m[name] = Fingerprint(name, contents)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Read reads the manifest file of staticPath.
+func Read(staticPath string) (_ Manifest, err error) {
+	defer derrors.Wrap(&err, "assets.Read(%q)", staticPath)
+
+	data, err := ioutil.ReadFile(filepath.Join(staticPath, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Write writes m to the manifest file of staticPath.
+func Write(staticPath string, m Manifest) (err error) {
+	defer derrors.Wrap(&err, "assets.Write(%q)", staticPath)
+
+	data, err := m.marshal()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(staticPath, ManifestFile), data, 0644)
+}
+
+// marshal returns the contents of the manifest file of m, which lists the
+// files in order, one per line, so that changes to it are easy to review.
+func (m Manifest) marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Check returns an error naming the files of staticPath whose fingerprinted
+// names in m are missing or out of date, if any.
+func (m Manifest) Check(staticPath string) (err error) {
+	defer derrors.Wrap(&err, "Manifest.Check(%q)", staticPath)
+
+	current, err := Build(staticPath)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for name, fp := range current {
+		if m[name] != fp {
+			stale = append(stale, name)
+		}
+	}
+	for name := range m {
+		if _, ok := current[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		return fmt.Errorf("%s is out of date for %s; run devtools/cmd/fingerprint",
+			ManifestFile, strings.Join(stale, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package assets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFingerprint(t *testing.T) {
+	for _, test := range []struct {
+		name, contents, want string
+	}{
+		{"css/stylesheet.css", "body {}", "css/stylesheet.62368a1a29.css"},
+		{"js/base.min.js", "", "js/base.min.e3b0c44298.js"},
+	} {
+		if got := Fingerprint(test.name, []byte(test.contents)); got != test.want {
+			t.Errorf("Fingerprint(%q, %q) = %q, want %q", test.name, test.contents, got, test.want)
+		}
+	}
+	if Fingerprint("a.css", []byte("x")) == Fingerprint("a.css", []byte("y")) {
+		t.Error("different contents have the same fingerprint")
+	}
+}
+
+func TestBuildWriteCheck(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) {
+		t.Helper()
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("css/stylesheet.css", "body {}")
+	write("css/generate.go", "package main")
+	write("js/base.min.js", "")
+	write("img/logo.svg", "<svg/>")
+
+	m, err := Build(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Manifest{
+		"css/stylesheet.css": "css/stylesheet.62368a1a29.css",
+		"js/base.min.js":     "js/base.min.e3b0c44298.js",
+	}
+	if diff := cmp.Diff(want, m); diff != "" {
+		t.Fatalf("Build mismatch (-want +got):\n%s", diff)
+	}
+	if err := Write(dir, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("Read mismatch (-want +got):\n%s", diff)
+	}
+	if err := got.Check(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	write("css/stylesheet.css", "body { color: red }")
+	write("js/new.js", "")
+	err = got.Check(dir)
+	if err == nil {
+		t.Fatal("Check of a stale manifest succeeded")
+	}
+	for _, name := range []string{"css/stylesheet.css", "js/new.js"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Check error %q does not name %s", err, name)
+		}
+	}
+}
+
+func TestBuildNoDirectories(t *testing.T) {
+	m, err := Build(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 0 {
+		t.Errorf("Build of an empty directory = %v, want no files", m)
+	}
+}
+
+// TestManifestUpToDate checks that the manifest of the frontend's static files
+// has been written since they last changed.
+func TestManifestUpToDate(t *testing.T) {
+	const staticPath = "../../content/static"
+	m, err := Read(staticPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Check(staticPath); err != nil {
+		t.Fatal(err)
+	}
+}
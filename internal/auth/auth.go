@@ -2,7 +2,8 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Package auth authorizes programs to make HTTP requests to the discovery site.
+// Package auth authorizes programs to make HTTP requests to the discovery site,
+// and authenticates the requests that its servers receive.
 package auth
 
 import (
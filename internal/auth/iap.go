@@ -0,0 +1,198 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// IAPAssertionHeader is the header in which Identity-Aware Proxy sends a
+// signed assertion of the identity of the user to the services behind it.
+const IAPAssertionHeader = "X-Goog-IAP-JWT-Assertion"
+
+const (
+	iapIssuer  = "https://cloud.google.com/iap"
+	iapKeysURL = "https://www.gstatic.com/iap/verify/public_key"
+
+	// iapKeysMaxAge is how long the public keys of IAP are used before they
+	// are fetched again. Keys are also fetched when an assertion is signed
+	// with an unknown key, at most every iapKeysMinAge.
+	iapKeysMaxAge = 12 * time.Hour
+	iapKeysMinAge = time.Minute
+
+	// iapClockSkew is how far the clocks of IAP and the server may differ.
+	iapClockSkew = 30 * time.Second
+)
+
+// An IAPVerifier verifies the assertions that Identity-Aware Proxy adds to
+// the requests it lets through, so that a server behind it can trust the
+// identity of the user without trusting the network.
+// See https://cloud.google.com/iap/docs/signed-headers-howto.
+type IAPVerifier struct {
+	audience string
+	keysURL  string
+	client   *http.Client
+	now      func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]*ecdsa.PublicKey // by key ID
+	fetchedAt time.Time
+}
+
+// NewIAPVerifier returns an IAPVerifier for assertions with the given
+// audience, which has the form "/projects/PROJECT_NUMBER/apps/PROJECT_ID" for
+// App Engine.
+func NewIAPVerifier(audience string) *IAPVerifier {
+	return &IAPVerifier{
+		audience: audience,
+		keysURL:  iapKeysURL,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// Verify checks that assertion is a valid, unexpired assertion from IAP for
+// the audience of v, and returns the email address of the user it identifies.
+func (v *IAPVerifier) Verify(ctx context.Context, assertion string) (_ string, err error) {
+	defer derrors.Wrap(&err, "IAPVerifier.Verify")
+
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed assertion")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return "", fmt.Errorf("header: %v", err)
+	}
+	if header.Alg != "ES256" {
+		return "", fmt.Errorf("unexpected algorithm %q", header.Alg)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return "", err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(sig) != 64 {
+		return "", errors.New("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return "", errors.New("bad signature")
+	}
+
+	var claims struct {
+		Iss   string `json:"iss"`
+		Aud   string `json:"aud"`
+		Email string `json:"email"`
+		Exp   int64  `json:"exp"`
+		Iat   int64  `json:"iat"`
+	}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return "", fmt.Errorf("claims: %v", err)
+	}
+	now := v.now()
+	switch {
+	case claims.Iss != iapIssuer:
+		return "", fmt.Errorf("unexpected issuer %q", claims.Iss)
+	case claims.Aud != v.audience:
+		return "", fmt.Errorf("unexpected audience %q", claims.Aud)
+	case now.After(time.Unix(claims.Exp, 0).Add(iapClockSkew)):
+		return "", errors.New("assertion expired")
+	case now.Before(time.Unix(claims.Iat, 0).Add(-iapClockSkew)):
+		return "", errors.New("assertion issued in the future")
+	case claims.Email == "":
+		return "", errors.New("assertion has no email")
+	}
+	return claims.Email, nil
+}
+
+// key returns the public key of IAP with the given ID, fetching the keys if
+// necessary.
+func (v *IAPVerifier) key(ctx context.Context, id string) (*ecdsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := v.now().Sub(v.fetchedAt)
+	if k, ok := v.keys[id]; ok && age < iapKeysMaxAge {
+		return k, nil
+	}
+	if v.keys == nil || age >= iapKeysMinAge {
+		keys, err := v.fetchKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		v.fetchedAt = v.now()
+	}
+	k, ok := v.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", id)
+	}
+	return k, nil
+}
+
+// fetchKeys fetches the public keys of IAP, which are served as a JSON object
+// that maps key IDs to PEM-encoded keys.
+func (v *IAPVerifier) fetchKeys(ctx context.Context) (_ map[string]*ecdsa.PublicKey, err error) {
+	defer derrors.Wrap(&err, "fetchKeys(%q)", v.keysURL)
+
+	resp, err := ctxhttp.Get(ctx, v.client, v.keysURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	var pems map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&pems); err != nil {
+		return nil, err
+	}
+	keys := map[string]*ecdsa.PublicKey{}
+	for id, p := range pems {
+		block, _ := pem.Decode([]byte(p))
+		if block == nil {
+			return nil, fmt.Errorf("key %q: no PEM data", id)
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", id, err)
+		}
+		k, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("key %q: not an ECDSA key", id)
+		}
+		keys[id] = k
+	}
+	return keys, nil
+}
+
+// decodeJWTSegment decodes a base64url-encoded JSON segment of a JWT into v.
+func decodeJWTSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testAudience = "/projects/123/apps/test"
+
+// signAssertion returns a JWT with the given header and claims signed by key.
+func signAssertion(t *testing.T, key *ecdsa.PrivateKey, header, claims interface{}) string {
+	t.Helper()
+	seg := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signed := seg(header) + "." + seg(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestIAPVerifier(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(map[string]string{
+			"k1": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		})
+	}))
+	defer ts.Close()
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	v := NewIAPVerifier(testAudience)
+	v.keysURL = ts.URL
+	v.now = func() time.Time { return now }
+
+	header := map[string]string{"alg": "ES256", "kid": "k1"}
+	claims := func(change func(map[string]interface{})) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":   iapIssuer,
+			"aud":   testAudience,
+			"email": "user@example.com",
+			"iat":   now.Add(-time.Minute).Unix(),
+			"exp":   now.Add(9 * time.Minute).Unix(),
+		}
+		if change != nil {
+			change(c)
+		}
+		return c
+	}
+
+	ctx := context.Background()
+	got, err := v.Verify(ctx, signAssertion(t, key, header, claims(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "user@example.com"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	for _, test := range []struct {
+		name      string
+		assertion string
+	}{
+		{"malformed", "not.a-jwt"},
+		{"other key", signAssertion(t, otherKey, header, claims(nil))},
+		{"unknown key", signAssertion(t, key, map[string]string{"alg": "ES256", "kid": "k2"}, claims(nil))},
+		{"algorithm", signAssertion(t, key, map[string]string{"alg": "none", "kid": "k1"}, claims(nil))},
+		{"issuer", signAssertion(t, key, header, claims(func(c map[string]interface{}) { c["iss"] = "https://example.com" }))},
+		{"audience", signAssertion(t, key, header, claims(func(c map[string]interface{}) { c["aud"] = "/projects/456/apps/other" }))},
+		{"expired", signAssertion(t, key, header, claims(func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() }))},
+		{"future", signAssertion(t, key, header, claims(func(c map[string]interface{}) { c["iat"] = now.Add(time.Hour).Unix() }))},
+		{"no email", signAssertion(t, key, header, claims(func(c map[string]interface{}) { delete(c, "email") }))},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := v.Verify(ctx, test.assertion); err == nil {
+				t.Error("got nil, want error")
+			}
+		})
+	}
+	// The keys are fetched once, and not again for the unknown key, because
+	// they were fetched too recently.
+	if fetches != 1 {
+		t.Errorf("keys fetched %d times, want 1", fetches)
+	}
+}
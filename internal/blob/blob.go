@@ -0,0 +1,86 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package blob stores large, opaque objects, like module zips and the
+// documentation of very large packages, outside of the database.
+//
+// A Bucket is opened from a URL that selects where the objects are stored:
+//
+//	gs://bucket/prefix                        Google Cloud Storage
+//	s3://bucket/prefix?region=us-east-1       Amazon S3 or a compatible service
+//	file:///var/lib/pkgsite/blobs             a directory on local disk
+//
+// For S3, the endpoint query parameter selects a service other than AWS, like
+// MinIO, whose buckets are addressed by path; credentials are found as by the
+// AWS SDK: in environment variables, shared configuration files, or the role
+// of the instance.
+package blob
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A Bucket stores objects under keys, which are '/'-separated paths.
+type Bucket interface {
+	// Write stores data under key, replacing any object already there.
+	Write(ctx context.Context, key string, data []byte) error
+	// Read returns the object stored under key. It returns an error
+	// that wraps derrors.NotFound if there is none.
+	Read(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object stored under key. Deleting a missing
+	// object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Open returns the Bucket described by rawURL. See the package documentation
+// for the forms of the URL.
+func Open(ctx context.Context, rawURL string) (_ Bucket, err error) {
+	defer derrors.Wrap(&err, "blob.Open(ctx, %q)", rawURL)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "gs":
+		return openGCS(ctx, u.Host, prefix)
+	case "s3":
+		return openS3(u.Host, prefix, u.Query())
+	case "file":
+		if u.Host != "" {
+			return nil, fmt.Errorf("file URL must not have a host: %w", derrors.InvalidArgument)
+		}
+		return NewDisk(u.Path)
+	default:
+		return nil, fmt.Errorf("unknown scheme %q: %w", u.Scheme, derrors.InvalidArgument)
+	}
+}
+
+// checkKey returns an error if key is not a valid key: a non-empty
+// '/'-separated path without empty, "." or ".." elements.
+func checkKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("empty key: %w", derrors.InvalidArgument)
+	}
+	for _, elem := range strings.Split(key, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return fmt.Errorf("invalid key %q: %w", key, derrors.InvalidArgument)
+		}
+	}
+	return nil
+}
+
+// join returns the name of the object with key under prefix.
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
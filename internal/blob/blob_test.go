@@ -0,0 +1,192 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// testBucket checks that b behaves like a Bucket.
+func testBucket(t *testing.T, b Bucket) {
+	t.Helper()
+	ctx := context.Background()
+	const key = "zips/github.com/!foo/bar/@v/v1.2.3.zip"
+
+	if _, err := b.Read(ctx, key); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("Read before Write: got %v, want NotFound", err)
+	}
+	for _, data := range []string{"first", "second"} {
+		if err := b.Write(ctx, key, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := b.Read(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Errorf("Read: got %q, want %q", got, data)
+		}
+	}
+	if err := b.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Read(ctx, key); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("Read after Delete: got %v, want NotFound", err)
+	}
+	if err := b.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of missing object: %v", err)
+	}
+	for _, bad := range []string{"", "a/../b", "/a", "a//b", "a/"} {
+		if err := b.Write(ctx, bad, nil); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("Write(%q): got %v, want InvalidArgument", bad, err)
+		}
+	}
+}
+
+func TestDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := Open(context.Background(), "file://"+filepath.ToSlash(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBucket(t, b)
+}
+
+func TestOpenErrors(t *testing.T) {
+	for _, u := range []string{
+		"ftp://bucket",
+		"gs:///prefix",
+		"s3:///prefix",
+		"file://host/dir",
+		"file://",
+	} {
+		if _, err := Open(context.Background(), u); err == nil {
+			t.Errorf("Open(%q) succeeded, want error", u)
+		}
+	}
+}
+
+func TestS3(t *testing.T) {
+	s := newFakeS3()
+	defer s.Close()
+
+	b, err := newS3(s.Client(), "bucket", "prefix", "us-west-2", s.URL,
+		credentials.NewStaticCredentials("AKID", "secret", "token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBucket(t, b)
+	for name := range s.objects {
+		t.Errorf("object %q remains after test", name)
+	}
+}
+
+func TestNewS3(t *testing.T) {
+	creds := credentials.NewStaticCredentials("AKID", "secret", "")
+	for _, test := range []struct {
+		region, endpoint, want string
+	}{
+		{"", "", "https://bucket.s3.amazonaws.com/prefix/a%40b"},
+		{"eu-west-1", "", "https://bucket.s3.eu-west-1.amazonaws.com/prefix/a%40b"},
+		{"", "http://localhost:9000", "http://localhost:9000/bucket/prefix/a%40b"},
+		{"", "http://localhost:9000/minio/", "http://localhost:9000/minio/bucket/prefix/a%40b"},
+	} {
+		b, err := newS3(http.DefaultClient, "bucket", "prefix", test.region, test.endpoint, creds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Record the URL instead of sending the request.
+		var got string
+		b.client.Handlers.Send.Clear()
+		b.client.Handlers.Send.PushBack(func(r *request.Request) {
+			got = r.HTTPRequest.URL.String()
+			r.Error = errors.New("no network")
+			r.Retryable = aws.Bool(false)
+		})
+		b.Read(context.Background(), "a@b")
+		if got != test.want {
+			t.Errorf("region %q, endpoint %q: got URL %q, want %q", test.region, test.endpoint, got, test.want)
+		}
+	}
+	if _, err := newS3(http.DefaultClient, "bucket", "", "", "localhost:9000", creds); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("relative endpoint: got %v, want InvalidArgument", err)
+	}
+}
+
+// fakeS3 is an in-memory server for the subset of the S3 API that s3Bucket
+// uses, addressed by path. It checks that requests are signed, but not their
+// signatures.
+type fakeS3 struct {
+	*httptest.Server
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeS3() *fakeS3 {
+	s := &fakeS3{objects: map[string][]byte{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Amz-Security-Token") != "token" {
+		http.Error(w, "missing session token", http.StatusForbidden)
+		return
+	}
+	name := r.URL.Path
+	if !strings.HasPrefix(name, "/bucket/prefix/") {
+		http.Error(w, "wrong bucket or prefix", http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[name] = data
+	case http.MethodGet:
+		data, ok := s.objects[name]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, name)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "bad method", http.StatusMethodNotAllowed)
+	}
+}
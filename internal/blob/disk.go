@@ -0,0 +1,92 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blob
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// Disk is a Bucket that stores each object in a file under a directory.
+type Disk struct {
+	dir string
+}
+
+// NewDisk returns a Disk that stores its objects under dir, creating dir if
+// necessary.
+func NewDisk(dir string) (_ *Disk, err error) {
+	defer derrors.Wrap(&err, "blob.NewDisk(%q)", dir)
+
+	if dir == "" {
+		return nil, fmt.Errorf("empty directory: %w", derrors.InvalidArgument)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Disk{dir: dir}, nil
+}
+
+// Write implements Bucket.Write. The data is written to a temporary file that
+// is renamed into place, so a reader never sees part of an object.
+func (d *Disk) Write(ctx context.Context, key string, data []byte) (err error) {
+	defer derrors.Wrap(&err, "blob.Disk.Write(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	name := d.filename(key)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once the file is renamed
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// Read implements Bucket.Read.
+func (d *Disk) Read(ctx context.Context, key string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "blob.Disk.Read(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(d.filename(key))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%v: %w", err, derrors.NotFound)
+	}
+	return data, err
+}
+
+// Delete implements Bucket.Delete.
+func (d *Disk) Delete(ctx context.Context, key string) (err error) {
+	defer derrors.Wrap(&err, "blob.Disk.Delete(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if err := os.Remove(d.filename(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *Disk) filename(key string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(key))
+}
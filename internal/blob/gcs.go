@@ -0,0 +1,76 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// gcsBucket is a Bucket that stores its objects in a Google Cloud Storage
+// bucket, under a prefix.
+type gcsBucket struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+func openGCS(ctx context.Context, bucket, prefix string) (*gcsBucket, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("missing bucket: %w", derrors.InvalidArgument)
+	}
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsBucket{bucket: client.Bucket(bucket), prefix: prefix}, nil
+}
+
+func (b *gcsBucket) Write(ctx context.Context, key string, data []byte) (err error) {
+	defer derrors.Wrap(&err, "blob.gcsBucket.Write(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	w := b.bucket.Object(join(b.prefix, key)).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (b *gcsBucket) Read(ctx context.Context, key string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "blob.gcsBucket.Read(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	r, err := b.bucket.Object(join(b.prefix, key)).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%v: %w", err, derrors.NotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (b *gcsBucket) Delete(ctx context.Context, key string) (err error) {
+	defer derrors.Wrap(&err, "blob.gcsBucket.Delete(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if err := b.bucket.Object(join(b.prefix, key)).Delete(ctx); err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return err
+	}
+	return nil
+}
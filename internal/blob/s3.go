@@ -0,0 +1,140 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// s3Timeout bounds each request to S3, including reading its body. It is
+// long enough to transfer the largest module zips.
+const s3Timeout = 5 * time.Minute
+
+// s3Bucket is a Bucket that stores its objects in an Amazon S3 bucket, or a
+// bucket of a service with a compatible API, under a prefix.
+type s3Bucket struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+// openS3 returns an s3Bucket for the bucket, configured by the query
+// parameters of its URL. Credentials are found by the AWS SDK's default
+// chain: environment variables, shared configuration files, then the role of
+// the ECS task or EC2 instance.
+//
+// The HTTP client uses the default transport, because the SDK can only add
+// the certificates of AWS_CA_BUNDLE to an *http.Transport.
+func openS3(bucket, prefix string, query url.Values) (*s3Bucket, error) {
+	httpClient := &http.Client{Timeout: s3Timeout}
+	return newS3(httpClient, bucket, prefix, query.Get("region"), query.Get("endpoint"), nil)
+}
+
+// newS3 returns an s3Bucket that sends requests with httpClient. If creds is
+// nil, the default credential chain is used.
+func newS3(httpClient *http.Client, bucket, prefix, region, endpoint string, creds *credentials.Credentials) (*s3Bucket, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("missing bucket: %w", derrors.InvalidArgument)
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	cfg := aws.NewConfig().
+		WithHTTPClient(httpClient).
+		WithRegion(region).
+		WithCredentials(creds)
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("endpoint %q is not an absolute URL: %w", endpoint, derrors.InvalidArgument)
+		}
+		// Services other than AWS, like MinIO, address buckets by path.
+		cfg = cfg.WithEndpoint(endpoint).WithS3ForcePathStyle(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *cfg,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Bucket{client: s3.New(sess), bucket: bucket, prefix: prefix}, nil
+}
+
+func (b *s3Bucket) Write(ctx context.Context, key string, data []byte) (err error) {
+	defer derrors.Wrap(&err, "blob.s3Bucket.Write(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	_, err = b.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(join(b.prefix, key)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (b *s3Bucket) Read(ctx context.Context, key string) (_ []byte, err error) {
+	defer derrors.Wrap(&err, "blob.s3Bucket.Read(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	out, err := b.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(join(b.prefix, key)),
+	})
+	if isS3NotFound(err) {
+		return nil, fmt.Errorf("%v: %w", err, derrors.NotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return ioutil.ReadAll(out.Body)
+}
+
+func (b *s3Bucket) Delete(ctx context.Context, key string) (err error) {
+	defer derrors.Wrap(&err, "blob.s3Bucket.Delete(ctx, %q)", key)
+
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	// S3 answers 204 whether or not the object existed, but compatible
+	// services may answer 404.
+	_, err = b.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(join(b.prefix, key)),
+	})
+	if isS3NotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// isS3NotFound reports whether err is a response from S3 saying that the
+// object does not exist.
+func isS3NotFound(err error) bool {
+	var rf awserr.RequestFailure
+	return errors.As(err, &rf) && rf.StatusCode() == http.StatusNotFound
+}
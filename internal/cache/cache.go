@@ -0,0 +1,237 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cache implements the cache of rendered pages, in Redis.
+//
+// Pages are stored under versioned keys, so that changing the format of
+// entries only requires bumping keyVersion: the old entries are then ignored,
+// and expire. Their TTLs are shortened by a random jitter, so that pages
+// cached at the same time do not all expire at the same time.
+//
+// A cached page can be tagged with the paths it shows. Invalidate removes the
+// pages with any of the given tags, so that pages can be removed as soon as
+// the data they show changes, instead of when they expire.
+//
+// The cache also records pages that are known not to exist, so that repeated
+// requests for them need not reach the database.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+const (
+	// keyVersion is part of every key. Bump it when the format of the cached
+	// pages changes.
+	keyVersion = 1
+
+	// expiryJitter is the largest fraction by which a TTL is shortened.
+	expiryJitter = 0.1
+)
+
+// A Cache stores pages in Redis.
+type Cache struct {
+	client *redis.Client
+}
+
+// New returns a Cache that stores pages with client.
+func New(client *redis.Client) *Cache {
+	return &Cache{client: client}
+}
+
+// pageKey returns the key of the page for url in the cache with the given
+// name.
+func pageKey(name, url string) string {
+	return fmt.Sprintf("page:v%d:%s:%s", keyVersion, name, url)
+}
+
+// tagKey returns the key of the set of the keys of the pages with tag.
+func tagKey(tag string) string {
+	return fmt.Sprintf("tag:v%d:%s", keyVersion, tag)
+}
+
+// PathTag returns the tag for pages about the given module, package or
+// directory path.
+func PathTag(path string) string {
+	return "path:" + path
+}
+
+// Get returns the page for url in the cache with the given name, and the time
+// until it expires, which is negative if it never does. It returns an error
+// wrapping derrors.NotFound if the page is not in the cache.
+func (c *Cache) Get(ctx context.Context, name, url string) (_ []byte, ttl time.Duration, err error) {
+	defer derrors.Wrap(&err, "cache.Get(%q, %q)", name, url)
+
+	key := pageKey(name, url)
+	var (
+		get  *redis.StringCmd
+		pttl *redis.DurationCmd
+	)
+	_, err = c.client.WithContext(ctx).Pipelined(func(p redis.Pipeliner) error {
+		get = p.Get(key)
+		pttl = p.PTTL(key)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, 0, derrors.NotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := get.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, pttl.Val(), nil
+}
+
+// Put stores data as the page for url in the cache with the given name, for
+// at most ttl, and tags it with tags.
+func (c *Cache) Put(ctx context.Context, name, url string, data []byte, ttl time.Duration, tags []string) (err error) {
+	defer derrors.Wrap(&err, "cache.Put(%q, %q)", name, url)
+
+	key := pageKey(name, url)
+	ttl = jitter(ttl)
+	_, err = c.client.WithContext(ctx).TxPipelined(func(p redis.Pipeliner) error {
+		p.Set(key, data, ttl)
+		for _, tag := range tags {
+			tagScript.Eval(p, []string{tagKey(tag)}, key, ttl.Milliseconds())
+		}
+		return nil
+	})
+	return err
+}
+
+// tagScript adds the page key ARGV[1] to the tag set KEYS[1]. A tag must last
+// as long as the pages it refers to, so the TTL of the set is only ever
+// extended, to ARGV[2] milliseconds, or removed if ARGV[2] is not positive,
+// for a page that never expires. Keys of pages that have expired are removed
+// from the set when it is invalidated.
+var tagScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+local current = redis.call("PTTL", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+if ttl <= 0 then
+	redis.call("PERSIST", KEYS[1])
+elseif current == -2 or (current >= 0 and current < ttl) then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// jitter returns ttl shortened by a random fraction of up to expiryJitter.
+func jitter(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return ttl
+	}
+	return ttl - time.Duration(rand.Float64()*expiryJitter*float64(ttl))
+}
+
+// Invalidate removes the pages tagged with any of tags from the cache.
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) (err error) {
+	defer derrors.Wrap(&err, "cache.Invalidate(%q)", tags)
+
+	client := c.client.WithContext(ctx)
+	var members []*redis.StringSliceCmd
+	if _, err := client.Pipelined(func(p redis.Pipeliner) error {
+		for _, tag := range tags {
+			members = append(members, p.SMembers(tagKey(tag)))
+		}
+		return nil
+	}); err != nil && err != redis.Nil {
+		return err
+	}
+	var keys []string
+	for i, m := range members {
+		keys = append(keys, m.Val()...)
+		keys = append(keys, tagKey(tags[i]))
+	}
+	if err := client.Del(keys...).Err(); err != nil {
+		return err
+	}
+	log.Debugf(ctx, "cache: invalidated %d keys for %d tags", len(keys)-len(tags), len(tags))
+	return nil
+}
+
+// InvalidateModule removes the pages about modulePath and paths from the
+// cache. Its signature is that of postgres.ModuleHook, so that it can be
+// called when a module changes. It only logs errors, since the pages expire
+// anyway.
+func (c *Cache) InvalidateModule(ctx context.Context, modulePath string, paths []string) {
+	tags := []string{PathTag(modulePath)}
+	for _, p := range paths {
+		if p != modulePath {
+			tags = append(tags, PathTag(p))
+		}
+	}
+	if err := c.Invalidate(ctx, tags...); err != nil {
+		log.Errorf(ctx, "invalidating pages of module %s: %v", modulePath, err)
+	}
+}
+
+// missingName is the name of the cache of pages that are known not to exist.
+const missingName = "missing"
+
+// MarkMissing records that the page for url, which would show path, does not
+// exist, for at most ttl. The record is removed when a module with path is
+// invalidated.
+func (c *Cache) MarkMissing(ctx context.Context, url, path string, ttl time.Duration) error {
+	return c.Put(ctx, missingName, url, nil, ttl, []string{PathTag(path)})
+}
+
+// IsMissing reports whether the page for url was recorded as not existing
+// with MarkMissing.
+func (c *Cache) IsMissing(ctx context.Context, url string) (bool, error) {
+	_, _, err := c.Get(ctx, missingName, url)
+	if errors.Is(err, derrors.NotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+type tagsKey struct{}
+
+// tagSet holds the tags of the page being rendered.
+type tagSet struct {
+	mu   sync.Mutex
+	tags []string
+}
+
+// NewContextWithTags returns a context in which Tag records tags for the page
+// being rendered. Tags returns them.
+func NewContextWithTags(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tagsKey{}, &tagSet{})
+}
+
+// Tag tags the page being rendered with ctx. It does nothing if ctx does not
+// come from NewContextWithTags, such as when the page is not cached.
+func Tag(ctx context.Context, tags ...string) {
+	ts, ok := ctx.Value(tagsKey{}).(*tagSet)
+	if !ok {
+		return
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.tags = append(ts.tags, tags...)
+}
+
+// Tags returns the tags recorded with Tag.
+func Tags(ctx context.Context) []string {
+	ts, ok := ctx.Value(tagsKey{}).(*tagSet)
+	if !ok {
+		return nil
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.tags...)
+}
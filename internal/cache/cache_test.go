@@ -0,0 +1,166 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func newTestCache(t *testing.T) *Cache {
+	t.Helper()
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return New(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+}
+
+func TestPutGet(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+
+	if _, _, err := c.Get(ctx, "details", "/a"); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("Get before Put: got %v, want NotFound", err)
+	}
+	const ttl = time.Hour
+	if err := c.Put(ctx, "details", "/a", []byte("page a"), ttl, nil); err != nil {
+		t.Fatal(err)
+	}
+	got, gotTTL, err := c.Get(ctx, "details", "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "page a" {
+		t.Errorf("got %q, want %q", got, "page a")
+	}
+	if min := time.Duration(float64(ttl) * (1 - expiryJitter)); gotTTL < min || gotTTL > ttl {
+		t.Errorf("got TTL %s, want between %s and %s", gotTTL, min, ttl)
+	}
+	// Caches with different names do not share pages.
+	if _, _, err := c.Get(ctx, "search", "/a"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("Get from other cache: got %v, want NotFound", err)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	const ttl = time.Minute
+	for i := 0; i < 100; i++ {
+		if got := jitter(ttl); got > ttl || got < time.Duration(float64(ttl)*(1-expiryJitter)) {
+			t.Fatalf("jitter(%s) = %s, out of range", ttl, got)
+		}
+	}
+	if got := jitter(0); got != 0 {
+		t.Errorf("jitter(0) = %s, want 0", got)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+
+	pages := map[string][]string{
+		"/m":          {PathTag("m")},
+		"/m/pkg":      {PathTag("m/pkg"), PathTag("m")},
+		"/other":      {PathTag("other")},
+		"/search?q=m": nil,
+	}
+	for url, tags := range pages {
+		if err := c.Put(ctx, "details", url, []byte(url), time.Hour, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.InvalidateModule(ctx, "m", []string{"m", "m/pkg"})
+
+	var got []string
+	for url := range pages {
+		_, _, err := c.Get(ctx, "details", url)
+		switch {
+		case err == nil:
+			got = append(got, url)
+		case !errors.Is(err, derrors.NotFound):
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(got)
+	want := []string{"/other", "/search?q=m"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("remaining pages mismatch (-want +got):\n%s", diff)
+	}
+
+	// Invalidating tags without pages is not an error.
+	if err := c.Invalidate(ctx, PathTag("none")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTagTTL(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t)
+	tagTTL := func() time.Duration {
+		t.Helper()
+		d, err := c.client.PTTL(tagKey(PathTag("m"))).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	put := func(url string, ttl time.Duration) {
+		t.Helper()
+		if err := c.Put(ctx, "details", url, []byte(url), ttl, []string{PathTag("m")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	put("/m", 10*time.Hour)
+	long := tagTTL()
+	if long < 9*time.Hour {
+		t.Fatalf("tag TTL = %s, want about 10h", long)
+	}
+	// A page with a shorter TTL does not shorten the tag's.
+	put("/m?tab=versions", time.Minute)
+	if got := tagTTL(); got < long-time.Second {
+		t.Errorf("after a shorter TTL: tag TTL = %s, want at least %s", got, long)
+	}
+	// A page with a longer TTL extends it.
+	put("/m?tab=imports", 100*time.Hour)
+	if got := tagTTL(); got <= long {
+		t.Errorf("after a longer TTL: tag TTL = %s, want more than %s", got, long)
+	}
+	// A page that never expires keeps the tag forever.
+	put("/m?tab=licenses", 0)
+	if got := tagTTL(); got >= 0 {
+		t.Errorf("after no TTL: tag TTL = %s, want none", got)
+	}
+	put("/m?tab=overview", time.Minute)
+	if got := tagTTL(); got >= 0 {
+		t.Errorf("after no TTL, then a TTL: tag TTL = %s, want none", got)
+	}
+}
+
+func TestTags(t *testing.T) {
+	ctx := context.Background()
+	// Tagging a context without tags does nothing.
+	Tag(ctx, "a")
+	if got := Tags(ctx); got != nil {
+		t.Errorf("Tags without NewContextWithTags = %v, want nil", got)
+	}
+
+	ctx = NewContextWithTags(ctx)
+	Tag(ctx, "a")
+	Tag(ctx, "b", "c")
+	if diff := cmp.Diff([]string{"a", "b", "c"}, Tags(ctx)); diff != "" {
+		t.Errorf("Tags mismatch (-want +got):\n%s", diff)
+	}
+}
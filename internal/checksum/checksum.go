@@ -0,0 +1,207 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package checksum verifies module hashes against a checksum database, such
+// as sum.golang.org.
+package checksum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+const (
+	// DefaultURL is the URL of the Go checksum database.
+	DefaultURL = "https://sum.golang.org"
+	// DefaultKey is the verifier key of the Go checksum database.
+	DefaultKey = "sum.golang.org+033de0ae+Ac4zctda0e5eza+HJyk9SxEdh+s3Ap18OTK2Xh1KeMh0g"
+)
+
+// A Result is the outcome of verifying a module version against the checksum
+// database.
+type Result string
+
+const (
+	// NotChecked means the module version was not verified.
+	NotChecked Result = ""
+	// Verified means the hashes of the module version match the checksum
+	// database.
+	Verified Result = "verified"
+	// Unverified means the checksum database could not be consulted, for
+	// example because it has no record of the module version.
+	Unverified Result = "unverified"
+	// Mismatch means the hashes of the module version do not match the
+	// checksum database.
+	Mismatch Result = "mismatch"
+)
+
+// maxLookups is the number of lookups after which a Client replaces its
+// sumdb.Client.
+const maxLookups = 1000
+
+// A Client verifies module hashes against a checksum database.
+//
+// A sumdb.Client remembers the result of every lookup and every tile it
+// reads, for as long as it lives, so a Client replaces its sumdb.Client every
+// maxLookups lookups, and after a lookup fails. The latest signed tree is kept
+// across replacements, so that the checksum database cannot roll it back.
+type Client struct {
+	ops     *ops
+	noSumDB string
+
+	mu      sync.Mutex
+	sc      *sumdb.Client // nil if it must be replaced
+	lookups int           // lookups made with sc
+}
+
+// New returns a Client for the checksum database served at url, whose
+// signatures are verified with key. Requests are made with httpClient, which
+// should have a timeout, because the sumdb client does not support contexts.
+func New(url, key string, httpClient *http.Client) *Client {
+	return &Client{ops: &ops{
+		url:        strings.TrimRight(url, "/"),
+		key:        key,
+		httpClient: httpClient,
+		config:     map[string][]byte{},
+	}}
+}
+
+// SetNoSumDB sets a comma-separated list of glob patterns of module path
+// prefixes, in the style of GONOSUMDB, for modules that should not be
+// verified. SetNoSumDB must be called before Verify.
+func (c *Client) SetNoSumDB(patterns string) {
+	c.noSumDB = patterns
+}
+
+// sumDBClient returns the sumdb.Client for the next lookup, creating it if
+// needed.
+func (c *Client) sumDBClient() *sumdb.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sc == nil || c.lookups >= maxLookups {
+		c.sc = sumdb.NewClient(c.ops)
+		if c.noSumDB != "" {
+			c.sc.SetGONOSUMDB(c.noSumDB)
+		}
+		c.lookups = 0
+	}
+	c.lookups++
+	return c.sc
+}
+
+// forget arranges for sc to be replaced, so that the failures it remembers
+// are not reported again.
+func (c *Client) forget(sc *sumdb.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sc == sc {
+		c.sc = nil
+	}
+}
+
+// Verify checks zipHash and goModHash, the go.sum hashes of the zip and
+// go.mod file of modulePath@version, against the checksum database. The
+// error describes why the result is not Verified. Modules matching the
+// patterns passed to SetNoSumDB are NotChecked.
+func (c *Client) Verify(modulePath, version, zipHash, goModHash string) (_ Result, err error) {
+	defer derrors.Wrap(&err, "checksum.Client.Verify(%q, %q)", modulePath, version)
+
+	for _, v := range []struct{ version, hash string }{
+		{version, zipHash},
+		{version + "/go.mod", goModHash},
+	} {
+		sc := c.sumDBClient()
+		lines, err := sc.Lookup(modulePath, v.version)
+		if err == sumdb.ErrGONOSUMDB {
+			return NotChecked, nil
+		}
+		if err != nil {
+			c.forget(sc)
+			return Unverified, err
+		}
+		want := fmt.Sprintf("%s %s %s", modulePath, v.version, v.hash)
+		if !contains(lines, want) {
+			return Mismatch, fmt.Errorf("%s@%s: got hash %s, checksum database has %q", modulePath, v.version, v.hash, lines)
+		}
+	}
+	return Verified, nil
+}
+
+func contains(lines []string, s string) bool {
+	for _, l := range lines {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ops implements sumdb.ClientOps. The latest signed tree is kept in memory,
+// and tiles are not cached here; see Client for the memory held by the
+// sumdb.Client itself.
+type ops struct {
+	url        string
+	key        string
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	config map[string][]byte
+}
+
+func (o *ops) ReadRemote(path string) (_ []byte, err error) {
+	u := o.url + path
+	r, err := ctxhttp.Get(context.Background(), o.httpClient, u)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", u, r.Status)
+	}
+	return ioutil.ReadAll(r.Body)
+}
+
+func (o *ops) ReadConfig(file string) ([]byte, error) {
+	if file == "key" {
+		return []byte(o.key), nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// A missing file is an empty signed tree.
+	return o.config[file], nil
+}
+
+func (o *ops) WriteConfig(file string, old, new []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if string(o.config[file]) != string(old) {
+		return sumdb.ErrWriteConflict
+	}
+	o.config[file] = new
+	return nil
+}
+
+func (o *ops) ReadCache(file string) ([]byte, error) {
+	return nil, errors.New("not cached")
+}
+
+func (o *ops) WriteCache(file string, data []byte) {}
+
+func (o *ops) Log(msg string) {
+	log.Info(context.Background(), msg)
+}
+
+func (o *ops) SecurityError(msg string) {
+	log.Error(context.Background(), msg)
+}
@@ -0,0 +1,96 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package checksum
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
+)
+
+func TestVerify(t *testing.T) {
+	const (
+		modulePath = "example.com/mod"
+		zipHash    = "h1:zip="
+		goModHash  = "h1:mod="
+	)
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sumdb.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gosum := func(path, vers string) ([]byte, error) {
+		if path != modulePath || vers == "v9.0.0" {
+			return nil, fmt.Errorf("%s@%s: not found", path, vers)
+		}
+		return []byte(fmt.Sprintf("%s %s %s\n%[1]s %[2]s/go.mod %[4]s\n", path, vers, zipHash, goModHash)), nil
+	}
+	srv := httptest.NewServer(sumdb.NewServer(sumdb.NewTestServer(skey, gosum)))
+	defer srv.Close()
+	c := New(srv.URL, vkey, srv.Client())
+	c.SetNoSumDB("private.example.com")
+
+	for _, test := range []struct {
+		modulePath, version, zipHash, goModHash string
+		want                                    Result
+	}{
+		{modulePath, "v1.0.0", zipHash, goModHash, Verified},
+		{modulePath, "v1.1.0", "h1:other=", goModHash, Mismatch},
+		{modulePath, "v1.2.0", zipHash, "h1:other=", Mismatch},
+		{modulePath, "v9.0.0", zipHash, goModHash, Unverified},
+		{"example.com/other", "v1.0.0", zipHash, goModHash, Unverified},
+		{"private.example.com/mod", "v1.0.0", zipHash, goModHash, NotChecked},
+	} {
+		got, err := c.Verify(test.modulePath, test.version, test.zipHash, test.goModHash)
+		if got != test.want {
+			t.Errorf("Verify(%q, %q, %q, %q) = %q, %v; want %q",
+				test.modulePath, test.version, test.zipHash, test.goModHash, got, err, test.want)
+		}
+		if (err == nil) != (got == Verified || got == NotChecked) {
+			t.Errorf("Verify(%q, %q): got result %q with error %v", test.modulePath, test.version, got, err)
+		}
+	}
+}
+
+func TestVerifyDoesNotRememberFailures(t *testing.T) {
+	const (
+		modulePath = "example.com/mod"
+		zipHash    = "h1:zip="
+		goModHash  = "h1:mod="
+	)
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sumdb.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The checksum database does not know the module until it is published.
+	var published int32
+	gosum := func(path, vers string) ([]byte, error) {
+		if atomic.LoadInt32(&published) == 0 {
+			return nil, fmt.Errorf("%s@%s: not found", path, vers)
+		}
+		return []byte(fmt.Sprintf("%s %s %s\n%[1]s %[2]s/go.mod %[4]s\n", path, vers, zipHash, goModHash)), nil
+	}
+	srv := httptest.NewServer(sumdb.NewServer(sumdb.NewTestServer(skey, gosum)))
+	defer srv.Close()
+	c := New(srv.URL, vkey, srv.Client())
+
+	if got, _ := c.Verify(modulePath, "v1.0.0", zipHash, goModHash); got != Unverified {
+		t.Fatalf("before publication: got %q, want %q", got, Unverified)
+	}
+	atomic.StoreInt32(&published, 1)
+	if got, err := c.Verify(modulePath, "v1.0.0", zipHash, goModHash); got != Verified {
+		t.Errorf("after publication: got %q, %v; want %q", got, err, Verified)
+	}
+	// The sumdb client is replaced after maxLookups lookups.
+	sc := c.sumDBClient()
+	c.lookups = maxLookups
+	if c.sumDBClient() == sc {
+		t.Error("sumdb client was not replaced after maxLookups lookups")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -61,9 +62,14 @@ func ValidateAppVersion(appVersion string) error {
 // Config holds shared configuration values used in instantiating our server
 // components.
 type Config struct {
-	// Discovery environment variables
+	// Discovery environment variables. ProxyURL may be a comma-separated
+	// list of proxies, which are tried in order.
 	ProxyURL, IndexURL string
 
+	// VulnDBURL is the URL of the Go vulnerability database, from which the
+	// worker syncs advisories.
+	VulnDBURL string
+
 	// Ports used for hosting. 'DebugPort' is used for serving HTTP debug pages.
 	Port, DebugPort string
 
@@ -95,10 +101,95 @@ type Config struct {
 	// cache instance as it has different availability requirements.
 	RedisHAHost, RedisHAPort string
 
+	// QueueBackend selects the task queue that schedules fetches: "gcp" for
+	// Cloud Tasks, "postgres" for the fetch_queue table of the database,
+	// "redis" for a Redis stream at RedisQueueHost and RedisQueuePort, or
+	// "inmemory" for a queue in the process. If it is empty, Cloud Tasks is
+	// used on AppEngine and the in-memory queue elsewhere.
+	QueueBackend                   string
+	RedisQueueHost, RedisQueuePort string
+
+	// BlobURL is the URL of the bucket that keeps large objects out of the
+	// database, like "gs://bucket/prefix" or "file:///var/lib/pkgsite"; see
+	// blob.Open. Nothing is stored there if it is empty. Documentation HTML
+	// larger than BlobDocThresholdKB kilobytes is stored in the bucket, unless
+	// the threshold is zero, and so are the zips of the modules that the
+	// worker fetches if BlobZips is set.
+	BlobURL            string
+	BlobDocThresholdKB int
+	BlobZips           bool
+
 	// UseProfiler specifies whether to enable Stackdriver Profiler.
 	UseProfiler bool
 
+	// OTLPEndpoint is the address of the OpenTelemetry collector that traces
+	// are exported to, such as "localhost:55680". Traces are not exported if
+	// it is empty. OTLPInsecure disables TLS for the connection to it.
+	OTLPEndpoint string
+	OTLPInsecure bool
+
+	// RequestLogSampleRate is the fraction of successful requests that the
+	// RequestLog middleware logs, from 0 to 1. Failed requests are always
+	// logged.
+	RequestLogSampleRate float64
+
+	// SearchQuerySampleRate is the fraction of searches that the frontend
+	// records in the search_queries table, from 0 to 1.
+	SearchQuerySampleRate float64
+
+	// Configuration for private modules, in the style of the go command's
+	// GOPRIVATE and GONOSUMDB. Modules matching PrivatePatterns are fetched
+	// only from PrivateProxyURL, using the credentials in PrivateProxyNetrc
+	// or PrivateProxyHeader. Modules matching NoSumDBPatterns are not
+	// verified against the checksum database.
+	PrivatePatterns, NoSumDBPatterns   string
+	PrivateProxyURL, PrivateProxyNetrc string
+	PrivateProxyHeader                 string `json:"-"`
+
+	// ServedPrefixes restricts the modules that the deployment fetches,
+	// indexes and serves to those under these path prefixes, like
+	// "corp.example.com/". Every module is served if it is empty. See
+	// internal.SetServedPrefixes.
+	ServedPrefixes []string
+
+	// LicensePolicy is the path of a YAML file with rules that decide which
+	// licenses are redistributable. See licenses.Policy.
+	LicensePolicy string
+
+	// SourceHosts is the path of a YAML file that describes how to link to
+	// source on code hosting sites that are not otherwise recognized, such
+	// as self-hosted GitLab or Gitea instances. See source.Host.
+	SourceHosts string
+
+	// GitHubToken authenticates requests to the GitHub API made when
+	// checking whether repositories are archived.
+	GitHubToken string `json:"-"`
+
+	// ExperimentOverrideKey is the key that signs experiment overrides.
+	// Overrides are disabled if it is empty. See
+	// middleware.ExperimentOverrides.
+	ExperimentOverrideKey string `json:"-"`
+
+	// ServerTiming adds Server-Timing headers to all responses of the
+	// frontend. Otherwise, they are only added for requests that carry
+	// ServerTimingKey, if it is not empty. See middleware.ServerTiming.
+	ServerTiming    bool
+	ServerTimingKey string `json:"-"`
+
+	// Authentication of the admin endpoints of the worker. IAPAudience is
+	// the audience of the assertions of Identity-Aware Proxy, of the form
+	// "/projects/PROJECT_NUMBER/apps/PROJECT_ID"; IAP users are not accepted
+	// if it is empty. AdminTokens maps bearer tokens to the names of their
+	// holders. CSRFKey signs the CSRF tokens of the forms on admin pages.
+	IAPAudience string
+	AdminTokens map[string]string `json:"-"`
+	CSRFKey     string            `json:"-"`
+
 	Quota QuotaSettings
+
+	Crawl CrawlSettings
+
+	TableStats TableStatsSettings
 }
 
 // AppVersionLabel returns the version label for the current instance.  This is
@@ -184,6 +275,7 @@ type configOverride struct {
 	DBSecondaryHost string
 	DBName          string
 	Quota           QuotaSettings
+	Crawl           CrawlSettings
 }
 
 // QuotaSettings is config for internal/middleware/quota.go
@@ -197,6 +289,61 @@ type QuotaSettings struct {
 	// AcceptedURLs is the list of URLs that will be ignored by the quota
 	// middleware.
 	AcceptedURLs []string
+
+	// Anonymous are the limits for requests to Paths from clients without
+	// an API key, per IP block. Unlike QPS and Burst, they are always
+	// enforced.
+	Anonymous QuotaTier
+	// Keyed are the limits for requests to Paths from clients with an API
+	// key, per key.
+	Keyed QuotaTier
+	// Crawler are the limits for requests to Paths and CrawlerPaths from
+	// clients without an API key whose User-Agent identifies them as
+	// crawlers, per IP block.
+	Crawler QuotaTier
+	// APIKeys are the keys that clients can send to get the keyed limits.
+	APIKeys []string `json:"-"`
+	// Paths are the URL path prefixes whose requests get the limits of the
+	// tiers above.
+	Paths []string
+	// CrawlerPaths are the URL path prefixes whose requests get the crawler
+	// limits when they come from crawlers, in addition to Paths.
+	CrawlerPaths []string
+}
+
+// CrawlSettings is config for steering crawlers away from pages that are
+// expensive to serve and of little value in search results, in the robots.txt
+// and robots meta tags of the frontend.
+type CrawlSettings struct {
+	// Disallow are the robots.txt patterns of URLs that crawlers should not
+	// crawl, in addition to search results, which are never crawled.
+	Disallow []string
+	// MaxIndexedPage is the last page of paginated lists that crawlers are
+	// asked to index; later pages are marked noindex. It is unlimited if
+	// zero.
+	MaxIndexedPage int
+	// NoIndexPseudoVersions marks the pages of pseudo-versions noindex, so
+	// that only tagged and latest versions are indexed.
+	NoIndexPseudoVersions bool
+}
+
+// TableStatsSettings are the thresholds on the statistics of database tables
+// above which the worker logs an alert when it records them.
+type TableStatsSettings struct {
+	// MaxDeadTupleRatio is the largest fraction of the tuples of a table
+	// that may be dead, from 0 to 1.
+	MaxDeadTupleRatio float64
+	// MaxVacuumAge is the longest a table may go without being vacuumed.
+	MaxVacuumAge time.Duration
+	// MinDeadTuples is the number of dead tuples below which a table is
+	// never reported, so that small tables do not cause alerts.
+	MinDeadTuples int64
+}
+
+// QuotaTier describes a token bucket.
+type QuotaTier struct {
+	QPS   int // allowed queries per second; the rate at which the bucket fills
+	Burst int // the size of the token bucket
 }
 
 const overrideBucket = "go-discovery"
@@ -210,6 +357,7 @@ func Init(ctx context.Context) (_ *Config, err error) {
 	cfg := &Config{
 		IndexURL:  GetEnv("GO_MODULE_INDEX_URL", "https://index.golang.org/index"),
 		ProxyURL:  GetEnv("GO_MODULE_PROXY_URL", "https://proxy.golang.org"),
+		VulnDBURL: GetEnv("GO_DISCOVERY_VULN_DB_URL", "https://storage.googleapis.com/go-vulndb"),
 		Port:      os.Getenv("PORT"),
 		DebugPort: os.Getenv("DEBUG_PORT"),
 		// Resolve AppEngine identifiers
@@ -235,15 +383,76 @@ func Init(ctx context.Context) (_ *Config, err error) {
 		RedisCachePort:       GetEnv("GO_DISCOVERY_REDIS_PORT", "6379"),
 		RedisHAHost:          os.Getenv("GO_DISCOVERY_REDIS_HA_HOST"),
 		RedisHAPort:          GetEnv("GO_DISCOVERY_REDIS_HA_PORT", "6379"),
+		QueueBackend:         os.Getenv("GO_DISCOVERY_QUEUE_BACKEND"),
+		RedisQueueHost:       os.Getenv("GO_DISCOVERY_REDIS_QUEUE_HOST"),
+		RedisQueuePort:       GetEnv("GO_DISCOVERY_REDIS_QUEUE_PORT", "6379"),
+		BlobURL:              os.Getenv("GO_DISCOVERY_BLOB_URL"),
+		BlobZips:             os.Getenv("GO_DISCOVERY_BLOB_ZIPS") == "TRUE",
 		Quota: QuotaSettings{
 			QPS:          10,
 			Burst:        20,
 			MaxEntries:   1000,
 			RecordOnly:   func() *bool { t := true; return &t }(),
 			AcceptedURLs: parseCommaList(GetEnv("GO_DISCOVERY_ACCEPTED_LIST", "")),
+			Anonymous:    QuotaTier{QPS: 2, Burst: 20},
+			Keyed:        QuotaTier{QPS: 20, Burst: 100},
+			Crawler:      QuotaTier{QPS: 5, Burst: 50},
+			APIKeys:      parseCommaList(os.Getenv("GO_DISCOVERY_API_KEYS")),
+			Paths:        []string{"/search", "/fetch/", "/files/"},
+			// Crawlers are limited on every path.
+			CrawlerPaths: []string{"/"},
+		},
+		Crawl: CrawlSettings{
+			Disallow:              parseCommaList(GetEnv("GO_DISCOVERY_ROBOTS_DISALLOW", "/*?tab=importedby")),
+			MaxIndexedPage:        2,
+			NoIndexPseudoVersions: true,
+		},
+		TableStats: TableStatsSettings{
+			MaxDeadTupleRatio: 0.2,
+			MaxVacuumAge:      7 * 24 * time.Hour,
+			MinDeadTuples:     10000,
 		},
-		UseProfiler: os.Getenv("GO_DISCOVERY_USE_PROFILER") == "TRUE",
+		UseProfiler:        os.Getenv("GO_DISCOVERY_USE_PROFILER") == "TRUE",
+		OTLPEndpoint:       os.Getenv("GO_DISCOVERY_OTLP_ENDPOINT"),
+		OTLPInsecure:       os.Getenv("GO_DISCOVERY_OTLP_INSECURE") == "TRUE",
+		PrivatePatterns:    os.Getenv("GO_DISCOVERY_PRIVATE"),
+		PrivateProxyURL:    os.Getenv("GO_DISCOVERY_PRIVATE_PROXY_URL"),
+		PrivateProxyNetrc:  os.Getenv("GO_DISCOVERY_PRIVATE_PROXY_NETRC"),
+		PrivateProxyHeader: os.Getenv("GO_DISCOVERY_PRIVATE_PROXY_HEADER"),
+		ServedPrefixes:     parseCommaList(os.Getenv("GO_DISCOVERY_SERVED_PREFIXES")),
+		LicensePolicy:      os.Getenv("GO_DISCOVERY_LICENSE_POLICY"),
+		SourceHosts:        os.Getenv("GO_DISCOVERY_SOURCE_HOSTS"),
+		GitHubToken:        os.Getenv("GO_DISCOVERY_GITHUB_TOKEN"),
+
+		ExperimentOverrideKey: os.Getenv("GO_DISCOVERY_EXPERIMENT_OVERRIDE_KEY"),
+		ServerTiming:          os.Getenv("GO_DISCOVERY_SERVER_TIMING") == "TRUE",
+		ServerTimingKey:       os.Getenv("GO_DISCOVERY_SERVER_TIMING_KEY"),
+		IAPAudience:           os.Getenv("GO_DISCOVERY_IAP_AUDIENCE"),
+		CSRFKey:               os.Getenv("GO_DISCOVERY_CSRF_KEY"),
 	}
+	cfg.AdminTokens, err = parseAdminTokens(os.Getenv("GO_DISCOVERY_ADMIN_TOKENS"))
+	if err != nil {
+		return nil, fmt.Errorf("GO_DISCOVERY_ADMIN_TOKENS: %v", err)
+	}
+	cfg.RequestLogSampleRate, err = strconv.ParseFloat(GetEnv("GO_DISCOVERY_REQUEST_LOG_SAMPLE_RATE", "1"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("GO_DISCOVERY_REQUEST_LOG_SAMPLE_RATE: %v", err)
+	}
+	cfg.SearchQuerySampleRate, err = strconv.ParseFloat(GetEnv("GO_DISCOVERY_SEARCH_QUERY_SAMPLE_RATE", "0.1"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("GO_DISCOVERY_SEARCH_QUERY_SAMPLE_RATE: %v", err)
+	}
+	cfg.BlobDocThresholdKB, err = strconv.Atoi(GetEnv("GO_DISCOVERY_BLOB_DOC_THRESHOLD_KB", "512"))
+	if err != nil {
+		return nil, fmt.Errorf("GO_DISCOVERY_BLOB_DOC_THRESHOLD_KB: %v", err)
+	}
+	switch cfg.QueueBackend {
+	case "", "gcp", "postgres", "redis", "inmemory":
+	default:
+		return nil, fmt.Errorf("GO_DISCOVERY_QUEUE_BACKEND: unknown queue backend %q", cfg.QueueBackend)
+	}
+	// As with the go command, private modules are not verified by default.
+	cfg.NoSumDBPatterns = GetEnv("GO_DISCOVERY_NOSUMDB", cfg.PrivatePatterns)
 	cfg.AppMonitoredResource = &mrpb.MonitoredResource{
 		Type: "gae_app",
 		Labels: map[string]string{
@@ -319,6 +528,13 @@ func processOverrides(cfg *Config, bytes []byte) {
 	overrideInt("Quota.Burst", &cfg.Quota.Burst, ov.Quota.Burst)
 	overrideInt("Quota.MaxEntries", &cfg.Quota.MaxEntries, ov.Quota.MaxEntries)
 	overrideBool("Quota.RecordOnly", &cfg.Quota.RecordOnly, ov.Quota.RecordOnly)
+	overrideInt("Quota.Anonymous.QPS", &cfg.Quota.Anonymous.QPS, ov.Quota.Anonymous.QPS)
+	overrideInt("Quota.Anonymous.Burst", &cfg.Quota.Anonymous.Burst, ov.Quota.Anonymous.Burst)
+	overrideInt("Quota.Keyed.QPS", &cfg.Quota.Keyed.QPS, ov.Quota.Keyed.QPS)
+	overrideInt("Quota.Keyed.Burst", &cfg.Quota.Keyed.Burst, ov.Quota.Keyed.Burst)
+	overrideInt("Quota.Crawler.QPS", &cfg.Quota.Crawler.QPS, ov.Quota.Crawler.QPS)
+	overrideInt("Quota.Crawler.Burst", &cfg.Quota.Crawler.Burst, ov.Quota.Crawler.Burst)
+	overrideInt("Crawl.MaxIndexedPage", &cfg.Crawl.MaxIndexedPage, ov.Crawl.MaxIndexedPage)
 }
 
 func overrideString(name string, field *string, val string) {
@@ -389,6 +605,20 @@ func gceMetadata(ctx context.Context, name string) (_ string, err error) {
 	return string(bytes), nil
 }
 
+// parseAdminTokens parses a comma-separated list of name:token pairs into a
+// map from tokens to names.
+func parseAdminTokens(s string) (map[string]string, error) {
+	tokens := map[string]string{}
+	for _, p := range parseCommaList(s) {
+		i := strings.IndexByte(p, ':')
+		if i <= 0 || i == len(p)-1 {
+			return nil, fmt.Errorf("%q is not of the form name:token", p)
+		}
+		tokens[p[i+1:]] = p[:i]
+	}
+	return tokens, nil
+}
+
 func parseCommaList(s string) []string {
 	var a []string
 	for _, p := range strings.Split(s, ",") {
@@ -58,20 +58,36 @@ func TestProcessOverrides(t *testing.T) {
 	cfg := Config{
 		DBHost: "origHost",
 		DBName: "origName",
-		Quota:  QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 3, RecordOnly: &tr},
+		Quota: QuotaSettings{
+			QPS: 1, Burst: 2, MaxEntries: 3, RecordOnly: &tr,
+			Anonymous: QuotaTier{QPS: 1, Burst: 2},
+			Keyed:     QuotaTier{QPS: 3, Burst: 4},
+		},
 	}
 	ov := `
         DBHost: newHost
         Quota:
            MaxEntries: 17
            RecordOnly: false
+           Keyed:
+              Burst: 40
+           Crawler:
+              QPS: 7
+        Crawl:
+           MaxIndexedPage: 5
     `
 	processOverrides(&cfg, []byte(ov))
 	got := cfg
 	want := Config{
 		DBHost: "newHost",
 		DBName: "origName",
-		Quota:  QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 17, RecordOnly: &f},
+		Quota: QuotaSettings{
+			QPS: 1, Burst: 2, MaxEntries: 17, RecordOnly: &f,
+			Anonymous: QuotaTier{QPS: 1, Burst: 2},
+			Keyed:     QuotaTier{QPS: 3, Burst: 40},
+			Crawler:   QuotaTier{QPS: 7},
+		},
+		Crawl: CrawlSettings{MaxIndexedPage: 5},
 	}
 	if diff := cmp.Diff(want, got); diff != "" {
 		t.Errorf("mismatch (-want, +got):\n%s", diff)
@@ -95,3 +111,27 @@ func TestParseCommaList(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAdminTokens(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"ci:abc", map[string]string{"abc": "ci"}, false},
+		{"ci:abc, ops:d:e", map[string]string{"abc": "ci", "d:e": "ops"}, false},
+		{"abc", nil, true},
+		{":abc", nil, true},
+		{"ci:", nil, true},
+	} {
+		got, err := parseAdminTokens(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: got error %v, want error %t", test.in, err, test.wantErr)
+			continue
+		}
+		if !cmp.Equal(got, test.want) {
+			t.Errorf("%q: got %#v, want %#v", test.in, got, test.want)
+		}
+	}
+}
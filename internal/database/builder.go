@@ -0,0 +1,390 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// A Statement is a SQL statement together with its arguments.
+type Statement interface {
+	// Build returns the text of the statement, with positional parameters
+	// ($1, $2, ...), and the corresponding arguments.
+	Build() (query string, args []interface{}, err error)
+}
+
+// Args holds the values of the named parameters of a statement. In the text
+// of a statement, a named parameter is written as a colon followed by its
+// name, as in "WHERE module_path = :path".
+type Args map[string]interface{}
+
+// Named returns a Statement for query, which may contain named parameters
+// whose values are given by args.
+//
+// A name that occurs more than once in query refers to the same positional
+// parameter. If the value of a parameter is a slice (other than a []byte or a
+// driver.Valuer, such as the result of pq.Array), it is expanded into a
+// comma-separated list with one parameter for each element, for use in an IN
+// list:
+//
+//	SELECT * FROM modules WHERE module_path IN (:paths)
+//
+// An empty slice is expanded to NULL, which matches nothing.
+func Named(query string, args Args) Statement {
+	return &namedStatement{query, args}
+}
+
+type namedStatement struct {
+	query string
+	args  Args
+}
+
+func (s *namedStatement) Build() (string, []interface{}, error) {
+	return bindNamed(s.query, s.args, nil)
+}
+
+// bindNamed replaces the named parameters of query with positional parameters
+// numbered after those in args, and returns the new query and args.
+func bindNamed(query string, named Args, args []interface{}) (_ string, _ []interface{}, err error) {
+	var (
+		b         strings.Builder
+		positions = map[string]string{}
+		used      = map[string]bool{}
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			// Copy string literals and quoted identifiers verbatim.
+			j := strings.IndexByte(query[i+1:], c)
+			if j < 0 {
+				return "", nil, fmt.Errorf("unterminated %c in %q", c, query)
+			}
+			b.WriteString(query[i : i+j+2])
+			i += j + 1
+		case c == ':' && i+1 < len(query) && query[i+1] == ':':
+			// A type cast.
+			b.WriteString("::")
+			i++
+		case c == ':' && i+1 < len(query) && isIdentStart(query[i+1]):
+			j := i + 1
+			for j < len(query) && isIdentChar(query[j]) {
+				j++
+			}
+			name := query[i+1 : j]
+			p, ok := positions[name]
+			if !ok {
+				v, ok := named[name]
+				if !ok {
+					return "", nil, fmt.Errorf("missing value for parameter %q", name)
+				}
+				p, args = addParam(args, v)
+				positions[name] = p
+			}
+			used[name] = true
+			b.WriteString(p)
+			i = j - 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	var unused []string
+	for name := range named {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", nil, fmt.Errorf("unused parameters %v", unused)
+	}
+	return b.String(), args, nil
+}
+
+// addParam appends v to args and returns the positional parameters that refer
+// to it. Slices are expanded as described in Named.
+func addParam(args []interface{}, v interface{}) (string, []interface{}) {
+	rv := reflect.ValueOf(v)
+	if _, ok := v.(driver.Valuer); ok || rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args)), args
+	}
+	if rv.Len() == 0 {
+		return "NULL", args
+	}
+	var ps []string
+	for i := 0; i < rv.Len(); i++ {
+		args = append(args, rv.Index(i).Interface())
+		ps = append(ps, fmt.Sprintf("$%d", len(args)))
+	}
+	return strings.Join(ps, ", "), args
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || '0' <= c && c <= '9'
+}
+
+// InsertStatement is a Statement that inserts rows into a table.
+type InsertStatement struct {
+	table          string
+	columns        []string
+	values         []interface{}
+	conflictAction string
+	returning      []string
+}
+
+// Insert returns a statement that inserts into the given columns of table.
+func Insert(table string, columns ...string) *InsertStatement {
+	return &InsertStatement{table: table, columns: columns}
+}
+
+// Values adds rows to the statement. The values of the rows are interleaved,
+// as in BulkInsert, so len(values) must be a multiple of the number of
+// columns.
+func (s *InsertStatement) Values(values ...interface{}) *InsertStatement {
+	s.values = append(s.values, values...)
+	return s
+}
+
+// OnConflict sets the conflict action of the statement, for example
+// OnConflictDoNothing.
+func (s *InsertStatement) OnConflict(action string) *InsertStatement {
+	s.conflictAction = action
+	return s
+}
+
+// Returning adds a RETURNING clause with the given columns to the statement.
+func (s *InsertStatement) Returning(columns ...string) *InsertStatement {
+	s.returning = columns
+	return s
+}
+
+// Build implements Statement.
+func (s *InsertStatement) Build() (string, []interface{}, error) {
+	if len(s.columns) == 0 {
+		return "", nil, errors.New("no columns to insert")
+	}
+	if len(s.values) == 0 || len(s.values)%len(s.columns) != 0 {
+		return "", nil, fmt.Errorf("got %d values for %d columns", len(s.values), len(s.columns))
+	}
+	return s.sql(len(s.values) / len(s.columns)), s.values, nil
+}
+
+// sql returns the text of the statement with placeholders for nrows rows:
+//
+//	INSERT INTO <table> (<columns>) VALUES (<placeholders>), ... <conflictAction> RETURNING <columns>
+func (s *InsertStatement) sql(nrows int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", s.table, strings.Join(s.columns, ", "))
+	n := 1
+	for r := 0; r < nrows; r++ {
+		if r > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for c := range s.columns {
+			if c > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", n)
+			n++
+		}
+		b.WriteByte(')')
+	}
+	if s.conflictAction != "" {
+		b.WriteString(" " + s.conflictAction)
+	}
+	if len(s.returning) > 0 {
+		fmt.Fprintf(&b, " RETURNING %s", strings.Join(s.returning, ", "))
+	}
+	return b.String()
+}
+
+// conditions holds the WHERE clauses of a statement and their named
+// arguments.
+type conditions struct {
+	where []string
+	args  Args
+	err   error
+}
+
+func (c *conditions) add(cond string, args Args) {
+	c.where = append(c.where, cond)
+	if c.args == nil {
+		c.args = Args{}
+	}
+	for k, v := range args {
+		if _, ok := c.args[k]; ok && c.err == nil {
+			c.err = fmt.Errorf("parameter %q given more than once", k)
+		}
+		c.args[k] = v
+	}
+}
+
+// write writes the WHERE clause, if any, to b.
+func (c *conditions) write(b *strings.Builder) {
+	if len(c.where) == 0 {
+		return
+	}
+	b.WriteString(" WHERE ")
+	for i, w := range c.where {
+		if i > 0 {
+			b.WriteString(" AND ")
+		}
+		fmt.Fprintf(b, "(%s)", w)
+	}
+}
+
+// UpdateStatement is a Statement that updates rows of a table.
+type UpdateStatement struct {
+	table     string
+	columns   []string
+	values    []interface{}
+	conds     conditions
+	returning []string
+}
+
+// Update returns a statement that updates table.
+func Update(table string) *UpdateStatement {
+	return &UpdateStatement{table: table}
+}
+
+// Set sets column to value.
+func (s *UpdateStatement) Set(column string, value interface{}) *UpdateStatement {
+	s.columns = append(s.columns, column)
+	s.values = append(s.values, value)
+	return s
+}
+
+// Where restricts the rows that are updated to those satisfying cond, which
+// may contain named parameters whose values are given by args. Conditions
+// from multiple calls are combined with AND.
+func (s *UpdateStatement) Where(cond string, args Args) *UpdateStatement {
+	s.conds.add(cond, args)
+	return s
+}
+
+// Returning adds a RETURNING clause with the given columns to the statement.
+func (s *UpdateStatement) Returning(columns ...string) *UpdateStatement {
+	s.returning = columns
+	return s
+}
+
+// Build implements Statement.
+func (s *UpdateStatement) Build() (string, []interface{}, error) {
+	if len(s.columns) == 0 {
+		return "", nil, errors.New("no columns to update")
+	}
+	if s.conds.err != nil {
+		return "", nil, s.conds.err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "UPDATE %s SET ", s.table)
+	for i, c := range s.columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s = $%d", c, i+1)
+	}
+	s.conds.write(&b)
+	if len(s.returning) > 0 {
+		fmt.Fprintf(&b, " RETURNING %s", strings.Join(s.returning, ", "))
+	}
+	args := append([]interface{}(nil), s.values...)
+	return bindNamed(b.String(), s.conds.args, args)
+}
+
+// SelectStatement is a Statement that queries a table.
+type SelectStatement struct {
+	columns []string
+	from    string
+	conds   conditions
+	orderBy []string
+	limit   int
+}
+
+// Select returns a statement that selects the given columns.
+func Select(columns ...string) *SelectStatement {
+	return &SelectStatement{columns: columns}
+}
+
+// From sets the FROM clause of the statement, which may be a table or a join.
+func (s *SelectStatement) From(from string) *SelectStatement {
+	s.from = from
+	return s
+}
+
+// Where restricts the rows that are returned to those satisfying cond, which
+// may contain named parameters whose values are given by args. Conditions
+// from multiple calls are combined with AND.
+func (s *SelectStatement) Where(cond string, args Args) *SelectStatement {
+	s.conds.add(cond, args)
+	return s
+}
+
+// OrderBy sets the ORDER BY clause of the statement.
+func (s *SelectStatement) OrderBy(exprs ...string) *SelectStatement {
+	s.orderBy = exprs
+	return s
+}
+
+// Limit sets the maximum number of rows returned. A limit of zero means no
+// limit.
+func (s *SelectStatement) Limit(n int) *SelectStatement {
+	s.limit = n
+	return s
+}
+
+// Build implements Statement.
+func (s *SelectStatement) Build() (string, []interface{}, error) {
+	if len(s.columns) == 0 {
+		return "", nil, errors.New("no columns to select")
+	}
+	if s.conds.err != nil {
+		return "", nil, s.conds.err
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT %s", strings.Join(s.columns, ", "))
+	if s.from != "" {
+		fmt.Fprintf(&b, " FROM %s", s.from)
+	}
+	s.conds.write(&b)
+	if len(s.orderBy) > 0 {
+		fmt.Fprintf(&b, " ORDER BY %s", strings.Join(s.orderBy, ", "))
+	}
+	if s.limit > 0 {
+		fmt.Fprintf(&b, " LIMIT %d", s.limit)
+	}
+	return bindNamed(b.String(), s.conds.args, nil)
+}
+
+// ExecStatement builds and executes s.
+func (db *DB) ExecStatement(ctx context.Context, s Statement) (sql.Result, error) {
+	query, args, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+	return db.Exec(ctx, query, args...)
+}
+
+// RunStatement builds and executes s, then calls f on each row.
+func (db *DB) RunStatement(ctx context.Context, s Statement, f func(*sql.Rows) error) error {
+	query, args, err := s.Build()
+	if err != nil {
+		return err
+	}
+	return db.RunQuery(ctx, query, f, args...)
+}
@@ -0,0 +1,159 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lib/pq"
+)
+
+func TestBuild(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		stmt      Statement
+		wantQuery string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "named",
+			stmt:      Named(`SELECT * FROM t WHERE a = :a AND b = :b OR a > :a`, Args{"a": 1, "b": "x"}),
+			wantQuery: `SELECT * FROM t WHERE a = $1 AND b = $2 OR a > $1`,
+			wantArgs:  []interface{}{1, "x"},
+		},
+		{
+			name:      "literals and casts",
+			stmt:      Named(`SELECT ':no', ":no" FROM t WHERE a = :a::text`, Args{"a": 1}),
+			wantQuery: `SELECT ':no', ":no" FROM t WHERE a = $1::text`,
+			wantArgs:  []interface{}{1},
+		},
+		{
+			name:      "in list",
+			stmt:      Named(`SELECT * FROM t WHERE a IN (:as) AND b = :b`, Args{"as": []string{"x", "y"}, "b": []byte("z")}),
+			wantQuery: `SELECT * FROM t WHERE a IN ($1, $2) AND b = $3`,
+			wantArgs:  []interface{}{"x", "y", []byte("z")},
+		},
+		{
+			name:      "empty in list",
+			stmt:      Named(`SELECT * FROM t WHERE a IN (:as)`, Args{"as": []int{}}),
+			wantQuery: `SELECT * FROM t WHERE a IN (NULL)`,
+		},
+		{
+			name:      "array",
+			stmt:      Named(`SELECT * FROM t WHERE a = ANY(:as)`, Args{"as": pq.Array([]int{1})}),
+			wantQuery: `SELECT * FROM t WHERE a = ANY($1)`,
+			wantArgs:  []interface{}{pq.Array([]int{1})},
+		},
+		{
+			name:      "insert",
+			stmt:      Insert("t", "a", "b").Values(1, 2, 3, 4).OnConflict(OnConflictDoNothing).Returning("id"),
+			wantQuery: `INSERT INTO t (a, b) VALUES ($1, $2), ($3, $4) ON CONFLICT DO NOTHING RETURNING id`,
+			wantArgs:  []interface{}{1, 2, 3, 4},
+		},
+		{
+			name:      "update",
+			stmt:      Update("t").Set("a", 1).Set("b", 2).Where("c = :c", Args{"c": 3}).Where("d IN (:ds)", Args{"ds": []int{4, 5}}).Returning("id"),
+			wantQuery: `UPDATE t SET a = $1, b = $2 WHERE (c = $3) AND (d IN ($4, $5)) RETURNING id`,
+			wantArgs:  []interface{}{1, 2, 3, 4, 5},
+		},
+		{
+			name:      "select",
+			stmt:      Select("a", "b").From("t").Where("c = :c", Args{"c": 1}).OrderBy("a", "b DESC").Limit(10),
+			wantQuery: `SELECT a, b FROM t WHERE (c = $1) ORDER BY a, b DESC LIMIT 10`,
+			wantArgs:  []interface{}{1},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			gotQuery, gotArgs, err := test.stmt.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotQuery != test.wantQuery {
+				t.Errorf("query:\ngot  %s\nwant %s", gotQuery, test.wantQuery)
+			}
+			if diff := cmp.Diff(test.wantArgs, gotArgs); diff != "" {
+				t.Errorf("args mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		stmt Statement
+	}{
+		{"missing", Named(`SELECT :a`, nil)},
+		{"unused", Named(`SELECT :a`, Args{"a": 1, "b": 2})},
+		{"unterminated", Named(`SELECT ':a`, Args{"a": 1})},
+		{"insert no values", Insert("t", "a")},
+		{"insert bad values", Insert("t", "a", "b").Values(1, 2, 3)},
+		{"update no set", Update("t").Where("a = :a", Args{"a": 1})},
+		{"duplicate", Select("a").From("t").Where("a = :a", Args{"a": 1}).Where("b = :a", Args{"a": 2})},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := test.stmt.Build(); err == nil {
+				t.Error("got nil, want error")
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if _, err := testDB.Exec(ctx, `DROP TABLE IF EXISTS test_statements`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.Exec(ctx, `CREATE TABLE test_statements (name TEXT PRIMARY KEY, score INT)`); err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Exec(ctx, `DROP TABLE test_statements`)
+
+	var queries []string
+	db := New(testDB.db, "test")
+	db.AddQueryHook(func(_ context.Context, query string, _ []interface{}, _ time.Duration, err error) {
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+		}
+		queries = append(queries, query)
+	})
+
+	err := db.Transact(ctx, sql.LevelDefault, func(tx *DB) error {
+		if _, err := tx.ExecStatement(ctx, Insert("test_statements", "name", "score").Values("a", 1, "b", 2, "c", 3)); err != nil {
+			return err
+		}
+		_, err := tx.ExecStatement(ctx, Update("test_statements").Set("score", 10).Where("name IN (:names)", Args{"names": []string{"a", "c"}}))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	err = db.RunStatement(ctx, Select("name").From("test_statements").Where("score = :score", Args{"score": 10}).OrderBy("name"),
+		func(rows *sql.Rows) error {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				return err
+			}
+			got = append(got, name)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "c"}; !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(queries) != 3 || !strings.HasPrefix(queries[2], "SELECT name") {
+		t.Errorf("got hook queries %q, want 3 ending with the SELECT", queries)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/dtrace"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// queryComment returns a SQL comment identifying the request, trace span and
+// query name of ctx, so that statements seen in pg_stat_activity or the
+// Postgres logs can be tied back to the request that issued them. It returns
+// the empty string if ctx has none of those.
+//
+// The comment follows the sqlcommenter format: comma-separated key='value'
+// pairs, sorted by key, with URL-encoded values. URL encoding guarantees that
+// a value cannot end the comment.
+func queryComment(ctx context.Context) string {
+	var kvs []string
+	add := func(k, v string) {
+		if v != "" {
+			kvs = append(kvs, fmt.Sprintf("%s='%s'", k, url.QueryEscape(v)))
+		}
+	}
+	if m := tag.FromContext(ctx); m != nil {
+		name, _ := m.Value(keyQueryName)
+		add("query_name", name)
+	}
+	add("request_id", log.TraceIDFromContext(ctx))
+	traceID, spanID := dtrace.IDs(ctx)
+	add("span_id", spanID)
+	add("trace_id", traceID)
+	if len(kvs) == 0 {
+		return ""
+	}
+	return "/*" + strings.Join(kvs, ",") + "*/"
+}
+
+// identifiesRequest reports whether ctx has a request or trace span to
+// record in the comments of its queries.
+func identifiesRequest(ctx context.Context) bool {
+	traceID, _ := dtrace.IDs(ctx)
+	return log.TraceIDFromContext(ctx) != "" || traceID != ""
+}
+
+// annotate returns query prefixed by the comment for ctx, or by the comment of
+// the transaction that db belongs to when ctx has none.
+//
+// The comment goes first because Postgres truncates the query text in
+// pg_stat_activity (to 1024 bytes by default), and the queries we most want to
+// identify, like bulk inserts, are long.
+//
+// Queries whose context identifies a request are not run from the statement
+// cache: the text of a prepared statement is fixed, so it could not hold the
+// comment of each request.
+func (db *DB) annotate(ctx context.Context, query string) string {
+	c := queryComment(ctx)
+	if c == "" {
+		c = db.comment
+	}
+	if c == "" {
+		return query
+	}
+	return c + " " + query
+}
+
+// annotateStatement returns query prefixed by the comment for ctx, which
+// does not identify a request. The result is both the text of the prepared
+// statement and its key in the statement cache, so the cache keeps one
+// statement per query and query name.
+func annotateStatement(ctx context.Context, query string) string {
+	if c := queryComment(ctx); c != "" {
+		return c + " " + query
+	}
+	return query
+}
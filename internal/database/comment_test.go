@@ -0,0 +1,111 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+func TestQueryComment(t *testing.T) {
+	ctx := context.Background()
+	if got := queryComment(ctx); got != "" {
+		t.Errorf("queryComment(empty) = %q, want empty", got)
+	}
+
+	ctx = log.NewContextWithTraceID(ctx, "abc/1;o=1")
+	ctx = WithQueryName(ctx, "insertPackages")
+	if got, want := queryComment(ctx), "/*query_name='insertPackages',request_id='abc%2F1%3Bo%3D1'*/"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(ctx, "test")
+	defer span.End()
+	sc := span.SpanContext()
+	want := fmt.Sprintf("/*query_name='insertPackages',request_id='abc%%2F1%%3Bo%%3D1',span_id='%s',trace_id='%s'*/", sc.SpanID, sc.TraceID)
+	if got := queryComment(ctx); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if !identifiesRequest(ctx) {
+		t.Error("identifiesRequest = false, want true")
+	}
+	if identifiesRequest(WithQueryName(context.Background(), "insertPackages")) {
+		t.Error("identifiesRequest with only a query name = true, want false")
+	}
+
+	// Values cannot end the comment.
+	ctx = log.NewContextWithTraceID(context.Background(), "*/ DROP TABLE x; /*")
+	if got := queryComment(ctx); strings.Count(got, "*/") != 1 {
+		t.Errorf("got %q, want a single comment", got)
+	}
+}
+
+func TestAnnotateTransaction(t *testing.T) {
+	ctx := log.NewContextWithTraceID(context.Background(), "request")
+	currentQuery := func(ctx context.Context, db *DB) string {
+		var q string
+		if err := db.QueryRow(ctx, `SELECT query FROM pg_stat_activity WHERE pid = pg_backend_pid()`).Scan(&q); err != nil {
+			t.Fatal(err)
+		}
+		return q
+	}
+	if err := testDB.Transact(ctx, sql.LevelDefault, func(tx *DB) error {
+		if got := currentQuery(ctx, tx); !strings.HasPrefix(got, "/*request_id='request'*/ ") {
+			t.Errorf("got %q, want it to start with the request comment", got)
+		}
+		// Queries whose context has no comment get the transaction's.
+		if got := currentQuery(context.Background(), tx); !strings.HasPrefix(got, "/*request_id='request'*/ ") {
+			t.Errorf("without context: got %q, want it to start with the request comment", got)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnnotateCachedStatement(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	db := New(testDB.db, "test")
+	db.EnableStatementCache(10)
+	const query = `SELECT query FROM pg_stat_activity WHERE pid = pg_backend_pid() AND $1::int = 1`
+	currentQuery := func(ctx context.Context) string {
+		t.Helper()
+		var got string
+		if err := db.QueryRow(ctx, query, 1).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	// Queries of requests are not cached, so they keep their request IDs.
+	for _, id := range []string{"request1", "request2"} {
+		ctx := WithQueryName(log.NewContextWithTraceID(ctx, id), "currentQuery")
+		if got, want := currentQuery(ctx), fmt.Sprintf("/*query_name='currentQuery',request_id='%s'*/ ", id); !strings.HasPrefix(got, want) {
+			t.Errorf("got %q, want it to start with %q", got, want)
+		}
+	}
+	if got := db.stmts.lru.Len(); got != 0 {
+		t.Errorf("got %d cached statements, want 0", got)
+	}
+
+	// Other queries are cached, with their query name.
+	for i := 0; i < 2; i++ {
+		if got, want := currentQuery(WithQueryName(ctx, "currentQuery")), "/*query_name='currentQuery'*/ "; !strings.HasPrefix(got, want) {
+			t.Errorf("got %q, want it to start with %q", got, want)
+		}
+	}
+	if got := db.stmts.lru.Len(); got != 1 {
+		t.Errorf("got %d cached statements, want 1", got)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressedPrefix begins every value compressed by Compress. It is the magic
+// number of a zstd frame, which no valid UTF-8 text begins with, so
+// compressed values cannot be confused with the text that is stored in the
+// columns Compress is used for.
+const CompressedPrefix = "\x28\xb5\x2f\xfd"
+
+// MinCompressSize is the length in bytes below which Compress leaves text
+// as it is, because compressing it saves too little to be worth the cost.
+const MinCompressSize = 512
+
+var (
+	// The encoder and decoder are safe for concurrent use by EncodeAll and
+	// DecodeAll.
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func init() {
+	var err error
+	zstdEncoder, err = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBetterCompression))
+	if err != nil {
+		panic(err)
+	}
+	zstdDecoder, err = zstd.NewReader(nil)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Compress returns the value to store in a bytea column for s: s compressed
+// with zstd, which begins with CompressedPrefix. Text shorter than
+// MinCompressSize is returned uncompressed, unless it begins with
+// CompressedPrefix itself. Values returned by Compress can be read back with
+// Decompress or Compressed.
+func Compress(s string) []byte {
+	if len(s) < MinCompressSize && !isCompressed([]byte(s)) {
+		return []byte(s)
+	}
+	return zstdEncoder.EncodeAll([]byte(s), nil)
+}
+
+// Decompress returns the text that b holds: b itself, unless it was
+// compressed by Compress.
+func Decompress(b []byte) (string, error) {
+	if !isCompressed(b) {
+		return string(b), nil
+	}
+	data, err := zstdDecoder.DecodeAll(b, nil)
+	if err != nil {
+		return "", fmt.Errorf("decompressing: %v", err)
+	}
+	return string(data), nil
+}
+
+func isCompressed(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(CompressedPrefix))
+}
+
+// Compressed returns a sql.Scanner that decompresses a bytea column that may
+// have been written with Compress into p, which must be a *string or a
+// *[]byte. If the column is NULL, p is set to its zero value.
+func Compressed(p interface{}) sql.Scanner {
+	return compressedScanner{p}
+}
+
+type compressedScanner struct {
+	ptr interface{} // a *string or *[]byte
+}
+
+func (c compressedScanner) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("Compressed: cannot scan %T", value)
+	}
+	s, err := Decompress(b)
+	if err != nil {
+		return err
+	}
+	switch p := c.ptr.(type) {
+	case *string:
+		*p = s
+	case *[]byte:
+		if value != nil {
+			*p = []byte(s)
+		} else {
+			*p = nil
+		}
+	default:
+		return fmt.Errorf("Compressed: cannot scan into %T", c.ptr)
+	}
+	return nil
+}
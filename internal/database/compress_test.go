@@ -0,0 +1,69 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	for _, test := range []struct {
+		name           string
+		in             string
+		wantCompressed bool
+	}{
+		{"empty", "", false},
+		{"short", "<p>hello</p>", false},
+		{"long", strings.Repeat("<p>hello</p>", 100), true},
+		{"short with prefix", CompressedPrefix + "x", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := Compress(test.in)
+			if got := bytes.HasPrefix(c, []byte(CompressedPrefix)); got != test.wantCompressed {
+				t.Errorf("Compress: compressed = %t, want %t", got, test.wantCompressed)
+			}
+			if test.wantCompressed && !strings.HasPrefix(test.in, CompressedPrefix) && len(c) >= len(test.in) {
+				t.Errorf("Compress: got %d bytes, want fewer than %d", len(c), len(test.in))
+			}
+			got, err := Decompress(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.in {
+				t.Errorf("Decompress(Compress(%q)) = %q", test.in, got)
+			}
+		})
+	}
+}
+
+func TestCompressedScanner(t *testing.T) {
+	want := strings.Repeat("<p>hello</p>", 100)
+	c := Compress(want)
+	var s string
+	if err := Compressed(&s).Scan(c); err != nil {
+		t.Fatal(err)
+	}
+	if s != want {
+		t.Errorf("scanning into *string: got %q, want %q", s, want)
+	}
+	var b []byte
+	if err := Compressed(&b).Scan(string(c)); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("scanning into *[]byte: got %q, want %q", b, want)
+	}
+	if err := Compressed(&s).Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if s != "" {
+		t.Errorf("scanning NULL: got %q, want empty string", s)
+	}
+	if err := Compressed(&s).Scan([]byte(CompressedPrefix + "not zstd")); err == nil {
+		t.Error("scanning a corrupt value succeeded, want error")
+	}
+}
@@ -10,8 +10,10 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
 	"sync"
@@ -20,7 +22,12 @@ import (
 	"unicode"
 
 	"github.com/lib/pq"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/semconv"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/log"
 )
 
@@ -34,8 +41,25 @@ type DB struct {
 	db         *sql.DB
 	instanceID string
 	tx         *sql.Tx
+	conn       *sql.Conn
 	mu         sync.Mutex
 	maxRetries int // max times a single transaction was retried
+	hooks      []QueryHook
+	stmts      *stmtCache // nil if statements are not cached
+	comment    string     // the query comment of the transaction, if any
+}
+
+// A QueryHook is called after each query run by a DB, with the text of the
+// query, its arguments, how long it took and the error it returned. The error
+// is always nil for QueryRow, whose errors are reported when the row is
+// scanned.
+type QueryHook func(ctx context.Context, query string, args []interface{}, d time.Duration, err error)
+
+// AddQueryHook arranges for h to be called after every query run by db,
+// including queries run in transactions started from db. It is not safe to
+// call AddQueryHook concurrently with queries.
+func (db *DB) AddQueryHook(h QueryHook) {
+	db.hooks = append(db.hooks, h)
 }
 
 // Open creates a new DB  for the given connection string.
@@ -73,42 +97,118 @@ func (db *DB) Close() error {
 	return db.db.Close()
 }
 
+// Ping verifies that a connection to the database can be made from the pool,
+// or that the connection of the transaction is still alive.
+func (db *DB) Ping(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "database.DB.Ping")
+	if db.tx != nil || db.conn != nil {
+		var one int
+		return db.QueryRow(ctx, "SELECT 1").Scan(&one)
+	}
+	return db.db.PingContext(ctx)
+}
+
 // Exec executes a SQL statement.
 func (db *DB) Exec(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
-	defer logQuery(ctx, query, args, db.instanceID)(&err)
+	ctx, span := startQuerySpan(ctx, query)
+	defer dtrace.End(span, &err)
+	defer db.logQuery(ctx, query, args)(&err)
 
+	if db.useStatementCache(ctx, len(args)) {
+		err = db.stmts.do(ctx, db.db, annotateStatement(ctx, query), func(stmt *sql.Stmt) error {
+			res, err = stmt.ExecContext(ctx, args...)
+			return err
+		})
+		return res, err
+	}
+	query = db.annotate(ctx, query)
 	if db.tx != nil {
 		return db.tx.ExecContext(ctx, query, args...)
 	}
+	if db.conn != nil {
+		return db.conn.ExecContext(ctx, query, args...)
+	}
 	return db.db.ExecContext(ctx, query, args...)
 }
 
 // Query runs the DB query.
-func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (_ *sql.Rows, err error) {
-	defer logQuery(ctx, query, args, db.instanceID)(&err)
+func (db *DB) Query(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer dtrace.End(span, &err)
+	defer db.logQuery(ctx, query, args)(&err)
+	if db.useStatementCache(ctx, len(args)) {
+		err = db.stmts.do(ctx, db.db, annotateStatement(ctx, query), func(stmt *sql.Stmt) error {
+			rows, err = stmt.QueryContext(ctx, args...)
+			return err
+		})
+		return rows, err
+	}
+	query = db.annotate(ctx, query)
 	if db.tx != nil {
 		return db.tx.QueryContext(ctx, query, args...)
 	}
+	if db.conn != nil {
+		return db.conn.QueryContext(ctx, query, args...)
+	}
 	return db.db.QueryContext(ctx, query, args...)
 }
 
 // QueryRow runs the query and returns a single row.
 func (db *DB) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	defer logQuery(ctx, query, args, db.instanceID)(nil)
+	ctx, span := startQuerySpan(ctx, query)
+	defer span.End()
+	defer db.logQuery(ctx, query, args)(nil)
+	if db.useStatementCache(ctx, len(args)) {
+		cs, err := db.stmts.acquire(ctx, db.db, annotateStatement(ctx, query))
+		if err == nil {
+			defer db.stmts.release(cs)
+			return cs.stmt.QueryRowContext(ctx, args...)
+		}
+		// Fall back to an unprepared query, which will report the error
+		// when the row is scanned.
+	}
+	query = db.annotate(ctx, query)
 	if db.tx != nil {
 		return db.tx.QueryRowContext(ctx, query, args...)
 	}
+	if db.conn != nil {
+		return db.conn.QueryRowContext(ctx, query, args...)
+	}
 	return db.db.QueryRowContext(ctx, query, args...)
 }
 
-func (db *DB) Prepare(ctx context.Context, query string) (*sql.Stmt, error) {
-	defer logQuery(ctx, "preparing "+query, nil, db.instanceID)
+func (db *DB) Prepare(ctx context.Context, query string) (_ *sql.Stmt, err error) {
+	defer db.logQuery(ctx, "preparing "+query, nil)(&err)
+	query = db.annotate(ctx, query)
 	if db.tx != nil {
 		return db.tx.PrepareContext(ctx, query)
 	}
+	if db.conn != nil {
+		return db.conn.PrepareContext(ctx, query)
+	}
 	return db.db.PrepareContext(ctx, query)
 }
 
+// WithConn calls f with a DB whose queries all run on a single connection, so
+// that they share session state such as advisory locks and temporary tables.
+// The DB should be used only inside f, and f should not start transactions
+// with Transact.
+func (db *DB) WithConn(ctx context.Context, f func(*DB) error) (err error) {
+	defer derrors.Wrap(&err, "WithConn")
+	if db.InTransaction() {
+		return errors.New("WithConn called on a DB in a transaction")
+	}
+	conn, err := db.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	dbconn := New(db.db, db.instanceID)
+	dbconn.conn = conn
+	dbconn.hooks = db.hooks
+	return f(dbconn)
+}
+
 // RunQuery executes query, then calls f on each row.
 func (db *DB) RunQuery(ctx context.Context, query string, f func(*sql.Rows) error, params ...interface{}) error {
 	rows, err := db.Query(ctx, query, params...)
@@ -140,6 +240,8 @@ func processRows(rows *sql.Rows, f func(*sql.Rows) error) error {
 // serialization failure, so txFunc may be called more than once.
 func (db *DB) Transact(ctx context.Context, iso sql.IsolationLevel, txFunc func(*DB) error) (err error) {
 	defer derrors.Wrap(&err, "Transact(%s)", iso)
+	ctx, span := dtrace.StartSpan(ctx, "db.Transact", label.String("db.isolation_level", iso.String()))
+	defer dtrace.End(span, &err)
 	// For the levels which require retry, see
 	// https://www.postgresql.org/docs/11/transaction-iso.html.
 	opts := &sql.TxOptions{Isolation: iso}
@@ -182,6 +284,9 @@ func (db *DB) transact(ctx context.Context, opts *sql.TxOptions, txFunc func(*DB
 	if db.InTransaction() {
 		return errors.New("a DB Transact function was called on a DB already in a transaction")
 	}
+	if db.conn != nil {
+		return errors.New("a DB Transact function was called on a DB from WithConn")
+	}
 	tx, err := db.db.BeginTx(ctx, opts)
 	if err != nil {
 		return fmt.Errorf("db.BeginTx(): %w", err)
@@ -201,6 +306,8 @@ func (db *DB) transact(ctx context.Context, opts *sql.TxOptions, txFunc func(*DB
 
 	dbtx := New(db.db, db.instanceID)
 	dbtx.tx = tx
+	dbtx.hooks = db.hooks
+	dbtx.comment = queryComment(ctx)
 	defer dbtx.logTransaction(ctx, opts)(&err)
 	if err := txFunc(dbtx); err != nil {
 		return fmt.Errorf("txFunc(tx): %w", err)
@@ -245,17 +352,26 @@ func (db *DB) BulkInsertReturning(ctx context.Context, table string, columns []s
 }
 
 // BulkUpsert is like BulkInsert, but instead of a conflict action, a list of
-// conflicting columns is provided. An "ON CONFLICT (conflict_columns) DO
-// UPDATE" clause is added to the statement, with assignments "c=excluded.c" for
-// every column c.
-func (db *DB) BulkUpsert(ctx context.Context, table string, columns []string, values []interface{}, conflictColumns []string) error {
-	conflictAction := buildUpsertConflictAction(columns, conflictColumns)
+// conflicting columns and a list of columns to update are provided. An "ON
+// CONFLICT (conflict_columns) DO UPDATE" clause is added to the statement, with
+// assignments "c=excluded.c" for every column c in updateColumns. If
+// updateColumns is nil, every column that is not a conflict column is
+// updated. If there are no columns to update, conflicting rows are left
+// unchanged.
+func (db *DB) BulkUpsert(ctx context.Context, table string, columns []string, values []interface{}, conflictColumns, updateColumns []string) error {
+	conflictAction := buildUpsertConflictAction(columns, conflictColumns, updateColumns)
 	return db.BulkInsert(ctx, table, columns, values, conflictAction)
 }
 
 // BulkUpsertReturning is like BulkInsertReturning, but performs an upsert like BulkUpsert.
-func (db *DB) BulkUpsertReturning(ctx context.Context, table string, columns []string, values []interface{}, conflictColumns, returningColumns []string, scanFunc func(*sql.Rows) error) error {
-	conflictAction := buildUpsertConflictAction(columns, conflictColumns)
+// Conflicting rows are always returned, even if there are no columns to update.
+func (db *DB) BulkUpsertReturning(ctx context.Context, table string, columns []string, values []interface{}, conflictColumns, updateColumns, returningColumns []string, scanFunc func(*sql.Rows) error) error {
+	conflictAction := buildUpsertConflictAction(columns, conflictColumns, updateColumns)
+	if conflictAction == OnConflictDoNothing {
+		// DO NOTHING does not return conflicting rows, so perform an update
+		// that leaves them unchanged instead.
+		conflictAction = buildUpsertConflictAction(columns, conflictColumns, conflictColumns)
+	}
 	return db.BulkInsertReturning(ctx, table, columns, values, conflictAction, returningColumns, scanFunc)
 }
 
@@ -274,8 +390,11 @@ func (db *DB) bulkInsert(ctx context.Context, table string, columns, returningCo
 		return fmt.Errorf("too many columns to insert: %d", len(columns))
 	}
 
+	insert := Insert(table, columns...).OnConflict(conflictAction).Returning(returningColumns...)
+	var query string
 	prepare := func(n int) (*sql.Stmt, error) {
-		return db.Prepare(ctx, buildInsertQuery(table, columns, returningColumns, n, conflictAction))
+		query = insert.sql(n / len(columns))
+		return db.Prepare(ctx, query)
 	}
 
 	var stmt *sql.Stmt
@@ -296,17 +415,7 @@ func (db *DB) bulkInsert(ctx context.Context, table string, columns, returningCo
 			defer stmt.Close()
 		}
 		valueSlice := values[leftBound:rightBound]
-		var err error
-		if returningColumns == nil {
-			_, err = stmt.ExecContext(ctx, valueSlice...)
-		} else {
-			var rows *sql.Rows
-			rows, err = stmt.QueryContext(ctx, valueSlice...)
-			if err != nil {
-				return err
-			}
-			err = processRows(rows, scanFunc)
-		}
+		err := db.execPrepared(ctx, stmt, query, valueSlice, scanFunc)
 		if err != nil {
 			return fmt.Errorf("running bulk insert query, values[%d:%d]): %w", leftBound, rightBound, err)
 		}
@@ -314,48 +423,43 @@ func (db *DB) bulkInsert(ctx context.Context, table string, columns, returningCo
 	return nil
 }
 
-// buildInsertQuery builds an multi-value insert query, following the format:
-// INSERT TO <table> (<columns>) VALUES (<placeholders-for-each-item-in-values>) <conflictAction>
-// If returningColumns is not empty, it appends a RETURNING clause to the query.
-//
-// When calling buildInsertQuery, it must be true that nvalues % len(columns) == 0.
-func buildInsertQuery(table string, columns, returningColumns []string, nvalues int, conflictAction string) string {
-	var b strings.Builder
-	fmt.Fprintf(&b, "INSERT INTO %s", table)
-	fmt.Fprintf(&b, "(%s) VALUES", strings.Join(columns, ", "))
-
-	var placeholders []string
-	for i := 1; i <= nvalues; i++ {
-		// Construct the full query by adding placeholders for each
-		// set of values that we want to insert.
-		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
-		if i%len(columns) != 0 {
-			continue
-		}
-
-		// When the end of a set is reached, write it to the query
-		// builder and reset placeholders.
-		fmt.Fprintf(&b, "(%s)", strings.Join(placeholders, ", "))
-		placeholders = nil
-
-		// Do not add a comma delimiter after the last set of values.
-		if i == nvalues {
-			break
-		}
-		b.WriteString(", ")
-	}
-	if conflictAction != "" {
-		b.WriteString(" " + conflictAction)
+// execPrepared executes stmt, which was prepared from query, with args. If
+// scanFunc is non-nil, it is called on each returned row.
+func (db *DB) execPrepared(ctx context.Context, stmt *sql.Stmt, query string, args []interface{}, scanFunc func(*sql.Rows) error) (err error) {
+	ctx, span := startQuerySpan(ctx, query)
+	defer dtrace.End(span, &err)
+	defer db.logQuery(ctx, query, args)(&err)
+	if scanFunc == nil {
+		_, err = stmt.ExecContext(ctx, args...)
+		return err
 	}
-	if len(returningColumns) > 0 {
-		fmt.Fprintf(&b, " RETURNING %s", strings.Join(returningColumns, ", "))
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return err
 	}
-	return b.String()
+	return processRows(rows, scanFunc)
 }
 
-func buildUpsertConflictAction(columns, conflictColumns []string) string {
+// buildUpsertConflictAction returns the conflict action for an upsert of
+// columns that updates updateColumns, or all columns that are not in
+// conflictColumns if updateColumns is nil.
+func buildUpsertConflictAction(columns, conflictColumns, updateColumns []string) string {
+	if updateColumns == nil {
+		isConflict := map[string]bool{}
+		for _, c := range conflictColumns {
+			isConflict[c] = true
+		}
+		for _, c := range columns {
+			if !isConflict[c] {
+				updateColumns = append(updateColumns, c)
+			}
+		}
+	}
+	if len(updateColumns) == 0 {
+		return OnConflictDoNothing
+	}
 	var sets []string
-	for _, c := range columns {
+	for _, c := range updateColumns {
 		sets = append(sets, fmt.Sprintf("%s=excluded.%[1]s", c))
 	}
 	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s",
@@ -436,6 +540,41 @@ func buildBulkUpdateQuery(table string, columns, types []string) string {
 	)
 }
 
+// BulkDelete deletes the rows of table that satisfy the condition where, at
+// most batchSize rows per statement, and returns the number of rows deleted.
+// The condition may refer to args as $1, $2, and so on.
+//
+// Outside of a transaction, each batch is committed separately, which bounds
+// the size of the transactions and how long their locks are held at the cost
+// of atomicity: if BulkDelete fails, some rows may have been deleted.
+func (db *DB) BulkDelete(ctx context.Context, table, where string, batchSize int, args ...interface{}) (n int64, err error) {
+	defer derrors.Wrap(&err, "DB.BulkDelete(ctx, %q, %q, %d)", table, where, batchSize)
+
+	if batchSize <= 0 {
+		return 0, errors.New("batchSize must be positive")
+	}
+	// Postgres does not support DELETE ... LIMIT, so select the physical
+	// locations of a batch of rows and delete those.
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE ctid = ANY(ARRAY(SELECT ctid FROM %[1]s WHERE %[2]s LIMIT %[3]d))`,
+		table, where, batchSize)
+	for {
+		res, err := db.Exec(ctx, query, args...)
+		if err != nil {
+			return n, err
+		}
+		m, err := res.RowsAffected()
+		if err != nil {
+			return n, err
+		}
+		n += m
+		if m < int64(batchSize) {
+			return n, nil
+		}
+	}
+}
+
 // QueryLoggingDisabled stops logging of queries when true.
 // For use in tests only: not concurrency-safe.
 var QueryLoggingDisabled bool
@@ -450,29 +589,13 @@ type queryEndLogEntry struct {
 	Error           string `json:",omitempty"`
 }
 
-func logQuery(ctx context.Context, query string, args []interface{}, instanceID string) func(*error) {
+func (db *DB) logQuery(ctx context.Context, query string, args []interface{}) func(*error) {
 	if QueryLoggingDisabled {
-		return func(*error) {}
-	}
-	const maxlen = 300 // maximum length of displayed query
-
-	// To make the query more compact and readable, replace newlines with spaces
-	// and collapse adjacent whitespace.
-	var r []rune
-	for _, c := range query {
-		if c == '\n' {
-			c = ' '
-		}
-		if len(r) == 0 || !unicode.IsSpace(r[len(r)-1]) || !unicode.IsSpace(c) {
-			r = append(r, c)
-		}
-	}
-	query = string(r)
-	if len(query) > maxlen {
-		query = query[:maxlen] + "..."
+		return db.runHooks(ctx, query, args)
 	}
-
-	uid := generateLoggingID(instanceID)
+	runHooks := db.runHooks(ctx, query, args)
+	query = compactQuery(query)
+	uid := generateLoggingID(db.instanceID)
 
 	// Construct a short string of the args.
 	const (
@@ -496,6 +619,7 @@ func logQuery(ctx context.Context, query string, args []interface{}, instanceID
 	start := time.Now()
 	return func(errp *error) {
 		dur := time.Since(start)
+		runHooks(errp)
 		if errp == nil { // happens with queryRow
 			log.Debugf(ctx, "%s done", uid)
 		} else {
@@ -516,6 +640,58 @@ func logQuery(ctx context.Context, query string, args []interface{}, instanceID
 	}
 }
 
+// compactQuery makes query more compact and readable for logging, by
+// replacing newlines with spaces, collapsing adjacent whitespace and
+// truncating it.
+func compactQuery(query string) string {
+	const maxlen = 300 // maximum length of displayed query
+
+	var r []rune
+	for _, c := range query {
+		if c == '\n' {
+			c = ' '
+		}
+		if len(r) == 0 || !unicode.IsSpace(r[len(r)-1]) || !unicode.IsSpace(c) {
+			r = append(r, c)
+		}
+	}
+	query = string(r)
+	if len(query) > maxlen {
+		query = query[:maxlen] + "..."
+	}
+	return query
+}
+
+// startQuerySpan starts the span for running query. The span is named for the
+// query name of ctx, if it has one.
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := "query"
+	if m := tag.FromContext(ctx); m != nil {
+		if n, ok := m.Value(keyQueryName); ok {
+			name = n
+		}
+	}
+	return dtrace.StartSpanKind(ctx, "db."+name, trace.SpanKindClient,
+		semconv.DBSystemPostgres, semconv.DBStatementKey.String(compactQuery(query)))
+}
+
+// runHooks returns a function that records the latency of query and calls the
+// query hooks of db for it, with the time since runHooks was called.
+func (db *DB) runHooks(ctx context.Context, query string, args []interface{}) func(*error) {
+	start := time.Now()
+	return func(errp *error) {
+		var err error
+		if errp != nil {
+			err = *errp
+		}
+		dur := time.Since(start)
+		recordQueryLatency(ctx, dur, err)
+		for _, h := range db.hooks {
+			h(ctx, query, args, dur, err)
+		}
+	}
+}
+
 func (db *DB) logTransaction(ctx context.Context, opts *sql.TxOptions) func(*error) {
 	if QueryLoggingDisabled {
 		return func(*error) {}
@@ -565,3 +741,59 @@ func (e emptyStringScanner) Scan(value interface{}) error {
 func NullIsEmpty(s *string) sql.Scanner {
 	return emptyStringScanner{s}
 }
+
+// zeroTimeScanner is a sql.Scanner that, if a time is NULL, writes the zero
+// time.
+type zeroTimeScanner struct {
+	ptr *time.Time
+}
+
+func (z zeroTimeScanner) Scan(value interface{}) error {
+	var nt sql.NullTime
+	if err := nt.Scan(value); err != nil {
+		return err
+	}
+	*z.ptr = nt.Time
+	return nil
+}
+
+// NullIsZero returns a sql.Scanner that writes the zero time to t if the
+// sql.Value is NULL.
+func NullIsZero(t *time.Time) sql.Scanner {
+	return zeroTimeScanner{t}
+}
+
+// JSONB returns a sql.Scanner that unmarshals a JSONB (or JSON) column into
+// the value that p points to. If the column is NULL, the value is set to its
+// zero value.
+func JSONB(p interface{}) sql.Scanner {
+	return jsonbScanner{p}
+}
+
+type jsonbScanner struct {
+	ptr interface{} // a pointer to a Go struct or other JSON-serializable value
+}
+
+func (s jsonbScanner) Scan(value interface{}) (err error) {
+	defer derrors.Wrap(&err, "jsonbScanner(%+v)", value)
+
+	vptr := reflect.ValueOf(s.ptr)
+	if value == nil {
+		// *s.ptr = nil
+		vptr.Elem().Set(reflect.Zero(vptr.Elem().Type()))
+		return nil
+	}
+	jsonBytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("not a []byte")
+	}
+	// v := &[type of *s.ptr]
+	v := reflect.New(vptr.Elem().Type())
+	if err := json.Unmarshal(jsonBytes, v.Interface()); err != nil {
+		return err
+	}
+
+	// *s.ptr = *v
+	vptr.Elem().Set(v.Elem())
+	return nil
+}
@@ -7,6 +7,7 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -28,7 +29,9 @@ var testDB *DB
 func TestMain(m *testing.M) {
 	const dbName = "discovery_postgres_test"
 
+	stop := dbtest.StartClusterIfRequested()
 	if err := dbtest.CreateDBIfNotExists(dbName); err != nil {
+		stop()
 		if errors.Is(err, derrors.NotFound) && os.Getenv("GO_DISCOVERY_TESTDB") != "true" {
 			log.Printf("SKIPPING: could not connect to DB (see doc/postgres.md to set up): %v", err)
 			return
@@ -41,12 +44,26 @@ func TestMain(m *testing.M) {
 		log.Fatalf("Open: %v %[1]T", err)
 	}
 	code := m.Run()
-	if err := testDB.Close(); err != nil {
+	err = testDB.Close()
+	stop()
+	if err != nil {
 		log.Fatal(err)
 	}
 	os.Exit(code)
 }
 
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+	if err := testDB.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.Transact(ctx, sql.LevelDefault, func(tx *DB) error {
+		return tx.Ping(ctx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestBulkInsert(t *testing.T) {
 	table := "test_bulk_insert"
 
@@ -236,7 +253,7 @@ func TestBulkUpsert(t *testing.T) {
 		{1, -1, 2, -2, 3, -3, 4, -4}, // Then replace those rows while inserting others.
 	} {
 		err := testDB.Transact(ctx, sql.LevelDefault, func(tx *DB) error {
-			return tx.BulkUpsert(ctx, "test_replace", []string{"C1", "C2"}, values, []string{"C1"})
+			return tx.BulkUpsert(ctx, "test_replace", []string{"C1", "C2"}, values, []string{"C1"}, nil)
 		})
 		if err != nil {
 			t.Fatal(err)
@@ -259,11 +276,65 @@ func TestBulkUpsert(t *testing.T) {
 	}
 }
 
+func TestBulkUpsertUpdateColumns(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if _, err := testDB.Exec(ctx, `CREATE TEMPORARY TABLE test_upsert_cols (C1 int PRIMARY KEY, C2 int, C3 int);`); err != nil {
+		t.Fatal(err)
+	}
+	cols := []string{"C1", "C2", "C3"}
+	if err := testDB.BulkInsert(ctx, "test_upsert_cols", cols, []interface{}{1, 1, 1, 2, 2, 2}, ""); err != nil {
+		t.Fatal(err)
+	}
+	// Only C2 should be updated.
+	if err := testDB.BulkUpsert(ctx, "test_upsert_cols", cols, []interface{}{1, 10, 10, 3, 30, 30}, []string{"C1"}, []string{"C2"}); err != nil {
+		t.Fatal(err)
+	}
+	var got []interface{}
+	err := testDB.RunQuery(ctx, `SELECT C1, C2, C3 FROM test_upsert_cols ORDER BY C1`, func(rows *sql.Rows) error {
+		var a, b, c int
+		if err := rows.Scan(&a, &b, &c); err != nil {
+			return err
+		}
+		got = append(got, a, b, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{1, 10, 1, 2, 2, 2, 3, 30, 30}
+	if !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 func TestBuildUpsertConflictAction(t *testing.T) {
-	got := buildUpsertConflictAction([]string{"a", "b"}, []string{"c", "d"})
-	want := "ON CONFLICT (c, d) DO UPDATE SET a=excluded.a, b=excluded.b"
-	if got != want {
-		t.Errorf("got %q, want %q", got, want)
+	for _, test := range []struct {
+		columns, conflictColumns, updateColumns []string
+		want                                    string
+	}{
+		{
+			[]string{"a", "b"}, []string{"c", "d"}, nil,
+			"ON CONFLICT (c, d) DO UPDATE SET a=excluded.a, b=excluded.b",
+		},
+		{
+			[]string{"a", "b", "c"}, []string{"a"}, nil,
+			"ON CONFLICT (a) DO UPDATE SET b=excluded.b, c=excluded.c",
+		},
+		{
+			[]string{"a", "b", "c"}, []string{"a"}, []string{"c"},
+			"ON CONFLICT (a) DO UPDATE SET c=excluded.c",
+		},
+		{
+			[]string{"a", "b"}, []string{"a", "b"}, nil,
+			OnConflictDoNothing,
+		},
+	} {
+		got := buildUpsertConflictAction(test.columns, test.conflictColumns, test.updateColumns)
+		if got != test.want {
+			t.Errorf("buildUpsertConflictAction(%v, %v, %v) = %q, want %q",
+				test.columns, test.conflictColumns, test.updateColumns, got, test.want)
+		}
 	}
 }
 
@@ -359,6 +430,39 @@ func TestBulkUpdate(t *testing.T) {
 	}
 }
 
+func TestBulkDelete(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if _, err := testDB.Exec(ctx, `CREATE TABLE bulk_delete (a INT)`); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if _, err := testDB.Exec(ctx, `DROP TABLE bulk_delete`); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if _, err := testDB.Exec(ctx, `INSERT INTO bulk_delete SELECT generate_series(1, 50)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete the values greater than 8, 3 at a time.
+	n, err := testDB.BulkDelete(ctx, "bulk_delete", "a > $1", 3, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Errorf("got %d rows deleted, want 42", n)
+	}
+	var count, max int
+	if err := testDB.QueryRow(ctx, `SELECT COUNT(*), MAX(a) FROM bulk_delete`).Scan(&count, &max); err != nil {
+		t.Fatal(err)
+	}
+	if count != 8 || max != 8 {
+		t.Errorf("got %d rows with max %d, want 8 rows with max 8", count, max)
+	}
+}
+
 func TestTransactSerializable(t *testing.T) {
 	// Test that serializable transactions retry until success.
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
@@ -439,3 +543,31 @@ func TestTransactSerializable(t *testing.T) {
 	}
 
 }
+
+func TestJSONB(t *testing.T) {
+	type S struct{ A int }
+
+	want := &S{1}
+	val, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got *S
+	js := JSONB(&got)
+	if err := js.Scan(val); err != nil {
+		t.Fatal(err)
+	}
+	if *got != *want {
+		t.Errorf("got %+v, want %+v", *got, *want)
+	}
+
+	var got2 *S
+	js = JSONB(&got2)
+	if err := js.Scan(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got2 != nil {
+		t.Errorf("got %#v, want nil", got2)
+	}
+}
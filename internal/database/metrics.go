@@ -0,0 +1,148 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+var (
+	keyQueryName   = tag.MustNewKey("db.query_name")
+	keyQueryStatus = tag.MustNewKey("db.query_status")
+	keyPoolState   = tag.MustNewKey("db.pool_state")
+
+	queryLatency = stats.Float64(
+		"go-discovery/database/query_latency",
+		"Latency of a database query.",
+		stats.UnitMilliseconds,
+	)
+	poolConnections = stats.Int64(
+		"go-discovery/database/pool_connections",
+		"The number of connections in the pool.",
+		stats.UnitDimensionless,
+	)
+	poolWaits = stats.Int64(
+		"go-discovery/database/pool_wait_count",
+		"The number of times a query waited for a connection.",
+		stats.UnitDimensionless,
+	)
+	poolWaitTime = stats.Float64(
+		"go-discovery/database/pool_wait_time",
+		"The time spent waiting for a connection.",
+		stats.UnitMilliseconds,
+	)
+
+	// QueryLatencyDistribution aggregates the latency of queries by query
+	// name and status. Callers name their queries with WithQueryName.
+	QueryLatencyDistribution = &view.View{
+		Name:        "go-discovery/database/query_latency",
+		Measure:     queryLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "database query latency, by query name and status",
+		TagKeys:     []tag.Key{keyQueryName, keyQueryStatus},
+	}
+	// QueryCount counts queries by query name and status.
+	QueryCount = &view.View{
+		Name:        "go-discovery/database/query_count",
+		Measure:     queryLatency,
+		Aggregation: view.Count(),
+		Description: "database query count, by query name and status",
+		TagKeys:     []tag.Key{keyQueryName, keyQueryStatus},
+	}
+	// PoolConnections is the number of connections in the pool that are in
+	// use and idle.
+	PoolConnections = &view.View{
+		Name:        "go-discovery/database/pool_connections",
+		Measure:     poolConnections,
+		Aggregation: view.LastValue(),
+		Description: "database connections, by state",
+		TagKeys:     []tag.Key{keyPoolState},
+	}
+	// PoolWaitCount is the number of times a query waited for a connection.
+	PoolWaitCount = &view.View{
+		Name:        "go-discovery/database/pool_wait_count",
+		Measure:     poolWaits,
+		Aggregation: view.Sum(),
+		Description: "waits for a database connection",
+	}
+	// PoolWaitTime is the total time spent waiting for a connection.
+	PoolWaitTime = &view.View{
+		Name:        "go-discovery/database/pool_wait_time",
+		Measure:     poolWaitTime,
+		Aggregation: view.Sum(),
+		Description: "time spent waiting for a database connection",
+	}
+
+	// Views are the views for the database package.
+	Views = []*view.View{
+		QueryLatencyDistribution,
+		QueryCount,
+		PoolConnections,
+		PoolWaitCount,
+		PoolWaitTime,
+		StatementCacheResultCount,
+	}
+)
+
+// WithQueryName returns a context that labels the metrics of the queries run
+// with it by name, such as "insertPackages". A name applies until it is
+// replaced by another.
+func WithQueryName(ctx context.Context, name string) context.Context {
+	ctx, err := tag.New(ctx, tag.Upsert(keyQueryName, name))
+	if err != nil {
+		// Only invalid names cause errors; don't fail the query for them.
+		log.Errorf(ctx, "database.WithQueryName(%q): %v", name, err)
+	}
+	return ctx
+}
+
+func recordQueryLatency(ctx context.Context, d time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(keyQueryStatus, status)},
+		queryLatency.M(float64(d)/float64(time.Millisecond)))
+}
+
+// PoolStats returns the statistics of db's connection pool.
+func (db *DB) PoolStats() sql.DBStats {
+	return db.db.Stats()
+}
+
+// RecordPoolStats records the statistics of db's connection pool every
+// interval, until ctx is done.
+func (db *DB) RecordPoolStats(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var prevWaits int64
+		var prevWaitTime time.Duration
+		for {
+			s := db.PoolStats()
+			stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(keyPoolState, "in_use")}, poolConnections.M(int64(s.InUse)))
+			stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(keyPoolState, "idle")}, poolConnections.M(int64(s.Idle)))
+			// The waits are cumulative; record the change since the last
+			// interval so that the views can sum them.
+			stats.Record(ctx,
+				poolWaits.M(s.WaitCount-prevWaits),
+				poolWaitTime.M(float64(s.WaitDuration-prevWaitTime)/float64(time.Millisecond)))
+			prevWaits, prevWaitTime = s.WaitCount, s.WaitDuration
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
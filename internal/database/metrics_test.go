@@ -0,0 +1,55 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+)
+
+func TestRecordQueryLatency(t *testing.T) {
+	if err := view.Register(QueryCount); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(QueryCount)
+
+	ctx := context.Background()
+	recordQueryLatency(WithQueryName(ctx, "a"), time.Millisecond, nil)
+	recordQueryLatency(WithQueryName(ctx, "a"), time.Millisecond, errors.New("bad"))
+	recordQueryLatency(WithQueryName(WithQueryName(ctx, "a"), "b"), time.Millisecond, nil)
+	recordQueryLatency(ctx, time.Millisecond, nil)
+
+	rows, err := view.RetrieveData(QueryCount.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, r := range rows {
+		var name, status string
+		for _, tg := range r.Tags {
+			switch tg.Key {
+			case keyQueryName:
+				name = tg.Value
+			case keyQueryStatus:
+				status = tg.Value
+			}
+		}
+		got[name+"/"+status] = r.Data.(*view.CountData).Value
+	}
+	want := map[string]int64{
+		"a/ok":    1,
+		"a/error": 1,
+		"b/ok":    1,
+		"/ok":     1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 
@@ -105,14 +107,42 @@ func (n nullPtr) Scan(value interface{}) error {
 	ntype := n.ptr.Elem().Type() // T
 	if value == nil {
 		n.ptr.Elem().Set(reflect.Zero(ntype)) // *v = nil
-	} else {
-		p := reflect.New(ntype.Elem())       // p := new(T)
-		p.Elem().Set(reflect.ValueOf(value)) // *p = value
-		n.ptr.Elem().Set(p)                  // *v = p
+		return nil
 	}
+	val, err := convertValue(value, ntype.Elem())
+	if err != nil {
+		return err
+	}
+	p := reflect.New(ntype.Elem()) // p := new(T)
+	p.Elem().Set(val)              // *p = value
+	n.ptr.Elem().Set(p)            // *v = p
 	return nil
 }
 
+// convertValue converts value, which was returned by the driver, to type t.
+// It supports the conversions between types of the same kind that we need for
+// pointer fields, such as int64 to int and []byte to string.
+func convertValue(value interface{}, t reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(value)
+	if b, ok := value.([]byte); ok {
+		// The driver may reuse b.
+		v = reflect.ValueOf(append([]byte(nil), b...))
+	}
+	switch {
+	case v.Type() == t:
+		return v, nil
+	case isNumber(v.Kind()) && isNumber(t.Kind()),
+		v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.String:
+		return v.Convert(t), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", value, t)
+	}
+}
+
+func isNumber(k reflect.Kind) bool {
+	return reflect.Int <= k && k <= reflect.Float64
+}
+
 func (n nullPtr) Value() (driver.Value, error) {
 	if n.ptr.Elem().IsNil() {
 		return nil, nil
@@ -121,7 +151,9 @@ func (n nullPtr) Value() (driver.Value, error) {
 }
 
 // CollectStructs scans the the rows from the query into structs and appends
-// them to pslice, which must be a pointer to a slice of structs.
+// them to pslice, which must be a pointer to a slice of structs or struct
+// pointers. Each column is scanned into the field of the same name, as
+// described at ScanStruct.
 // Example:
 //   type Player struct { Name string; Score int }
 //   var players []Player
@@ -145,10 +177,20 @@ func (db *DB) CollectStructs(ctx context.Context, pslice interface{}, query stri
 		return fmt.Errorf("slice element type is neither struct nor struct pointer: %s", ve.Type().Elem())
 	}
 
-	scanner := structScannerForType(et)
+	var scanner func(reflect.Value) []interface{}
 	err := db.RunQuery(ctx, query, func(rows *sql.Rows) error {
+		if scanner == nil {
+			cols, err := rows.Columns()
+			if err != nil {
+				return err
+			}
+			scanner, err = columnScanner(et, cols)
+			if err != nil {
+				return err
+			}
+		}
 		e := reflect.New(et)
-		if err := rows.Scan(scanner(e.Interface())...); err != nil {
+		if err := rows.Scan(scanner(e.Elem())...); err != nil {
 			return err
 		}
 		if !isPointer {
@@ -163,3 +205,189 @@ func (db *DB) CollectStructs(ctx context.Context, pslice interface{}, query stri
 	v.Elem().Set(ve)
 	return nil
 }
+
+// ScanStruct scans the current row of rows into the struct that p points to.
+//
+// Each column is scanned into the exported field named by its db tag or, if
+// it has none, whose name in snake case is the column name: the column for a
+// field ModulePath is module_path, and for ModuleID is module_id. A tag of
+// "-" excludes the field. It is an error for a column to have no field;
+// fields with no column are left alone.
+//
+// As with StructScanner, slice fields are scanned as Postgres arrays and
+// pointer fields as nullable columns. A tag may also have these options after
+// the column name, which may be empty:
+//   nullempty  the field is a string, and NULL is scanned as the empty string
+//   json       the column is JSON or JSONB, and is unmarshaled into the field
+// For example,
+//   type Unit struct {
+//       Path       string
+//       Synopsis   string         `db:",nullempty"`
+//       SourceInfo *source.Info   `db:"source_info,json"`
+//       Cache      map[string]int `db:"-"`
+//   }
+func ScanStruct(rows *sql.Rows, p interface{}) error {
+	v := reflect.ValueOf(p)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ScanStruct: %T is not a pointer to a struct", p)
+	}
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	scanner, err := columnScanner(v.Elem().Type(), cols)
+	if err != nil {
+		return err
+	}
+	return rows.Scan(scanner(v.Elem())...)
+}
+
+// QueryStruct runs the query and scans its first row into the struct that p
+// points to, as described at ScanStruct. It returns sql.ErrNoRows if the query
+// returns no rows.
+func (db *DB) QueryStruct(ctx context.Context, p interface{}, query string, args ...interface{}) error {
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := ScanStruct(rows, p); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// A columnField describes the struct field that a column is scanned into.
+type columnField struct {
+	num       int // to pass to v.Field
+	kind      reflect.Kind
+	nullEmpty bool
+	json      bool
+}
+
+// dest returns the argument to Scan for the field of v described by f.
+func (f columnField) dest(v reflect.Value) interface{} {
+	fv := v.Field(f.num)
+	p := fv.Addr().Interface()
+	switch {
+	case f.json:
+		return JSONB(p)
+	case f.nullEmpty:
+		return NullIsEmpty(p.(*string))
+	case f.kind == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8:
+		return pq.Array(p)
+	case f.kind == reflect.Ptr:
+		return NullPtr(p)
+	default:
+		return p
+	}
+}
+
+// columnFieldsCache maps a struct type to its column fields, or the error
+// that occurred computing them.
+var columnFieldsCache sync.Map
+
+// columnFields returns the fields of the struct type t, keyed by column name.
+func columnFields(t reflect.Type) (map[string]columnField, error) {
+	if c, ok := columnFieldsCache.Load(t); ok {
+		if err, ok := c.(error); ok {
+			return nil, err
+		}
+		return c.(map[string]columnField), nil
+	}
+	fields, err := computeColumnFields(t)
+	if err != nil {
+		columnFieldsCache.Store(t, err)
+		return nil, err
+	}
+	columnFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+func computeColumnFields(t reflect.Type) (map[string]columnField, error) {
+	fields := map[string]columnField{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		r, _ := utf8.DecodeRuneInString(sf.Name)
+		if !unicode.IsUpper(r) {
+			continue
+		}
+		tag := sf.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		if name == "" {
+			name = snakeCase(sf.Name)
+		}
+		f := columnField{num: i, kind: sf.Type.Kind()}
+		for _, o := range opts[1:] {
+			switch o {
+			case "nullempty":
+				if f.kind != reflect.String {
+					return nil, fmt.Errorf("%s.%s: nullempty field is not a string", t, sf.Name)
+				}
+				f.nullEmpty = true
+			case "json":
+				f.json = true
+			default:
+				return nil, fmt.Errorf("%s.%s: unknown db tag option %q", t, sf.Name, o)
+			}
+		}
+		if _, ok := fields[name]; ok {
+			return nil, fmt.Errorf("%s: more than one field for column %q", t, name)
+		}
+		fields[name] = f
+	}
+	return fields, nil
+}
+
+// columnScanner returns a function that, when called on a struct value of
+// type t, returns the arguments to pass to Scan for the given columns.
+func columnScanner(t reflect.Type, columns []string) (func(v reflect.Value) []interface{}, error) {
+	fields, err := columnFields(t)
+	if err != nil {
+		return nil, err
+	}
+	var fs []columnField
+	for _, c := range columns {
+		f, ok := fields[c]
+		if !ok {
+			return nil, fmt.Errorf("%s has no field for column %q", t, c)
+		}
+		fs = append(fs, f)
+	}
+	return func(v reflect.Value) []interface{} {
+		ps := make([]interface{}, len(fs))
+		for i, f := range fs {
+			ps[i] = f.dest(v)
+		}
+		return ps
+	}, nil
+}
+
+// snakeCase converts a Go identifier to the corresponding column name, like
+// ModulePath to module_path. A run of capitals is treated as one word, so
+// HTMLURL becomes htmlurl and ModuleID becomes module_id.
+func snakeCase(s string) string {
+	rs := []rune(s)
+	var b strings.Builder
+	for i, r := range rs {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
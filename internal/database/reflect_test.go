@@ -6,7 +6,9 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"database/sql/driver"
+	"reflect"
 	"sort"
 	"testing"
 
@@ -18,7 +20,7 @@ type testStruct struct {
 	Name  string
 	Score int
 	Slice []int64
-	Ptr   *int64
+	Ptr   *int64 `db:"nullable"`
 }
 
 func TestNullPtr(t *testing.T) {
@@ -118,6 +120,74 @@ func TestCollectStructs(t *testing.T) {
 		t.Errorf("got %+v, want %+v", gotp, wantp)
 	}
 
+	// A column with no field is an error.
+	if err := testDB.CollectStructs(ctx, &got, `SELECT name, score AS points FROM structs`); err == nil {
+		t.Error("got nil, want error for column with no field")
+	}
+}
+
+func TestQueryStruct(t *testing.T) {
+	ctx := context.Background()
+	type info struct{ A int }
+	type row struct {
+		ModuleID  int
+		Synopsis  string `db:",nullempty"`
+		Info      *info  `db:"info_json,json"`
+		Count     *int
+		Unscanned string `db:"-"`
+	}
+
+	var got row
+	err := testDB.QueryStruct(ctx, &got, `
+		SELECT 1 AS module_id, NULL AS synopsis, '{"A": 2}'::jsonb AS info_json, 3 AS count`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := row{ModuleID: 1, Info: &info{2}, Count: func() *int { n := 3; return &n }()}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	err = testDB.QueryStruct(ctx, &got, `SELECT 1 AS module_id WHERE false`)
+	if err != sql.ErrNoRows {
+		t.Errorf("got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"Name", "name"},
+		{"ModulePath", "module_path"},
+		{"ModuleID", "module_id"},
+		{"HTMLDoc", "html_doc"},
+		{"V1Path", "v1_path"},
+		{"ID", "id"},
+	} {
+		if got := snakeCase(test.in); got != test.want {
+			t.Errorf("snakeCase(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestColumnFieldsErrors(t *testing.T) {
+	for _, s := range []interface{}{
+		struct {
+			N int `db:",nullempty"`
+		}{},
+		struct {
+			S string `db:",bogus"`
+		}{},
+		struct {
+			A string
+			B string `db:"a"`
+		}{},
+	} {
+		if _, err := columnFields(reflect.TypeOf(s)); err == nil {
+			t.Errorf("%T: got nil, want error", s)
+		}
+	}
 }
 
 func intptr(i int64) *int64 {
@@ -0,0 +1,156 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A SlowQuery describes a query that took longer than the slow query
+// threshold.
+type SlowQuery struct {
+	Query    string
+	Args     string // redacted; see redactArgs
+	Duration time.Duration
+	Error    string
+	// Plan is the output of EXPLAIN for the query, if it was captured. It is
+	// the estimated plan: the query is not run again.
+	Plan       string
+	RecordedAt time.Time
+}
+
+// SlowQueryOptions configures the handling of slow queries.
+type SlowQueryOptions struct {
+	// Threshold is the duration above which a query is considered slow.
+	Threshold time.Duration
+	// ExplainFraction is the fraction of slow SELECT queries for which a
+	// plan is captured.
+	ExplainFraction float64
+	// Record, if non-nil, is called with each slow query after it is
+	// logged. Queries run by Record are not themselves checked.
+	Record func(context.Context, *SlowQuery)
+}
+
+// explainTimeout bounds the time spent capturing the plan of a query.
+const explainTimeout = time.Minute
+
+type slowQueryKey struct{}
+
+// LogSlowQueries arranges for db to log queries that take longer than
+// opts.Threshold, and to pass them to opts.Record. Plans are captured with
+// plain EXPLAIN, which plans the query without running it, so that queries
+// with side effects, including SELECTs that call functions that write, are
+// not run twice. It is done only for SELECT statements, and in the
+// background; at most one plan is captured at a time, and slow queries that
+// arrive meanwhile are not explained.
+//
+// Like AddQueryHook, it should be called before db is used concurrently.
+func (db *DB) LogSlowQueries(opts SlowQueryOptions) {
+	explaining := make(chan struct{}, 1)
+	db.AddQueryHook(func(ctx context.Context, query string, args []interface{}, d time.Duration, err error) {
+		if d < opts.Threshold || ctx.Value(slowQueryKey{}) != nil {
+			return
+		}
+		sq := &SlowQuery{
+			Query:      query,
+			Args:       redactArgs(args),
+			Duration:   d,
+			RecordedAt: time.Now(),
+		}
+		if err != nil {
+			sq.Error = err.Error()
+		}
+		log.Infof(ctx, "slow query (%s): %s args=%s", d, compactQuery(query), sq.Args)
+
+		explain := isSelect(query) && rand.Float64() < opts.ExplainFraction
+		if explain {
+			select {
+			case explaining <- struct{}{}:
+			default:
+				explain = false
+			}
+		}
+		if !explain && opts.Record == nil {
+			return
+		}
+		// Don't delay the caller, and don't depend on its context, which
+		// may be done when the query returns.
+		go func() {
+			ctx := context.WithValue(context.Background(), slowQueryKey{}, true)
+			if explain {
+				defer func() { <-explaining }()
+				plan, err := db.explain(ctx, query, args)
+				if err != nil {
+					log.Errorf(ctx, "explaining slow query: %v", err)
+				}
+				sq.Plan = plan
+			}
+			if opts.Record != nil {
+				opts.Record(ctx, sq)
+			}
+		}()
+	})
+}
+
+// explain returns the output of EXPLAIN for query. It does not run the query.
+func (db *DB) explain(ctx context.Context, query string, args []interface{}) (_ string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, explainTimeout)
+	defer cancel()
+	rows, err := db.db.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// isSelect reports whether query is a SELECT statement. Those are the slow
+// queries whose plans are worth capturing; writes are usually slow because of
+// their size or locks rather than their plan.
+func isSelect(query string) bool {
+	fields := strings.Fields(query)
+	return len(fields) > 0 && strings.EqualFold(fields[0], "SELECT")
+}
+
+// redactArgs returns a description of args that omits the contents of
+// strings and byte slices, which may contain user data. Numbers, booleans and
+// times are shown.
+func redactArgs(args []interface{}) string {
+	var s []string
+	for _, a := range args {
+		switch a := a.(type) {
+		case nil:
+			s = append(s, "NULL")
+		case bool, int, int32, int64, uint, uint32, uint64, float32, float64:
+			s = append(s, fmt.Sprint(a))
+		case time.Time:
+			s = append(s, a.Format(time.RFC3339))
+		case string:
+			s = append(s, fmt.Sprintf("<string len=%d>", len(a)))
+		case []byte:
+			s = append(s, fmt.Sprintf("<bytes len=%d>", len(a)))
+		default:
+			s = append(s, fmt.Sprintf("<%T>", a))
+		}
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedactArgs(t *testing.T) {
+	ts := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	got := redactArgs([]interface{}{nil, 3, true, "secret", []byte("xy"), ts, struct{}{}})
+	want := "NULL, 3, true, <string len=6>, <bytes len=2>, 2020-06-01T00:00:00Z, <struct {}>"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestIsSelect(t *testing.T) {
+	for _, test := range []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT 1", true},
+		{"\n\t\tselect * FROM t", true},
+		{"INSERT INTO t VALUES (1)", false},
+		{"WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", false},
+		{"", false},
+	} {
+		if got := isSelect(test.query); got != test.want {
+			t.Errorf("isSelect(%q) = %t, want %t", test.query, got, test.want)
+		}
+	}
+}
+
+func TestLogSlowQueries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	recorded := make(chan *SlowQuery, 10)
+	db := New(testDB.db, "test")
+	db.LogSlowQueries(SlowQueryOptions{
+		Threshold:       0, // every query is slow
+		ExplainFraction: 1,
+		Record:          func(_ context.Context, sq *SlowQuery) { recorded <- sq },
+	})
+	var n int
+	if err := db.QueryRow(ctx, `SELECT $1::int`, 7).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sq := <-recorded:
+		if sq.Query != `SELECT $1::int` || sq.Args != "7" {
+			t.Errorf("got query %q with args %q", sq.Query, sq.Args)
+		}
+		if !strings.Contains(sq.Plan, "Result") {
+			t.Errorf("got plan %q, want one containing %q", sq.Plan, "Result")
+		}
+	case <-ctx.Done():
+		t.Fatal("slow query was not recorded")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"github.com/lib/pq"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	keyStatementCacheResult = tag.MustNewKey("statement_cache.result")
+	statementCacheResults   = stats.Int64(
+		"go-discovery/database/statement_cache_count",
+		"The result of looking up a prepared statement in the cache.",
+		stats.UnitDimensionless,
+	)
+
+	// StatementCacheResultCount is a counter of prepared statement cache
+	// lookups, by whether they hit, missed, or were invalidated by a schema
+	// change.
+	StatementCacheResultCount = &view.View{
+		Name:        "go-discovery/database/statement_cache_count",
+		Measure:     statementCacheResults,
+		Aggregation: view.Count(),
+		Description: "prepared statement cache lookups, by result",
+		TagKeys:     []tag.Key{keyStatementCacheResult},
+	}
+)
+
+func recordStatementCache(ctx context.Context, result string) {
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(keyStatementCacheResult, result)}, statementCacheResults.M(1))
+}
+
+// EnableStatementCache arranges for db to prepare the queries it runs and to
+// keep up to size of the prepared statements, evicting the least recently
+// used. Only queries that have arguments, run outside of a transaction and
+// whose context does not identify a request are cached, so that the queries
+// of requests keep their request and trace IDs in pg_stat_activity. database/sql prepares a cached statement on each connection the
+// first time it is used there.
+//
+// A statement is evicted, and the query retried, when Postgres reports that
+// it was invalidated by a schema change. QueryRow cannot observe such errors,
+// so callers that change the schema should call InvalidateStatementCache.
+//
+// EnableStatementCache should be called before db is used concurrently.
+func (db *DB) EnableStatementCache(size int) {
+	if size <= 0 {
+		db.stmts = nil
+		return
+	}
+	db.stmts = newStmtCache(size)
+}
+
+// InvalidateStatementCache closes all the statements prepared by the
+// statement cache, if any.
+func (db *DB) InvalidateStatementCache() {
+	if db.stmts != nil {
+		db.stmts.clear()
+	}
+}
+
+// useStatementCache reports whether a query with nargs arguments, run with
+// ctx, should use the statement cache.
+func (db *DB) useStatementCache(ctx context.Context, nargs int) bool {
+	return db.stmts != nil && db.tx == nil && db.conn == nil && nargs > 0 && !identifiesRequest(ctx)
+}
+
+// stmtCache is an LRU cache of prepared statements, keyed by query.
+type stmtCache struct {
+	max int
+
+	mu      sync.Mutex
+	lru     *list.List // of *cachedStmt, most recently used first
+	byQuery map[string]*list.Element
+}
+
+// A cachedStmt is a prepared statement that is closed when it has been
+// evicted and is no longer in use.
+type cachedStmt struct {
+	query   string
+	stmt    *sql.Stmt
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(max int) *stmtCache {
+	return &stmtCache{
+		max:     max,
+		lru:     list.New(),
+		byQuery: map[string]*list.Element{},
+	}
+}
+
+// acquire returns a prepared statement for query, preparing it if necessary.
+// The caller must call release when it is done with the statement.
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) (*cachedStmt, error) {
+	c.mu.Lock()
+	if e, ok := c.byQuery[query]; ok {
+		c.lru.MoveToFront(e)
+		cs := e.Value.(*cachedStmt)
+		cs.refs++
+		c.mu.Unlock()
+		recordStatementCache(ctx, "hit")
+		return cs, nil
+	}
+	c.mu.Unlock()
+	recordStatementCache(ctx, "miss")
+
+	// Prepare without holding the lock. If another goroutine prepares the
+	// same query concurrently, keep the first statement.
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.byQuery[query]; ok {
+		stmt.Close()
+		cs := e.Value.(*cachedStmt)
+		cs.refs++
+		return cs, nil
+	}
+	cs := &cachedStmt{query: query, stmt: stmt, refs: 1}
+	c.byQuery[query] = c.lru.PushFront(cs)
+	for c.lru.Len() > c.max {
+		c.evict(c.lru.Back())
+	}
+	return cs, nil
+}
+
+// release releases a statement returned by acquire.
+func (c *stmtCache) release(cs *cachedStmt) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cs.refs--
+	if cs.evicted && cs.refs == 0 {
+		cs.stmt.Close()
+	}
+}
+
+// remove evicts the statement for query, if it is cached.
+func (c *stmtCache) remove(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.byQuery[query]; ok {
+		c.evict(e)
+	}
+}
+
+// clear evicts all statements.
+func (c *stmtCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.evict(c.lru.Back())
+	}
+}
+
+// evict removes e from the cache. c.mu must be held.
+func (c *stmtCache) evict(e *list.Element) {
+	cs := c.lru.Remove(e).(*cachedStmt)
+	delete(c.byQuery, cs.query)
+	cs.evicted = true
+	if cs.refs == 0 {
+		cs.stmt.Close()
+	}
+}
+
+// do calls f with a prepared statement for query. If f fails because the
+// statement was invalidated by a schema change, the statement is evicted and
+// f is called once more with a new one.
+func (c *stmtCache) do(ctx context.Context, db *sql.DB, query string, f func(*sql.Stmt) error) error {
+	for i := 0; ; i++ {
+		cs, err := c.acquire(ctx, db, query)
+		if err != nil {
+			return err
+		}
+		err = f(cs.stmt)
+		c.release(cs)
+		if i == 0 && isInvalidatedStatement(err) {
+			recordStatementCache(ctx, "invalidated")
+			c.remove(query)
+			continue
+		}
+		return err
+	}
+}
+
+// isInvalidatedStatement reports whether err means that a prepared
+// statement can no longer be used: either the result type of its query has
+// changed, or it no longer exists on the server.
+func isInvalidatedStatement(err error) bool {
+	var perr *pq.Error
+	if !errors.As(err, &perr) {
+		return false
+	}
+	switch perr.Code {
+	case "0A000": // feature_not_supported: "cached plan must not change result type"
+		return perr.Message == "cached plan must not change result type"
+	case "26000": // invalid_sql_statement_name
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+func TestStatementCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	db := New(testDB.db, "test")
+	db.EnableStatementCache(2)
+	queries := []string{`SELECT $1::int`, `SELECT $1::int + 1`, `SELECT $1::int + 2`}
+	for i, q := range queries {
+		var got int
+		if err := db.QueryRow(ctx, q, 1).Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		if want := i + 1; got != want {
+			t.Errorf("%s: got %d, want %d", q, got, want)
+		}
+	}
+	if got := db.stmts.lru.Len(); got != 2 {
+		t.Errorf("got %d cached statements, want 2", got)
+	}
+	if _, ok := db.stmts.byQuery[queries[0]]; ok {
+		t.Errorf("least recently used query %q is still cached", queries[0])
+	}
+	db.InvalidateStatementCache()
+	if got := db.stmts.lru.Len(); got != 0 {
+		t.Errorf("after invalidation, got %d cached statements, want 0", got)
+	}
+}
+
+func TestStatementCacheSchemaChange(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if _, err := testDB.Exec(ctx, `DROP TABLE IF EXISTS test_stmt_cache`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.Exec(ctx, `CREATE TABLE test_stmt_cache (a int)`); err != nil {
+		t.Fatal(err)
+	}
+	defer testDB.Exec(ctx, `DROP TABLE test_stmt_cache`)
+	if _, err := testDB.Exec(ctx, `INSERT INTO test_stmt_cache (a) VALUES (1)`); err != nil {
+		t.Fatal(err)
+	}
+
+	db := New(testDB.db, "test")
+	db.EnableStatementCache(10)
+	count := func() int {
+		t.Helper()
+		n := 0
+		err := db.RunQuery(ctx, `SELECT * FROM test_stmt_cache WHERE a = $1`, func(*sql.Rows) error {
+			n++
+			return nil
+		}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if got := count(); got != 1 {
+		t.Fatalf("got %d rows, want 1", got)
+	}
+	// Changing the result type of the cached query invalidates its
+	// statement, which should be prepared again.
+	if _, err := testDB.Exec(ctx, `ALTER TABLE test_stmt_cache ADD COLUMN b int`); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(); got != 1 {
+		t.Fatalf("after schema change, got %d rows, want 1", got)
+	}
+}
@@ -36,6 +36,13 @@ type DataSource interface {
 	// GetTaggedVersionsForModule returns LegacyModuleInfo for all known tagged
 	// versions for any module containing a package with the given import path.
 	GetTaggedVersionsForPackageSeries(ctx context.Context, pkgPath string) ([]*ModuleInfo, error)
+	// GetUnit returns information about the unit described by um, which must
+	// come from GetUnitMeta, including only the given fields of its README,
+	// documentation and imports.
+	GetUnit(ctx context.Context, um *UnitMeta, fields FieldSet) (_ *VersionedDirectory, err error)
+	// GetUnitMeta returns the metadata of the unit at path in modulePath at
+	// version. The module and version must both be known.
+	GetUnitMeta(ctx context.Context, path, modulePath, version string) (_ *UnitMeta, err error)
 
 	// TODO(golang/go#39629): Deprecate these methods.
 	//
@@ -21,6 +21,7 @@ import (
 	"go.opencensus.io/zpages"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/log"
 	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
 )
@@ -48,7 +49,12 @@ func NewRouter(tagger RouteTagger) *Router {
 	mux := http.NewServeMux()
 	return &Router{
 		mux:     mux,
-		Handler: &ochttp.Handler{Handler: mux},
+		// Spans come from dtrace.Handler; the ochttp.Handler only records
+		// metrics.
+		Handler: &ochttp.Handler{
+			Handler:      mux,
+			StartOptions: trace.StartOptions{Sampler: trace.NeverSample()},
+		},
 		tagger:  tagger,
 	}
 }
@@ -58,7 +64,8 @@ func NewRouter(tagger RouteTagger) *Router {
 func (r *Router) Handle(route string, handler http.Handler) {
 	r.mux.HandleFunc(route, func(w http.ResponseWriter, req *http.Request) {
 		tag := r.tagger(route, req)
-		ochttp.WithRouteTag(handler, tag).ServeHTTP(w, req)
+		log.SetRequestLabel(req.Context(), "route", route)
+		ochttp.WithRouteTag(dtrace.Handler(tag, handler), tag).ServeHTTP(w, req)
 	})
 }
 
@@ -69,17 +76,16 @@ func (r *Router) HandleFunc(route string, handler http.HandlerFunc) {
 
 const debugPage = `
 <html>
-<p><a href="/tracez">/tracez</a> - trace spans</p>
 <p><a href="/statsz">/statz</a> - prometheus metrics page</p>
 `
 
-// Init configures tracing and aggregation according to the given Views. If
-// running on GCP, Init also configures exporting to StackDriver.
+// Init configures aggregation according to the given Views. If running on
+// GCP, Init also configures exporting to StackDriver. Traces are not handled
+// here, but by the dtrace package.
 func Init(cfg *config.Config, views ...*view.View) error {
-	// The default trace sampler samples with probability 1e-4. That's too
-	// infrequent for our traffic levels. In the future we may want to decrease
-	// this sampling rate.
-	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(0.01)})
+	// OpenCensus instrumentation, such as ochttp, still starts spans. Never
+	// sample them, so that they cost nothing.
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.NeverSample()})
 	if err := view.Register(views...); err != nil {
 		return fmt.Errorf("dcensus.Init(views): view.Register: %v", err)
 	}
@@ -112,7 +118,7 @@ func (r *monitoredResource) MonitoredResource() (resType string, labels map[stri
 }
 
 // ExportToStackdriver checks to see if the process is running in a GCP
-// environment, and if so configures exporting to stackdriver.
+// environment, and if so configures exporting metrics to stackdriver.
 func exportToStackdriver(ctx context.Context, cfg *config.Config) {
 	if cfg.ProjectID == "" {
 		log.Infof(ctx, "Not exporting to StackDriver: GOOGLE_CLOUD_PROJECT is unset.")
@@ -128,20 +134,6 @@ func exportToStackdriver(ctx context.Context, cfg *config.Config) {
 		log.Fatalf(ctx, "error creating view exporter: %v", err)
 	}
 	view.RegisterExporter(viewExporter)
-
-	// We want traces to be associated with the *app*, not the instance.
-	// TraceSpansBufferMaxBytes is increased from the default of 8MiB, though we
-	// can't increase *too* much because this is still running in GAE, which is
-	// relatively memory-constrained.
-	traceExporter, err := stackdriver.NewExporter(stackdriver.Options{
-		ProjectID:                cfg.ProjectID,
-		MonitoredResource:        (*monitoredResource)(cfg.AppMonitoredResource),
-		TraceSpansBufferMaxBytes: 32 * 1024 * 1024, // 32 MiB
-	})
-	if err != nil {
-		log.Fatalf(ctx, "error creating trace exporter: %v", err)
-	}
-	trace.RegisterExporter(traceExporter)
 }
 
 // NewViewExporter creates a StackDriver exporter for stats.
@@ -10,6 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/modstatus"
 )
 
 //lint:file-ignore ST1012 prefixing error values with Err would stutter
@@ -28,13 +31,23 @@ var (
 	BadModule = errors.New("bad module")
 	// Excluded indicates that the module is excluded. (See internal/postgres/excluded.go.)
 	Excluded = errors.New("excluded")
+	// Removed indicates that the path was removed for legal reasons (HTTP
+	// 451). (See internal/postgres/removed_paths.go.)
+	Removed = errors.New("removed")
 
 	// AlternativeModule indicates that the path of the module zip file differs
 	// from the path specified in the go.mod file.
 	AlternativeModule = errors.New("alternative module")
 
+	// ChecksumMismatch indicates that the hashes of the module served by the
+	// proxy do not match the checksum database.
+	ChecksumMismatch = errors.New("checksum mismatch")
+
 	// Unknown indicates that the error has unknown semantics.
 	Unknown = errors.New("unknown")
+	// Panic indicates that the code panicked. It corresponds to HTTP 500,
+	// like Unknown, but has its own category.
+	Panic = errors.New("panic")
 
 	// PackageBuildContextNotSupported indicates that the build context for the
 	// package is not supported.
@@ -83,23 +96,24 @@ var httpCodes = []struct {
 	err  error
 	code int
 }{
-	{NotFound, http.StatusNotFound},
-	{InvalidArgument, http.StatusBadRequest},
-	{Excluded, http.StatusForbidden},
-
-	// Since the following aren't HTTP statuses, pick unused codes.
-	{HasIncompletePackages, 290},
-	{DBModuleInsertInvalid, 480},
-	{BadModule, 490},
-	{AlternativeModule, 491},
-
-	// 52x errors represents modules that need to be reprocessed, and the
-	// previous status code the module had. Note that the status code
-	// matters for determining reprocessing order.
-	{ReprocessStatusOK, 520},
-	{ReprocessHasIncompletePackages, 521},
-	{ReprocessBadModule, 540},
-	{ReprocessAlternative, 541},
+	{NotFound, int(modstatus.NotFound)},
+	{InvalidArgument, int(modstatus.InvalidArgument)},
+	{Excluded, int(modstatus.Excluded)},
+	{Removed, int(modstatus.Removed)},
+
+	// Since the following aren't HTTP statuses, modstatus picks unused codes.
+	{HasIncompletePackages, int(modstatus.HasIncompletePackages)},
+	{DBModuleInsertInvalid, int(modstatus.DBModuleInsertInvalid)},
+	{BadModule, int(modstatus.BadModule)},
+	{AlternativeModule, int(modstatus.AlternativeModule)},
+	{ChecksumMismatch, int(modstatus.ChecksumMismatch)},
+
+	// Reprocess errors represent modules that need to be reprocessed, and the
+	// previous status code the module had.
+	{ReprocessStatusOK, int(modstatus.ReprocessOK)},
+	{ReprocessHasIncompletePackages, int(modstatus.ReprocessHasIncompletePackages)},
+	{ReprocessBadModule, int(modstatus.ReprocessBadModule)},
+	{ReprocessAlternative, int(modstatus.ReprocessAlternative)},
 
 	// 60x errors represents errors that occurred when processing a
 	// package.
@@ -147,21 +161,29 @@ func ToHTTPStatus(err error) int {
 	return http.StatusInternalServerError
 }
 
-// ToReprocessStatus returns the reprocess status code corresponding to the
-// provided status.
-func ToReprocessStatus(status int) int {
-	switch status {
-	case http.StatusOK:
-		return ToHTTPStatus(ReprocessStatusOK)
-	case ToHTTPStatus(HasIncompletePackages):
-		return ToHTTPStatus(ReprocessHasIncompletePackages)
-	case ToHTTPStatus(BadModule):
-		return ToHTTPStatus(ReprocessBadModule)
-	case ToHTTPStatus(AlternativeModule):
-		return ToHTTPStatus(ReprocessAlternative)
-	default:
-		return status
+// Category returns a short name for the kind of error that err is: the
+// message of the first error in httpCodes that err wraps, with spaces replaced
+// by hyphens, such as "not-found". It returns "panic" if err wraps Panic,
+// "unknown" if err wraps none of them, and "" if err is nil.
+func Category(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, Panic) {
+		return Panic.Error()
+	}
+	for _, e := range httpCodes {
+		if errors.Is(err, e.err) {
+			return strings.ReplaceAll(e.err.Error(), " ", "-")
+		}
 	}
+	return strings.ReplaceAll(Unknown.Error(), " ", "-")
+}
+
+// ToModuleStatus returns the status of a module version whose processing
+// failed with err, or modstatus.OK if err is nil.
+func ToModuleStatus(err error) modstatus.Code {
+	return modstatus.Code(ToHTTPStatus(err))
 }
 
 // Add adds context to the error.
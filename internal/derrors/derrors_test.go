@@ -63,6 +63,23 @@ func TestToHTTPStatus(t *testing.T) {
 	}
 }
 
+func TestCategory(t *testing.T) {
+	for _, tc := range []struct {
+		in   error
+		want string
+	}{
+		{nil, ""},
+		{InvalidArgument, "invalid-argument"},
+		{fmt.Errorf("wrapping: %w", NotFound), "not-found"},
+		{io.ErrUnexpectedEOF, "unknown"},
+		{fmt.Errorf("index out of range: %w", Panic), "panic"},
+	} {
+		if got := Category(tc.in); got != tc.want {
+			t.Errorf("Category(%v) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
 func TestAdd(t *testing.T) {
 	var err error
 	Add(&err, "whatever")
@@ -39,6 +39,10 @@ type ModuleInfo struct {
 	IsRedistributable bool
 	HasGoMod          bool // whether the module zip has a go.mod file
 	SourceInfo        *source.Info
+	// PublishedAt is when the module index reported the version, which is
+	// when it was first fetched from the proxy. It is the zero time if it is
+	// not known, like for the standard library.
+	PublishedAt time.Time
 }
 
 // LegacyModuleInfo holds metadata associated with a module.
@@ -97,10 +101,27 @@ type Module struct {
 	LegacyModuleInfo
 	// Licenses holds all licenses within this module version, including those
 	// that may be contained in nested subdirectories.
-	Licenses    []*licenses.License
+	Licenses []*licenses.License
+	// Notices holds the files that accompany the licenses, like NOTICE and
+	// PATENTS files.
+	Notices     []*licenses.Notice
 	Directories []*DirectoryNew
+	// Requirements are the modules that the go.mod file of the module
+	// requires, ordered by module path.
+	Requirements []*ModuleRequirement
 
 	LegacyPackages []*LegacyPackage
+	// AppVersion is the version of the worker that processed the module. It
+	// is recorded with the data derived from the module, like documentation
+	// and licenses, so that data computed by old versions can be found.
+	AppVersion string
+}
+
+// A ModuleRequirement is a requirement in the go.mod file of a module on a
+// version of another module.
+type ModuleRequirement struct {
+	ModulePath string
+	Version    string
 }
 
 // VersionedDirectory is a DirectoryNew along with its corresponding module
@@ -119,6 +140,22 @@ type DirectoryMeta struct {
 	Licenses          []*licenses.Metadata // metadata of applicable licenses
 }
 
+// UnitMeta is the metadata of a unit: a directory in a module version, which
+// may be a package, the root of the module, or both. It is cheap to read from
+// the data store, and identifies the unit to read the rest of with GetUnit.
+type UnitMeta struct {
+	DirectoryMeta
+	ModuleInfo
+	// Name is the name of the package at Path, or the empty string if the
+	// unit is not a package.
+	Name string
+}
+
+// IsPackage reports whether the unit is a package.
+func (um *UnitMeta) IsPackage() bool {
+	return um.Name != ""
+}
+
 // DirectoryNew represents a folder in a module version, and the contents of that folder.
 // It will replace LegacyDirectory once everything has been migrated.
 type DirectoryNew struct {
@@ -146,6 +183,9 @@ type Documentation struct {
 	GOARCH   string
 	Synopsis string
 	HTML     string
+	// Coverage is the fraction of the identifiers in the documentation that
+	// have doc comments, or nil if there are none or it is not known.
+	Coverage *float64
 }
 
 // Readme is a README at a given directory.
@@ -203,6 +243,46 @@ type ModuleVersionState struct {
 	// NumPackages it the number of packages that were processed as part of the
 	// module (regardless of whether the processing was successful).
 	NumPackages *int
+
+	// DeadLetteredAt is the time this version was moved to the dead-letter
+	// queue after failing repeatedly, or nil if it is scheduled normally.
+	// Versions in the dead-letter queue are not requeued.
+	DeadLetteredAt *time.Time
+	// DeadLetterReason describes why the version was moved to the dead-letter
+	// queue.
+	DeadLetterReason string
+
+	// ZipHash is the go.sum hash of the module zip that was last processed,
+	// or empty if it is not known.
+	ZipHash string
+	// ChecksumResult is the result of verifying the version against the
+	// checksum database, or empty if it was not verified.
+	ChecksumResult string
+	// Proxy is the URL of the module proxy that served the version when it
+	// was last processed, or empty if it is not known.
+	Proxy string
+}
+
+// ModuleVersionStateForUpsert holds the result of processing a module
+// version, to be recorded in its module version state.
+type ModuleVersionStateForUpsert struct {
+	ModulePath string
+	Version    string
+	AppVersion string
+	Timestamp  time.Time
+	Status     int
+	GoModPath  string
+	// ZipHash is the go.sum hash of the module zip that was processed, or
+	// empty if it is not known.
+	ZipHash string
+	// ChecksumResult is the result of verifying the version against the
+	// checksum database, or empty if it was not verified.
+	ChecksumResult string
+	// Proxy is the URL of the module proxy that served the version, or empty
+	// if it is not known.
+	Proxy                string
+	FetchErr             error
+	PackageVersionStates []*PackageVersionState
 }
 
 // PackageVersionState holds a worker package version state. It is associated
@@ -215,6 +295,18 @@ type PackageVersionState struct {
 	Error       string
 }
 
+// LicenseChange describes a version of a module whose license types differ
+// from those of the version before it.
+type LicenseChange struct {
+	ModulePath      string
+	Version         string
+	PreviousVersion string
+	// Added and Removed are the license types that appear only in Version
+	// and only in PreviousVersion, respectively.
+	Added   []string
+	Removed []string
+}
+
 // SearchResult represents a single search result from SearchDocuments.
 type SearchResult struct {
 	Name        string
@@ -231,6 +323,14 @@ type SearchResult struct {
 	// NumImportedBy is the number of packages that import PackagePath.
 	NumImportedBy uint64
 
+	// NumVulns is the number of known vulnerabilities of the module at
+	// Version.
+	NumVulns int
+
+	// Owner is the owner of the module on a code hosting site, like
+	// github.com/spf13, or the empty string if it is not known.
+	Owner string
+
 	// NumResults is the total number of packages that were returned for this
 	// search.
 	NumResults uint64
@@ -238,6 +338,12 @@ type SearchResult struct {
 	// can be approximate if search scanned only a subset of documents, and
 	// result count is estimated using the hyperloglog algorithm.
 	Approximate bool
+
+	// NumModuleResults is the number of packages of ModulePath that matched
+	// the search, when results are grouped by module. Only some of them may
+	// be returned. In that case NumResults is the number of modules that
+	// matched.
+	NumModuleResults uint64
 }
 
 // A FieldSet is a bit set of struct fields. It is used to avoid reading large
@@ -270,6 +376,7 @@ const StringFieldMissing = "!MISSING"
 const (
 	WithReadmeContents FieldSet = 1 << iota
 	WithDocumentationHTML
+	WithImports
 )
 
 // LegacyDirectory represents a folder in a module version, and all of the
@@ -290,6 +397,10 @@ type LegacyPackage struct {
 	Licenses          []*licenses.Metadata // metadata of applicable licenses
 	Imports           []string
 	DocumentationHTML string
+	// DocumentationCoverage is the fraction of the identifiers in the
+	// documentation that have doc comments, or nil if there are none or it is
+	// not known.
+	DocumentationCoverage *float64
 	// The values of the GOOS and GOARCH environment variables used to parse the
 	// package.
 	GOOS   string
@@ -298,6 +409,27 @@ type LegacyPackage struct {
 	// V1Path is the package path of a package with major version 1 in a given
 	// series.
 	V1Path string
+
+	// Findings summarizes what analyzers reported about the source of the
+	// package. It is empty if no analyzers ran, or if they found nothing.
+	Findings []*Finding
+
+	// Symbols is the exported API of the package, with methods and fields
+	// as "Type.Method" and "Type.Field". It is nil if it is not known.
+	Symbols []string
+	// ImportedSymbols are the package-level symbols that the package refers
+	// to in each of its imports, by import path.
+	ImportedSymbols map[string][]string
+}
+
+// A Finding is the number of problems of one kind that an analyzer reported
+// in the source of a package.
+type Finding struct {
+	// Analyzer is the name of the analyzer, such as "vet".
+	Analyzer string
+	// Category is the kind of problem, such as the name of a vet check.
+	Category string
+	Count    int
 }
 
 // LegacyVersionedPackage is a LegacyPackage along with its corresponding module
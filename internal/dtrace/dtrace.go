@@ -0,0 +1,155 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package dtrace provides distributed tracing with OpenTelemetry.
+//
+// Spans started with this package are exported over OTLP, if an endpoint is
+// configured, and their context is propagated across processes in W3C Trace
+// Context headers: from the frontend to the worker through queued tasks, and
+// from both to the module proxy and other servers.
+package dtrace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagators"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// instrumentationName names the tracer of this package.
+const instrumentationName = "golang.org/x/pkgsite"
+
+// sampleRate is the fraction of traces that are sampled, unless the caller
+// that started the trace decided otherwise. It is the same as the rate that
+// was used with OpenCensus.
+const sampleRate = 0.01
+
+// Keys of the attributes that identify the module that a span is about. They
+// are set on the spans of every layer that handles a module, so that all the
+// spans for a module can be found.
+const (
+	ModulePathKey = label.Key("pkgsite.module_path")
+	VersionKey    = label.Key("pkgsite.version")
+)
+
+// ModulePath returns the attribute for a module path.
+func ModulePath(modulePath string) label.KeyValue {
+	return ModulePathKey.String(modulePath)
+}
+
+// Version returns the attribute for a version.
+func Version(version string) label.KeyValue {
+	return VersionKey.String(version)
+}
+
+// Init configures tracing for the service with the given name, such as
+// "frontend" or "worker". If cfg.OTLPEndpoint is set, spans are exported to
+// the OpenTelemetry collector at that address; otherwise no spans are
+// recorded.
+func Init(ctx context.Context, cfg *config.Config, serviceName string) error {
+	global.SetTextMapPropagator(propagators.TraceContext{})
+	if cfg.OTLPEndpoint == "" {
+		log.Infof(ctx, "Not exporting traces: GO_DISCOVERY_OTLP_ENDPOINT is unset.")
+		return nil
+	}
+	opts := []otlp.ExporterOption{otlp.WithAddress(cfg.OTLPEndpoint)}
+	if cfg.OTLPInsecure {
+		opts = append(opts, otlp.WithInsecure())
+	}
+	exporter, err := otlp.NewExporter(opts...)
+	if err != nil {
+		return fmt.Errorf("dtrace.Init: otlp.NewExporter: %v", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{
+			DefaultSampler: sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRate)),
+		}),
+		sdktrace.WithResource(resource.New(
+			semconv.ServiceNamespaceKey.String("go-discovery"),
+			semconv.ServiceNameKey.String(serviceName),
+			semconv.ServiceVersionKey.String(cfg.AppVersionLabel()),
+			semconv.ServiceInstanceIDKey.String(cfg.InstanceID),
+		)),
+		sdktrace.WithBatcher(exporter),
+	)
+	global.SetTracerProvider(tp)
+	log.Infof(ctx, "Exporting traces to %s", cfg.OTLPEndpoint)
+	return nil
+}
+
+// StartSpan starts a span with the given name and attributes, as a child of
+// the span in ctx, if any. It returns a context with the new span.
+func StartSpan(ctx context.Context, name string, attrs ...label.KeyValue) (context.Context, trace.Span) {
+	return StartSpanKind(ctx, name, trace.SpanKindInternal, attrs...)
+}
+
+// StartSpanKind is like StartSpan, but starts a span of the given kind, such
+// as trace.SpanKindProducer for a span that schedules work.
+func StartSpanKind(ctx context.Context, name string, kind trace.SpanKind, attrs ...label.KeyValue) (context.Context, trace.Span) {
+	return global.Tracer(instrumentationName).Start(ctx, name,
+		trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// SetAttributes sets attributes on the span in ctx, if any.
+func SetAttributes(ctx context.Context, attrs ...label.KeyValue) {
+	trace.SpanFromContext(ctx).SetAttributes(attrs...)
+}
+
+// End ends span, first recording *errp on it if it is non-nil. It is meant to
+// be deferred, like derrors.Wrap:
+//
+//	ctx, span := dtrace.StartSpan(ctx, "name")
+//	defer dtrace.End(span, &err)
+func End(span trace.Span, errp *error) {
+	if errp != nil && *errp != nil {
+		span.RecordError(context.Background(), *errp, trace.WithErrorStatus(codes.Error))
+	}
+	span.End()
+}
+
+// IDs returns the trace and span IDs of the span in ctx, or empty strings if
+// there is no recorded span.
+func IDs(ctx context.Context) (traceID, spanID string) {
+	sc := trace.SpanFromContext(ctx).SpanContext()
+	if !sc.IsValid() {
+		return "", ""
+	}
+	return sc.TraceID.String(), sc.SpanID.String()
+}
+
+// Inject adds the trace context of ctx to h.
+func Inject(ctx context.Context, h http.Header) {
+	global.TextMapPropagator().Inject(ctx, h)
+}
+
+// Extract returns ctx with the trace context in h, if any.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	return global.TextMapPropagator().Extract(ctx, h)
+}
+
+// TaskHeaders returns the headers that propagate the trace context of ctx to
+// the handler of a queued task.
+func TaskHeaders(ctx context.Context) map[string]string {
+	h := http.Header{}
+	Inject(ctx, h)
+	if len(h) == 0 {
+		return nil
+	}
+	m := map[string]string{}
+	for k := range h {
+		m[k] = h.Get(k)
+	}
+	return m
+}
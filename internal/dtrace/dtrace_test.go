@@ -0,0 +1,170 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dtrace
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/api/global"
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/label"
+	"go.opentelemetry.io/otel/propagators"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	"go.opentelemetry.io/otel/sdk/export/trace/tracetest"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// setupTracing records every span in the returned exporter.
+func setupTracing(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	prevProvider, prevPropagator := global.TracerProvider(), global.TextMapPropagator()
+	global.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()}),
+		sdktrace.WithSyncer(exporter)))
+	global.SetTextMapPropagator(propagators.TraceContext{})
+	t.Cleanup(func() {
+		global.SetTracerProvider(prevProvider)
+		global.SetTextMapPropagator(prevPropagator)
+	})
+	return exporter
+}
+
+func findSpan(t *testing.T, spans []*exporttrace.SpanData, name string) *exporttrace.SpanData {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no span named %q", name)
+	return nil
+}
+
+func attribute(s *exporttrace.SpanData, k label.Key) string {
+	for _, kv := range s.Attributes {
+		if kv.Key == k {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestHandlerAndTransport(t *testing.T) {
+	exporter := setupTracing(t)
+
+	// The backend is traced as a continuation of the trace of the client.
+	var backendTraceparent string
+	backend := httptest.NewServer(Handler("backend", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendTraceparent = r.Header.Get("traceparent")
+		SetAttributes(r.Context(), ModulePath("example.com/m"), Version("v1.0.0"))
+		w.WriteHeader(http.StatusNotFound)
+	})))
+	defer backend.Close()
+
+	ctx, root := StartSpan(context.Background(), "root")
+	client := &http.Client{Transport: Transport(nil)}
+	req, err := http.NewRequestWithContext(ctx, "GET", backend.URL+"/example.com/m/@v/v1.0.0.info", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	root.End()
+
+	if backendTraceparent == "" {
+		t.Fatal("request has no traceparent header")
+	}
+	spans := exporter.GetSpans()
+	rootSpan := findSpan(t, spans, "root")
+	clientSpan := findSpan(t, spans, "HTTP GET")
+	serverSpan := findSpan(t, spans, "backend")
+	if clientSpan.SpanKind != trace.SpanKindClient || serverSpan.SpanKind != trace.SpanKindServer {
+		t.Errorf("got kinds %s, %s; want client, server", clientSpan.SpanKind, serverSpan.SpanKind)
+	}
+	if clientSpan.ParentSpanID != rootSpan.SpanContext.SpanID {
+		t.Error("client span is not a child of the root span")
+	}
+	if serverSpan.ParentSpanID != clientSpan.SpanContext.SpanID || !serverSpan.HasRemoteParent {
+		t.Error("server span is not a child of the client span")
+	}
+	if serverSpan.SpanContext.TraceID != rootSpan.SpanContext.TraceID {
+		t.Error("server span is not in the trace of the root span")
+	}
+	if got, want := attribute(serverSpan, ModulePathKey), "example.com/m"; got != want {
+		t.Errorf("module path = %q, want %q", got, want)
+	}
+	if got, want := attribute(serverSpan, VersionKey), "v1.0.0"; got != want {
+		t.Errorf("version = %q, want %q", got, want)
+	}
+	for _, s := range []*exporttrace.SpanData{clientSpan, serverSpan} {
+		if got, want := attribute(s, "http.status_code"), "404"; got != want {
+			t.Errorf("%s: status code = %q, want %q", s.Name, got, want)
+		}
+	}
+}
+
+func TestTaskHeaders(t *testing.T) {
+	exporter := setupTracing(t)
+
+	if got := TaskHeaders(context.Background()); got != nil {
+		t.Errorf("TaskHeaders(no span) = %v, want nil", got)
+	}
+	ctx, span := StartSpanKind(context.Background(), "schedule", trace.SpanKindProducer)
+	headers := TaskHeaders(ctx)
+	span.End()
+
+	h := http.Header{}
+	for k, v := range headers {
+		h.Set(k, v)
+	}
+	_, task := StartSpan(Extract(context.Background(), h), "task")
+	task.End()
+	spans := exporter.GetSpans()
+	if findSpan(t, spans, "task").ParentSpanID != findSpan(t, spans, "schedule").SpanContext.SpanID {
+		t.Errorf("task span is not a child of the span that scheduled it (headers %v)", headers)
+	}
+}
+
+func TestEnd(t *testing.T) {
+	exporter := setupTracing(t)
+
+	_, span := StartSpan(context.Background(), "ok")
+	var err error
+	End(span, &err)
+	_, span = StartSpan(context.Background(), "failed")
+	err = errors.New("bad")
+	End(span, &err)
+
+	spans := exporter.GetSpans()
+	if got := findSpan(t, spans, "ok").StatusCode; got != codes.Unset {
+		t.Errorf("ok: status = %s, want Unset", got)
+	}
+	failed := findSpan(t, spans, "failed")
+	if failed.StatusCode != codes.Error || len(failed.MessageEvents) != 1 {
+		t.Errorf("failed: status = %s with %d events, want Error with 1 event", failed.StatusCode, len(failed.MessageEvents))
+	}
+}
+
+func TestIDs(t *testing.T) {
+	if traceID, spanID := IDs(context.Background()); traceID != "" || spanID != "" {
+		t.Errorf("IDs(no span) = %q, %q; want empty", traceID, spanID)
+	}
+	setupTracing(t)
+	ctx, span := StartSpan(context.Background(), "test")
+	defer span.End()
+	sc := span.SpanContext()
+	if traceID, spanID := IDs(ctx); traceID != sc.TraceID.String() || spanID != sc.SpanID.String() {
+		t.Errorf("IDs = %q, %q; want %q, %q", traceID, spanID, sc.TraceID, sc.SpanID)
+	}
+}
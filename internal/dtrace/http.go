@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dtrace
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"go.opentelemetry.io/otel/semconv"
+)
+
+// Handler returns a handler that serves each request with h inside a server
+// span named for route, continuing the trace of the client that made the
+// request, if any.
+func Handler(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := Extract(r.Context(), r.Header)
+		ctx, span := StartSpanKind(ctx, route, trace.SpanKindServer,
+			semconv.HTTPServerAttributesFromHTTPRequest("", route, r)...)
+		defer span.End()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(sw, r.WithContext(ctx))
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(sw.status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(sw.status))
+	})
+}
+
+// statusWriter is an http.ResponseWriter that records the status of the
+// response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Transport returns an http.RoundTripper that makes each request with base,
+// or http.DefaultTransport if base is nil, inside a client span, and passes
+// the trace context to the server.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartSpanKind(req.Context(), "HTTP "+req.Method, trace.SpanKindClient,
+		semconv.HTTPClientAttributesFromHTTPRequest(req)...)
+	// RoundTrip must not modify req, so inject into a copy.
+	req = req.Clone(ctx)
+	Inject(ctx, req.Header)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		End(span, &err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode))
+	// The span covers the time to the response headers; reading the body is
+	// the caller's business.
+	span.End()
+	return resp, nil
+}
@@ -9,11 +9,14 @@ import (
 )
 
 const (
+	ExperimentCompressText                = "compress-text"
 	ExperimentFrontendFetch               = "frontend-fetch"
 	ExperimentFrontendPackageAtMaster     = "frontend-package-at-master"
 	ExperimentInsertDirectories           = "insert-directories"
 	ExperimentInsertPlaygroundLinks       = "insert-playground-links"
 	ExperimentInsertSerializable          = "insert-serializable-txn"
+	ExperimentRunAnalyzers                = "run-analyzers"
+	ExperimentSearchGrouping              = "search-grouping"
 	ExperimentTeeProxyMakePkgGoDevRequest = "teeproxy-make-pkg-go-dev-request"
 	ExperimentUseDirectories              = "use-directories"
 	ExperimentTranslateHTML               = "translate-html"
@@ -0,0 +1,108 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// An Analyzer examines the source of packages while they are fetched, and
+// summarizes what it finds as counts of findings, which are stored as quality
+// signals of the packages.
+type Analyzer interface {
+	// Name identifies the analyzer in the findings that it reports.
+	Name() string
+
+	// Analyze returns the number of findings in each category for the
+	// package described by in. Categories with no findings may be omitted.
+	// Analyze should return ctx.Err() soon after ctx is done.
+	Analyze(ctx context.Context, in *AnalysisInput) (map[string]int, error)
+}
+
+// AnalysisInput is the package that an Analyzer examines.
+type AnalysisInput struct {
+	ImportPath string
+	// The values of the GOOS and GOARCH environment variables used to parse
+	// the package.
+	GOOS   string
+	GOARCH string
+	Fset   *token.FileSet
+	// Files are the non-test files of the package that match GOOS and
+	// GOARCH, sorted by name. Analyzers must not modify them.
+	Files []*ast.File
+}
+
+// Analyzers are the analyzers that run on each package when the
+// internal.ExperimentRunAnalyzers experiment is active.
+var Analyzers []Analyzer
+
+const (
+	// maxAnalyzedPackageSize is the largest total size of the non-test Go
+	// files of a package that is analyzed. Larger packages are not analyzed.
+	maxAnalyzedPackageSize = 2 * megabyte
+
+	// maxAnalysisTime is how long each analyzer has to analyze a package.
+	maxAnalysisTime = 30 * time.Second
+)
+
+// analyzePackage runs Analyzers on the package described by in, whose
+// non-test files have a total size of size bytes, and returns their findings
+// sorted by analyzer and category.
+//
+// Analysis only provides quality signals, so it never causes a fetch to fail:
+// an analyzer that returns an error, panics or runs out of time contributes no
+// findings, and the problem is logged.
+func analyzePackage(ctx context.Context, in *AnalysisInput, size int) []*internal.Finding {
+	if len(Analyzers) == 0 {
+		return nil
+	}
+	if size > maxAnalyzedPackageSize {
+		log.Infof(ctx, "not analyzing %s: %d bytes of Go files exceeds limit %d for maxAnalyzedPackageSize",
+			in.ImportPath, size, maxAnalyzedPackageSize)
+		return nil
+	}
+	var findings []*internal.Finding
+	for _, a := range Analyzers {
+		counts, err := runAnalyzer(ctx, a, in)
+		if err != nil {
+			log.Infof(ctx, "analyzing %s with %s: %v", in.ImportPath, a.Name(), err)
+			continue
+		}
+		for category, n := range counts {
+			if n > 0 {
+				findings = append(findings, &internal.Finding{Analyzer: a.Name(), Category: category, Count: n})
+			}
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Analyzer != findings[j].Analyzer {
+			return findings[i].Analyzer < findings[j].Analyzer
+		}
+		return findings[i].Category < findings[j].Category
+	})
+	return findings
+}
+
+// runAnalyzer runs a on in with a time limit of maxAnalysisTime, converting
+// panics to errors.
+func runAnalyzer(ctx context.Context, a Analyzer, in *AnalysisInput) (_ map[string]int, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("internal panic: %v\n\n%s", e, debug.Stack())
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, maxAnalysisTime)
+	defer cancel()
+	return a.Analyze(ctx, in)
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+// fakeAnalyzer is an Analyzer that returns counts and err, or panics if
+// panics is set.
+type fakeAnalyzer struct {
+	name   string
+	counts map[string]int
+	err    error
+	panics bool
+}
+
+func (a *fakeAnalyzer) Name() string { return a.name }
+
+func (a *fakeAnalyzer) Analyze(context.Context, *AnalysisInput) (map[string]int, error) {
+	if a.panics {
+		panic("bad analyzer")
+	}
+	return a.counts, a.err
+}
+
+func TestAnalyzePackage(t *testing.T) {
+	defer func(as []Analyzer) { Analyzers = as }(Analyzers)
+	Analyzers = []Analyzer{
+		&fakeAnalyzer{name: "b", counts: map[string]int{"y": 2, "x": 1, "none": 0}},
+		&fakeAnalyzer{name: "a", counts: map[string]int{"z": 3}},
+		&fakeAnalyzer{name: "failing", counts: map[string]int{"x": 1}, err: errors.New("failed")},
+		&fakeAnalyzer{name: "panicking", panics: true},
+	}
+	ctx := context.Background()
+	in := &AnalysisInput{ImportPath: "example.com/p"}
+
+	got := analyzePackage(ctx, in, 100)
+	want := []*internal.Finding{
+		{Analyzer: "a", Category: "z", Count: 3},
+		{Analyzer: "b", Category: "x", Count: 1},
+		{Analyzer: "b", Category: "y", Count: 2},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	if got := analyzePackage(ctx, in, maxAnalyzedPackageSize+1); got != nil {
+		t.Errorf("got %v for a package that is too large, want nil", got)
+	}
+}
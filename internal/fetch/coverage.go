@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+
+	"golang.org/x/pkgsite/internal/fetch/internal/doc"
+)
+
+// documentationCoverage returns the fraction of the identifiers in the
+// documentation of d that have doc comments, or nil if it has none. The
+// identifiers are the constants, variables, functions, types and methods that
+// the documentation shows, which are the exported ones except for the builtin
+// package. A constant or variable is documented by a comment on its
+// declaration or on its line.
+func documentationCoverage(d *doc.Package) *float64 {
+	var documented, total int
+	values := func(vs []*doc.Value) {
+		for _, v := range vs {
+			for _, spec := range v.Decl.Specs {
+				s, ok := spec.(*ast.ValueSpec)
+				if !ok {
+					continue
+				}
+				hasDoc := v.Doc != "" || s.Doc != nil || s.Comment != nil
+				for _, n := range s.Names {
+					if n.Name == "_" {
+						continue
+					}
+					total++
+					if hasDoc {
+						documented++
+					}
+				}
+			}
+		}
+	}
+	funcs := func(fs []*doc.Func) {
+		for _, f := range fs {
+			total++
+			if f.Doc != "" {
+				documented++
+			}
+		}
+	}
+	values(d.Consts)
+	values(d.Vars)
+	funcs(d.Funcs)
+	for _, t := range d.Types {
+		total++
+		if t.Doc != "" {
+			documented++
+		}
+		values(t.Consts)
+		values(t.Vars)
+		funcs(t.Funcs)
+		funcs(t.Methods)
+	}
+	if total == 0 {
+		return nil
+	}
+	c := float64(documented) / float64(total)
+	return &c
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/fetch/internal/doc"
+)
+
+func TestDocumentationCoverage(t *testing.T) {
+	for _, test := range []struct {
+		name, src string
+		want      float64 // -1 for nil
+	}{
+		{"empty", ``, -1},
+		{"unexported only", `func f() {}`, -1},
+		{"all", `
+			// F does things.
+			func F() {}`, 1},
+		{"none", `
+			func F() {}
+			type T int`, 0},
+		{"values", `
+			// A and B are documented by their declaration.
+			const A, B = 1, 2
+
+			var (
+				// C is documented.
+				C = 3
+				D = 4 // D is documented too.
+				E = 5
+				_ = 6
+			)`, 4.0 / 5},
+		{"types and methods", `
+			// T is documented.
+			type T struct{}
+
+			// New is associated with T.
+			func New() *T { return nil }
+
+			func (*T) M() {}
+
+			func (*T) m() {}`, 2.0 / 3},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "p.go", "package p\n"+test.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := doc.NewFromFiles(fset, []*ast.File{f}, "example.com/p")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := documentationCoverage(d)
+			switch {
+			case test.want < 0 && got != nil:
+				t.Errorf("got %v, want nil", *got)
+			case test.want >= 0 && (got == nil || *got != test.want):
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
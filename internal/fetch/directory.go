@@ -68,6 +68,7 @@ func moduleDirectories(modulePath string,
 					GOARCH:   pkg.GOARCH,
 					Synopsis: pkg.Synopsis,
 					HTML:     pkg.DocumentationHTML,
+					Coverage: pkg.DocumentationCoverage,
 				},
 			}
 		}
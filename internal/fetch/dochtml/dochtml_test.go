@@ -40,6 +40,17 @@ func TestRender(t *testing.T) {
 		// Check that the id and data-kind labels are right.
 		testIDsAndKinds(t, htmlDoc)
 	})
+	// The remaining checks are for the structure that assistive technologies,
+	// like screen readers, use to navigate the documentation.
+	t.Run("headings", func(t *testing.T) {
+		testHeadings(t, htmlDoc)
+	})
+	t.Run("landmarks", func(t *testing.T) {
+		testLandmarks(t, htmlDoc)
+	})
+	t.Run("links", func(t *testing.T) {
+		testLinks(t, htmlDoc)
+	})
 }
 
 func testDuplicateIDs(t *testing.T, htmlDoc *html.Node) {
@@ -102,6 +113,123 @@ func testIDsAndKinds(t *testing.T, htmlDoc *html.Node) {
 	}
 }
 
+// testHeadings checks that the headings are properly nested: the first one is
+// an h2, because the page that shows the documentation has the h1, and no
+// heading is more than one level below the heading before it.
+func testHeadings(t *testing.T, htmlDoc *html.Node) {
+	var levels []int
+	walk(htmlDoc, func(n *html.Node) {
+		if n.Type == html.ElementNode && len(n.Data) == 2 && n.Data[0] == 'h' && '1' <= n.Data[1] && n.Data[1] <= '6' {
+			levels = append(levels, int(n.Data[1]-'0'))
+		}
+	})
+	if len(levels) == 0 {
+		t.Fatal("no headings")
+	}
+	if levels[0] != 2 {
+		t.Errorf("first heading is h%d, want h2", levels[0])
+	}
+	for i := 1; i < len(levels); i++ {
+		if levels[i] > levels[i-1]+1 {
+			t.Errorf("heading %d is h%d, after h%d", i, levels[i], levels[i-1])
+		}
+	}
+	// The package comment and the method comment have headings, nested under
+	// the overview and the method.
+	for id, want := range map[string]string{
+		"hdr-Headings": "h3",
+		"hdr-Details":  "h5",
+		"T.M":          "h4",
+	} {
+		if n := findByID(htmlDoc, id); n == nil {
+			t.Errorf("no element with id %q", id)
+		} else if n.Data != want {
+			t.Errorf("element with id %q is %s, want %s", id, n.Data, want)
+		}
+	}
+}
+
+// testLandmarks checks that the navigation and every section are labeled, and
+// that the skip link skips the navigation.
+func testLandmarks(t *testing.T, htmlDoc *html.Node) {
+	var navs, sections int
+	walk(htmlDoc, func(n *html.Node) {
+		if n.Type != html.ElementNode || (n.Data != "nav" && n.Data != "section") {
+			return
+		}
+		if n.Data == "nav" {
+			navs++
+		} else {
+			sections++
+		}
+		if attr(n, "aria-label") != "" {
+			return
+		}
+		id := attr(n, "aria-labelledby")
+		if id == "" {
+			t.Errorf("<%s class=%q> has no label", n.Data, attr(n, "class"))
+			return
+		}
+		if findByID(htmlDoc, id) == nil {
+			t.Errorf("<%s class=%q> is labeled by %q, which does not exist", n.Data, attr(n, "class"), id)
+		}
+	})
+	if navs != 1 {
+		t.Errorf("got %d navs, want 1", navs)
+	}
+	// Overview, index, constants, variables, functions, types and notes.
+	if want := 7; sections != want {
+		t.Errorf("got %d sections, want %d", sections, want)
+	}
+
+	var skip *html.Node
+	walk(htmlDoc, func(n *html.Node) {
+		if skip == nil && n.Type == html.ElementNode && n.Data == "a" {
+			skip = n
+		}
+	})
+	if skip == nil || attr(skip, "class") != "Documentation-skipLink" {
+		t.Fatal("the first link is not the skip link")
+	}
+	content := findByID(htmlDoc, strings.TrimPrefix(attr(skip, "href"), "#"))
+	if content == nil {
+		t.Fatalf("skip link points to %q, which does not exist", attr(skip, "href"))
+	}
+	walk(content, func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "nav" {
+			t.Error("skip link target contains the navigation")
+		}
+	})
+}
+
+// testLinks checks that every link within the documentation points to an
+// element that exists, and that the permalinks, whose text is just a pilcrow,
+// have a label.
+func testLinks(t *testing.T, htmlDoc *html.Node) {
+	walk(htmlDoc, func(n *html.Node) {
+		if n.Type != html.ElementNode || n.Data != "a" {
+			return
+		}
+		href := attr(n, "href")
+		if strings.HasPrefix(href, "#") && findByID(htmlDoc, href[1:]) == nil {
+			t.Errorf("link to %q, which does not exist", href)
+		}
+		if n.FirstChild != nil && n.FirstChild.Data == "¶" && attr(n, "aria-label") == "" {
+			t.Errorf("permalink to %q has no label", href)
+		}
+	})
+}
+
+func findByID(htmlDoc *html.Node, id string) *html.Node {
+	var found *html.Node
+	walk(htmlDoc, func(n *html.Node) {
+		if found == nil && attr(n, "id") == id {
+			found = n
+		}
+	})
+	return found
+}
+
 func walk(n *html.Node, f func(*html.Node)) {
 	f(n)
 	for c := n.FirstChild; c != nil; c = c.NextSibling {
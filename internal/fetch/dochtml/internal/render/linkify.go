@@ -52,7 +52,10 @@ var (
 	badAnchorRx = regexp.MustCompile(`[^a-zA-Z0-9]`)
 )
 
-func (r *Renderer) declHTML(doc string, decl ast.Decl) (out struct{ Doc, Decl template.HTML }) {
+// declHTML formats doc and decl as HTML. Headings in doc are rendered as
+// elements of the given heading level, so that they nest under the heading of
+// the section that doc appears in.
+func (r *Renderer) declHTML(doc string, decl ast.Decl, headingLevel int) (out struct{ Doc, Decl template.HTML }) {
 	dids := newDeclIDs(decl)
 	idr := &identifierResolver{r.pids, dids, r.packageURL}
 	if doc != "" {
@@ -75,12 +78,12 @@ func (r *Renderer) declHTML(doc string, decl ast.Decl) (out struct{ Doc, Decl te
 				b.WriteString("</pre>\n")
 			case *heading:
 				id := badAnchorRx.ReplaceAllString(blk.title, "_")
-				b.WriteString(`<h3 id="hdr-` + id + `">`)
-				b.WriteString(template.HTMLEscapeString(blk.title))
+				title := template.HTMLEscapeString(blk.title)
+				fmt.Fprintf(&b, `<h%d id="hdr-%s">%s`, headingLevel, id, title)
 				if !r.disablePermalinks {
-					b.WriteString(` <a href="#hdr-` + id + `">¶</a>`)
+					fmt.Fprintf(&b, ` <a href="#hdr-%s" aria-label="Go to %s">¶</a>`, id, title)
 				}
-				b.WriteString("</h3>\n")
+				fmt.Fprintf(&b, "</h%d>\n", headingLevel)
 			}
 		}
 		out.Doc = template.HTML(b.String())
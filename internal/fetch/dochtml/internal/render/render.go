@@ -128,13 +128,14 @@ func (r *Renderer) Synopsis(n ast.Node) string {
 //
 // DocHTML is intended for documentation for the package and examples.
 func (r *Renderer) DocHTML(doc string) template.HTML {
-	return r.declHTML(doc, nil).Doc
+	return r.declHTML(doc, nil, 3).Doc
 }
 
 // DeclHTML formats the doc and decl and returns a tuple of
 // strings corresponding to each input argument.
 //
-// This formats documentation HTML according to the same rules as DocHTML.
+// This formats documentation HTML according to the same rules as DocHTML,
+// except that headings are <hN> elements, where N is headingLevel.
 //
 // This formats declaration HTML with:
 //	<pre>                       element wrapping the entire declaration
@@ -143,10 +144,10 @@ func (r *Renderer) DocHTML(doc string) template.HTML {
 //	<a href="XXX">              elements for URL hyperlinks
 //
 // DeclHTML is intended for top-level package declarations.
-func (r *Renderer) DeclHTML(doc string, decl ast.Decl) (out struct{ Doc, Decl template.HTML }) {
+func (r *Renderer) DeclHTML(doc string, decl ast.Decl, headingLevel int) (out struct{ Doc, Decl template.HTML }) {
 	// This returns an anonymous struct instead of multiple return values since
 	// the template package only allows single return values.
-	return r.declHTML(doc, decl)
+	return r.declHTML(doc, decl, headingLevel)
 }
 
 // CodeHTML formats example code. If the code is a single block statement,
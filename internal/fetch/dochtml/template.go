@@ -35,7 +35,8 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 	},
 ).Parse(`{{- "" -}}
 {{- if or .Doc .Consts .Vars .Funcs .Types .Examples.List -}}
-<nav class="Documentation-nav">
+<a class="Documentation-skipLink" href="#pkg-content">Skip to documentation</a>
+<nav class="Documentation-nav" aria-label="Documentation contents">
 	<ul class="Documentation-toc">{{"\n" -}}
 	{{- if or .Doc (index .Examples.Map "") -}}
 		<li class="Documentation-tocItem Documentation-tocItem--selected">
@@ -112,19 +113,19 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 </nav>
 {{- end -}}
 
-<div> {{/* Documentation content container */}}
+<div class="Documentation-content" id="pkg-content"> {{/* Documentation content container */}}
 
 {{- if or .Doc (index .Examples.Map "") -}}
-	<section class="Documentation-overview">
-		<h2 id="pkg-overview" class="Documentation-overviewHeader">Overview <a href="#pkg-overview">¶</a></h2>{{"\n\n" -}}
+	<section class="Documentation-overview" aria-labelledby="pkg-overview">
+		<h2 id="pkg-overview" class="Documentation-overviewHeader">Overview <a href="#pkg-overview" aria-label="Go to Overview">¶</a></h2>{{"\n\n" -}}
 		{{render_doc .Doc}}{{"\n" -}}
 		{{- template "example" (index .Examples.Map "") -}}
 	</section>
 {{- end -}}
 
 {{- if or .Consts .Vars .Funcs .Types .Examples.List -}}
-	<section class="Documentation-index">
-		<h2 id="pkg-index" class="Documentation-indexHeader">Index <a href="#pkg-index">¶</a></h2>{{"\n\n" -}}
+	<section class="Documentation-index" aria-labelledby="pkg-index">
+		<h2 id="pkg-index" class="Documentation-indexHeader">Index <a href="#pkg-index" aria-label="Go to Index">¶</a></h2>{{"\n\n" -}}
 		<ul class="Documentation-indexList">{{"\n" -}}
 			{{- if .Consts -}}<li class="Documentation-indexConstants"><a href="#pkg-constants">Constants</a></li>{{"\n"}}{{- end -}}
 			{{- if .Vars -}}<li class="Documentation-indexVariables"><a href="#pkg-variables">Variables</a></li>{{"\n"}}{{- end -}}
@@ -157,8 +158,8 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 	</section>
 
 	{{- if .Examples.List -}}
-	<section class="Documentation-examples">
-		<h3 id="pkg-examples" class="Documentation-examplesHeader">Examples <a href="#pkg-examples">¶</a></h3>{{"\n" -}}
+	<section class="Documentation-examples" aria-labelledby="pkg-examples">
+		<h3 id="pkg-examples" class="Documentation-examplesHeader">Examples <a href="#pkg-examples" aria-label="Go to Examples">¶</a></h3>{{"\n" -}}
 		<ul class="Documentation-examplesList">{{"\n" -}}
 			{{- range .Examples.List -}}
 				<li><a href="#{{.ID}}">{{or .ParentID "Package"}}{{with .Suffix}} ({{.}}){{end}}</a></li>{{"\n" -}}
@@ -168,10 +169,10 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 	{{- end -}}
 
 	{{- if .Consts -}}
-	<section class="Documentation-constants">
-		<h3 id="pkg-constants" class="Documentation-constantsHeader">Constants <a href="#pkg-constants">¶</a></h3>{{"\n"}}
+	<section class="Documentation-constants" aria-labelledby="pkg-constants">
+		<h2 id="pkg-constants" class="Documentation-constantsHeader">Constants <a href="#pkg-constants" aria-label="Go to Constants">¶</a></h2>{{"\n"}}
 		{{- range .Consts -}}
-			{{- $out := render_decl .Doc .Decl -}}
+			{{- $out := render_decl .Doc .Decl 3 -}}
 			{{- $out.Decl -}}
 			{{- $out.Doc -}}
 			{{"\n"}}
@@ -180,10 +181,10 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 	{{- end -}}
 
 	{{- if .Vars -}}
-	<section class="Documentation-variables">
-		<h3 id="pkg-variables" class="Documentation-variablesHeader">Variables <a href="#pkg-variables">¶</a></h3>{{"\n"}}
+	<section class="Documentation-variables" aria-labelledby="pkg-variables">
+		<h2 id="pkg-variables" class="Documentation-variablesHeader">Variables <a href="#pkg-variables" aria-label="Go to Variables">¶</a></h2>{{"\n"}}
 		{{- range .Vars -}}
-			{{- $out := render_decl .Doc .Decl -}}
+			{{- $out := render_decl .Doc .Decl 3 -}}
 			{{- $out.Decl -}}
 			{{- $out.Doc -}}
 			{{"\n"}}
@@ -192,11 +193,12 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 	{{- end -}}
 
 	{{- if .Funcs -}}
-	<section class="Documentation-functions">
+	<section class="Documentation-functions" aria-labelledby="pkg-functions">
+		<h2 id="pkg-functions" class="Documentation-functionsHeader">Functions <a href="#pkg-functions" aria-label="Go to Functions">¶</a></h2>{{"\n"}}
 		{{- range .Funcs -}}
 		<div class="Documentation-function">
-			<h3 id="{{.Name}}" data-kind="function" class="Documentation-functionHeader">func {{source_link .Name .Decl}} <a href="#{{.Name}}">¶</a></h3>{{"\n"}}
-			{{- $out := render_decl .Doc .Decl -}}
+			<h3 id="{{.Name}}" data-kind="function" class="Documentation-functionHeader">func {{source_link .Name .Decl}} <a href="#{{.Name}}" aria-label="Go to {{.Name}}">¶</a></h3>{{"\n"}}
+			{{- $out := render_decl .Doc .Decl 4 -}}
 			{{- $out.Decl -}}
 			{{- $out.Doc -}}
 			{{"\n"}}
@@ -207,12 +209,13 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 	{{- end -}}
 
 	{{- if .Types -}}
-	<section class="Documentation-types">
+	<section class="Documentation-types" aria-labelledby="pkg-types">
+		<h2 id="pkg-types" class="Documentation-typesHeader">Types <a href="#pkg-types" aria-label="Go to Types">¶</a></h2>{{"\n"}}
 		{{- range .Types -}}
 		<div class="Documentation-type">
 			{{- $tname := .Name -}}
-			<h3 id="{{.Name}}" data-kind="type" class="Documentation-typeHeader">type {{source_link .Name .Decl}} <a href="#{{.Name}}">¶</a></h3>{{"\n"}}
-			{{- $out := render_decl .Doc .Decl -}}
+			<h3 id="{{.Name}}" data-kind="type" class="Documentation-typeHeader">type {{source_link .Name .Decl}} <a href="#{{.Name}}" aria-label="Go to {{.Name}}">¶</a></h3>{{"\n"}}
+			{{- $out := render_decl .Doc .Decl 4 -}}
 			{{- $out.Decl -}}
 			{{- $out.Doc -}}
 			{{"\n"}}
@@ -220,7 +223,7 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 
 			{{- range .Consts -}}
 			<div class="Documentation-typeConstant">
-				{{- $out := render_decl .Doc .Decl -}}
+				{{- $out := render_decl .Doc .Decl 4 -}}
 				{{- $out.Decl -}}
 				{{- $out.Doc -}}
 				{{"\n"}}
@@ -229,7 +232,7 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 
 			{{- range .Vars -}}
 			<div class="Documentation-typeVariable">
-				{{- $out := render_decl .Doc .Decl -}}
+				{{- $out := render_decl .Doc .Decl 4 -}}
 				{{- $out.Decl -}}
 				{{- $out.Doc -}}
 				{{"\n"}}
@@ -238,8 +241,8 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 
 			{{- range .Funcs -}}
 			<div class="Documentation-typeFunc">
-				<h3 id="{{.Name}}" data-kind="function" class="Documentation-typeFuncHeader">func {{source_link .Name .Decl}} <a href="#{{.Name}}">¶</a></h3>{{"\n"}}
-				{{- $out := render_decl .Doc .Decl -}}
+				<h4 id="{{.Name}}" data-kind="function" class="Documentation-typeFuncHeader">func {{source_link .Name .Decl}} <a href="#{{.Name}}" aria-label="Go to {{.Name}}">¶</a></h4>{{"\n"}}
+				{{- $out := render_decl .Doc .Decl 5 -}}
 				{{- $out.Decl -}}
 				{{- $out.Doc -}}
 				{{"\n"}}
@@ -250,8 +253,8 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 			{{- range .Methods -}}
 			<div class="Documentation-typeMethod">
 				{{- $name := (printf "%s.%s" $tname .Name) -}}
-				<h3 id="{{$name}}" data-kind="method" class="Documentation-typeMethodHeader">func ({{.Recv}}) {{source_link .Name .Decl}} <a href="#{{$name}}">¶</a></h3>{{"\n"}}
-				{{- $out := render_decl .Doc .Decl -}}
+				<h4 id="{{$name}}" data-kind="method" class="Documentation-typeMethodHeader">func ({{.Recv}}) {{source_link .Name .Decl}} <a href="#{{$name}}" aria-label="Go to {{$name}}">¶</a></h4>{{"\n"}}
+				{{- $out := render_decl .Doc .Decl 5 -}}
 				{{- $out.Decl -}}
 				{{- $out.Doc -}}
 				{{"\n"}}
@@ -265,13 +268,13 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 {{- end -}}
 
 {{- if .Notes -}}
-<section class="Documentation-notes">
+<section class="Documentation-notes" aria-label="Notes">
 	{{- range $marker, $content := .Notes -}}
 	<div class="Documentation-note">
-		<h2 id="pkg-note-{{$marker}}" class="Documentation-noteHeader">{{$marker}}s <a href="#pkg-note-{{$marker}}">¶</a></h2>
-		<ul class="Documentation-noteList" style="padding-left: 20px; list-style: initial;">{{"\n" -}}
+		<h2 id="pkg-note-{{$marker}}" class="Documentation-noteHeader">{{$marker}}s <a href="#pkg-note-{{$marker}}" aria-label="Go to {{$marker}}s">¶</a></h2>
+		<ul class="Documentation-noteList">{{"\n" -}}
 		{{- range $v := $content -}}
-			<li style="margin: 6px 0 6px 0;">{{render_doc $v.Body}}</li>
+			<li>{{render_doc $v.Body}}</li>
 		{{- end -}}
 		</ul>{{"\n" -}}
 	</div>
@@ -284,7 +287,7 @@ var htmlPackage = template.Must(template.New("package").Funcs(
 {{- define "example" -}}
 	{{- range . -}}
 	<details id="{{.ID}}" class="Documentation-exampleDetails">{{"\n" -}}
-		<summary class="Documentation-exampleDetailsHeader">Example{{with .Suffix}} ({{.}}){{end}} <a href="#{{.ID}}">¶</a></summary>{{"\n" -}}
+		<summary class="Documentation-exampleDetailsHeader">Example{{with .Suffix}} ({{.}}){{end}} <a href="#{{.ID}}" aria-label="Go to {{or .ParentID "package"}} example{{with .Suffix}} ({{.}}){{end}}">¶</a></summary>{{"\n" -}}
 		<div class="Documentation-exampleDetailsBody">{{"\n" -}}
 			{{- if .Doc -}}{{render_doc .Doc}}{{"\n" -}}{{- end -}}
 			{{- with play_url .Example -}}
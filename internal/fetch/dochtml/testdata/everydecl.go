@@ -4,7 +4,11 @@
 
 // Package everydecl has every form of declaration known to dochtml.
 // It is designed to test that the generated HTML has the right id and data-kind
-// attributes.
+// attributes, and the right structure.
+//
+// Headings
+//
+// A heading in the package comment is part of the overview.
 package everydecl
 
 // const
@@ -29,6 +33,10 @@ var VT T
 func TF() T { return T(0) }
 
 // method
+//
+// Details
+//
+// A heading in the comment of a method is part of the method.
 func (T) M() {}
 
 type S1 struct {
@@ -48,3 +56,5 @@ type I2 interface {
 	I1 // embedded interface; should not have an id
 	M2()
 }
+
+// BUG(someone): notes are rendered too.
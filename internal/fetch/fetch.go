@@ -25,13 +25,16 @@ import (
 	"runtime/debug"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"go.opencensus.io/trace"
 	"golang.org/x/mod/modfile"
 	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/fetch/dochtml"
 	"golang.org/x/pkgsite/internal/fetch/internal/doc"
@@ -57,6 +60,19 @@ type FetchResult struct {
 	Error                error
 	Module               *internal.Module
 	PackageVersionStates []*internal.PackageVersionState
+	// ZipHash is the go.sum hash of the module zip. It is empty for the
+	// standard library.
+	ZipHash string
+	// GoModHash is the go.sum hash of the module's go.mod file. It is empty
+	// for the standard library.
+	GoModHash string
+	// Unchanged reports whether processing was skipped because the module
+	// zip had the hash passed to FetchModuleIfChanged. If so, Module and
+	// PackageVersionStates are nil.
+	Unchanged bool
+	// Proxy is the URL of the module proxy that served the version. It is
+	// empty for the standard library.
+	Proxy string
 }
 
 // FetchModule queries the proxy or the Go repo for the requested module
@@ -64,7 +80,14 @@ type FetchResult struct {
 // *internal.Module and related information.
 //
 // Even if err is non-nil, the result may contain useful information, like the go.mod path.
-func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client) (fr *FetchResult) {
+func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client) *FetchResult {
+	return FetchModuleIfChanged(ctx, modulePath, requestedVersion, proxyClient, sourceClient, "")
+}
+
+// FetchModuleIfChanged is like FetchModule, but if zipHash is non-empty and
+// the module zip has that hash, it returns without processing the zip, and
+// the result has Unchanged set.
+func FetchModuleIfChanged(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, zipHash string) (fr *FetchResult) {
 	fr = &FetchResult{
 		ModulePath:       modulePath,
 		RequestedVersion: requestedVersion,
@@ -83,15 +106,15 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 	var (
 		commitTime time.Time
 		zipReader  *zip.Reader
+		goModBytes []byte
 		err        error
 	)
 	if modulePath == stdlib.ModulePath {
-		zipReader, commitTime, err = stdlib.Zip(requestedVersion)
+		zipReader, fr.ResolvedVersion, commitTime, err = stdlib.Zip(requestedVersion)
 		if err != nil {
 			fr.Error = err
 			return fr
 		}
-		fr.ResolvedVersion = requestedVersion
 	} else {
 		info, err := proxyClient.GetInfo(ctx, modulePath, requestedVersion)
 		if err != nil {
@@ -99,9 +122,10 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 			return fr
 		}
 		fr.ResolvedVersion = info.Version
+		fr.Proxy = info.Proxy
 		commitTime = info.Time
 
-		goModBytes, err := proxyClient.GetMod(ctx, modulePath, fr.ResolvedVersion)
+		goModBytes, err = proxyClient.GetMod(ctx, modulePath, fr.ResolvedVersion)
 		if err != nil {
 			fr.Error = err
 			return fr
@@ -112,6 +136,11 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 			return fr
 		}
 		fr.GoModPath = goModPath
+		fr.GoModHash, err = hashGoMod(goModBytes)
+		if err != nil {
+			fr.Error = err
+			return fr
+		}
 		if goModPath != modulePath {
 			// The module path in the go.mod file doesn't match the path of the
 			// zip file. Don't insert the module. Store an AlternativeModule
@@ -124,6 +153,16 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 			fr.Error = err
 			return fr
 		}
+		fr.ZipHash, err = hashZip(zipReader)
+		if err != nil {
+			fr.Error = fmt.Errorf("%v: %w", err, derrors.BadModule)
+			return fr
+		}
+		if zipHash != "" && fr.ZipHash == zipHash {
+			log.Infof(ctx, "%s@%s: zip hash %s unchanged, skipping processing", modulePath, fr.ResolvedVersion, zipHash)
+			fr.Unchanged = true
+			return fr
+		}
 	}
 	versionType, err := version.ParseType(fr.ResolvedVersion)
 	if err != nil {
@@ -136,6 +175,7 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 		return fr
 	}
 	fr.Module = mod
+	fr.Module.Requirements = goModRequirements(goModBytes)
 	fr.PackageVersionStates = pvs
 	if modulePath == stdlib.ModulePath {
 		fr.Module.HasGoMod = true
@@ -148,12 +188,60 @@ func FetchModule(ctx context.Context, modulePath, requestedVersion string, proxy
 	return fr
 }
 
+// goModRequirements returns the requirements of the go.mod file with the
+// given contents, ordered by module path. If a module is required more than
+// once, only its highest version is kept, as the go command does. A go.mod
+// file that cannot be parsed has no requirements; that does not keep the
+// module from being processed.
+func goModRequirements(goModBytes []byte) []*internal.ModuleRequirement {
+	if len(goModBytes) == 0 {
+		return nil
+	}
+	f, err := modfile.ParseLax("go.mod", goModBytes, nil)
+	if err != nil {
+		return nil
+	}
+	versions := map[string]string{}
+	for _, r := range f.Require {
+		if v, ok := versions[r.Mod.Path]; !ok || semver.Compare(r.Mod.Version, v) > 0 {
+			versions[r.Mod.Path] = r.Mod.Version
+		}
+	}
+	var reqs []*internal.ModuleRequirement
+	for p, v := range versions {
+		reqs = append(reqs, &internal.ModuleRequirement{ModulePath: p, Version: v})
+	}
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].ModulePath < reqs[j].ModulePath })
+	return reqs
+}
+
+// hashZip returns the go.sum hash of the files in zipReader.
+func hashZip(zipReader *zip.Reader) (string, error) {
+	var files []string
+	zfiles := map[string]*zip.File{}
+	for _, f := range zipReader.File {
+		files = append(files, f.Name)
+		zfiles[f.Name] = f
+	}
+	return dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		return zfiles[name].Open()
+	})
+}
+
+// hashGoMod returns the go.sum hash of the go.mod file with the given
+// contents.
+func hashGoMod(goModBytes []byte) (string, error) {
+	return dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(goModBytes)), nil
+	})
+}
+
 // processZipFile extracts information from the module version zip.
 func processZipFile(ctx context.Context, modulePath string, versionType version.Type, resolvedVersion string, commitTime time.Time, zipReader *zip.Reader, sourceClient *source.Client) (_ *internal.Module, _ []*internal.PackageVersionState, err error) {
 	defer derrors.Wrap(&err, "processZipFile(%q, %q)", modulePath, resolvedVersion)
 
-	ctx, span := trace.StartSpan(ctx, "fetch.processZipFile")
-	defer span.End()
+	ctx, span := dtrace.StartSpan(ctx, "fetch.processZipFile", dtrace.ModulePath(modulePath), dtrace.Version(resolvedVersion))
+	defer dtrace.End(span, &err)
 
 	sourceInfo, err := source.ModuleInfo(ctx, sourceClient, modulePath, resolvedVersion)
 	if err != nil {
@@ -202,6 +290,7 @@ func processZipFile(ctx context.Context, modulePath string, versionType version.
 		},
 		LegacyPackages: packages,
 		Licenses:       allLicenses,
+		Notices:        d.Notices(),
 		Directories:    moduleDirectories(modulePath, packages, readmes, d),
 	}, packageVersionStates, nil
 }
@@ -259,7 +348,7 @@ func isReadme(file string) bool {
 // * the particular set of build contexts we consider (goEnvs)
 // * whether the import path is valid.
 func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion string, r *zip.Reader, d *licenses.Detector, sourceInfo *source.Info) (_ []*internal.LegacyPackage, _ []*internal.PackageVersionState, err error) {
-	ctx, span := trace.StartSpan(ctx, "fetch.extractPackagesFromZip")
+	ctx, span := dtrace.StartSpan(ctx, "fetch.extractPackagesFromZip")
 	defer span.End()
 	defer func() {
 		if e := recover(); e != nil {
@@ -371,19 +460,32 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 	// If we got this far, the file metadata was okay.
 	// Start reading the file contents now to extract information
 	// about Go packages.
-	var pkgs []*internal.LegacyPackage
-	for innerPath, goFiles := range dirs {
+	//
+	// Packages are loaded concurrently, but the results are processed in
+	// order of their directory paths, so that the output is deterministic.
+	var innerPaths []string
+	for innerPath := range dirs {
 		if incompleteDirs[innerPath] {
 			// Something went wrong when processing this directory, so we skip.
 			log.Infof(ctx, "Skipping %q because it is incomplete", innerPath)
 			continue
 		}
+		innerPaths = append(innerPaths, innerPath)
+	}
+	sort.Strings(innerPaths)
+	loaded, err := loadPackages(ctx, innerPaths, dirs, modulePath, sourceInfo)
+	if err != nil {
+		return nil, nil, err
+	}
 
+	var pkgs []*internal.LegacyPackage
+	for i, innerPath := range innerPaths {
+		goFiles := dirs[innerPath]
 		var (
 			status error
 			errMsg string
 		)
-		pkg, err := loadPackage(ctx, goFiles, innerPath, modulePath, sourceInfo)
+		pkg, err := loaded[i].pkg, loaded[i].err
 		if bpe := (*BadPackageError)(nil); errors.As(err, &bpe) {
 			incompleteDirs[innerPath] = true
 			status = derrors.PackageInvalidContents
@@ -433,6 +535,50 @@ func extractPackagesFromZip(ctx context.Context, modulePath, resolvedVersion str
 	return pkgs, packageVersionStates, nil
 }
 
+// loadResult holds the result of loading a single package.
+type loadResult struct {
+	pkg *internal.LegacyPackage
+	err error
+}
+
+// loadPackages calls loadPackage for the package in each of innerPaths, using
+// at most PackageParallelism goroutines. The i'th result corresponds to
+// innerPaths[i]. If ctx is done before every package has started loading,
+// loadPackages waits for the loads in progress and returns ctx.Err().
+func loadPackages(ctx context.Context, innerPaths []string, dirs map[string][]*zip.File, modulePath string, sourceInfo *source.Info) ([]loadResult, error) {
+	results := make([]loadResult, len(innerPaths))
+	n := PackageParallelism
+	if n < 1 {
+		n = 1
+	}
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, innerPath := range innerPaths {
+		i, innerPath := i, innerPath
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				// Panics in this goroutine would not be caught by the recover
+				// in extractPackagesFromZip, so convert them to errors here.
+				if e := recover(); e != nil {
+					results[i].err = fmt.Errorf("internal panic: %v\n\n%s", e, debug.Stack())
+				}
+				<-sem
+				wg.Done()
+			}()
+			results[i].pkg, results[i].err = loadPackage(ctx, dirs[innerPath], innerPath, modulePath, sourceInfo)
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
 // ignoredByGoTool reports whether the given import path corresponds
 // to a directory that would be ignored by the go tool.
 //
@@ -499,7 +645,7 @@ var goEnvs = []struct{ GOOS, GOARCH string }{
 // If the package is fine except that its documentation is too large, loadPackage
 // returns both a package and a non-nil error with dochtml.ErrTooLarge in its chain.
 func loadPackage(ctx context.Context, zipGoFiles []*zip.File, innerPath, modulePath string, sourceInfo *source.Info) (*internal.LegacyPackage, error) {
-	ctx, span := trace.StartSpan(ctx, "fetch.loadPackage")
+	ctx, span := dtrace.StartSpan(ctx, "fetch.loadPackage")
 	defer span.End()
 	for _, env := range goEnvs {
 		pkg, err := loadPackageWithBuildContext(ctx, env.GOOS, env.GOARCH, zipGoFiles, innerPath, modulePath, sourceInfo)
@@ -547,6 +693,7 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 		fset            = token.NewFileSet()
 		goFiles         = make(map[string]*ast.File)
 		allGoFiles      []*ast.File
+		goFilesSize     int // Total size of the files in goFiles.
 		packageName     string
 		packageNameFile string // Name of file where packageName came from.
 	)
@@ -563,6 +710,7 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 			continue
 		}
 		goFiles[name] = pf
+		goFilesSize += len(b)
 		if len(goFiles) == 1 {
 			packageName = pf.Name.Name
 			packageNameFile = name
@@ -590,6 +738,43 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 		noTypeAssociation = true
 	}
 
+	// Run optional analyzers over the source. This must happen before
+	// computing the documentation, which edits the syntax trees.
+	var findings []*internal.Finding
+	if experiment.IsActive(ctx, internal.ExperimentRunAnalyzers) {
+		in := &AnalysisInput{
+			ImportPath: path.Join(modulePath, innerPath),
+			GOOS:       goos,
+			GOARCH:     goarch,
+			Fset:       fset,
+		}
+		if modulePath == stdlib.ModulePath {
+			in.ImportPath = innerPath
+		}
+		var names []string
+		for name := range goFiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			in.Files = append(in.Files, goFiles[name])
+		}
+		findings = analyzePackage(ctx, in, goFilesSize)
+	}
+
+	// Record the symbols used from imports. This must also happen before
+	// computing the documentation, which removes function bodies.
+	var fileNames []string
+	for name := range goFiles {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+	var sortedGoFiles []*ast.File
+	for _, name := range fileNames {
+		sortedGoFiles = append(sortedGoFiles, goFiles[name])
+	}
+	imported := importedSymbols(sortedGoFiles)
+
 	// Compute package documentation.
 	importPath := path.Join(modulePath, innerPath)
 	var m doc.Mode
@@ -671,14 +856,18 @@ func loadPackageWithBuildContext(ctx context.Context, goos, goarch string, zipGo
 		importPath = innerPath
 	}
 	return &internal.LegacyPackage{
-		Path:              importPath,
-		Name:              packageName,
-		Synopsis:          doc.Synopsis(d.Doc),
-		V1Path:            v1path,
-		Imports:           d.Imports,
-		DocumentationHTML: docHTML,
-		GOOS:              goos,
-		GOARCH:            goarch,
+		Path:                  importPath,
+		Name:                  packageName,
+		Synopsis:              doc.Synopsis(d.Doc),
+		V1Path:                v1path,
+		Imports:               d.Imports,
+		DocumentationHTML:     docHTML,
+		DocumentationCoverage: documentationCoverage(d),
+		GOOS:                  goos,
+		GOARCH:                goarch,
+		Findings:              findings,
+		Symbols:               exportedSymbols(d),
+		ImportedSymbols:       imported,
 	}, err
 }
 
@@ -99,9 +99,10 @@ func TestFetchModule(t *testing.T) {
 			sortFetchResult(fr)
 			sortFetchResult(got)
 			opts := []cmp.Option{
-				cmpopts.IgnoreFields(internal.LegacyPackage{}, "DocumentationHTML"),
+				cmpopts.IgnoreFields(internal.LegacyPackage{}, "DocumentationHTML", "Symbols", "ImportedSymbols"),
 				cmpopts.IgnoreFields(internal.Documentation{}, "HTML"),
 				cmpopts.IgnoreFields(internal.PackageVersionState{}, "Error"),
+				cmpopts.IgnoreFields(FetchResult{}, "ZipHash", "GoModHash", "Proxy"),
 				cmp.AllowUnexported(source.Info{}),
 				cmpopts.EquateEmpty(),
 			}
@@ -151,6 +152,81 @@ func TestFetchModule_Errors(t *testing.T) {
 	}
 }
 
+func TestFetchModule_PackageOrder(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer func(old int) { PackageParallelism = old }(PackageParallelism)
+	modulePath := moduleBadPackages.mod.ModulePath
+	proxyClient, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: modulePath,
+		Files:      moduleBadPackages.mod.Files,
+	}})
+	defer teardownProxy()
+	sourceClient := source.NewClient(sourceTimeout)
+
+	var want []*internal.PackageVersionState
+	for _, n := range []int{1, 2, 8} {
+		PackageParallelism = n
+		got := FetchModule(ctx, modulePath, "v1.0.0", proxyClient, sourceClient)
+		if got.Error != nil {
+			t.Fatalf("PackageParallelism=%d: %v", n, got.Error)
+		}
+		pkgs := got.Module.LegacyPackages
+		if !sort.SliceIsSorted(pkgs, func(i, j int) bool { return pkgs[i].Path < pkgs[j].Path }) {
+			t.Errorf("PackageParallelism=%d: packages not sorted by path", n)
+		}
+		if want == nil {
+			want = got.PackageVersionStates
+			continue
+		}
+		// The test proxy builds zips from a map, so the order of files within a
+		// package, and hence some error messages, may differ between fetches.
+		if diff := cmp.Diff(want, got.PackageVersionStates, cmpopts.IgnoreFields(internal.PackageVersionState{}, "Error")); diff != "" {
+			t.Errorf("PackageParallelism=%d: mismatch (-want +got):\n%s", n, diff)
+		}
+	}
+}
+
+func TestFetchModuleIfChanged(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	modulePath := moduleMultiPackage.mod.ModulePath
+	proxyClient, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{{
+		ModulePath: modulePath,
+		Files:      moduleMultiPackage.mod.Files,
+	}})
+	defer teardownProxy()
+	sourceClient := source.NewClient(sourceTimeout)
+
+	first := FetchModuleIfChanged(ctx, modulePath, "v1.0.0", proxyClient, sourceClient, "")
+	if first.Error != nil {
+		t.Fatal(first.Error)
+	}
+	if first.Unchanged || first.ZipHash == "" || first.Module == nil {
+		t.Fatalf("first fetch: got Unchanged=%t, ZipHash=%q, Module=%v; want a processed module with a hash",
+			first.Unchanged, first.ZipHash, first.Module)
+	}
+
+	got := FetchModuleIfChanged(ctx, modulePath, "v1.0.0", proxyClient, sourceClient, first.ZipHash)
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	if !got.Unchanged || got.Module != nil || got.ZipHash != first.ZipHash || got.Status != http.StatusOK {
+		t.Errorf("same hash: got Unchanged=%t, Module=%v, ZipHash=%q, Status=%d; want unchanged result with hash %q",
+			got.Unchanged, got.Module, got.ZipHash, got.Status, first.ZipHash)
+	}
+
+	got = FetchModuleIfChanged(ctx, modulePath, "v1.0.0", proxyClient, sourceClient, "h1:other")
+	if got.Error != nil {
+		t.Fatal(got.Error)
+	}
+	if got.Unchanged || got.Module == nil {
+		t.Errorf("different hash: got Unchanged=%t, Module=%v; want a processed module", got.Unchanged, got.Module)
+	}
+}
+
 func TestExtractReadmesFromZip(t *testing.T) {
 	stdlib.UseTestData = true
 
@@ -212,7 +288,7 @@ func TestExtractReadmesFromZip(t *testing.T) {
 				err    error
 			)
 			if test.modulePath == stdlib.ModulePath {
-				reader, _, err = stdlib.Zip(test.version)
+				reader, _, _, err = stdlib.Zip(test.version)
 				if err != nil {
 					t.Fatal(err)
 				}
@@ -387,6 +463,41 @@ func mustParse(fset *token.FileSet, filename, src string) *ast.File {
 	return f
 }
 
+func TestGoModRequirements(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		goMod string
+		want  []*internal.ModuleRequirement
+	}{
+		{"empty", "", nil},
+		{"no requirements", "module example.com/m\n", nil},
+		{"unparseable", "module example.com/m\nrequire (\n", nil},
+		{
+			"requirements",
+			`module example.com/m
+
+require (
+	golang.org/x/text v0.3.0
+	example.com/b v1.2.0 // indirect
+)
+
+require example.com/b v1.1.0
+`,
+			[]*internal.ModuleRequirement{
+				{ModulePath: "example.com/b", Version: "v1.2.0"},
+				{ModulePath: "golang.org/x/text", Version: "v0.3.0"},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := goModRequirements([]byte(test.goMod))
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestFetchPlayURL(t *testing.T) {
 	ex := &doc.Example{
 		Play: mustParse(token.NewFileSet(), "src.go", `
@@ -60,6 +60,7 @@ var moduleOnePackage = &testModule{
 						Name: "foo",
 						Documentation: &internal.Documentation{
 							Synopsis: "package foo exports a helpful constant.",
+							Coverage: coverage(0),
 						},
 						Imports: []string{"net/http"},
 					},
@@ -139,6 +140,7 @@ var moduleMultiPackage = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "package bar",
 							HTML:     "Bar returns the string &#34;bar&#34;.",
+							Coverage: coverage(1),
 						},
 					},
 				},
@@ -152,6 +154,7 @@ var moduleMultiPackage = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "package foo",
 							HTML:     "FooBar returns the string &#34;foo bar&#34;.",
+							Coverage: coverage(1),
 						},
 						Imports: []string{"fmt", "github.com/my/module/bar"},
 					},
@@ -200,6 +203,7 @@ var moduleNoGoMod = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "Package p is inside a module where a go.mod file hasn't been explicitly added yet.",
 							HTML:     "const Year = 2009",
+							Coverage: coverage(1),
 						},
 					},
 				},
@@ -267,6 +271,7 @@ var moduleBadPackages = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "Package good is inside a module that has bad packages.",
 							HTML:     `const Good = <a href="/pkg/builtin#true">true</a>`,
+							Coverage: coverage(1),
 						},
 					},
 				},
@@ -336,6 +341,7 @@ var moduleBuildConstraints = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "Package cpu implements processor feature detection used by the Go standard library.",
 							HTML:     "const CacheLinePadSize = 3",
+							Coverage: coverage(0),
 						},
 					},
 				},
@@ -433,6 +439,7 @@ var moduleNonRedist = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "package bar",
 							HTML:     "Bar returns the string",
+							Coverage: coverage(1),
 						},
 					},
 				},
@@ -446,6 +453,7 @@ var moduleNonRedist = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "package baz",
 							HTML:     "Baz returns the string",
+							Coverage: coverage(1),
 						},
 					},
 				},
@@ -463,6 +471,7 @@ var moduleNonRedist = &testModule{
 						Documentation: &internal.Documentation{
 							Synopsis: "package foo",
 							HTML:     "FooBar returns the string",
+							Coverage: coverage(1),
 						},
 						Imports: []string{"fmt", "github.com/my/module/bar"},
 					},
@@ -580,7 +589,7 @@ var moduleDocTest = &testModule{
 						Name: "permalink",
 						Documentation: &internal.Documentation{
 							Synopsis: "Package permalink is for testing the heading permalink documentation rendering feature.",
-							HTML:     "<h3 id=\"hdr-This_is_a_heading\">This is a heading <a href=\"#hdr-This_is_a_heading\">¶</a></h3>",
+							HTML:     "<h3 id=\"hdr-This_is_a_heading\">This is a heading <a href=\"#hdr-This_is_a_heading\" aria-label=\"Go to This is a heading\">¶</a></h3>",
 						},
 					},
 				},
@@ -683,6 +692,7 @@ var moduleWasm = &testModule{
 							Synopsis: "Package js only works with wasm.",
 							GOOS:     "js",
 							GOARCH:   "wasm",
+							Coverage: coverage(0),
 						},
 					},
 				},
@@ -740,6 +750,7 @@ var moduleStd = &testModule{
 						Name: "builtin",
 						Documentation: &internal.Documentation{
 							Synopsis: "Package builtin provides documentation for Go's predeclared identifiers.",
+							Coverage: coverage(1),
 						},
 					},
 				},
@@ -793,6 +804,7 @@ var moduleStd = &testModule{
 						Name: "context",
 						Documentation: &internal.Documentation{
 							Synopsis: "Package context defines the Context type, which carries deadlines, cancelation signals, and other request-scoped values across API boundaries and between processes.",
+							Coverage: coverage(1),
 						},
 						Imports: []string{"errors", "fmt", "reflect", "sync", "time"},
 					},
@@ -812,6 +824,7 @@ var moduleStd = &testModule{
 						Name: "json",
 						Documentation: &internal.Documentation{
 							Synopsis: "Package json implements encoding and decoding of JSON as defined in RFC 7159.",
+							Coverage: coverage(19.0 / 24),
 						},
 						Imports: []string{
 							"bytes",
@@ -841,6 +854,7 @@ var moduleStd = &testModule{
 						Name: "errors",
 						Documentation: &internal.Documentation{
 							Synopsis: "Package errors implements functions to manipulate errors.",
+							Coverage: coverage(1),
 						},
 					},
 				},
@@ -853,6 +867,7 @@ var moduleStd = &testModule{
 						Name: "flag",
 						Documentation: &internal.Documentation{
 							Synopsis: "Package flag implements command-line flag parsing.",
+							Coverage: coverage(1),
 						},
 						Imports: []string{"errors", "fmt", "io", "os", "reflect", "sort", "strconv", "strings", "time"},
 					},
@@ -862,10 +877,20 @@ var moduleStd = &testModule{
 	},
 }
 
+// coverage returns a pointer to the documentation coverage c.
+func coverage(c float64) *float64 {
+	return &c
+}
+
 // moduleWithExamples returns a testModule that contains an example.
 // It provides the common bits for the tests for package, function,
 // type, and method examples below.
 func moduleWithExamples(path, source, test string) *testModule {
+	var cov *float64
+	if source != "" {
+		// The declarations in source have no doc comments.
+		cov = coverage(0)
+	}
 	return &testModule{
 		mod: &proxy.TestModule{
 			ModulePath: path,
@@ -906,6 +931,7 @@ package example_test
 							Documentation: &internal.Documentation{
 								Synopsis: "Package example contains examples.",
 								HTML:     testPlaygroundID,
+								Coverage: cov,
 							},
 						},
 					},
@@ -23,4 +23,8 @@ const (
 // It is a variable for testing.
 var MaxDocumentationHTML = 10 * megabyte
 
+// PackageParallelism is the maximum number of packages in a single module
+// that are loaded and rendered concurrently.
+var PackageParallelism = 4
+
 const megabyte = 1000 * 1000
@@ -0,0 +1,151 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/fetch/internal/doc"
+)
+
+// exportedSymbols returns the sorted exported API of the package documented
+// by d: its constants, variables, functions and types, and the methods and
+// exported struct fields of its types, as "Type.Method" and "Type.Field".
+// It returns an empty, non-nil slice for a package without any.
+func exportedSymbols(d *doc.Package) []string {
+	symbols := []string{}
+	values := func(vs []*doc.Value) {
+		for _, v := range vs {
+			for _, n := range v.Names {
+				if ast.IsExported(n) {
+					symbols = append(symbols, n)
+				}
+			}
+		}
+	}
+	funcs := func(prefix string, fs []*doc.Func) {
+		for _, f := range fs {
+			if ast.IsExported(f.Name) {
+				symbols = append(symbols, prefix+f.Name)
+			}
+		}
+	}
+	values(d.Consts)
+	values(d.Vars)
+	funcs("", d.Funcs)
+	for _, t := range d.Types {
+		if !ast.IsExported(t.Name) {
+			continue
+		}
+		symbols = append(symbols, t.Name)
+		values(t.Consts)
+		values(t.Vars)
+		funcs("", t.Funcs)
+		funcs(t.Name+".", t.Methods)
+		for _, spec := range t.Decl.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			for _, f := range st.Fields.List {
+				for _, n := range f.Names {
+					if n.IsExported() {
+						symbols = append(symbols, t.Name+"."+n.Name)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// importedSymbols returns the exported symbols that files refer to in each
+// package they import, by import path. Symbols are sorted, and include only
+// the package-level names that are qualified by the name of the import, like
+// Client in http.Client; methods and fields are not recorded.
+//
+// The name of an import without an explicit name is guessed from its path,
+// since the imported package is not loaded. Uses of imports whose package
+// name differs from the guess are missed.
+func importedSymbols(files []*ast.File) map[string][]string {
+	uses := map[string]map[string]bool{}
+	for _, f := range files {
+		names := map[string]string{} // import name to import path
+		for _, spec := range f.Imports {
+			p, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				continue
+			}
+			name := guessPackageName(p)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			if name == "_" || name == "." {
+				continue
+			}
+			names[name] = p
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			// A package qualifier is not resolved to a declaration in the
+			// file.
+			x, ok := sel.X.(*ast.Ident)
+			if !ok || x.Obj != nil || !sel.Sel.IsExported() {
+				return true
+			}
+			p, ok := names[x.Name]
+			if !ok {
+				return true
+			}
+			if uses[p] == nil {
+				uses[p] = map[string]bool{}
+			}
+			uses[p][sel.Sel.Name] = true
+			return true
+		})
+	}
+	symbols := map[string][]string{}
+	for p, u := range uses {
+		for s := range u {
+			symbols[p] = append(symbols[p], s)
+		}
+		sort.Strings(symbols[p])
+	}
+	return symbols
+}
+
+var (
+	majorVersionElemRegexp   = regexp.MustCompile(`^v[0-9]+$`)
+	gopkgInVersionRegexp     = regexp.MustCompile(`\.v[0-9]+$`)
+	invalidPackageNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// guessPackageName returns the likely name of the package with import path
+// importPath, by the usual conventions: the last element of the path,
+// without a major version element or suffix, or a "go-" prefix or "-go"
+// suffix.
+func guessPackageName(importPath string) string {
+	dir, name := path.Split(importPath)
+	if majorVersionElemRegexp.MatchString(name) && dir != "" {
+		name = path.Base(strings.TrimSuffix(dir, "/"))
+	}
+	name = gopkgInVersionRegexp.ReplaceAllString(name, "")
+	name = strings.TrimPrefix(name, "go-")
+	name = strings.TrimSuffix(name, "-go")
+	return invalidPackageNameRegexp.ReplaceAllString(name, "")
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fetch
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/fetch/internal/doc"
+)
+
+func TestExportedSymbols(t *testing.T) {
+	const src = `package p
+
+const A, b = 1, 2
+
+var V int
+
+func F() {}
+
+func f() {}
+
+type T struct {
+	Field int
+	field int
+	Embedded
+}
+
+type Embedded struct{}
+
+func NewT() *T { return nil }
+
+func (T) M() {}
+
+func (T) m() {}
+
+type u int
+
+func (u) M() {}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := doc.NewFromFiles(fset, []*ast.File{f}, "example.com/p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"A", "Embedded", "F", "NewT", "T", "T.Field", "T.M", "V"}
+	if diff := cmp.Diff(want, exportedSymbols(d)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestImportedSymbols(t *testing.T) {
+	const src = `package p
+
+import (
+	"net/http"
+	yaml "gopkg.in/yaml.v2"
+	"github.com/mattn/go-sqlite3"
+	"example.com/m/v2"
+	_ "example.com/side/effect"
+)
+
+func f() {
+	var c http.Client
+	_ = http.StatusOK
+	_ = yaml.Marshal
+	_ = sqlite3.ErrNo
+	_ = m.New().Method
+	_ = c.Timeout
+	_ = http.unexported
+}
+
+func g(http string) {
+	_ = http.Field
+}
+`
+	f, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"net/http":                    {"Client", "StatusOK"},
+		"gopkg.in/yaml.v2":            {"Marshal"},
+		"github.com/mattn/go-sqlite3": {"ErrNo"},
+		"example.com/m/v2":            {"New"},
+	}
+	if diff := cmp.Diff(want, importedSymbols([]*ast.File{f})); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGuessPackageName(t *testing.T) {
+	for _, test := range []struct {
+		path, want string
+	}{
+		{"net/http", "http"},
+		{"example.com/m/v2", "m"},
+		{"gopkg.in/yaml.v2", "yaml"},
+		{"github.com/mattn/go-sqlite3", "sqlite3"},
+		{"github.com/a/b-go", "b"},
+		{"github.com/a/kebab-case", "kebabcase"},
+	} {
+		if got := guessPackageName(test.path); got != test.want {
+			t.Errorf("guessPackageName(%q) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
@@ -44,6 +44,9 @@ func cleanFetchResult(fr *FetchResult, detector *licenses.Detector) *FetchResult
 		fr.Module.CommitTime = testProxyCommitTime
 	}
 
+	if notices := detector.Notices(); len(notices) > 0 {
+		fr.Module.Notices = notices
+	}
 	allLicenses := detector.AllLicenses()
 	if len(allLicenses) > 0 {
 		fr.Module.Licenses = allLicenses
@@ -67,16 +70,17 @@ func cleanFetchResult(fr *FetchResult, detector *licenses.Detector) *FetchResult
 			}
 			dir.Package.Path = dir.Path
 			fr.Module.LegacyPackages = append(fr.Module.LegacyPackages, &internal.LegacyPackage{
-				Path:              dir.Path,
-				V1Path:            dir.V1Path,
-				Licenses:          dir.Licenses,
-				Name:              dir.Package.Name,
-				Synopsis:          dir.Package.Documentation.Synopsis,
-				DocumentationHTML: dir.Package.Documentation.HTML,
-				Imports:           dir.Package.Imports,
-				GOOS:              dir.Package.Documentation.GOOS,
-				GOARCH:            dir.Package.Documentation.GOARCH,
-				IsRedistributable: dir.IsRedistributable,
+				Path:                  dir.Path,
+				V1Path:                dir.V1Path,
+				Licenses:              dir.Licenses,
+				Name:                  dir.Package.Name,
+				Synopsis:              dir.Package.Documentation.Synopsis,
+				DocumentationHTML:     dir.Package.Documentation.HTML,
+				DocumentationCoverage: dir.Package.Documentation.Coverage,
+				Imports:               dir.Package.Imports,
+				GOOS:                  dir.Package.Documentation.GOOS,
+				GOARCH:                dir.Package.Documentation.GOARCH,
+				IsRedistributable:     dir.IsRedistributable,
 			})
 			if shouldSetPVS {
 				fr.PackageVersionStates = append(
@@ -100,7 +104,7 @@ func licenseDetector(ctx context.Context, t *testing.T, modulePath, version stri
 		err       error
 	)
 	if modulePath == stdlib.ModulePath {
-		zipReader, _, err = stdlib.Zip(version)
+		zipReader, _, _, err = stdlib.Zip(version)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vet provides a fetch.Analyzer that runs checks of go vet.
+package vet
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"path"
+
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/loopclosure"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/stringintconv"
+	"golang.org/x/tools/go/analysis/passes/structtag"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+	"golang.org/x/tools/go/analysis/passes/unsafeptr"
+)
+
+// Checks are the checks of go vet that the Analyzer runs. The dependencies
+// of a module are not available during fetch, so a package is type-checked
+// without the declarations of the packages it imports. Checks that need those
+// declarations, such as printf and copylock, are left out, because they would
+// find nothing or report false positives.
+var Checks = []*analysis.Analyzer{
+	assign.Analyzer,
+	bools.Analyzer,
+	loopclosure.Analyzer,
+	nilfunc.Analyzer,
+	shift.Analyzer,
+	stringintconv.Analyzer,
+	structtag.Analyzer,
+	unreachable.Analyzer,
+	unsafeptr.Analyzer,
+}
+
+// Analyzer is a fetch.Analyzer that reports the number of diagnostics of each
+// of Checks, using the check names as categories.
+var Analyzer fetch.Analyzer = analyzer{}
+
+type analyzer struct{}
+
+func (analyzer) Name() string { return "vet" }
+
+func (analyzer) Analyze(ctx context.Context, in *fetch.AnalysisInput) (map[string]int, error) {
+	pkg, info := typeCheck(in)
+	r := &runner{
+		in:      in,
+		pkg:     pkg,
+		info:    info,
+		sizes:   types.SizesFor("gc", in.GOARCH),
+		results: map[*analysis.Analyzer]interface{}{},
+		counts:  map[string]int{},
+	}
+	for _, a := range Checks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := r.run(a); err != nil {
+			return nil, err
+		}
+	}
+	return r.counts, nil
+}
+
+// typeCheck type-checks the package of in, giving every imported package
+// other than unsafe no declarations. Type errors are ignored: there are
+// always some, for the uses of the imported packages.
+func typeCheck(in *fetch.AnalysisInput) (*types.Package, *types.Info) {
+	conf := &types.Config{
+		Importer:    importerFunc(emptyImport),
+		FakeImportC: true,
+		Sizes:       types.SizesFor("gc", in.GOARCH),
+		Error:       func(error) {},
+	}
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Scopes:     map[ast.Node]*types.Scope{},
+	}
+	// Check only returns an error that was also passed to conf.Error, and it
+	// returns a package even then.
+	pkg, _ := conf.Check(in.ImportPath, in.Fset, in.Files, info)
+	return pkg, info
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func emptyImport(importPath string) (*types.Package, error) {
+	if importPath == "unsafe" {
+		return types.Unsafe, nil
+	}
+	p := types.NewPackage(importPath, path.Base(importPath))
+	p.MarkComplete()
+	return p, nil
+}
+
+// A runner runs analyzers over one package, running each at most once, after
+// the analyzers it requires.
+type runner struct {
+	in      *fetch.AnalysisInput
+	pkg     *types.Package
+	info    *types.Info
+	sizes   types.Sizes
+	results map[*analysis.Analyzer]interface{}
+	// counts is the number of diagnostics reported by each check.
+	counts map[string]int
+}
+
+func (r *runner) run(a *analysis.Analyzer) (interface{}, error) {
+	if res, ok := r.results[a]; ok {
+		return res, nil
+	}
+	resultOf := map[*analysis.Analyzer]interface{}{}
+	for _, req := range a.Requires {
+		res, err := r.run(req)
+		if err != nil {
+			return nil, err
+		}
+		resultOf[req] = res
+	}
+	pass := &analysis.Pass{
+		Analyzer:   a,
+		Fset:       r.in.Fset,
+		Files:      r.in.Files,
+		Pkg:        r.pkg,
+		TypesInfo:  r.info,
+		TypesSizes: r.sizes,
+		ResultOf:   resultOf,
+		Report:     func(analysis.Diagnostic) { r.counts[a.Name]++ },
+		// Facts are only needed to pass information between packages, and
+		// there is only one.
+		ImportObjectFact:  func(types.Object, analysis.Fact) bool { return false },
+		ImportPackageFact: func(*types.Package, analysis.Fact) bool { return false },
+		ExportObjectFact:  func(types.Object, analysis.Fact) {},
+		ExportPackageFact: func(analysis.Fact) {},
+		AllObjectFacts:    func() []analysis.ObjectFact { return nil },
+		AllPackageFacts:   func() []analysis.PackageFact { return nil },
+	}
+	res, err := a.Run(pass)
+	if err != nil {
+		return nil, err
+	}
+	r.results[a] = res
+	return res, nil
+}
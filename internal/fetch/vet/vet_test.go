@@ -0,0 +1,92 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vet
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/fetch"
+)
+
+func TestAnalyze(t *testing.T) {
+	const src = `
+package p
+
+import (
+	"fmt"
+	"io"
+)
+
+type T struct {
+	A int ` + "`json:name`" + `
+	B io.Writer
+}
+
+func (t *T) WriteTo(w io.Writer) (int64, error) {
+	fmt.Fprintln(w, t.A)
+	t.A = t.A
+	return 0, nil
+	fmt.Println("unreachable")
+}
+
+func F(s []string) {
+	for _, x := range s {
+		go func() { fmt.Println(x) }()
+	}
+	if F == nil {
+		return
+	}
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Analyzer.Analyze(context.Background(), &fetch.AnalysisInput{
+		ImportPath: "example.com/p",
+		GOOS:       "linux",
+		GOARCH:     "amd64",
+		Fset:       fset,
+		Files:      []*ast.File{f},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{
+		"assign":      1,
+		"loopclosure": 1,
+		"nilfunc":     1,
+		"structtag":   1,
+		"unreachable": 1,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestAnalyzeCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", "package p", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Analyzer.Analyze(ctx, &fetch.AnalysisInput{
+		ImportPath: "example.com/p",
+		GOARCH:     "amd64",
+		Fset:       fset,
+		Files:      []*ast.File{f},
+	})
+	if err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
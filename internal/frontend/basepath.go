@@ -0,0 +1,35 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// siteLinkRegexp matches the attributes of HTML elements that link to paths of
+// the site, like href="/search", but not those that link to other hosts, like
+// src="//example.com/x.js".
+var siteLinkRegexp = regexp.MustCompile(`\s(?:href|src|action)="/(?:[^/]|$)`)
+
+// rebaseLinks returns page with its links to paths of the site moved under
+// basePath.
+//
+// Templates, and the HTML that pages include, like documentation and
+// breadcrumbs, link to paths of the site as if it were served at the root of
+// its host. Every page is rendered through rebaseLinks, so that no link
+// leaves the site when it is served under a base path.
+func rebaseLinks(page []byte, basePath string) []byte {
+	if basePath == "" {
+		return page
+	}
+	return siteLinkRegexp.ReplaceAllFunc(page, func(m []byte) []byte {
+		i := bytes.IndexByte(m, '"') + 1
+		var b []byte
+		b = append(b, m[:i]...)
+		b = append(b, basePath...)
+		return append(b, m[i:]...)
+	})
+}
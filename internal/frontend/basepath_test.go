@@ -0,0 +1,75 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/middleware"
+)
+
+func TestRebaseLinks(t *testing.T) {
+	const page = `<a href="/">home</a> <a href="/net/http#Handler">x</a>
+<link href="/static/css/stylesheet.css" rel="stylesheet">
+<script src="//example.com/x.js"></script> <a href="https://golang.org/">go</a>
+<a href="#section">s</a> <form action="/search"></form> <p>href="/not-an-attribute"</p>`
+	const want = `<a href="/godoc/">home</a> <a href="/godoc/net/http#Handler">x</a>
+<link href="/godoc/static/css/stylesheet.css" rel="stylesheet">
+<script src="//example.com/x.js"></script> <a href="https://golang.org/">go</a>
+<a href="#section">s</a> <form action="/godoc/search"></form> <p>href="/not-an-attribute"</p>`
+	if got := string(rebaseLinks([]byte(page), "/godoc")); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := string(rebaseLinks([]byte(page), "")); got != page {
+		t.Errorf("without a base path, got\n%s\nwant the page unchanged", got)
+	}
+}
+
+// linkRegexp matches the attributes of HTML elements that are links.
+var linkRegexp = regexp.MustCompile(`\s(?:href|src|action)="([^"]*)"`)
+
+func TestServeUnderBasePath(t *testing.T) {
+	s, err := NewServer(ServerConfig{
+		StaticPath:     "../../content/static",
+		ThirdPartyPath: "../../third_party",
+		BasePath:       "/godoc/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.Install(mux.Handle, nil)
+	handler := middleware.BasePath("/godoc/")(mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	pages := map[string]string{"error page": string(s.errorPage)}
+	for _, path := range []string{"/godoc/", "/godoc/search-help", "/godoc/license-policy"} {
+		pages[path] = get(path).Body.String()
+	}
+	for name, body := range pages {
+		if !strings.Contains(body, `data-base-path="/godoc"`) {
+			t.Errorf("%s: page does not have the base path", name)
+		}
+		for _, m := range linkRegexp.FindAllStringSubmatch(body, -1) {
+			if link := m[1]; strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//") && !strings.HasPrefix(link, "/godoc/") {
+				t.Errorf("%s: link %q is not under the base path", name, link)
+			}
+		}
+	}
+	if w := get("/godoc/static/css/stylesheet.css"); w.Code != http.StatusOK {
+		t.Errorf("stylesheet: status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := get("/godoc/mod/std"); w.Header().Get("Location") != "/godoc/std" {
+		t.Errorf("redirect: Location = %q, want %q", w.Header().Get("Location"), "/godoc/std")
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// breakingChangesAPIPath is the path of the breaking changes API endpoint.
+const breakingChangesAPIPath = "/api/v1/breaking-changes/"
+
+// breakingChangesAPIResponse is the response of the breaking changes API.
+type breakingChangesAPIResponse struct {
+	ModulePath string `json:"module_path"`
+	Version    string `json:"version"`
+	// PreviousModulePath and PreviousVersion are omitted if there is no
+	// previous release to compare against.
+	PreviousModulePath string                       `json:"previous_module_path,omitempty"`
+	PreviousVersion    string                       `json:"previous_version,omitempty"`
+	Packages           []*breakingChangesAPIPackage `json:"packages"`
+}
+
+// breakingChangesAPIPackage is a package that lost symbols.
+type breakingChangesAPIPackage struct {
+	Path              string                      `json:"path"`
+	Removed           bool                        `json:"removed,omitempty"`
+	RemovedSymbols    []*breakingChangesAPISymbol `json:"removed_symbols"`
+	Importers         int                         `json:"importers"`
+	AffectedImporters int                         `json:"affected_importers"`
+	UnknownImporters  int                         `json:"unknown_importers"`
+}
+
+// breakingChangesAPISymbol is a symbol that was removed from a package.
+type breakingChangesAPISymbol struct {
+	Name              string `json:"name"`
+	AffectedImporters int    `json:"affected_importers"`
+}
+
+// serveBreakingChangesAPI serves, for a module version, the exported symbols
+// that were removed since the previous release of the module, and the number
+// of importers in other modules that use them. The URL has the form
+//
+//	/api/v1/breaking-changes/<module-path>[?version=<version>]
+//
+// The version defaults to the latest version of the module. The first
+// version of a new major version is compared against the latest release of
+// the previous major version.
+func (s *Server) serveBreakingChangesAPI(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db, ok := s.ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	modulePath, version, err := parseModuleAPIRequest(strings.TrimPrefix(r.URL.Path, breakingChangesAPIPath), r.FormValue("version"))
+	if err != nil {
+		return &serverError{status: http.StatusBadRequest, err: err, detail: err.Error()}
+	}
+	if version == "" {
+		mi, err := db.LegacyGetModuleInfo(ctx, modulePath, internal.LatestVersion)
+		if err != nil {
+			return breakingChangesError(err)
+		}
+		version = mi.Version
+	}
+	report, err := db.GetBreakingChangeReport(ctx, modulePath, version)
+	if err != nil {
+		return breakingChangesError(err)
+	}
+	body, err := json.Marshal(newBreakingChangesAPIResponse(report))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Errorf(ctx, "Error writing breaking changes to ResponseWriter: %v", err)
+	}
+	return nil
+}
+
+// breakingChangesError returns the error to serve for err, which is a
+// NotFound error if the module version is not known.
+func breakingChangesError(err error) error {
+	if errors.Is(err, derrors.NotFound) {
+		return &serverError{status: http.StatusNotFound, err: err, detail: "module version not found"}
+	}
+	return err
+}
+
+// newBreakingChangesAPIResponse returns the breaking changes API response for
+// report.
+func newBreakingChangesAPIResponse(report *postgres.BreakingChangeReport) *breakingChangesAPIResponse {
+	resp := &breakingChangesAPIResponse{
+		ModulePath:         report.ModulePath,
+		Version:            report.Version,
+		PreviousModulePath: report.PreviousModulePath,
+		PreviousVersion:    report.PreviousVersion,
+		// Serve an empty array rather than null, like the other APIs.
+		Packages: []*breakingChangesAPIPackage{},
+	}
+	for _, p := range report.Packages {
+		ap := &breakingChangesAPIPackage{
+			Path:              p.Path,
+			Removed:           p.Removed,
+			Importers:         p.NumImporters,
+			AffectedImporters: p.NumAffectedImporters,
+			UnknownImporters:  p.NumUnknownImporters,
+		}
+		for _, rs := range p.RemovedSymbols {
+			ap.RemovedSymbols = append(ap.RemovedSymbols, &breakingChangesAPISymbol{
+				Name:              rs.Name,
+				AffectedImporters: rs.NumAffectedImporters,
+			})
+		}
+		resp.Packages = append(resp.Packages, ap)
+	}
+	return resp
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+func TestNewBreakingChangesAPIResponse(t *testing.T) {
+	report := &postgres.BreakingChangeReport{
+		ModulePath:         "a.com/m/v2",
+		Version:            "v2.0.0",
+		PreviousModulePath: "a.com/m",
+		PreviousVersion:    "v1.1.0",
+		Packages: []*postgres.PackageBreakingChanges{
+			{
+				Path:                 "a.com/m/foo",
+				RemovedSymbols:       []*postgres.RemovedSymbol{{Name: "T.M", NumAffectedImporters: 2}},
+				NumImporters:         5,
+				NumAffectedImporters: 2,
+				NumUnknownImporters:  1,
+			},
+		},
+	}
+	want := &breakingChangesAPIResponse{
+		ModulePath:         "a.com/m/v2",
+		Version:            "v2.0.0",
+		PreviousModulePath: "a.com/m",
+		PreviousVersion:    "v1.1.0",
+		Packages: []*breakingChangesAPIPackage{
+			{
+				Path:              "a.com/m/foo",
+				RemovedSymbols:    []*breakingChangesAPISymbol{{Name: "T.M", AffectedImporters: 2}},
+				Importers:         5,
+				AffectedImporters: 2,
+				UnknownImporters:  1,
+			},
+		},
+	}
+	if diff := cmp.Diff(want, newBreakingChangesAPIResponse(report)); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	got := newBreakingChangesAPIResponse(&postgres.BreakingChangeReport{ModulePath: "a.com/m", Version: "v1.0.0"})
+	if got.Packages == nil {
+		t.Error("got nil Packages, want empty")
+	}
+}
@@ -21,7 +21,7 @@ import (
 
 // handleAutoCompletion handles requests for /autocomplete?q=<input prefix>, by
 // querying redis sorted sets indexing package paths.
-func (s *Server) handleAutoCompletion(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleAutoCompletion(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	var completions []*complete.Completion
 	if s.cmplClient != nil {
@@ -29,9 +29,7 @@ func (s *Server) handleAutoCompletion(w http.ResponseWriter, r *http.Request) {
 		q := r.FormValue("q")
 		completions, err = doCompletion(r.Context(), s.cmplClient, strings.ToLower(q), 5)
 		if err != nil {
-			code := http.StatusInternalServerError
-			http.Error(w, http.StatusText(code), code)
-			return
+			return err
 		}
 	}
 	if completions == nil {
@@ -48,6 +46,7 @@ func (s *Server) handleAutoCompletion(w http.ResponseWriter, r *http.Request) {
 	if _, err := io.Copy(w, bytes.NewReader(response)); err != nil {
 		log.Errorf(ctx, "Error copying json buffer to ResponseWriter: %v", err)
 	}
+	return nil
 }
 
 // scoredCompletion wraps Completions with a relevancy score, so that they can
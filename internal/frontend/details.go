@@ -6,18 +6,22 @@ package frontend
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
 	"strings"
+	"time"
 
 	"golang.org/x/mod/module"
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
 )
 
@@ -35,6 +39,135 @@ type DetailsPage struct {
 	// PageType is either "mod", "dir", or "pkg" depending on the details
 	// handler.
 	PageType string
+
+	// RepoStatus is set if the module's repository is archived or deleted.
+	RepoStatus *RepoStatus
+
+	// ModuleRedirect is set if the module moved to a new module path.
+	ModuleRedirect *ModuleRedirect
+
+	// DependencyWeight is set on module pages if the dependency statistics
+	// of the module version are known.
+	DependencyWeight *DependencyWeight
+
+	// Vulns are the known vulnerabilities of the module version.
+	Vulns []*Vuln
+}
+
+// RepoStatus describes a repository that is archived or deleted.
+type RepoStatus struct {
+	Archived  bool // if false, the repository was deleted
+	CheckedAt string
+}
+
+// fetchRepoStatus returns the status of the repository described by info, or
+// nil if it is not known to be archived or deleted. Repository statuses are
+// only available from a postgres.DB.
+func fetchRepoStatus(ctx context.Context, ds internal.DataSource, info *source.Info) *RepoStatus {
+	db, ok := ds.(*postgres.DB)
+	if !ok || info.RepoURL() == "" {
+		return nil
+	}
+	st, err := db.GetRepoStatus(ctx, info.RepoURL())
+	if err != nil {
+		if !errors.Is(err, derrors.NotFound) {
+			// The status is informational; don't fail the page.
+			log.Errorf(ctx, "fetchRepoStatus: %v", err)
+		}
+		return nil
+	}
+	if !st.IsInactive() {
+		return nil
+	}
+	return &RepoStatus{
+		Archived:  st.Status == source.RepoArchived,
+		CheckedAt: elapsedTime(st.CheckedAt),
+	}
+}
+
+// ModuleRedirect describes the new module path of a module that moved.
+type ModuleRedirect struct {
+	ToPath string
+	URL    string
+	Reason string
+}
+
+// fetchModuleRedirect returns the approved redirect of the module at
+// modulePath, or nil if it has none. Module redirects are only available from
+// a postgres.DB.
+func fetchModuleRedirect(ctx context.Context, ds internal.DataSource, modulePath string) *ModuleRedirect {
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return nil
+	}
+	mr, err := db.GetModuleRedirect(ctx, modulePath)
+	if err != nil {
+		if !errors.Is(err, derrors.NotFound) {
+			// The redirect is informational; don't fail the page.
+			log.Errorf(ctx, "fetchModuleRedirect: %v", err)
+		}
+		return nil
+	}
+	return &ModuleRedirect{
+		ToPath: mr.ToPath,
+		URL:    "/mod/" + mr.ToPath,
+		Reason: mr.Reason,
+	}
+}
+
+// Thresholds of the number of dependencies for dependency weights.
+const (
+	lightDependencyCount    = 10
+	moderateDependencyCount = 50
+)
+
+// DependencyWeight describes how many modules a module requires, directly or
+// not.
+type DependencyWeight struct {
+	Weight   string // "Light", "Moderate" or "Heavy"
+	Count    int
+	MaxDepth int
+	HasCycle bool
+}
+
+// fetchDependencyWeight returns the dependency weight of modulePath at
+// version, or nil if it is not known. Dependency statistics are only
+// available from a postgres.DB, and only for the latest version of a module.
+func fetchDependencyWeight(ctx context.Context, ds internal.DataSource, modulePath, version string) *DependencyWeight {
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return nil
+	}
+	st, err := db.GetDependencyStats(ctx, modulePath)
+	if err != nil {
+		if !errors.Is(err, derrors.NotFound) {
+			// The weight is informational; don't fail the page.
+			log.Errorf(ctx, "fetchDependencyWeight: %v", err)
+		}
+		return nil
+	}
+	if st.Version != version {
+		return nil
+	}
+	return newDependencyWeight(st)
+}
+
+// newDependencyWeight returns the dependency weight for st.
+func newDependencyWeight(st *postgres.DependencyStats) *DependencyWeight {
+	w := &DependencyWeight{
+		Count:    st.DependencyCount,
+		MaxDepth: st.MaxDepth,
+		HasCycle: st.HasCycle,
+	}
+	switch {
+	case st.DependencyCount <= lightDependencyCount:
+		w.Weight = "Light"
+	case st.DependencyCount <= moderateDependencyCount:
+		w.Weight = "Moderate"
+	default:
+		w.Weight = "Heavy"
+	}
+	return w
 }
 
 // serveDetails handles requests for package/directory/module details pages. It
@@ -85,6 +218,35 @@ func (s *Server) serveDetails(w http.ResponseWriter, r *http.Request) (err error
 	}
 
 	ctx := r.Context()
+	defer func() {
+		log.SetRequestLabel(ctx, "datasource", datasourceResult(err))
+	}()
+	// Tag the page with the path it shows, and with its module if it is
+	// known, so that it is removed from the cache when they change.
+	cache.Tag(ctx, cache.PathTag(fullPath))
+	if modulePath != internal.UnknownModulePath && modulePath != fullPath {
+		cache.Tag(ctx, cache.PathTag(modulePath))
+	}
+	defer func() {
+		if errors.Is(err, derrors.NotFound) {
+			s.addPathSuggestions(ctx, err, fullPath, modulePath)
+		}
+	}()
+	if s.isMissingPage(ctx, r.URL.Path) {
+		pathType := "package"
+		if isModule {
+			pathType = "module"
+		}
+		return pathNotFoundError(ctx, pathType, fullPath, requestedVersion)
+	}
+	defer func() {
+		// Remember pages for paths that the datasource does not have.
+		// Other errors, such as for paths that exist at other versions,
+		// are not recorded.
+		if errors.Is(err, derrors.NotFound) {
+			s.markMissingPage(ctx, r.URL.Path, fullPath)
+		}
+	}()
 	// Validate the fullPath and requestedVersion that were parsed.
 	if err := checkPathAndVersion(ctx, s.ds, fullPath, requestedVersion); err != nil {
 		return err
@@ -104,14 +266,92 @@ func (s *Server) serveDetails(w http.ResponseWriter, r *http.Request) (err error
 				modulePath, fullPath, requestedVersion, r.URL.Path, status, responseText)
 		}()
 	}
+	// The tip of the standard library is served at the version that it
+	// resolved to. (The fetch above still needs the requested version.)
+	version := requestedVersion
+	if modulePath == stdlib.ModulePath && requestedVersion == internal.MasterVersion {
+		version, err = s.stdlibTipVersion(ctx, fullPath)
+		if err != nil {
+			return err
+		}
+	}
 	// Depending on what the request was for, return the module or package page.
 	if isModule || fullPath == stdlib.ModulePath {
-		return s.legacyServeModulePage(w, r, fullPath, requestedVersion)
+		return s.legacyServeModulePage(w, r, fullPath, version)
 	}
 	if isActiveUseDirectories(ctx) {
-		return s.servePackagePageNew(w, r, fullPath, modulePath, requestedVersion)
+		return s.servePackagePageNew(w, r, fullPath, modulePath, version)
+	}
+	return s.legacyServePackagePage(w, r, fullPath, modulePath, version)
+}
+
+// stdlibTipVersion returns the pseudo-version that the tip of the standard
+// library resolved to when it was last fetched, so that the page for fullPath
+// at tip can be served.
+func (s *Server) stdlibTipVersion(ctx context.Context, fullPath string) (string, error) {
+	db, ok := s.ds.(*postgres.DB)
+	if !ok {
+		return "", proxydatasourceNotSupportedErr()
+	}
+	vm, err := db.GetVersionMap(ctx, stdlib.ModulePath, internal.MasterVersion)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return "", pathNotFoundError(ctx, "package", fullPath, internal.MasterVersion)
+		}
+		return "", err
+	}
+	if vm.Status != http.StatusOK {
+		return "", pathNotFoundError(ctx, "package", fullPath, internal.MasterVersion)
+	}
+	return vm.ResolvedVersion, nil
+}
+
+// isMissingPage reports whether the details page at urlPath is known not to
+// exist. It reports false if that cannot be determined quickly, so that the
+// datasource is consulted.
+func (s *Server) isMissingPage(ctx context.Context, urlPath string) bool {
+	if s.missingPages == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, missingPageTimeout)
+	defer cancel()
+	missing, err := s.missingPages.IsMissing(ctx, urlPath)
+	if err != nil {
+		log.Errorf(ctx, "isMissingPage(%q): %v", urlPath, err)
+		return false
+	}
+	return missing
+}
+
+// markMissingPage records that the details page at urlPath, which would show
+// fullPath, does not exist.
+func (s *Server) markMissingPage(ctx context.Context, urlPath, fullPath string) {
+	if s.missingPages == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, missingPageTimeout)
+	defer cancel()
+	if err := s.missingPages.MarkMissing(ctx, urlPath, fullPath, missingTTL); err != nil {
+		log.Errorf(ctx, "markMissingPage(%q): %v", urlPath, err)
+	}
+}
+
+// missingPageTimeout bounds requests to the cache of missing pages, so that
+// pages are served from the datasource if the cache is unavailable.
+const missingPageTimeout = 50 * time.Millisecond
+
+// datasourceResult describes the result of looking up a details page in the
+// datasource, for the request log: "hit" if it was found, "miss" if it was
+// not, and "error" if the lookup failed.
+func datasourceResult(err error) string {
+	if err == nil {
+		return "hit"
+	}
+	var serr *serverError
+	if errors.Is(err, derrors.NotFound) || (errors.As(err, &serr) && serr.status == http.StatusNotFound) {
+		return "miss"
 	}
-	return s.legacyServePackagePage(w, r, fullPath, modulePath, requestedVersion)
+	return "error"
 }
 
 // parseDetailsURLPath parses a URL path that refers (or may refer) to something
@@ -195,7 +435,7 @@ func parseDetailsURLPath(urlPath string) (fullPath, modulePath, version string,
 // checkPathAndVersion verifies that the requested path and version are
 // acceptable. The given path may be a module or package path.
 func checkPathAndVersion(ctx context.Context, ds internal.DataSource, fullPath, requestedVersion string) error {
-	if !isSupportedVersion(ctx, requestedVersion) {
+	if !isSupportedVersion(ctx, fullPath, requestedVersion) {
 		return &serverError{
 			status: http.StatusBadRequest,
 			epage: &errorPage{
@@ -208,6 +448,11 @@ func checkPathAndVersion(ctx context.Context, ds internal.DataSource, fullPath,
 			},
 		}
 	}
+	if !internal.IsServed(fullPath) {
+		// Paths outside the prefixes this deployment serves are treated like
+		// paths that do not exist.
+		return &serverError{status: http.StatusNotFound}
+	}
 	db, ok := ds.(*postgres.DB)
 	if !ok {
 		return nil
@@ -220,14 +465,25 @@ func checkPathAndVersion(ctx context.Context, ds internal.DataSource, fullPath,
 		// Return NotFound; don't let the user know that the package was excluded.
 		return &serverError{status: http.StatusNotFound}
 	}
+	rp, err := db.GetRemovedPath(ctx, fullPath, requestedVersion)
+	if err != nil {
+		return err
+	}
+	if rp != nil {
+		return removedPathError(fullPath, rp.Reason)
+	}
 	return nil
 }
 
-// isSupportedVersion reports whether the version is supported by the frontend.
-func isSupportedVersion(ctx context.Context, version string) bool {
+// isSupportedVersion reports whether the version of fullPath is supported by
+// the frontend. The tip of the standard library is always supported.
+func isSupportedVersion(ctx context.Context, fullPath, version string) bool {
 	if version == internal.LatestVersion || semver.IsValid(version) {
 		return true
 	}
+	if version == internal.MasterVersion && stdlib.Contains(fullPath) {
+		return true
+	}
 	if isActivePathAtMaster(ctx) {
 		return version == internal.MasterVersion
 	}
@@ -257,6 +513,7 @@ func pathNotFoundError(ctx context.Context, pathType, fullPath, version string)
 	}
 	return &serverError{
 		status: http.StatusNotFound,
+		err:    derrors.NotFound,
 		epage: &errorPage{
 			messageTemplate: `<h3 class="Error-message">404 Not Found</h3>
 				 <p class="Error-message">
@@ -277,6 +534,7 @@ func pathNotFoundErrorNew(fullPath, version string) error {
 	}
 	return &serverError{
 		status: http.StatusNotFound,
+		err:    derrors.NotFound,
 		epage: &errorPage{
 			templateName: "notfound.tmpl",
 			messageTemplate: `
@@ -289,6 +547,37 @@ func pathNotFoundErrorNew(fullPath, version string) error {
 	}
 }
 
+// maxPathSuggestions is the maximum number of packages of each kind suggested
+// on the page for a path that was not found.
+const maxPathSuggestions = 5
+
+// pathSuggestionsTimeout bounds the time spent looking for suggestions, so
+// that the page for a path that was not found is never slow.
+const pathSuggestionsTimeout = 500 * time.Millisecond
+
+// addPathSuggestions adds the packages that the user may have meant to the
+// error page of err, for fullPath, which was not found. Suggestions are best
+// effort: if there is no error page, the datasource cannot suggest paths, or
+// looking for them fails, the page is left as it is.
+func (s *Server) addPathSuggestions(ctx context.Context, err error, fullPath, modulePath string) {
+	var serr *serverError
+	if !errors.As(err, &serr) || serr.epage == nil {
+		return
+	}
+	db, ok := s.ds.(*postgres.DB)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, pathSuggestionsTimeout)
+	defer cancel()
+	sugs, err := db.GetPathSuggestions(ctx, fullPath, modulePath, maxPathSuggestions)
+	if err != nil {
+		log.Errorf(ctx, "addPathSuggestions: %v", err)
+		return
+	}
+	serr.epage.Suggestions = sugs
+}
+
 // pathFoundAtLatestError returns an error page when the fullPath exists, but
 // the version that is requested does not.
 func pathFoundAtLatestError(ctx context.Context, pathType, fullPath, version string) error {
@@ -310,6 +599,22 @@ func pathFoundAtLatestError(ctx context.Context, pathType, fullPath, version str
 	}
 }
 
+// removedPathError returns an error page for a path that was removed for
+// legal reasons, with the reason it was removed.
+func removedPathError(fullPath, reason string) error {
+	return &serverError{
+		status: http.StatusUnavailableForLegalReasons,
+		err:    derrors.Removed,
+		detail: reason,
+		epage: &errorPage{
+			messageTemplate: `
+				<h3 class="Error-message">{{.Path}} is not available for legal reasons.</h3>
+				<p class="Error-message">{{.Reason}}</p>`,
+			MessageData: struct{ Path, Reason string }{fullPath, reason},
+		},
+	}
+}
+
 func proxydatasourceNotSupportedErr() error {
 	return &serverError{
 		status: http.StatusFailedDependency,
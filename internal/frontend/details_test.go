@@ -6,11 +6,18 @@ package frontend
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/stdlib"
 )
 
@@ -108,6 +115,8 @@ func TestCheckPathAndVersion(t *testing.T) {
 	}{
 		{"import/path", "v1.2.3", http.StatusOK},
 		{"import/path", "v1.2.bad", http.StatusBadRequest},
+		{"example.com/path", "master", http.StatusBadRequest},
+		{"net/http", "master", http.StatusOK},
 	}
 
 	for _, test := range tests {
@@ -126,6 +135,94 @@ func TestCheckPathAndVersion(t *testing.T) {
 	}
 }
 
+func TestCheckPathAndVersionServedPrefixes(t *testing.T) {
+	internal.SetServedPrefixes([]string{"corp.example.com/"})
+	defer internal.SetServedPrefixes(nil)
+
+	ctx := context.Background()
+	if err := checkPathAndVersion(ctx, fakeDataSource{}, "corp.example.com/team/pkg", "v1.2.3"); err != nil {
+		t.Errorf("checkPathAndVersion for a served path: got %v, want nil", err)
+	}
+	for _, path := range []string{"github.com/a/b", "net/http"} {
+		err := checkPathAndVersion(ctx, fakeDataSource{}, path, "v1.2.3")
+		if serr, ok := err.(*serverError); !ok || serr.status != http.StatusNotFound {
+			t.Errorf("checkPathAndVersion(ctx, ds, %q, %q): got %v, want status %d", path, "v1.2.3", err, http.StatusNotFound)
+		}
+	}
+}
+
 type fakeDataSource struct {
 	internal.DataSource
 }
+
+// notFoundDataSource has no packages or directories, and counts the lookups
+// for them.
+type notFoundDataSource struct {
+	internal.DataSource
+	lookups int
+}
+
+func (ds *notFoundDataSource) LegacyGetPackage(context.Context, string, string, string) (*internal.LegacyVersionedPackage, error) {
+	ds.lookups++
+	return nil, derrors.NotFound
+}
+
+func (ds *notFoundDataSource) LegacyGetDirectory(context.Context, string, string, string, internal.FieldSet) (*internal.LegacyDirectory, error) {
+	ds.lookups++
+	return nil, derrors.NotFound
+}
+
+func TestMissingPages(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	ds := &notFoundDataSource{}
+	c := cache.New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	s := &Server{ds: ds, missingPages: c}
+	serve := func() {
+		t.Helper()
+		r := httptest.NewRequest("GET", "/example.com/module/pkg", nil)
+		err := s.serveDetails(httptest.NewRecorder(), r)
+		if !errors.Is(err, derrors.NotFound) {
+			t.Fatalf("serveDetails: got %v, want NotFound", err)
+		}
+	}
+
+	serve()
+	if ds.lookups == 0 {
+		t.Fatal("first request did not consult the datasource")
+	}
+	// The page is now known to be missing.
+	n := ds.lookups
+	serve()
+	if ds.lookups != n {
+		t.Errorf("second request consulted the datasource")
+	}
+	// Processing the module forgets that.
+	c.InvalidateModule(context.Background(), "example.com/module", []string{"example.com/module/pkg"})
+	serve()
+	if ds.lookups == n {
+		t.Errorf("request after invalidation did not consult the datasource")
+	}
+}
+
+func TestNewDependencyWeight(t *testing.T) {
+	for _, test := range []struct {
+		count int
+		want  string
+	}{
+		{0, "Light"},
+		{lightDependencyCount, "Light"},
+		{lightDependencyCount + 1, "Moderate"},
+		{moderateDependencyCount, "Moderate"},
+		{moderateDependencyCount + 1, "Heavy"},
+	} {
+		got := newDependencyWeight(&postgres.DependencyStats{DependencyCount: test.count, MaxDepth: 2})
+		if got.Weight != test.want || got.Count != test.count || got.MaxDepth != 2 {
+			t.Errorf("newDependencyWeight(%d) = %+v, want weight %q", test.count, got, test.want)
+		}
+	}
+}
@@ -13,7 +13,9 @@ import (
 
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/version"
 )
 
 // DocumentationDetails contains data for the doc template.
@@ -21,6 +23,69 @@ type DocumentationDetails struct {
 	GOOS          string
 	GOARCH        string
 	Documentation template.HTML
+	// JumpIndex is embedded in the page for the "jump to identifier" dialog.
+	// It is nil if the symbols of the package are not known, in which case
+	// the dialog finds them in the documentation HTML.
+	JumpIndex *jumpIndex
+}
+
+// A jumpIndex lists the sections and symbols of the documentation of a
+// package, so that the "jump to identifier" dialog does not have to search the
+// whole documentation HTML for them, which is slow for large packages.
+type jumpIndex struct {
+	Sections []*jumpSection `json:"sections"`
+	// Symbols are the exported symbols of the package, which are also the ids
+	// of their elements in the documentation HTML.
+	Symbols []string `json:"symbols"`
+}
+
+// A jumpSection is a section of the documentation HTML.
+type jumpSection struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// docSections are the sections that the documentation HTML can have, in
+// order.
+var docSections = []*jumpSection{
+	{ID: "pkg-overview", Name: "Overview"},
+	{ID: "pkg-index", Name: "Index"},
+	{ID: "pkg-examples", Name: "Examples"},
+	{ID: "pkg-constants", Name: "Constants"},
+	{ID: "pkg-variables", Name: "Variables"},
+	{ID: "pkg-functions", Name: "Functions"},
+	{ID: "pkg-types", Name: "Types"},
+}
+
+// addJumpIndex sets the jump index of details from the exported symbols of
+// the package at pkgPath in modulePath@version, if the data source has them.
+func addJumpIndex(ctx context.Context, ds internal.DataSource, details *DocumentationDetails, pkgPath, modulePath, version string) error {
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		// The proxydatasource does not record symbols.
+		return nil
+	}
+	symbols, err := db.GetExportedSymbols(ctx, pkgPath, modulePath, version)
+	if err != nil {
+		return err
+	}
+	details.JumpIndex = newJumpIndex(string(details.Documentation), symbols)
+	return nil
+}
+
+// newJumpIndex returns the jump index of the documentation docHTML of a
+// package with the given exported symbols. It returns nil if symbols is nil.
+func newJumpIndex(docHTML string, symbols []string) *jumpIndex {
+	if symbols == nil {
+		return nil
+	}
+	index := &jumpIndex{Symbols: symbols}
+	for _, s := range docSections {
+		if strings.Contains(docHTML, `id="`+s.ID+`"`) {
+			index.Sections = append(index.Sections, s)
+		}
+	}
+	return index
 }
 
 // addDocQueryParam controls whether to use a regexp replacement to append
@@ -36,10 +101,28 @@ func fetchDocumentationDetails(pkg *internal.LegacyVersionedPackage) *Documentat
 	return &DocumentationDetails{
 		GOOS:          pkg.GOOS,
 		GOARCH:        pkg.GOARCH,
-		Documentation: template.HTML(docHTML),
+		Documentation: sanitizeHTML([]byte(docHTML)),
 	}
 }
 
+// hydrateDocumentation reads the documentation of pkg if the data source left
+// it out because it is large, so that it is only read for the doc tab.
+func hydrateDocumentation(ctx context.Context, ds internal.DataSource, pkg *internal.LegacyVersionedPackage) error {
+	if pkg.DocumentationHTML != internal.StringFieldMissing {
+		return nil
+	}
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return fmt.Errorf("documentation of %s@%s is missing", pkg.Path, pkg.Version)
+	}
+	html, err := db.LegacyGetPackageDocumentation(ctx, pkg.Path, pkg.ModulePath, pkg.Version)
+	if err != nil {
+		return err
+	}
+	pkg.DocumentationHTML = html
+	return nil
+}
+
 // fetchDocumentationDetails returnsNew a DocumentationDetails constructed from doc.
 func fetchDocumentationDetailsNew(doc *internal.Documentation) *DocumentationDetails {
 	docHTML := doc.HTML
@@ -49,7 +132,7 @@ func fetchDocumentationDetailsNew(doc *internal.Documentation) *DocumentationDet
 	return &DocumentationDetails{
 		GOOS:          doc.GOOS,
 		GOARCH:        doc.GOARCH,
-		Documentation: template.HTML(docHTML),
+		Documentation: sanitizeHTML([]byte(docHTML)),
 	}
 }
 
@@ -72,13 +155,13 @@ func hackUpDocumentation(docHTML string) string {
 // fileSource returns the original filepath in the module zip where the given
 // filePath can be found. For std, the corresponding URL in
 // go.google.source.com/go is returned.
-func fileSource(modulePath, version, filePath string) string {
+func fileSource(modulePath, v, filePath string) string {
 	if modulePath != stdlib.ModulePath {
-		return fmt.Sprintf("%s@%s/%s", modulePath, version, filePath)
+		return fmt.Sprintf("%s@%s/%s", modulePath, v, filePath)
 	}
 
 	root := strings.TrimPrefix(stdlib.GoRepoURL, "https://")
-	tag, err := stdlib.TagForVersion(version)
+	tag, err := stdlib.TagForVersion(v)
 	if err != nil {
 		// This should never happen unless there is a bug in
 		// stdlib.TagForVersion. In which case, fallback to the default
@@ -86,5 +169,9 @@ func fileSource(modulePath, version, filePath string) string {
 		log.Errorf(context.TODO(), "fileSource: %v", err)
 		return fmt.Sprintf("%s/+/refs/heads/master/%s", root, filePath)
 	}
+	if version.IsPseudo(v) {
+		// Tip has no tag, so link to the commit.
+		return fmt.Sprintf("%s/+/%s/%s", root, tag, filePath)
+	}
 	return fmt.Sprintf("%s/+/refs/tags/%s/%s", root, tag, filePath)
 }
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/google/go-cmp/cmp"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
@@ -28,6 +29,12 @@ func TestFileSource(t *testing.T) {
 			filePath:   "README.md",
 			want:       fmt.Sprintf("go.googlesource.com/go/+/refs/tags/%s/%s", "go1.13", "README.md"),
 		},
+		{
+			modulePath: stdlib.ModulePath,
+			version:    "v0.0.0-20201014123456-0123456789ab",
+			filePath:   "README.md",
+			want:       fmt.Sprintf("go.googlesource.com/go/+/%s/%s", "0123456789ab", "README.md"),
+		},
 		{
 			modulePath: stdlib.ModulePath,
 			version:    "v1.13.invalid",
@@ -76,3 +83,25 @@ func TestHackUpDocumentation(t *testing.T) {
 		}
 	}
 }
+
+func TestNewJumpIndex(t *testing.T) {
+	docHTML := `<h2 id="pkg-overview">Overview</h2><h2 id="pkg-index">Index</h2>` +
+		`<h2 id="pkg-types">Types</h2><h3 id="T">type T</h3><h4 id="T.M">func (T) M</h4>`
+	got := newJumpIndex(docHTML, []string{"T", "T.M"})
+	want := &jumpIndex{
+		Sections: []*jumpSection{
+			{ID: "pkg-overview", Name: "Overview"},
+			{ID: "pkg-index", Name: "Index"},
+			{ID: "pkg-types", Name: "Types"},
+		},
+		Symbols: []string{"T", "T.M"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want, +got):\n%s", diff)
+	}
+
+	// Without symbols, the dialog searches the documentation HTML instead.
+	if got := newJumpIndex(docHTML, nil); got != nil {
+		t.Errorf("newJumpIndex(docHTML, nil) = %+v, want nil", got)
+	}
+}
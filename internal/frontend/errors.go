@@ -0,0 +1,322 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/i18n"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// errorPage contains fields for rendering a HTTP error page.
+type errorPage struct {
+	basePage
+	templateName    string
+	messageTemplate string
+	MessageData     interface{}
+	// RequestID identifies the request in the logs. It is only shown on pages
+	// for server errors, so that users can include it when they report them.
+	RequestID string
+	// Suggestions are packages that the user may have meant, for a path
+	// that was not found.
+	Suggestions *postgres.PathSuggestions
+}
+
+// statusMessages are the message templates of the error pages for the
+// statuses that handlers most often return, for when the handler does not
+// provide its own. They are executed with the status, as in "404 Not Found".
+var statusMessages = map[int]string{
+	http.StatusBadRequest: `
+		<h3 class="Error-message">{{.}}</h3>
+		<p class="Error-message">The address of this page is not valid. Check that you entered it correctly.</p>`,
+	http.StatusNotFound: `
+		<h3 class="Error-message">{{.}}</h3>
+		<p class="Error-message">
+		  Check that you entered the address correctly, or <a href="/search-help">search</a> for what you are looking for.
+		</p>`,
+	http.StatusUnavailableForLegalReasons: `
+		<h3 class="Error-message">{{.}}</h3>
+		<p class="Error-message">This page is not available for legal reasons.</p>`,
+	http.StatusInternalServerError: `
+		<h3 class="Error-message">{{.}}</h3>
+		<p class="Error-message">Something went wrong on our end. Please try again later.</p>`,
+}
+
+// problemContentType is the media type of problem details, as described in
+// RFC 7807.
+const problemContentType = "application/problem+json"
+
+// problemDetails is the body of an error response to an API request, as
+// described in RFC 7807. Category and RequestID are extension members.
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Category is the derrors category of the error, such as "not-found".
+	Category  string `json:"category"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// apiPaths are the prefixes of the paths of endpoints that serve programs
+// rather than people. Their errors are reported as problem details.
+var apiPaths = []string{"/api/", "/autocomplete", "/fetch/", "/files/"}
+
+// wantsProblemDetails reports whether an error in response to r should be
+// reported as problem details rather than as an HTML page: if r is for an
+// API endpoint, or lists problem details in its Accept header.
+func wantsProblemDetails(r *http.Request) bool {
+	for _, p := range apiPaths {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return strings.Contains(r.Header.Get("Accept"), problemContentType)
+}
+
+// requestID returns an identifier for the request being served with ctx. It
+// is the trace ID that the RequestLog middleware adds to ctx, if there is
+// one, and a new random ID otherwise.
+func requestID(ctx context.Context) string {
+	id := log.TraceIDFromContext(ctx)
+	// The X-Cloud-Trace-Context header has the form TRACE_ID/SPAN_ID;o=TRACE_TRUE.
+	if i := strings.IndexAny(id, "/;"); i >= 0 {
+		id = id[:i]
+	}
+	if id != "" {
+		return id
+	}
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// PanicHandler returns an http.HandlerFunc that can be used in HTTP
+// middleware. It returns an error if something goes wrong pre-rendering the
+// error template.
+func (s *Server) PanicHandler() (_ http.HandlerFunc, err error) {
+	defer derrors.Wrap(&err, "PanicHandler")
+	status := http.StatusInternalServerError
+	buf, err := s.renderErrorPage(context.Background(), status, "error.tmpl", nil)
+	if err != nil {
+		return nil, err
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if wantsProblemDetails(r) {
+			serveProblem(w, r, &problemDetails{
+				Status:    status,
+				Category:  derrors.Category(derrors.Panic),
+				RequestID: requestID(r.Context()),
+			})
+			return
+		}
+		w.WriteHeader(status)
+		if _, err := io.Copy(w, bytes.NewReader(buf)); err != nil {
+			log.Errorf(r.Context(), "Error copying panic template to ResponseWriter: %v", err)
+		}
+	}, nil
+}
+
+// RateLimitedHandler returns an http.HandlerFunc that serves the page for
+// requests that are refused by the quota middleware. It returns an error if
+// something goes wrong pre-rendering the page.
+func (s *Server) RateLimitedHandler() (_ http.HandlerFunc, err error) {
+	defer derrors.Wrap(&err, "RateLimitedHandler")
+	status := http.StatusTooManyRequests
+	buf, err := s.renderErrorPage(context.Background(), status, "error.tmpl", &errorPage{
+		messageTemplate: `
+			<h3 class="Error-message">Too many requests.</h3>
+			<p class="Error-message">Please wait a moment before trying again.</p>`,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if wantsProblemDetails(r) {
+			serveProblem(w, r, &problemDetails{
+				Status:    status,
+				Detail:    "Too many requests. Please wait a moment before trying again.",
+				Category:  derrors.Category(derrors.Unknown),
+				RequestID: requestID(r.Context()),
+			})
+			return
+		}
+		w.WriteHeader(status)
+		if _, err := io.Copy(w, bytes.NewReader(buf)); err != nil {
+			log.Errorf(r.Context(), "Error copying rate limit template to ResponseWriter: %v", err)
+		}
+	}, nil
+}
+
+type serverError struct {
+	status int // HTTP status code
+	epage  *errorPage
+	err    error // wrapped error
+	// detail explains the error to clients of API endpoints. It is the detail
+	// member of the problem details, so it must not reveal anything internal.
+	detail string
+}
+
+func (s *serverError) Error() string {
+	return fmt.Sprintf("%d (%s): %v (epage=%v)", s.status, http.StatusText(s.status), s.err, s.epage)
+}
+
+func (s *serverError) Unwrap() error {
+	return s.err
+}
+
+// category returns the derrors category of the error: that of the wrapped
+// error if it is known, and that of the status otherwise.
+func (s *serverError) category() string {
+	if c := derrors.Category(s.err); c != "" && c != derrors.Category(derrors.Unknown) {
+		return c
+	}
+	return derrors.Category(derrors.FromHTTPStatus(s.status, ""))
+}
+
+func (s *Server) errorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := f(w, r); err != nil {
+			s.serveError(w, r, err)
+		}
+	}
+}
+
+func (s *Server) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	var serr *serverError
+	if !errors.As(err, &serr) {
+		serr = &serverError{status: http.StatusInternalServerError, err: err}
+	}
+	id := requestID(ctx)
+	if log.TraceIDFromContext(ctx) == "" {
+		// Log the ID, so that the request can be found from it.
+		ctx = log.NewContextWithTraceID(ctx, id)
+	}
+	if serr.status == http.StatusInternalServerError {
+		log.Error(ctx, err)
+	} else {
+		log.Infof(ctx, "returning %d (%s) for error %v", serr.status, http.StatusText(serr.status), err)
+	}
+	if wantsProblemDetails(r) {
+		serveProblem(w, r, &problemDetails{
+			Status:    serr.status,
+			Detail:    serr.detail,
+			Category:  serr.category(),
+			RequestID: id,
+		})
+		return
+	}
+	page := serr.epage
+	if serr.status >= http.StatusInternalServerError {
+		if page == nil {
+			page = &errorPage{basePage: s.newBasePage(r, "")}
+		}
+		page.RequestID = id
+	}
+	s.serveErrorPage(w, r, serr.status, page)
+}
+
+// serveProblem writes p as the response. The type of p is always
+// "about:blank", so its title is the text of its status.
+func serveProblem(w http.ResponseWriter, r *http.Request, p *problemDetails) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	body, err := json.Marshal(p)
+	if err != nil {
+		log.Errorf(r.Context(), "json.Marshal(%+v): %v", p, err)
+		http.Error(w, p.Title, p.Status)
+		return
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	if _, err := w.Write(body); err != nil {
+		log.Errorf(r.Context(), "Error writing problem details to ResponseWriter: %v", err)
+	}
+}
+
+func (s *Server) serveErrorPage(w http.ResponseWriter, r *http.Request, status int, page *errorPage) {
+	template := "error.tmpl"
+	if page == nil {
+		page = &errorPage{
+			basePage: s.newBasePage(r, ""),
+		}
+	} else if page.templateName != "" {
+		template = page.templateName
+	}
+	buf, err := s.renderErrorPage(r.Context(), status, template, page)
+	if err != nil {
+		log.Errorf(r.Context(), "s.renderErrorPage(w, %d, %v): %v", status, page, err)
+		buf = s.errorPage
+		status = http.StatusInternalServerError
+	}
+
+	w.WriteHeader(status)
+	if _, err := io.Copy(w, bytes.NewReader(buf)); err != nil {
+		log.Errorf(r.Context(), "Error copying template %q buffer to ResponseWriter: %v", template, err)
+	}
+}
+
+// renderErrorPage executes error.tmpl with the given errorPage. If the page
+// has no message, the message for the status in statusMessages is used, or
+// else just the status.
+func (s *Server) renderErrorPage(ctx context.Context, status int, templateName string, page *errorPage) ([]byte, error) {
+	statusInfo := fmt.Sprintf("%d %s", status, http.StatusText(status))
+	if page == nil {
+		page = &errorPage{}
+	}
+	if page.Nonce == "" {
+		page.Nonce = middleware.NoncePlaceholder
+	}
+	if page.messageTemplate == "" {
+		page.messageTemplate = statusMessages[status]
+		if page.messageTemplate == "" {
+			page.messageTemplate = `<h3 class="Error-message">{{.}}</h3>`
+		}
+	}
+	if page.MessageData == nil {
+		page.MessageData = statusInfo
+	}
+	if page.HTMLTitle == "" {
+		page.HTMLTitle = statusInfo
+	}
+	if templateName == "" {
+		templateName = "error.tmpl"
+	}
+
+	etmpl, err := s.findTemplate(i18n.FromContext(ctx), templateName)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := etmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	_, err = tmpl.New("message").Parse(page.messageTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	buf, err := executeTemplate(ctx, templateName, tmpl, page)
+	if err != nil {
+		return nil, err
+	}
+	return rebaseLinks(buf, s.basePath), nil
+}
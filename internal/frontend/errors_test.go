@@ -0,0 +1,138 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+func TestServeErrorProblemDetails(t *testing.T) {
+	s := &Server{}
+	for _, test := range []struct {
+		name   string
+		path   string
+		accept string
+		err    error
+		want   problemDetails
+	}{
+		{
+			name: "API endpoint",
+			path: "/fetch/example.com/foo@v1.0.0",
+			err:  &serverError{status: http.StatusNotFound, detail: "example.com/foo@v1.0.0 could not be found."},
+			want: problemDetails{
+				Type:      "about:blank",
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "example.com/foo@v1.0.0 could not be found.",
+				Category:  "not-found",
+				RequestID: "trace-id",
+			},
+		},
+		{
+			name:   "accept header",
+			path:   "/example.com/foo",
+			accept: "application/problem+json",
+			err:    &serverError{status: http.StatusBadRequest, err: fmt.Errorf("parsing: %w", derrors.InvalidArgument)},
+			want: problemDetails{
+				Type:      "about:blank",
+				Title:     "Bad Request",
+				Status:    http.StatusBadRequest,
+				Category:  "invalid-argument",
+				RequestID: "trace-id",
+			},
+		},
+		{
+			name: "internal error",
+			path: "/autocomplete",
+			err:  errors.New("redis is down"),
+			want: problemDetails{
+				Type:      "about:blank",
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Category:  "unknown",
+				RequestID: "trace-id",
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", test.path, nil)
+			r = r.WithContext(log.NewContextWithTraceID(r.Context(), "trace-id/1;o=1"))
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			w := httptest.NewRecorder()
+			s.serveError(w, r, test.err)
+			if w.Code != test.want.Status {
+				t.Errorf("status = %d, want %d", w.Code, test.want.Status)
+			}
+			if got := w.Header().Get("Content-Type"); got != problemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+			}
+			var got problemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestServeErrorPage(t *testing.T) {
+	s, err := NewServer(ServerConfig{StaticPath: "../../content/static"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		status        int
+		want          string
+		wantRequestID bool
+	}{
+		{http.StatusBadRequest, "The address of this page is not valid.", false},
+		{http.StatusNotFound, "Check that you entered the address correctly", false},
+		{http.StatusUnavailableForLegalReasons, "This page is not available for legal reasons.", false},
+		{http.StatusInternalServerError, "Something went wrong on our end.", true},
+	} {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/example.com/foo", nil)
+			r = r.WithContext(log.NewContextWithTraceID(context.Background(), "trace-id"))
+			w := httptest.NewRecorder()
+			s.serveError(w, r, &serverError{status: test.status})
+			if w.Code != test.status {
+				t.Errorf("status = %d, want %d", w.Code, test.status)
+			}
+			body := w.Body.String()
+			if !strings.Contains(body, test.want) {
+				t.Errorf("body does not contain %q:\n%s", test.want, body)
+			}
+			if got := strings.Contains(body, "Request ID: trace-id"); got != test.wantRequestID {
+				t.Errorf("body has request ID: %t, want %t", got, test.wantRequestID)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	ctx := log.NewContextWithTraceID(context.Background(), "105445aa7843bc8bf206b12000100000/1;o=1")
+	if got, want := requestID(ctx), "105445aa7843bc8bf206b12000100000"; got != want {
+		t.Errorf("requestID(ctx with trace ID) = %q, want %q", got, want)
+	}
+	id1, id2 := requestID(context.Background()), requestID(context.Background())
+	if id1 == "" || id1 == id2 {
+		t.Errorf("requestID(ctx without trace ID) = %q, then %q; want different non-empty IDs", id1, id2)
+	}
+}
@@ -18,12 +18,15 @@ import (
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel/label"
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/modstatus"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/source"
@@ -75,38 +78,46 @@ var (
 // the requested path and version to a task queue, to be fetched by the worker.
 // Meanwhile, the request will poll the database until a row is found, or a
 // timeout occurs. A status and responseText will be returned based on the
-// result of the request.
+// result of the request; errors are reported as problem details, with the
+// responseText as their detail.
 // TODO(golang/go#37002): This should be a POST request, since it is causing a change in state.
 // update middleware.AcceptMethods so that this can be a POST instead of a GET.
-func (s *Server) fetchHandler(w http.ResponseWriter, r *http.Request) {
-	if _, ok := s.ds.(*postgres.DB); !ok {
+func (s *Server) fetchHandler(w http.ResponseWriter, r *http.Request) error {
+	db, ok := s.ds.(*postgres.DB)
+	if !ok {
 		// There's no reason for the proxydatasource to need this codepath.
-		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-		return
+		return &serverError{status: http.StatusForbidden}
 	}
 	ctx := r.Context()
 	if !isActiveFrontendFetch(ctx) {
 		// If the experiment flag is not on, treat this as a request for the
 		// "fetch" package, which does not exist.
-		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-		return
+		return &serverError{status: http.StatusNotFound}
 	}
 	// fetchHander accepts a requests following the same URL format as the
 	// detailsHandler.
 	fullPath, modulePath, requestedVersion, err := parseDetailsURLPath(strings.TrimPrefix(r.URL.Path, "/fetch"))
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
+		return &serverError{status: http.StatusBadRequest, err: err}
 	}
 	if !isActivePathAtMaster(ctx) && requestedVersion != internal.MasterVersion {
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
-		return
+		return &serverError{status: http.StatusBadRequest}
+	}
+	if !internal.IsServed(fullPath) {
+		return &serverError{status: http.StatusNotFound}
+	}
+	rp, err := db.GetRemovedPath(ctx, fullPath, requestedVersion)
+	if err != nil {
+		return err
+	}
+	if rp != nil {
+		return removedPathError(fullPath, rp.Reason)
 	}
 	status, responseText := s.fetchAndPoll(r.Context(), modulePath, fullPath, requestedVersion)
 	if status != http.StatusOK {
-		http.Error(w, responseText, status)
-		return
+		return &serverError{status: status, detail: responseText}
 	}
+	return nil
 }
 
 type fetchResult struct {
@@ -177,7 +188,7 @@ func (s *Server) fetchAndPoll(parentCtx context.Context, modulePath, fullPath, r
 	for _, fr := range results {
 		// Results are in order of longest module path first. Once an
 		// appropriate result is found, return. Otherwise, look at the next path.
-		if fr.status == derrors.ToHTTPStatus(derrors.AlternativeModule) {
+		if modstatus.Code(fr.status) == modstatus.AlternativeModule {
 			return fr.status, fmt.Sprintf("%q is not a supported package path. Were you looking for %q?", fullPath, fr.goModPath)
 		}
 		if responseText, ok := statusToResponseText[fr.status]; ok {
@@ -207,6 +218,12 @@ func (s *Server) fetchAndPoll(parentCtx context.Context, modulePath, fullPath, r
 }
 
 func (s *Server) fetchModule(ctx context.Context, fullPath, modulePath, requestedVersion string) (fr *fetchResult) {
+	ctx, span := dtrace.StartSpan(ctx, "frontend.fetchModule",
+		dtrace.ModulePath(modulePath), dtrace.Version(requestedVersion))
+	defer func() {
+		span.SetAttributes(label.Int("pkgsite.fetch.status", fr.status))
+		dtrace.End(span, &fr.err)
+	}()
 	// Before enqueuing the module version to be fetched, check if we have
 	// already attempted to fetch it in the past. If so, just return the result
 	// from that fetch process.
@@ -315,7 +332,7 @@ func checkForPath(ctx context.Context, db *postgres.DB, fullPath, modulePath, re
 		// The version_map indicates that the proxy returned a 404/410.
 		fr.err = errModuleDoesNotExist
 		return fr
-	case derrors.ToHTTPStatus(derrors.AlternativeModule):
+	case int(modstatus.AlternativeModule):
 		// The row indicates that the provided module path did not match the
 		// module path returned by a request to
 		// /<modulePath>/@v/<requestedPath>.mod.
@@ -328,7 +345,7 @@ func checkForPath(ctx context.Context, db *postgres.DB, fullPath, modulePath, re
 		// that is complete.
 		// TODO(golang/go#37002): mark versions for reprocessing in version_map
 		// inside postgres.UpdateModuleVersionStatesForReprocessing.
-		if fr.status >= derrors.ToHTTPStatus(derrors.ReprocessStatusOK) {
+		if modstatus.Code(fr.status).IsReprocess() {
 			fr.status = http.StatusProcessing
 		}
 		// All remaining non-200 statuses will be in the 40x range.
@@ -363,28 +380,40 @@ func checkForPath(ctx context.Context, db *postgres.DB, fullPath, modulePath, re
 }
 
 // modulePathsToFetch returns the slice of module paths that we should check
-// for the path. If modulePath is known, only check that modulePath. If a row
-// for the fullPath already exists, check that modulePath. Otherwise, check all
-// possible module paths based on the elements for the fullPath.
-// Resulting paths are returned in reverse length order.
-func modulePathsToFetch(ctx context.Context, ds internal.DataSource, fullPath, modulePath string) (_ []string, err error) {
-
-	defer derrors.Wrap(&err, "modulePathsToFetch(ctx, ds, %q, %q)", fullPath, modulePath)
+// for the path. If modulePath is known, only check that modulePath. If a
+// module that contains the fullPath already exists, check that modulePath.
+// Otherwise, check all possible module paths based on the elements for the
+// fullPath, except for those shorter than the longest known module path, which
+// would take precedence over them. Resulting paths are returned in reverse
+// length order.
+func modulePathsToFetch(ctx context.Context, db *postgres.DB, fullPath, modulePath string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "modulePathsToFetch(ctx, db, %q, %q)", fullPath, modulePath)
 	if modulePath != internal.UnknownModulePath {
 		return []string{modulePath}, nil
 	}
-
-	modulePath, _, _, err = ds.GetPathInfo(ctx, fullPath, modulePath, internal.LatestVersion)
+	modulePaths, err := candidateModulePaths(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	modulePath, containsPath, err := db.GetModulePathForPath(ctx, fullPath, modulePaths)
 	if err != nil && !errors.Is(err, derrors.NotFound) {
 		return nil, &serverError{
 			status: http.StatusInternalServerError,
 			err:    fmt.Errorf("fetchModuleForPath: %v", err),
 		}
 	}
-	if err == nil {
+	if err != nil {
+		return modulePaths, nil
+	}
+	if containsPath {
 		return []string{modulePath}, nil
 	}
-	return candidateModulePaths(fullPath)
+	for i, mp := range modulePaths {
+		if mp == modulePath {
+			return modulePaths[:i+1], nil
+		}
+	}
+	return modulePaths, nil
 }
 
 var vcsHostsWithThreeElementRepoName = map[string]bool{
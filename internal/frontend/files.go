@@ -0,0 +1,321 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/stdlib"
+)
+
+// filesCacheControl is the Cache-Control header of responses from the files
+// endpoint. The contents of a module version never change, but whether it can
+// be redistributed may.
+var filesCacheControl = fmt.Sprintf("public, max-age=%d", int(longTTL.Seconds()))
+
+// maxFilesZipSize is the size, in bytes, of the largest module zip whose
+// files are served. Zips are read into memory, and kept there for later
+// requests; see filesZipCache.
+const maxFilesZipSize = 100 << 20
+
+// serveFile serves a single file of a module version, as found in the zip
+// that the module proxy serves for it. The URL has the form
+//
+//	/files/<module-path>@<version>/<file-path>
+//
+// If the file path is empty or ends in a slash, it serves the list of files
+// in that directory and below it, one per line, instead.
+//
+// Files are only served from directories that are not removed and whose
+// licenses, as detected when the module is fetched, allow redistribution.
+// They are always served
+// as plain text or as an opaque stream of bytes, never as HTML, so that the
+// contents of a module cannot run in the context of the site.
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	if s.proxyClient == nil {
+		return &serverError{status: http.StatusNotFound}
+	}
+	modulePath, version, filePath, err := parseFilesURLPath(strings.TrimPrefix(r.URL.Path, "/files"))
+	if err != nil {
+		return &serverError{status: http.StatusBadRequest, err: err}
+	}
+	mi, err := s.ds.LegacyGetModuleInfo(ctx, modulePath, version)
+	if err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{status: http.StatusNotFound, err: err}
+		}
+		return err
+	}
+	dir := path.Dir(filePath)
+	if filePath == "" || strings.HasSuffix(filePath, "/") {
+		dir = path.Clean(filePath)
+	}
+	access := &fileAccess{s: s, mi: &mi.ModuleInfo, dirs: map[string]error{}}
+	// Check for removed paths before reading the zip, so that requests for
+	// files that must not be served are cheap.
+	if err := access.checkRemoved(ctx, dir); err != nil {
+		return err
+	}
+	access.mf, err = s.moduleFiles(ctx, &mi.ModuleInfo)
+	if err != nil {
+		return err
+	}
+	if err := access.check(ctx, dir); err != nil {
+		return err
+	}
+	zr := access.mf.zr
+	prefix := modulePath + "@" + version + "/"
+	if filePath == "" || strings.HasSuffix(filePath, "/") {
+		var names []string
+		for _, n := range zipFileNames(zr, prefix+filePath) {
+			err := access.check(ctx, path.Dir(strings.TrimPrefix(n, prefix)))
+			var serr *serverError
+			switch {
+			case err == nil:
+				names = append(names, n)
+			case errors.As(err, &serr):
+				// Leave out files that cannot be served.
+			default:
+				return err
+			}
+		}
+		if len(names) == 0 {
+			return &serverError{status: http.StatusNotFound}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", filesCacheControl)
+		for _, n := range names {
+			fmt.Fprintln(w, strings.TrimPrefix(n, prefix))
+		}
+		return nil
+	}
+	var f *zip.File
+	for _, zf := range zr.File {
+		if zf.Name == prefix+filePath {
+			f = zf
+			break
+		}
+	}
+	if f == nil {
+		return &serverError{status: http.StatusNotFound}
+	}
+	if f.UncompressedSize64 > fetch.MaxFileSize {
+		return &serverError{
+			status: http.StatusRequestEntityTooLarge,
+			err:    fmt.Errorf("%s: size %d exceeds max limit %d", f.Name, f.UncompressedSize64, fetch.MaxFileSize),
+		}
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	// Sniff the start of the file to tell text from binary data.
+	head := make([]byte, 512)
+	n, err := io.ReadFull(rc, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	contentType := "application/octet-stream"
+	if strings.HasPrefix(http.DetectContentType(head), "text/") {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatUint(f.UncompressedSize64, 10))
+	w.Header().Set("Cache-Control", filesCacheControl)
+	// Once the response has started, errors can no longer be reported to the
+	// client.
+	if _, err := w.Write(head); err == nil {
+		io.Copy(w, rc)
+	}
+	return nil
+}
+
+// moduleFiles returns the zip of the module version of mi, and the licenses
+// in it. Module zips are not stored by the site, so it is fetched from the
+// proxy, unless it is too large to be read into memory, and then cached.
+func (s *Server) moduleFiles(ctx context.Context, mi *internal.ModuleInfo) (*moduleFiles, error) {
+	return s.filesZips.get(ctx, mi.ModulePath+"@"+mi.Version, func(ctx context.Context) (*moduleFiles, error) {
+		size, err := s.proxyClient.GetZipSize(ctx, mi.ModulePath, mi.Version)
+		if err != nil {
+			if errors.Is(err, derrors.NotFound) {
+				return nil, &serverError{status: http.StatusNotFound, err: err}
+			}
+			return nil, err
+		}
+		if size > maxFilesZipSize {
+			return nil, &serverError{
+				status: http.StatusRequestEntityTooLarge,
+				err:    fmt.Errorf("%s@%s: zip size %d exceeds max limit %d", mi.ModulePath, mi.Version, size, maxFilesZipSize),
+			}
+		}
+		zr, err := s.proxyClient.GetZip(ctx, mi.ModulePath, mi.Version)
+		if err != nil {
+			if errors.Is(err, derrors.NotFound) {
+				return nil, &serverError{status: http.StatusNotFound, err: err}
+			}
+			return nil, err
+		}
+		logf := func(format string, args ...interface{}) {
+			log.Infof(ctx, format, args...)
+		}
+		// Use the same license detection as the worker does when it fetches
+		// the module. Detect the licenses of every directory now, since the
+		// Detector is shared by concurrent requests.
+		d := licenses.NewDetector(mi.ModulePath, mi.Version, zr, logf)
+		d.AllLicenses()
+		return &moduleFiles{zr: zr, detector: d, size: size}, nil
+	})
+}
+
+// A fileAccess decides whether the files of the directories of a module
+// version may be served, and remembers its decisions.
+type fileAccess struct {
+	s    *Server
+	mi   *internal.ModuleInfo
+	mf   *moduleFiles
+	dirs map[string]error // by directory relative to the module root
+}
+
+// check returns an error if the files in dir, a directory relative to the
+// module root, may not be served: because a removed path covers it, or
+// because the licenses that cover it do not allow redistribution. A license
+// override for dir or a directory above it takes precedence over the detected
+// licenses.
+func (a *fileAccess) check(ctx context.Context, dir string) error {
+	if err, ok := a.dirs[dir]; ok {
+		return err
+	}
+	err := a.doCheck(ctx, dir)
+	a.dirs[dir] = err
+	return err
+}
+
+func (a *fileAccess) doCheck(ctx context.Context, dir string) error {
+	if err := a.checkRemoved(ctx, dir); err != nil {
+		return err
+	}
+	redistributable, _ := a.mf.detector.PackageInfo(dir)
+	if db, ok := a.s.ds.(*postgres.DB); ok {
+		o, err := db.GetLicenseOverride(ctx, a.unitPath(dir), a.mi.Version)
+		if err != nil {
+			return err
+		}
+		if o != nil {
+			redistributable = o.IsRedistributable
+		}
+	}
+	if !redistributable {
+		return &serverError{
+			status: http.StatusForbidden,
+			err:    fmt.Errorf("%s@%s is not redistributable", a.unitPath(dir), a.mi.Version),
+		}
+	}
+	return nil
+}
+
+// checkRemoved returns an error if a removed path covers dir.
+func (a *fileAccess) checkRemoved(ctx context.Context, dir string) error {
+	db, ok := a.s.ds.(*postgres.DB)
+	if !ok {
+		return nil
+	}
+	rp, err := db.GetRemovedPath(ctx, a.unitPath(dir), a.mi.Version)
+	if err != nil {
+		return err
+	}
+	if rp != nil {
+		return removedPathError(a.unitPath(dir)+"@"+a.mi.Version, rp.Reason)
+	}
+	return nil
+}
+
+// unitPath returns the full path of dir, a directory relative to the module
+// root.
+func (a *fileAccess) unitPath(dir string) string {
+	if dir == "." {
+		return a.mi.ModulePath
+	}
+	return a.mi.ModulePath + "/" + dir
+}
+
+// parseFilesURLPath parses a URL path of the form
+// /<module-path>@<version>[/<file-path>]. The version must be a semantic
+// version; the file path is returned without a leading slash.
+func parseFilesURLPath(urlPath string) (modulePath, version, filePath string, err error) {
+	defer derrors.Wrap(&err, "parseFilesURLPath(%q)", urlPath)
+
+	parts := strings.SplitN(strings.TrimPrefix(urlPath, "/"), "@", 2)
+	if len(parts) != 2 {
+		return "", "", "", errors.New("missing version")
+	}
+	modulePath = parts[0]
+	version = parts[1]
+	if i := strings.IndexByte(version, '/'); i >= 0 {
+		version, filePath = version[:i], version[i+1:]
+	}
+	if stdlib.Contains(modulePath) {
+		return "", "", "", errors.New("files of the standard library are not served")
+	}
+	if err := module.CheckPath(modulePath); err != nil {
+		return "", "", "", err
+	}
+	if !semver.IsValid(version) {
+		return "", "", "", fmt.Errorf("invalid version %q", version)
+	}
+	if filePath != "" {
+		clean := path.Clean(filePath)
+		if strings.HasSuffix(filePath, "/") {
+			clean += "/"
+		}
+		if clean != filePath || strings.HasPrefix(clean, "../") || clean == "./" {
+			return "", "", "", fmt.Errorf("bad file path %q", filePath)
+		}
+	}
+	return modulePath, version, filePath, nil
+}
+
+// zipFileNames returns the sorted names of the files in zr that begin with
+// prefix.
+func zipFileNames(zr *zip.Reader, prefix string) []string {
+	var names []string
+	for _, f := range zr.File {
+		if strings.HasPrefix(f.Name, prefix) && !strings.HasSuffix(f.Name, "/") {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileProxyURL returns the URL of the file at filePath in modulePath@version
+// on the files endpoint. It returns "" if the files of the module are not
+// served: for the standard library, and for modules that are not
+// redistributable.
+func fileProxyURL(mi *internal.ModuleInfo, filePath string) string {
+	if mi.ModulePath == stdlib.ModulePath || !mi.IsRedistributable {
+		return ""
+	}
+	return "/files/" + mi.ModulePath + "@" + mi.Version + "/" + strings.TrimPrefix(path.Clean(filePath), "/")
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/testing/sample"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+func TestParseFilesURLPath(t *testing.T) {
+	for _, test := range []struct {
+		urlPath                   string
+		wantModule, wantV, wantFP string
+		wantErr                   bool
+	}{
+		{"/github.com/a/b@v1.2.3/c/d.go", "github.com/a/b", "v1.2.3", "c/d.go", false},
+		{"/github.com/a/b@v1.2.3", "github.com/a/b", "v1.2.3", "", false},
+		{"/github.com/a/b@v1.2.3/c/", "github.com/a/b", "v1.2.3", "c/", false},
+		{"/github.com/a/b/c/d.go", "", "", "", true},
+		{"/github.com/a/b@latest/c/d.go", "", "", "", true},
+		{"/github.com/a/b@v1.2.3/../d.go", "", "", "", true},
+		{"/github.com/a/b@v1.2.3/c//d.go", "", "", "", true},
+		{"/net/http@go1.14/request.go", "", "", "", true},
+	} {
+		gotModule, gotV, gotFP, err := parseFilesURLPath(test.urlPath)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseFilesURLPath(%q): got error %v, want error: %t", test.urlPath, err, test.wantErr)
+			continue
+		}
+		if gotModule != test.wantModule || gotV != test.wantV || gotFP != test.wantFP {
+			t.Errorf("parseFilesURLPath(%q) = %q, %q, %q; want %q, %q, %q",
+				test.urlPath, gotModule, gotV, gotFP, test.wantModule, test.wantV, test.wantFP)
+		}
+	}
+}
+
+func TestServeFile(t *testing.T) {
+	ctx := context.Background()
+	_, handler, teardown := newTestServer(t, []*proxy.TestModule{
+		{
+			ModulePath: sample.ModulePath,
+			Version:    sample.VersionString,
+			Files: map[string]string{
+				"LICENSE":               testhelper.MITLicense,
+				"foo/foo.go":            "package foo\n",
+				"foo/x.html":            "<script>alert(1)</script>",
+				"bar/LICENSE":           "All rights reserved.\n",
+				"bar/bar.go":            "package bar\n",
+				"bar/testdata/data.txt": "data\n",
+				"baz/baz.go":            "package baz\n",
+				"qux/qux.go":            "package qux\n",
+			},
+		},
+	})
+	defer teardown()
+	defer postgres.ResetTestDB(testDB, t)
+	m := sample.DefaultModule()
+	// The license of bar does not allow redistribution, baz is removed,
+	// and a license override covers qux.
+	bar := sample.LegacyPackage(sample.ModulePath, "bar")
+	bar.IsRedistributable = false
+	bar.Licenses = nil
+	sample.AddPackage(m, bar)
+	sample.AddPackage(m, sample.LegacyPackage(sample.ModulePath, "baz"))
+	if err := testDB.SetLicenseOverride(ctx, &postgres.LicenseOverride{
+		Path:              sample.ModulePath + "/qux",
+		IsRedistributable: false,
+		Reason:            "license is not OSI-approved",
+		CreatedBy:         "test",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.InsertRemovedPath(ctx, &postgres.RemovedPath{
+		Path:      sample.ModulePath + "/baz",
+		Scope:     postgres.RemovedPrefix,
+		Reason:    "Removed in response to a takedown request.",
+		CreatedBy: "test",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := "/files/" + sample.ModulePath + "@" + sample.VersionString
+	for _, test := range []struct {
+		path, wantBody string
+		wantStatus     int
+	}{
+		{prefix + "/foo/foo.go", "package foo\n", http.StatusOK},
+		{prefix + "/foo/x.html", "<script>alert(1)</script>", http.StatusOK},
+		{prefix + "/foo/", "foo/foo.go\nfoo/x.html\n", http.StatusOK},
+		{prefix + "/foo/bar.go", "", http.StatusNotFound},
+		{prefix + "/", "LICENSE\nfoo/foo.go\nfoo/x.html\n", http.StatusOK},
+		{prefix + "/bar/bar.go", "", http.StatusForbidden},
+		{prefix + "/bar/", "", http.StatusForbidden},
+		// There is no unit at bar/testdata, but the license of bar covers it.
+		{prefix + "/bar/testdata/data.txt", "", http.StatusForbidden},
+		{prefix + "/qux/qux.go", "", http.StatusForbidden},
+		{prefix + "/baz/baz.go", "", http.StatusUnavailableForLegalReasons},
+		{"/files/github.com/unknown/module@v1.0.0/foo.go", "", http.StatusNotFound},
+		{"/files/" + sample.ModulePath + "/foo/foo.go", "", http.StatusBadRequest},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+			if got := w.Body.String(); got != test.wantBody {
+				t.Errorf("body = %q, want %q", got, test.wantBody)
+			}
+			if got, want := w.Header().Get("Content-Type"), "text/plain; charset=utf-8"; got != want {
+				t.Errorf("Content-Type = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFilesZipCache(t *testing.T) {
+	ctx := context.Background()
+	c := newFilesZipCache()
+	loads := 0
+	get := func(key string, size int64) {
+		t.Helper()
+		mf, err := c.get(ctx, key, func(context.Context) (*moduleFiles, error) {
+			loads++
+			return &moduleFiles{size: size}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mf.size != size {
+			t.Fatalf("get(%q): got size %d, want %d", key, mf.size, size)
+		}
+	}
+
+	get("a@v1.0.0", maxFilesZipSize)
+	get("a@v1.0.0", maxFilesZipSize)
+	if loads != 1 {
+		t.Errorf("got %d loads, want 1", loads)
+	}
+	// Adding two more zips of the maximum size evicts the first.
+	get("b@v1.0.0", maxFilesZipSize)
+	get("c@v1.0.0", maxFilesZipSize)
+	if c.size > filesZipCacheSize {
+		t.Errorf("cache size %d exceeds %d", c.size, filesZipCacheSize)
+	}
+	get("a@v1.0.0", maxFilesZipSize)
+	if loads != 4 {
+		t.Errorf("got %d loads, want 4", loads)
+	}
+}
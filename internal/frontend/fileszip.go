@@ -0,0 +1,97 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"archive/zip"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/sync/singleflight"
+)
+
+// filesZipCacheSize is the total size, in bytes, of the module zips that are
+// kept in memory to serve files. It holds at least one zip of the maximum
+// size.
+const filesZipCacheSize = 2 * maxFilesZipSize
+
+// filesZipTimeout bounds the time to read a module zip and detect its
+// licenses.
+const filesZipTimeout = time.Minute
+
+// A moduleFiles is the zip of a module version, along with the licenses
+// detected in it.
+type moduleFiles struct {
+	zr       *zip.Reader
+	detector *licenses.Detector
+	size     int64
+}
+
+// A filesZipCache holds the most recently used module zips, so that each
+// request to the files endpoint does not read a zip from the proxy. Concurrent
+// requests for the same module version share a single read.
+type filesZipCache struct {
+	group singleflight.Group
+
+	mu    sync.Mutex
+	size  int64      // total size of the zips in cache
+	cache *lru.Cache // of *moduleFiles, by module@version
+}
+
+func newFilesZipCache() *filesZipCache {
+	c := &filesZipCache{cache: lru.New(0)}
+	c.cache.OnEvicted = func(_ lru.Key, v interface{}) {
+		c.size -= v.(*moduleFiles).size
+	}
+	return c
+}
+
+// get returns the moduleFiles for key, calling load to read them if they are
+// not in the cache. Since the result is shared, load runs with its own
+// deadline rather than that of the request that started it; ctx only bounds
+// the wait.
+func (c *filesZipCache) get(ctx context.Context, key string, load func(context.Context) (*moduleFiles, error)) (*moduleFiles, error) {
+	c.mu.Lock()
+	v, ok := c.cache.Get(key)
+	c.mu.Unlock()
+	if ok {
+		return v.(*moduleFiles), nil
+	}
+	ch := c.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), filesZipTimeout)
+		defer cancel()
+		mf, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.add(key, mf)
+		return mf, nil
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(*moduleFiles), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *filesZipCache) add(key string, mf *moduleFiles) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.cache.Get(key); ok {
+		return
+	}
+	c.cache.Add(key, mf)
+	c.size += mf.size
+	for c.size > filesZipCacheSize && c.cache.Len() > 1 {
+		c.cache.RemoveOldest()
+	}
+}
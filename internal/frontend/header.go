@@ -7,6 +7,7 @@ package frontend
 import (
 	"fmt"
 	"html/template"
+	"math"
 	"path"
 	"strings"
 	"time"
@@ -17,6 +18,7 @@ import (
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/middleware"
 	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/version"
 )
 
 // Package contains information for an individual package.
@@ -29,6 +31,10 @@ type Package struct {
 	URL                string // relative to this site
 	LatestURL          string // link with latest-version placeholder, relative to this site
 	Licenses           []LicenseMetadata
+	// DocumentationCoverage is the percentage of the identifiers in the
+	// documentation that have doc comments, such as "85%", or empty if it is
+	// not known.
+	DocumentationCoverage string
 }
 
 // Module contains information for an individual module.
@@ -41,6 +47,25 @@ type Module struct {
 	URL               string // relative to this site
 	LatestURL         string // link with latest-version placeholder, relative to this site
 	Licenses          []LicenseMetadata
+	// VCSRef is the tag or commit of the version in the module's repo, or
+	// nil if it is not known.
+	VCSRef *VCSRef
+	// ReleaseNotesURL links to the release notes of the version of the
+	// standard library. It is empty for other modules.
+	ReleaseNotesURL string
+	// PublishTime is when the module index reported the version, or empty if
+	// it is not known. CommitTime is shown instead when it is empty.
+	PublishTime string
+}
+
+// VCSRef identifies the tag or commit in a repo that a version was made from.
+// Exactly one of Tag and Commit is set. The URLs link to the page of the tag
+// or commit at the repo's host, and may be empty.
+type VCSRef struct {
+	Tag       string
+	TagURL    string
+	Commit    string
+	CommitURL string
 }
 
 // legacyCreatePackage returns a *Package based on the fields of the specified
@@ -69,13 +94,14 @@ func legacyCreatePackage(pkg *internal.LegacyPackage, mi *internal.ModuleInfo, l
 		urlVersion = internal.LatestVersion
 	}
 	return &Package{
-		Path:              pkg.Path,
-		Synopsis:          pkg.Synopsis,
-		IsRedistributable: pkg.IsRedistributable,
-		Licenses:          transformLicenseMetadata(pkg.Licenses),
-		Module:            *m,
-		URL:               constructPackageURL(pkg.Path, mi.ModulePath, urlVersion),
-		LatestURL:         constructPackageURL(pkg.Path, mi.ModulePath, middleware.LatestVersionPlaceholder),
+		Path:                  pkg.Path,
+		Synopsis:              pkg.Synopsis,
+		IsRedistributable:     pkg.IsRedistributable,
+		Licenses:              transformLicenseMetadata(mi.SourceInfo, pkg.Licenses),
+		Module:                *m,
+		URL:                   constructPackageURL(pkg.Path, mi.ModulePath, urlVersion),
+		LatestURL:             constructPackageURL(pkg.Path, mi.ModulePath, middleware.LatestVersionPlaceholder),
+		DocumentationCoverage: formatCoverage(pkg.DocumentationCoverage),
 	}, nil
 }
 
@@ -105,16 +131,29 @@ func createPackageNew(vdir *internal.VersionedDirectory, latestRequested bool) (
 		urlVersion = internal.LatestVersion
 	}
 	return &Package{
-		Path:              vdir.Path,
-		Synopsis:          vdir.Package.Documentation.Synopsis,
-		IsRedistributable: vdir.DirectoryNew.IsRedistributable,
-		Licenses:          transformLicenseMetadata(vdir.Licenses),
-		Module:            *m,
-		URL:               constructPackageURL(vdir.Path, vdir.ModulePath, urlVersion),
-		LatestURL:         constructPackageURL(vdir.Path, vdir.ModulePath, middleware.LatestVersionPlaceholder),
+		Path:                  vdir.Path,
+		Synopsis:              vdir.Package.Documentation.Synopsis,
+		IsRedistributable:     vdir.DirectoryNew.IsRedistributable,
+		Licenses:              transformLicenseMetadata(vdir.SourceInfo, vdir.Licenses),
+		Module:                *m,
+		URL:                   constructPackageURL(vdir.Path, vdir.ModulePath, urlVersion),
+		LatestURL:             constructPackageURL(vdir.Path, vdir.ModulePath, middleware.LatestVersionPlaceholder),
+		DocumentationCoverage: formatCoverage(vdir.Package.Documentation.Coverage),
 	}, nil
 }
 
+// formatCoverage formats a documentation coverage as a percentage, or returns
+// the empty string if it is nil. The percentage is rounded down, so that only
+// fully documented packages show 100%.
+func formatCoverage(c *float64) string {
+	if c == nil {
+		return ""
+	}
+	// Add a little, so that fractions like 29/100 are not rounded down from
+	// 28.999....
+	return fmt.Sprintf("%d%%", int(math.Floor(*c*100+1e-9)))
+}
+
 // createModule returns a *Module based on the fields of the specified
 // versionInfo.
 //
@@ -132,12 +171,51 @@ func createModule(mi *internal.ModuleInfo, licmetas []*licenses.Metadata, latest
 		ModulePath:        mi.ModulePath,
 		CommitTime:        elapsedTime(mi.CommitTime),
 		IsRedistributable: mi.IsRedistributable,
-		Licenses:          transformLicenseMetadata(licmetas),
+		Licenses:          transformLicenseMetadata(mi.SourceInfo, licmetas),
 		URL:               constructModuleURL(mi.ModulePath, urlVersion),
 		LatestURL:         constructModuleURL(mi.ModulePath, middleware.LatestVersionPlaceholder),
+		VCSRef:            vcsRef(mi),
+		ReleaseNotesURL:   releaseNotesURL(mi),
+		PublishTime:       publishTime(mi),
 	}
 }
 
+// publishTime formats the time the module index reported mi like
+// elapsedTime, or returns the empty string if it is not known.
+func publishTime(mi *internal.ModuleInfo) string {
+	if mi.PublishedAt.IsZero() {
+		return ""
+	}
+	return elapsedTime(mi.PublishedAt)
+}
+
+// releaseNotesURL returns the URL of the release notes of mi, if it is a
+// version of the standard library.
+func releaseNotesURL(mi *internal.ModuleInfo) string {
+	if mi.ModulePath != stdlib.ModulePath {
+		return ""
+	}
+	return stdlib.ReleaseNotesURL(mi.Version)
+}
+
+// vcsRef returns the VCSRef of mi. The commit of a pseudo-version can be
+// read from the version itself, but the tag of other versions is only known
+// from the module's source info.
+func vcsRef(mi *internal.ModuleInfo) *VCSRef {
+	v := strings.TrimSuffix(mi.Version, "+incompatible")
+	if version.IsPseudo(v) {
+		ref := &VCSRef{Commit: mi.SourceInfo.Commit(), CommitURL: mi.SourceInfo.CommitURL()}
+		if ref.Commit == "" {
+			ref.Commit = v[strings.LastIndex(v, "-")+1:]
+		}
+		return ref
+	}
+	if mi.SourceInfo == nil {
+		return nil
+	}
+	return &VCSRef{Tag: mi.SourceInfo.Commit(), TagURL: mi.SourceInfo.TagURL()}
+}
+
 func constructModuleURL(modulePath, linkVersion string) string {
 	url := "/"
 	if modulePath != stdlib.ModulePath {
@@ -329,6 +407,9 @@ func moduleTitle(modulePath string) string {
 // (2) 'today' between 6 hours and 1 day ago
 // (3) 'Y days ago' when Y < 6
 // (4) A date formatted like "Jan 2, 2006" for anything further back
+//
+// Dates are in UTC, so that they do not depend on the time zone of the server
+// or of the database session that read them.
 func elapsedTime(date time.Time) string {
 	elapsedHours := int(time.Since(date).Hours())
 	if elapsedHours == 1 {
@@ -346,5 +427,5 @@ func elapsedTime(date time.Time) string {
 		return fmt.Sprintf("%d days ago", elapsedDays)
 	}
 
-	return date.Format("Jan _2, 2006")
+	return date.UTC().Format("Jan _2, 2006")
 }
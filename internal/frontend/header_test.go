@@ -14,6 +14,7 @@ import (
 	"golang.org/x/net/html"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/testing/htmlcheck"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
@@ -23,19 +24,21 @@ func samplePackage(mutators ...func(*Package)) *Package {
 		Path:              sample.PackagePath,
 		Synopsis:          sample.Synopsis,
 		IsRedistributable: true,
-		Licenses:          transformLicenseMetadata(sample.LicenseMetadata),
 		Module: Module{
 			DisplayVersion:    sample.VersionString,
 			LinkVersion:       sample.VersionString,
 			CommitTime:        "0 hours ago",
 			ModulePath:        sample.ModulePath,
 			IsRedistributable: true,
-			Licenses:          transformLicenseMetadata(sample.LicenseMetadata),
 		},
 	}
 	for _, mut := range mutators {
 		mut(p)
 	}
+	info := sample.ModuleInfo(p.ModulePath, p.LinkVersion).SourceInfo
+	p.Licenses = transformLicenseMetadata(info, sample.LicenseMetadata)
+	p.Module.Licenses = transformLicenseMetadata(info, sample.LicenseMetadata)
+	p.Module.VCSRef = &VCSRef{Tag: info.Commit(), TagURL: info.TagURL()}
 	p.URL = constructPackageURL(p.Path, p.ModulePath, p.LinkVersion)
 	p.Module.URL = constructModuleURL(p.ModulePath, p.LinkVersion)
 	p.LatestURL = constructPackageURL(p.Path, p.ModulePath, middleware.LatestVersionPlaceholder)
@@ -78,7 +81,12 @@ func TestElapsedTime(t *testing.T) {
 		{
 			name:        "more_than_6_days_ago",
 			date:        now.Add(time.Hour * 24 * -14),
-			elapsedTime: now.Add(time.Hour * 24 * -14).Format("Jan _2, 2006"),
+			elapsedTime: now.Add(time.Hour * 24 * -14).UTC().Format("Jan _2, 2006"),
+		},
+		{
+			name:        "date_in_utc",
+			date:        time.Date(2019, 1, 1, 23, 0, 0, 0, time.FixedZone("UTC-5", -5*60*60)),
+			elapsedTime: "Jan  2, 2019",
 		},
 	}
 
@@ -225,3 +233,71 @@ func TestBreadcrumbPath(t *testing.T) {
 		})
 	}
 }
+
+func TestVCSRef(t *testing.T) {
+	const pseudo = "v0.0.0-20190615154606-3a9541ec9974"
+	for _, test := range []struct {
+		name string
+		mi   *internal.ModuleInfo
+		want *VCSRef
+	}{
+		{
+			name: "tag",
+			mi:   &internal.ModuleInfo{Version: "v1.2.3", SourceInfo: source.NewGitHubInfo("https://github.com/a/b", "foo", "foo/v1.2.3")},
+			want: &VCSRef{Tag: "foo/v1.2.3", TagURL: "https://github.com/a/b/releases/tag/foo/v1.2.3"},
+		},
+		{
+			name: "commit",
+			mi:   &internal.ModuleInfo{Version: pseudo, SourceInfo: source.NewGitHubInfo("https://github.com/a/b", "", "3a9541ec9974")},
+			want: &VCSRef{Commit: "3a9541ec9974", CommitURL: "https://github.com/a/b/commit/3a9541ec9974"},
+		},
+		{
+			name: "commit without source info",
+			mi:   &internal.ModuleInfo{Version: pseudo + "+incompatible"},
+			want: &VCSRef{Commit: "3a9541ec9974"},
+		},
+		{
+			name: "tag without source info",
+			mi:   &internal.ModuleInfo{Version: "v1.2.3"},
+			want: nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if diff := cmp.Diff(test.want, vcsRef(test.mi)); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFormatCoverage(t *testing.T) {
+	c := func(f float64) *float64 { return &f }
+	for _, test := range []struct {
+		in   *float64
+		want string
+	}{
+		{nil, ""},
+		{c(0), "0%"},
+		{c(29.0 / 100), "29%"},
+		{c(2.0 / 3), "66%"},
+		{c(199.0 / 200), "99%"},
+		{c(1), "100%"},
+	} {
+		if got := formatCoverage(test.in); got != test.want {
+			t.Errorf("formatCoverage(%v) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestPublishTime(t *testing.T) {
+	if got := publishTime(&internal.ModuleInfo{}); got != "" {
+		t.Errorf("publishTime with an unknown publish time = %q, want empty", got)
+	}
+	mi := &internal.ModuleInfo{
+		CommitTime:  time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		PublishedAt: time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC),
+	}
+	if got, want := publishTime(mi), "Mar  4, 2019"; got != want {
+		t.Errorf("publishTime(%+v) = %q, want %q", mi, got, want)
+	}
+}
@@ -7,9 +7,13 @@ package frontend
 import (
 	"context"
 	"net/url"
+	"path"
 
+	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/source"
 )
 
 // License contains information used for a single license section.
@@ -19,9 +23,24 @@ type License struct {
 	Source string
 }
 
+// Notice contains information used for a single notice section, like that of
+// a NOTICE file.
+type Notice struct {
+	*licenses.Notice
+	Anchor string
+	Source string
+}
+
 // LicensesDetails contains license information for a package or module.
 type LicensesDetails struct {
 	Licenses []License
+	// Notices are the files that accompany the licenses, like NOTICE and
+	// PATENTS files. Licenses such as Apache-2.0 require them to be passed
+	// on along with the licensed work.
+	Notices []Notice
+	// LicenseChange is the most recent change to the licenses of the module
+	// at or before the current version, if any.
+	LicenseChange *internal.LicenseChange
 }
 
 // LicenseMetadata contains license metadata that is used in the package
@@ -29,6 +48,16 @@ type LicensesDetails struct {
 type LicenseMetadata struct {
 	Type   string
 	Anchor string
+	// FilePath is the path of the license file, relative to the module root.
+	FilePath string
+	// URL links to the license file in the module's repository, or is empty
+	// if the repository is unknown.
+	URL string
+}
+
+// AtRoot reports whether the license file is in the module root directory.
+func (l LicenseMetadata) AtRoot() bool {
+	return path.Dir(l.FilePath) == "."
 }
 
 // fetchPackageLicensesDetails fetches license data for the package version specified by
@@ -38,7 +67,49 @@ func fetchPackageLicensesDetails(ctx context.Context, ds internal.DataSource, pk
 	if err != nil {
 		return nil, err
 	}
-	return &LicensesDetails{Licenses: transformLicenses(modulePath, version, dsLicenses)}, nil
+	return fetchLicensesDetails(ctx, ds, pkgPath, modulePath, version, dsLicenses)
+}
+
+// fetchLicensesDetails returns a LicensesDetails for lics, the licenses of
+// fullPath, a package or module in modulePath@version.
+func fetchLicensesDetails(ctx context.Context, ds internal.DataSource, fullPath, modulePath, version string, lics []*licenses.License) (*LicensesDetails, error) {
+	changes, err := fetchLicenseChanges(ctx, ds, modulePath)
+	if err != nil {
+		return nil, err
+	}
+	ld := &LicensesDetails{Licenses: transformLicenses(modulePath, version, lics)}
+	// Like the license history, notices are only available from the database.
+	if db, ok := ds.(*postgres.DB); ok {
+		notices, err := db.GetNoticesForPath(ctx, fullPath, modulePath, version)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range notices {
+			ld.Notices = append(ld.Notices, Notice{
+				Notice: n,
+				Anchor: licenseAnchor(n.FilePath),
+				Source: fileSource(modulePath, version, n.FilePath),
+			})
+		}
+	}
+	for _, c := range changes {
+		if semver.Compare(c.Version, version) <= 0 {
+			ld.LicenseChange = c
+			break
+		}
+	}
+	return ld, nil
+}
+
+// fetchLicenseChanges returns the license changes of modulePath, latest
+// first. The license history is only recorded in the database, so it returns
+// nil for other data sources.
+func fetchLicenseChanges(ctx context.Context, ds internal.DataSource, modulePath string) ([]*internal.LicenseChange, error) {
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return nil, nil
+	}
+	return db.GetLicenseChanges(ctx, modulePath)
 }
 
 // transformLicenses transforms licenses.License into a License
@@ -56,21 +127,41 @@ func transformLicenses(modulePath, version string, dbLicenses []*licenses.Licens
 }
 
 // transformLicenseMetadata transforms licenses.Metadata into a LicenseMetadata
-// by adding an anchor field.
-func transformLicenseMetadata(dbLicenses []*licenses.Metadata) []LicenseMetadata {
+// by adding an anchor field, and a link to the file using info.
+func transformLicenseMetadata(info *source.Info, dbLicenses []*licenses.Metadata) []LicenseMetadata {
 	var mds []LicenseMetadata
 	for _, l := range dbLicenses {
 		anchor := licenseAnchor(l.FilePath)
 		for _, typ := range l.Types {
 			mds = append(mds, LicenseMetadata{
-				Type:   typ,
-				Anchor: anchor,
+				Type:     typ,
+				Anchor:   anchor,
+				FilePath: l.FilePath,
+				URL:      info.FileURL(l.FilePath),
 			})
 		}
 	}
 	return mds
 }
 
+// addPackageLicenseAttribution replaces the licenses of pkg with the ones that
+// govern it according to the database, so that each is shown along with the
+// file it comes from. The list of licenses stored with a package does not
+// have a well-defined order, but the database returns them from the module
+// root down. Other data sources leave pkg unchanged.
+func addPackageLicenseAttribution(ctx context.Context, ds internal.DataSource, pkg *Package, mi *internal.ModuleInfo) error {
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return nil
+	}
+	mds, err := db.GetLicensesForPath(ctx, pkg.Path, mi.ModulePath, mi.Version)
+	if err != nil {
+		return err
+	}
+	pkg.Licenses = transformLicenseMetadata(mi.SourceInfo, mds)
+	return nil
+}
+
 // licenseAnchor returns the anchor that should be used to jump to the specific
 // license on the licenses page.
 func licenseAnchor(filePath string) string {
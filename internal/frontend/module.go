@@ -71,15 +71,19 @@ func (s *Server) legacyServeModulePageWithModule(ctx context.Context, w http.Res
 		}
 	}
 	page := &DetailsPage{
-		basePage:       s.newBasePage(r, moduleHTMLTitle(mi.ModulePath)),
-		Title:          moduleTitle(mi.ModulePath),
-		Settings:       settings,
-		Header:         modHeader,
-		BreadcrumbPath: breadcrumbPath(modHeader.ModulePath, modHeader.ModulePath, modHeader.LinkVersion),
-		Details:        details,
-		CanShowDetails: canShowDetails,
-		Tabs:           moduleTabSettings,
-		PageType:       "mod",
+		basePage:         s.newBasePage(r, moduleHTMLTitle(mi.ModulePath)),
+		Title:            moduleTitle(mi.ModulePath),
+		Settings:         settings,
+		Header:           modHeader,
+		BreadcrumbPath:   breadcrumbPath(modHeader.ModulePath, modHeader.ModulePath, modHeader.LinkVersion),
+		Details:          details,
+		CanShowDetails:   canShowDetails,
+		Tabs:             moduleTabSettings,
+		PageType:         "mod",
+		RepoStatus:       fetchRepoStatus(ctx, s.ds, mi.SourceInfo),
+		ModuleRedirect:   fetchModuleRedirect(ctx, s.ds, mi.ModulePath),
+		DependencyWeight: fetchDependencyWeight(ctx, s.ds, mi.ModulePath, mi.Version),
+		Vulns:            fetchVulnsForVersion(ctx, s.ds, mi.ModulePath, mi.Version, modHeader.LinkVersion),
 	}
 	s.servePage(ctx, w, settings.TemplateName, page)
 	return nil
@@ -13,8 +13,8 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"unicode"
 
-	"github.com/microcosm-cc/bluemonday"
 	"github.com/russross/blackfriday/v2"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
@@ -32,8 +32,14 @@ type OverviewDetails struct {
 	PackageSourceURL string
 	ReadMe           template.HTML
 	ReadMeSource     string
-	Redistributable  bool
-	RepositoryURL    string
+	// ReadMeOutline lists the headings of ReadMe, for navigating it. It is
+	// empty if ReadMe has too few headings to need it.
+	ReadMeOutline   []*ReadmeHeading
+	Redistributable bool
+	RepositoryURL   string
+	// VanityRoot is the vanity import path prefix that resolves to
+	// RepositoryURL, if any.
+	VanityRoot string
 }
 
 // versionedLinks says whether the constructed URLs should have versions.
@@ -49,11 +55,12 @@ func constructOverviewDetails(ctx context.Context, mi *internal.ModuleInfo, read
 		ModulePath:      mi.ModulePath,
 		ModuleURL:       constructModuleURL(mi.ModulePath, lv),
 		RepositoryURL:   mi.SourceInfo.RepoURL(),
+		VanityRoot:      mi.SourceInfo.VanityRoot(),
 		Redistributable: isRedistributable,
 	}
 	if overview.Redistributable && readme != nil {
 		overview.ReadMeSource = fileSource(mi.ModulePath, mi.Version, readme.Filepath)
-		overview.ReadMe = readmeHTML(ctx, mi, readme)
+		overview.ReadMe, overview.ReadMeOutline = readmeHTML(ctx, mi, readme)
 	}
 	return overview
 }
@@ -81,12 +88,13 @@ func fetchPackageOverviewDetailsNew(ctx context.Context, vdir *internal.Versione
 		ModulePath:       vdir.ModulePath,
 		ModuleURL:        constructModuleURL(vdir.ModulePath, lv),
 		RepositoryURL:    vdir.SourceInfo.RepoURL(),
+		VanityRoot:       vdir.SourceInfo.VanityRoot(),
 		Redistributable:  vdir.DirectoryNew.IsRedistributable,
 		PackageSourceURL: vdir.SourceInfo.DirectoryURL(packageSubdir(vdir.Path, vdir.ModulePath)),
 	}
 	if overview.Redistributable && vdir.Readme != nil {
 		overview.ReadMeSource = fileSource(vdir.ModulePath, vdir.Version, vdir.Readme.Filepath)
-		overview.ReadMe = readmeHTML(ctx, &vdir.ModuleInfo, vdir.Readme)
+		overview.ReadMe, overview.ReadMeOutline = readmeHTML(ctx, &vdir.ModuleInfo, vdir.Readme)
 	}
 	return overview
 }
@@ -103,40 +111,67 @@ func packageSubdir(pkgPath, modulePath string) string {
 	}
 }
 
-// readmeHTML sanitizes readmeContents based on bluemondy.UGCPolicy and returns
-// a template.HTML. If readmeFilePath indicates that this is a markdown file,
-// it will also render the markdown contents using blackfriday.
-func readmeHTML(ctx context.Context, mi *internal.ModuleInfo, readme *internal.Readme) template.HTML {
+// A ReadmeHeading is a heading in a README, for its outline.
+type ReadmeHeading struct {
+	// Level is the level of the heading, from 1 for <h1> to 6.
+	Level int
+	Text  string
+	// ID is the id attribute of the heading element.
+	ID string
+}
+
+const (
+	// minOutlineHeadings is the least number of headings that a README needs
+	// to have an outline.
+	minOutlineHeadings = 3
+
+	// maxOutlineLevel is the deepest level of heading in an outline.
+	maxOutlineLevel = 3
+)
+
+// readmeHTML sanitizes readmeContents with sanitizeHTML and returns a
+// template.HTML. If readmeFilePath indicates that this is a markdown file,
+// it will also render the markdown contents using blackfriday, and return an
+// outline of its headings if it has at least minOutlineHeadings of them.
+func readmeHTML(ctx context.Context, mi *internal.ModuleInfo, readme *internal.Readme) (template.HTML, []*ReadmeHeading) {
 	if readme == nil {
-		return ""
+		return "", nil
 	}
 	if !isMarkdown(readme.Filepath) {
-		return template.HTML(fmt.Sprintf(`<pre class="readme">%s</pre>`, template.HTMLEscapeString(readme.Contents)))
+		return template.HTML(fmt.Sprintf(`<pre class="readme">%s</pre>`, template.HTMLEscapeString(readme.Contents))), nil
 	}
 
-	// bluemonday.UGCPolicy allows a broad selection of HTML elements and
-	// attributes that are safe for user generated content. This policy does
-	// not allow iframes, object, embed, styles, script, etc.
-	p := bluemonday.UGCPolicy()
-
-	// Allow width and align attributes on img, div, and p tags.
-	// This is used to center elements in a readme as well as to size it
-	// images appropriately where used, like the gin-gonic/logo/color.png
-	// image in the github.com/gin-gonic/gin README.
-	p.AllowAttrs("width", "align").OnElements("img")
-	p.AllowAttrs("width", "align").OnElements("div")
-	p.AllowAttrs("width", "align").OnElements("p")
-
 	// blackfriday.Run() uses CommonHTMLFlags and CommonExtensions by default.
+	// Heading IDs are not generated by blackfriday, but below, so that they
+	// match the ones on GitHub.
 	renderer := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{Flags: blackfriday.CommonHTMLFlags})
-	parser := blackfriday.New(blackfriday.WithExtensions(blackfriday.CommonExtensions | blackfriday.AutoHeadingIDs))
+	parser := blackfriday.New(blackfriday.WithExtensions(blackfriday.CommonExtensions))
 
 	// Render HTML similar to blackfriday.Run(), but here we implement a custom
 	// Walk function in order to modify image paths in the rendered HTML.
 	b := &bytes.Buffer{}
 	rootNode := parser.Parse([]byte(readme.Contents))
+	var (
+		outline []*ReadmeHeading
+		ids     = headingIDs{}
+	)
 	rootNode.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
 		switch node.Type {
+		case blackfriday.Heading:
+			if entering {
+				text := headingText(node)
+				// A non-empty HeadingID was set explicitly, with the
+				// "# Heading {#id}" syntax.
+				id := node.HeadingID
+				if id == "" {
+					id = headingSlug(text)
+				}
+				id = ids.unique(id)
+				node.HeadingID = id
+				if node.Level <= maxOutlineLevel && text != "" {
+					outline = append(outline, &ReadmeHeading{Level: node.Level, Text: text, ID: id})
+				}
+			}
 		case blackfriday.Image, blackfriday.Link:
 			useRaw := node.Type == blackfriday.Image
 			if d := translateRelativeLink(string(node.LinkData.Destination), mi, useRaw, readme); d != "" {
@@ -154,7 +189,57 @@ func readmeHTML(ctx context.Context, mi *internal.ModuleInfo, readme *internal.R
 		}
 		return renderer.RenderNode(b, node, entering)
 	})
-	return template.HTML(p.SanitizeReader(b).String())
+	if len(outline) < minOutlineHeadings {
+		outline = nil
+	}
+	return sanitizeHTML(b.Bytes()), outline
+}
+
+// headingText returns the text of a heading node, without markup.
+func headingText(heading *blackfriday.Node) string {
+	var b strings.Builder
+	heading.Walk(func(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (node.Type == blackfriday.Text || node.Type == blackfriday.Code) {
+			b.Write(node.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// headingSlug returns the anchor that GitHub gives to a heading with the given
+// text, so that links between the sections of a README work on both sites:
+// the text in lower case, with spaces replaced by hyphens and punctuation
+// other than hyphens and underscores removed.
+func headingSlug(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	if b.Len() == 0 {
+		return "heading"
+	}
+	return b.String()
+}
+
+// headingIDs records the heading IDs used in a README.
+type headingIDs map[string]bool
+
+// unique returns id if it has not been used, and otherwise id with the first
+// suffix "-1", "-2", ... that makes it unused, like GitHub. It records the
+// result as used.
+func (ids headingIDs) unique(id string) string {
+	u := id
+	for i := 1; ids[u]; i++ {
+		u = fmt.Sprintf("%s-%d", id, i)
+	}
+	ids[u] = true
+	return u
 }
 
 // isMarkdown reports whether filename says that the file contains markdown.
@@ -181,10 +266,18 @@ func translateRelativeLink(dest string, mi *internal.ModuleInfo, useRaw bool, re
 	}
 	// Paths are relative to the README location.
 	destPath := path.Join(path.Dir(readme.Filepath), path.Clean(destURL.Path))
+	var u string
 	if useRaw {
-		return mi.SourceInfo.RawURL(destPath)
+		u = mi.SourceInfo.RawURL(destPath)
+	} else {
+		u = mi.SourceInfo.FileURL(destPath)
+	}
+	if u == "" {
+		// We don't know how to link to the repository, so link to our copy of
+		// the file.
+		u = fileProxyURL(mi, destPath)
 	}
-	return mi.SourceInfo.FileURL(destPath)
+	return u
 }
 
 // translateHTML parses html text into parsed html nodes. It then
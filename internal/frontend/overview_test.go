@@ -309,7 +309,7 @@ func TestReadmeHTML(t *testing.T) {
 		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
-			got := readmeHTML(ctx, tc.mi, tc.readme)
+			got, _ := readmeHTML(ctx, tc.mi, tc.readme)
 			if diff := cmp.Diff(tc.want, got); diff != "" {
 				t.Errorf("readmeHTML(%v) mismatch (-want +got):\n%s", tc.mi, diff)
 			}
@@ -317,6 +317,71 @@ func TestReadmeHTML(t *testing.T) {
 	}
 }
 
+func TestReadmeOutline(t *testing.T) {
+	ctx := context.Background()
+	mi := &internal.ModuleInfo{}
+	for _, tc := range []struct {
+		name        string
+		contents    string
+		wantHTML    template.HTML
+		wantOutline []*ReadmeHeading
+	}{
+		{
+			name: "headings",
+			contents: "# The `foo` Package\n\n" +
+				"## Install & Use\n\n" +
+				"### Usage\n\n" +
+				"#### Details\n\n" +
+				"## Usage\n\n" +
+				"## Custom {#my-id}\n",
+			wantHTML: template.HTML(`<h1 id="the-foo-package">The <code>foo</code> Package</h1>` + "\n\n" +
+				`<h2 id="install--use">Install &amp; Use</h2>` + "\n\n" +
+				`<h3 id="usage">Usage</h3>` + "\n\n" +
+				`<h4 id="details">Details</h4>` + "\n\n" +
+				`<h2 id="usage-1">Usage</h2>` + "\n\n" +
+				`<h2 id="my-id">Custom</h2>` + "\n"),
+			wantOutline: []*ReadmeHeading{
+				{Level: 1, Text: "The foo Package", ID: "the-foo-package"},
+				{Level: 2, Text: "Install & Use", ID: "install--use"},
+				{Level: 3, Text: "Usage", ID: "usage"},
+				{Level: 2, Text: "Usage", ID: "usage-1"},
+				{Level: 2, Text: "Custom", ID: "my-id"},
+			},
+		},
+		{
+			name:     "too few headings",
+			contents: "# One\n\n## Two\n",
+			wantHTML: template.HTML(`<h1 id="one">One</h1>` + "\n\n" + `<h2 id="two">Two</h2>` + "\n"),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			gotHTML, gotOutline := readmeHTML(ctx, mi, &internal.Readme{Filepath: "README.md", Contents: tc.contents})
+			if diff := cmp.Diff(tc.wantHTML, gotHTML); diff != "" {
+				t.Errorf("HTML mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantOutline, gotOutline); diff != "" {
+				t.Errorf("outline mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHeadingSlug(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"Getting Started", "getting-started"},
+		{"What's new in v1.2?", "whats-new-in-v12"},
+		{"snake_case and kebab-case", "snake_case-and-kebab-case"},
+		{"Über", "über"},
+		{"!!!", "heading"},
+	} {
+		if got := headingSlug(test.in); got != test.want {
+			t.Errorf("headingSlug(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
 func TestPackageSubdir(t *testing.T) {
 	for _, test := range []struct {
 		pkgPath, modulePath string
@@ -92,6 +92,9 @@ func (s *Server) legacyServePackagePageWithPackage(ctx context.Context, w http.R
 	if err != nil {
 		return fmt.Errorf("creating package header for %s@%s: %v", pkg.Path, pkg.Version, err)
 	}
+	if err := addPackageLicenseAttribution(ctx, s.ds, pkgHeader, &pkg.ModuleInfo); err != nil {
+		return err
+	}
 
 	tab := r.FormValue("tab")
 	settings, ok := packageTabLookup[tab]
@@ -126,6 +129,9 @@ func (s *Server) legacyServePackagePageWithPackage(ctx context.Context, w http.R
 		CanShowDetails: canShowDetails,
 		Tabs:           packageTabSettings,
 		PageType:       "pkg",
+		RepoStatus:     fetchRepoStatus(ctx, s.ds, pkg.SourceInfo),
+		ModuleRedirect: fetchModuleRedirect(ctx, s.ds, pkg.ModulePath),
+		Vulns:          fetchVulnsForVersion(ctx, s.ds, pkg.ModulePath, pkg.Version, pkgHeader.Module.LinkVersion),
 	}
 	s.servePage(ctx, w, settings.TemplateName, page)
 	return nil
@@ -170,12 +176,12 @@ func (s *Server) servePackagePageNew(w http.ResponseWriter, r *http.Request, ful
 		}
 		return pathFoundAtLatestError(ctx, "package", fullPath, inVersion)
 	}
-	vdir, err := s.ds.GetDirectoryNew(ctx, fullPath, modulePath, version)
+	um, err := s.ds.GetUnitMeta(ctx, fullPath, modulePath, version)
 	if err != nil {
 		return err
 	}
-	if vdir.Package != nil {
-		return s.servePackagePageWithVersionedDirectory(ctx, w, r, vdir, inVersion)
+	if um.IsPackage() {
+		return s.servePackagePageWithUnitMeta(ctx, w, r, um, inVersion)
 	}
 	dir, err := s.ds.LegacyGetDirectory(ctx, fullPath, modulePath, version, internal.AllFields)
 	if err != nil {
@@ -206,18 +212,15 @@ func (s *Server) stdlibPathForShortcut(ctx context.Context, shortcut string) (pa
 	return "", nil
 }
 
-func (s *Server) servePackagePageWithVersionedDirectory(ctx context.Context,
-	w http.ResponseWriter, r *http.Request, vdir *internal.VersionedDirectory, requestedVersion string) error {
-	pkgHeader, err := createPackageNew(vdir, requestedVersion == internal.LatestVersion)
-	if err != nil {
-		return fmt.Errorf("creating package header for %s@%s: %v", vdir.Path, vdir.Version, err)
-	}
-
+// servePackagePageWithUnitMeta serves the page for the package described by
+// um, reading only the fields of the package that the requested tab renders.
+func (s *Server) servePackagePageWithUnitMeta(ctx context.Context,
+	w http.ResponseWriter, r *http.Request, um *internal.UnitMeta, requestedVersion string) error {
 	tab := r.FormValue("tab")
 	settings, ok := packageTabLookup[tab]
 	if !ok {
 		var tab string
-		if vdir.DirectoryNew.IsRedistributable {
+		if um.DirectoryMeta.IsRedistributable {
 			tab = "doc"
 		} else {
 			tab = "overview"
@@ -225,6 +228,18 @@ func (s *Server) servePackagePageWithVersionedDirectory(ctx context.Context,
 		http.Redirect(w, r, fmt.Sprintf(r.URL.Path+"?tab=%s", tab), http.StatusFound)
 		return nil
 	}
+	vdir, err := s.ds.GetUnit(ctx, um, settings.UnitFields)
+	if err != nil {
+		return err
+	}
+	pkgHeader, err := createPackageNew(vdir, requestedVersion == internal.LatestVersion)
+	if err != nil {
+		return fmt.Errorf("creating package header for %s@%s: %v", vdir.Path, vdir.Version, err)
+	}
+	if err := addPackageLicenseAttribution(ctx, s.ds, pkgHeader, &vdir.ModuleInfo); err != nil {
+		return err
+	}
+
 	canShowDetails := vdir.DirectoryNew.IsRedistributable || settings.AlwaysShowDetails
 
 	var details interface{}
@@ -246,6 +261,9 @@ func (s *Server) servePackagePageWithVersionedDirectory(ctx context.Context,
 		CanShowDetails: canShowDetails,
 		Tabs:           packageTabSettings,
 		PageType:       "pkg",
+		RepoStatus:     fetchRepoStatus(ctx, s.ds, vdir.SourceInfo),
+		ModuleRedirect: fetchModuleRedirect(ctx, s.ds, vdir.ModulePath),
+		Vulns:          fetchVulnsForVersion(ctx, s.ds, vdir.ModulePath, vdir.Version, pkgHeader.Module.LinkVersion),
 	}
 	s.servePage(ctx, w, settings.TemplateName, page)
 	return nil
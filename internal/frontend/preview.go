@@ -0,0 +1,159 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// previewPath is the path of the component preview page, which is served
+// only in dev mode.
+const previewPath = "/dev/preview"
+
+// A previewComponent is a template that the preview page can render on its
+// own, with fixture data.
+type previewComponent struct {
+	Name        string // name of the template
+	Description string
+	// data returns the fixture data for the template, given the request.
+	data func(r *http.Request) interface{}
+}
+
+// previewComponents are the components listed on the preview page.
+var previewComponents = []*previewComponent{
+	{
+		Name:        "search_snippet",
+		Description: "a search result, grouped with other packages of its module",
+		data: func(*http.Request) interface{} {
+			return &SearchResult{
+				Name:           "cmp",
+				PackagePath:    "github.com/google/go-cmp/cmp",
+				ModulePath:     "github.com/google/go-cmp",
+				Synopsis:       "Package cmp determines equality of values.",
+				DisplayVersion: "v0.5.2",
+				Licenses:       []string{"BSD-3-Clause"},
+				CommitTime:     "Aug 18, 2020",
+				NumImportedBy:  12345,
+				NumVulns:       1,
+				SecurityURL:    "/mod/github.com/google/go-cmp?tab=security",
+				Owner:          "github.com/google",
+				OwnerSearchURL: "/search?q=cmp+owner%3Agithub.com%2Fgoogle",
+				SameModule: []*SearchResult{
+					{PackagePath: "github.com/google/go-cmp/cmp/cmpopts", Synopsis: "Package cmpopts provides common options for the cmp package."},
+					{PackagePath: "github.com/google/go-cmp/cmp/internal/diff"},
+				},
+				OtherModuleResults: 3,
+			}
+		},
+	},
+	{
+		Name:        "module_list",
+		Description: "the versions of a module, by major version",
+		data: func(*http.Request) interface{} {
+			return []*VersionList{
+				{
+					VersionListKey: VersionListKey{ModulePath: "github.com/go-redis/redis/v7", Major: "v7"},
+					Versions: []*VersionSummary{
+						{DisplayVersion: "v7.4.0", TooltipVersion: "v7.4.0", CommitTime: "Jun 6, 2020", PublishTime: "Jun 7, 2020", Link: "/github.com/go-redis/redis/v7@v7.4.0"},
+						{DisplayVersion: "v7.3.0", TooltipVersion: "v7.3.0", CommitTime: "May 21, 2020", Link: "/github.com/go-redis/redis/v7@v7.3.0", LicenseChanged: true},
+						{DisplayVersion: "v7.0.0-beta.4", TooltipVersion: "v7.0.0-beta.4", CommitTime: "Sep 16, 2019", Link: "/github.com/go-redis/redis/v7@v7.0.0-beta.4"},
+					},
+				},
+				{
+					VersionListKey: VersionListKey{ModulePath: "std", Major: "go1"},
+					Versions: []*VersionSummary{
+						{DisplayVersion: "go1.15.2", TooltipVersion: "v1.15.2", CommitTime: "Sep 9, 2020", Link: "/std@go1.15.2", ReleaseNotesURL: "https://golang.org/doc/devel/release.html#go1.15.minor"},
+					},
+				},
+			}
+		},
+	},
+	{
+		Name:        "pagination_nav",
+		Description: "the summary and navigation of a page of results",
+		data: func(r *http.Request) interface{} {
+			params := newPaginationParams(r, 10)
+			if params.page == 1 {
+				params.page = 4
+			}
+			return newPagination(params, 10, 95)
+		},
+	},
+	{
+		Name:        "license_change",
+		Description: "the notice of a license change between versions",
+		data: func(*http.Request) interface{} {
+			return &internal.LicenseChange{
+				ModulePath:      "example.com/mod",
+				Version:         "v1.3.0",
+				PreviousVersion: "v1.2.0",
+				Added:           []string{"Apache-2.0"},
+				Removed:         []string{"MIT"},
+			}
+		},
+	},
+	{
+		Name:        "directories",
+		Description: "the table of the directories of a module",
+		data: func(*http.Request) interface{} {
+			return []*Package{
+				{PathAfterDirectory: "cmp (root)", URL: "/github.com/google/go-cmp/cmp", Synopsis: "Package cmp determines equality of values."},
+				{PathAfterDirectory: "cmpopts", URL: "/github.com/google/go-cmp/cmp/cmpopts", Synopsis: "Package cmpopts provides common options for the cmp package."},
+				{PathAfterDirectory: "internal/diff", URL: "/github.com/google/go-cmp/cmp/internal/diff"},
+			}
+		},
+	},
+	{
+		Name:        "empty_content",
+		Description: "the message shown when a tab has nothing to show",
+		data: func(*http.Request) interface{} {
+			return "No documentation available for this package!"
+		},
+	},
+}
+
+// previewPage is the data for the component preview page.
+type previewPage struct {
+	basePage
+	Components []*previewComponent
+	// Component is the name of the component being previewed, if any, and
+	// Data is its fixture data.
+	Component string
+	Data      interface{}
+}
+
+// servePreview serves a page that lists the components of previewComponents
+// and renders the one named by the "component" query parameter with its
+// fixture data. Since templates are parsed again when they change in dev
+// mode, editing a component and reloading the page shows the change.
+func (s *Server) servePreview(w http.ResponseWriter, r *http.Request) error {
+	page := previewPage{
+		basePage:   s.newBasePage(r, "Component preview"),
+		Components: previewComponents,
+	}
+	if name := r.FormValue("component"); name != "" {
+		var c *previewComponent
+		for _, pc := range previewComponents {
+			if pc.Name == name {
+				c = pc
+				break
+			}
+		}
+		if c == nil {
+			return &serverError{
+				status: http.StatusNotFound,
+				err:    fmt.Errorf("unknown component %q: %w", name, derrors.NotFound),
+			}
+		}
+		page.Component = c.Name
+		page.Data = c.data(r)
+	}
+	s.servePage(r.Context(), w, "preview.tmpl", page)
+	return nil
+}
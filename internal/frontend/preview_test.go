@@ -0,0 +1,132 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPreviewServer(t *testing.T, staticPath string) http.Handler {
+	t.Helper()
+	s, err := NewServer(ServerConfig{
+		StaticPath:     staticPath,
+		ThirdPartyPath: "../../third_party",
+		DevMode:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.Install(mux.Handle, nil)
+	return mux
+}
+
+func TestPreview(t *testing.T) {
+	handler := newPreviewServer(t, "../../content/static")
+	for _, test := range []struct {
+		component string
+		want      string
+	}{
+		{"search_snippet", `class="SearchSnippet"`},
+		{"module_list", `class="Versions-list"`},
+		{"pagination_nav", `class="Pagination-nav"`},
+		{"license_change", `class="LicenseChange"`},
+		{"directories", `class="Directories"`},
+		{"empty_content", `class="EmptyContent-message"`},
+	} {
+		t.Run(test.component, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", previewPath+"?component="+test.component, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if !strings.Contains(w.Body.String(), test.want) {
+				t.Errorf("body does not contain %q", test.want)
+			}
+		})
+	}
+	if got, want := len(previewComponents), 6; got != want {
+		t.Errorf("%d components, but %d are tested", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", previewPath+"?component=nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown component: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestPreviewOnlyInDevMode(t *testing.T) {
+	s, err := NewServer(ServerConfig{StaticPath: "../../content/static"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Install(func(pattern string, _ http.Handler) {
+		if pattern == previewPath {
+			t.Errorf("%s is served outside dev mode", previewPath)
+		}
+	}, nil)
+}
+
+func TestTemplateReload(t *testing.T) {
+	// Copy the templates, so that the test can change them.
+	staticPath := t.TempDir()
+	src := "../../content/static/html"
+	if err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(staticPath, "html", rel)
+		if info.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(dst, data, 0644)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	handler := newPreviewServer(t, staticPath)
+	get := func() string {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", previewPath+"?component=empty_content", nil))
+		return w.Body.String()
+	}
+	if body := get(); strings.Contains(body, "EmptyContent-changed") {
+		t.Fatal("template changed before it was edited")
+	}
+
+	file := filepath.Join(staticPath, "html", "helpers", "_empty_content.tmpl")
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), "EmptyContent-message", "EmptyContent-changed", 1))
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Make sure the modification time changes, whatever the resolution of
+	// the file system.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if body := get(); !strings.Contains(body, "EmptyContent-changed") {
+		t.Error("edited template was not reloaded")
+	}
+}
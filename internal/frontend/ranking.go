@@ -0,0 +1,227 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// rankingPathPrefix is the prefix of the paths of ranking report pages.
+const rankingPathPrefix = "/about/ranking/"
+
+// RankingPage contains the data for the ranking report of a module.
+type RankingPage struct {
+	basePage
+	ModulePath string
+	// Excluded reports whether the module is excluded from the site, and so
+	// from search results.
+	Excluded bool
+	Packages []*RankedPackage
+	// Suggestions are changes to the module that would improve the ranking
+	// of its packages.
+	Suggestions []string
+}
+
+// RankedPackage is the row of a package in a ranking report.
+type RankedPackage struct {
+	Path    string
+	URL     string
+	Version string
+	Signals []*RankingSignal
+	// Quality is the product of the factors of the signals.
+	Quality string
+}
+
+// RankingSignal describes one factor of the search score of a package.
+type RankingSignal struct {
+	Name  string
+	Value string
+	// Factor is what the signal multiplies the score by, or the empty string
+	// if the signal affects the relevance of the package to queries instead.
+	Factor string
+	// Penalized reports whether the signal lowers the score.
+	Penalized bool
+}
+
+// serveRankingReport serves a page that shows the owner of a module the
+// signals that affect the search ranking of its packages, independently of
+// the query. The URL has the form /about/ranking/<module-path>.
+func (s *Server) serveRankingReport(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db, ok := s.ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	modulePath := strings.TrimPrefix(r.URL.Path, rankingPathPrefix)
+	if modulePath == "" {
+		return &serverError{status: http.StatusBadRequest}
+	}
+	excluded, err := db.IsExcluded(ctx, modulePath)
+	if err != nil {
+		return err
+	}
+	page := &RankingPage{
+		basePage:   s.newBasePage(r, fmt.Sprintf("Search ranking of %s", modulePath)),
+		ModulePath: modulePath,
+		Excluded:   excluded,
+	}
+	if !excluded {
+		signals, err := db.GetRankingSignals(ctx, modulePath)
+		if err != nil {
+			return err
+		}
+		if len(signals) == 0 {
+			return &serverError{
+				status: http.StatusNotFound,
+				epage: &errorPage{
+					messageTemplate: `
+						<h3 class="Error-message">{{.}} has no packages in search results.</h3>
+						<p class="Error-message">Check that the module path is correct, and that the module has been fetched.</p>`,
+					MessageData: modulePath,
+				},
+			}
+		}
+		page.Packages, page.Suggestions = rankedPackages(modulePath, signals)
+	}
+	s.servePage(ctx, w, "ranking.tmpl", page)
+	return nil
+}
+
+// rankedPackages returns the rows of a ranking report for the signals of the
+// packages of modulePath, and the suggestions for improving them.
+func rankedPackages(modulePath string, signals []*postgres.RankingSignals) ([]*RankedPackage, []string) {
+	var (
+		pkgs                                  []*RankedPackage
+		notRedistributable, noGoMod, inactive bool
+		noReadme, moved                       bool
+		noSynopsis, undocumented              []string
+	)
+	for _, rs := range signals {
+		p := &RankedPackage{
+			Path:    rs.PackagePath,
+			URL:     "/" + rs.PackagePath,
+			Version: rs.Version,
+			Quality: formatFactor(rs.Quality),
+			Signals: []*RankingSignal{
+				{
+					Name:   "Imported by",
+					Value:  fmt.Sprintf("%d packages", rs.ImportedByCount),
+					Factor: formatFactor(rs.PopularityFactor),
+				},
+				{
+					Name:      "Redistributable license",
+					Value:     yesNo(rs.Redistributable),
+					Factor:    formatFactor(rs.RedistributableFactor),
+					Penalized: !rs.Redistributable,
+				},
+				{
+					Name:      "go.mod file",
+					Value:     yesNo(rs.HasGoMod),
+					Factor:    formatFactor(rs.GoModFactor),
+					Penalized: !rs.HasGoMod,
+				},
+				{
+					Name:      "Active repository",
+					Value:     yesNo(!rs.RepoInactive),
+					Factor:    formatFactor(rs.RepoFactor),
+					Penalized: rs.RepoInactive,
+				},
+				{
+					Name:      "Documented",
+					Value:     coverageValue(rs.DocumentationCoverage),
+					Factor:    formatFactor(rs.DocCoverageFactor),
+					Penalized: rs.DocumentationCoverage != nil && *rs.DocumentationCoverage < 1,
+				},
+				{
+					Name:      "Current module path",
+					Value:     yesNo(!rs.ModuleMoved),
+					Factor:    formatFactor(rs.MovedFactor),
+					Penalized: rs.ModuleMoved,
+				},
+				{
+					Name:      "Synopsis",
+					Value:     yesNo(rs.HasSynopsis),
+					Penalized: !rs.HasSynopsis,
+				},
+			},
+		}
+		if rs.PackagePath == modulePath {
+			// Only the search document of the package at the root of the
+			// module includes the README.
+			p.Signals = append(p.Signals, &RankingSignal{
+				Name:      "README",
+				Value:     yesNo(rs.HasReadme),
+				Penalized: !rs.HasReadme,
+			})
+			noReadme = !rs.HasReadme
+		}
+		pkgs = append(pkgs, p)
+		notRedistributable = notRedistributable || !rs.Redistributable
+		noGoMod = noGoMod || !rs.HasGoMod
+		inactive = inactive || rs.RepoInactive
+		moved = moved || rs.ModuleMoved
+		if !rs.HasSynopsis {
+			noSynopsis = append(noSynopsis, rs.PackagePath)
+		}
+		if rs.DocumentationCoverage != nil && *rs.DocumentationCoverage < 1 {
+			undocumented = append(undocumented, rs.PackagePath)
+		}
+	}
+	var suggestions []string
+	if notRedistributable {
+		suggestions = append(suggestions,
+			"Add a license that is recognized as redistributable.")
+	}
+	if noGoMod {
+		suggestions = append(suggestions,
+			"Add a go.mod file to the module, and publish a new version.")
+	}
+	if inactive {
+		suggestions = append(suggestions,
+			"The repository of the module is archived or deleted. If the module is maintained elsewhere, publish it from there.")
+	}
+	if moved {
+		suggestions = append(suggestions,
+			"The module moved to a new module path, which ranks above it. Publish new versions at the new path.")
+	}
+	if noReadme {
+		suggestions = append(suggestions,
+			"Add a README to the root of the module. Its text is searched along with the synopsis of the root package.")
+	}
+	if len(noSynopsis) > 0 {
+		suggestions = append(suggestions,
+			fmt.Sprintf("Add a package comment to %s. Its first sentence is the synopsis, which weighs more in search than the README.",
+				strings.Join(noSynopsis, ", ")))
+	}
+	if len(undocumented) > 0 {
+		suggestions = append(suggestions,
+			fmt.Sprintf("Add doc comments to the exported identifiers of %s.", strings.Join(undocumented, ", ")))
+	}
+	return pkgs, suggestions
+}
+
+// coverageValue formats a documentation coverage for a ranking report.
+func coverageValue(c *float64) string {
+	if c == nil {
+		return "Unknown"
+	}
+	return formatCoverage(c)
+}
+
+// formatFactor formats a factor of the search score.
+func formatFactor(f float64) string {
+	return fmt.Sprintf("×%.2f", f)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
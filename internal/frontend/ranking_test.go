@@ -0,0 +1,86 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+func TestRankedPackages(t *testing.T) {
+	const modulePath = "example.com/mod"
+	half := 0.5
+	signals := []*postgres.RankingSignals{
+		{
+			PackagePath:           modulePath,
+			Version:               "v1.0.0",
+			ImportedByCount:       3,
+			Redistributable:       true,
+			HasGoMod:              false,
+			HasSynopsis:           true,
+			HasReadme:             true,
+			PopularityFactor:      1.75,
+			RedistributableFactor: 1,
+			GoModFactor:           0.8,
+			RepoFactor:            1,
+			DocCoverageFactor:     1,
+			MovedFactor:           1,
+			Quality:               1.4,
+		},
+		{
+			PackagePath:           modulePath + "/pkg",
+			Version:               "v1.0.0",
+			Redistributable:       true,
+			HasGoMod:              false,
+			DocumentationCoverage: &half,
+			PopularityFactor:      1,
+			RedistributableFactor: 1,
+			GoModFactor:           0.8,
+			RepoFactor:            1,
+			DocCoverageFactor:     0.9,
+			MovedFactor:           1,
+			Quality:               0.72,
+		},
+	}
+	pkgs, suggestions := rankedPackages(modulePath, signals)
+
+	want := &RankedPackage{
+		Path:    modulePath,
+		URL:     "/example.com/mod",
+		Version: "v1.0.0",
+		Quality: "×1.40",
+		Signals: []*RankingSignal{
+			{Name: "Imported by", Value: "3 packages", Factor: "×1.75"},
+			{Name: "Redistributable license", Value: "Yes", Factor: "×1.00"},
+			{Name: "go.mod file", Value: "No", Factor: "×0.80", Penalized: true},
+			{Name: "Active repository", Value: "Yes", Factor: "×1.00"},
+			{Name: "Documented", Value: "Unknown", Factor: "×1.00"},
+			{Name: "Current module path", Value: "Yes", Factor: "×1.00"},
+			{Name: "Synopsis", Value: "Yes"},
+			{Name: "README", Value: "Yes"},
+		},
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d packages, want 2", len(pkgs))
+	}
+	if diff := cmp.Diff(want, pkgs[0]); diff != "" {
+		t.Errorf("root package mismatch (-want +got):\n%s", diff)
+	}
+	// Only the root package has a README signal.
+	if got := len(pkgs[1].Signals); got != 7 {
+		t.Errorf("got %d signals for %s, want 7", got, pkgs[1].Path)
+	}
+
+	wantSuggestions := []string{
+		"Add a go.mod file to the module, and publish a new version.",
+		"Add a package comment to example.com/mod/pkg. Its first sentence is the synopsis, which weighs more in search than the README.",
+		"Add doc comments to the exported identifiers of example.com/mod/pkg.",
+	}
+	if diff := cmp.Diff(wantSuggestions, suggestions); diff != "" {
+		t.Errorf("suggestions mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/version"
+)
+
+// robotsNoIndex is the content of the robots meta tag of pages that crawlers
+// should neither index nor follow the links of.
+const robotsNoIndex = "noindex, nofollow"
+
+// robotsTxt returns the contents of robots.txt for settings. Search results
+// are never crawled.
+func robotsTxt(settings config.CrawlSettings) string {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	b.WriteString("Disallow: /search?*\n")
+	for _, d := range settings.Disallow {
+		b.WriteString("Disallow: " + d + "\n")
+	}
+	return b.String()
+}
+
+// robotsHandler serves the robots.txt for settings.
+func robotsHandler(settings config.CrawlSettings) http.Handler {
+	body := robotsTxt(settings)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	})
+}
+
+// metaRobots returns the content of the robots meta tag of the page served for
+// r, or the empty string if crawlers may index it. Pages past
+// settings.MaxIndexedPage of a paginated list, and the pages of
+// pseudo-versions if settings.NoIndexPseudoVersions is set, are not indexed:
+// they are as expensive to serve as any other, but rarely what a searcher
+// is looking for.
+func metaRobots(r *http.Request, settings config.CrawlSettings) string {
+	if settings.MaxIndexedPage > 0 {
+		if p, err := strconv.Atoi(r.FormValue("page")); err == nil && p > settings.MaxIndexedPage {
+			return robotsNoIndex
+		}
+	}
+	if settings.NoIndexPseudoVersions && isPseudoVersionPath(r.URL.Path) {
+		return robotsNoIndex
+	}
+	return ""
+}
+
+// isPseudoVersionPath reports whether urlPath requests a pseudo-version, as in
+// "/example.com/mod@v0.0.0-20200101000000-abcdefabcdef/pkg".
+func isPseudoVersionPath(urlPath string) bool {
+	i := strings.IndexByte(urlPath, '@')
+	if i < 0 {
+		return false
+	}
+	v := urlPath[i+1:]
+	if j := strings.IndexByte(v, '/'); j >= 0 {
+		v = v[:j]
+	}
+	return version.IsPseudo(strings.TrimSuffix(v, "+incompatible"))
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/config"
+)
+
+func TestRobotsTxt(t *testing.T) {
+	got := robotsTxt(config.CrawlSettings{Disallow: []string{"/*?tab=importedby"}})
+	want := "User-agent: *\nDisallow: /search?*\nDisallow: /*?tab=importedby\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMetaRobots(t *testing.T) {
+	settings := config.CrawlSettings{MaxIndexedPage: 2, NoIndexPseudoVersions: true}
+	for _, test := range []struct {
+		url  string
+		want string
+	}{
+		{"/net/http", ""},
+		{"/example.com/mod?tab=versions&page=2", ""},
+		{"/example.com/mod?tab=versions&page=3", robotsNoIndex},
+		{"/example.com/mod?tab=versions&page=x", ""},
+		{"/example.com/mod@v1.2.3/pkg", ""},
+		{"/example.com/mod@v0.0.0-20200101000000-abcdefabcdef/pkg", robotsNoIndex},
+		{"/mod/example.com/mod@v2.0.1-0.20200101000000-abcdefabcdef+incompatible", robotsNoIndex},
+	} {
+		r := httptest.NewRequest("GET", test.url, nil)
+		if got := metaRobots(r, settings); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.url, got, test.want)
+		}
+	}
+	r := httptest.NewRequest("GET", "/example.com/mod@v0.0.0-20200101000000-abcdefabcdef?page=3", nil)
+	if got := metaRobots(r, config.CrawlSettings{}); got != "" {
+		t.Errorf("with no settings: got %q, want empty", got)
+	}
+}
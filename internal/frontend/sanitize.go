@@ -0,0 +1,92 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"golang.org/x/pkgsite/internal/middleware"
+)
+
+// contentPolicy is the allowlist of the HTML of documentation and READMEs.
+// That HTML comes from modules: READMEs are written by their authors, and
+// documentation, although rendered by the worker, carries doc comments and
+// links from the module, and is stored in the database long before it is
+// served. Both are third-party content, and every page renders them through
+// sanitizeHTML.
+//
+// The policy allows the markup of READMEs, and the elements, classes and
+// attributes that the dochtml package generates. Changes to the HTML of
+// dochtml must be reflected here, or they will be stripped.
+var contentPolicy = newContentPolicy()
+
+// contentClass matches the class attributes that dochtml generates. Other
+// classes are stripped, so that content cannot take on the style of the
+// site's own elements.
+var contentClass = func() *regexp.Regexp {
+	const class = `(?:(?:Documentation|TypesAndFuncs)(?:-[A-Za-z-]+)?|comment)`
+	return regexp.MustCompile(`^` + class + `(?: ` + class + `)*$`)
+}()
+
+func newContentPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	// id, title, lang and dir, on any element.
+	p.AllowStandardAttributes()
+	p.AllowAttrs("class").Matching(contentClass).Globally()
+	p.AllowAttrs("aria-label").Matching(bluemonday.Paragraph).Globally()
+	p.AllowAttrs("aria-labelledby").Matching(bluemonday.SpaceSeparatedTokens).Globally()
+	// Identifiers in declarations, and the headers of functions, types and
+	// methods, are marked with their kind, for the jump to identifier dialog.
+	p.AllowAttrs("data-kind").Matching(regexp.MustCompile(`^[a-z]+$`)).OnElements("span", "h3", "h4")
+
+	// Only http, https and mailto URLs, and relative URLs, which must parse.
+	p.AllowStandardURLs()
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("cite").OnElements("blockquote", "q")
+	p.AllowImages()
+	// Links to other sites are marked nofollow, but not those to this site,
+	// like the links of documentation to other packages. This must come
+	// after AllowStandardURLs and AllowImages, which mark all links.
+	p.RequireNoFollowOnLinks(false)
+	p.RequireNoFollowOnFullyQualifiedLinks(true)
+
+	p.AllowElements("article", "aside", "figure", "figcaption", "nav", "section", "summary")
+	p.AllowAttrs("open").Matching(regexp.MustCompile(`(?i)^(|open)$`)).OnElements("details")
+	p.AllowElements("h1", "h2", "h3", "h4", "h5", "h6", "hgroup")
+	p.AllowElements("br", "div", "hr", "p", "span", "wbr", "blockquote", "pre")
+	p.AllowElements("abbr", "b", "cite", "code", "del", "dfn", "em", "i", "ins", "kbd",
+		"mark", "q", "s", "samp", "small", "strike", "strong", "sub", "sup", "tt", "u", "var")
+	p.AllowLists()
+	p.AllowTables()
+
+	// Allow width and align attributes on img, div, and p tags.
+	// This is used to center elements in a readme as well as to size it
+	// images appropriately where used, like the gin-gonic/logo/color.png
+	// image in the github.com/gin-gonic/gin README.
+	p.AllowAttrs("width", "align").OnElements("img", "div", "p")
+	return p
+}
+
+// escapedNoncePlaceholder is middleware.NoncePlaceholder, escaped so that it
+// is displayed as is but no longer matches.
+var escapedNoncePlaceholder = []byte(strings.ReplaceAll(middleware.NoncePlaceholder, "$", "&#36;"))
+
+// sanitizeHTML returns the HTML of documentation or a README, from a module,
+// with only the elements and attributes of contentPolicy. Scripts, styles,
+// event handlers, forms, frames and URLs with schemes other than http, https
+// and mailto are removed.
+func sanitizeHTML(b []byte) template.HTML {
+	b = contentPolicy.SanitizeBytes(b)
+	// SecureHeaders only replaces the nonce placeholder in nonce attributes,
+	// which the policy removes, but escape it anyway, so that content can
+	// never be given the nonce of the page it is on.
+	b = bytes.ReplaceAll(b, []byte(middleware.NoncePlaceholder), escapedNoncePlaceholder)
+	return template.HTML(b)
+}
@@ -0,0 +1,255 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/pkgsite/internal/fetch"
+	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/testhelper"
+)
+
+func TestSanitizeHTMLXSS(t *testing.T) {
+	f, err := os.Open("testdata/xss.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	forbiddenElements := map[string]bool{
+		"audio": true, "base": true, "bgsound": true, "body": true,
+		"button": true, "embed": true, "form": true, "frame": true,
+		"frameset": true, "iframe": true, "input": true, "link": true,
+		"marquee": true, "math": true, "meta": true, "noscript": true,
+		"object": true, "script": true, "select": true, "source": true,
+		"style": true, "svg": true, "template": true, "textarea": true,
+		"title": true, "video": true, "xmp": true,
+	}
+	forbiddenAttrs := map[string]bool{
+		"background": true, "data-url": true, "formaction": true,
+		"nonce": true, "srcdoc": true, "style": true,
+		"target": true, "xlink:href": true,
+	}
+	urlAttrs := map[string]bool{"action": true, "cite": true, "href": true, "src": true}
+	forbiddenSchemes := []string{"javascript:", "vbscript:", "data:"}
+
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	n := 0
+	for scanner.Scan() {
+		vector := scanner.Text()
+		if vector == "" || strings.HasPrefix(vector, "#") {
+			continue
+		}
+		n++
+		got := string(sanitizeHTML([]byte(vector)))
+		if strings.Contains(got, middleware.NoncePlaceholder) {
+			t.Errorf("%s\nsanitized to %s\ncontains the nonce placeholder", vector, got)
+		}
+		nodes, err := html.ParseFragment(strings.NewReader(got), body)
+		if err != nil {
+			t.Fatalf("%s: %v", vector, err)
+		}
+		var check func(*html.Node)
+		check = func(node *html.Node) {
+			if node.Type == html.ElementNode {
+				if forbiddenElements[node.Data] {
+					t.Errorf("%s\nsanitized to %s\nhas element %q", vector, got, node.Data)
+				}
+				for _, a := range node.Attr {
+					key := strings.ToLower(a.Key)
+					if a.Namespace != "" {
+						key = a.Namespace + ":" + key
+					}
+					if forbiddenAttrs[key] || strings.HasPrefix(key, "on") {
+						t.Errorf("%s\nsanitized to %s\nhas attribute %q", vector, got, key)
+					}
+					if key == "rel" && a.Val != "nofollow" {
+						t.Errorf("%s\nsanitized to %s\nhas rel %q", vector, got, a.Val)
+					}
+					if key == "class" && !contentClass.MatchString(a.Val) {
+						t.Errorf("%s\nsanitized to %s\nhas class %q", vector, got, a.Val)
+					}
+					if urlAttrs[key] {
+						// Browsers ignore whitespace and control characters in
+						// URLs.
+						u := strings.ToLower(strings.Map(func(r rune) rune {
+							if r <= ' ' {
+								return -1
+							}
+							return r
+						}, a.Val))
+						for _, s := range forbiddenSchemes {
+							if strings.HasPrefix(u, s) {
+								t.Errorf("%s\nsanitized to %s\nhas %s URL %q", vector, got, s, a.Val)
+							}
+						}
+					}
+				}
+			}
+			for c := node.FirstChild; c != nil; c = c.NextSibling {
+				check(c)
+			}
+		}
+		for _, node := range nodes {
+			check(node)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Fatal("no vectors in testdata/xss.txt")
+	}
+}
+
+func TestSanitizeHTMLKeepsDocumentation(t *testing.T) {
+	// Markup like that of the dochtml package, which must not be changed.
+	for _, doc := range []string{
+		`<nav class="Documentation-nav" aria-label="Package documentation"><a class="Documentation-skipLink" href="#pkg-index">Skip</a></nav>`,
+		`<section class="Documentation-index" aria-labelledby="pkg-index"><h3 id="pkg-index" class="Documentation-indexHeader">Index <a href="#pkg-index" aria-label="Go to Index">¶</a></h3></section>`,
+		`<li class="Documentation-tocItem Documentation-tocItem--constants"><a href="#pkg-constants">Constants</a></li>`,
+		`<details class="Documentation-exampleDetails" id="example-Foo"><summary class="Documentation-exampleDetailsHeader">Example</summary></details>`,
+		`<pre><span class="comment">// A comment.</span>
+func <span id="Foo" data-kind="function"></span>Foo(r <a href="/io?tab=doc#Reader">io.Reader</a>)</pre>`,
+		`<h4 id="hdr-Heading" title="Heading">Heading</h4>`,
+		`<ul class="Documentation-noteList"><li>Note.</li></ul>`,
+		`<div class="TypesAndFuncs-item TypesAndFuncs-item--noBorder"><p>Text <a href="/x">link</a>.</p></div>`,
+	} {
+		if got := string(sanitizeHTML([]byte(doc))); got != doc {
+			t.Errorf("sanitizeHTML(%s)\n= %s\nwant it unchanged", doc, got)
+		}
+	}
+}
+
+func TestSanitizeHTMLKeepsRenderedDocumentation(t *testing.T) {
+	ctx := context.Background()
+	const modulePath = "github.com/sanitize/doc"
+	proxyClient, teardown := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: modulePath,
+			Files: map[string]string{
+				"LICENSE": testhelper.MITLicense,
+				"doc.go": `// Package doc has every kind of declaration.
+//
+// Heading
+//
+// Text with a link to https://golang.org.
+//
+//	code
+package doc
+
+import "io"
+
+// C is a constant.
+const C = 1
+
+// V is a variable.
+var V = 2
+
+// F is a function.
+func F(r io.Reader) {}
+
+// T is a type.
+type T struct{ X int }
+
+// NewT returns a T.
+func NewT() *T { return nil }
+
+// M is a method.
+func (T) M() {}
+
+// BUG(someone): a note.
+`,
+				"example_test.go": `package doc_test
+
+func ExampleF() {
+	// Output:
+}
+
+func ExampleT_M() {}
+`,
+			},
+		},
+	})
+	defer teardown()
+
+	res := fetch.FetchModule(ctx, modulePath, "v1.0.0", proxyClient, source.NewClient(time.Second))
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Module.LegacyPackages) == 0 {
+		t.Fatal("no packages")
+	}
+	for _, p := range res.Module.LegacyPackages {
+		doc := p.DocumentationHTML
+		if doc == "" {
+			t.Fatalf("%s: no documentation", p.Path)
+		}
+		// The sanitizer writes attributes in its own way, like open="" for
+		// open, so compare the documents as parsed. The only change it may
+		// make is to mark links to other sites nofollow.
+		want := renderParsedHTML(t, doc, false)
+		got := renderParsedHTML(t, string(sanitizeHTML([]byte(doc))), true)
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("%s: sanitizeHTML changed the documentation (-want +got):\n%s", p.Path, diff)
+		}
+	}
+}
+
+// renderParsedHTML parses the HTML fragment s and renders it again. If
+// dropNoFollow is true, it removes the rel="nofollow" attributes of links to
+// other sites.
+func renderParsedHTML(t *testing.T, s string, dropNoFollow bool) string {
+	t.Helper()
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(s), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fix func(*html.Node)
+	fix = func(n *html.Node) {
+		if dropNoFollow && n.Type == html.ElementNode && n.DataAtom == atom.A {
+			var href string
+			for _, a := range n.Attr {
+				if a.Key == "href" {
+					href = a.Val
+				}
+			}
+			if strings.HasPrefix(href, "http://") || strings.HasPrefix(href, "https://") {
+				var attrs []html.Attribute
+				for _, a := range n.Attr {
+					if a.Key != "rel" || a.Val != "nofollow" {
+						attrs = append(attrs, a)
+					}
+				}
+				n.Attr = attrs
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			fix(c)
+		}
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		fix(n)
+		if err := html.Render(&b, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.String()
+}
@@ -6,21 +6,30 @@ package frontend
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"path"
 	"strings"
 
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/xcontext"
 )
 
 const defaultSearchLimit = 10
 
+// searchPackagesPerModule is the maximum number of packages of a module that
+// are shown when search results are grouped by module.
+const searchPackagesPerModule = 5
+
 // SearchPage contains all of the data that the search template needs to
 // populate.
 type SearchPage struct {
@@ -40,30 +49,49 @@ type SearchResult struct {
 	CommitTime     string
 	NumImportedBy  uint64
 	Approximate    bool
+
+	// NumVulns is the number of known vulnerabilities of the version, and
+	// SecurityURL is the URL of the security tab of its module.
+	NumVulns    int
+	SecurityURL string
+
+	// Owner is the owner of the module on a code hosting site, like
+	// github.com/spf13, and OwnerSearchURL is the URL of the same search
+	// restricted to that owner.
+	Owner          string
+	OwnerSearchURL string
+
+	// SameModule are other packages of the module of the result that matched
+	// the search, when results are grouped by module. OtherModuleResults is
+	// the number of packages of the module that matched but are not shown.
+	SameModule         []*SearchResult
+	OtherModuleResults int
 }
 
 // fetchSearchPage fetches data matching the search query from the database and
-// returns a SearchPage.
+// returns a SearchPage. If the search-grouping experiment is active, the
+// results are grouped by module, and the page is a page of modules.
 func fetchSearchPage(ctx context.Context, db *postgres.DB, query string, pageParams paginationParams) (*SearchPage, error) {
-	dbresults, err := db.Search(ctx, query, pageParams.limit, pageParams.offset())
+	grouped := experiment.IsActive(ctx, internal.ExperimentSearchGrouping)
+	var (
+		dbresults []*internal.SearchResult
+		err       error
+	)
+	if grouped {
+		dbresults, err = db.SearchGrouped(ctx, query, pageParams.limit, pageParams.offset(), searchPackagesPerModule)
+	} else {
+		dbresults, err = db.Search(ctx, query, pageParams.limit, pageParams.offset())
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	var results []*SearchResult
-	for _, r := range dbresults {
-		results = append(results, &SearchResult{
-			Name:           r.Name,
-			PackagePath:    r.PackagePath,
-			ModulePath:     r.ModulePath,
-			Synopsis:       r.Synopsis,
-			DisplayVersion: displayVersion(r.Version, r.ModulePath),
-			Licenses:       r.Licenses,
-			CommitTime:     elapsedTime(r.CommitTime),
-			NumImportedBy:  r.NumImportedBy,
-		})
+	results := newSearchResults(dbresults, grouped)
+	for _, r := range results {
+		if r.Owner != "" {
+			r.OwnerSearchURL = "/search?q=" + url.QueryEscape(query+" owner:"+r.Owner)
+		}
 	}
-
 	var (
 		numResults  int
 		approximate bool
@@ -86,6 +114,45 @@ func fetchSearchPage(ctx context.Context, db *postgres.DB, query string, pagePar
 	}, nil
 }
 
+// newSearchResults returns the SearchResults for dbresults. If grouped is
+// true, dbresults are grouped by module as returned by
+// postgres.DB.SearchGrouped, and each group becomes a single SearchResult
+// whose SameModule holds the rest of the group.
+func newSearchResults(dbresults []*internal.SearchResult, grouped bool) []*SearchResult {
+	var results []*SearchResult
+	for _, r := range dbresults {
+		sr := newSearchResult(r)
+		if grouped {
+			if n := len(results); n > 0 && results[n-1].ModulePath == r.ModulePath {
+				first := results[n-1]
+				first.SameModule = append(first.SameModule, sr)
+				first.OtherModuleResults--
+				continue
+			}
+			sr.OtherModuleResults = int(r.NumModuleResults) - 1
+		}
+		results = append(results, sr)
+	}
+	return results
+}
+
+// newSearchResult returns the SearchResult for r.
+func newSearchResult(r *internal.SearchResult) *SearchResult {
+	return &SearchResult{
+		Name:           r.Name,
+		PackagePath:    r.PackagePath,
+		ModulePath:     r.ModulePath,
+		Synopsis:       r.Synopsis,
+		DisplayVersion: displayVersion(r.Version, r.ModulePath),
+		Licenses:       r.Licenses,
+		CommitTime:     elapsedTime(r.CommitTime),
+		NumImportedBy:  r.NumImportedBy,
+		NumVulns:       r.NumVulns,
+		SecurityURL:    constructModuleURL(r.ModulePath, linkVersion(r.Version, r.ModulePath)) + "?tab=security",
+		Owner:          r.Owner,
+	}
+}
+
 // approximateNumber returns an approximation of the estimate, calibrated by
 // the statistical estimate of standard error.
 // i.e., a number that isn't misleading when we say '1-10 of approximately N
@@ -100,8 +167,10 @@ func approximateNumber(estimate int, sigma float64) int {
 }
 
 // serveSearch applies database data to the search template. Handles endpoint
-// /search?q=<query>. If <query> is an exact match for a package path, the user
-// will be redirected to the details page.
+// /search?q=<query>. The query is searched according to its intent, as
+// classified by classifySearchQuery. If <query> is an exact match for a
+// package path, or a URL for one, the user will be redirected to the details
+// page.
 func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request) error {
 	db, ok := s.ds.(*postgres.DB)
 	if !ok {
@@ -116,13 +185,30 @@ func (s *Server) serveSearch(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
-	if path := searchRequestRedirectPath(ctx, s.ds, query); path != "" {
-		http.Redirect(w, r, path, http.StatusFound)
-		return nil
+	intent, searchTerms := classifySearchQuery(query)
+	entry := searchIntentLogEntry{Query: query, Intent: intent, SearchQuery: searchTerms}
+	if intent == intentURL || intent == intentPath {
+		if path := searchRequestRedirectPath(ctx, s.ds, searchTerms); path != "" {
+			entry.Redirect = path
+			logSearchIntent(ctx, entry)
+			http.Redirect(w, r, path, http.StatusFound)
+			return nil
+		}
 	}
-	page, err := fetchSearchPage(ctx, db, query, newPaginationParams(r, defaultSearchLimit))
+	page, err := fetchSearchPage(ctx, db, searchTerms, newPaginationParams(r, defaultSearchLimit))
 	if err != nil {
-		return fmt.Errorf("fetchSearchPage(ctx, db, %q): %v", query, err)
+		return fmt.Errorf("fetchSearchPage(ctx, db, %q): %v", searchTerms, err)
+	}
+	entry.NumResults = page.Pagination.TotalCount
+	logSearchIntent(ctx, entry)
+	if s.searchQuerySampleRate > 0 && rand.Float64() < s.searchQuerySampleRate {
+		// Record the query in the background, so that searches are not slowed
+		// down by the write.
+		go func() {
+			if err := db.RecordSearchQuery(xcontext.Detach(ctx), query, page.Pagination.TotalCount); err != nil {
+				log.Error(ctx, err)
+			}
+		}()
 	}
 	page.basePage = s.newBasePage(r, query)
 	s.servePage(ctx, w, "search.tmpl", page)
@@ -182,3 +268,105 @@ func searchRequestRedirectPath(ctx context.Context, ds internal.DataSource, quer
 func searchQuery(r *http.Request) string {
 	return strings.TrimSpace(r.FormValue("q"))
 }
+
+// searchAPIPath is the path of the search API endpoint.
+const searchAPIPath = "/api/v1/search"
+
+// maxSearchAPILimit is the maximum number of results in a response from the
+// search API.
+const maxSearchAPILimit = 100
+
+// searchAPIResponse is the response of the search API. It holds the same
+// results as the search page.
+type searchAPIResponse struct {
+	Query string `json:"query"`
+	// Total is the number of results: the number of modules if results are
+	// grouped by module, and the number of packages otherwise.
+	Total       int                `json:"total"`
+	Approximate bool               `json:"approximate,omitempty"`
+	Results     []*searchAPIResult `json:"results"`
+}
+
+// searchAPIResult is a module, with the packages of the module that matched
+// the search.
+type searchAPIResult struct {
+	ModulePath string              `json:"module_path"`
+	Version    string              `json:"version"`
+	Owner      string              `json:"owner,omitempty"`
+	Packages   []*searchAPIPackage `json:"packages"`
+	// MorePackages is the number of packages of the module that matched but
+	// are not in Packages.
+	MorePackages int `json:"more_packages,omitempty"`
+}
+
+// searchAPIPackage is a package in a searchAPIResult.
+type searchAPIPackage struct {
+	Path       string `json:"path"`
+	Name       string `json:"name"`
+	Synopsis   string `json:"synopsis"`
+	ImportedBy uint64 `json:"imported_by"`
+}
+
+// serveSearchAPI serves the results of a search as JSON. The URL has the form
+//
+//	/api/v1/search?q=<query>[&page=<page>][&limit=<limit>]
+//
+// Unlike /search, it never redirects.
+func (s *Server) serveSearchAPI(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db, ok := s.ds.(*postgres.DB)
+	if !ok {
+		return proxydatasourceNotSupportedErr()
+	}
+	query := searchQuery(r)
+	if query == "" {
+		return &serverError{status: http.StatusBadRequest, detail: "missing query"}
+	}
+	pageParams := newPaginationParams(r, defaultSearchLimit)
+	if pageParams.limit > maxSearchAPILimit {
+		pageParams.limit = maxSearchAPILimit
+	}
+	page, err := fetchSearchPage(ctx, db, query, pageParams)
+	if err != nil {
+		return fmt.Errorf("fetchSearchPage(ctx, db, %q): %v", query, err)
+	}
+	body, err := json.Marshal(newSearchAPIResponse(query, page))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		log.Errorf(ctx, "Error writing search results to ResponseWriter: %v", err)
+	}
+	return nil
+}
+
+// newSearchAPIResponse returns the search API response for page.
+func newSearchAPIResponse(query string, page *SearchPage) *searchAPIResponse {
+	resp := &searchAPIResponse{
+		Query:       query,
+		Total:       page.Pagination.TotalCount,
+		Approximate: page.Pagination.Approximate,
+		// Serve an empty array rather than null, like the vulnerabilities
+		// API.
+		Results: []*searchAPIResult{},
+	}
+	for _, r := range page.Results {
+		res := &searchAPIResult{
+			ModulePath:   r.ModulePath,
+			Version:      r.DisplayVersion,
+			Owner:        r.Owner,
+			MorePackages: r.OtherModuleResults,
+		}
+		for _, p := range append([]*SearchResult{r}, r.SameModule...) {
+			res.Packages = append(res.Packages, &searchAPIPackage{
+				Path:       p.PackagePath,
+				Name:       p.Name,
+				Synopsis:   p.Synopsis,
+				ImportedBy: p.NumImportedBy,
+			})
+		}
+		resp.Results = append(resp.Results, res)
+	}
+	return resp
+}
@@ -96,6 +96,7 @@ func TestFetchSearchPage(t *testing.T) {
 						Licenses:       []string{"MIT"},
 						CommitTime:     elapsedTime(moduleBar.CommitTime),
 						NumImportedBy:  0,
+						SecurityURL:    constructModuleURL(moduleBar.ModulePath, moduleBar.Version) + "?tab=security",
 					},
 				},
 			},
@@ -124,6 +125,7 @@ func TestFetchSearchPage(t *testing.T) {
 						Licenses:       []string{"MIT"},
 						CommitTime:     elapsedTime(moduleFoo.CommitTime),
 						NumImportedBy:  0,
+						SecurityURL:    constructModuleURL(moduleFoo.ModulePath, moduleFoo.Version) + "?tab=security",
 					},
 				},
 			},
@@ -155,6 +157,46 @@ func TestFetchSearchPage(t *testing.T) {
 	}
 }
 
+func TestNewSearchResultsGrouped(t *testing.T) {
+	dbresults := []*internal.SearchResult{
+		{PackagePath: "a.com/m/p1", ModulePath: "a.com/m", Version: "v1.0.0", NumModuleResults: 5},
+		{PackagePath: "a.com/m/p2", ModulePath: "a.com/m", Version: "v1.0.0", NumModuleResults: 5},
+		{PackagePath: "b.com/n/p", ModulePath: "b.com/n", Version: "v1.0.0", NumModuleResults: 1},
+	}
+	got := newSearchResults(dbresults, true)
+	if len(got) != 2 {
+		t.Fatalf("got %d results, want 2", len(got))
+	}
+	if len(got[0].SameModule) != 1 || got[0].SameModule[0].PackagePath != "a.com/m/p2" || got[0].OtherModuleResults != 3 {
+		t.Errorf("a.com/m: got SameModule %v and OtherModuleResults %d, want [a.com/m/p2] and 3", got[0].SameModule, got[0].OtherModuleResults)
+	}
+	if len(got[1].SameModule) != 0 || got[1].OtherModuleResults != 0 {
+		t.Errorf("b.com/n: got SameModule %v and OtherModuleResults %d, want none", got[1].SameModule, got[1].OtherModuleResults)
+	}
+
+	resp := newSearchAPIResponse("q", &SearchPage{Results: got, Pagination: pagination{TotalCount: 2}})
+	want := &searchAPIResponse{
+		Query: "q",
+		Total: 2,
+		Results: []*searchAPIResult{
+			{
+				ModulePath:   "a.com/m",
+				Version:      "v1.0.0",
+				Packages:     []*searchAPIPackage{{Path: "a.com/m/p1"}, {Path: "a.com/m/p2"}},
+				MorePackages: 3,
+			},
+			{
+				ModulePath: "b.com/n",
+				Version:    "v1.0.0",
+				Packages:   []*searchAPIPackage{{Path: "b.com/n/p"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, resp); diff != "" {
+		t.Errorf("newSearchAPIResponse mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestApproximateNumber(t *testing.T) {
 	tests := []struct {
 		estimate int
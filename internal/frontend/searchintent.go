@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A searchIntent is the kind of thing that a search query looks for. It
+// decides how the query is searched.
+type searchIntent string
+
+const (
+	// intentURL is the URL of a repository or of a documentation page, like
+	// https://github.com/a/b or https://pkg.go.dev/net/http. It is searched
+	// like the path it contains.
+	intentURL searchIntent = "url"
+	// intentPath is an import or module path, like golang.org/x/net/html. It
+	// redirects to the details page of the path if there is one.
+	intentPath searchIntent = "path"
+	// intentSymbol is a symbol qualified by a package name, like http.Client.
+	// The package name and the symbol are searched as separate words.
+	intentSymbol searchIntent = "symbol"
+	// intentText is anything else: keywords or natural language. It is
+	// searched as it is.
+	intentText searchIntent = "text"
+)
+
+// docHosts are the hosts of documentation sites whose URLs have an import
+// path as their path.
+var docHosts = map[string]bool{
+	"pkg.go.dev":    true,
+	"godoc.org":     true,
+	"www.godoc.org": true,
+}
+
+// symbolRegexp matches a package name followed by an exported symbol, and
+// optionally by a field or method of that symbol. Requiring the symbol to be
+// exported distinguishes it from a domain name like gopkg.in.
+var symbolRegexp = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)\.([A-Z][a-zA-Z0-9_]*)(?:\.([a-zA-Z_][a-zA-Z0-9_]*))?$`)
+
+// classifySearchQuery returns the intent of query, and the query that should
+// be searched for it.
+func classifySearchQuery(query string) (searchIntent, string) {
+	if len(strings.Fields(query)) != 1 {
+		return intentText, query
+	}
+	if strings.Contains(query, "://") {
+		if p := urlSearchPath(query); p != "" {
+			return intentURL, p
+		}
+		return intentText, query
+	}
+	if m := symbolRegexp.FindStringSubmatch(query); m != nil {
+		return intentSymbol, strings.TrimSpace(strings.Join(m[1:], " "))
+	}
+	if strings.Contains(query, "/") {
+		return intentPath, query
+	}
+	return intentText, query
+}
+
+// urlSearchPath returns the path that the URL u refers to, or the empty string
+// if u is not a valid URL. For the URL of a documentation site, that is the
+// path of the page, without a version. For other URLs, like those of
+// repositories, it is the host and path of the URL.
+func urlSearchPath(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil || pu.Host == "" {
+		return ""
+	}
+	host := strings.ToLower(pu.Host)
+	p := strings.Trim(pu.Path, "/")
+	if docHosts[host] {
+		p = strings.TrimPrefix(p, "mod/")
+		if i := strings.IndexByte(p, '@'); i >= 0 {
+			p = p[:i]
+		}
+	} else {
+		p = strings.TrimSuffix(host+"/"+p, "/")
+		p = strings.TrimSuffix(p, ".git")
+	}
+	return p
+}
+
+// searchIntentLogEntry is logged for every search, so that the decisions of
+// classifySearchQuery can be evaluated offline together with the results
+// they led to.
+type searchIntentLogEntry struct {
+	Query       string
+	Intent      searchIntent
+	SearchQuery string
+	// Redirect is the path that the search redirected to, if any.
+	Redirect   string `json:",omitempty"`
+	NumResults int
+}
+
+// logSearchIntent logs the intent that a search query was classified with,
+// and what it led to.
+func logSearchIntent(ctx context.Context, entry searchIntentLogEntry) {
+	log.SetRequestLabel(ctx, "search_intent", string(entry.Intent))
+	log.Info(ctx, entry)
+}
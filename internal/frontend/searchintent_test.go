@@ -0,0 +1,37 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import "testing"
+
+func TestClassifySearchQuery(t *testing.T) {
+	for _, test := range []struct {
+		query      string
+		wantIntent searchIntent
+		wantSearch string
+	}{
+		{"https://github.com/a/b", intentURL, "github.com/a/b"},
+		{"https://github.com/a/b.git", intentURL, "github.com/a/b"},
+		{"http://GitHub.com/a/b/", intentURL, "github.com/a/b"},
+		{"https://pkg.go.dev/golang.org/x/net/html@v0.1.0", intentURL, "golang.org/x/net/html"},
+		{"https://pkg.go.dev/mod/golang.org/x/net", intentURL, "golang.org/x/net"},
+		{"https://godoc.org/net/http", intentURL, "net/http"},
+		{"https://", intentText, "https://"},
+		{"golang.org/x/net/html", intentPath, "golang.org/x/net/html"},
+		{"cmd/go", intentPath, "cmd/go"},
+		{"http.Client", intentSymbol, "http Client"},
+		{"http.Client.Do", intentSymbol, "http Client Do"},
+		{"gopkg.in", intentText, "gopkg.in"},
+		{"errors", intentText, "errors"},
+		{"http client", intentText, "http client"},
+		{"how to parse yaml", intentText, "how to parse yaml"},
+		{"http.Client retry", intentText, "http.Client retry"},
+	} {
+		gotIntent, gotSearch := classifySearchQuery(test.query)
+		if gotIntent != test.wantIntent || gotSearch != test.wantSearch {
+			t.Errorf("classifySearchQuery(%q) = (%q, %q), want (%q, %q)", test.query, gotIntent, gotSearch, test.wantIntent, test.wantSearch)
+		}
+	}
+}
@@ -7,11 +7,11 @@ package frontend
 import (
 	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -19,18 +19,29 @@ import (
 
 	"github.com/go-redis/redis/v7"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/assets"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/i18n"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/queue"
+	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/text/language"
 )
 
 // Server can be installed to serve the go discovery frontend.
 type Server struct {
 	ds    internal.DataSource
 	queue queue.Queue
+	// proxyClient is used to serve the files of modules. It may be nil.
+	proxyClient *proxy.Client
+	// filesZips holds the module zips read to serve files.
+	filesZips *filesZipCache
 	// cmplClient is a redis client that has access to the "completions" sorted
 	// set.
 	cmplClient           *redis.Client
@@ -38,45 +49,92 @@ type Server struct {
 	staticPath           string
 	thirdPartyPath       string
 	templateDir          string
-	devMode              bool
-	errorPage            []byte
-	appVersionLabel      string
-
-	mu        sync.Mutex // Protects all fields below
-	templates map[string]*template.Template
+	// basePath is the path under which the site is served, without a
+	// trailing slash, or empty if it is served at the root of its host.
+	basePath string
+	// assets maps the static CSS and JavaScript files to their fingerprinted
+	// paths. It is nil in dev mode.
+	assets          assets.Manifest
+	devMode         bool
+	errorPage       []byte
+	appVersionLabel string
+	// searchQuerySampleRate is the fraction of searches that are recorded
+	// in the search_queries table.
+	searchQuerySampleRate float64
+	// missingPages records details pages that are known not to exist. It is
+	// nil if there is no cache.
+	missingPages *cache.Cache
+	// crawl steers crawlers away from expensive pages.
+	crawl config.CrawlSettings
+
+	mu sync.Mutex // Protects all fields below
+	// catalog holds the translations of the templates.
+	catalog *i18n.Catalog
+	// templates holds the template of each page, by language and then by
+	// name.
+	templates map[language.Tag]map[string]*template.Template
+	// templatesModTime is the latest modification time of the template
+	// files when templates were parsed. It is only set in dev mode.
+	templatesModTime time.Time
 }
 
 // ServerConfig contains everything needed by a Server.
 type ServerConfig struct {
 	DataSource           internal.DataSource
 	Queue                queue.Queue
+	ProxyClient          *proxy.Client
 	CompletionClient     *redis.Client
 	TaskIDChangeInterval time.Duration
 	StaticPath           string
 	ThirdPartyPath       string
-	DevMode              bool
-	AppVersionLabel      string
+	// BasePath is the path under which the site is served, like /godoc, or
+	// empty to serve it at the root of its host. Requests must reach the
+	// server with BasePath stripped from their paths, as by
+	// middleware.BasePath.
+	BasePath        string
+	DevMode         bool
+	AppVersionLabel string
+	// SearchQuerySampleRate is the fraction of searches that are recorded
+	// for analysis.
+	SearchQuerySampleRate float64
+	// Crawl configures robots.txt and the robots meta tags of pages.
+	Crawl config.CrawlSettings
 }
 
 // NewServer creates a new Server for the given database and template directory.
 func NewServer(scfg ServerConfig) (_ *Server, err error) {
 	defer derrors.Wrap(&err, "NewServer(...)")
 	templateDir := filepath.Join(scfg.StaticPath, "html")
-	ts, err := parsePageTemplates(templateDir)
+	basePath := strings.TrimSuffix(scfg.BasePath, "/")
+	if basePath != "" && !strings.HasPrefix(basePath, "/") {
+		return nil, fmt.Errorf("base path %q does not start with a slash", scfg.BasePath)
+	}
+	manifest, err := readAssets(scfg.StaticPath, scfg.DevMode)
+	if err != nil {
+		return nil, err
+	}
+	cat, ts, err := loadTemplates(templateDir, basePath, manifest)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing templates: %v", err)
+		return nil, err
 	}
 	s := &Server{
-		ds:                   scfg.DataSource,
-		queue:                scfg.Queue,
-		cmplClient:           scfg.CompletionClient,
-		staticPath:           scfg.StaticPath,
-		thirdPartyPath:       scfg.ThirdPartyPath,
-		templateDir:          templateDir,
-		devMode:              scfg.DevMode,
-		templates:            ts,
-		taskIDChangeInterval: scfg.TaskIDChangeInterval,
-		appVersionLabel:      scfg.AppVersionLabel,
+		ds:                    scfg.DataSource,
+		queue:                 scfg.Queue,
+		proxyClient:           scfg.ProxyClient,
+		filesZips:             newFilesZipCache(),
+		cmplClient:            scfg.CompletionClient,
+		staticPath:            scfg.StaticPath,
+		thirdPartyPath:        scfg.ThirdPartyPath,
+		templateDir:           templateDir,
+		basePath:              basePath,
+		assets:                manifest,
+		devMode:               scfg.DevMode,
+		catalog:               cat,
+		templates:             ts,
+		taskIDChangeInterval:  scfg.TaskIDChangeInterval,
+		searchQuerySampleRate: scfg.SearchQuerySampleRate,
+		appVersionLabel:       scfg.AppVersionLabel,
+		crawl:                 scfg.Crawl,
 	}
 	errorPageBytes, err := s.renderErrorPage(context.Background(), http.StatusInternalServerError, "error.tmpl", nil)
 	if err != nil {
@@ -93,28 +151,37 @@ func (s *Server) Install(handle func(string, http.Handler), redisClient *redis.C
 		searchHandler http.Handler = s.errorHandler(s.serveSearch)
 	)
 	if redisClient != nil {
-		detailHandler = middleware.Cache("details", redisClient, detailsTTL)(detailHandler)
-		searchHandler = middleware.Cache("search", redisClient, middleware.TTL(defaultTTL))(searchHandler)
-	}
-	handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir(s.staticPath))))
+		c := cache.New(redisClient)
+		s.missingPages = c
+		detailHandler = middleware.Cache("details", c, detailsTTL, staleTTL, detailsPageType)(detailHandler)
+		searchHandler = middleware.Cache("search", c, middleware.TTL(defaultTTL), staleTTL, nil)(searchHandler)
+	}
+	// Coalesce outside the cache, so that identical requests that miss it
+	// are rendered, and written to it, once.
+	detailHandler = middleware.Coalesce()(detailHandler)
+	searchHandler = middleware.Coalesce()(searchHandler)
+	handle("/static/", s.staticHandler())
 	handle("/third_party/", http.StripPrefix("/third_party", http.FileServer(http.Dir(s.thirdPartyPath))))
 	handle("/favicon.ico", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, fmt.Sprintf("%s/img/favicon.ico", http.Dir(s.staticPath)))
 	}))
-	handle("/fetch/", http.HandlerFunc(s.fetchHandler))
+	handle("/fetch/", s.errorHandler(s.fetchHandler))
 	handle("/pkg/", http.HandlerFunc(s.handlePackageDetailsRedirect))
+	handle("/files/", s.errorHandler(s.serveFile))
 	handle("/search", searchHandler)
 	handle("/search-help", s.staticPageHandler("search_help.tmpl", "Search Help - go.dev"))
 	handle("/license-policy", s.licensePolicyHandler())
 	handle("/about", http.RedirectHandler("https://go.dev/about", http.StatusFound))
+	handle(rankingPathPrefix, s.errorHandler(s.serveRankingReport))
 	handle("/", detailHandler)
-	handle("/autocomplete", http.HandlerFunc(s.handleAutoCompletion))
-	handle("/robots.txt", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(`User-agent: *
-Disallow: /search?*
-`))
-	}))
+	handle("/autocomplete", s.errorHandler(s.handleAutoCompletion))
+	handle(vulnsAPIPath, s.errorHandler(s.serveVulnsAPI))
+	handle(searchAPIPath, s.errorHandler(s.serveSearchAPI))
+	handle(breakingChangesAPIPath, s.errorHandler(s.serveBreakingChangesAPI))
+	handle("/robots.txt", robotsHandler(s.crawl))
+	if s.devMode {
+		handle(previewPath, s.errorHandler(s.servePreview))
+	}
 }
 
 const (
@@ -126,8 +193,31 @@ const (
 	shortTTL = 10 * time.Minute
 	// longTTL is used when details content is essentially static.
 	longTTL = 24 * time.Hour
+	// staleTTL is how long a page is served from the cache after its TTL,
+	// while it is rendered again.
+	staleTTL = 5 * time.Minute
+
+	// missingTTL is how long a details page is remembered not to exist.
+	// Records of missing pages are removed when the worker processes their
+	// module, so this only bounds how long a module that appears in some other
+	// way, or while the page was being looked up, can go unseen.
+	missingTTL = 5 * time.Minute
 )
 
+// detailsPageType classifies details requests for the cache metrics.
+func detailsPageType(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/":
+		return "home"
+	case strings.HasPrefix(r.URL.Path, "/mod/"):
+		return "module"
+	case stdlib.Contains(strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "@", 2)[0]):
+		return "std"
+	default:
+		return "unit"
+	}
+}
+
 // detailsTTL assigns the cache TTL for package detail requests.
 func detailsTTL(r *http.Request) time.Duration {
 	return detailsTTLForPath(r.Context(), r.URL.Path, r.FormValue("tab"))
@@ -190,6 +280,8 @@ type basePage struct {
 	GodocURL        string
 	DevMode         bool
 	AppVersionLabel string
+	// MetaRobots is the content of the robots meta tag, if the page has one.
+	MetaRobots string
 }
 
 // licensePolicyPage is used to generate the static license policy page.
@@ -221,6 +313,7 @@ func (s *Server) newBasePage(r *http.Request, title string) basePage {
 		GodocURL:        middleware.GodocURLPlaceholder,
 		DevMode:         s.devMode,
 		AppVersionLabel: s.appVersionLabel,
+		MetaRobots:      metaRobots(r, s.crawl),
 	}
 }
 
@@ -229,128 +322,6 @@ func (b basePage) GoogleTagManagerContainerID() string {
 	return "GTM-W8MVQXG"
 }
 
-// errorPage contains fields for rendering a HTTP error page.
-type errorPage struct {
-	basePage
-	templateName    string
-	messageTemplate string
-	MessageData     interface{}
-}
-
-// PanicHandler returns an http.HandlerFunc that can be used in HTTP
-// middleware. It returns an error if something goes wrong pre-rendering the
-// error template.
-func (s *Server) PanicHandler() (_ http.HandlerFunc, err error) {
-	defer derrors.Wrap(&err, "PanicHandler")
-	status := http.StatusInternalServerError
-	buf, err := s.renderErrorPage(context.Background(), status, "error.tmpl", nil)
-	if err != nil {
-		return nil, err
-	}
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(status)
-		if _, err := io.Copy(w, bytes.NewReader(buf)); err != nil {
-			log.Errorf(r.Context(), "Error copying panic template to ResponseWriter: %v", err)
-		}
-	}, nil
-}
-
-type serverError struct {
-	status int // HTTP status code
-	epage  *errorPage
-	err    error // wrapped error
-}
-
-func (s *serverError) Error() string {
-	return fmt.Sprintf("%d (%s): %v (epage=%v)", s.status, http.StatusText(s.status), s.err, s.epage)
-}
-
-func (s *serverError) Unwrap() error {
-	return s.err
-}
-
-func (s *Server) errorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if err := f(w, r); err != nil {
-			s.serveError(w, r, err)
-		}
-	}
-}
-
-func (s *Server) serveError(w http.ResponseWriter, r *http.Request, err error) {
-	ctx := r.Context()
-	var serr *serverError
-	if !errors.As(err, &serr) {
-		serr = &serverError{status: http.StatusInternalServerError, err: err}
-	}
-	if serr.status == http.StatusInternalServerError {
-		log.Error(ctx, err)
-	} else {
-		log.Infof(ctx, "returning %d (%s) for error %v", serr.status, http.StatusText(serr.status), err)
-	}
-	s.serveErrorPage(w, r, serr.status, serr.epage)
-}
-
-func (s *Server) serveErrorPage(w http.ResponseWriter, r *http.Request, status int, page *errorPage) {
-	template := "error.tmpl"
-	if page == nil {
-		page = &errorPage{
-			basePage: s.newBasePage(r, ""),
-		}
-	} else if page.templateName != "" {
-		template = page.templateName
-	}
-	buf, err := s.renderErrorPage(r.Context(), status, template, page)
-	if err != nil {
-		log.Errorf(r.Context(), "s.renderErrorPage(w, %d, %v): %v", status, page, err)
-		buf = s.errorPage
-		status = http.StatusInternalServerError
-	}
-
-	w.WriteHeader(status)
-	if _, err := io.Copy(w, bytes.NewReader(buf)); err != nil {
-		log.Errorf(r.Context(), "Error copying template %q buffer to ResponseWriter: %v", template, err)
-	}
-}
-
-// renderErrorPage executes error.tmpl with the given errorPage
-func (s *Server) renderErrorPage(ctx context.Context, status int, templateName string, page *errorPage) ([]byte, error) {
-	statusInfo := fmt.Sprintf("%d %s", status, http.StatusText(status))
-	if page == nil {
-		page = &errorPage{}
-	}
-	if page.Nonce == "" {
-		page.Nonce = middleware.NoncePlaceholder
-	}
-	if page.messageTemplate == "" {
-		page.messageTemplate = `<h3 class="Error-message">{{.}}</h3>`
-	}
-	if page.MessageData == nil {
-		page.MessageData = statusInfo
-	}
-	if page.HTMLTitle == "" {
-		page.HTMLTitle = statusInfo
-	}
-	if templateName == "" {
-		templateName = "error.tmpl"
-	}
-
-	etmpl, err := s.findTemplate(templateName)
-	if err != nil {
-		return nil, err
-	}
-	tmpl, err := etmpl.Clone()
-	if err != nil {
-		return nil, err
-	}
-	_, err = tmpl.New("message").Parse(page.messageTemplate)
-	if err != nil {
-		return nil, err
-	}
-
-	return executeTemplate(ctx, templateName, tmpl, page)
-}
-
 // servePage is used to execute all templates for a *Server.
 func (s *Server) servePage(ctx context.Context, w http.ResponseWriter, templateName string, page interface{}) {
 	buf, err := s.renderPage(ctx, templateName, page)
@@ -365,33 +336,80 @@ func (s *Server) servePage(ctx context.Context, w http.ResponseWriter, templateN
 	}
 }
 
-// renderPage executes the given templateName with page.
+// renderPage executes the given templateName with page, in the language of
+// ctx.
 func (s *Server) renderPage(ctx context.Context, templateName string, page interface{}) ([]byte, error) {
-	tmpl, err := s.findTemplate(templateName)
+	tmpl, err := s.findTemplate(i18n.FromContext(ctx), templateName)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := executeTemplate(ctx, templateName, tmpl, page)
 	if err != nil {
 		return nil, err
 	}
-	return executeTemplate(ctx, templateName, tmpl, page)
+	return rebaseLinks(buf, s.basePath), nil
 }
 
-func (s *Server) findTemplate(templateName string) (*template.Template, error) {
+// findTemplate returns the template named templateName in lang, or in
+// i18n.Default if lang is not supported. In dev mode, the templates are parsed
+// again if any template file has changed since they were last parsed, so edits
+// show up on the next page load.
+func (s *Server) findTemplate(lang language.Tag, templateName string) (*template.Template, error) {
 	if s.devMode {
 		s.mu.Lock()
 		defer s.mu.Unlock()
-		var err error
-		s.templates, err = parsePageTemplates(s.templateDir)
+		modTime, err := latestModTime(s.templateDir)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing templates: %v", err)
+			return nil, err
 		}
+		if modTime.After(s.templatesModTime) {
+			cat, ts, err := loadTemplates(s.templateDir, s.basePath, s.assets)
+			if err != nil {
+				return nil, err
+			}
+			s.catalog = cat
+			s.templates = ts
+			s.templatesModTime = modTime
+		}
+	}
+	ts := s.templates[lang]
+	if ts == nil {
+		ts = s.templates[i18n.Default]
 	}
-	tmpl := s.templates[templateName]
+	tmpl := ts[templateName]
 	if tmpl == nil {
 		return nil, fmt.Errorf("BUG: s.templates[%q] not found", templateName)
 	}
 	return tmpl, nil
 }
 
+// MatchLanguage returns the supported language that best matches the
+// preferences in an Accept-Language header, for middleware.Language.
+func (s *Server) MatchLanguage(acceptLanguage string) language.Tag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.catalog.Match(acceptLanguage)
+}
+
+// latestModTime returns the latest modification time of the files in the
+// tree rooted at dir.
+func latestModTime(dir string) (time.Time, error) {
+	var latest time.Time
+	err := filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest, err
+}
+
 func executeTemplate(ctx context.Context, templateName string, tmpl *template.Template, data interface{}) ([]byte, error) {
+	start := time.Now()
+	defer func() { middleware.RecordPhase(ctx, middleware.PhaseTemplate, time.Since(start)) }()
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
 		log.Errorf(ctx, "Error executing page template %q: %v", templateName, err)
@@ -400,12 +418,33 @@ func executeTemplate(ctx context.Context, templateName string, tmpl *template.Te
 	return buf.Bytes(), nil
 }
 
+// loadTemplates loads the catalog of translations in the i18n directory of
+// templateDir, and parses the templates of templateDir for each of its
+// languages, for a site served under basePath with the static files of
+// manifest.
+func loadTemplates(templateDir, basePath string, manifest assets.Manifest) (*i18n.Catalog, map[language.Tag]map[string]*template.Template, error) {
+	cat, err := i18n.Load(filepath.Join(templateDir, "i18n"))
+	if err != nil {
+		return nil, nil, err
+	}
+	ts, err := parsePageTemplates(templateDir, cat, basePath, manifest)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing templates: %v", err)
+	}
+	return cat, ts, nil
+}
+
 // parsePageTemplates parses html templates contained in the given base
-// directory in order to generate a map of Name->*template.Template.
+// directory in order to generate a map of Name->*template.Template for each
+// language of cat.
 //
 // Separate templates are used so that certain contextual functions (e.g.
-// templateName) can be bound independently for each page.
-func parsePageTemplates(base string) (map[string]*template.Template, error) {
+// templateName) can be bound independently for each page. Each language has
+// its own copy of the templates, with the "translate" and "lang" functions
+// bound to it. {{basePath}} is basePath, for scripts that build URLs, and
+// {{static "css/stylesheet.css"}} is the URL of a static file, fingerprinted
+// with manifest if it is not nil.
+func parsePageTemplates(base string, cat *i18n.Catalog, basePath string, manifest assets.Manifest) (map[language.Tag]map[string]*template.Template, error) {
 	htmlSets := [][]string{
 		{"index.tmpl"},
 		{"error.tmpl"},
@@ -413,6 +452,8 @@ func parsePageTemplates(base string) (map[string]*template.Template, error) {
 		{"search.tmpl"},
 		{"search_help.tmpl"},
 		{"license_policy.tmpl"},
+		{"ranking.tmpl"},
+		{"preview.tmpl"},
 		{"overview.tmpl", "details.tmpl"},
 		{"subdirectories.tmpl", "details.tmpl"},
 		{"pkg_doc.tmpl", "details.tmpl"},
@@ -420,10 +461,14 @@ func parsePageTemplates(base string) (map[string]*template.Template, error) {
 		{"pkg_imports.tmpl", "details.tmpl"},
 		{"licenses.tmpl", "details.tmpl"},
 		{"versions.tmpl", "details.tmpl"},
+		{"security.tmpl", "details.tmpl"},
 		{"not_implemented.tmpl", "details.tmpl"},
 	}
 
-	templates := make(map[string]*template.Template)
+	templates := make(map[language.Tag]map[string]*template.Template)
+	for _, lang := range cat.Languages() {
+		templates[lang] = make(map[string]*template.Template)
+	}
 	for _, set := range htmlSets {
 		t, err := template.New("base.tmpl").Funcs(template.FuncMap{
 			"add": func(i, j int) int { return i + j },
@@ -436,6 +481,10 @@ func parsePageTemplates(base string) (map[string]*template.Template, error) {
 			"commaseparate": func(s []string) string {
 				return strings.Join(s, ", ")
 			},
+			"basePath":  func() string { return basePath },
+			"static":    staticURLFunc(manifest),
+			"translate": func(msg string, args ...interface{}) string { return "" },
+			"lang":      func() string { return "" },
 		}).ParseFiles(filepath.Join(base, "base.tmpl"))
 		if err != nil {
 			return nil, fmt.Errorf("ParseFiles: %v", err)
@@ -452,7 +501,26 @@ func parsePageTemplates(base string) (map[string]*template.Template, error) {
 		if _, err := t.ParseFiles(files...); err != nil {
 			return nil, fmt.Errorf("ParseFiles(%v): %v", files, err)
 		}
-		templates[set[0]] = t
+		for _, lang := range cat.Languages() {
+			lt, err := t.Clone()
+			if err != nil {
+				return nil, err
+			}
+			templates[lang][set[0]] = lt.Funcs(localizedFuncs(cat, lang))
+		}
 	}
 	return templates, nil
 }
+
+// localizedFuncs returns the template functions that depend on the language.
+// {{translate msg args...}} translates msg into lang and formats it with args,
+// like fmt.Sprintf, and {{lang}} is the BCP 47 tag of lang.
+func localizedFuncs(cat *i18n.Catalog, lang language.Tag) template.FuncMap {
+	p := cat.Printer(lang)
+	return template.FuncMap{
+		"translate": func(msg string, args ...interface{}) string {
+			return p.Sprintf(msg, args...)
+		},
+		"lang": lang.String,
+	}
+}
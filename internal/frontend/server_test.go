@@ -861,6 +861,14 @@ func TestServerErrors(t *testing.T) {
 	if err := testDB.InsertModule(ctx, sampleModule); err != nil {
 		t.Fatal(err)
 	}
+	if err := testDB.InsertRemovedPath(ctx, &postgres.RemovedPath{
+		Path:      sampleModule.ModulePath,
+		Scope:     postgres.RemovedModule,
+		Reason:    "Removed in response to a takedown request.",
+		CreatedBy: "test",
+	}); err != nil {
+		t.Fatal(err)
+	}
 	_, handler, _ := newTestServer(t, nil)
 
 	for _, test := range []struct {
@@ -869,6 +877,7 @@ func TestServerErrors(t *testing.T) {
 	}{
 		{"not found", "/invalid-page", http.StatusNotFound},
 		{"bad request", "/gocloud.dev/@latest/blob", http.StatusBadRequest},
+		{"removed", "/" + sample.PackagePath, http.StatusUnavailableForLegalReasons},
 	} {
 		t.Run(test.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
@@ -880,6 +889,86 @@ func TestServerErrors(t *testing.T) {
 	}
 }
 
+func TestPathSuggestions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer postgres.ResetTestDB(testDB, t)
+	for _, m := range []*internal.Module{
+		sample.Module("example.com/suggest", "v1.2.0", "alpha", "beta"),
+		sample.Module("example.com/suggest/v2", "v2.0.0", "alpha"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, handler, _ := newTestServer(t, nil)
+
+	for _, test := range []struct {
+		path string
+		want []string
+	}{
+		{"/example.com/suggest/v3/alpha", []string{
+			`href="/example.com/suggest/v2/alpha"`,
+			`href="/example.com/suggest/beta"`,
+		}},
+		{"/example.com/sugest/beta", []string{`href="/example.com/suggest/beta"`}},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusNotFound)
+			}
+			body := w.Body.String()
+			for _, want := range test.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderPathSuggestions(t *testing.T) {
+	s, err := NewServer(ServerConfig{StaticPath: "../../content/static"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sugs := &postgres.PathSuggestions{
+		OtherMajorVersions: []*postgres.PathSuggestion{{PackagePath: "example.com/mod/v2/pkg", Synopsis: "Package pkg is v2."}},
+		Similar:            []*postgres.PathSuggestion{{PackagePath: "example.com/mod/pkgs"}},
+	}
+	for _, templateName := range []string{"error.tmpl", "notfound.tmpl"} {
+		t.Run(templateName, func(t *testing.T) {
+			err := pathNotFoundErrorNew("example.com/mod/pkg", internal.LatestVersion)
+			if templateName == "error.tmpl" {
+				err = pathNotFoundError(context.Background(), "package", "example.com/mod/pkg", internal.LatestVersion)
+			}
+			page := err.(*serverError).epage
+			page.Suggestions = sugs
+			buf, err := s.renderErrorPage(context.Background(), http.StatusNotFound, page.templateName, page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{
+				"Other major versions",
+				`<a href="/example.com/mod/v2/pkg">example.com/mod/v2/pkg</a>`,
+				"Package pkg is v2.",
+				"Packages with similar paths",
+				`<a href="/example.com/mod/pkgs">example.com/mod/pkgs</a>`,
+			} {
+				if !strings.Contains(string(buf), want) {
+					t.Errorf("page does not contain %q", want)
+				}
+			}
+			if strings.Contains(string(buf), "Other packages in this module") {
+				t.Error("page has a heading for an empty list")
+			}
+		})
+	}
+}
+
 func mustRequest(urlPath string, t *testing.T) *http.Request {
 	t.Helper()
 	r, err := http.NewRequest(http.MethodGet, "http://localhost"+urlPath, nil)
@@ -957,10 +1046,14 @@ func newTestServer(t *testing.T, proxyModules []*proxy.TestModule, experimentNam
 		exps = append(exps, &internal.Experiment{Name: n, Rollout: 100})
 		set[n] = true
 	}
-	q := queue.NewInMemory(ctx, proxyClient, sourceClient, testDB, 1, FetchAndUpdateState, experiment.NewSet(set), "appVersionLabel")
+	process := func(ctx context.Context, modulePath, version string) (int, error) {
+		return FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, testDB, "appVersionLabel")
+	}
+	q := queue.NewInMemory(ctx, queue.NewLimiter(1), process, experiment.NewSet(set))
 	s, err := NewServer(ServerConfig{
 		DataSource:           testDB,
 		Queue:                q,
+		ProxyClient:          proxyClient,
 		TaskIDChangeInterval: 10 * time.Minute,
 		StaticPath:           "../../content/static",
 		ThirdPartyPath:       "../../third_party",
@@ -985,3 +1078,53 @@ func newTestServer(t *testing.T, proxyModules []*proxy.TestModule, experimentNam
 		postgres.ResetTestDB(testDB, t)
 	}
 }
+
+func TestLanguages(t *testing.T) {
+	s, err := NewServer(ServerConfig{
+		StaticPath:     "../../content/static",
+		ThirdPartyPath: "../../third_party",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	s.Install(mux.Handle, nil)
+	handler := middleware.Language(s.MatchLanguage)(mux)
+
+	for _, test := range []struct {
+		acceptLanguage string
+		want, notWant  []string
+	}{
+		{
+			acceptLanguage: "fr-CA, fr;q=0.9",
+			want:           []string{`<html lang="fr"`, "Paquets populaires", `aria-label="Rechercher un paquet"`},
+			notWant:        []string{"Popular Packages"},
+		},
+		{
+			acceptLanguage: "de",
+			want:           []string{`<html lang="en"`, "Popular Packages", `aria-label="Search for a package"`},
+			notWant:        []string{"Paquets populaires"},
+		},
+	} {
+		t.Run(test.acceptLanguage, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Language", test.acceptLanguage)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			body := w.Body.String()
+			for _, want := range test.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q", want)
+				}
+			}
+			for _, notWant := range test.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("body contains %q", notWant)
+				}
+			}
+		})
+	}
+}
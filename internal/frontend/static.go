@@ -0,0 +1,78 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/assets"
+)
+
+// assetCacheControl is the Cache-Control header of static files served at
+// their fingerprinted paths, which change with their contents.
+const assetCacheControl = "public, max-age=31536000, immutable"
+
+// readAssets returns the manifest of the fingerprinted static files of
+// staticPath, after checking that it is up to date, so that a fingerprinted
+// path never serves contents other than those it was computed from. In dev
+// mode, it returns nil: static files are served at their own paths, so that
+// edits show up on the next page load.
+func readAssets(staticPath string, devMode bool) (assets.Manifest, error) {
+	if devMode {
+		return nil, nil
+	}
+	m, err := assets.Read(staticPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Check(staticPath); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// staticURLFunc returns the {{static}} template function, which returns the
+// URL of a static file from its path in the static directory, like
+// css/stylesheet.css. The URL is fingerprinted if m is not nil, in which case
+// files that m does not have are an error.
+func staticURLFunc(m assets.Manifest) func(string) (string, error) {
+	return func(name string) (string, error) {
+		if m == nil {
+			return "/static/" + name, nil
+		}
+		fp, ok := m[name]
+		if !ok {
+			return "", fmt.Errorf("static file %q is not in %s", name, assets.ManifestFile)
+		}
+		return "/static/" + fp, nil
+	}
+}
+
+// staticHandler serves the files of the static directory, and the
+// fingerprinted paths of its CSS and JavaScript files, which can be cached
+// forever.
+func (s *Server) staticHandler() http.Handler {
+	files := http.StripPrefix("/static/", http.FileServer(http.Dir(s.staticPath)))
+	fingerprinted := map[string]string{}
+	for name, fp := range s.assets {
+		fingerprinted[fp] = name
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name, ok := fingerprinted[strings.TrimPrefix(r.URL.Path, "/static/")]; ok {
+			w.Header().Set("Cache-Control", assetCacheControl)
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = "/static/" + name
+			r2.URL.RawPath = ""
+			r = r2
+		}
+		files.ServeHTTP(w, r)
+	})
+}
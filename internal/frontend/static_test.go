@@ -0,0 +1,96 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/assets"
+)
+
+func TestStaticAssets(t *testing.T) {
+	const staticPath = "../../content/static"
+	s, err := NewServer(ServerConfig{
+		StaticPath:     staticPath,
+		ThirdPartyPath: "../../third_party",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := assets.Read(staticPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp := manifest["css/stylesheet.css"]
+	if fp == "" {
+		t.Fatal("css/stylesheet.css is not in the manifest")
+	}
+
+	// Pages link to the fingerprinted URL.
+	if want := `href="/static/` + fp + `"`; !strings.Contains(string(s.errorPage), want) {
+		t.Errorf("error page does not contain %s", want)
+	}
+
+	mux := http.NewServeMux()
+	s.Install(mux.Handle, nil)
+	contents, err := ioutil.ReadFile(staticPath + "/css/stylesheet.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		path             string
+		wantCode         int
+		wantCacheControl string
+	}{
+		{"/static/" + fp, http.StatusOK, assetCacheControl},
+		{"/static/css/stylesheet.css", http.StatusOK, ""},
+		{"/static/css/stylesheet.0000000000.css", http.StatusNotFound, ""},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		if w.Code != test.wantCode {
+			t.Errorf("%s: status = %d, want %d", test.path, w.Code, test.wantCode)
+			continue
+		}
+		if got := w.Header().Get("Cache-Control"); got != test.wantCacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", test.path, got, test.wantCacheControl)
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+			t.Errorf("%s: Content-Type = %q, want text/css", test.path, got)
+		}
+		if w.Body.String() != string(contents) {
+			t.Errorf("%s: body is not the contents of css/stylesheet.css", test.path)
+		}
+	}
+}
+
+func TestStaticURLFunc(t *testing.T) {
+	m := assets.Manifest{"css/stylesheet.css": "css/stylesheet.0123456789.css"}
+	for _, test := range []struct {
+		manifest assets.Manifest
+		name     string
+		want     string
+		wantErr  bool
+	}{
+		{m, "css/stylesheet.css", "/static/css/stylesheet.0123456789.css", false},
+		{m, "css/missing.css", "", true},
+		{nil, "css/stylesheet.css", "/static/css/stylesheet.css", false},
+	} {
+		got, err := staticURLFunc(test.manifest)(test.name)
+		if (err != nil) != test.wantErr {
+			t.Errorf("static(%q) with manifest %v: err = %v, want error: %t", test.name, test.manifest, err, test.wantErr)
+		}
+		if got != test.want {
+			t.Errorf("static(%q) with manifest %v = %q, want %q", test.name, test.manifest, got, test.want)
+		}
+	}
+}
@@ -34,6 +34,10 @@ type TabSettings struct {
 
 	// Disabled indicates whether a tab should be displayed as disabled.
 	Disabled bool
+
+	// UnitFields are the fields of the unit, beyond those that every page
+	// shows, that the tab renders.
+	UnitFields internal.FieldSet
 }
 
 var (
@@ -42,12 +46,14 @@ var (
 			Name:         "doc",
 			DisplayName:  "Doc",
 			TemplateName: "pkg_doc.tmpl",
+			UnitFields:   internal.WithDocumentationHTML,
 		},
 		{
 			Name:              "overview",
 			AlwaysShowDetails: true,
 			DisplayName:       "Overview",
 			TemplateName:      "overview.tmpl",
+			UnitFields:        internal.WithReadmeContents,
 		},
 		{
 			Name:              "subdirectories",
@@ -108,6 +114,12 @@ var (
 			DisplayName:  "Licenses",
 			TemplateName: "licenses.tmpl",
 		},
+		{
+			Name:              "security",
+			DisplayName:       "Security",
+			AlwaysShowDetails: true,
+			TemplateName:      "security.tmpl",
+		},
 	}
 	moduleTabLookup = make(map[string]TabSettings)
 )
@@ -145,9 +157,16 @@ func init() {
 func fetchDetailsForPackage(ctx context.Context, r *http.Request, tab string, ds internal.DataSource, pkg *internal.LegacyVersionedPackage) (interface{}, error) {
 	switch tab {
 	case "doc":
-		return fetchDocumentationDetails(pkg), nil
+		if err := hydrateDocumentation(ctx, ds, pkg); err != nil {
+			return nil, err
+		}
+		details := fetchDocumentationDetails(pkg)
+		if err := addJumpIndex(ctx, ds, details, pkg.Path, pkg.ModulePath, pkg.Version); err != nil {
+			return nil, err
+		}
+		return details, nil
 	case "versions":
-		return fetchPackageVersionsDetails(ctx, ds, pkg.Path, pkg.V1Path, pkg.ModulePath)
+		return fetchPackageVersionsDetails(ctx, ds, pkg.Path, pkg.V1Path, pkg.ModulePath, newVersionsParams(r))
 	case "subdirectories":
 		return fetchDirectoryDetails(ctx, ds, pkg.Path, &pkg.ModuleInfo, pkg.Licenses, false)
 	case "imports":
@@ -173,9 +192,13 @@ func fetchDetailsForVersionedDirectory(ctx context.Context, r *http.Request, tab
 	ds internal.DataSource, vdir *internal.VersionedDirectory) (interface{}, error) {
 	switch tab {
 	case "doc":
-		return fetchDocumentationDetailsNew(vdir.Package.Documentation), nil
+		details := fetchDocumentationDetailsNew(vdir.Package.Documentation)
+		if err := addJumpIndex(ctx, ds, details, vdir.Path, vdir.ModulePath, vdir.Version); err != nil {
+			return nil, err
+		}
+		return details, nil
 	case "versions":
-		return fetchPackageVersionsDetails(ctx, ds, vdir.Path, vdir.V1Path, vdir.ModulePath)
+		return fetchPackageVersionsDetails(ctx, ds, vdir.Path, vdir.V1Path, vdir.ModulePath, newVersionsParams(r))
 	case "subdirectories":
 		return fetchDirectoryDetails(ctx, ds, vdir.Path, &vdir.ModuleInfo, vdir.Licenses, false)
 	case "imports":
@@ -206,9 +229,16 @@ func fetchDetailsForModule(ctx context.Context, r *http.Request, tab string, ds
 	case "packages":
 		return fetchDirectoryDetails(ctx, ds, mi.ModulePath, &mi.ModuleInfo, licensesToMetadatas(licenses), true)
 	case "licenses":
-		return &LicensesDetails{Licenses: transformLicenses(mi.ModulePath, mi.Version, licenses)}, nil
+		return fetchLicensesDetails(ctx, ds, mi.ModulePath, mi.ModulePath, mi.Version, licenses)
 	case "versions":
-		return fetchModuleVersionsDetails(ctx, ds, &mi.ModuleInfo)
+		return fetchModuleVersionsDetails(ctx, ds, &mi.ModuleInfo, newVersionsParams(r))
+	case "security":
+		db, ok := ds.(*postgres.DB)
+		if !ok {
+			// The proxydatasource does not support advisories.
+			return nil, proxydatasourceNotSupportedErr()
+		}
+		return fetchSecurityDetails(ctx, db, mi.ModulePath, mi.Version, linkVersion(mi.Version, mi.ModulePath))
 	case "overview":
 		readme := &internal.Readme{Filepath: mi.LegacyReadmeFilePath, Contents: mi.LegacyReadmeContents}
 		return constructOverviewDetails(ctx, &mi.ModuleInfo, readme, mi.IsRedistributable, urlIsVersioned(r.URL)), nil
@@ -7,13 +7,16 @@ package frontend
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"path"
 	"strings"
+	"time"
 
 	"golang.org/x/mod/module"
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/version"
 )
@@ -29,16 +32,68 @@ type VersionsDetails struct {
 	// OtherModules is the slice of VersionLists with a different module path
 	// from the current package.
 	OtherModules []*VersionList
+
+	// LicenseChange is the most recent change to the licenses of the current
+	// module, if any.
+	LicenseChange *internal.LicenseChange
+
+	// TipURL links to the page at the tip of the Go repo, for the standard
+	// library. Tip is not a tagged version, so it is not in the lists.
+	TipURL string
+
+	// PseudoVersions are the most recent pseudo-versions, which are shown
+	// collapsed below the tagged versions.
+	PseudoVersions []*VersionList
+
+	// Sort is the order of the tagged versions.
+	Sort postgres.VersionsOrder
+
+	// Pagination paginates the tagged versions by minor version. It has no
+	// pages if the data source cannot paginate versions.
+	Pagination pagination
+}
+
+// versionsParams are the parameters of a request for the versions tab.
+type versionsParams struct {
+	paginationParams
+	order postgres.VersionsOrder
+}
+
+const (
+	// defaultVersionsLimit and maxVersionsLimit are the default and maximum
+	// number of minor versions on a page of the versions tab.
+	defaultVersionsLimit = 20
+	maxVersionsLimit     = 100
+)
+
+// newVersionsParams extracts the parameters of the versions tab from r. The
+// versions are sorted by date with "sort=date", and by semver otherwise.
+func newVersionsParams(r *http.Request) versionsParams {
+	params := versionsParams{
+		paginationParams: newPaginationParams(r, defaultVersionsLimit),
+		order:            postgres.OrderBySemver,
+	}
+	if params.limit > maxVersionsLimit {
+		params.limit = maxVersionsLimit
+	}
+	if r.FormValue("sort") == string(postgres.OrderByDate) {
+		params.order = postgres.OrderByDate
+	}
+	return params
 }
 
 // VersionListKey identifies a version list on the versions tab. We have a
 // separate VersionList for each major version of a module series. Notably we
 // have more version lists than module paths: v0 and v1 module versions are in
 // separate version lists, despite having the same module path.
+//
+// The standard library instead has a separate VersionList for each Go
+// release, which holds its minor revisions, betas and release candidates.
 type VersionListKey struct {
 	// ModulePath is the module path of this major version.
 	ModulePath string
-	// Major is the major version string (e.g. v1, v2)
+	// Major is the major version string (e.g. v1, v2), or for the standard
+	// library, the Go release (e.g. go1.13).
 	Major string
 }
 
@@ -57,57 +112,61 @@ type VersionSummary struct {
 	TooltipVersion string
 	DisplayVersion string
 	CommitTime     string
+	// PublishTime is when the module index reported the version, or empty if
+	// it is not known.
+	PublishTime string
 	// Link to this version, for use in the anchor href.
 	Link string
+	// ReleaseNotesURL links to the release notes of this version of the
+	// standard library. It is empty for other modules.
+	ReleaseNotesURL string
+	// LicenseChanged reports whether the license types of this version differ
+	// from those of the previous version.
+	LicenseChanged bool
 }
 
 // fetchModuleVersionsDetails builds a version hierarchy for module versions
 // with the same series path as the given version.
-func fetchModuleVersionsDetails(ctx context.Context, ds internal.DataSource, mi *internal.ModuleInfo) (*VersionsDetails, error) {
-	versions, err := ds.GetTaggedVersionsForModule(ctx, mi.ModulePath)
+func fetchModuleVersionsDetails(ctx context.Context, ds internal.DataSource, mi *internal.ModuleInfo, params versionsParams) (*VersionsDetails, error) {
+	versions, err := fetchVersions(ctx, ds, mi.ModulePath, "", params)
 	if err != nil {
 		return nil, err
 	}
-	// If no tagged versions of the module are found, fetch pseudo-versions
-	// instead.
-	if len(versions) == 0 {
-		versions, err = ds.GetPseudoVersionsForModule(ctx, mi.ModulePath)
-		if err != nil {
-			return nil, err
-		}
-	}
 	linkify := func(m *internal.ModuleInfo) string {
 		return constructModuleURL(m.ModulePath, linkVersion(m.Version, m.ModulePath))
 	}
-	return buildVersionDetails(mi.ModulePath, versions, linkify), nil
+	details := versions.details(mi.ModulePath, params, linkify)
+	if mi.ModulePath == stdlib.ModulePath {
+		details.TipURL = constructModuleURL(mi.ModulePath, internal.MasterVersion)
+	}
+	if err := addLicenseChanges(ctx, ds, details, mi.ModulePath); err != nil {
+		return nil, err
+	}
+	return details, nil
 }
 
 // fetchPackageVersionsDetails builds a version hierarchy for all module
 // versions containing a package path with v1 import path matching the given v1 path.
-func fetchPackageVersionsDetails(ctx context.Context, ds internal.DataSource, pkgPath, v1Path, modulePath string) (*VersionsDetails, error) {
-	versions, err := ds.GetTaggedVersionsForPackageSeries(ctx, pkgPath)
+func fetchPackageVersionsDetails(ctx context.Context, ds internal.DataSource, pkgPath, v1Path, modulePath string, params versionsParams) (*VersionsDetails, error) {
+	versions, err := fetchVersions(ctx, ds, modulePath, pkgPath, params)
 	if err != nil {
 		return nil, err
 	}
-	// If no tagged versions for the package series are found, fetch the
-	// pseudo-versions instead.
-	if len(versions) == 0 {
-		versions, err = ds.GetPseudoVersionsForPackageSeries(ctx, pkgPath)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	var filteredVersions []*internal.ModuleInfo
 	// TODO(rfindley): remove this filtering, as it should not be necessary and
 	// is probably a relic of earlier version query implementations.
-	for _, v := range versions {
-		if seriesPath := v.SeriesPath(); strings.HasPrefix(v1Path, seriesPath) || seriesPath == stdlib.ModulePath {
-			filteredVersions = append(filteredVersions, v)
-		} else {
-			log.Errorf(ctx, "got version with mismatching series: %q", seriesPath)
+	filter := func(versions []*internal.ModuleInfo) []*internal.ModuleInfo {
+		var filteredVersions []*internal.ModuleInfo
+		for _, v := range versions {
+			if seriesPath := v.SeriesPath(); strings.HasPrefix(v1Path, seriesPath) || seriesPath == stdlib.ModulePath {
+				filteredVersions = append(filteredVersions, v)
+			} else {
+				log.Errorf(ctx, "got version with mismatching series: %q", seriesPath)
+			}
 		}
+		return filteredVersions
 	}
+	versions.tagged = filter(versions.tagged)
+	versions.pseudo = filter(versions.pseudo)
 
 	linkify := func(mi *internal.ModuleInfo) string {
 		// Here we have only version information, but need to construct the full
@@ -120,7 +179,107 @@ func fetchPackageVersionsDetails(ctx context.Context, ds internal.DataSource, pk
 		}
 		return constructPackageURL(versionPath, mi.ModulePath, linkVersion(mi.Version, mi.ModulePath))
 	}
-	return buildVersionDetails(modulePath, filteredVersions, linkify), nil
+	details := versions.details(modulePath, params, linkify)
+	if modulePath == stdlib.ModulePath {
+		details.TipURL = constructPackageURL(pkgPath, modulePath, internal.MasterVersion)
+	}
+	if err := addLicenseChanges(ctx, ds, details, modulePath); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// tabVersions are the versions shown on a versions tab.
+type tabVersions struct {
+	// tagged are the tagged versions on the requested page, and pseudo the
+	// most recent pseudo-versions.
+	tagged, pseudo []*internal.ModuleInfo
+	// numMinors and numMinorsOnPage are the number of minor versions of the
+	// tagged versions, on all pages and on the requested page. They are zero
+	// if the versions are not paginated.
+	numMinors, numMinorsOnPage int
+}
+
+// fetchVersions fetches the versions of the modules in the series of
+// modulePath if pkgPath is empty, and otherwise the versions of the modules
+// that contain the package series of pkgPath. Only the postgres data source
+// paginates the tagged versions; other data sources return all of them.
+func fetchVersions(ctx context.Context, ds internal.DataSource, modulePath, pkgPath string, params versionsParams) (_ *tabVersions, err error) {
+	var versions tabVersions
+	if pkgPath == "" {
+		versions.pseudo, err = ds.GetPseudoVersionsForModule(ctx, modulePath)
+	} else {
+		versions.pseudo, err = ds.GetPseudoVersionsForPackageSeries(ctx, pkgPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		if pkgPath == "" {
+			versions.tagged, err = ds.GetTaggedVersionsForModule(ctx, modulePath)
+		} else {
+			versions.tagged, err = ds.GetTaggedVersionsForPackageSeries(ctx, pkgPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &versions, nil
+	}
+	var page *postgres.VersionsPage
+	if pkgPath == "" {
+		page, err = db.GetModuleVersionsPage(ctx, modulePath, params.order, params.limit, params.offset())
+	} else {
+		page, err = db.GetPackageVersionsPage(ctx, pkgPath, params.order, params.limit, params.offset())
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, mv := range page.Minors {
+		versions.tagged = append(versions.tagged, mv.Versions...)
+	}
+	versions.numMinors = page.NumMinors
+	versions.numMinorsOnPage = len(page.Minors)
+	return &versions, nil
+}
+
+// details returns the VersionsDetails of the versions, with the lists of
+// tagged versions organized by buildVersionDetails.
+func (v *tabVersions) details(currentModulePath string, params versionsParams, linkify func(*internal.ModuleInfo) string) *VersionsDetails {
+	details := buildVersionDetails(currentModulePath, v.tagged, linkify)
+	pseudo := buildVersionDetails(currentModulePath, v.pseudo, linkify)
+	details.PseudoVersions = append(pseudo.ThisModule, pseudo.OtherModules...)
+	details.Sort = params.order
+	if v.numMinors > 0 {
+		details.Pagination = newPagination(params.paginationParams, v.numMinorsOnPage, v.numMinors)
+	}
+	return details
+}
+
+// addLicenseChanges marks the versions of modulePath in details whose licenses
+// changed, and sets details.LicenseChange to the latest such change.
+func addLicenseChanges(ctx context.Context, ds internal.DataSource, details *VersionsDetails, modulePath string) error {
+	changes, err := fetchLicenseChanges(ctx, ds, modulePath)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	details.LicenseChange = changes[0]
+	changed := map[string]bool{}
+	for _, c := range changes {
+		changed[c.Version] = true
+	}
+	for _, vl := range details.ThisModule {
+		if vl.ModulePath != modulePath {
+			continue
+		}
+		for _, vs := range vl.Versions {
+			// Outside the standard library, the tooltip is the full version.
+			if changed[vs.TooltipVersion] {
+				vs.LicenseChanged = true
+			}
+		}
+	}
+	return nil
 }
 
 // pathInVersion constructs the full import path of the package corresponding
@@ -165,7 +324,7 @@ func buildVersionDetails(currentModulePath string, modInfos []*internal.ModuleIn
 		major := semver.Major(mi.Version)
 		if mi.ModulePath == stdlib.ModulePath {
 			var err error
-			major, err = stdlib.MajorVersionForVersion(mi.Version)
+			major, err = stdlib.ReleaseForVersion(mi.Version)
 			if err != nil {
 				panic(err)
 			}
@@ -183,17 +342,21 @@ func buildVersionDetails(currentModulePath string, modInfos []*internal.ModuleIn
 			}
 		}
 		key := VersionListKey{ModulePath: mi.ModulePath, Major: major}
-		ttversion := mi.Version
 		fmtVersion := displayVersion(mi.Version, mi.ModulePath)
-		if mi.ModulePath == stdlib.ModulePath {
-			ttversion = fmtVersion // tooltips will show the Go tag
-		}
 		vs := &VersionSummary{
-			TooltipVersion: ttversion,
+			TooltipVersion: mi.Version,
 			Link:           linkify(mi),
 			CommitTime:     elapsedTime(mi.CommitTime),
+			PublishTime:    publishTime(mi),
 			DisplayVersion: fmtVersion,
 		}
+		if mi.ModulePath == stdlib.ModulePath {
+			vs.TooltipVersion = fmtVersion // tooltips will show the Go tag
+			// The commit of a Go tag is made on the day of the release, so
+			// its time is the release date.
+			vs.CommitTime = releaseDate(mi.CommitTime)
+			vs.ReleaseNotesURL = stdlib.ReleaseNotesURL(mi.Version)
+		}
 		if _, ok := lists[key]; !ok {
 			seenLists = append(seenLists, key)
 		}
@@ -215,6 +378,11 @@ func buildVersionDetails(currentModulePath string, modInfos []*internal.ModuleIn
 	return &details
 }
 
+// releaseDate formats the date of a Go release, in UTC like elapsedTime.
+func releaseDate(t time.Time) string {
+	return t.UTC().Format("Jan _2, 2006")
+}
+
 // formatVersion formats a more readable representation of the given version
 // string. On any parsing error, it simply returns the input unmodified.
 //
@@ -238,17 +406,21 @@ func formatVersion(v string) string {
 	case version.TypePrerelease:
 		return fmt.Sprintf("%s (%s)", base, pre)
 	case version.TypePseudo:
-		rev := pseudoVersionRev(v)
-		commitLen := 7
-		if len(rev) < commitLen {
-			commitLen = len(rev)
-		}
-		return fmt.Sprintf("%s (%s)", base, rev[0:commitLen])
+		return fmt.Sprintf("%s (%s)", base, shortRev(pseudoVersionRev(v)))
 	default:
 		return v
 	}
 }
 
+// shortRev returns the abbreviated form of the commit identifier rev.
+func shortRev(rev string) string {
+	commitLen := 7
+	if len(rev) < commitLen {
+		commitLen = len(rev)
+	}
+	return rev[0:commitLen]
+}
+
 // pseudoVersionRev extracts the commit identifier from a pseudo version
 // string. It assumes the pseudo version is correctly formatted.
 func pseudoVersionRev(v string) string {
@@ -260,6 +432,10 @@ func pseudoVersionRev(v string) string {
 // displayVersion returns the version string, formatted for display.
 func displayVersion(v string, modulePath string) string {
 	if modulePath == stdlib.ModulePath {
+		if version.IsPseudo(v) {
+			// Tip has no tag, so show the commit.
+			return fmt.Sprintf("%s (%s)", stdlib.MasterVersion, shortRev(pseudoVersionRev(v)))
+		}
 		return goTagForVersion(v)
 	}
 	return formatVersion(v)
@@ -268,7 +444,7 @@ func displayVersion(v string, modulePath string) string {
 // linkVersion returns the version string, suitable for use in
 // a link to this site.
 func linkVersion(v string, modulePath string) string {
-	if modulePath == stdlib.ModulePath {
+	if modulePath == stdlib.ModulePath && !version.IsPseudo(v) {
 		return goTagForVersion(v)
 	}
 	return v
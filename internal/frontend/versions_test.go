@@ -6,9 +6,11 @@ package frontend
 
 import (
 	"context"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/stdlib"
@@ -20,6 +22,8 @@ var (
 	modulePath1 = "test.com/module"
 	modulePath2 = "test.com/module/v2"
 	commitTime  = "0 hours ago"
+
+	defaultVersionsParams = newVersionsParams(httptest.NewRequest("GET", "/?tab=versions", nil))
 )
 
 func sampleModule(modulePath, version string, versionType version.Type, packages ...*internal.LegacyPackage) *internal.Module {
@@ -50,6 +54,10 @@ func versionSummaries(path string, versions []string, linkify func(path, version
 			Link:           linkify(path, version),
 			CommitTime:     commitTime,
 		}
+		if stdlib.Contains(path) {
+			vs[i].CommitTime = releaseDate(sample.CommitTime)
+			vs[i].ReleaseNotesURL = stdlib.ReleaseNotesURL(stdlib.VersionForTag(version))
+		}
 	}
 	return vs
 }
@@ -93,6 +101,10 @@ func TestFetchModuleVersionDetails(t *testing.T) {
 				OtherModules: []*VersionList{
 					makeList("test.com/module/v2", "v2", []string{"v2.2.1-alpha.1", "v2.0.0"}),
 				},
+				PseudoVersions: []*VersionList{
+					makeList("test.com/module", "v0", []string{"v0.0.0-20140414041502-3c2ca4d52544"}),
+				},
+				Sort: postgres.OrderBySemver,
 			},
 		},
 		{
@@ -113,6 +125,10 @@ func TestFetchModuleVersionDetails(t *testing.T) {
 				OtherModules: []*VersionList{
 					makeList("test.com/module", "v1", []string{"v2.1.0+incompatible", "v1.2.3", "v1.2.1"}),
 				},
+				PseudoVersions: []*VersionList{
+					makeList("test.com/module", "v0", []string{"v0.0.0-20140414041502-3c2ca4d52544"}),
+				},
+				Sort: postgres.OrderBySemver,
 			},
 		},
 		{
@@ -123,12 +139,13 @@ func TestFetchModuleVersionDetails(t *testing.T) {
 				sampleModule(modulePath1, "v0.0.0-20140414041502-4c2ca4d52544", version.TypePseudo),
 			},
 			wantDetails: &VersionsDetails{
-				OtherModules: []*VersionList{
+				PseudoVersions: []*VersionList{
 					makeList("test.com/module", "v0", []string{
 						"v0.0.0-20140414041502-4c2ca4d52544",
 						"v0.0.0-20140414041501-3c2ca4d52544"},
 					),
 				},
+				Sort: postgres.OrderBySemver,
 			},
 		},
 	} {
@@ -141,11 +158,11 @@ func TestFetchModuleVersionDetails(t *testing.T) {
 				}
 			}
 
-			got, err := fetchModuleVersionsDetails(ctx, testDB, tc.info)
+			got, err := fetchModuleVersionsDetails(ctx, testDB, tc.info, defaultVersionsParams)
 			if err != nil {
 				t.Fatalf("fetchModuleVersionsDetails(ctx, db, %v): %v", tc.info, err)
 			}
-			if diff := cmp.Diff(tc.wantDetails, got); diff != "" {
+			if diff := cmp.Diff(tc.wantDetails, got, cmpopts.IgnoreFields(VersionsDetails{}, "Pagination")); diff != "" {
 				t.Errorf("fetchModuleVersionsDetails(ctx, db, %v) mismatch (-want +got):\n%s", tc.info, diff)
 			}
 		})
@@ -197,8 +214,11 @@ func TestFetchPackageVersionsDetails(t *testing.T) {
 			},
 			wantDetails: &VersionsDetails{
 				ThisModule: []*VersionList{
-					makeList("net/http", "std", "go1", []string{"go1.12.5", "go1.11.6"}),
+					makeList("net/http", "std", "go1.12", []string{"go1.12.5"}),
+					makeList("net/http", "std", "go1.11", []string{"go1.11.6"}),
 				},
+				TipURL: "/net/http@master",
+				Sort:   postgres.OrderBySemver,
 			},
 		},
 		{
@@ -221,6 +241,10 @@ func TestFetchPackageVersionsDetails(t *testing.T) {
 					makeList(v2Path, modulePath2, "v2", []string{"v2.2.1-alpha.1", "v2.0.0"}),
 					makeList(v1Path, "test.com", "v1", []string{"v1.2.1"}),
 				},
+				PseudoVersions: []*VersionList{
+					makeList(v1Path, modulePath1, "v0", []string{"v0.0.0-20140414041502-3c2ca4d52544"}),
+				},
+				Sort: postgres.OrderBySemver,
 			},
 		},
 		{
@@ -241,6 +265,10 @@ func TestFetchPackageVersionsDetails(t *testing.T) {
 				OtherModules: []*VersionList{
 					makeList(v1Path, modulePath1, "v1", []string{"v2.1.0+incompatible", "v1.2.3", "v1.2.1"}),
 				},
+				PseudoVersions: []*VersionList{
+					makeList(v1Path, modulePath1, "v0", []string{"v0.0.0-20140414041502-3c2ca4d52544"}),
+				},
+				Sort: postgres.OrderBySemver,
 			},
 		},
 		{
@@ -251,12 +279,13 @@ func TestFetchPackageVersionsDetails(t *testing.T) {
 				sampleModule(modulePath1, "v0.0.0-20140414041502-4c2ca4d52544", version.TypePseudo, &pkg2.LegacyPackage),
 			},
 			wantDetails: &VersionsDetails{
-				OtherModules: []*VersionList{
+				PseudoVersions: []*VersionList{
 					makeList(v1Path, modulePath1, "v0", []string{
 						"v0.0.0-20140414041502-4c2ca4d52544",
 						"v0.0.0-20140414041501-3c2ca4d52544",
 					}),
 				},
+				Sort: postgres.OrderBySemver,
 			},
 		},
 	} {
@@ -269,11 +298,11 @@ func TestFetchPackageVersionsDetails(t *testing.T) {
 				}
 			}
 
-			got, err := fetchPackageVersionsDetails(ctx, testDB, tc.pkg.Path, tc.pkg.V1Path, tc.pkg.ModulePath)
+			got, err := fetchPackageVersionsDetails(ctx, testDB, tc.pkg.Path, tc.pkg.V1Path, tc.pkg.ModulePath, defaultVersionsParams)
 			if err != nil {
 				t.Fatalf("fetchPackageVersionsDetails(ctx, db, %v): %v", tc.pkg, err)
 			}
-			if diff := cmp.Diff(tc.wantDetails, got); diff != "" {
+			if diff := cmp.Diff(tc.wantDetails, got, cmpopts.IgnoreFields(VersionsDetails{}, "Pagination")); diff != "" {
 				t.Errorf("fetchPackageVersionsDetails(ctx, db, %v) mismatch (-want +got):\n%s", tc.pkg, diff)
 			}
 		})
@@ -320,3 +349,62 @@ func TestFormatVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestStdlibDisplayAndLinkVersion(t *testing.T) {
+	for _, test := range []struct {
+		version, wantDisplay, wantLink string
+	}{
+		{"v1.13.0", "go1.13", "go1.13"},
+		{"v1.14.0-rc.1", "go1.14rc1", "go1.14rc1"},
+		{"v0.0.0-20201014123456-0123456789ab", "master (0123456)", "v0.0.0-20201014123456-0123456789ab"},
+	} {
+		t.Run(test.version, func(t *testing.T) {
+			if got := displayVersion(test.version, stdlib.ModulePath); got != test.wantDisplay {
+				t.Errorf("displayVersion(%q) = %q, want %q", test.version, got, test.wantDisplay)
+			}
+			if got := linkVersion(test.version, stdlib.ModulePath); got != test.wantLink {
+				t.Errorf("linkVersion(%q) = %q, want %q", test.version, got, test.wantLink)
+			}
+		})
+	}
+}
+
+func TestBuildVersionDetailsStdlib(t *testing.T) {
+	var modInfos []*internal.ModuleInfo
+	for _, v := range []string{"v1.14.0-rc.1", "v1.13.1", "v1.13.0"} {
+		modInfos = append(modInfos, sample.ModuleInfo(stdlib.ModulePath, v))
+	}
+	linkify := func(mi *internal.ModuleInfo) string {
+		return constructPackageURL("net/http", mi.ModulePath, linkVersion(mi.Version, mi.ModulePath))
+	}
+	got := buildVersionDetails(stdlib.ModulePath, modInfos, linkify)
+	summary := func(tag, releaseNotesURL string) *VersionSummary {
+		return &VersionSummary{
+			TooltipVersion:  tag,
+			DisplayVersion:  tag,
+			CommitTime:      releaseDate(sample.CommitTime),
+			Link:            "/net/http@" + tag,
+			ReleaseNotesURL: releaseNotesURL,
+		}
+	}
+	want := &VersionsDetails{
+		ThisModule: []*VersionList{
+			{
+				VersionListKey: VersionListKey{ModulePath: stdlib.ModulePath, Major: "go1.14"},
+				Versions: []*VersionSummary{
+					summary("go1.14rc1", "https://golang.org/doc/go1.14"),
+				},
+			},
+			{
+				VersionListKey: VersionListKey{ModulePath: stdlib.ModulePath, Major: "go1.13"},
+				Versions: []*VersionSummary{
+					summary("go1.13.1", "https://golang.org/doc/devel/release.html#go1.13.minor"),
+					summary("go1.13", "https://golang.org/doc/go1.13"),
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/vuln"
+)
+
+// Vuln is an advisory from the Go vulnerability database, as shown on details
+// pages.
+type Vuln struct {
+	ID string
+	// Summary is the summary of the advisory, or the first line of its
+	// details if it has none.
+	Summary string
+	Details string
+	// Aliases are other IDs of the advisory, such as CVEs.
+	Aliases []string
+	// FixedVersions are the versions of the module that fix the advisory.
+	FixedVersions []string
+	// AffectsVersion reports whether the advisory applies to the version of
+	// the page.
+	AffectsVersion bool
+	References     []string
+	// URL is the advisory on the security tab of the module.
+	URL string
+}
+
+// SecurityDetails contains the data for the security tab of a module.
+type SecurityDetails struct {
+	// Vulns are the advisories for the module, at any version.
+	Vulns []*Vuln
+}
+
+// fetchVulnsForVersion returns the advisories that apply to modulePath at
+// version, for the banner of a details page. Advisories are only available
+// from a postgres.DB.
+func fetchVulnsForVersion(ctx context.Context, ds internal.DataSource, modulePath, version, linkVersion string) []*Vuln {
+	db, ok := ds.(*postgres.DB)
+	if !ok {
+		return nil
+	}
+	entries, err := db.GetVulnsForVersion(ctx, modulePath, version)
+	if err != nil {
+		// The advisories are informational; don't fail the page.
+		log.Errorf(ctx, "fetchVulnsForVersion: %v", err)
+		return nil
+	}
+	return toVulns(entries, modulePath, version, linkVersion)
+}
+
+// fetchSecurityDetails returns the advisories for every version of
+// modulePath, noting those that apply to version.
+func fetchSecurityDetails(ctx context.Context, db *postgres.DB, modulePath, version, linkVersion string) (*SecurityDetails, error) {
+	entries, err := db.GetVulnsForModule(ctx, modulePath)
+	if err != nil {
+		return nil, err
+	}
+	return &SecurityDetails{Vulns: toVulns(entries, modulePath, version, linkVersion)}, nil
+}
+
+// toVulns converts advisories for modulePath to Vulns, for a page about
+// version.
+func toVulns(entries []*vuln.Entry, modulePath, version, linkVersion string) []*Vuln {
+	var vulns []*Vuln
+	for _, e := range entries {
+		v := &Vuln{
+			ID:             e.ID,
+			Summary:        e.Summary,
+			Details:        e.Details,
+			Aliases:        e.Aliases,
+			AffectsVersion: e.AffectsVersion(modulePath, version),
+			URL:            constructModuleURL(modulePath, linkVersion) + "?tab=security#" + e.ID,
+		}
+		if v.Summary == "" {
+			v.Summary = strings.SplitN(strings.TrimSpace(e.Details), "\n", 2)[0]
+		}
+		for _, f := range e.FixedVersions(modulePath) {
+			v.FixedVersions = append(v.FixedVersions, displayVersion(f, modulePath))
+		}
+		for _, r := range e.References {
+			v.References = append(v.References, r.URL)
+		}
+		vulns = append(vulns, v)
+	}
+	return vulns
+}
+
+// vulnsAPIPath is the path of the vulnerabilities API endpoint.
+const vulnsAPIPath = "/api/v1/vulns/"
+
+// vulnsCacheControl is the Cache-Control header of responses from the
+// vulnerabilities API. Advisories change whenever they are synced.
+var vulnsCacheControl = fmt.Sprintf("public, max-age=%d", int(shortTTL.Seconds()))
+
+// serveVulnsAPI serves the advisories for a module as a JSON array of OSV
+// entries, in the format of the Go vulnerability database. The URL has the
+// form
+//
+//	/api/v1/vulns/<module-path>[?version=<version>]
+//
+// If a version is given, only the advisories that apply to it are served. The
+// version is any semantic version, or a Go tag for the standard library; it
+// need not be known to the site. A module without advisories is served an
+// empty array.
+func (s *Server) serveVulnsAPI(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	db, ok := s.ds.(*postgres.DB)
+	if !ok {
+		return &serverError{
+			status: http.StatusFailedDependency,
+			detail: "Vulnerabilities are not supported by the proxydatasource.",
+		}
+	}
+	modulePath, version, err := parseModuleAPIRequest(strings.TrimPrefix(r.URL.Path, vulnsAPIPath), r.FormValue("version"))
+	if err != nil {
+		return &serverError{status: http.StatusBadRequest, err: err, detail: err.Error()}
+	}
+	entries, err := db.GetVulnsForModule(ctx, modulePath)
+	if err != nil {
+		return err
+	}
+	if version != "" {
+		entries = entriesAffectingVersion(entries, modulePath, version)
+	}
+	if entries == nil {
+		// Serve an empty array rather than null, so that clients need not
+		// tell them apart.
+		entries = []*vuln.Entry{}
+	}
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", vulnsCacheControl)
+	if _, err := w.Write(body); err != nil {
+		log.Errorf(ctx, "Error writing vulns to ResponseWriter: %v", err)
+	}
+	return nil
+}
+
+// parseModuleAPIRequest checks the module path and version of a request to an
+// API about a module, like the vulnerabilities API, and returns the version as
+// a semantic version.
+func parseModuleAPIRequest(modulePath, version string) (_, _ string, err error) {
+	defer derrors.Wrap(&err, "parseModuleAPIRequest(%q, %q)", modulePath, version)
+
+	if modulePath == "" {
+		return "", "", errors.New("missing module path")
+	}
+	if modulePath != stdlib.ModulePath {
+		if err := module.CheckPath(modulePath); err != nil {
+			return "", "", err
+		}
+	}
+	if version == "" {
+		return modulePath, "", nil
+	}
+	v := version
+	if modulePath == stdlib.ModulePath && strings.HasPrefix(version, "go") {
+		v = stdlib.VersionForTag(version)
+	}
+	if !semver.IsValid(v) {
+		return "", "", fmt.Errorf("invalid version %q", version)
+	}
+	return modulePath, v, nil
+}
+
+// entriesAffectingVersion returns the entries that apply to modulePath at
+// version.
+func entriesAffectingVersion(entries []*vuln.Entry, modulePath, version string) []*vuln.Entry {
+	var es []*vuln.Entry
+	for _, e := range entries {
+		if e.AffectsVersion(modulePath, version) {
+			es = append(es, e)
+		}
+	}
+	return es
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package frontend
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/vuln"
+)
+
+func TestToVulns(t *testing.T) {
+	const modulePath = "example.com/mod"
+	entries := []*vuln.Entry{
+		{
+			ID:      "GO-2020-0001",
+			Details: "Crash on bad input.\n\nMore details.",
+			Aliases: []string{"CVE-2020-1234"},
+			Affected: []vuln.Affected{{
+				Package: vuln.Package{Name: modulePath},
+				Ranges:  []vuln.Range{{Type: vuln.RangeTypeSemver, Events: []vuln.RangeEvent{{Introduced: "0"}, {Fixed: "1.2.0"}}}},
+			}},
+			References: []vuln.Reference{{Type: "FIX", URL: "https://example.com/fix"}},
+		},
+		{
+			ID:      "GO-2020-0002",
+			Summary: "Old bug",
+			Details: "Fixed long ago.",
+			Affected: []vuln.Affected{{
+				Package: vuln.Package{Name: modulePath},
+				Ranges:  []vuln.Range{{Type: vuln.RangeTypeSemver, Events: []vuln.RangeEvent{{Introduced: "0"}, {Fixed: "0.5.0"}}}},
+			}},
+		},
+	}
+	got := toVulns(entries, modulePath, "v1.0.0", internal.LatestVersion)
+	want := []*Vuln{
+		{
+			ID:             "GO-2020-0001",
+			Summary:        "Crash on bad input.",
+			Details:        "Crash on bad input.\n\nMore details.",
+			Aliases:        []string{"CVE-2020-1234"},
+			FixedVersions:  []string{"v1.2.0"},
+			AffectsVersion: true,
+			References:     []string{"https://example.com/fix"},
+			URL:            "/mod/example.com/mod?tab=security#GO-2020-0001",
+		},
+		{
+			ID:            "GO-2020-0002",
+			Summary:       "Old bug",
+			Details:       "Fixed long ago.",
+			FixedVersions: []string{"v0.5.0"},
+			URL:           "/mod/example.com/mod?tab=security#GO-2020-0002",
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseVulnsAPIRequest(t *testing.T) {
+	for _, test := range []struct {
+		modulePath, version string
+		wantVersion         string
+		wantErr             bool
+	}{
+		{"example.com/mod", "", "", false},
+		{"example.com/mod", "v1.2.3", "v1.2.3", false},
+		{"std", "go1.15.2", "v1.15.2", false},
+		{"std", "v1.15.2", "v1.15.2", false},
+		{"", "", "", true},
+		{"example.com/mod", "1.2.3", "", true},
+		{"example.com/mod", "go1.15", "", true},
+		{"std", "go1.0", "", true},
+		{"example.com/MOD\x00", "", "", true},
+	} {
+		gotPath, gotVersion, err := parseModuleAPIRequest(test.modulePath, test.version)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseModuleAPIRequest(%q, %q): got error %v, want error %t", test.modulePath, test.version, err, test.wantErr)
+			continue
+		}
+		if err == nil && (gotPath != test.modulePath || gotVersion != test.wantVersion) {
+			t.Errorf("parseModuleAPIRequest(%q, %q) = %q, %q; want %q, %q",
+				test.modulePath, test.version, gotPath, gotVersion, test.modulePath, test.wantVersion)
+		}
+	}
+}
+
+func TestEntriesAffectingVersion(t *testing.T) {
+	const modulePath = "example.com/mod"
+	entry := func(id, fixed string) *vuln.Entry {
+		return &vuln.Entry{
+			ID: id,
+			Affected: []vuln.Affected{{
+				Package: vuln.Package{Name: modulePath},
+				Ranges:  []vuln.Range{{Type: vuln.RangeTypeSemver, Events: []vuln.RangeEvent{{Introduced: "0"}, {Fixed: fixed}}}},
+			}},
+		}
+	}
+	e1, e2 := entry("GO-2020-0001", "1.2.0"), entry("GO-2020-0002", "0.5.0")
+	entries := []*vuln.Entry{e1, e2}
+	for _, test := range []struct {
+		version string
+		want    []*vuln.Entry
+	}{
+		{"v0.1.0", []*vuln.Entry{e1, e2}},
+		{"v1.0.0", []*vuln.Entry{e1}},
+		{"v1.2.0", nil},
+	} {
+		got := entriesAffectingVersion(entries, modulePath, test.version)
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.version, diff)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package globs matches module paths against lists of glob patterns, like
+// those of GOPRIVATE.
+package globs
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchPrefixPatterns reports whether any path prefix of target matches one
+// of the glob patterns, as defined by path.Match, in the comma-separated
+// globs list. Empty and malformed patterns are ignored. It follows the
+// semantics of GOPRIVATE, and is the same as module.MatchPrefixPatterns in
+// later versions of golang.org/x/mod.
+func MatchPrefixPatterns(globs, target string) bool {
+	for _, glob := range strings.Split(globs, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == "" {
+			continue
+		}
+		// A glob with N+1 path elements (N slashes) is matched against
+		// the first N+1 path elements of target.
+		n := strings.Count(glob, "/")
+		prefix := target
+		for i := 0; i < len(target); i++ {
+			if target[i] == '/' {
+				if n == 0 {
+					prefix = target[:i]
+					break
+				}
+				n--
+			}
+		}
+		if n > 0 {
+			// Not enough path elements.
+			continue
+		}
+		if matched, _ := path.Match(glob, prefix); matched {
+			return true
+		}
+	}
+	return false
+}
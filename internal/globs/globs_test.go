@@ -0,0 +1,29 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package globs
+
+import "testing"
+
+func TestMatchPrefixPatterns(t *testing.T) {
+	for _, test := range []struct {
+		globs, target string
+		want          bool
+	}{
+		{"", "example.com/m", false},
+		{"example.com", "example.com/m", true},
+		{"example.com", "example.com", true},
+		{"example.com", "example.org/m", false},
+		{"*.corp.com,example.com/private", "git.corp.com/team/m", true},
+		{"*.corp.com,example.com/private", "example.com/private/m", true},
+		{"*.corp.com,example.com/private", "example.com/public", false},
+		{"example.com/a/b", "example.com/a", false},
+		{" example.com , [", "example.com/m", true},
+		{"github.com/*/private", "github.com/alice/private/m", true},
+	} {
+		if got := MatchPrefixPatterns(test.globs, test.target); got != test.want {
+			t.Errorf("MatchPrefixPatterns(%q, %q) = %t, want %t", test.globs, test.target, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package health serves the health and readiness endpoints of the frontend
+// and the worker, which probe the services that they depend on.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/proxy"
+)
+
+// A Probe checks that a dependency is working.
+type Probe struct {
+	// Name identifies the dependency in responses, such as "postgres".
+	Name string
+	// Check returns an error if the dependency is not working. It must
+	// return when ctx is done.
+	Check func(ctx context.Context) error
+}
+
+// Postgres returns a probe of the database.
+func Postgres(db *database.DB) Probe {
+	return Probe{Name: "postgres", Check: db.Ping}
+}
+
+// Redis returns a probe of a Redis instance with the given name, such as
+// "redis-cache".
+func Redis(name string, c *redis.Client) Probe {
+	return Probe{Name: name, Check: func(ctx context.Context) error {
+		return c.WithContext(ctx).Ping().Err()
+	}}
+}
+
+// Proxy returns a probe of the module proxy.
+func Proxy(c *proxy.Client) Probe {
+	return Probe{Name: "proxy", Check: c.Ping}
+}
+
+// Handler serves the health and readiness endpoints. Every request to either
+// runs all of its probes concurrently, each subject to a timeout.
+type Handler struct {
+	timeout time.Duration
+	probes  []Probe
+}
+
+// New returns a Handler that runs the given probes, failing each that takes
+// longer than timeout.
+func New(timeout time.Duration, probes ...Probe) *Handler {
+	return &Handler{timeout: timeout, probes: probes}
+}
+
+// Install registers the endpoints of h with handle:
+//
+// /healthz reports the status of each dependency. It always responds with 200
+// OK, because it is used to check that the instance is alive, and restarting
+// it would not revive a dependency.
+//
+// /readyz reports the same, but responds with 503 Service Unavailable if any
+// dependency is failing, so that load balancers stop sending requests to the
+// instance until it recovers.
+func (h *Handler) Install(handle func(string, http.Handler)) {
+	handle("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, false)
+	}))
+	handle("/readyz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.serve(w, r, true)
+	}))
+}
+
+const (
+	statusOK      = "ok"
+	statusFailing = "failing"
+)
+
+// report is the body of a response from a health endpoint.
+type report struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// LatencyMS is how long the probe took, in milliseconds.
+	LatencyMS int64 `json:"latencyMs"`
+	// Error describes why the probe failed. It is always "timeout" or
+	// "error", to avoid revealing details of the dependency; the error
+	// itself is logged.
+	Error string `json:"error,omitempty"`
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request, ready bool) {
+	rep := h.check(r.Context())
+	status := http.StatusOK
+	if ready && rep.Status != statusOK {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		log.Errorf(r.Context(), "health: writing response: %v", err)
+	}
+}
+
+// check runs the probes of h concurrently and reports their results.
+func (h *Handler) check(ctx context.Context) *report {
+	rep := &report{Status: statusOK, Checks: make([]checkResult, len(h.probes))}
+	var wg sync.WaitGroup
+	for i, p := range h.probes {
+		i, p := i, p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rep.Checks[i] = h.runProbe(ctx, p)
+		}()
+	}
+	wg.Wait()
+	for _, c := range rep.Checks {
+		if c.Status != statusOK {
+			rep.Status = statusFailing
+		}
+	}
+	return rep
+}
+
+func (h *Handler) runProbe(ctx context.Context, p Probe) checkResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.Check(ctx)
+	res := checkResult{
+		Name:      p.Name,
+		Status:    statusOK,
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		log.Errorf(ctx, "health: probe %s failed: %v", p.Name, err)
+		res.Status = statusFailing
+		res.Error = "error"
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() == context.DeadlineExceeded {
+			res.Error = "timeout"
+		}
+	}
+	return res
+}
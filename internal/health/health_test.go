@@ -0,0 +1,100 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestHandler(t *testing.T) {
+	ok := Probe{Name: "ok", Check: func(context.Context) error { return nil }}
+	failing := Probe{Name: "failing", Check: func(context.Context) error { return errors.New("bad") }}
+	slow := Probe{Name: "slow", Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	for _, test := range []struct {
+		name       string
+		probes     []Probe
+		path       string
+		wantStatus int
+		want       *report
+	}{
+		{
+			name:       "healthy",
+			probes:     []Probe{ok},
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+			want:       &report{Status: statusOK, Checks: []checkResult{{Name: "ok", Status: statusOK}}},
+		},
+		{
+			name:       "not ready",
+			probes:     []Probe{ok, failing, slow},
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			want: &report{Status: statusFailing, Checks: []checkResult{
+				{Name: "ok", Status: statusOK},
+				{Name: "failing", Status: statusFailing, Error: "error"},
+				{Name: "slow", Status: statusFailing, Error: "timeout"},
+			}},
+		},
+		{
+			name:       "alive",
+			probes:     []Probe{ok, failing},
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+			want: &report{Status: statusFailing, Checks: []checkResult{
+				{Name: "ok", Status: statusOK},
+				{Name: "failing", Status: statusFailing, Error: "error"},
+			}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			New(10*time.Millisecond, test.probes...).Install(mux.Handle)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			var got report
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, &got, cmpopts.IgnoreFields(checkResult{}, "LatencyMS")); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRedis(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	p := Redis("redis", redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	ctx := context.Background()
+	if err := p.Check(ctx); err != nil {
+		t.Fatalf("with server running: %v", err)
+	}
+	s.Close()
+	if err := p.Check(ctx); err == nil {
+		t.Error("with server closed: got nil, want error")
+	}
+}
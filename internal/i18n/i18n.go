@@ -0,0 +1,154 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package i18n translates the strings of the frontend's templates into the
+// languages of its users.
+//
+// The messages of the templates are in English, which is always supported.
+// The translations of a language are in a catalog file named after its BCP 47
+// tag, like fr.json, which holds a JSON object from English messages to their
+// translations. Messages may contain fmt verbs, which must appear in their
+// translations too.
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/message/catalog"
+)
+
+// Default is the language of the messages in the templates, which is served
+// when no other supported language matches the preferences of a user.
+var Default = language.English
+
+type contextKey struct{}
+
+// NewContext returns a context that holds the language of a request.
+func NewContext(ctx context.Context, lang language.Tag) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// FromContext returns the language of a request, or Default if ctx does not
+// hold one.
+func FromContext(ctx context.Context) language.Tag {
+	if lang, ok := ctx.Value(contextKey{}).(language.Tag); ok {
+		return lang
+	}
+	return Default
+}
+
+// A Catalog holds the translations of messages into the supported languages.
+type Catalog struct {
+	builder   *catalog.Builder
+	languages []language.Tag
+	matcher   language.Matcher
+}
+
+// Load returns a catalog of the translations in the catalog files of dir.
+// If dir does not exist, only Default is supported.
+func Load(dir string) (_ *Catalog, err error) {
+	defer derrors.Wrap(&err, "i18n.Load(%q)", dir)
+
+	c := &Catalog{
+		builder:   catalog.NewBuilder(catalog.Fallback(Default)),
+		languages: []language.Tag{Default},
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+		lang, err := language.Parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		if lang == Default {
+			return nil, fmt.Errorf("%s: the messages of the templates are already in %s", file, Default)
+		}
+		messages, err := readCatalogFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for msg, translation := range messages {
+			if err := c.builder.SetString(lang, msg, translation); err != nil {
+				return nil, fmt.Errorf("%s: %q: %v", file, msg, err)
+			}
+		}
+		c.languages = append(c.languages, lang)
+	}
+	c.matcher = language.NewMatcher(c.languages)
+	return c, nil
+}
+
+// readCatalogFile reads the messages of a catalog file, and checks that each
+// translation has the same fmt verbs as its message.
+func readCatalogFile(file string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	for msg, translation := range messages {
+		if got, want := verbs(translation), verbs(msg); got != want {
+			return nil, fmt.Errorf("%s: translation of %q has verbs %q, want %q", file, msg, got, want)
+		}
+	}
+	return messages, nil
+}
+
+// verbs returns the fmt verbs of s, in order.
+func verbs(s string) string {
+	var vs []string
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] != '%' {
+			continue
+		}
+		i++
+		if s[i] != '%' {
+			vs = append(vs, "%"+string(s[i]))
+		}
+	}
+	return strings.Join(vs, " ")
+}
+
+// Languages returns the supported languages, starting with Default.
+func (c *Catalog) Languages() []language.Tag {
+	return c.languages
+}
+
+// Match returns the supported language that best matches the preferences in
+// an Accept-Language header, or Default if none does.
+func (c *Catalog) Match(acceptLanguage string) language.Tag {
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return Default
+	}
+	_, i, confidence := c.matcher.Match(prefs...)
+	if confidence == language.No {
+		return Default
+	}
+	// Return the supported tag itself, not the one Match returns, which may
+	// carry extensions of the preferences, like a region.
+	return c.languages[i]
+}
+
+// Printer returns a printer that translates messages into lang, and formats
+// them like fmt.Sprintf. Messages without a translation are left in Default.
+func (c *Catalog) Printer(lang language.Tag) *message.Printer {
+	return message.NewPrinter(lang, message.Catalog(c.builder))
+}
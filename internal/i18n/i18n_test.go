@@ -0,0 +1,192 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package i18n
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/text/language"
+)
+
+func writeCatalogs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	dir := writeCatalogs(t, map[string]string{
+		"fr.json": `{"Search": "Rechercher", "%d results": "%d résultats"}`,
+		"de.json": `{"Search": "Suchen"}`,
+	})
+	c, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]language.Tag{language.English, language.German, language.French}, c.Languages(), cmp.Comparer(func(a, b language.Tag) bool { return a == b })); diff != "" {
+		t.Errorf("Languages() mismatch (-want, +got):\n%s", diff)
+	}
+	for _, test := range []struct {
+		lang language.Tag
+		msg  string
+		args []interface{}
+		want string
+	}{
+		{language.French, "Search", nil, "Rechercher"},
+		{language.French, "%d results", []interface{}{3}, "3 résultats"},
+		{language.German, "Search", nil, "Suchen"},
+		{language.German, "%d results", []interface{}{3}, "3 results"},
+		{language.English, "Search", nil, "Search"},
+	} {
+		if got := c.Printer(test.lang).Sprintf(test.msg, test.args...); got != test.want {
+			t.Errorf("%s: Sprintf(%q, %v) = %q, want %q", test.lang, test.msg, test.args, got, test.want)
+		}
+	}
+}
+
+func TestLoadNoDirectory(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Languages(); len(got) != 1 || got[0] != Default {
+		t.Errorf("Languages() = %v, want [%s]", got, Default)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, file, content string
+	}{
+		{"bad tag", "not a tag.json", `{}`},
+		{"default language", "en.json", `{"Search": "Search"}`},
+		{"bad JSON", "fr.json", `{"Search": }`},
+		{"missing verb", "fr.json", `{"%d results": "résultats"}`},
+		{"different verb", "fr.json", `{"%d results": "%s résultats"}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := writeCatalogs(t, map[string]string{test.file: test.content})
+			if _, err := Load(dir); err == nil {
+				t.Error("got nil, want error")
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	c, err := Load(writeCatalogs(t, map[string]string{"fr.json": `{}`}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		acceptLanguage string
+		want           language.Tag
+	}{
+		{"", language.English},
+		{"fr", language.French},
+		{"fr-CH, fr;q=0.9, en;q=0.8", language.French},
+		{"en-US,en;q=0.9,fr;q=0.8", language.English},
+		{"de", language.English},
+		{"de, fr;q=0.5", language.French},
+		{"%%%", language.English},
+	} {
+		if got := c.Match(test.acceptLanguage); got != test.want {
+			t.Errorf("Match(%q) = %s, want %s", test.acceptLanguage, got, test.want)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	if got := FromContext(ctx); got != Default {
+		t.Errorf("FromContext(empty) = %s, want %s", got, Default)
+	}
+	if got := FromContext(NewContext(ctx, language.French)); got != language.French {
+		t.Errorf("FromContext = %s, want %s", got, language.French)
+	}
+}
+
+// translateRegexp matches the calls of the translate function in templates.
+var translateRegexp = regexp.MustCompile(`\{\{-?\s*translate\s+("(?:[^"\\]|\\.)*")`)
+
+// TestTemplateCatalogs checks that the catalogs of the frontend translate
+// every message of its templates, and nothing else.
+func TestTemplateCatalogs(t *testing.T) {
+	const templateDir = "../../content/static/html"
+	c, err := Load(filepath.Join(templateDir, "i18n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Languages()) < 2 {
+		t.Fatal("no catalogs")
+	}
+	var files []string
+	for _, pattern := range []string{"*.tmpl", "helpers/*.tmpl", "pages/*.tmpl"} {
+		fs, err := filepath.Glob(filepath.Join(templateDir, pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, fs...)
+	}
+	messages := map[string]bool{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range translateRegexp.FindAllStringSubmatch(string(data), -1) {
+			msg, err := strconv.Unquote(m[1])
+			if err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+			messages[msg] = true
+		}
+	}
+	if len(messages) == 0 {
+		t.Fatal("no messages in templates")
+	}
+	for _, lang := range c.Languages()[1:] {
+		catalog, err := readCatalogFile(filepath.Join(templateDir, "i18n", lang.String()+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for msg := range messages {
+			if _, ok := catalog[msg]; !ok {
+				t.Errorf("%s: no translation of %q", lang, msg)
+			}
+		}
+		for msg := range catalog {
+			if !messages[msg] {
+				t.Errorf("%s: %q is translated but not used", lang, msg)
+			}
+		}
+	}
+}
+
+func TestVerbs(t *testing.T) {
+	for in, want := range map[string]string{
+		"no verbs":      "",
+		"%d results":    "%d",
+		"100%% of %s":   "%s",
+		"%s and %d":     "%s %d",
+		"trailing %":    "",
+		"%v in %s (%q)": "%v %s %q",
+	} {
+		if got := verbs(in); got != want {
+			t.Errorf("verbs(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
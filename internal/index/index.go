@@ -78,3 +78,59 @@ func (c *Client) GetVersions(ctx context.Context, since time.Time, limit int) (_
 	}
 	return versions, nil
 }
+
+// maxLimit is the largest number of versions that the index returns for one
+// request.
+const maxLimit = 2000
+
+// GetVersionsBetween returns the versions in the index with timestamps in
+// the window [since, until), in order. It reads the index in pages of
+// pageSize versions.
+//
+// The index can only be asked for the versions from a timestamp on, truncated
+// to the second, so pages overlap. If a page ends in the second it starts in,
+// the same page is requested again with twice the limit, until a page ends
+// in a later second or is not full, so that no version in that second is
+// missed. It is an error if more than maxLimit versions share one second.
+func (c *Client) GetVersionsBetween(ctx context.Context, since, until time.Time, pageSize int) (_ []*internal.IndexVersion, err error) {
+	defer derrors.Wrap(&err, "index.Client.GetVersionsBetween(ctx, %s, %s, %d)", since, until, pageSize)
+
+	var (
+		versions []*internal.IndexVersion
+		seen     = map[string]bool{}
+		limit    = pageSize
+	)
+	for {
+		page, err := c.GetVersions(ctx, since, limit)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range page {
+			if !v.Timestamp.Before(until) {
+				return versions, nil
+			}
+			key := v.Path + "@" + v.Version
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			versions = append(versions, v)
+		}
+		if len(page) < limit {
+			return versions, nil
+		}
+		next := page[len(page)-1].Timestamp.Truncate(time.Second)
+		if next.After(since.Truncate(time.Second)) {
+			since = next
+			limit = pageSize
+			continue
+		}
+		if limit >= maxLimit {
+			return nil, fmt.Errorf("more than %d versions at %s", maxLimit, since.Format(time.RFC3339))
+		}
+		limit *= 2
+		if limit > maxLimit {
+			limit = maxLimit
+		}
+	}
+}
@@ -6,6 +6,7 @@ package index
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -59,3 +60,72 @@ func TestGetVersions(t *testing.T) {
 		})
 	}
 }
+
+func TestGetVersionsBetween(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	var allVersions []*internal.IndexVersion
+	for i := 0; i < 10; i++ {
+		allVersions = append(allVersions, &internal.IndexVersion{
+			Path:      "github.com/my/module",
+			Version:   fmt.Sprintf("v1.%d.0", i),
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	client, teardown := SetupTestIndex(t, allVersions)
+	defer teardown()
+
+	for _, tc := range []struct {
+		name         string
+		since, until time.Time
+		pageSize     int
+		want         []*internal.IndexVersion
+	}{
+		{"all", start, start.Add(time.Hour), 3, allVersions},
+		{"window", start.Add(2 * time.Minute), start.Add(7 * time.Minute), 2, allVersions[2:7]},
+		{"empty window", start.Add(time.Hour), start.Add(2 * time.Hour), 3, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := client.GetVersionsBetween(ctx, tc.since, tc.until, tc.pageSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("GetVersionsBetween(ctx, %s, %s, %d) mismatch (-want +got):\n%s", tc.since, tc.until, tc.pageSize, diff)
+			}
+		})
+	}
+}
+
+func TestGetVersionsBetweenSameSecond(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// More versions than fit in a page have timestamps in the same second.
+	start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	var allVersions []*internal.IndexVersion
+	for i := 0; i < 7; i++ {
+		allVersions = append(allVersions, &internal.IndexVersion{
+			Path:      "github.com/my/module",
+			Version:   fmt.Sprintf("v1.%d.0", i),
+			Timestamp: start.Add(time.Second + time.Duration(i)*time.Millisecond),
+		})
+	}
+	allVersions = append(allVersions, &internal.IndexVersion{
+		Path:      "github.com/my/module",
+		Version:   "v2.0.0",
+		Timestamp: start.Add(time.Minute),
+	})
+	client, teardown := SetupTestIndex(t, allVersions)
+	defer teardown()
+
+	got, err := client.GetVersionsBetween(ctx, start, start.Add(time.Hour), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(allVersions, got); diff != "" {
+		t.Errorf("GetVersionsBetween mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"strconv"
 	"testing"
+	"time"
 
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/testing/testhelper"
@@ -30,9 +31,25 @@ func SetupTestIndex(t *testing.T, versions []*internal.IndexVersion) (*Client, f
 					t.Fatalf("error parsing limit parameter: %v", err)
 				}
 			}
+			var since time.Time
+			if sinceParam := r.FormValue("since"); sinceParam != "" {
+				var err error
+				since, err = time.Parse(time.RFC3339, sinceParam)
+				if err != nil {
+					t.Fatalf("error parsing since parameter: %v", err)
+				}
+			}
 			w.Header().Set("Content-Type", "application/json")
-			for i := 0; i < limit && i < len(versions); i++ {
-				json.NewEncoder(w).Encode(versions[i])
+			n := 0
+			for _, v := range versions {
+				if n >= limit {
+					break
+				}
+				if v.Timestamp.Before(since) {
+					continue
+				}
+				json.NewEncoder(w).Encode(v)
+				n++
 			}
 		}))
 
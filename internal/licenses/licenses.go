@@ -57,6 +57,9 @@ var maxLicenseSize uint64 = 1e7
 type Metadata struct {
 	// Types is the set of license types, as determined by the licensecheck package.
 	Types []string
+	// Expression is the SPDX license expression for the file, in canonical
+	// form, or empty if it is not known. See ParseExpression.
+	Expression string
 	// FilePath is the '/'-separated path to the license file in the module zip,
 	// relative to the contents directory.
 	FilePath string
@@ -64,6 +67,24 @@ type Metadata struct {
 	Coverage licensecheck.Coverage
 }
 
+// IsLowConfidence reports whether the classification of the license file is
+// based on less than classifyThreshold percent coverage by licensecheck, so
+// that a decision about redistributability made from it deserves review.
+// Files classified by exception (see exceptions.go) are not low-confidence,
+// even though they have no coverage.
+func (m *Metadata) IsLowConfidence() bool {
+	if m.Coverage.Percent >= classifyThreshold {
+		return false
+	}
+	isException := m.Coverage.Percent == 0 && len(m.Types) > 0
+	for _, t := range m.Types {
+		if t == unknownLicenseType {
+			isException = false
+		}
+	}
+	return !isException
+}
+
 // A License is a classified license file path and its contents.
 type License struct {
 	*Metadata
@@ -76,6 +97,7 @@ var (
 		"COPYING.md",
 		"COPYING.markdown",
 		"COPYING.txt",
+		"COPYING.lesser",
 		"LICENCE",
 		"LICENCE.md",
 		"LICENCE.markdown",
@@ -201,7 +223,7 @@ type AcceptedLicenseInfo struct {
 // redistributable. Its result is intended to be displayed to users.
 func AcceptedLicenses() []AcceptedLicenseInfo {
 	var lics []AcceptedLicenseInfo
-	for l := range redistributableLicenseTypes {
+	for _, l := range getPolicy().acceptedTypes() {
 		osiName := osiNameOverrides[l]
 		if osiName == "" {
 			osiName = l
@@ -224,9 +246,11 @@ type Detector struct {
 	version        string
 	zr             *zip.Reader
 	logf           func(string, ...interface{})
+	policy         *Policy
 	moduleRedist   bool
 	moduleLicenses []*License // licenses at module root directory, or list from exceptions
 	allLicenses    []*License
+	notices        []*Notice
 	licsByDir      map[string][]*License // from directory to list of licenses
 }
 
@@ -242,6 +266,7 @@ func NewDetector(modulePath, version string, zr *zip.Reader, logf func(string, .
 		version:    version,
 		zr:         zr,
 		logf:       logf,
+		policy:     getPolicy(),
 	}
 	d.computeModuleInfo()
 	return d
@@ -289,8 +314,7 @@ func (d *Detector) PackageInfo(dir string) (isRedistributable bool, lics []*Lice
 	// Note that this is not the same as asking if the module licenses plus the
 	// package licenses are redistributable. A module that is granted an
 	// exception (see Detector.isException) may licenses that are non-redistributable.
-	ltypes := types(lics)
-	isRedistributable = d.ModuleIsRedistributable() && (len(ltypes) == 0 || Redistributable(ltypes))
+	isRedistributable = d.ModuleIsRedistributable() && (len(types(lics)) == 0 || d.policy.Redistributable(d.modulePath, lics))
 	// A package's licenses include the ones we've already computed, as well
 	// as the module licenses.
 	return isRedistributable, append(lics, d.moduleLicenses...)
@@ -300,7 +324,7 @@ func (d *Detector) PackageInfo(dir string) (isRedistributable bool, lics []*Lice
 func (d *Detector) computeModuleInfo() {
 	// Check that all licenses in the contents directory are redistributable.
 	d.moduleLicenses = d.detectFiles(d.Files(RootFiles))
-	d.moduleRedist = Redistributable(types(d.moduleLicenses))
+	d.moduleRedist = d.policy.Redistributable(d.modulePath, d.moduleLicenses)
 }
 
 // computeAllLicenseInfo collects all the detected licenses in the zip and
@@ -333,15 +357,22 @@ const (
 // Files returns a list of license files from the zip. The which argument
 // determines the location of the files considered.
 func (d *Detector) Files(which WhichFiles) []*zip.File {
+	return d.files(which, fileNamesLowercase, "license")
+}
+
+// files returns the files from the zip whose downcased base names are in
+// names. The which argument determines the location of the files considered,
+// and what describes the files in log messages.
+func (d *Detector) files(which WhichFiles, names map[string]bool, what string) []*zip.File {
 	cdir := contentsDir(d.modulePath, d.version)
 	prefix := pathPrefix(cdir)
 	var files []*zip.File
 	for _, f := range d.zr.File {
-		if !fileNamesLowercase[strings.ToLower(path.Base(f.Name))] {
+		if !names[strings.ToLower(path.Base(f.Name))] {
 			continue
 		}
 		if !strings.HasPrefix(f.Name, prefix) {
-			d.logf("potential license file %q found outside of the expected path %q", f.Name, cdir)
+			d.logf("potential %s file %q found outside of the expected path %q", what, f.Name, cdir)
 			continue
 		}
 		// Skip files we should ignore.
@@ -410,9 +441,10 @@ func (d *Detector) detectFiles(files []*zip.File) []*License {
 		types, cov := DetectFile(bytes, f.Name, d.logf)
 		licenses = append(licenses, &License{
 			Metadata: &Metadata{
-				Types:    types,
-				FilePath: strings.TrimPrefix(f.Name, prefix),
-				Coverage: cov,
+				Types:      types,
+				Expression: detectExpression(bytes, types),
+				FilePath:   strings.TrimPrefix(f.Name, prefix),
+				Coverage:   cov,
 			},
 			Contents: bytes,
 		})
@@ -454,13 +486,16 @@ func DetectFile(contents []byte, filename string, logf func(string, ...interface
 }
 
 // Redistributable reports whether the set of license types establishes that a
-// module or package is redistributable.
+// module or package is redistributable under the current policy (see
+// SetPolicy). Rules of the policy that restrict modules, files or coverage are
+// not considered.
 func Redistributable(licenseTypes []string) bool {
 	if len(licenseTypes) == 0 {
 		return false
 	}
+	p := getPolicy()
 	for _, t := range licenseTypes {
-		if !redistributableLicenseTypes[t] && !ignorableLicenseTypes[t] {
+		if p.typeAction(t) == actionDeny {
 			return false
 		}
 	}
@@ -255,14 +255,14 @@ func TestModuleIsRedistributable(t *testing.T) {
 			module:    "golang.org/x/time",
 			version:   "v0.0.0-20191024005414-555d28b269f0",
 			want:      true,
-			wantMetas: []*Metadata{{Types: []string{"BSD-3-Clause"}, FilePath: "LICENSE"}},
+			wantMetas: []*Metadata{{Types: []string{"BSD-3-Clause"}, Expression: "BSD-3-Clause", FilePath: "LICENSE"}},
 		},
 		{
 			filename:  "smasher",
 			module:    "github.com/smasher164/mem",
 			version:   "v0.0.0-20191114064341-4e07bd0f0d69",
 			want:      true,
-			wantMetas: []*Metadata{{Types: []string{"BSD-0-Clause"}, FilePath: "LICENSE.md"}},
+			wantMetas: []*Metadata{{Types: []string{"BSD-0-Clause"}, Expression: "BSD-0-Clause", FilePath: "LICENSE.md"}},
 		},
 		{
 			filename: "gioui",
@@ -270,8 +270,8 @@ func TestModuleIsRedistributable(t *testing.T) {
 			version:  "v0.0.0-20200103103112-ccbcbdbfbd4f",
 			want:     true,
 			wantMetas: []*Metadata{
-				{Types: []string{"MIT"}, FilePath: "LICENSE-MIT"},
-				{Types: []string{"Unlicense"}, FilePath: "UNLICENSE"},
+				{Types: []string{"MIT"}, Expression: "MIT", FilePath: "LICENSE-MIT"},
+				{Types: []string{"Unlicense"}, Expression: "Unlicense", FilePath: "UNLICENSE"},
 			},
 		},
 		{
@@ -280,9 +280,9 @@ func TestModuleIsRedistributable(t *testing.T) {
 			version:  "v0.6.2",
 			want:     true,
 			wantMetas: []*Metadata{
-				{Types: []string{"BSD-3-Clause"}, FilePath: "LICENSE"},
-				{Types: []string{"MIT"}, FilePath: "graph/formats/cytoscapejs/testdata/LICENSE"},
-				{Types: []string{"MIT"}, FilePath: "graph/formats/sigmajs/testdata/LICENSE.txt"},
+				{Types: []string{"BSD-3-Clause"}, Expression: "BSD-3-Clause", FilePath: "LICENSE"},
+				{Types: []string{"MIT"}, Expression: "MIT", FilePath: "graph/formats/cytoscapejs/testdata/LICENSE"},
+				{Types: []string{"MIT"}, Expression: "MIT", FilePath: "graph/formats/sigmajs/testdata/LICENSE.txt"},
 			},
 		},
 	} {
@@ -350,6 +350,8 @@ func TestFiles(t *testing.T) {
 		"LICENCE":            "",
 		"License":            "",
 		"COPYING":            "",
+		"COPYING.LESSER":     "",
+		"NOTICE":             "", // not a license file
 		"liCeNse":            "",
 		"foo/LICENSE":        "",
 		"foo/LICENSE.md":     "",
@@ -366,8 +368,8 @@ func TestFiles(t *testing.T) {
 	}{
 		{
 			RootFiles,
-			[]string{"m@v1/LICENSE", "m@v1/LICENCE", "m@v1/License", "m@v1/COPYING", "m@v1/LICENSE.md",
-				"m@v1/liCeNse"},
+			[]string{"m@v1/LICENSE", "m@v1/LICENCE", "m@v1/License", "m@v1/COPYING", "m@v1/COPYING.LESSER",
+				"m@v1/LICENSE.md", "m@v1/liCeNse"},
 		},
 		{
 			NonRootFiles,
@@ -379,8 +381,8 @@ func TestFiles(t *testing.T) {
 		{
 			AllFiles,
 			[]string{
-				"m@v1/LICENSE", "m@v1/LICENCE", "m@v1/License", "m@v1/COPYING", "m@v1/LICENSE.md",
-				"m@v1/liCeNse", "m@v1/foo/LICENSE", "m@v1/foo/LICENSE.md", "m@v1/foo/LICENCE", "m@v1/foo/License",
+				"m@v1/LICENSE", "m@v1/LICENCE", "m@v1/License", "m@v1/COPYING", "m@v1/COPYING.LESSER",
+				"m@v1/LICENSE.md", "m@v1/liCeNse", "m@v1/foo/LICENSE", "m@v1/foo/LICENSE.md", "m@v1/foo/LICENCE", "m@v1/foo/License",
 				"m@v1/foo/license", "m@v1/foo/COPYING", "m@v1/pkg/vendor/LICENSE",
 			},
 		},
@@ -413,7 +415,7 @@ func TestDetectFiles(t *testing.T) {
 			contents: map[string]string{
 				"foo/LICENSE": mitLicense,
 			},
-			want: []*Metadata{{Types: []string{"MIT"}, FilePath: "foo/LICENSE", Coverage: mitCoverage}},
+			want: []*Metadata{{Types: []string{"MIT"}, Expression: "MIT", FilePath: "foo/LICENSE", Coverage: mitCoverage}},
 		},
 
 		{
@@ -424,12 +426,12 @@ func TestDetectFiles(t *testing.T) {
 				"COPYING":        bsd0License,
 			},
 			want: []*Metadata{
-				{Types: []string{"BSD-0-Clause"}, FilePath: "COPYING", Coverage: lc.Coverage{
+				{Types: []string{"BSD-0-Clause"}, Expression: "BSD-0-Clause", FilePath: "COPYING", Coverage: lc.Coverage{
 					Percent: 100,
 					Match:   []lc.Match{{Name: "BSD-0-Clause", Type: lc.BSD, Percent: 100}},
 				}},
-				{Types: []string{"MIT"}, FilePath: "LICENSE", Coverage: mitCoverage},
-				{Types: []string{"MIT"}, FilePath: "foo/LICENSE.md", Coverage: mitCoverage},
+				{Types: []string{"MIT"}, Expression: "MIT", FilePath: "LICENSE", Coverage: mitCoverage},
+				{Types: []string{"MIT"}, Expression: "MIT", FilePath: "foo/LICENSE.md", Coverage: mitCoverage},
 			},
 		},
 		{
@@ -438,7 +440,7 @@ func TestDetectFiles(t *testing.T) {
 				"LICENSE": mitLicense + "\n" + bsd0License,
 			},
 			want: []*Metadata{
-				{Types: []string{"BSD-0-Clause", "MIT"}, FilePath: "LICENSE", Coverage: lc.Coverage{
+				{Types: []string{"BSD-0-Clause", "MIT"}, Expression: "BSD-0-Clause AND MIT", FilePath: "LICENSE", Coverage: lc.Coverage{
 					Percent: 100,
 					Match: []lc.Match{
 						{Name: "MIT", Type: lc.MIT, Percent: 100},
@@ -498,9 +500,10 @@ func TestDetectFiles(t *testing.T) {
 					FilePath: "COPYING",
 				},
 				{
-					Types:    []string{"MIT"},
-					FilePath: "LICENSE",
-					Coverage: mitCoverage,
+					Types:      []string{"MIT"},
+					Expression: "MIT",
+					FilePath:   "LICENSE",
+					Coverage:   mitCoverage,
 				},
 			},
 		},
@@ -511,8 +514,9 @@ func TestDetectFiles(t *testing.T) {
 			},
 			want: []*Metadata{
 				{
-					Types:    []string{"Apache-2.0"},
-					FilePath: "LICENSE",
+					Types:      []string{"Apache-2.0"},
+					Expression: "Apache-2.0",
+					FilePath:   "LICENSE",
 					Coverage: lc.Coverage{
 						Percent: 100,
 						Match: []lc.Match{{
@@ -555,7 +559,11 @@ func TestPackageInfo(t *testing.T) {
 		version = "v1.2.3"
 	)
 	meta := func(typ, path string) *Metadata {
-		return &Metadata{Types: []string{typ}, FilePath: path}
+		m := &Metadata{Types: []string{typ}, FilePath: path}
+		if typ != unknownLicenseType {
+			m.Expression = typ
+		}
+		return m
 	}
 
 	for _, test := range []struct {
@@ -723,3 +731,23 @@ func coveragePercentEqual(a, b float64) bool {
 	}
 	return math.Abs(a-b) <= 4
 }
+
+func TestIsLowConfidence(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		md   Metadata
+		want bool
+	}{
+		{"high coverage", Metadata{Types: []string{"MIT"}, Coverage: lc.Coverage{Percent: 95}}, false},
+		{"low coverage", Metadata{Types: []string{"MIT"}, Coverage: lc.Coverage{Percent: 80}}, true},
+		{"unknown", Metadata{Types: []string{unknownLicenseType}, Coverage: lc.Coverage{Percent: 50}}, true},
+		{"check failed", Metadata{Types: []string{unknownLicenseType}}, true},
+		{"exception", Metadata{Types: []string{"BSD-3-Clause"}}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.md.IsLowConfidence(); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
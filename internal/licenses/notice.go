@@ -0,0 +1,92 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package licenses
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Kinds of files that are found along with licenses.
+const (
+	// KindLicense is the kind of a license file.
+	KindLicense = "license"
+	// KindNotice is the kind of a NOTICE file, which licenses like
+	// Apache-2.0 require to be passed on with the licensed work.
+	KindNotice = "notice"
+	// KindPatents is the kind of a file that grants or describes patent
+	// rights.
+	KindPatents = "patents"
+)
+
+// A Notice is a file that accompanies the licenses of a module without being
+// a license itself, like a NOTICE or PATENTS file. Notices are not classified
+// and do not affect whether a module is redistributable.
+type Notice struct {
+	// Kind is KindNotice or KindPatents.
+	Kind string
+	// FilePath is the '/'-separated path to the file in the module zip,
+	// relative to the contents directory.
+	FilePath string
+	Contents []byte
+}
+
+// NoticeFileNames are the names of the files collected as notices, by kind.
+var NoticeFileNames = map[string][]string{
+	KindNotice: {
+		"NOTICE",
+		"NOTICE.md",
+		"NOTICE.markdown",
+		"NOTICE.txt",
+	},
+	KindPatents: {
+		"PATENTS",
+		"PATENTS.md",
+		"PATENTS.markdown",
+		"PATENTS.txt",
+	},
+}
+
+// noticeKindsLowercase maps the downcased entries of NoticeFileNames to their
+// kind.
+var noticeKindsLowercase = map[string]string{}
+
+// noticeFileNamesLowercase is the set of keys of noticeKindsLowercase.
+var noticeFileNamesLowercase = map[string]bool{}
+
+func init() {
+	for kind, names := range NoticeFileNames {
+		for _, n := range names {
+			noticeKindsLowercase[strings.ToLower(n)] = kind
+			noticeFileNamesLowercase[strings.ToLower(n)] = true
+		}
+	}
+}
+
+// Notices returns all the notice files in the module, sorted by path. Files
+// that cannot be read are logged and skipped.
+func (d *Detector) Notices() []*Notice {
+	if d.notices != nil {
+		return d.notices
+	}
+	d.notices = []*Notice{}
+	prefix := pathPrefix(contentsDir(d.modulePath, d.version))
+	for _, f := range d.files(AllFiles, noticeFileNamesLowercase, "notice") {
+		contents, err := readZipFile(f)
+		if err != nil {
+			d.logf("reading zip file %s: %v", f.Name, err)
+			continue
+		}
+		filePath := strings.TrimPrefix(f.Name, prefix)
+		d.notices = append(d.notices, &Notice{
+			Kind:     noticeKindsLowercase[strings.ToLower(path.Base(filePath))],
+			FilePath: filePath,
+			Contents: contents,
+		})
+	}
+	sort.Slice(d.notices, func(i, j int) bool { return d.notices[i].FilePath < d.notices[j].FilePath })
+	return d.notices
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package licenses
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNotices(t *testing.T) {
+	zr := newZipReader(t, "m@v1", map[string]string{
+		"LICENSE":           mitLicense,
+		"NOTICE":            "root notice",
+		"foo/notice.txt":    "foo notice",
+		"foo/PATENTS":       "foo patents",
+		"vendor/pkg/NOTICE": "vendored notice", // vendored files ignored
+		"NOTICES.txt":       "not a notice file",
+	})
+	d := NewDetector("m", "v1", zr, nil)
+	got := d.Notices()
+	want := []*Notice{
+		{Kind: KindNotice, FilePath: "NOTICE", Contents: []byte("root notice")},
+		{Kind: KindPatents, FilePath: "foo/PATENTS", Contents: []byte("foo patents")},
+		{Kind: KindNotice, FilePath: "foo/notice.txt", Contents: []byte("foo notice")},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+	// Notices are not licenses.
+	if !d.ModuleIsRedistributable() {
+		t.Error("module is not redistributable, want redistributable")
+	}
+	if n := len(d.AllLicenses()); n != 1 {
+		t.Errorf("got %d licenses, want 1", n)
+	}
+}
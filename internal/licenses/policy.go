@@ -0,0 +1,229 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package licenses
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+	"golang.org/x/pkgsite/internal/globs"
+)
+
+// A Policy decides which licenses allow a module or package to be
+// redistributed, that is, to have its documentation and source displayed.
+//
+// A policy is a list of rules. The action for a license type found in a
+// license file is that of the first rule that matches it; a type that no
+// rule matches is denied. A set of license files is redistributable if it
+// contains at least one license type and none of its types are denied.
+//
+// A policy is read from YAML like this:
+//
+//	rules:
+//	# Allow a company license, but only in the company's modules.
+//	- types: [Example-Corp-1.0]
+//	  modules: [corp.example.com]
+//	  action: allow
+//	# Require a stronger match than usual for this license.
+//	- types: [BSD-2-Clause]
+//	  minCoverage: 95
+//	  action: allow
+//	# Licenses of test data don't matter.
+//	- files: ["*/testdata/*"]
+//	  action: ignore
+//
+// The rules of the default policy are appended unless replaceDefault is true.
+type Policy struct {
+	Rules []*Rule `json:"rules"`
+	// ReplaceDefault drops the rules of the default policy.
+	ReplaceDefault bool `json:"replaceDefault"`
+}
+
+// A Rule of a Policy. A rule matches a license type in a license file if
+// every one of its restrictions that is non-empty holds.
+type Rule struct {
+	// Types are the license types that the rule matches, as reported by
+	// licensecheck.
+	Types []string `json:"types"`
+	// Modules are glob patterns, as with GOPRIVATE, for the paths of the
+	// modules that the rule matches.
+	Modules []string `json:"modules"`
+	// Files are path.Match patterns for the paths of license files, relative
+	// to the module root, that the rule matches.
+	Files []string `json:"files"`
+	// MinCoverage is the minimum percentage of the license file that
+	// licensecheck must recognize for the rule to match. Files that are
+	// classified by exception (see exceptions.go) have no coverage.
+	MinCoverage float64 `json:"minCoverage"`
+	// Action is what to do with a matching license type: "allow", "deny" or
+	// "ignore".
+	Action string `json:"action"`
+}
+
+const (
+	actionAllow  = "allow"
+	actionDeny   = "deny"
+	actionIgnore = "ignore"
+)
+
+// DefaultPolicy returns the policy of pkg.go.dev: it allows the types in
+// redistributableLicenseTypes and ignores those in ignorableLicenseTypes.
+func DefaultPolicy() *Policy {
+	return &Policy{Rules: defaultRules(), ReplaceDefault: true}
+}
+
+func defaultRules() []*Rule {
+	return []*Rule{
+		{Types: setToSortedSlice(ignorableLicenseTypes), Action: actionIgnore},
+		{Types: setToSortedSlice(redistributableLicenseTypes), Action: actionAllow},
+	}
+}
+
+// ParsePolicy parses a policy from YAML or JSON.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing license policy: %v", err)
+	}
+	for i, r := range p.Rules {
+		switch r.Action {
+		case actionAllow, actionDeny, actionIgnore:
+		default:
+			return nil, fmt.Errorf("license policy rule %d: unknown action %q", i, r.Action)
+		}
+		for _, f := range append(r.Files, r.Modules...) {
+			if _, err := path.Match(f, ""); err != nil {
+				return nil, fmt.Errorf("license policy rule %d: bad pattern %q", i, f)
+			}
+		}
+	}
+	if !p.ReplaceDefault {
+		p.Rules = append(p.Rules, defaultRules()...)
+		p.ReplaceDefault = true
+	}
+	return &p, nil
+}
+
+// ReadPolicyFile reads a policy from the named file. See ParsePolicy.
+func ReadPolicyFile(filename string) (*Policy, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+var (
+	policyMu      sync.Mutex
+	currentPolicy = DefaultPolicy()
+)
+
+// SetPolicy sets the policy used by Detectors created after it is called,
+// and by Redistributable and AcceptedLicenses.
+func SetPolicy(p *Policy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	currentPolicy = p
+}
+
+func getPolicy() *Policy {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	return currentPolicy
+}
+
+// Redistributable reports whether the license files lics of the module with
+// the given path allow redistribution.
+func (p *Policy) Redistributable(modulePath string, lics []*License) bool {
+	found := false
+	for _, l := range lics {
+		for _, t := range l.Types {
+			if p.action(modulePath, l.Metadata, t) == actionDeny {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// action returns the action of the first rule matching the license type t
+// in the file l.
+func (p *Policy) action(modulePath string, l *Metadata, t string) string {
+	for _, r := range p.Rules {
+		if r.matches(modulePath, l, t) {
+			return r.Action
+		}
+	}
+	return actionDeny
+}
+
+// typeAction returns the action for the license type t, considering only the
+// rules that don't depend on the module or file.
+func (p *Policy) typeAction(t string) string {
+	for _, r := range p.Rules {
+		if r.unconditional() && (len(r.Types) == 0 || contains(r.Types, t)) {
+			return r.Action
+		}
+	}
+	return actionDeny
+}
+
+func (r *Rule) matches(modulePath string, l *Metadata, t string) bool {
+	if len(r.Types) > 0 && !contains(r.Types, t) {
+		return false
+	}
+	if len(r.Modules) > 0 && !globs.MatchPrefixPatterns(strings.Join(r.Modules, ","), modulePath) {
+		return false
+	}
+	if len(r.Files) > 0 && !matchAny(r.Files, l.FilePath) {
+		return false
+	}
+	return l.Coverage.Percent >= r.MinCoverage
+}
+
+func (r *Rule) unconditional() bool {
+	return len(r.Modules) == 0 && len(r.Files) == 0 && r.MinCoverage == 0
+}
+
+// acceptedTypes returns the license types that p allows in every module and
+// file.
+func (p *Policy) acceptedTypes() []string {
+	var ts []string
+	seen := map[string]bool{}
+	for _, r := range p.Rules {
+		for _, t := range r.Types {
+			if !seen[t] && p.typeAction(t) == actionAllow {
+				ts = append(ts, t)
+			}
+			seen[t] = true
+		}
+	}
+	sort.Strings(ts)
+	return ts
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package licenses
+
+import (
+	"testing"
+
+	lc "github.com/google/licensecheck"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy([]byte(`
+rules:
+- types: [CommonsClause]
+  modules: [corp.example.com]
+  action: allow
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(p.Rules), 1+len(defaultRules()); got != want {
+		t.Errorf("got %d rules, want %d", got, want)
+	}
+
+	p, err = ParsePolicy([]byte(`
+replaceDefault: true
+rules:
+- action: allow
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(p.Rules); got != 1 {
+		t.Errorf("got %d rules, want 1", got)
+	}
+
+	for _, bad := range []string{
+		"rules: [{action: permit}]",
+		"rules: [{action: allow, files: ['[']}]",
+		"rules: 3",
+	} {
+		if _, err := ParsePolicy([]byte(bad)); err == nil {
+			t.Errorf("%q: got nil error, want error", bad)
+		}
+	}
+}
+
+func TestPolicyRedistributable(t *testing.T) {
+	p, err := ParsePolicy([]byte(`
+rules:
+- types: [CommonsClause]
+  modules: [corp.example.com, "*.corp.test"]
+  action: allow
+- files: ["*/testdata/*"]
+  action: ignore
+- types: [BSD-2-Clause]
+  minCoverage: 95
+  action: allow
+- types: [BSD-2-Clause]
+  action: deny
+- types: [Unlicense]
+  action: deny
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lic := func(typ, file string, coverage float64) *License {
+		return &License{Metadata: &Metadata{
+			Types:    []string{typ},
+			FilePath: file,
+			Coverage: lc.Coverage{Percent: coverage},
+		}}
+	}
+	for _, test := range []struct {
+		name   string
+		module string
+		lics   []*License
+		want   bool
+	}{
+		{"none", "m", nil, false},
+		{"default allow", "m", []*License{lic("MIT", "LICENSE", 100)}, true},
+		{"default deny", "m", []*License{lic("CommonsClause", "LICENSE", 100)}, false},
+		{"module allow", "corp.example.com/a", []*License{lic("CommonsClause", "LICENSE", 100)}, true},
+		{"module glob allow", "x.corp.test/b", []*License{lic("CommonsClause", "LICENSE", 100)}, true},
+		{"module prefix only", "corp.example.com.evil/a", []*License{lic("CommonsClause", "LICENSE", 100)}, false},
+		{"file ignore", "m", []*License{lic("MIT", "LICENSE", 100), lic(unknownLicenseType, "a/testdata/LICENSE", 0)}, true},
+		{"coverage high", "m", []*License{lic("BSD-2-Clause", "LICENSE", 98)}, true},
+		{"coverage low", "m", []*License{lic("BSD-2-Clause", "LICENSE", 92)}, false},
+		{"override default", "m", []*License{lic("MIT", "LICENSE", 100), lic("Unlicense", "UNLICENSE", 100)}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := p.Redistributable(test.module, test.lics); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+	for t1 := range redistributableLicenseTypes {
+		if got := p.typeAction(t1); got != actionAllow {
+			t.Errorf("%s: got %q, want %q", t1, got, actionAllow)
+		}
+	}
+	for t1 := range ignorableLicenseTypes {
+		if got := p.typeAction(t1); got != actionIgnore {
+			t.Errorf("%s: got %q, want %q", t1, got, actionIgnore)
+		}
+	}
+	if got := p.typeAction(unknownLicenseType); got != actionDeny {
+		t.Errorf("%s: got %q, want %q", unknownLicenseType, got, actionDeny)
+	}
+	if got, want := len(p.acceptedTypes()), len(redistributableLicenseTypes); got != want {
+		t.Errorf("got %d accepted types, want %d", got, want)
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	defer SetPolicy(getPolicy())
+	p, err := ParsePolicy([]byte("rules: [{types: [CommonsClause], action: allow}]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetPolicy(p)
+	if !Redistributable([]string{"MIT", "CommonsClause"}) {
+		t.Error("got false, want true")
+	}
+	found := false
+	for _, l := range AcceptedLicenses() {
+		if l.Name == "CommonsClause" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("CommonsClause not in AcceptedLicenses")
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package licenses
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An Expression is a parsed SPDX license expression, like
+// "MIT OR Apache-2.0" or "GPL-2.0-only WITH Classpath-exception-2.0".
+// See https://spdx.github.io/spdx-spec/appendix-IV-SPDX-license-expressions/.
+type Expression interface {
+	// String returns the expression in canonical form: operators in upper
+	// case, single spaces between terms, and only the parentheses that are
+	// needed.
+	String() string
+
+	// addLicenses calls add for each license identifier in the expression.
+	addLicenses(add func(string))
+}
+
+// ExpressionLicenses returns the distinct license identifiers in e, sorted.
+// Exceptions are not included.
+func ExpressionLicenses(e Expression) []string {
+	set := map[string]bool{}
+	e.addLicenses(func(id string) { set[id] = true })
+	return setToSortedSlice(set)
+}
+
+// A licenseRef is a single license identifier, like "MIT" or
+// "LicenseRef-Custom". The "+" suffix is part of the identifier.
+type licenseRef string
+
+func (l licenseRef) String() string               { return string(l) }
+func (l licenseRef) addLicenses(add func(string)) { add(string(l)) }
+
+// A withException is a license with an exception.
+type withException struct {
+	license   licenseRef
+	exception string
+}
+
+func (w withException) String() string               { return string(w.license) + " WITH " + w.exception }
+func (w withException) addLicenses(add func(string)) { add(string(w.license)) }
+
+// A compound joins two expressions with AND or OR.
+type compound struct {
+	op          string // "AND" or "OR"
+	left, right Expression
+}
+
+func (c compound) String() string {
+	return c.operand(c.left) + " " + c.op + " " + c.operand(c.right)
+}
+
+// operand formats an operand of c, parenthesizing it if it binds less tightly
+// than c.
+func (c compound) operand(e Expression) string {
+	if sub, ok := e.(compound); ok && c.op == "AND" && sub.op == "OR" {
+		return "(" + sub.String() + ")"
+	}
+	return e.String()
+}
+
+func (c compound) addLicenses(add func(string)) {
+	c.left.addLicenses(add)
+	c.right.addLicenses(add)
+}
+
+// ParseExpression parses an SPDX license expression. Operators may be in
+// any case. WITH binds more tightly than AND, which binds more tightly than
+// OR.
+func ParseExpression(s string) (_ Expression, err error) {
+	p := &exprParser{tokens: tokenizeExpression(s)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("parsing SPDX expression %q: empty expression", s)
+	}
+	e, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing SPDX expression %q: %v", s, err)
+	}
+	return e, nil
+}
+
+// tokenizeExpression splits s into parentheses and words.
+func tokenizeExpression(s string) []string {
+	var tokens []string
+	for _, f := range strings.Fields(s) {
+		for f != "" {
+			i := strings.IndexAny(f, "()")
+			switch {
+			case i < 0:
+				tokens = append(tokens, f)
+				f = ""
+			case i == 0:
+				tokens = append(tokens, f[:1])
+				f = f[1:]
+			default:
+				tokens = append(tokens, f[:i])
+				f = f[i:]
+			}
+		}
+	}
+	return tokens
+}
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+// peekOp reports whether the next token is the operator op.
+func (p *exprParser) peekOp(op string) bool {
+	return p.pos < len(p.tokens) && strings.EqualFold(p.tokens[p.pos], op)
+}
+
+func (p *exprParser) parseOr() (Expression, error) {
+	return p.parseCompound("OR", p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (Expression, error) {
+	return p.parseCompound("AND", p.parseWith)
+}
+
+// parseCompound parses a left-associative sequence of operands joined by op.
+func (p *exprParser) parseCompound(op string, parseOperand func() (Expression, error)) (Expression, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOp(op) {
+		p.pos++
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = compound{op, left, right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseWith() (Expression, error) {
+	if p.pos < len(p.tokens) && p.tokens[p.pos] == "(" {
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos] != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return e, nil
+	}
+	id, err := p.parseID()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekOp("WITH") {
+		return id, nil
+	}
+	p.pos++
+	exc, err := p.parseID()
+	if err != nil {
+		return nil, err
+	}
+	return withException{id, string(exc)}, nil
+}
+
+// idRegexp matches license and exception identifiers: letters, digits, '.'
+// and '-', optionally followed by '+', and the "DocumentRef-x:" prefix of
+// license references in other documents.
+var idRegexp = regexp.MustCompile(`^(DocumentRef-[A-Za-z0-9.-]+:)?[A-Za-z0-9.-]+\+?$`)
+
+func (p *exprParser) parseID() (licenseRef, error) {
+	if p.pos >= len(p.tokens) {
+		return "", fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	if !idRegexp.MatchString(t) || p.peekOp("AND") || p.peekOp("OR") || p.peekOp("WITH") {
+		return "", fmt.Errorf("unexpected %q", t)
+	}
+	p.pos++
+	return licenseRef(t), nil
+}
+
+// spdxIdentifierRegexp matches an SPDX-License-Identifier line, capturing the
+// expression.
+var spdxIdentifierRegexp = regexp.MustCompile(`(?m)SPDX-License-Identifier:[ \t]*(.*?)[ \t]*(?:\*/|-->)?[ \t]*$`)
+
+// detectExpression returns the SPDX expression, in canonical form, for a
+// license file with the given contents and detected types. An
+// SPDX-License-Identifier line in the file takes precedence; otherwise the
+// types are all taken to apply, as they are for redistributability. It returns
+// the empty string if there is no identifier and the types are unknown.
+func detectExpression(contents []byte, types []string) string {
+	if m := spdxIdentifierRegexp.FindSubmatch(contents); m != nil {
+		if e, err := ParseExpression(string(m[1])); err == nil {
+			return e.String()
+		}
+	}
+	var ids []string
+	for _, t := range types {
+		if t == unknownLicenseType {
+			return ""
+		}
+		if ignorableLicenseTypes[t] {
+			continue
+		}
+		if n := osiNameOverrides[t]; n != "" {
+			t = n
+		}
+		ids = append(ids, t)
+	}
+	sort.Strings(ids)
+	return strings.Join(ids, " AND ")
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package licenses
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseExpression(t *testing.T) {
+	for _, test := range []struct {
+		in, want     string
+		wantLicenses []string
+	}{
+		{"MIT", "MIT", []string{"MIT"}},
+		{"MIT OR Apache-2.0", "MIT OR Apache-2.0", []string{"Apache-2.0", "MIT"}},
+		{"mit or  apache-2.0", "mit OR apache-2.0", []string{"apache-2.0", "mit"}},
+		{"GPL-2.0-only WITH Classpath-exception-2.0", "GPL-2.0-only WITH Classpath-exception-2.0", []string{"GPL-2.0-only"}},
+		{"GPL-2.0+", "GPL-2.0+", []string{"GPL-2.0+"}},
+		{"LicenseRef-Custom AND MIT", "LicenseRef-Custom AND MIT", []string{"LicenseRef-Custom", "MIT"}},
+		{"DocumentRef-spdx-tool-1.2:LicenseRef-MIT-Style-2", "DocumentRef-spdx-tool-1.2:LicenseRef-MIT-Style-2", []string{"DocumentRef-spdx-tool-1.2:LicenseRef-MIT-Style-2"}},
+		// AND binds more tightly than OR.
+		{"MIT AND BSD-3-Clause OR Apache-2.0", "MIT AND BSD-3-Clause OR Apache-2.0", []string{"Apache-2.0", "BSD-3-Clause", "MIT"}},
+		{"MIT AND (BSD-3-Clause OR Apache-2.0)", "MIT AND (BSD-3-Clause OR Apache-2.0)", []string{"Apache-2.0", "BSD-3-Clause", "MIT"}},
+		{"(MIT AND BSD-3-Clause) OR Apache-2.0", "MIT AND BSD-3-Clause OR Apache-2.0", []string{"Apache-2.0", "BSD-3-Clause", "MIT"}},
+		{"((MIT))", "MIT", []string{"MIT"}},
+		{"(MIT OR Apache-2.0) AND (ISC WITH LLVM-exception)", "(MIT OR Apache-2.0) AND ISC WITH LLVM-exception", []string{"Apache-2.0", "ISC", "MIT"}},
+		{"MIT AND MIT", "MIT AND MIT", []string{"MIT"}},
+	} {
+		e, err := ParseExpression(test.in)
+		if err != nil {
+			t.Errorf("ParseExpression(%q): %v", test.in, err)
+			continue
+		}
+		if got := e.String(); got != test.want {
+			t.Errorf("ParseExpression(%q) = %q, want %q", test.in, got, test.want)
+		}
+		if diff := cmp.Diff(test.wantLicenses, ExpressionLicenses(e)); diff != "" {
+			t.Errorf("ExpressionLicenses(%q) mismatch (-want, +got):\n%s", test.in, diff)
+		}
+	}
+}
+
+func TestParseExpressionErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"MIT OR",
+		"OR MIT",
+		"MIT Apache-2.0",
+		"(MIT",
+		"MIT)",
+		"MIT WITH",
+		"MIT WITH (Classpath-exception-2.0)",
+		"(MIT OR ISC) WITH Classpath-exception-2.0",
+		"MIT/Apache",
+	} {
+		if _, err := ParseExpression(in); err == nil {
+			t.Errorf("ParseExpression(%q): got nil, want error", in)
+		}
+	}
+}
+
+func TestDetectExpression(t *testing.T) {
+	for _, test := range []struct {
+		contents string
+		types    []string
+		want     string
+	}{
+		{"", []string{"MIT"}, "MIT"},
+		{"", []string{"MIT", "GPL2", "CC-Notice"}, "GPL-2.0 AND MIT"},
+		{"", []string{unknownLicenseType}, ""},
+		{"", nil, ""},
+		{"// SPDX-License-Identifier: MIT OR Apache-2.0\n...", []string{"MIT", "Apache-2.0"}, "MIT OR Apache-2.0"},
+		{"/* SPDX-License-Identifier: (MIT) */\n", []string{unknownLicenseType}, "MIT"},
+		{"<!-- SPDX-License-Identifier: GPL-2.0-only WITH Classpath-exception-2.0 -->", nil, "GPL-2.0-only WITH Classpath-exception-2.0"},
+		// An identifier that doesn't parse is ignored.
+		{"SPDX-License-Identifier: MIT/X11\n", []string{"MIT"}, "MIT"},
+	} {
+		if got := detectExpression([]byte(test.contents), test.types); got != test.want {
+			t.Errorf("detectExpression(%q, %v) = %q, want %q", test.contents, test.types, got, test.want)
+		}
+	}
+}
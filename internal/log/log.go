@@ -33,6 +33,9 @@ type (
 
 	// labelsKey is the type of the context key for labels.
 	labelsKey struct{}
+
+	// requestLabelsKey is the type of the context key for request labels.
+	requestLabelsKey struct{}
 )
 
 // NewContextWithTraceID creates a new context from ctx that adds the trace ID.
@@ -40,6 +43,13 @@ func NewContextWithTraceID(ctx context.Context, traceID string) context.Context
 	return context.WithValue(ctx, traceIDKey{}, traceID)
 }
 
+// TraceIDFromContext returns the trace ID added to ctx by NewContextWithTraceID,
+// or the empty string if there is none.
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
 // NewContextWithLabel creates anew context from ctx that adds a label that will
 // appear in the log entry.
 func NewContextWithLabel(ctx context.Context, key, value string) context.Context {
@@ -53,6 +63,54 @@ func NewContextWithLabel(ctx context.Context, key, value string) context.Context
 	return context.WithValue(ctx, labelsKey{}, newLabels)
 }
 
+// requestLabels holds the labels of the log entry for a request. They are set
+// while the request is served, possibly on several goroutines.
+type requestLabels struct {
+	mu     sync.Mutex
+	labels map[string]string
+}
+
+// NewContextWithRequestLabels creates a new context from ctx in which
+// SetRequestLabel records labels for the log entry of the request being
+// served. RequestLabels returns them.
+func NewContextWithRequestLabels(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestLabelsKey{}, &requestLabels{labels: map[string]string{}})
+}
+
+// SetRequestLabel sets a label of the log entry for the request being served
+// with ctx, such as the route that served it. Unlike NewContextWithLabel, it
+// applies to the whole request, even if ctx is derived from the context of
+// the request. It does nothing if ctx does not come from
+// NewContextWithRequestLabels.
+func SetRequestLabel(ctx context.Context, key, value string) {
+	rl, ok := ctx.Value(requestLabelsKey{}).(*requestLabels)
+	if !ok {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.labels[key] = value
+}
+
+// RequestLabels returns a copy of the labels set with SetRequestLabel, or nil
+// if there are none.
+func RequestLabels(ctx context.Context) map[string]string {
+	rl, ok := ctx.Value(requestLabelsKey{}).(*requestLabels)
+	if !ok {
+		return nil
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if len(rl.labels) == 0 {
+		return nil
+	}
+	labels := map[string]string{}
+	for k, v := range rl.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
 // stackdriverLogger logs to GCP Stackdriver.
 type stackdriverLogger struct {
 	sdlogger *logging.Logger
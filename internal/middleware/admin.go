@@ -0,0 +1,246 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/auth"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// The ways in which AdminAuth authenticates users.
+const (
+	AdminAuthIAP      = "iap"
+	AdminAuthToken    = "token"
+	AdminAuthInsecure = "insecure"
+)
+
+// An AdminUser is the user of a request authenticated by AdminAuth.
+type AdminUser struct {
+	// Name identifies the user: the email address of an IAP user, or the
+	// name of the holder of a token.
+	Name string
+	// Method is how the user was authenticated: AdminAuthIAP, AdminAuthToken
+	// or AdminAuthInsecure.
+	Method string
+}
+
+type adminUserKey struct{}
+
+// AdminUserFromContext returns the user authenticated by AdminAuth, or nil if
+// there is none.
+func AdminUserFromContext(ctx context.Context) *AdminUser {
+	u, _ := ctx.Value(adminUserKey{}).(*AdminUser)
+	return u
+}
+
+// AdminAuthConfig configures AdminAuth.
+type AdminAuthConfig struct {
+	// VerifyIAP verifies the assertion that Identity-Aware Proxy sends in the
+	// auth.IAPAssertionHeader header, and returns the email address of the
+	// user. If it is nil, IAP users are not accepted.
+	VerifyIAP func(ctx context.Context, assertion string) (email string, err error)
+	// Tokens maps the bearer tokens that are accepted in the Authorization
+	// header to the names of their holders, such as scripts.
+	Tokens map[string]string
+	// Insecure lets requests without credentials through, as the user
+	// "local". It is for development, and must not be set in production.
+	Insecure bool
+}
+
+// AdminAuth returns a Middleware that only lets through requests from
+// authenticated users, and serves 401 (Unauthorized) for others. A request
+// is authenticated by a bearer token in its Authorization header, or by an
+// assertion from Identity-Aware Proxy. The user is available to handlers from
+// AdminUserFromContext.
+func AdminAuth(cfg AdminAuthConfig) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			u, err := authenticateAdmin(ctx, cfg, r)
+			if err != nil {
+				log.Infof(ctx, "AdminAuth: rejecting %s %s: %v", r.Method, r.URL.Path, err)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			log.SetRequestLabel(ctx, "admin_user", u.Name)
+			h.ServeHTTP(w, r.WithContext(context.WithValue(ctx, adminUserKey{}, u)))
+		})
+	}
+}
+
+func authenticateAdmin(ctx context.Context, cfg AdminAuthConfig, r *http.Request) (*AdminUser, error) {
+	if h := r.Header.Get("Authorization"); h != "" {
+		token := strings.TrimPrefix(h, "Bearer ")
+		if token == h {
+			return nil, errors.New("Authorization header is not a bearer token")
+		}
+		// Compare with every token, so that the time taken does not reveal
+		// which one matched.
+		var name string
+		for t, n := range cfg.Tokens {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				name = n
+			}
+		}
+		if name == "" {
+			return nil, errors.New("unknown token")
+		}
+		return &AdminUser{Name: name, Method: AdminAuthToken}, nil
+	}
+	if a := r.Header.Get(auth.IAPAssertionHeader); a != "" && cfg.VerifyIAP != nil {
+		email, err := cfg.VerifyIAP(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		return &AdminUser{Name: email, Method: AdminAuthIAP}, nil
+	}
+	if cfg.Insecure {
+		return &AdminUser{Name: "local", Method: AdminAuthInsecure}, nil
+	}
+	return nil, errors.New("no credentials")
+}
+
+const (
+	// CSRFTokenField is the form field in which forms send CSRF tokens.
+	CSRFTokenField = "csrf_token"
+	// CSRFTokenHeader is the header in which scripts can send CSRF tokens
+	// instead.
+	CSRFTokenHeader = "X-CSRF-Token"
+
+	// csrfTokenLifetime is how long a CSRF token is valid: how long a page
+	// with a form can be left open before the form stops working.
+	csrfTokenLifetime = 12 * time.Hour
+)
+
+// CSRF returns a Middleware that protects handlers that change state from
+// cross-site request forgery. It must come after AdminAuth.
+//
+// Requests from browser sessions, which are those not authenticated by a
+// bearer token, must carry a CSRF token for their user, from CSRFToken(key,
+// r), in the CSRFTokenField form field or the CSRFTokenHeader header.
+// Requests without a valid token are served 403 (Forbidden). Requests
+// authenticated by bearer tokens are exempt, because browsers never add those
+// to requests on their own.
+//
+// CSRF only protects POST requests; use AcceptMethods to reject others.
+func CSRF(key []byte) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u := AdminUserFromContext(r.Context())
+			if u == nil || u.Method != AdminAuthToken {
+				token := r.Header.Get(CSRFTokenHeader)
+				if token == "" {
+					token = r.PostFormValue(CSRFTokenField)
+				}
+				if u == nil || !validCSRFToken(key, u.Name, token, time.Now()) {
+					log.Infof(r.Context(), "CSRF: rejecting %s %s: missing or invalid token", r.Method, r.URL.Path)
+					http.Error(w, "missing or invalid CSRF token; reload the page and try again", http.StatusForbidden)
+					return
+				}
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRFToken returns a CSRF token for the user of r, for the forms on a page
+// served to them. It returns the empty string if r has no user.
+func CSRFToken(key []byte, r *http.Request) string {
+	u := AdminUserFromContext(r.Context())
+	if u == nil {
+		return ""
+	}
+	return signCSRFToken(key, u.Name, time.Now().Add(csrfTokenLifetime))
+}
+
+// signCSRFToken returns a token for user that expires at expiry. The token is
+// the expiry, in Unix seconds, and the hex-encoded HMAC-SHA256 of the user and
+// the expiry, separated by a dot.
+func signCSRFToken(key []byte, user string, expiry time.Time) string {
+	exp := strconv.FormatInt(expiry.Unix(), 10)
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s", user, exp)
+	return exp + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validCSRFToken reports whether token is an unexpired token for user.
+func validCSRFToken(key []byte, user, token string, now time.Time) bool {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return false
+	}
+	exp, err := strconv.ParseInt(token[:i], 10, 64)
+	if err != nil || now.After(time.Unix(exp, 0)) {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(signCSRFToken(key, user, time.Unix(exp, 0))))
+}
+
+// An AuditEntry describes a request served by a handler wrapped by Audit.
+type AuditEntry struct {
+	User       string
+	AuthMethod string
+	Method     string
+	Path       string
+	// Params are the query and form parameters of the request, except for
+	// the CSRF token.
+	Params map[string][]string
+	Status int
+}
+
+// maxAuditFormMemory is the number of bytes of a multipart form that Audit
+// holds in memory.
+const maxAuditFormMemory = 32 << 20
+
+// Audit returns a Middleware that calls record with an entry for each request,
+// after it has been served, so that changes made through the handler can be
+// traced to the users who made them. It must come after AdminAuth. Errors
+// from record are logged; they cannot change the response.
+func Audit(record func(context.Context, *AuditEntry) error) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Parse the form now, so that its parameters can be recorded
+			// whether or not the handler reads them.
+			if err := r.ParseMultipartForm(maxAuditFormMemory); err != nil && err != http.ErrNotMultipart {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rw := &responseWriter{ResponseWriter: w}
+			h.ServeHTTP(rw, r)
+
+			ctx := r.Context()
+			e := &AuditEntry{
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Params: map[string][]string{},
+				Status: translateStatus(rw.status),
+			}
+			if u := AdminUserFromContext(ctx); u != nil {
+				e.User = u.Name
+				e.AuthMethod = u.Method
+			}
+			for k, v := range r.Form {
+				if k != CSRFTokenField {
+					e.Params[k] = v
+				}
+			}
+			if err := record(ctx, e); err != nil {
+				log.Errorf(ctx, "Audit: recording %s %s by %q: %v", e.Method, e.Path, e.User, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/auth"
+)
+
+// adminUserHandler writes the name and authentication method of the admin
+// user.
+var adminUserHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	u := AdminUserFromContext(r.Context())
+	w.Write([]byte(u.Name + " " + u.Method))
+})
+
+func TestAdminAuth(t *testing.T) {
+	verifyIAP := func(_ context.Context, assertion string) (string, error) {
+		if assertion != "good" {
+			return "", errors.New("bad assertion")
+		}
+		return "user@example.com", nil
+	}
+	cfg := AdminAuthConfig{
+		VerifyIAP: verifyIAP,
+		Tokens:    map[string]string{"secret": "script"},
+	}
+	for _, test := range []struct {
+		name       string
+		cfg        AdminAuthConfig
+		header     http.Header
+		wantStatus int
+		wantBody   string
+	}{
+		{"token", cfg, http.Header{"Authorization": {"Bearer secret"}}, http.StatusOK, "script token"},
+		{"bad token", cfg, http.Header{"Authorization": {"Bearer wrong"}}, http.StatusUnauthorized, ""},
+		{"not bearer", cfg, http.Header{"Authorization": {"Basic secret"}}, http.StatusUnauthorized, ""},
+		{"iap", cfg, http.Header{auth.IAPAssertionHeader: {"good"}}, http.StatusOK, "user@example.com iap"},
+		{"bad iap", cfg, http.Header{auth.IAPAssertionHeader: {"bad"}}, http.StatusUnauthorized, ""},
+		{"no credentials", cfg, nil, http.StatusUnauthorized, ""},
+		{"iap disabled", AdminAuthConfig{}, http.Header{auth.IAPAssertionHeader: {"good"}}, http.StatusUnauthorized, ""},
+		{"insecure", AdminAuthConfig{Insecure: true}, nil, http.StatusOK, "local insecure"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for k, v := range test.header {
+				r.Header.Set(k, v[0])
+			}
+			w := httptest.NewRecorder()
+			AdminAuth(test.cfg)(adminUserHandler).ServeHTTP(w, r)
+			if w.Code != test.wantStatus {
+				t.Fatalf("got status %d, want %d", w.Code, test.wantStatus)
+			}
+			if test.wantStatus == http.StatusOK && w.Body.String() != test.wantBody {
+				t.Errorf("got body %q, want %q", w.Body.String(), test.wantBody)
+			}
+		})
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	key := []byte("key")
+	now := time.Now()
+	userToken := signCSRFToken(key, "user@example.com", now.Add(time.Hour))
+	for _, test := range []struct {
+		name       string
+		header     http.Header
+		form       url.Values
+		wantStatus int
+	}{
+		{"form token", http.Header{auth.IAPAssertionHeader: {"user"}}, url.Values{CSRFTokenField: {userToken}}, http.StatusOK},
+		{"header token", http.Header{auth.IAPAssertionHeader: {"user"}, CSRFTokenHeader: {userToken}}, nil, http.StatusOK},
+		{"no token", http.Header{auth.IAPAssertionHeader: {"user"}}, nil, http.StatusForbidden},
+		{"other user", http.Header{auth.IAPAssertionHeader: {"other"}}, url.Values{CSRFTokenField: {userToken}}, http.StatusForbidden},
+		{"expired", http.Header{auth.IAPAssertionHeader: {"user"}},
+			url.Values{CSRFTokenField: {signCSRFToken(key, "user@example.com", now.Add(-time.Minute))}}, http.StatusForbidden},
+		{"other key", http.Header{auth.IAPAssertionHeader: {"user"}},
+			url.Values{CSRFTokenField: {signCSRFToken([]byte("other"), "user@example.com", now.Add(time.Hour))}}, http.StatusForbidden},
+		{"bearer token", http.Header{"Authorization": {"Bearer secret"}}, nil, http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(test.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			for k, v := range test.header {
+				r.Header.Set(k, v[0])
+			}
+			w := httptest.NewRecorder()
+			Chain(
+				AdminAuth(AdminAuthConfig{
+					VerifyIAP: func(_ context.Context, a string) (string, error) { return a + "@example.com", nil },
+					Tokens:    map[string]string{"secret": "script"},
+				}),
+				CSRF(key),
+			)(adminUserHandler).ServeHTTP(w, r)
+			if w.Code != test.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	key := []byte("key")
+	r := httptest.NewRequest("GET", "/", nil)
+	if got := CSRFToken(key, r); got != "" {
+		t.Errorf("CSRFToken without user = %q, want empty", got)
+	}
+	r = r.WithContext(context.WithValue(r.Context(), adminUserKey{}, &AdminUser{Name: "u", Method: AdminAuthIAP}))
+	token := CSRFToken(key, r)
+	if !validCSRFToken(key, "u", token, time.Now()) {
+		t.Errorf("token %q is not valid", token)
+	}
+	if validCSRFToken(key, "u", token, time.Now().Add(csrfTokenLifetime+time.Minute)) {
+		t.Errorf("token %q is valid after its lifetime", token)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	var got []*AuditEntry
+	record := func(_ context.Context, e *AuditEntry) error {
+		got = append(got, e)
+		return nil
+	}
+	h := Chain(AdminAuth(AdminAuthConfig{Insecure: true}), Audit(record))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", http.StatusBadRequest)
+		}))
+	form := url.Values{"name": {"exp"}, CSRFTokenField: {"token"}}
+	r := httptest.NewRequest("POST", "/experiments/set?rollout=10", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	want := []*AuditEntry{{
+		User:       "local",
+		AuthMethod: AdminAuthInsecure,
+		Method:     "POST",
+		Path:       "/experiments/set",
+		Params:     map[string][]string{"name": {"exp"}, "rollout": {"10"}},
+		Status:     http.StatusBadRequest,
+	}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
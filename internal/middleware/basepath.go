@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BasePath returns a Middleware that serves the site under basePath, a path
+// like /godoc, for when a reverse proxy forwards requests for that path
+// unchanged. It strips basePath from the paths of requests, so that handlers
+// see the paths they would see at the root of a host, and adds it back to the
+// Location of redirects to paths of the site. Paths outside of basePath are
+// not found, and basePath itself redirects to basePath + "/".
+//
+// BasePath must come first, so that every other middleware sees the paths of
+// the site.
+func BasePath(basePath string) Middleware {
+	basePath = strings.TrimSuffix(basePath, "/")
+	return func(h http.Handler) http.Handler {
+		if basePath == "" {
+			return h
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == basePath {
+				u := *r.URL
+				u.Path = basePath + "/"
+				u.RawPath = ""
+				http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+				return
+			}
+			p := strings.TrimPrefix(r.URL.Path, basePath)
+			rp := strings.TrimPrefix(r.URL.RawPath, basePath)
+			if len(p) == len(r.URL.Path) || !strings.HasPrefix(p, "/") ||
+				(r.URL.RawPath != "" && len(rp) == len(r.URL.RawPath)) {
+				http.NotFound(w, r)
+				return
+			}
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = p
+			r2.URL.RawPath = rp
+			h.ServeHTTP(&basePathResponseWriter{ResponseWriter: w, basePath: basePath}, r2)
+		})
+	}
+}
+
+// basePathResponseWriter moves the Location of redirects to paths of the site
+// under basePath.
+type basePathResponseWriter struct {
+	http.ResponseWriter
+	basePath string
+}
+
+func (b *basePathResponseWriter) WriteHeader(statusCode int) {
+	h := b.Header()
+	if loc := h.Get("Location"); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		h.Set("Location", b.basePath+loc)
+	}
+	b.ResponseWriter.WriteHeader(statusCode)
+}
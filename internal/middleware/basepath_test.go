@@ -0,0 +1,55 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.Path)
+	})
+	mux.Handle("/old", http.RedirectHandler("/new?a=b", http.StatusFound))
+	mux.Handle("/relative/old", http.RedirectHandler("new", http.StatusFound))
+	mux.Handle("/external", http.RedirectHandler("https://example.com/x", http.StatusFound))
+
+	for _, test := range []struct {
+		basePath, path string
+		wantStatus     int
+		wantBody       string
+		wantLocation   string
+	}{
+		{"", "/pkg", http.StatusOK, "/pkg", ""},
+		{"/godoc/", "/godoc/pkg", http.StatusOK, "/pkg", ""},
+		{"/godoc", "/godoc/", http.StatusOK, "/", ""},
+		{"/godoc", "/godoc/a%2Fb", http.StatusOK, "/a/b", ""},
+		{"/godoc", "/godoc", http.StatusMovedPermanently, "", "/godoc/"},
+		{"/godoc", "/godoc?q=x", http.StatusMovedPermanently, "", "/godoc/?q=x"},
+		{"/godoc", "/pkg", http.StatusNotFound, "", ""},
+		{"/godoc", "/godocs/pkg", http.StatusNotFound, "", ""},
+		{"/godoc", "/godoc/old", http.StatusFound, "", "/godoc/new?a=b"},
+		{"/godoc", "/godoc/relative/old", http.StatusFound, "", "/godoc/relative/new"},
+		{"/godoc", "/godoc/external", http.StatusFound, "", "https://example.com/x"},
+	} {
+		t.Run(test.basePath+test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			BasePath(test.basePath)(mux).ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+			if w.Code != test.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, test.wantStatus)
+			}
+			if test.wantBody != "" && w.Body.String() != test.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), test.wantBody)
+			}
+			if got := w.Header().Get("Location"); got != test.wantLocation {
+				t.Errorf("Location = %q, want %q", got, test.wantLocation)
+			}
+		})
+	}
+}
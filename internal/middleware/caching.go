@@ -8,22 +8,27 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
-	"github.com/go-redis/redis/v7"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/cache"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/i18n"
 	"golang.org/x/pkgsite/internal/log"
 )
 
 var (
 	keyCacheHit       = tag.MustNewKey("cache.hit")
 	keyCacheName      = tag.MustNewKey("cache.name")
+	keyCachePageType  = tag.MustNewKey("cache.page_type")
 	keyCacheOperation = tag.MustNewKey("cache.operation")
 	cacheResults      = stats.Int64(
 		"go-discovery/cache/result_count",
@@ -36,13 +41,14 @@ var (
 		stats.UnitDimensionless,
 	)
 
-	// CacheResultCount is a counter of cache results, by cache name and hit success.
+	// CacheResultCount is a counter of cache results, by cache name, page
+	// type and hit success.
 	CacheResultCount = &view.View{
 		Name:        "go-discovery/cache/result_count",
 		Measure:     cacheResults,
 		Aggregation: view.Count(),
-		Description: "cache results, by cache name and whether it was a hit",
-		TagKeys:     []tag.Key{keyCacheName, keyCacheHit},
+		Description: "cache results, by cache name, page type and whether it was a hit",
+		TagKeys:     []tag.Key{keyCacheName, keyCachePageType, keyCacheHit},
 	}
 	// CacheErrorCount is a counter of cache errors, by cache name.
 	CacheErrorCount = &view.View{
@@ -58,11 +64,17 @@ var (
 	testMode = false
 )
 
-func recordCacheResult(ctx context.Context, name string, hit bool) {
+func recordCacheResult(ctx context.Context, name, pageType string, hit bool) {
 	stats.RecordWithTags(ctx, []tag.Mutator{
 		tag.Upsert(keyCacheName, name),
+		tag.Upsert(keyCachePageType, pageType),
 		tag.Upsert(keyCacheHit, strconv.FormatBool(hit)),
 	}, cacheResults.M(1))
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	log.SetRequestLabel(ctx, "cache", result)
 }
 
 func recordCacheError(ctx context.Context, name, operation string) {
@@ -72,11 +84,15 @@ func recordCacheError(ctx context.Context, name, operation string) {
 	}, cacheErrors.M(1))
 }
 
-type cache struct {
+type cacheHandler struct {
 	name     string
-	client   *redis.Client
+	cache    *cache.Cache
 	delegate http.Handler
 	expirer  Expirer
+	stale    time.Duration
+	pageType PageTyper
+
+	revalidating sync.Map // keys of pages being revalidated
 }
 
 // An Expirer computes the TTL that should be used when caching a page.
@@ -89,60 +105,104 @@ func TTL(ttl time.Duration) Expirer {
 	}
 }
 
-// Cache returns a new Middleware that caches every request.
-// The name of the cache is used only for metrics.
+// A PageTyper returns the type of the page for a request, such as "unit"
+// or "module", for metrics. It must return one of a small set of values.
+type PageTyper func(r *http.Request) string
+
+// Cache returns a new Middleware that caches every request in c.
+// The name of the cache is part of the cache keys, and is used for metrics.
 // The expirer is a func that is used to map a new request to its TTL.
-func Cache(name string, client *redis.Client, expirer Expirer) Middleware {
+// The pageType func classifies requests for metrics; if it is nil, all pages
+// have the name of the cache as their type.
+//
+// A page is kept for stale longer than its TTL. During that time it is still
+// served from the cache, but each request for it also starts rendering the
+// page again in the background, to replace the cached copy. That way, popular
+// pages are always served from the cache.
+//
+// A page is cached with the tags that the handler sets on its request context
+// with cache.Tag, so that cache.Invalidate can remove it.
+func Cache(name string, c *cache.Cache, expirer Expirer, stale time.Duration, pageType PageTyper) Middleware {
+	if pageType == nil {
+		pageType = func(*http.Request) string { return name }
+	}
 	return func(h http.Handler) http.Handler {
-		return &cache{
+		return &cacheHandler{
 			name:     name,
-			client:   client,
+			cache:    c,
 			delegate: h,
 			expirer:  expirer,
+			stale:    stale,
+			pageType: pageType,
 		}
 	}
 }
 
 const cacheBypassHeader = "x-go-discovery-bypass-cache"
 
-func (c *cache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+func (c *cacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// To facilitate load testing and debugging, we check for a magic header that
 	// bypasses the cache. This completely avoids the cached serving path, and
 	// does not write to the cache after success.
 	if bypass := r.Header.Get(cacheBypassHeader); bypass != "" {
+		log.SetRequestLabel(r.Context(), "cache", "bypass")
 		c.delegate.ServeHTTP(w, r)
 		return
 	}
 	ctx := r.Context()
-	key := r.URL.String()
-	if reader, ok := c.get(ctx, key); ok {
-		recordCacheResult(ctx, c.name, true)
+	key := cacheKey(r)
+	pageType := c.pageType(r)
+	start := time.Now()
+	reader, stale, ok := c.get(ctx, key)
+	RecordPhase(ctx, PhaseCache, time.Since(start))
+	if ok {
+		recordCacheResult(ctx, c.name, pageType, true)
+		if stale {
+			if testMode {
+				c.revalidate(r, key)
+			} else {
+				go c.revalidate(r, key)
+			}
+		}
 		if _, err := io.Copy(w, reader); err != nil {
 			log.Errorf(ctx, "error copying zip bytes: %v", err)
 		}
 		return
 	}
-	recordCacheResult(ctx, c.name, false)
+	recordCacheResult(ctx, c.name, pageType, false)
 	rec := newRecorder(w)
+	r = r.WithContext(cache.NewContextWithTags(ctx))
 	c.delegate.ServeHTTP(rec, r)
 	if rec.bufErr == nil && (rec.statusCode == 0 || rec.statusCode == http.StatusOK) {
-		ttl := c.expirer(r)
+		ttl := c.expirer(r) + c.stale
+		tags := cache.Tags(r.Context())
 		if testMode {
-			c.put(ctx, key, rec, ttl)
+			c.put(ctx, key, rec, ttl, tags)
 		} else {
-			go c.put(ctx, key, rec, ttl)
+			go c.put(ctx, key, rec, ttl, tags)
 		}
 	}
 }
 
-func (c *cache) get(ctx context.Context, key string) (io.Reader, bool) {
+// cacheKey returns the key of the cached page for r. Pages in a language
+// other than the default are cached separately.
+func cacheKey(r *http.Request) string {
+	key := r.URL.String()
+	if lang := i18n.FromContext(r.Context()); lang != i18n.Default {
+		key += "#lang=" + lang.String()
+	}
+	return key
+}
+
+// get returns the cached page for key, and whether it is stale.
+func (c *cacheHandler) get(ctx context.Context, key string) (_ io.Reader, stale, ok bool) {
 	// Set a short timeout for redis requests, so that we can quickly
 	// fall back to un-cached serving if redis is unavailable.
 	getCtx, cancelGet := context.WithTimeout(ctx, 50*time.Millisecond)
 	defer cancelGet()
-	val, err := c.client.WithContext(getCtx).Get(key).Bytes()
-	if err == redis.Nil {
-		return nil, false
+	val, remaining, err := c.cache.Get(getCtx, c.name, key)
+	if errors.Is(err, derrors.NotFound) {
+		return nil, false, false
 	}
 	if err != nil {
 		select {
@@ -152,18 +212,64 @@ func (c *cache) get(ctx context.Context, key string) (io.Reader, bool) {
 			log.Errorf(ctx, "cache get: %v", err)
 		}
 		recordCacheError(ctx, c.name, "GET")
-		return nil, false
+		return nil, false, false
 	}
 	zr, err := gzip.NewReader(bytes.NewReader(val))
 	if err != nil {
 		log.Errorf(ctx, "cache: gzip.NewReader: %v", err)
 		recordCacheError(ctx, c.name, "UNZIP")
-		return nil, false
+		return nil, false, false
+	}
+	// The page is stale if it has outlived its TTL, and is only being kept
+	// for the stale period. A negative TTL means the page never expires.
+	stale = c.stale > 0 && remaining >= 0 && remaining < c.stale
+	return zr, stale, true
+}
+
+// revalidateTimeout bounds the time to render a page again in the background.
+const revalidateTimeout = time.Minute
+
+// revalidate renders the page for r again, and replaces the cached copy at
+// key with it. Only one revalidation per key runs at a time.
+func (c *cacheHandler) revalidate(r *http.Request, key string) {
+	if _, loaded := c.revalidating.LoadOrStore(key, true); loaded {
+		return
+	}
+	defer c.revalidating.Delete(key)
+
+	// The request that found the page stale may finish before the page is
+	// rendered again, so it must not cancel the rendering.
+	ctx, cancel := context.WithTimeout(detachedContext{r.Context()}, revalidateTimeout)
+	defer cancel()
+	ctx = cache.NewContextWithTags(ctx)
+	rec := newRecorder(&discardResponseWriter{header: http.Header{}})
+	c.delegate.ServeHTTP(rec, r.WithContext(ctx))
+	if rec.bufErr == nil && (rec.statusCode == 0 || rec.statusCode == http.StatusOK) {
+		c.put(ctx, key, rec, c.expirer(r)+c.stale, cache.Tags(ctx))
 	}
-	return zr, true
 }
 
-func (c *cache) put(ctx context.Context, key string, rec *cacheRecorder, ttl time.Duration) {
+// detachedContext is a context.Context that has the values of its parent, but
+// not its deadline or cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// discardResponseWriter is an http.ResponseWriter that discards the response.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header         { return d.header }
+func (d *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+
+func (c *cacheHandler) put(ctx context.Context, key string, rec *cacheRecorder, ttl time.Duration, tags []string) {
 	if err := rec.zipWriter.Close(); err != nil {
 		log.Errorf(ctx, "cache: error closing zip for %q: %v", key, err)
 		return
@@ -171,8 +277,7 @@ func (c *cache) put(ctx context.Context, key string, rec *cacheRecorder, ttl tim
 	log.Infof(ctx, "caching response of length %d for %s", rec.buf.Len(), key)
 	setCtx, cancelSet := context.WithTimeout(context.Background(), 1*time.Second)
 	defer cancelSet()
-	_, err := c.client.WithContext(setCtx).Set(key, rec.buf.Bytes(), ttl).Result()
-	if err != nil {
+	if err := c.cache.Put(setCtx, c.name, key, rec.buf.Bytes(), ttl, tags); err != nil {
 		recordCacheError(ctx, c.name, "SET")
 		log.Errorf(ctx, "cache set %q: %v", key, err)
 	}
@@ -17,6 +17,7 @@ import (
 	"github.com/go-redis/redis/v7"
 	"github.com/google/go-cmp/cmp"
 	"go.opencensus.io/stats/view"
+	"golang.org/x/pkgsite/internal/cache"
 )
 
 func TestCache(t *testing.T) {
@@ -43,7 +44,7 @@ func TestCache(t *testing.T) {
 
 	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
 	mux := http.NewServeMux()
-	mux.Handle("/A", Cache("A", c, TTL(1*time.Minute))(handler))
+	mux.Handle("/A", Cache("A", cache.New(c), TTL(1*time.Minute), 0, nil)(handler))
 	mux.Handle("/B", handler)
 	ts := httptest.NewServer(mux)
 	view.Register(CacheResultCount)
@@ -177,3 +178,50 @@ func TestCache(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheStale(t *testing.T) {
+	testMode = true
+	var (
+		body    string
+		renders int
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		fmt.Fprint(w, body)
+	})
+
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	c := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	h := Cache("stale", cache.New(c), TTL(time.Minute), time.Minute, nil)(handler)
+	for _, test := range []struct {
+		label       string
+		advanceTime time.Duration
+		body        string
+		wantBody    string
+		wantRenders int
+	}{
+		{"miss", 0, "1", "1", 1},
+		{"fresh", 30 * time.Second, "2", "1", 1},
+		// The page is served stale, and rendered again.
+		{"stale", 45 * time.Second, "3", "1", 2},
+		{"revalidated", 0, "4", "3", 2},
+		// The revalidated page is kept for the TTL plus the stale period.
+		{"expired", 2*time.Minute + time.Second, "5", "5", 3},
+	} {
+		s.FastForward(test.advanceTime)
+		body = test.body
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/A", nil))
+		if got := w.Body.String(); got != test.wantBody {
+			t.Errorf("[%s] got body %q, want %q", test.label, got, test.wantBody)
+		}
+		if renders != test.wantRenders {
+			t.Errorf("[%s] got %d renders, want %d", test.label, renders, test.wantRenders)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/i18n"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	keyCoalesceShared = tag.MustNewKey("coalesce.shared")
+	coalesceResults   = stats.Int64(
+		"go-discovery/coalesce/result_count",
+		"The result of coalescing a request.",
+		stats.UnitDimensionless,
+	)
+	// CoalesceResultCount is a counter of coalesced requests, by whether the
+	// response was shared with another request.
+	CoalesceResultCount = &view.View{
+		Name:        "go-discovery/coalesce/result_count",
+		Measure:     coalesceResults,
+		Aggregation: view.Count(),
+		Description: "coalesce results, by whether the response was shared",
+		TagKeys:     []tag.Key{keyCoalesceShared},
+	}
+)
+
+// coalesceTimeout bounds the time of a call to the delegate handler that
+// serves several requests. It is a variable for testing.
+var coalesceTimeout = time.Minute
+
+// Coalesce returns a Middleware that serves identical concurrent GET
+// requests with a single call to the delegate handler: while a request is
+// being served, requests for the same page wait for it and get a copy of its
+// response. That keeps a popular page whose cache entry has just expired from
+// being rendered many times at once.
+//
+// Requests are identical if they have the same path, query parameters in any
+// order, set of experiments and language.
+//
+// The shared call has the values of the context of the request that started
+// it, such as its experiments and language, but not its cancellation: it runs
+// with a deadline of its own, so that a client that goes away does not fail
+// the requests waiting for it. An unsuccessful response of a call that ran out
+// of time is not shared; the requests that waited for it call the delegate
+// handler themselves.
+func Coalesce() Middleware {
+	var g singleflight.Group
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.Header.Get(cacheBypassHeader) != "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			leader := false
+			ch := g.DoChan(coalesceKey(r), func() (interface{}, error) {
+				leader = true
+				ctx, cancel := context.WithTimeout(detachedContext{r.Context()}, coalesceTimeout)
+				defer cancel()
+				rec := &responseRecorder{header: http.Header{}}
+				h.ServeHTTP(rec, r.WithContext(ctx))
+				if rec.statusCode != 0 && rec.statusCode/100 != 2 && ctx.Err() != nil {
+					return rec, ctx.Err()
+				}
+				return rec, nil
+			})
+			var res singleflight.Result
+			select {
+			case res = <-ch:
+			case <-r.Context().Done():
+				// The call goes on for the requests that wait for it.
+				return
+			}
+			stats.RecordWithTags(r.Context(), []tag.Mutator{
+				tag.Upsert(keyCoalesceShared, strconv.FormatBool(res.Shared)),
+			}, coalesceResults.M(1))
+			if res.Err != nil && !leader {
+				h.ServeHTTP(w, r)
+				return
+			}
+			rec := res.Val.(*responseRecorder)
+			for k, vs := range rec.header {
+				w.Header()[k] = append([]string(nil), vs...)
+			}
+			if rec.statusCode != 0 {
+				w.WriteHeader(rec.statusCode)
+			}
+			if _, err := w.Write(rec.buf.Bytes()); err != nil {
+				log.Errorf(r.Context(), "Coalesce, writing: %v", err)
+			}
+		})
+	}
+}
+
+// coalesceKey returns the key that identifies the page requested by r.
+func coalesceKey(r *http.Request) string {
+	exps := experiment.FromContext(r.Context()).Active()
+	sort.Strings(exps)
+	// Encode sorts the query parameters by key.
+	return r.URL.Path + "?" + r.URL.Query().Encode() + "#" + strings.Join(exps, ",") + "#" + i18n.FromContext(r.Context()).String()
+}
+
+// responseRecorder is an http.ResponseWriter that records a response, so that
+// it can be written to several clients.
+type responseRecorder struct {
+	header     http.Header
+	statusCode int
+	buf        bytes.Buffer
+}
+
+func (r *responseRecorder) Header() http.Header { return r.header }
+
+func (r *responseRecorder) Write(b []byte) (int, error) { return r.buf.Write(b) }
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if r.statusCode == 0 {
+		r.statusCode = statusCode
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/experiment"
+)
+
+func TestCoalesce(t *testing.T) {
+	var (
+		calls   int32
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprint(w, "body")
+	})
+	h := Coalesce()(handler)
+
+	const n = 10
+	var wg sync.WaitGroup
+	recs := make([]*httptest.ResponseRecorder, n)
+	serve := func(i int, target string) {
+		defer wg.Done()
+		recs[i] = httptest.NewRecorder()
+		h.ServeHTTP(recs[i], httptest.NewRequest("GET", target, nil))
+	}
+	wg.Add(1)
+	go serve(0, "/p?a=1&b=2")
+	<-started
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go serve(i, "/p?b=2&a=1")
+	}
+	// Give the other requests time to wait for the first one.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+	for i, rec := range recs {
+		if rec.Code != http.StatusTeapot || rec.Body.String() != "body" || rec.Header().Get("X-Test") != "yes" {
+			t.Errorf("response %d: got %d %q %v, want the handler's response", i, rec.Code, rec.Body.String(), rec.Header())
+		}
+	}
+}
+
+func TestCoalesceLeaderCanceled(t *testing.T) {
+	var (
+		once    sync.Once
+		started = make(chan struct{})
+		release = make(chan struct{})
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { close(started) })
+		<-release
+		if r.Context().Err() != nil {
+			http.Error(w, "canceled", http.StatusServiceUnavailable)
+			return
+		}
+		// The shared call keeps the values of the leader's context.
+		fmt.Fprint(w, experiment.IsActive(r.Context(), "e"))
+	})
+	h := Coalesce()(handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{"e": true}))
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/p", nil).WithContext(ctx))
+	}()
+	<-started
+	followerCtx := experiment.NewContext(context.Background(), experiment.NewSet(map[string]bool{"e": true}))
+	follower := httptest.NewRecorder()
+	followerDone := make(chan struct{})
+	go func() {
+		defer close(followerDone)
+		h.ServeHTTP(follower, httptest.NewRequest("GET", "/p", nil).WithContext(followerCtx))
+	}()
+	// Give the follower time to wait for the leader, which then goes away;
+	// its request returns without waiting for the call.
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-leaderDone
+	close(release)
+	<-followerDone
+	if follower.Code != http.StatusOK || follower.Body.String() != "true" {
+		t.Errorf("follower: got %d %q, want 200 %q", follower.Code, follower.Body.String(), "true")
+	}
+}
+
+func TestCoalesceTimeoutNotShared(t *testing.T) {
+	defer func(d time.Duration) { coalesceTimeout = d }(coalesceTimeout)
+	coalesceTimeout = 10 * time.Millisecond
+
+	var (
+		calls   int32
+		started = make(chan struct{})
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The first call runs out of time.
+			close(started)
+			<-r.Context().Done()
+			http.Error(w, "timed out", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "body")
+	})
+	h := Coalesce()(handler)
+
+	var wg sync.WaitGroup
+	leader, follower := httptest.NewRecorder(), httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(leader, httptest.NewRequest("GET", "/p", nil))
+	}()
+	<-started
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(follower, httptest.NewRequest("GET", "/p", nil))
+	}()
+	wg.Wait()
+	if leader.Code != http.StatusServiceUnavailable {
+		t.Errorf("leader: got %d, want 503", leader.Code)
+	}
+	// The follower may have joined the call that timed out, or started a new
+	// one; either way, it gets a response of its own.
+	if follower.Code != http.StatusOK || follower.Body.String() != "body" {
+		t.Errorf("follower: got %d %q, want 200 %q", follower.Code, follower.Body.String(), "body")
+	}
+}
+
+func TestCoalesceKey(t *testing.T) {
+	req := func(target string, exps ...string) *http.Request {
+		r := httptest.NewRequest("GET", target, nil)
+		set := map[string]bool{}
+		for _, e := range exps {
+			set[e] = true
+		}
+		return r.WithContext(experiment.NewContext(r.Context(), experiment.NewSet(set)))
+	}
+	for _, test := range []struct {
+		a, b *http.Request
+		same bool
+	}{
+		{req("/p?tab=doc&x=1"), req("/p?x=1&tab=doc"), true},
+		{req("/p", "e1", "e2"), req("/p", "e2", "e1"), true},
+		{req("/p?tab=doc"), req("/p?tab=versions"), false},
+		{req("/p", "e1"), req("/p"), false},
+		{req("/p"), req("/q"), false},
+	} {
+		a, b := coalesceKey(test.a), coalesceKey(test.b)
+		if (a == b) != test.same {
+			t.Errorf("keys %q and %q: same = %t, want %t", a, b, a == b, test.same)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// ETag returns a Middleware that sets a strong ETag on successful HTML
+// responses, and replies with 304 Not Modified to requests whose
+// If-None-Match header has the ETag of the page they would get.
+//
+// The ETag is a hash of appVersion and the page as served, so it changes
+// whenever anything the page shows does, such as its licenses, its
+// vulnerabilities or its imported-by count, whether or not the version of
+// its module changed. The page is still produced for a 304 response, but a
+// page that is in the cache is only read from it, not rendered.
+//
+// ETag must come after SecureHeaders in the chain, so that the nonce, which
+// differs for every response, is not part of the hash. A 304 response has no
+// Content-Security-Policy header, so that clients keep using the one that
+// matches the nonce in their copy of the page. ETag must come before
+// LatestVersion, so that the hash covers the latest-version badge.
+func ETag(appVersion string) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				h.ServeHTTP(w, r)
+				return
+			}
+			erw := &etagResponseWriter{ResponseWriter: w}
+			h.ServeHTTP(erw, r)
+			body := erw.buf.Bytes()
+			if erw.statusCode == 0 || erw.statusCode == http.StatusOK {
+				ct := w.Header().Get("Content-Type")
+				if ct == "" {
+					ct = http.DetectContentType(body)
+				}
+				if strings.HasPrefix(ct, "text/html") {
+					etag := computeETag(appVersion, body)
+					w.Header().Set("ETag", etag)
+					if etagMatches(r.Header.Get("If-None-Match"), etag) {
+						w.Header().Del("Content-Security-Policy")
+						w.WriteHeader(http.StatusNotModified)
+						return
+					}
+				}
+			}
+			if erw.statusCode != 0 {
+				w.WriteHeader(erw.statusCode)
+			}
+			if _, err := w.Write(body); err != nil {
+				log.Errorf(r.Context(), "ETag, writing: %v", err)
+			}
+		})
+	}
+}
+
+// computeETag returns a strong ETag for body, as served by appVersion.
+func computeETag(appVersion string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(appVersion))
+	h.Write([]byte{0})
+	h.Write(body)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether the value of an If-None-Match header matches
+// etag. As RFC 7232 requires for If-None-Match, it uses the weak comparison,
+// which ignores a "W/" prefix.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// etagResponseWriter is an http.ResponseWriter that captures the status code
+// and body of a response, so that the ETag can be set only on successful HTML
+// responses.
+type etagResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	buf        bytes.Buffer
+}
+
+func (e *etagResponseWriter) WriteHeader(statusCode int) {
+	if e.statusCode == 0 {
+		e.statusCode = statusCode
+	}
+}
+
+func (e *etagResponseWriter) Write(b []byte) (int, error) {
+	return e.buf.Write(b)
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	var (
+		body        = "<!DOCTYPE html><p>v1.0.0</p>"
+		contentType string
+		status      int
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if status != 0 {
+			w.WriteHeader(status)
+		}
+		fmt.Fprint(w, body)
+	})
+	// Stand in for SecureHeaders, which sets its headers before calling the
+	// next handler.
+	csp := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Security-Policy", "script-src 'nonce-x'")
+			h.ServeHTTP(w, r)
+		})
+	}
+	mw := csp(ETag("app1")(handler))
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("GET", "/p", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		mw.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != body || etag == "" {
+		t.Fatalf("first request: got status %d, body %q, ETag %q", w.Code, w.Body.String(), etag)
+	}
+
+	w = get(`"other", ` + etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("matching request: got status %d, body %q; want 304 and no body", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("matching request: got Content-Security-Policy %q, want none", got)
+	}
+
+	w = get("W/" + etag)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("weak match: got status %d, want 304", w.Code)
+	}
+
+	// A different app version or page has a different ETag, even if the
+	// version of the module shown did not change.
+	if got := computeETag("app2", []byte(body)); got == etag {
+		t.Errorf("ETag does not depend on app version")
+	}
+	body = "<!DOCTYPE html><p>v1.0.0</p><p>Imported by 2</p>"
+	w = get(etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("changed page: got status %d, ETag %q; want 200 and a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+
+	// Errors and non-HTML responses have no ETag.
+	status = http.StatusNotFound
+	w = get("")
+	if w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+		t.Errorf("error: got status %d, ETag %q; want 404 and none", w.Code, w.Header().Get("ETag"))
+	}
+	status = 0
+	contentType = "text/plain; charset=utf-8"
+	if w = get(""); w.Header().Get("ETag") != "" {
+		t.Errorf("plain text: got ETag %q, want none", w.Header().Get("ETag"))
+	}
+}
@@ -6,12 +6,19 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"hash/fnv"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
@@ -20,6 +27,31 @@ import (
 
 const experimentQueryParamKey = "experiment"
 
+// experimentCookieName is the name of the cookie that holds the bucket of a
+// client, so that it stays in the same experiments if its IP address changes.
+const experimentCookieName = "pkgsite_experiment_bucket"
+
+// experimentCookieMaxAge is how long a client keeps its bucket.
+const experimentCookieMaxAge = 90 * 24 * time.Hour
+
+var (
+	keyExperimentName = tag.MustNewKey("experiment.name")
+	experimentResults = stats.Int64(
+		"go-discovery/experiment/request_count",
+		"A request enrolled in an experiment.",
+		stats.UnitDimensionless,
+	)
+	// ExperimentRequestCount is a counter of requests by the experiments
+	// they are enrolled in.
+	ExperimentRequestCount = &view.View{
+		Name:        "go-discovery/experiment/request_count",
+		Measure:     experimentResults,
+		Aggregation: view.Count(),
+		Description: "requests enrolled in each experiment",
+		TagKeys:     []tag.Key{keyExperimentName},
+	}
+)
+
 // An Experimenter contains information about active experiments from the
 // experiment source.
 type Experimenter struct {
@@ -47,27 +79,56 @@ func NewExperimenter(ctx context.Context, pollEvery time.Duration, es internal.E
 }
 
 // Experiment returns a new Middleware that sets active experiments for each
-// incoming request.
+// incoming request. The experiments that were active are recorded in the
+// request log entry, if the request came through RequestLog.
 func Experiment(e *Experimenter) Middleware {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			r2 := e.setExperimentsForRequest(r)
+			r2 := e.setExperimentsForRequest(w, r)
+			active := experiment.FromContext(r2.Context()).Active()
+			sort.Strings(active)
+			if len(active) > 0 {
+				log.SetRequestLabel(r.Context(), "experiments", strings.Join(active, ", "))
+			}
+			for _, name := range active {
+				stats.RecordWithTags(r.Context(), []tag.Mutator{
+					tag.Upsert(keyExperimentName, name),
+				}, experimentResults.M(1))
+			}
 			h.ServeHTTP(w, r2)
 		})
 	}
 }
 
 // setExperimentsForRequest sets the experiments for a given request.
-// Experiments should be stable for a given IP address.
-func (e *Experimenter) setExperimentsForRequest(r *http.Request) *http.Request {
+// Experiments are stable for a given client: see experimentBucket.
+//
+// If an experiment is partly rolled out, and the client has no bucket
+// cookie, the cookie is set on w.
+func (e *Experimenter) setExperimentsForRequest(w http.ResponseWriter, r *http.Request) *http.Request {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	bucket, fromCookie := experimentBucket(r)
 	set := map[string]bool{}
+	partial := false
 	for _, exp := range e.snapshot {
-		if shouldSetExperiment(r, exp) {
+		if shouldSetExperiment(bucket, exp) {
 			set[exp.Name] = true
 		}
+		if exp.Rollout > 0 && exp.Rollout < 100 {
+			partial = true
+		}
+	}
+	if partial && bucket != "" && !fromCookie {
+		http.SetCookie(w, &http.Cookie{
+			Name:     experimentCookieName,
+			Value:    bucket,
+			Path:     "/",
+			MaxAge:   int(experimentCookieMaxAge.Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
 	}
 	keys := r.URL.Query()[experimentQueryParamKey]
 	for _, k := range keys {
@@ -110,24 +171,53 @@ func (e *Experimenter) loadNextSnapshot(ctx context.Context) (err error) {
 	return nil
 }
 
-// shouldSetExperiment reports whether a given request should be enrolled in
-// the experiment, based on the ip. e.Name, and e.Rollout.
+// experimentBucket returns the bucket of the client that sent r, which
+// determines the experiments it is enrolled in, and whether it came from the
+// bucket cookie. A client without the cookie is put in a bucket derived from
+// a hash of its IP address; the hash, not the address, is what the cookie
+// holds.
+//
+// It returns "" if the client cannot be identified.
+func experimentBucket(r *http.Request) (bucket string, fromCookie bool) {
+	if c, err := r.Cookie(experimentCookieName); err == nil && isExperimentBucket(c.Value) {
+		return c.Value, true
+	}
+	ip := ipKey(r.Header.Get("X-Forwarded-For"))
+	if ip == "" {
+		return "", false
+	}
+	h := sha256.Sum256([]byte(ip))
+	return hex.EncodeToString(h[:8]), false
+}
+
+// isExperimentBucket reports whether s could have been returned by
+// experimentBucket, to keep arbitrary cookie values out of the hash.
+func isExperimentBucket(s string) bool {
+	if len(s) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// shouldSetExperiment reports whether requests from bucket should be
+// enrolled in the experiment, based on e.Name and e.Rollout.
 //
-// Requests from empty ip addresses are never enrolled.
-// All requests from the same IP will be enrolled in the same set of
-// experiments.
-func shouldSetExperiment(r *http.Request, e *internal.Experiment) bool {
+// The empty bucket is never enrolled, unless the experiment is fully rolled
+// out. A bucket is enrolled in an experiment if a hash of the bucket and the
+// experiment name, modulo 100, is less than the rollout percentage, so that
+// raising the rollout only adds buckets to the experiment.
+func shouldSetExperiment(bucket string, e *internal.Experiment) bool {
 	if e.Rollout == 0 {
 		return false
 	}
-	if e.Rollout == 100 {
+	if e.Rollout >= 100 {
 		return true
 	}
-	ip := ipKey(r.Header.Get("X-Forwarded-For"))
-	if ip == "" {
+	if bucket == "" {
 		return false
 	}
 	h := fnv.New32a()
-	fmt.Fprintf(h, "%s %s", ip, e.Name)
-	return uint(h.Sum32())%(100/e.Rollout) == 0
+	fmt.Fprintf(h, "%s %s", bucket, e.Name)
+	return uint(h.Sum32()%100) < e.Rollout
 }
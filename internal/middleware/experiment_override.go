@@ -0,0 +1,163 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+const (
+	// ExperimentOverridesHeader is the header in which clients send
+	// experiment overrides, such as "insert-directories=on,use-directories=off".
+	ExperimentOverridesHeader = "X-Pkgsite-Experiments"
+
+	// ExperimentOverridesSignatureHeader is the header in which clients send
+	// the signature of the overrides in ExperimentOverridesHeader.
+	ExperimentOverridesSignatureHeader = "X-Pkgsite-Experiments-Signature"
+
+	// The query parameters that can be used instead of the headers, for
+	// browsers.
+	experimentOverridesParam          = "pkgsite-experiments"
+	experimentOverridesSignatureParam = "pkgsite-experiments-sig"
+
+	// maxExperimentOverridesLifetime is the longest time before its expiry
+	// that a signature of experiment overrides is accepted, so that a leaked
+	// URL cannot be used for long.
+	maxExperimentOverridesLifetime = 24 * time.Hour
+)
+
+// ExperimentOverrides returns a Middleware that lets authorized clients turn
+// experiments on or off for a request, regardless of their rollout, so that
+// experimental code paths can be tried in production.
+//
+// The overrides are a comma-separated list of name=on or name=off, in the
+// ExperimentOverridesHeader header or the "pkgsite-experiments" query
+// parameter. They must be signed with key: the signature, in the
+// ExperimentOverridesSignatureHeader header or the "pkgsite-experiments-sig"
+// query parameter, is SignExperimentOverrides(key, overrides, expiry), with an
+// expiry at most a day away. Requests with overrides that are not correctly
+// signed, or whose signature has expired, are served without them.
+//
+// Requests with overrides bypass the page cache, so that their responses are
+// neither served from it nor stored in it.
+//
+// If key is empty, overrides are disabled. ExperimentOverrides must come
+// after Experiment.
+func ExperimentOverrides(key string) Middleware {
+	if key == "" {
+		return Identity()
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			overrides, sig := r.Header.Get(ExperimentOverridesHeader), r.Header.Get(ExperimentOverridesSignatureHeader)
+			if overrides == "" {
+				overrides, sig = r.FormValue(experimentOverridesParam), r.FormValue(experimentOverridesSignatureParam)
+			}
+			if overrides == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			if !validExperimentSignature(key, overrides, sig, time.Now()) {
+				log.Infof(ctx, "ignoring experiment overrides %q: bad or expired signature", overrides)
+				h.ServeHTTP(w, r)
+				return
+			}
+			on, err := parseExperimentOverrides(overrides)
+			if err != nil {
+				log.Infof(ctx, "ignoring experiment overrides: %v", err)
+				h.ServeHTTP(w, r)
+				return
+			}
+			set := map[string]bool{}
+			for _, name := range experiment.FromContext(ctx).Active() {
+				set[name] = true
+			}
+			for name, v := range on {
+				if v {
+					set[name] = true
+				} else {
+					delete(set, name)
+				}
+			}
+			log.Infof(ctx, "applying experiment overrides %q", overrides)
+			s := experiment.NewSet(set)
+			active := s.Active()
+			sort.Strings(active)
+			log.SetRequestLabel(ctx, "experiments", strings.Join(active, ", "))
+			r2 := r.WithContext(experiment.NewContext(ctx, s))
+			r2.Header = r.Header.Clone()
+			r2.Header.Set(cacheBypassHeader, "experiment-overrides")
+			w.Header().Set("Cache-Control", "no-store")
+			h.ServeHTTP(w, r2)
+		})
+	}
+}
+
+// SignExperimentOverrides returns the signature of overrides with key, which
+// expires at expiry. The signature is the expiry, in Unix seconds, and the
+// hex-encoded HMAC-SHA256 of the overrides and the expiry, separated by a dot.
+// It can also be computed with
+//
+//	EXP=$(($(date +%s) + 3600))
+//	echo $EXP.$(printf '%s\n%s' "$OVERRIDES" $EXP | openssl dgst -sha256 -hmac "$KEY" | sed 's/^.* //')
+func SignExperimentOverrides(key, overrides string, expiry time.Time) string {
+	exp := strconv.FormatInt(expiry.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%s", overrides, exp)
+	return exp + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validExperimentSignature reports whether sig is a signature of overrides
+// with key that has not expired at now, and does not expire more than
+// maxExperimentOverridesLifetime after it.
+func validExperimentSignature(key, overrides, sig string, now time.Time) bool {
+	i := strings.IndexByte(sig, '.')
+	if i < 0 {
+		return false
+	}
+	exp, err := strconv.ParseInt(sig[:i], 10, 64)
+	if err != nil {
+		return false
+	}
+	expiry := time.Unix(exp, 0)
+	if now.After(expiry) || expiry.Sub(now) > maxExperimentOverridesLifetime {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(SignExperimentOverrides(key, overrides, expiry)))
+}
+
+// parseExperimentOverrides parses a comma-separated list of name=on or
+// name=off, and returns whether each experiment should be on.
+func parseExperimentOverrides(s string) (map[string]bool, error) {
+	on := map[string]bool{}
+	for _, o := range strings.Split(s, ",") {
+		o = strings.TrimSpace(o)
+		parts := strings.SplitN(o, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("bad experiment override %q", o)
+		}
+		switch parts[1] {
+		case "on":
+			on[parts[0]] = true
+		case "off":
+			on[parts[0]] = false
+		default:
+			return nil, fmt.Errorf("experiment override %q: value must be on or off", o)
+		}
+	}
+	return on, nil
+}
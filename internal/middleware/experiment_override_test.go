@@ -0,0 +1,133 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/experiment"
+)
+
+func TestExperimentOverrides(t *testing.T) {
+	const key = "secret"
+	var (
+		gotActive []string
+		gotBypass string
+	)
+	h := ExperimentOverrides(key)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotActive = experiment.FromContext(r.Context()).Active()
+		sort.Strings(gotActive)
+		gotBypass = r.Header.Get(cacheBypassHeader)
+	}))
+	// The experiments assigned by the Experiment middleware.
+	withExperiments := func(r *http.Request) *http.Request {
+		return r.WithContext(experiment.NewContext(r.Context(), experiment.NewSet(map[string]bool{"a": true, "b": true})))
+	}
+
+	const overrides = "b=off,c=on"
+	expiry := time.Now().Add(time.Hour)
+	sig := SignExperimentOverrides(key, overrides, expiry)
+	for _, test := range []struct {
+		name       string
+		header     http.Header
+		query      url.Values
+		want       []string
+		wantBypass bool
+	}{
+		{
+			name: "none",
+			want: []string{"a", "b"},
+		},
+		{
+			name:       "header",
+			header:     http.Header{ExperimentOverridesHeader: {overrides}, ExperimentOverridesSignatureHeader: {sig}},
+			want:       []string{"a", "c"},
+			wantBypass: true,
+		},
+		{
+			name:       "query",
+			query:      url.Values{experimentOverridesParam: {overrides}, experimentOverridesSignatureParam: {sig}},
+			want:       []string{"a", "c"},
+			wantBypass: true,
+		},
+		{
+			name:   "bad signature",
+			header: http.Header{ExperimentOverridesHeader: {overrides}, ExperimentOverridesSignatureHeader: {SignExperimentOverrides("other", overrides, expiry)}},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "expired",
+			header: http.Header{ExperimentOverridesHeader: {overrides}, ExperimentOverridesSignatureHeader: {SignExperimentOverrides(key, overrides, time.Now().Add(-time.Minute))}},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "unsigned",
+			header: http.Header{ExperimentOverridesHeader: {overrides}},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "bad value",
+			header: http.Header{ExperimentOverridesHeader: {"c=yes"}, ExperimentOverridesSignatureHeader: {SignExperimentOverrides(key, "c=yes", expiry)}},
+			want:   []string{"a", "b"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?"+test.query.Encode(), nil)
+			for k, v := range test.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, withExperiments(r))
+			if diff := cmp.Diff(test.want, gotActive); diff != "" {
+				t.Errorf("active experiments mismatch (-want +got):\n%s", diff)
+			}
+			if got := gotBypass != ""; got != test.wantBypass {
+				t.Errorf("cache bypassed: %t, want %t", got, test.wantBypass)
+			}
+			if r.Header.Get(cacheBypassHeader) != "" {
+				t.Error("original request was modified")
+			}
+		})
+	}
+}
+
+func TestSignExperimentOverrides(t *testing.T) {
+	// From: printf '%s\n%s' 'insert-directories=on' 1600000000 | openssl dgst -sha256 -hmac key
+	const want = "1600000000.cc4501360f04f806135a32a1d4f3eef3e15e292f01e0c19668fce44ba236191d"
+	if got := SignExperimentOverrides("key", "insert-directories=on", time.Unix(1600000000, 0)); got != want {
+		t.Errorf("SignExperimentOverrides = %q, want %q", got, want)
+	}
+}
+
+func TestValidExperimentSignature(t *testing.T) {
+	const key, overrides = "key", "insert-directories=on"
+	now := time.Unix(1600000000, 0)
+	sig := SignExperimentOverrides(key, overrides, now.Add(time.Hour))
+	for _, test := range []struct {
+		name      string
+		overrides string
+		sig       string
+		want      bool
+	}{
+		{"valid", overrides, sig, true},
+		{"other overrides", "insert-directories=off", sig, false},
+		{"expired", overrides, SignExperimentOverrides(key, overrides, now.Add(-time.Second)), false},
+		{"too long", overrides, SignExperimentOverrides(key, overrides, now.Add(maxExperimentOverridesLifetime+time.Hour)), false},
+		{"changed expiry", overrides, "1600009999" + sig[len("1600003600"):], false},
+		{"no expiry", overrides, sig[len("1600003600."):], false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validExperimentSignature(key, test.overrides, test.sig, now); got != test.want {
+				t.Errorf("validExperimentSignature(%q, %q) = %t, want %t", test.overrides, test.sig, got, test.want)
+			}
+		})
+	}
+}
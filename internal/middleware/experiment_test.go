@@ -7,6 +7,7 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
@@ -16,6 +17,7 @@ import (
 	"testing"
 	"time"
 
+	"cloud.google.com/go/logging"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/experiment"
 )
@@ -92,36 +94,36 @@ func TestSetAndLoadExperiments(t *testing.T) {
 }
 
 func TestShouldSetExperiment(t *testing.T) {
+	// Use a fixed seed, so that the test is deterministic.
+	rnd := rand.New(rand.NewSource(1))
 	ipv4Addr := func() string {
 		a := make([]string, 4)
 		for i := 0; i < 4; i++ {
-			// The use case is simple enough that a deterministic
-			// seed should provide enough coverage.
-			a[i] = strconv.Itoa(rand.Intn(256))
+			a[i] = strconv.Itoa(rnd.Intn(256))
 		}
 		return strings.Join(a, ".")
 	}
-	var ipAddresses []string
+	var buckets []string
 	const numIPs = 10000.0
 	for i := 0; i < numIPs; i++ {
-		ip := ipv4Addr()
-		ipAddresses = append(ipAddresses, ip)
+		req, err := http.NewRequest("GET", "http://foo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("X-Forwarded-For", ipv4Addr())
+		b, _ := experimentBucket(req)
+		buckets = append(buckets, b)
 	}
 
-	for _, rollout := range []uint{0, 33, 50, 100} {
+	for _, rollout := range []uint{0, 33, 40, 50, 100} {
 		t.Run(fmt.Sprintf("%d", rollout), func(t *testing.T) {
 			test := &internal.Experiment{
 				Name:    "test",
 				Rollout: rollout,
 			}
 			var inExperiment int
-			for _, ip := range ipAddresses {
-				req, err := http.NewRequest("GET", "http://foo", nil)
-				if err != nil {
-					t.Fatal(err)
-				}
-				req.Header.Add("X-Forwarded-For", ip)
-				if shouldSetExperiment(req, test) {
+			for _, b := range buckets {
+				if shouldSetExperiment(b, test) {
 					inExperiment++
 				}
 			}
@@ -131,10 +133,90 @@ func TestShouldSetExperiment(t *testing.T) {
 				}
 				return
 			}
-			got := uint(100 * inExperiment / numIPs)
-			if got != test.Rollout {
-				t.Errorf("rollout = %d; want = %d", got, test.Rollout)
+			got := 100 * float64(inExperiment) / numIPs
+			if math.Abs(got-float64(test.Rollout)) > 2 {
+				t.Errorf("rollout = %.1f; want = %d", got, test.Rollout)
 			}
 		})
 	}
 }
+
+func TestExperimentBucket(t *testing.T) {
+	experimenter, err := NewExperimenter(context.Background(), time.Hour, &testExperimentSource{
+		experiments: []*internal.Experiment{{Name: "test", Rollout: 50}},
+	}, LocalLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := Experiment(experimenter)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	serve := func(ip string, cookie *http.Cookie) *http.Cookie {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == experimentCookieName {
+				return c
+			}
+		}
+		return nil
+	}
+
+	// A new client gets a cookie with the bucket for its IP address.
+	c := serve("1.2.3.4", nil)
+	if c == nil {
+		t.Fatal("no bucket cookie set")
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	if want, _ := experimentBucket(req); c.Value != want {
+		t.Errorf("cookie value = %q, want %q", c.Value, want)
+	}
+	// A client with the cookie keeps its bucket when its IP address changes.
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Forwarded-For", "5.6.7.8")
+	req.AddCookie(c)
+	if got, fromCookie := experimentBucket(req); got != c.Value || !fromCookie {
+		t.Errorf("experimentBucket with cookie = %q, %t; want %q, true", got, fromCookie, c.Value)
+	}
+	if c2 := serve("5.6.7.8", c); c2 != nil {
+		t.Errorf("cookie set again: %v", c2)
+	}
+	// Cookies that experimentBucket could not have set are ignored.
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: experimentCookieName, Value: "not-a-bucket"})
+	if got, _ := experimentBucket(req); got != "" {
+		t.Errorf("experimentBucket with bad cookie = %q, want empty", got)
+	}
+}
+
+func TestExperimentRequestLog(t *testing.T) {
+	experimenter, err := NewExperimenter(context.Background(), time.Hour, &testExperimentSource{
+		experiments: []*internal.Experiment{{Name: "b", Rollout: 100}, {Name: "a", Rollout: 100}},
+	}, LocalLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lg := &entriesLog{}
+	handler := Chain(RequestLog(lg, 1), Experiment(experimenter))(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if len(lg.entries) != 1 {
+		t.Fatalf("got %d log entries, want 1", len(lg.entries))
+	}
+	if got, want := lg.entries[0].Labels["experiments"], "a, b"; got != want {
+		t.Errorf("experiments label = %q, want %q", got, want)
+	}
+}
+
+// entriesLog is a Logger that keeps the entries it logs.
+type entriesLog struct {
+	entries []logging.Entry
+}
+
+func (l *entriesLog) Log(entry logging.Entry) {
+	l.entries = append(l.entries, entry)
+}
@@ -0,0 +1,28 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/i18n"
+	"golang.org/x/text/language"
+)
+
+// Language returns a Middleware that chooses the language of the response
+// with match, from the Accept-Language header of the request, and stores it in
+// the request context with i18n.NewContext.
+//
+// Language must come before caching and coalescing, whose keys include the
+// language.
+func Language(match func(acceptLanguage string) language.Tag) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Language")
+			lang := match(r.Header.Get("Accept-Language"))
+			h.ServeHTTP(w, r.WithContext(i18n.NewContext(r.Context(), lang)))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/i18n"
+	"golang.org/x/text/language"
+)
+
+func TestLanguage(t *testing.T) {
+	match := func(acceptLanguage string) language.Tag {
+		if acceptLanguage == "fr" {
+			return language.French
+		}
+		return i18n.Default
+	}
+	var keys []string
+	handler := Language(match)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, cacheKey(r))
+		fmt.Fprint(w, i18n.FromContext(r.Context()))
+	}))
+	for _, test := range []struct {
+		acceptLanguage, wantLang, wantKey string
+	}{
+		{"", "en", "/p?q=1"},
+		{"de", "en", "/p?q=1"},
+		{"fr", "fr", "/p?q=1#lang=fr"},
+	} {
+		keys = nil
+		r := httptest.NewRequest("GET", "/p?q=1", nil)
+		if test.acceptLanguage != "" {
+			r.Header.Set("Accept-Language", test.acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if got := w.Body.String(); got != test.wantLang {
+			t.Errorf("%q: language = %q, want %q", test.acceptLanguage, got, test.wantLang)
+		}
+		if got := w.Header().Get("Vary"); got != "Accept-Language" {
+			t.Errorf("%q: Vary = %q, want Accept-Language", test.acceptLanguage, got)
+		}
+		if len(keys) != 1 || keys[0] != test.wantKey {
+			t.Errorf("%q: cache key = %v, want %q", test.acceptLanguage, keys, test.wantKey)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// A Phase is a part of the time spent serving a request.
+type Phase string
+
+const (
+	// PhaseDB is the time spent in database queries. Queries that run
+	// concurrently are added up.
+	PhaseDB Phase = "db"
+	// PhaseCache is the time spent reading pages from the page cache.
+	PhaseCache Phase = "cache"
+	// PhaseTemplate is the time spent executing templates.
+	PhaseTemplate Phase = "template"
+	// PhaseHandler is the rest of the time spent in the handler of the route.
+	PhaseHandler Phase = "handler"
+	// PhaseMiddleware is the time spent in middleware, outside the handler of
+	// the route.
+	PhaseMiddleware Phase = "middleware"
+)
+
+var (
+	keyLatencyRoute = tag.MustNewKey("latency.route")
+	keyLatencyPhase = tag.MustNewKey("latency.phase")
+	phaseLatency    = stats.Float64(
+		"go-discovery/latency/phase",
+		"The time spent in a phase of serving a request.",
+		stats.UnitMilliseconds,
+	)
+	// PhaseLatencyDistribution aggregates the time spent serving requests by
+	// route and phase.
+	PhaseLatencyDistribution = &view.View{
+		Name:        "go-discovery/latency/phase",
+		Measure:     phaseLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "request latency, by route and phase",
+		TagKeys:     []tag.Key{keyLatencyRoute, keyLatencyPhase},
+	}
+)
+
+type latencyKey struct{}
+
+// phaseTimes holds the time spent in the phases of a request. They are
+// recorded while the request is served, possibly on several goroutines.
+type phaseTimes struct {
+	mu      sync.Mutex
+	route   string
+	handler time.Duration
+	phases  map[Phase]time.Duration
+}
+
+// withPhaseTimes returns the phase times of r, and r with a context that holds
+// them. If r already has phase times, as when both Latency and ServerTiming
+// serve it, they are shared.
+func withPhaseTimes(r *http.Request) (*phaseTimes, *http.Request) {
+	if pt, ok := r.Context().Value(latencyKey{}).(*phaseTimes); ok {
+		return pt, r
+	}
+	pt := &phaseTimes{phases: map[Phase]time.Duration{}}
+	return pt, r.WithContext(context.WithValue(r.Context(), latencyKey{}, pt))
+}
+
+// RecordPhase adds d to the time spent in phase by the request being served
+// with ctx. It does nothing if the request is not served by Latency or
+// ServerTiming.
+func RecordPhase(ctx context.Context, phase Phase, d time.Duration) {
+	pt, ok := ctx.Value(latencyKey{}).(*phaseTimes)
+	if !ok {
+		return
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.phases[phase] += d
+}
+
+// Latency returns a Middleware that records the time spent serving requests
+// in the PhaseLatencyDistribution metric, broken down by phase, for requests
+// whose route handler is wrapped by LatencyRoute. The database, cache and
+// template phases are recorded by the code that runs them, with RecordPhase.
+//
+// Latency should come early in the chain, so that the middleware phase covers
+// the rest of it.
+func Latency() Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			pt, r2 := withPhaseTimes(r)
+			h.ServeHTTP(w, r2)
+			total := time.Since(start)
+
+			pt.mu.Lock()
+			defer pt.mu.Unlock()
+			if pt.route == "" {
+				// The request did not reach a route, so its latency cannot be
+				// broken down.
+				return
+			}
+			db, c, tmpl := pt.phases[PhaseDB], pt.phases[PhaseCache], pt.phases[PhaseTemplate]
+			recordPhaseLatency(r.Context(), pt.route, PhaseDB, db)
+			recordPhaseLatency(r.Context(), pt.route, PhaseCache, c)
+			recordPhaseLatency(r.Context(), pt.route, PhaseTemplate, tmpl)
+			recordPhaseLatency(r.Context(), pt.route, PhaseHandler, nonNegative(pt.handler-db-c-tmpl))
+			recordPhaseLatency(r.Context(), pt.route, PhaseMiddleware, nonNegative(total-pt.handler))
+		})
+	}
+}
+
+// LatencyRoute returns a handler that serves requests with h, and records the
+// time spent in it as the time spent in the handler of route, a pattern like
+// those of http.ServeMux, for Latency.
+func LatencyRoute(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pt, ok := r.Context().Value(latencyKey{}).(*phaseTimes)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		defer func() {
+			pt.mu.Lock()
+			defer pt.mu.Unlock()
+			pt.route = route
+			pt.handler += time.Since(start)
+		}()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// nonNegative returns d, or 0 if d is negative, as the time spent in the
+// handler may be less than that of concurrent queries.
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func recordPhaseLatency(ctx context.Context, route string, phase Phase, d time.Duration) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyLatencyRoute, route),
+		tag.Upsert(keyLatencyPhase, string(phase)),
+	}, phaseLatency.M(float64(d)/float64(time.Millisecond)))
+}
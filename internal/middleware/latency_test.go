@@ -0,0 +1,95 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+)
+
+func TestLatency(t *testing.T) {
+	view.Register(PhaseLatencyDistribution)
+	defer view.Unregister(PhaseLatencyDistribution)
+
+	mux := http.NewServeMux()
+	mux.Handle("/pkg/", LatencyRoute("/pkg/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordPhase(r.Context(), PhaseDB, 20*time.Millisecond)
+		// Phases may be recorded concurrently.
+		done := make(chan struct{})
+		go func() {
+			RecordPhase(r.Context(), PhaseDB, 10*time.Millisecond)
+			close(done)
+		}()
+		<-done
+		RecordPhase(r.Context(), PhaseTemplate, 5*time.Millisecond)
+	})))
+	handler := Latency()(mux)
+	for _, path := range []string{"/pkg/a", "/pkg/b", "/no-route"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rows, err := view.RetrieveData(PhaseLatencyDistribution.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]*view.DistributionData{}
+	for _, row := range rows {
+		var route, phase string
+		for _, tg := range row.Tags {
+			switch tg.Key {
+			case keyLatencyRoute:
+				route = tg.Value
+			case keyLatencyPhase:
+				phase = tg.Value
+			}
+		}
+		if route != "/pkg/" {
+			t.Errorf("recorded latency of route %q, want only /pkg/", route)
+			continue
+		}
+		got[phase] = row.Data.(*view.DistributionData)
+	}
+	for _, test := range []struct {
+		phase    Phase
+		wantMean float64 // if 0, only check that the phase was recorded
+	}{
+		{PhaseDB, 30},
+		{PhaseTemplate, 5},
+		{PhaseHandler, 0},
+		{PhaseMiddleware, 0},
+	} {
+		d, ok := got[string(test.phase)]
+		if !ok {
+			t.Errorf("phase %q was not recorded", test.phase)
+			continue
+		}
+		if d.Count != 2 {
+			t.Errorf("phase %q: count = %d, want 2", test.phase, d.Count)
+		}
+		if test.wantMean != 0 && d.Mean != test.wantMean {
+			t.Errorf("phase %q: mean = %v, want %v", test.phase, d.Mean, test.wantMean)
+		}
+		if d.Min < 0 {
+			t.Errorf("phase %q: min = %v, want non-negative", test.phase, d.Min)
+		}
+	}
+}
+
+func TestRecordPhaseWithoutLatency(t *testing.T) {
+	// RecordPhase and LatencyRoute do nothing outside of Latency.
+	h := LatencyRoute("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordPhase(r.Context(), PhaseDB, time.Second)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusTeapot)
+	}
+}
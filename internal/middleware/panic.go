@@ -5,23 +5,118 @@
 package middleware
 
 import (
+	"fmt"
+	"hash/fnv"
 	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
 
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/log"
 )
 
+// fingerprintFrames is the number of frames at the top of the stack of a
+// panic that identify it. Panics with the same top frames are grouped
+// together, even if they were reached from different callers.
+const fingerprintFrames = 3
+
+var (
+	keyPanicFingerprint = tag.MustNewKey("panic.fingerprint")
+	panics              = stats.Int64(
+		"go-discovery/panic/count",
+		"A panic recovered from a handler.",
+		stats.UnitDimensionless,
+	)
+	// PanicCount is a counter of recovered panics, by fingerprint.
+	PanicCount = &view.View{
+		Name:        "go-discovery/panic/count",
+		Measure:     panics,
+		Aggregation: view.Count(),
+		Description: "recovered panics, by fingerprint",
+		TagKeys:     []tag.Key{keyPanicFingerprint},
+	}
+)
+
 // Panic returns a middleware that executes panicHandler on any panic
-// originating from the delegate handler.
+// originating from the delegate handler. If panicHandler is nil, it responds
+// with a plain 500.
+//
+// The panic is logged with its stack and a fingerprint of the functions at
+// the top of the stack, which is also recorded in the PanicCount metric and in
+// the request log entry, so that occurrences of the same panic can be grouped.
 func Panic(panicHandler http.Handler) Middleware {
+	if panicHandler == nil {
+		panicHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		})
+	}
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
-				if e := recover(); e != nil {
-					log.Errorf(r.Context(), "middleware.Panic: %v", e)
-					panicHandler.ServeHTTP(w, r)
+				e := recover()
+				if e == nil {
+					return
+				}
+				if e == http.ErrAbortHandler {
+					// The handler meant to abort the response; let the
+					// server do that.
+					panic(e)
 				}
+				ctx := r.Context()
+				fp := panicFingerprint(panicFrames())
+				err := fmt.Errorf("%v: %w", e, derrors.Panic)
+				log.Errorf(ctx, "middleware.Panic: %v [fingerprint %s]\n%s", err, fp, debug.Stack())
+				log.SetRequestLabel(ctx, "panic", fp)
+				stats.RecordWithTags(ctx, []tag.Mutator{
+					tag.Upsert(keyPanicFingerprint, fp),
+				}, panics.M(1))
+				panicHandler.ServeHTTP(w, r)
 			}()
 			h.ServeHTTP(w, r)
 		})
 	}
 }
+
+// panicFrames returns the names of the functions at the top of the stack of
+// the panic being recovered, starting from the function that panicked. It
+// must be called from the deferred function that recovers the panic.
+func panicFrames() []string {
+	pcs := make([]uintptr, 64)
+	// Skip runtime.Callers, panicFrames and the deferred function.
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var (
+		funcs    []string
+		panicked bool
+	)
+	for {
+		f, more := frames.Next()
+		switch {
+		case !panicked:
+			// Frames up to and including runtime.gopanic belong to the
+			// panic machinery.
+			panicked = f.Function == "runtime.gopanic"
+		case strings.HasPrefix(f.Function, "runtime."):
+			// Panics raised by the runtime, such as for nil dereferences,
+			// go through more runtime frames.
+		default:
+			funcs = append(funcs, f.Function)
+		}
+		if !more || len(funcs) == fingerprintFrames {
+			return funcs
+		}
+	}
+}
+
+// panicFingerprint returns a short hash of the given function names.
+func panicFingerprint(funcs []string) string {
+	h := fnv.New32a()
+	for _, f := range funcs {
+		fmt.Fprintln(h, f)
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
@@ -9,6 +9,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -57,3 +58,55 @@ func TestPanic(t *testing.T) {
 		})
 	}
 }
+
+func TestPanicFingerprint(t *testing.T) {
+	var gotFrames [][]string
+	record := Panic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	// Capture the frames of each panic as Panic sees them.
+	capture := func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if e := recover(); e != nil {
+					gotFrames = append(gotFrames, panicFrames())
+					panic(e)
+				}
+			}()
+			h.ServeHTTP(w, r)
+		})
+	}
+	var m map[string]int
+	explicit := func(http.ResponseWriter, *http.Request) { panicExplicitly() }
+	for _, h := range []http.HandlerFunc{
+		explicit,
+		explicit,
+		func(http.ResponseWriter, *http.Request) { m["x"]++ },
+	} {
+		w := httptest.NewRecorder()
+		record(capture(h)).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("code = %d, want %d", w.Code, http.StatusInternalServerError)
+		}
+	}
+	if len(gotFrames) != 3 {
+		t.Fatalf("got %d panics, want 3", len(gotFrames))
+	}
+	if len(gotFrames[0]) == 0 || !strings.HasSuffix(gotFrames[0][0], ".panicExplicitly") {
+		t.Errorf("frames = %v, want panicExplicitly first", gotFrames[0])
+	}
+	if strings.HasPrefix(gotFrames[2][0], "runtime.") {
+		t.Errorf("frames = %v, want no runtime frames", gotFrames[2])
+	}
+	fp := func(i int) string { return panicFingerprint(gotFrames[i]) }
+	if fp(0) != fp(1) {
+		t.Errorf("same panic got fingerprints %s and %s", fp(0), fp(1))
+	}
+	if fp(0) == fp(2) {
+		t.Errorf("different panics got the same fingerprint %s", fp(0))
+	}
+}
+
+func panicExplicitly() {
+	panic("explicit")
+}
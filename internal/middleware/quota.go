@@ -6,88 +6,276 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"math"
 	"net"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/go-redis/redis/v7"
 	"github.com/golang/groupcache/lru"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/log"
-	"golang.org/x/time/rate"
 )
 
 var (
 	keyQuotaBlocked = tag.MustNewKey("quota.blocked")
+	keyQuotaTier    = tag.MustNewKey("quota.tier")
 	quotaResults    = stats.Int64(
 		"go-discovery/quota_result_count",
 		"The result of a quota check.",
 		stats.UnitDimensionless,
 	)
-	// QuotaResultCount is a counter of quota results, by whether the request was blocked or not.
+	// QuotaResultCount is a counter of quota results, by tier and whether the
+	// request was blocked or not.
 	QuotaResultCount = &view.View{
 		Name:        "go-discovery/quota/result_count",
 		Measure:     quotaResults,
 		Aggregation: view.Count(),
-		Description: "quota results, by blocked or allowed",
-		TagKeys:     []tag.Key{keyQuotaBlocked},
+		Description: "quota results, by tier and blocked or allowed",
+		TagKeys:     []tag.Key{keyQuotaBlocked, keyQuotaTier},
 	}
 )
 
-// Quota implements a simple IP-based rate limiter. Each set of incoming IP
-// addresses with the same low-order byte gets qps requests per second, with the
-// given burst.
-// Information is kept in an LRU cache of size maxEntries.
+// APIKeyHeader is the header in which clients send their API key.
+const APIKeyHeader = "X-Go-Discovery-API-Key"
+
+// Quota implements an IP-based rate limiter, using a token bucket for each
+// client. Each set of incoming IP addresses with the same low-order byte gets
+// settings.QPS requests per second, with a burst of settings.Burst. If
+// settings.RecordOnly is true, these limits are recorded but not enforced.
 //
-// If a request is disallowed, a 429 (TooManyRequests) will be served.
-func Quota(settings config.QuotaSettings) Middleware {
-	var mu sync.Mutex
-	cache := lru.New(settings.MaxEntries)
-
-	return func(h http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			for _, url := range settings.AcceptedURLs {
-				if r.Referer() == url {
-					recordQuotaMetric("accepted")
-					log.Infof(r.Context(), "Quota: accepting referer %q", r.Referer())
-					h.ServeHTTP(w, r)
-					return
-				}
-			}
+// Requests to the paths in settings.Paths get the stricter anonymous limits
+// instead, which are always enforced. Clients that send one of
+// settings.APIKeys in the APIKeyHeader header get the keyed limits on those
+// paths, per key. Crawlers, as identified by their User-Agent, get the
+// crawler limits instead, on settings.CrawlerPaths as well; their buckets are
+// also per IP block, since the User-Agent is easy to forge.
+//
+// The buckets are kept in redis if client is not nil, so that they are shared
+// by all instances, and otherwise in an LRU cache of size
+// settings.MaxEntries. If redis is unavailable, requests are allowed.
+//
+// Enforced responses have RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers, as described in
+// https://tools.ietf.org/html/draft-ietf-httpapi-ratelimit-headers. If a
+// request is disallowed, blocked serves it, after a Retry-After header is set.
+// If blocked is nil, a 429 (TooManyRequests) is served.
+func Quota(settings config.QuotaSettings, client *redis.Client, blocked http.Handler) Middleware {
+	q := &quota{
+		settings: settings,
+		client:   client,
+		cache:    lru.New(settings.MaxEntries),
+		blocked:  blocked,
+		now:      time.Now,
+	}
+	return q.middleware
+}
 
-			key := ipKey(r.Header.Get("X-Forwarded-For"))
-			// key is empty if we couldn't parse an IP, or there is no IP.
-			// Fail open in this case: allow serving.
-			var limiter *rate.Limiter
-			if key != "" {
-				mu.Lock()
-				if v, ok := cache.Get(key); ok {
-					limiter = v.(*rate.Limiter)
-				} else {
-					limiter = rate.NewLimiter(rate.Limit(settings.QPS), settings.Burst)
-					cache.Add(key, limiter)
-				}
-				mu.Unlock()
+type quota struct {
+	settings config.QuotaSettings
+	client   *redis.Client
+	blocked  http.Handler
+	now      func() time.Time // for testing
+
+	mu    sync.Mutex
+	cache *lru.Cache // of *bucket, by key; used without redis
+}
+
+func (q *quota) middleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, url := range q.settings.AcceptedURLs {
+			if r.Referer() == url {
+				recordQuotaMetric("", "accepted")
+				log.Infof(r.Context(), "Quota: accepting referer %q", r.Referer())
+				h.ServeHTTP(w, r)
+				return
 			}
-			blocked := limiter != nil && !limiter.Allow()
-			recordQuotaMetric(strconv.FormatBool(blocked))
-			if blocked && settings.RecordOnly != nil && !*settings.RecordOnly {
+		}
+
+		tierName, tier, enforced, key := q.tier(r)
+		// key is empty if we couldn't parse an IP, or there is no IP.
+		// Fail open in this case: allow serving.
+		if key == "" || tier.QPS <= 0 || tier.Burst <= 0 {
+			recordQuotaMetric(tierName, "false")
+			h.ServeHTTP(w, r)
+			return
+		}
+		allowed, tokens, err := q.take(r.Context(), key, tier)
+		if err != nil {
+			log.Errorf(r.Context(), "Quota: %v", err)
+			h.ServeHTTP(w, r)
+			return
+		}
+		recordQuotaMetric(tierName, strconv.FormatBool(!allowed))
+		if !enforced {
+			h.ServeHTTP(w, r)
+			return
+		}
+		hdr := w.Header()
+		hdr.Set("RateLimit-Limit", strconv.Itoa(tier.Burst))
+		hdr.Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+		hdr.Set("RateLimit-Reset", strconv.Itoa(secondsUntil(float64(tier.Burst)-tokens, tier.QPS)))
+		if !allowed {
+			hdr.Set("Retry-After", strconv.Itoa(secondsUntil(1-tokens, tier.QPS)))
+			if q.blocked == nil {
 				const tmr = http.StatusTooManyRequests
 				http.Error(w, http.StatusText(tmr), tmr)
 				return
 			}
-			h.ServeHTTP(w, r)
-		})
+			q.blocked.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tier returns the limits that apply to r, along with the name of their tier,
+// whether they are enforced, and the key of the client's bucket. The key is
+// empty if the client cannot be identified.
+func (q *quota) tier(r *http.Request) (name string, _ config.QuotaTier, enforced bool, key string) {
+	s := q.settings
+	crawler := classifyUserAgent(r.Header.Get("User-Agent")) == "bot"
+	ip := ipKey(r.Header.Get("X-Forwarded-For"))
+	if !hasAnyPrefix(r.URL.Path, s.Paths) && !(crawler && hasAnyPrefix(r.URL.Path, s.CrawlerPaths)) {
+		def := config.QuotaTier{QPS: s.QPS, Burst: s.Burst}
+		enforced := s.RecordOnly != nil && !*s.RecordOnly
+		if ip == "" {
+			return "default", def, enforced, ""
+		}
+		return "default", def, enforced, "quota:default:" + ip
+	}
+	if k := r.Header.Get(APIKeyHeader); k != "" {
+		for _, ak := range s.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(ak)) == 1 {
+				// Don't store API keys in redis.
+				h := sha256.Sum256([]byte(k))
+				return "keyed", s.Keyed, true, "quota:key:" + hex.EncodeToString(h[:8])
+			}
+		}
+	}
+	name, tier, prefix := "anonymous", s.Anonymous, "quota:ip:"
+	if crawler {
+		name, tier, prefix = "crawler", s.Crawler, "quota:crawler:"
+	}
+	if ip == "" {
+		return name, tier, true, ""
+	}
+	return name, tier, true, prefix + ip
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// take takes a token from the bucket at key, and returns whether there was
+// one, and how many are left.
+func (q *quota) take(ctx context.Context, key string, tier config.QuotaTier) (allowed bool, tokens float64, err error) {
+	if q.client == nil {
+		allowed, tokens := q.takeLocal(key, tier)
+		return allowed, tokens, nil
+	}
+	// As for the cache, use a short timeout so that requests are not held
+	// up if redis is slow.
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	now := q.now().UnixNano() / int64(time.Millisecond)
+	res, err := tokenBucketScript.Run(q.client.WithContext(ctx), []string{key}, tier.QPS, tier.Burst, now).Result()
+	if err != nil {
+		return false, 0, err
+	}
+	vals, ok := res.([]interface{})
+	if !ok || len(vals) != 2 {
+		return false, 0, errUnexpectedScriptResult
+	}
+	a, ok1 := vals[0].(int64)
+	t, ok2 := vals[1].(int64)
+	if !ok1 || !ok2 {
+		return false, 0, errUnexpectedScriptResult
+	}
+	return a == 1, float64(t) / 1000, nil
+}
+
+// tokenBucketScript takes a token from the bucket at KEYS[1], which fills at
+// ARGV[1] tokens per second up to ARGV[2] tokens. ARGV[3] is the current time
+// in milliseconds. It returns whether a token was taken, and the number of
+// tokens left in thousandths.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HMSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate))
+return {allowed, math.floor(tokens * 1000)}
+`)
+
+var errUnexpectedScriptResult = errors.New("unexpected result from token bucket script")
+
+// A bucket is a token bucket kept in memory. It is filled in the same way as
+// the buckets of tokenBucketScript.
+type bucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// takeLocal is like take, for a bucket in q's LRU cache.
+func (q *quota) takeLocal(key string, tier config.QuotaTier) (allowed bool, tokens float64) {
+	now := q.now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var b *bucket
+	if v, ok := q.cache.Get(key); ok {
+		b = v.(*bucket)
+	} else {
+		b = &bucket{tokens: float64(tier.Burst), ts: now}
+		q.cache.Add(key, b)
+	}
+	elapsed := math.Max(0, now.Sub(b.ts).Seconds())
+	b.tokens = math.Min(float64(tier.Burst), b.tokens+elapsed*float64(tier.QPS))
+	b.ts = now
+	if b.tokens < 1 {
+		return false, b.tokens
+	}
+	b.tokens--
+	return true, b.tokens
+}
+
+// secondsUntil returns the number of seconds, rounded up, until a bucket that
+// fills at qps tokens per second has gained n tokens.
+func secondsUntil(n float64, qps int) int {
+	if n <= 0 {
+		return 0
 	}
+	return int(math.Ceil(n / float64(qps)))
 }
 
-func recordQuotaMetric(blocked string) {
+func recordQuotaMetric(tier, blocked string) {
 	stats.RecordWithTags(context.Background(), []tag.Mutator{
 		tag.Upsert(keyQuotaBlocked, blocked),
+		tag.Upsert(keyQuotaTier, tier),
 	}, quotaResults.M(1))
 }
 
@@ -5,19 +5,23 @@
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/golang/groupcache/lru"
 	"github.com/google/go-cmp/cmp"
 	"go.opencensus.io/stats/view"
 	"golang.org/x/pkgsite/internal/config"
 )
 
 func TestQuota(t *testing.T) {
-	mw := Quota(config.QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 1, RecordOnly: boolptr(false)})
+	mw := Quota(config.QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 1, RecordOnly: boolptr(false)}, nil, nil)
 	var npass int
 	h := func(w http.ResponseWriter, r *http.Request) {
 		npass++
@@ -72,7 +76,7 @@ func TestQuota(t *testing.T) {
 
 func TestQuotaRecordOnly(t *testing.T) {
 	// Like TestQuota, but with in RecordOnly mode nothing is actually blocked.
-	mw := Quota(config.QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 1, RecordOnly: boolptr(true)})
+	mw := Quota(config.QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 1, RecordOnly: boolptr(true)}, nil, nil)
 	npass := 0
 	h := func(w http.ResponseWriter, r *http.Request) {
 		npass++
@@ -108,7 +112,7 @@ func TestQuotaRecordOnly(t *testing.T) {
 
 func TestQuotaBadKey(t *testing.T) {
 	// Verify that invalid IP addresses are not blocked.
-	mw := Quota(config.QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 1, RecordOnly: boolptr(true)})
+	mw := Quota(config.QuotaSettings{QPS: 1, Burst: 2, MaxEntries: 1, RecordOnly: boolptr(true)}, nil, nil)
 	npass := 0
 	h := func(w http.ResponseWriter, r *http.Request) {
 		npass++
@@ -149,16 +153,119 @@ func collectViewData(t *testing.T) map[bool]int {
 		t.Fatal(err)
 	}
 	for _, row := range rows {
-		blocked, err := strconv.ParseBool(row.Tags[0].Value)
-		if err != nil {
-			t.Fatalf("collectViewData: %v", err)
+		for _, tg := range row.Tags {
+			if tg.Key != keyQuotaBlocked {
+				continue
+			}
+			blocked, err := strconv.ParseBool(tg.Value)
+			if err != nil {
+				t.Fatalf("collectViewData: %v", err)
+			}
+			m[blocked] += int(row.Data.(*view.CountData).Value)
 		}
-		count := int(row.Data.(*view.CountData).Value)
-		m[blocked] = count
 	}
 	return m
 }
 
+func TestQuotaTiers(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+	blocked := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+	now := time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC)
+	settings := config.QuotaSettings{
+		QPS:          1,
+		Burst:        1,
+		MaxEntries:   10,
+		RecordOnly:   boolptr(true),
+		Anonymous:    config.QuotaTier{QPS: 1, Burst: 2},
+		Keyed:        config.QuotaTier{QPS: 10, Burst: 5},
+		Crawler:      config.QuotaTier{QPS: 1, Burst: 3},
+		APIKeys:      []string{"secret"},
+		Paths:        []string{"/search"},
+		CrawlerPaths: []string{"/"},
+	}
+
+	// The tiers behave the same whether the buckets are kept in redis or
+	// in memory.
+	for _, client := range []*redis.Client{redis.NewClient(&redis.Options{Addr: s.Addr()}), nil} {
+		s.FlushAll()
+		now := now
+		q := &quota{
+			settings: settings,
+			client:   client,
+			cache:    lru.New(settings.MaxEntries),
+			blocked:  blocked,
+			now:      func() time.Time { return now },
+		}
+		h := q.middleware(handler)
+
+		const crawlerUA = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+		getAs := func(path, ip, apiKey, userAgent string) *httptest.ResponseRecorder {
+			t.Helper()
+			r := httptest.NewRequest("GET", path, nil)
+			r.Header.Set("X-Forwarded-For", ip)
+			if apiKey != "" {
+				r.Header.Set(APIKeyHeader, apiKey)
+			}
+			if userAgent != "" {
+				r.Header.Set("User-Agent", userAgent)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+		get := func(path, ip, apiKey string) *httptest.ResponseRecorder {
+			t.Helper()
+			return getAs(path, ip, apiKey, "")
+		}
+		check := func(label string, w *httptest.ResponseRecorder, wantStatus int, wantRemaining string) {
+			t.Helper()
+			if w.Code != wantStatus {
+				t.Errorf("redis=%t, %s: got status %d, want %d", client != nil, label, w.Code, wantStatus)
+			}
+			if got := w.Header().Get("RateLimit-Remaining"); got != wantRemaining {
+				t.Errorf("redis=%t, %s: got RateLimit-Remaining %q, want %q", client != nil, label, got, wantRemaining)
+			}
+		}
+
+		const ip = "1.2.3.4"
+		check("first", get("/search?q=a", ip, ""), http.StatusOK, "1")
+		check("second", get("/search?q=b", ip, ""), http.StatusOK, "0")
+		w := get("/search?q=c", ip, "")
+		check("third", w, http.StatusTooManyRequests, "0")
+		if got, want := w.Header().Get("Retry-After"), "1"; got != want {
+			t.Errorf("redis=%t: got Retry-After %q, want %q", client != nil, got, want)
+		}
+		// Addresses in the same block share a bucket; others don't.
+		check("same block", get("/search", "1.2.3.5", ""), http.StatusTooManyRequests, "0")
+		check("other block", get("/search", "5.6.7.8", ""), http.StatusOK, "1")
+		// Other paths get the default limits, which are only recorded.
+		for i := 0; i < 3; i++ {
+			check("default path", get("/net/http", ip, ""), http.StatusOK, "")
+		}
+		// Clients with an API key have their own bucket; a bad key is ignored.
+		check("keyed", get("/search", ip, "secret"), http.StatusOK, "4")
+		check("bad key", get("/search", ip, "guess"), http.StatusTooManyRequests, "0")
+
+		// Crawlers have their own buckets, which also limit other paths.
+		check("crawler", getAs("/net/http", ip, "", crawlerUA), http.StatusOK, "2")
+		check("crawler search", getAs("/search", ip, "", crawlerUA), http.StatusOK, "1")
+		check("crawler other block", getAs("/net/http", "5.6.7.8", "", crawlerUA), http.StatusOK, "2")
+
+		now = now.Add(time.Second)
+		check("refilled", get("/search", ip, ""), http.StatusOK, "0")
+	}
+}
+
 func TestIPKey(t *testing.T) {
 	for _, test := range []struct {
 		in   string
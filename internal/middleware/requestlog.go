@@ -7,6 +7,7 @@ package middleware
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
@@ -43,38 +44,78 @@ func (l LocalLogger) Log(entry logging.Entry) {
 // which logged PII when behind IAP, in such a way that was impossible to turn
 // off.
 //
+// Each request is logged with a single entry when it ends, with its status,
+// latency and labels for traffic analysis: the route that served it, the kind
+// of client that made it (see classifyUserAgent), and any labels set with
+// log.SetRequestLabel while serving it, such as whether it was a cache hit.
+// Only a fraction sampleRate of the requests that succeed are logged; requests
+// that fail, with a 4xx or 5xx status, are always logged.
+//
 // Logs may be viewed in Pantheon by selecting the log source corresponding to
 // the AppEngine service name (e.g. 'dev-worker').
-func RequestLog(lg Logger) Middleware {
+func RequestLog(lg Logger, sampleRate float64) Middleware {
 	return func(h http.Handler) http.Handler {
-		return &handler{delegate: h, logger: lg}
+		return &handler{delegate: h, logger: lg, sampleRate: sampleRate}
 	}
 }
 
 type handler struct {
-	delegate http.Handler
-	logger   Logger
+	delegate   http.Handler
+	logger     Logger
+	sampleRate float64
+}
+
+// requestLogEntry is the payload of the log entry for a request.
+type requestLogEntry struct {
+	Message        string  `json:"message"`
+	Status         int     `json:"status"`
+	LatencySeconds float64 `json:"latencySeconds"`
+	// SampleRate is the fraction of requests like this one that are logged,
+	// so that each entry stands for 1/SampleRate requests.
+	SampleRate float64 `json:"sampleRate"`
+}
+
+func (e requestLogEntry) String() string {
+	return fmt.Sprintf("%s in %.3fs", e.Message, e.LatencySeconds)
 }
 
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
 	traceID := r.Header.Get("X-Cloud-Trace-Context")
-	h.logger.Log(logging.Entry{
-		HTTPRequest: &logging.HTTPRequest{Request: r},
-		Payload:     "request start",
-		Severity:    logging.Info,
-		Trace:       traceID,
-	})
 	w2 := &responseWriter{ResponseWriter: w}
-	h.delegate.ServeHTTP(w2, r.WithContext(log.NewContextWithTraceID(r.Context(), traceID)))
+	ctx := log.NewContextWithRequestLabels(log.NewContextWithTraceID(r.Context(), traceID))
+	log.SetRequestLabel(ctx, "client", classifyUserAgent(r.UserAgent()))
+	h.delegate.ServeHTTP(w2, r.WithContext(ctx))
+
+	status := translateStatus(w2.status)
+	rate := 1.0
+	severity := logging.Info
+	switch {
+	case status >= 500:
+		severity = logging.Error
+	case status >= 400:
+		severity = logging.Warning
+	default:
+		rate = h.sampleRate
+		if rate < 1 && rand.Float64() >= rate {
+			return
+		}
+	}
+	latency := time.Since(start)
 	h.logger.Log(logging.Entry{
 		HTTPRequest: &logging.HTTPRequest{
 			Request: r,
-			Status:  translateStatus(w2.status),
-			Latency: time.Since(start),
+			Status:  status,
+			Latency: latency,
+		},
+		Payload: requestLogEntry{
+			Message:        "request end",
+			Status:         status,
+			LatencySeconds: latency.Seconds(),
+			SampleRate:     rate,
 		},
-		Payload:  "request end",
-		Severity: logging.Info,
+		Severity: severity,
+		Labels:   log.RequestLabels(ctx),
 		Trace:    traceID,
 	})
 }
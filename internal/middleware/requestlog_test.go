@@ -11,32 +11,58 @@ import (
 
 	"cloud.google.com/go/logging"
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/log"
 )
 
 func TestRequestLog(t *testing.T) {
 	tests := []struct {
-		label   string
-		handler http.HandlerFunc
-		want    fakeLog
+		label      string
+		handler    http.HandlerFunc
+		sampleRate float64
+		want       fakeLog
 	}{
 		{
 			label: "writes status",
 			handler: func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(400)
 			},
-			want: fakeLog{Status: 400},
+			sampleRate: 1,
+			want:       fakeLog{Entries: 1, Status: 400, Severity: logging.Warning, Labels: map[string]string{"client": "tool"}},
 		},
 		{
-			label:   "translates 200s",
-			handler: func(w http.ResponseWriter, r *http.Request) {},
-			want:    fakeLog{Status: 200},
+			label:      "translates 200s",
+			handler:    func(w http.ResponseWriter, r *http.Request) {},
+			sampleRate: 1,
+			want:       fakeLog{Entries: 1, Status: 200, Severity: logging.Info, Labels: map[string]string{"client": "tool"}},
+		},
+		{
+			label: "records labels",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				log.SetRequestLabel(r.Context(), "cache", "hit")
+			},
+			sampleRate: 1,
+			want:       fakeLog{Entries: 1, Status: 200, Severity: logging.Info, Labels: map[string]string{"client": "tool", "cache": "hit"}},
+		},
+		{
+			label:      "samples 200s",
+			handler:    func(w http.ResponseWriter, r *http.Request) {},
+			sampleRate: 0,
+			want:       fakeLog{},
+		},
+		{
+			label: "always logs errors",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(500)
+			},
+			sampleRate: 0,
+			want:       fakeLog{Entries: 1, Status: 500, Severity: logging.Error, Labels: map[string]string{"client": "tool"}},
 		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.label, func(t *testing.T) {
 			lg := fakeLog{}
-			mw := RequestLog(&lg)
+			mw := RequestLog(&lg, test.sampleRate)
 			ts := httptest.NewServer(mw(test.handler))
 			defer ts.Close()
 			resp, err := ts.Client().Get(ts.URL)
@@ -52,11 +78,17 @@ func TestRequestLog(t *testing.T) {
 }
 
 type fakeLog struct {
-	Status int
+	Entries  int
+	Status   int
+	Severity logging.Severity
+	Labels   map[string]string
 }
 
 func (l *fakeLog) Log(entry logging.Entry) {
+	l.Entries++
 	if entry.HTTPRequest != nil {
 		l.Status = entry.HTTPRequest.Status
 	}
+	l.Severity = entry.Severity
+	l.Labels = entry.Labels
 }
@@ -14,9 +14,15 @@ import (
 )
 
 // NoncePlaceholder should be used as the value for nonces in rendered content.
-// It is substituted for the actual nonce value by the SecureHeaders middleware.
+// It is substituted for the actual nonce value by the SecureHeaders middleware,
+// but only in nonce attributes, as in <script nonce="$$GODISCOVERYNONCE$$">,
+// so that a page that shows the placeholder elsewhere, like in a README or
+// a search query, does not reveal the nonce.
 const NoncePlaceholder = "$$GODISCOVERYNONCE$$"
 
+// nonceAttr is a nonce attribute with the placeholder value.
+var nonceAttr = []byte(`nonce="` + NoncePlaceholder + `"`)
+
 // policy is a helper for constructing content security policies.
 type policy struct {
 	directives []string
@@ -109,7 +115,7 @@ func SecureHeaders() Middleware {
 
 			crw := &capturingResponseWriter{ResponseWriter: w}
 			h.ServeHTTP(crw, r)
-			body := bytes.ReplaceAll(crw.bytes(), []byte(NoncePlaceholder), []byte(nonce))
+			body := bytes.ReplaceAll(crw.bytes(), nonceAttr, []byte(`nonce="`+nonce+`"`))
 			if _, err := w.Write(body); err != nil {
 				log.Errorf(r.Context(), "SecureHeaders, writing: %v", err)
 			}
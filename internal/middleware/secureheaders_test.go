@@ -31,6 +31,7 @@ func TestSecureHeaders(t *testing.T) {
     <script nonce="$$GODISCOVERYNONCE$$">js</script>
     bloo bloo bloo
     <iframe nonce="$$GODISCOVERYNONCE$$" src="baz"></iframe>
+    <p title="$$GODISCOVERYNONCE$$">$$GODISCOVERYNONCE$$</p>
 `
 
 	const wantBodyFmt = `
@@ -40,6 +41,7 @@ func TestSecureHeaders(t *testing.T) {
     <script nonce="%[1]s">js</script>
     bloo bloo bloo
     <iframe nonce="%[1]s" src="baz"></iframe>
+    <p title="$$GODISCOVERYNONCE$$">$$GODISCOVERYNONCE$$</p>
 `
 
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -82,3 +84,27 @@ func TestSecureHeaders(t *testing.T) {
 		t.Errorf("got  body %s\nwant body %s", gotBody, wantBody)
 	}
 }
+
+func TestSecureHeadersNoncePerResponse(t *testing.T) {
+	handler := SecureHeaders()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<script nonce="$$GODISCOVERYNONCE$$">js</script>`)
+	}))
+	nonceRE := regexp.MustCompile(`'nonce-([^']+)'`)
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		matches := nonceRE.FindStringSubmatch(w.Header().Get("Content-Security-Policy"))
+		if matches == nil {
+			t.Fatal("cannot extract nonce")
+		}
+		nonce := matches[1]
+		if seen[nonce] {
+			t.Errorf("nonce %q was served twice", nonce)
+		}
+		seen[nonce] = true
+		if got, want := w.Body.String(), `<script nonce="`+nonce+`">js</script>`; got != want {
+			t.Errorf("body = %q, want %q", got, want)
+		}
+	}
+}
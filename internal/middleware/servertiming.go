@@ -0,0 +1,103 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ServerTimingKeyHeader is the header in which clients send the key that
+// authorizes them to receive Server-Timing headers.
+const ServerTimingKeyHeader = "X-Pkgsite-Server-Timing-Key"
+
+// serverTimingPhases are the phases reported in Server-Timing headers, in
+// order, with their descriptions.
+var serverTimingPhases = []struct {
+	phase Phase
+	desc  string
+}{
+	{PhaseDB, "Database queries"},
+	{PhaseCache, "Page cache"},
+	{PhaseTemplate, "Rendering"},
+}
+
+// ServerTiming returns a Middleware that adds a Server-Timing header to
+// responses, which browser developer tools show, with the time spent in the
+// database, cache and template phases, as recorded with RecordPhase, and the
+// total time, up to when the response started. The header is added to all
+// responses if always is true, and otherwise only to requests that have key
+// in the ServerTimingKeyHeader header. If always is false and key is empty,
+// the header is never added.
+//
+// ServerTiming should come early in the chain, so that the total covers the
+// rest of it.
+func ServerTiming(always bool, key string) Middleware {
+	if !always && key == "" {
+		return Identity()
+	}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !always && subtle.ConstantTimeCompare([]byte(r.Header.Get(ServerTimingKeyHeader)), []byte(key)) != 1 {
+				h.ServeHTTP(w, r)
+				return
+			}
+			pt, r := withPhaseTimes(r)
+			tw := &serverTimingResponseWriter{ResponseWriter: w, start: time.Now(), pt: pt}
+			h.ServeHTTP(tw, r)
+			// The handler may not have written anything.
+			tw.setHeader()
+		})
+	}
+}
+
+// serverTimingResponseWriter sets the Server-Timing header when the response
+// starts.
+type serverTimingResponseWriter struct {
+	http.ResponseWriter
+	start     time.Time
+	pt        *phaseTimes
+	headerSet bool
+}
+
+func (w *serverTimingResponseWriter) WriteHeader(statusCode int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *serverTimingResponseWriter) Write(b []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *serverTimingResponseWriter) setHeader() {
+	if w.headerSet {
+		return
+	}
+	w.headerSet = true
+	w.Header().Set("Server-Timing", w.pt.serverTiming(time.Since(w.start)))
+}
+
+// serverTiming returns the value of a Server-Timing header for the phases of
+// pt, and the total time.
+func (pt *phaseTimes) serverTiming(total time.Duration) string {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	var metrics []string
+	for _, p := range serverTimingPhases {
+		if d, ok := pt.phases[p.phase]; ok {
+			metrics = append(metrics, serverTimingMetric(string(p.phase), p.desc, d))
+		}
+	}
+	metrics = append(metrics, serverTimingMetric("total", "Total", total))
+	return strings.Join(metrics, ", ")
+}
+
+func serverTimingMetric(name, desc string, d time.Duration) string {
+	return fmt.Sprintf("%s;desc=%q;dur=%.1f", name, desc, float64(d)/float64(time.Millisecond))
+}
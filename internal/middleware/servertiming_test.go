@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestServerTiming(t *testing.T) {
+	const key = "secret"
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordPhase(r.Context(), PhaseDB, 20*time.Millisecond)
+		RecordPhase(r.Context(), PhaseDB, 5*time.Millisecond)
+		RecordPhase(r.Context(), PhaseTemplate, 3*time.Millisecond)
+		fmt.Fprint(w, "page")
+		// Phases recorded after the response started are not reported.
+		RecordPhase(r.Context(), PhaseCache, time.Second)
+	})
+	want := regexp.MustCompile(`^db;desc="Database queries";dur=25\.0, template;desc="Rendering";dur=3\.0, total;desc="Total";dur=\d+\.\d$`)
+
+	for _, test := range []struct {
+		name      string
+		always    bool
+		key       string
+		headerKey string
+		wantTime  bool
+	}{
+		{"always", true, "", "", true},
+		{"always with key", true, key, "", true},
+		{"authorized", false, key, key, true},
+		{"wrong key", false, key, "guess", false},
+		{"no key", false, key, "", false},
+		{"disabled", false, "", "", false},
+		{"disabled with header", false, "", "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if test.headerKey != "" {
+				r.Header.Set(ServerTimingKeyHeader, test.headerKey)
+			}
+			w := httptest.NewRecorder()
+			ServerTiming(test.always, test.key)(handler).ServeHTTP(w, r)
+			got := w.Header().Get("Server-Timing")
+			if !test.wantTime {
+				if got != "" {
+					t.Errorf("Server-Timing = %q, want none", got)
+				}
+				return
+			}
+			if !want.MatchString(got) {
+				t.Errorf("Server-Timing = %q, want match for %s", got, want)
+			}
+			if w.Body.String() != "page" {
+				t.Errorf("body = %q, want %q", w.Body.String(), "page")
+			}
+		})
+	}
+}
+
+func TestServerTimingNoBody(t *testing.T) {
+	// The header is set even if the handler writes nothing, and shares the
+	// phases recorded for Latency.
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		RecordPhase(r.Context(), PhaseCache, 2*time.Millisecond)
+	})
+	w := httptest.NewRecorder()
+	Latency()(ServerTiming(true, "")(handler)).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	want := regexp.MustCompile(`^cache;desc="Page cache";dur=2\.0, total;desc="Total";dur=\d+\.\d$`)
+	if got := w.Header().Get("Server-Timing"); !want.MatchString(got) {
+		t.Errorf("Server-Timing = %q, want match for %s", got, want)
+	}
+}
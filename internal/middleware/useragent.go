@@ -0,0 +1,38 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import "strings"
+
+// Substrings of the lower-cased User-Agent header that identify crawlers and
+// command-line tools or libraries. Crawlers are checked first, since some of
+// them also name the library they are built with.
+var (
+	botUserAgents  = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "archive.org_bot"}
+	toolUserAgents = []string{"curl", "wget", "go-http-client", "python", "java/", "okhttp", "libwww-perl", "httpie", "postman"}
+)
+
+// classifyUserAgent returns the kind of client that sent a request with the
+// given User-Agent header: "bot", "tool", "browser", or "unknown".
+func classifyUserAgent(ua string) string {
+	ua = strings.ToLower(ua)
+	if ua == "" {
+		return "unknown"
+	}
+	for _, s := range botUserAgents {
+		if strings.Contains(ua, s) {
+			return "bot"
+		}
+	}
+	for _, s := range toolUserAgents {
+		if strings.Contains(ua, s) {
+			return "tool"
+		}
+	}
+	if strings.HasPrefix(ua, "mozilla/") || strings.HasPrefix(ua, "opera/") {
+		return "browser"
+	}
+	return "unknown"
+}
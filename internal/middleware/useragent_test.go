@@ -0,0 +1,27 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package middleware
+
+import "testing"
+
+func TestClassifyUserAgent(t *testing.T) {
+	for _, test := range []struct {
+		ua, want string
+	}{
+		{"", "unknown"},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.75 Safari/537.36", "browser"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "bot"},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", "bot"},
+		{"facebookexternalhit/1.1", "bot"},
+		{"curl/7.68.0", "tool"},
+		{"Go-http-client/1.1", "tool"},
+		{"python-requests/2.24.0", "tool"},
+		{"SomethingElse/1.0", "unknown"},
+	} {
+		if got := classifyUserAgent(test.ua); got != test.want {
+			t.Errorf("classifyUserAgent(%q) = %q, want %q", test.ua, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package migrations applies schema migrations to the discovery database.
+//
+// Migrations are read from files named {version}_{title}.up.sql and
+// {version}_{title}.down.sql. The current version is recorded in the
+// schema_migrations table, in the same format used by
+// github.com/golang-migrate/migrate, so a database can be migrated by either.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A Migration is a single schema change.
+type Migration struct {
+	Version uint
+	Title   string
+	// Up and Down are the SQL statements that apply and revert the
+	// migration.
+	Up, Down string
+}
+
+var fileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the top-level directory of fsys, and returns
+// them in order of version. Every migration must have an up file.
+func Load(fsys fs.FS) (_ []*Migration, err error) {
+	defer derrors.Wrap(&err, "migrations.Load")
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[uint]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := fileRegexp.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		v, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.Name(), err)
+		}
+		if v == 0 {
+			return nil, fmt.Errorf("%s: version must be positive", e.Name())
+		}
+		mig := byVersion[uint(v)]
+		if mig == nil {
+			mig = &Migration{Version: uint(v), Title: m[2]}
+			byVersion[uint(v)] = mig
+		} else if mig.Title != m[2] {
+			return nil, fmt.Errorf("%s: version %d is also used by %q", e.Name(), v, mig.Title)
+		}
+		contents, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if m[3] == "up" {
+			mig.Up = string(contents)
+		} else {
+			mig.Down = string(contents)
+		}
+	}
+	var migs []*Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Title)
+		}
+		migs = append(migs, m)
+	}
+	sort.Slice(migs, func(i, j int) bool { return migs[i].Version < migs[j].Version })
+	return migs, nil
+}
+
+// lockID is the key of the advisory lock that is held while migrating, so
+// that binaries starting at the same time do not migrate concurrently.
+const lockID = 0x706b6773697465 // "pkgsite"
+
+// Version returns the current version of db, and whether the migration to
+// that version failed part way through. A version of 0 means that no
+// migrations have been applied. Version does not change db: if the version
+// table does not exist, it returns 0 without creating it.
+func Version(ctx context.Context, db *database.DB) (_ uint, dirty bool, err error) {
+	defer derrors.Wrap(&err, "migrations.Version")
+
+	var exists bool
+	if err := db.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return 0, false, err
+	}
+	if !exists {
+		return 0, false, nil
+	}
+	return version(ctx, db)
+}
+
+// Up applies, in order, all the migrations in fsys that are newer than the
+// current version of db, and returns the migrations it applied. It returns
+// an error without applying anything if a previous migration failed.
+func Up(ctx context.Context, db *database.DB, fsys fs.FS) (applied []*Migration, err error) {
+	defer derrors.Wrap(&err, "migrations.Up")
+
+	migs, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	err = withLock(ctx, db, func(conn *database.DB) error {
+		current, err := cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migs {
+			if m.Version <= current {
+				continue
+			}
+			if err := run(ctx, conn, m.Version, m.Up); err != nil {
+				return fmt.Errorf("migrating up to %d_%s: %w", m.Version, m.Title, err)
+			}
+			log.Infof(ctx, "applied migration %d_%s", m.Version, m.Title)
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	if len(applied) > 0 {
+		db.InvalidateStatementCache()
+	}
+	if err != nil {
+		return applied, err
+	}
+	return applied, nil
+}
+
+// Down reverts the n most recent migrations applied to db, and returns the
+// migrations it reverted. It is meant for development; deployed databases
+// should only be migrated up.
+func Down(ctx context.Context, db *database.DB, fsys fs.FS, n int) (reverted []*Migration, err error) {
+	defer derrors.Wrap(&err, "migrations.Down(%d)", n)
+
+	migs, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	err = withLock(ctx, db, func(conn *database.DB) error {
+		current, err := cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migs) - 1; i >= 0 && len(reverted) < n; i-- {
+			m := migs[i]
+			if m.Version > current {
+				continue
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down file", m.Version, m.Title)
+			}
+			var prev uint
+			if i > 0 {
+				prev = migs[i-1].Version
+			}
+			if err := run(ctx, conn, prev, m.Down); err != nil {
+				return fmt.Errorf("migrating down from %d_%s: %w", m.Version, m.Title, err)
+			}
+			log.Infof(ctx, "reverted migration %d_%s", m.Version, m.Title)
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	if len(reverted) > 0 {
+		db.InvalidateStatementCache()
+	}
+	if err != nil {
+		return reverted, err
+	}
+	return reverted, nil
+}
+
+// Force sets the version of db to v and marks it clean, without running any
+// migrations. It is used to recover after a migration fails and the schema
+// has been fixed by hand.
+func Force(ctx context.Context, db *database.DB, v uint) (err error) {
+	defer derrors.Wrap(&err, "migrations.Force(%d)", v)
+	return withLock(ctx, db, func(conn *database.DB) error {
+		return setVersion(ctx, conn, v, false)
+	})
+}
+
+// withLock calls f with a single connection to db, on which the migration
+// lock is held and the version table exists.
+func withLock(ctx context.Context, db *database.DB, f func(*database.DB) error) error {
+	return db.WithConn(ctx, func(conn *database.DB) (err error) {
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+			return err
+		}
+		defer func() {
+			if _, uerr := conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, lockID); uerr != nil && err == nil {
+				err = uerr
+			}
+		}()
+		if err := ensureVersionTable(ctx, conn); err != nil {
+			return err
+		}
+		return f(conn)
+	})
+}
+
+// run marks db dirty at version v, executes the statements in stmts, and
+// marks db clean at version v.
+func run(ctx context.Context, conn *database.DB, v uint, stmts string) error {
+	if err := setVersion(ctx, conn, v, true); err != nil {
+		return err
+	}
+	// Executing without arguments allows multiple statements, which the
+	// migration files manage in their own transactions.
+	if _, err := conn.Exec(ctx, stmts); err != nil {
+		// A statement that fails inside a migration's transaction leaves it
+		// open and aborted. Roll it back, so that the lock can be released
+		// and the connection is usable when it returns to the pool.
+		if _, rerr := conn.Exec(ctx, `ROLLBACK`); rerr != nil {
+			log.Errorf(ctx, "rolling back failed migration: %v", rerr)
+		}
+		return err
+	}
+	return setVersion(ctx, conn, v, false)
+}
+
+func ensureVersionTable(ctx context.Context, db *database.DB) error {
+	_, err := db.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`)
+	return err
+}
+
+// version returns the version recorded in schema_migrations, or 0 if there
+// is none.
+func version(ctx context.Context, db *database.DB) (v uint, dirty bool, err error) {
+	err = db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&v, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	return v, dirty, err
+}
+
+// cleanVersion returns the version of db, or an error if it is dirty.
+func cleanVersion(ctx context.Context, db *database.DB) (uint, error) {
+	v, dirty, err := version(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("database is dirty at version %d: fix the schema, then force the version", v)
+	}
+	return v, nil
+}
+
+// setVersion records v as the version of db. A version of 0 is recorded by
+// leaving the table empty.
+func setVersion(ctx context.Context, conn *database.DB, v uint, dirty bool) (err error) {
+	// conn is a single connection, so the statements between BEGIN and
+	// COMMIT run in one transaction.
+	if _, err := conn.Exec(ctx, `BEGIN`); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			conn.Exec(ctx, `ROLLBACK`)
+		}
+	}()
+	if _, err := conn.Exec(ctx, `TRUNCATE schema_migrations`); err != nil {
+		return err
+	}
+	if v > 0 {
+		if _, err := conn.Exec(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, v, dirty); err != nil {
+			return err
+		}
+	}
+	_, err = conn.Exec(ctx, `COMMIT`)
+	return err
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrations
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/dbtest"
+	"golang.org/x/pkgsite/migrations"
+)
+
+const testTimeout = 30 * time.Second
+
+var testDB *database.DB
+
+func TestMain(m *testing.M) {
+	const dbName = "discovery_migrations_test"
+
+	stop := dbtest.StartClusterIfRequested()
+	if err := dbtest.CreateDBIfNotExists(dbName); err != nil {
+		stop()
+		if errors.Is(err, derrors.NotFound) && os.Getenv("GO_DISCOVERY_TESTDB") != "true" {
+			log.Printf("SKIPPING: could not connect to DB (see doc/postgres.md to set up): %v", err)
+			// Tests that need testDB skip themselves.
+			os.Exit(m.Run())
+		}
+		log.Fatal(err)
+	}
+	var err error
+	testDB, err = database.Open("postgres", dbtest.DBConnURI(dbName), "test")
+	if err != nil {
+		log.Fatalf("database.Open: %v", err)
+	}
+	code := m.Run()
+	err = testDB.Close()
+	stop()
+	if err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(code)
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_b.up.sql":   {Data: []byte("up 2")},
+		"000001_a.up.sql":   {Data: []byte("up 1")},
+		"000001_a.down.sql": {Data: []byte("down 1")},
+		"README.md":         {Data: []byte("ignored")},
+	}
+	got, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d migrations, want 2", len(got))
+	}
+	if m := got[0]; m.Version != 1 || m.Title != "a" || m.Up != "up 1" || m.Down != "down 1" {
+		t.Errorf("got %+v, want version 1", m)
+	}
+	if m := got[1]; m.Version != 2 || m.Title != "b" || m.Up != "up 2" || m.Down != "" {
+		t.Errorf("got %+v, want version 2", m)
+	}
+
+	for _, bad := range []fstest.MapFS{
+		{"000001_a.down.sql": {}},
+		{"000001_a.up.sql": {Data: []byte("x")}, "000001_b.up.sql": {Data: []byte("y")}},
+		{"000000_a.up.sql": {Data: []byte("x")}},
+	} {
+		if _, err := Load(bad); err == nil {
+			t.Errorf("Load(%v): got nil, want error", bad)
+		}
+	}
+}
+
+func TestLoadEmbedded(t *testing.T) {
+	migs, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migs {
+		if m.Version != uint(i+1) {
+			t.Errorf("migration %d_%s: want version %d", m.Version, m.Title, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %d_%s has no down file", m.Version, m.Title)
+		}
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	if testDB == nil {
+		t.Skip("no test database")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	fsys := fstest.MapFS{
+		"000001_create.up.sql":   {Data: []byte("BEGIN; CREATE TABLE migrations_test (a int); END;")},
+		"000001_create.down.sql": {Data: []byte("BEGIN; DROP TABLE migrations_test; END;")},
+		"000002_alter.up.sql":    {Data: []byte("BEGIN; ALTER TABLE migrations_test ADD COLUMN b int; END;")},
+		"000002_alter.down.sql":  {Data: []byte("BEGIN; ALTER TABLE migrations_test DROP COLUMN b; END;")},
+	}
+	if _, err := testDB.Exec(ctx, `DROP TABLE IF EXISTS migrations_test, schema_migrations`); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion := func(want uint) {
+		t.Helper()
+		got, dirty, err := Version(ctx, testDB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want || dirty {
+			t.Errorf("got version %d (dirty=%t), want %d", got, dirty, want)
+		}
+	}
+
+	// Version does not create the version table.
+	checkVersion(0)
+	var exists bool
+	if err := testDB.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Version created schema_migrations")
+	}
+
+	applied, err := Up(ctx, testDB, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("Up applied %d migrations, want 2", len(applied))
+	}
+	checkVersion(2)
+	if _, err := testDB.Exec(ctx, `INSERT INTO migrations_test (a, b) VALUES (1, 2)`); err != nil {
+		t.Fatal(err)
+	}
+
+	// Up is a no-op when there is nothing new.
+	applied, err = Up(ctx, testDB, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second Up applied %d migrations, want 0", len(applied))
+	}
+
+	if _, err := Down(ctx, testDB, fsys, 1); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion(1)
+	if _, err := Down(ctx, testDB, fsys, 1); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion(0)
+
+	// A failed migration leaves the database dirty until it is forced. Its
+	// transaction is rolled back, so the database can still be used.
+	fsys["000003_bad.up.sql"] = &fstest.MapFile{Data: []byte("BEGIN; SELECT * FROM no_such_table; END;")}
+	if _, err := Up(ctx, testDB, fsys); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if _, dirty, err := Version(ctx, testDB); err != nil || !dirty {
+		t.Fatalf("got dirty=%t, err=%v; want dirty", dirty, err)
+	}
+	if _, err := Up(ctx, testDB, fsys); err == nil {
+		t.Fatal("Up on dirty database: got nil, want error")
+	}
+	if err := Force(ctx, testDB, 2); err != nil {
+		t.Fatal(err)
+	}
+	checkVersion(2)
+	if _, err := testDB.Exec(ctx, `DROP TABLE migrations_test, schema_migrations`); err != nil {
+		t.Fatal(err)
+	}
+}
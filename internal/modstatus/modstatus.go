@@ -0,0 +1,213 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package modstatus defines the statuses of module versions in the
+// module_version_states and version_map tables, and how they change as
+// module versions are processed and reprocessed.
+//
+// Statuses are HTTP status codes where one fits, like 404 for a version that
+// the proxy does not have. Other outcomes of processing use codes that HTTP
+// does not: 29x for versions that were processed with problems, 48x and 49x
+// for versions that cannot be served, and 52x and 54x for versions that are
+// waiting to be reprocessed, which remember the status they had before.
+//
+// A version that has never been processed has status 0. Processing gives it a
+// result status. Some results are reprocessable: marking the version for
+// reprocessing moves it to the matching reprocess status, until it is
+// processed again.
+package modstatus
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// A Code is the status of a module version.
+type Code int
+
+const (
+	// Unprocessed is the status of a version that was never processed.
+	Unprocessed Code = 0
+
+	// OK is the status of a version that was processed successfully.
+	OK Code = http.StatusOK
+	// HasIncompletePackages is the status of a version that was processed,
+	// but some of whose packages could not be.
+	HasIncompletePackages Code = 290
+
+	// InvalidArgument, Excluded, NotFound and Removed are the statuses of
+	// versions that could not be fetched for the HTTP reason of the same
+	// name.
+	InvalidArgument Code = http.StatusBadRequest
+	Excluded        Code = http.StatusForbidden
+	NotFound        Code = http.StatusNotFound
+	Removed         Code = http.StatusUnavailableForLegalReasons
+	// DBModuleInsertInvalid is the status of a version that was fetched but
+	// could not be inserted.
+	DBModuleInsertInvalid Code = 480
+	// BadModule is the status of a version with a problem, like an invalid
+	// go.mod file.
+	BadModule Code = 490
+	// AlternativeModule is the status of a version whose go.mod file gives
+	// another module path. It is served under that path instead.
+	AlternativeModule Code = 491
+	// ChecksumMismatch is the status of a version whose hashes do not match
+	// the checksum database.
+	ChecksumMismatch Code = 492
+
+	// InternalError is the status of a version whose processing failed for a
+	// reason that may not happen again.
+	InternalError Code = http.StatusInternalServerError
+
+	// The reprocess statuses are those of versions that are waiting to be
+	// reprocessed, by the status they had before. Their order is the order
+	// in which they are reprocessed.
+	ReprocessOK                    Code = 520
+	ReprocessHasIncompletePackages Code = 521
+	ReprocessBadModule             Code = 540
+	ReprocessAlternative           Code = 541
+)
+
+// reprocessCodes maps the reprocessable statuses to their reprocess status.
+var reprocessCodes = map[Code]Code{
+	OK:                    ReprocessOK,
+	HasIncompletePackages: ReprocessHasIncompletePackages,
+	BadModule:             ReprocessBadModule,
+	AlternativeModule:     ReprocessAlternative,
+}
+
+// descriptions are the descriptions of the statuses in pages.
+var descriptions = map[Code]string{
+	Unprocessed:                    "unprocessed",
+	OK:                             "ok",
+	HasIncompletePackages:          "has incomplete packages",
+	InvalidArgument:                "invalid argument",
+	Excluded:                       "excluded",
+	NotFound:                       "not found",
+	Removed:                        "removed",
+	DBModuleInsertInvalid:          "db module insert invalid",
+	BadModule:                      "bad module",
+	AlternativeModule:              "alternative module",
+	ChecksumMismatch:               "checksum mismatch",
+	InternalError:                  "internal error",
+	ReprocessOK:                    "reprocess ok",
+	ReprocessHasIncompletePackages: "reprocess has incomplete packages",
+	ReprocessBadModule:             "reprocess bad module",
+	ReprocessAlternative:           "reprocess alternative module",
+}
+
+// Reprocessable returns the statuses that have a reprocess status, in
+// increasing order.
+func Reprocessable() []Code {
+	return []Code{OK, HasIncompletePackages, BadModule, AlternativeModule}
+}
+
+// Description returns a short description of c, like "bad module". Codes
+// without a description of their own are described by their HTTP status text,
+// if they have one.
+func (c Code) Description() string {
+	if d, ok := descriptions[c]; ok {
+		return d
+	}
+	return strings.ToLower(http.StatusText(int(c)))
+}
+
+// String returns c and its description, like "490 (bad module)".
+func (c Code) String() string {
+	if d := c.Description(); d != "" {
+		return fmt.Sprintf("%d (%s)", int(c), d)
+	}
+	return strconv.Itoa(int(c))
+}
+
+// Succeeded reports whether processing a version succeeded with status c,
+// possibly with problems in some of its packages.
+func (c Code) Succeeded() bool {
+	return c == OK || c == HasIncompletePackages
+}
+
+// HasModule reports whether a version with status c was inserted in the
+// database, so that its pages can be served. That is so even while it waits
+// to be reprocessed.
+func (c Code) HasModule() bool {
+	return c.Succeeded() || c == ReprocessOK || c == ReprocessHasIncompletePackages
+}
+
+// IsAlternative reports whether c is the status of an alternative module,
+// including while it waits to be reprocessed.
+func (c Code) IsAlternative() bool {
+	return c == AlternativeModule || c == ReprocessAlternative
+}
+
+// IsReprocessable reports whether a version with status c can be marked for
+// reprocessing.
+func (c Code) IsReprocessable() bool {
+	_, ok := reprocessCodes[c]
+	return ok
+}
+
+// IsReprocess reports whether c is the status of a version that is waiting to
+// be reprocessed.
+func (c Code) IsReprocess() bool {
+	for _, r := range reprocessCodes {
+		if c == r {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFailure reports whether processing a version failed with status c in a
+// way that may not happen again, so that it is retried.
+func (c Code) IsFailure() bool {
+	return c >= InternalError && !c.IsReprocess()
+}
+
+// IsResult reports whether c is a status that processing can give a version.
+func (c Code) IsResult() bool {
+	return c != Unprocessed && !c.IsReprocess()
+}
+
+// Reprocess returns the status of a version with status c once it is marked
+// for reprocessing. Statuses that are not reprocessable are returned
+// unchanged.
+func (c Code) Reprocess() Code {
+	if r, ok := reprocessCodes[c]; ok {
+		return r
+	}
+	return c
+}
+
+// CanTransition reports whether a version may move from status from to status
+// to. Processing may give any version a status that is not a reprocess
+// status, and a reprocessable status may move to its reprocess status.
+func CanTransition(from, to Code) bool {
+	if to.IsResult() {
+		return true
+	}
+	return to.IsReprocess() && from.Reprocess() == to && from != to
+}
+
+// ReprocessSQL returns a SQL expression for the reprocess status of the status
+// in column, for UPDATE statements that mark versions for reprocessing.
+func ReprocessSQL(column string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CASE %s", column)
+	for _, c := range Reprocessable() {
+		fmt.Fprintf(&b, " WHEN %d THEN %d", c, c.Reprocess())
+	}
+	fmt.Fprintf(&b, " ELSE %s END", column)
+	return b.String()
+}
+
+// InSQL returns a SQL condition that the status in column is one of codes.
+func InSQL(column string, codes ...Code) string {
+	var s []string
+	for _, c := range codes {
+		s = append(s, strconv.Itoa(int(c)))
+	}
+	return fmt.Sprintf("%s IN (%s)", column, strings.Join(s, ", "))
+}
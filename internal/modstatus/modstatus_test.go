@@ -0,0 +1,113 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modstatus
+
+import (
+	"testing"
+)
+
+func TestReprocess(t *testing.T) {
+	for _, test := range []struct {
+		in, want Code
+	}{
+		{OK, ReprocessOK},
+		{HasIncompletePackages, ReprocessHasIncompletePackages},
+		{BadModule, ReprocessBadModule},
+		{AlternativeModule, ReprocessAlternative},
+		{NotFound, NotFound},
+		{InternalError, InternalError},
+		{ReprocessOK, ReprocessOK},
+		{Unprocessed, Unprocessed},
+	} {
+		if got := test.in.Reprocess(); got != test.want {
+			t.Errorf("%s.Reprocess() = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	for _, test := range []struct {
+		code                                         Code
+		succeeded, hasModule, alternative, reprocess bool
+		failure, result                              bool
+	}{
+		{Unprocessed, false, false, false, false, false, false},
+		{OK, true, true, false, false, false, true},
+		{HasIncompletePackages, true, true, false, false, false, true},
+		{NotFound, false, false, false, false, false, true},
+		{BadModule, false, false, false, false, false, true},
+		{AlternativeModule, false, false, true, false, false, true},
+		{InternalError, false, false, false, false, true, true},
+		{ReprocessOK, false, true, false, true, false, false},
+		{ReprocessHasIncompletePackages, false, true, false, true, false, false},
+		{ReprocessBadModule, false, false, false, true, false, false},
+		{ReprocessAlternative, false, false, true, true, false, false},
+	} {
+		c := test.code
+		for _, p := range []struct {
+			name      string
+			got, want bool
+		}{
+			{"Succeeded", c.Succeeded(), test.succeeded},
+			{"HasModule", c.HasModule(), test.hasModule},
+			{"IsAlternative", c.IsAlternative(), test.alternative},
+			{"IsReprocess", c.IsReprocess(), test.reprocess},
+			{"IsFailure", c.IsFailure(), test.failure},
+			{"IsResult", c.IsResult(), test.result},
+		} {
+			if p.got != p.want {
+				t.Errorf("%s.%s() = %t, want %t", c, p.name, p.got, p.want)
+			}
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	for _, test := range []struct {
+		from, to Code
+		want     bool
+	}{
+		{Unprocessed, OK, true},
+		{OK, ReprocessOK, true},
+		{ReprocessOK, OK, true},
+		{ReprocessOK, NotFound, true},
+		{BadModule, ReprocessBadModule, true},
+		{OK, ReprocessBadModule, false},
+		{NotFound, ReprocessOK, false},
+		{ReprocessOK, ReprocessOK, false},
+		{OK, Unprocessed, false},
+	} {
+		if got := CanTransition(test.from, test.to); got != test.want {
+			t.Errorf("CanTransition(%s, %s) = %t, want %t", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, test := range []struct {
+		code Code
+		want string
+	}{
+		{BadModule, "490 (bad module)"},
+		{ReprocessOK, "520 (reprocess ok)"},
+		{Code(502), "502 (bad gateway)"},
+		{Code(599), "599"},
+	} {
+		if got := test.code.String(); got != test.want {
+			t.Errorf("Code(%d).String() = %q, want %q", int(test.code), got, test.want)
+		}
+	}
+}
+
+func TestSQL(t *testing.T) {
+	want := "CASE status WHEN 200 THEN 520 WHEN 290 THEN 521 WHEN 490 THEN 540 WHEN 491 THEN 541 ELSE status END"
+	if got := ReprocessSQL("status"); got != want {
+		t.Errorf("ReprocessSQL(%q) = %q, want %q", "status", got, want)
+	}
+	want = "status IN (520, 541)"
+	if got := InSQL("status", ReprocessOK, ReprocessAlternative); got != want {
+		t.Errorf("InSQL = %q, want %q", got, want)
+	}
+}
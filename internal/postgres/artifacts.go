@@ -0,0 +1,134 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// An Artifact is a kind of data that the worker derives from a module version,
+// and records along with the app version that computed it.
+type Artifact string
+
+const (
+	// ArtifactDocumentation is the documentation HTML of packages.
+	ArtifactDocumentation Artifact = "documentation"
+	// ArtifactLicenses is the detected license files.
+	ArtifactLicenses Artifact = "licenses"
+	// ArtifactReadme is the rendered README files.
+	ArtifactReadme Artifact = "readme"
+	// ArtifactSearchDocuments is the search documents of packages.
+	ArtifactSearchDocuments Artifact = "search_documents"
+)
+
+// staleCondition returns a condition that is true for the rows of the table
+// with the given alias that were computed before the app version, or by an
+// unknown one. It leaves a %[1]s verb for the parameter of the app version.
+func staleCondition(alias string) string {
+	return fmt.Sprintf("(%[1]s.app_version IS NULL OR %[1]s.app_version < %%[1]s)", alias)
+}
+
+// staleArtifactQueries are queries for the module_path and version of the
+// module versions with an artifact that is stale, by staleCondition. Each is a
+// format string whose single argument is the parameter of the app version.
+var staleArtifactQueries = map[Artifact]string{
+	ArtifactDocumentation: `
+		SELECT p.module_path, p.version
+		FROM packages p
+		WHERE ` + staleCondition("p") + `
+		UNION
+		SELECT m.module_path, m.version
+		FROM documentation d
+		INNER JOIN paths p ON p.id = d.path_id
+		INNER JOIN modules m ON m.id = p.module_id
+		WHERE ` + staleCondition("d"),
+	ArtifactLicenses: `
+		SELECT l.module_path, l.version
+		FROM licenses l
+		WHERE ` + staleCondition("l"),
+	// The README of a module is in the modules table, and those of its
+	// directories in readmes.
+	ArtifactReadme: `
+		SELECT m.module_path, m.version
+		FROM modules m
+		WHERE m.readme_file_path <> '' AND ` + staleCondition("m") + `
+		UNION
+		SELECT m.module_path, m.version
+		FROM readmes r
+		INNER JOIN paths p ON p.id = r.path_id
+		INNER JOIN modules m ON m.id = p.module_id
+		WHERE ` + staleCondition("r"),
+	ArtifactSearchDocuments: `
+		SELECT s.module_path, s.version
+		FROM search_documents s
+		WHERE ` + staleCondition("s"),
+}
+
+// Artifacts returns the kinds of artifacts, in sorted order.
+func Artifacts() []Artifact {
+	var as []Artifact
+	for a := range staleArtifactQueries {
+		as = append(as, a)
+	}
+	sort.Slice(as, func(i, j int) bool { return as[i] < as[j] })
+	return as
+}
+
+// staleArtifactQuery returns the query of staleArtifactQueries for a, with
+// param as the parameter of the app version.
+func staleArtifactQuery(a Artifact, param string) (string, error) {
+	q, ok := staleArtifactQueries[a]
+	if !ok {
+		return "", fmt.Errorf("unknown artifact %q: %w", a, derrors.InvalidArgument)
+	}
+	return fmt.Sprintf(q, param), nil
+}
+
+// GetStaleArtifactVersions returns the states of up to limit module versions,
+// ordered by module path and version, with an artifact a that was computed by
+// an app version before appVersion, or by an unknown one.
+func (db *DB) GetStaleArtifactVersions(ctx context.Context, a Artifact, appVersion string, limit int) (_ []*internal.ModuleVersionState, err error) {
+	defer derrors.Wrap(&err, "GetStaleArtifactVersions(ctx, %q, %q, %d)", a, appVersion, limit)
+
+	stale, err := staleArtifactQuery(a, "$1")
+	if err != nil {
+		return nil, err
+	}
+	queryFormat := `
+		SELECT %s
+		FROM module_version_states
+		WHERE (module_path, version) IN (` + stale + `)
+		ORDER BY module_path, version
+		LIMIT $2`
+	return db.queryModuleVersionStates(ctx, queryFormat, appVersion, limit)
+}
+
+// CountStaleArtifactVersions returns the number of module versions with an
+// artifact a that was computed by an app version before appVersion, or by an
+// unknown one.
+func (db *DB) CountStaleArtifactVersions(ctx context.Context, a Artifact, appVersion string) (n int, err error) {
+	defer derrors.Wrap(&err, "CountStaleArtifactVersions(ctx, %q, %q)", a, appVersion)
+
+	stale, err := staleArtifactQuery(a, "$1")
+	if err != nil {
+		return 0, err
+	}
+	err = db.db.QueryRow(ctx, `SELECT COUNT(*) FROM (`+stale+`) a`, appVersion).Scan(&n)
+	return n, err
+}
+
+// nullAppVersion returns the app version of m to record with the artifacts
+// derived from it, or nil if it is not known.
+func nullAppVersion(m *internal.Module) interface{} {
+	if m.AppVersion == "" {
+		return nil
+	}
+	return m.AppVersion
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestStaleArtifacts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	now := sample.NowTruncated()
+	for _, m := range []struct {
+		modulePath, appVersion string
+	}{
+		{"example.com/old", "2020-01-01t00"},
+		{"example.com/new", "2020-06-01t00"},
+		{"example.com/unknown", ""},
+	} {
+		mod := sample.Module(m.modulePath, sample.VersionString, "")
+		mod.AppVersion = m.appVersion
+		if err := testDB.InsertModule(ctx, mod); err != nil {
+			t.Fatal(err)
+		}
+		if err := upsertModuleVersionState(ctx, testDB.db, nil, &internal.ModuleVersionStateForUpsert{
+			ModulePath: m.modulePath,
+			Version:    sample.VersionString,
+			AppVersion: "2020-06-01t00",
+			Timestamp:  now,
+			Status:     http.StatusOK,
+			GoModPath:  m.modulePath,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const appVersion = "2020-03-01t00"
+	want := []string{"example.com/old", "example.com/unknown"}
+	for _, a := range Artifacts() {
+		states, err := testDB.GetStaleArtifactVersions(ctx, a, appVersion, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, s := range states {
+			got = append(got, s.ModulePath)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("GetStaleArtifactVersions(%q) mismatch (-want +got):\n%s", a, diff)
+		}
+		n, err := testDB.CountStaleArtifactVersions(ctx, a, appVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != len(want) {
+			t.Errorf("CountStaleArtifactVersions(%q) = %d, want %d", a, n, len(want))
+		}
+	}
+	if _, err := testDB.GetStaleArtifactVersions(ctx, "unknown", appVersion, 10); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("unknown artifact: got %v, want InvalidArgument", err)
+	}
+
+	// Every version was processed by the latest app version, so only a
+	// campaign driven by an artifact selects any.
+	c := &ReprocessingCampaign{
+		Name:       "redo-docs",
+		AppVersion: appVersion,
+		Artifact:   ArtifactDocumentation,
+		CreatedBy:  "someone",
+	}
+	if err := testDB.CreateReprocessingCampaign(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Total, 2; got != want {
+		t.Errorf("Total = %d, want %d", got, want)
+	}
+	got, err := testDB.GetReprocessingCampaign(ctx, c.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Artifact != ArtifactDocumentation {
+		t.Errorf("Artifact = %q, want %q", got.Artifact, ArtifactDocumentation)
+	}
+
+	// Reinserting with the latest app version makes the artifacts current.
+	mod := sample.Module("example.com/old", sample.VersionString, "")
+	mod.AppVersion = appVersion
+	if err := testDB.InsertModule(ctx, mod); err != nil {
+		t.Fatal(err)
+	}
+	states, err := testDB.GetStaleArtifactVersions(ctx, ArtifactLicenses, appVersion, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].ModulePath != "example.com/unknown" {
+		t.Errorf("after reinserting: got %v, want only example.com/unknown", states)
+	}
+}
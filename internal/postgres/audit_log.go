@@ -0,0 +1,178 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// AuditRequest is the action of the entries of the audit log that record a
+// request to an admin endpoint of the worker, rather than a change.
+const AuditRequest = "request"
+
+// An AuditEntry is an entry in the audit log. It records either an
+// administrative change, or a request to an admin endpoint of the worker
+// that changes state, which usually leads to one.
+type AuditEntry struct {
+	// Actor is who made the change or the request: the name of the
+	// authenticated user, or of the person or program that ran a command.
+	Actor string
+	// AuthMethod is how the actor of a request was authenticated: "iap",
+	// "token" or "insecure". It is empty for changes.
+	AuthMethod string
+	// Action names the kind of change, such as "experiment.set", or is
+	// AuditRequest.
+	Action string
+	// Target identifies what was changed, such as an experiment name or a
+	// module path and version, or is the URL path of a request. It can be
+	// empty.
+	Target string
+	// Before and After are the JSON encodings of the target before and after
+	// the change. They are nil if the target did not exist, and for
+	// requests.
+	Before, After json.RawMessage
+	// Params are the query and form parameters of a request, and Status is
+	// the HTTP status of its response. They are zero for changes.
+	Params    map[string][]string
+	Status    int
+	CreatedAt time.Time
+}
+
+// Audit adds an entry to the audit log of administrative changes. Before and
+// after are encoded as JSON; a nil value, or a nil pointer, records that the
+// target did not exist.
+func (db *DB) Audit(ctx context.Context, actor, action, target string, before, after interface{}) (err error) {
+	defer derrors.Wrap(&err, "DB.Audit(ctx, %q, %q, %q)", actor, action, target)
+
+	e := &AuditEntry{Actor: actor, Action: action, Target: target}
+	if e.Before, err = encodeAuditState(before); err != nil {
+		return err
+	}
+	if e.After, err = encodeAuditState(after); err != nil {
+		return err
+	}
+	return db.InsertAuditEntry(ctx, e)
+}
+
+// encodeAuditState returns the JSON encoding of v, or nil if v encodes as
+// null.
+func encodeAuditState(v interface{}) (json.RawMessage, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, []byte("null")) {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// InsertAuditEntry adds e to the audit log. e.CreatedAt is ignored.
+func (db *DB) InsertAuditEntry(ctx context.Context, e *AuditEntry) (err error) {
+	defer derrors.Wrap(&err, "DB.InsertAuditEntry(ctx, %q, %q)", e.Action, e.Target)
+
+	var params json.RawMessage
+	if e.Params != nil {
+		if params, err = json.Marshal(e.Params); err != nil {
+			return err
+		}
+	}
+	var status interface{}
+	if e.Status != 0 {
+		status = e.Status
+	}
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO audit_log (actor, auth_method, action, target, before, after, params, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		e.Actor, e.AuthMethod, e.Action, e.Target, nullJSON(e.Before), nullJSON(e.After), nullJSON(params), status)
+	return err
+}
+
+// nullJSON returns nil for an empty JSON value, so that it is stored as NULL.
+func nullJSON(b json.RawMessage) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return []byte(b)
+}
+
+// An AuditFilter selects entries of the audit log. Empty fields match every
+// entry.
+type AuditFilter struct {
+	Actor string
+	// Action matches entries whose action is Action, or begins with Action
+	// followed by a dot, so that "license-override" matches every change to
+	// license overrides.
+	Action string
+	// Target matches entries whose target is Target, or begins with Target
+	// followed by a slash or an at sign, so that a module path matches the
+	// changes to its packages and versions.
+	Target string
+}
+
+// GetAuditEntries returns up to limit entries from the audit log that match
+// f, most recent first.
+func (db *DB) GetAuditEntries(ctx context.Context, f AuditFilter, limit int) (_ []*AuditEntry, err error) {
+	defer derrors.Wrap(&err, "DB.GetAuditEntries(ctx, %+v, %d)", f, limit)
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+	if f.Actor != "" {
+		args = append(args, f.Actor)
+		conds = append(conds, fmt.Sprintf("actor = $%d", len(args)))
+	}
+	if f.Action != "" {
+		args = append(args, f.Action)
+		conds = append(conds, fmt.Sprintf(
+			"(action = $%[1]d OR left(action, length($%[1]d) + 1) = $%[1]d || '.')", len(args)))
+	}
+	if f.Target != "" {
+		args = append(args, f.Target)
+		conds = append(conds, fmt.Sprintf(
+			"(target = $%[1]d OR left(target, length($%[1]d) + 1) IN ($%[1]d || '/', $%[1]d || '@'))", len(args)))
+	}
+	query := `
+		SELECT actor, auth_method, action, target, before, after, params, COALESCE(status, 0), created_at
+		FROM audit_log`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	var es []*AuditEntry
+	collect := func(rows *sql.Rows) error {
+		var (
+			e                     AuditEntry
+			before, after, params []byte
+		)
+		if err := rows.Scan(&e.Actor, &e.AuthMethod, &e.Action, &e.Target, &before, &after, &params, &e.Status, &e.CreatedAt); err != nil {
+			return err
+		}
+		e.Before = before
+		e.After = after
+		if params != nil {
+			if err := json.Unmarshal(params, &e.Params); err != nil {
+				return err
+			}
+		}
+		es = append(es, &e)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, args...); err != nil {
+		return nil, err
+	}
+	return es, nil
+}
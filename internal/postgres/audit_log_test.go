@@ -0,0 +1,98 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	req := &AuditEntry{
+		Actor:      "script",
+		AuthMethod: "token",
+		Action:     AuditRequest,
+		Target:     "/experiments/set",
+		Params:     map[string][]string{"name": {"e"}, "rollout": {"50"}},
+		Status:     200,
+	}
+	if err := testDB.InsertAuditEntry(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	var noExperiment *internal.Experiment
+	exp := &internal.Experiment{Name: "e", Rollout: 50, Description: "d"}
+	for _, a := range []struct {
+		actor, action, target string
+		before, after         interface{}
+	}{
+		{"alice@example.com", "experiment.set", "e", noExperiment, exp},
+		{"bob@example.com", "removed-path.add", "example.com/m@v1.0.0", nil, map[string]string{"reason": "takedown"}},
+		{"alice@example.com", "removed-path.add", "example.com/m/pkg", nil, map[string]string{"reason": "takedown"}},
+		{"script", "removed-path.add", "example.com/mod", nil, map[string]string{"reason": "takedown"}},
+		{"alice@example.com", "experiment.delete", "e", exp, nil},
+	} {
+		if err := testDB.Audit(ctx, a.actor, a.action, a.target, a.before, a.after); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expJSON := json.RawMessage(`{"Name": "e", "Rollout": 50, "Description": "d"}`)
+	// Compare JSON by value, since jsonb does not preserve formatting.
+	decodeJSON := cmp.Transformer("decodeJSON", func(b json.RawMessage) interface{} {
+		var v interface{}
+		if err := json.Unmarshal(b, &v); err != nil {
+			return string(b)
+		}
+		return v
+	})
+	for _, test := range []struct {
+		name   string
+		filter AuditFilter
+		want   []*AuditEntry
+	}{
+		{"actor", AuditFilter{Actor: "alice@example.com"}, []*AuditEntry{
+			{Actor: "alice@example.com", Action: "experiment.delete", Target: "e", Before: expJSON},
+			{Actor: "alice@example.com", Action: "removed-path.add", Target: "example.com/m/pkg", After: json.RawMessage(`{"reason": "takedown"}`)},
+			{Actor: "alice@example.com", Action: "experiment.set", Target: "e", After: expJSON},
+		}},
+		{"target and action", AuditFilter{Target: "example.com/m", Action: "removed-path.add"}, []*AuditEntry{
+			{Actor: "alice@example.com", Action: "removed-path.add", Target: "example.com/m/pkg", After: json.RawMessage(`{"reason": "takedown"}`)},
+			{Actor: "bob@example.com", Action: "removed-path.add", Target: "example.com/m@v1.0.0", After: json.RawMessage(`{"reason": "takedown"}`)},
+		}},
+		{"action prefix", AuditFilter{Action: "experiment"}, []*AuditEntry{
+			{Actor: "alice@example.com", Action: "experiment.delete", Target: "e", Before: expJSON},
+			{Actor: "alice@example.com", Action: "experiment.set", Target: "e", After: expJSON},
+		}},
+		{"requests", AuditFilter{Action: AuditRequest}, []*AuditEntry{req}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testDB.GetAuditEntries(ctx, test.filter, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got, cmpopts.IgnoreFields(AuditEntry{}, "CreatedAt"), decodeJSON); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	got, err := testDB.GetAuditEntries(ctx, AuditFilter{}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Action != "experiment.delete" {
+		t.Errorf("GetAuditEntries(ctx, AuditFilter{}, 1) = %+v, want the most recent entry", got)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/blob"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// blobStore holds the objects of a DB that are kept in a blob bucket instead
+// of the database: large documentation, and module zips. A nil *blobStore
+// stores all documentation in the database.
+type blobStore struct {
+	bucket blob.Bucket
+	// threshold is the size in bytes above which documentation HTML is
+	// stored in the bucket.
+	threshold int
+}
+
+// SetBlobBucket makes db store documentation HTML larger than docThreshold
+// bytes in b, keeping only its key in the database; if docThreshold is zero,
+// all documentation stays in the database. Blobs in b that belong to a module,
+// like its zip, are deleted along with it. Documentation that was stored in b
+// can only be read by a DB that has b set. SetBlobBucket must be called before
+// db is used.
+func (db *DB) SetBlobBucket(b blob.Bucket, docThreshold int) {
+	db.blobs = &blobStore{bucket: b, threshold: docThreshold}
+}
+
+// documentationKey returns the key of the blob that holds the documentation
+// of the package at pkgPath in the module at modulePath and version, for
+// goos and goarch. The documentation is compressed with gzip, as the ".gz"
+// suffix of the key says; blobs written before documentation was compressed
+// have keys without it.
+func documentationKey(modulePath, version, pkgPath, goos, goarch string) string {
+	return fmt.Sprintf("documentation/%s/@v/%s/%s/%s_%s.html.gz", modulePath, version, pkgPath, goos, goarch)
+}
+
+// store returns the HTML to write to the database for the documentation of
+// the given package, and the value of its blob key column: nil if the HTML is
+// kept in the database, or the key of the blob where it was written instead.
+func (s *blobStore) store(ctx context.Context, modulePath, version, pkgPath, goos, goarch, html string) (string, interface{}, error) {
+	if s == nil || s.threshold <= 0 || len(html) <= s.threshold {
+		return html, nil, nil
+	}
+	key := documentationKey(modulePath, version, pkgPath, goos, goarch)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, html); err != nil {
+		return "", nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return "", nil, err
+	}
+	if err := s.bucket.Write(ctx, key, buf.Bytes()); err != nil {
+		return "", nil, err
+	}
+	return "", key, nil
+}
+
+// load replaces *html with the contents of the blob with the given key, if key
+// is not empty.
+func (s *blobStore) load(ctx context.Context, html *string, key string) error {
+	if key == "" {
+		return nil
+	}
+	if s == nil {
+		return fmt.Errorf("documentation is stored in blob %q, but there is no blob bucket", key)
+	}
+	data, err := s.bucket.Read(ctx, key)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(key, ".gz") {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("blob %q: %v", key, err)
+		}
+		if data, err = ioutil.ReadAll(zr); err != nil {
+			return fmt.Errorf("blob %q: %v", key, err)
+		}
+	}
+	*html = string(data)
+	return nil
+}
+
+// deleteBlobs deletes the blobs with the given keys, logging failures: the
+// rows that refer to them are already gone, so a blob left behind only wastes
+// space.
+func (db *DB) deleteBlobs(ctx context.Context, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	if db.blobs == nil {
+		log.Errorf(ctx, "not deleting %d blobs, because there is no blob bucket", len(keys))
+		return
+	}
+	for _, k := range keys {
+		if err := db.blobs.bucket.Delete(ctx, k); err != nil {
+			log.Error(ctx, err)
+		}
+	}
+}
+
+// SetModuleZipKey records that the zip of the module at modulePath and
+// version is stored in the blob with key, so that the blob is deleted along
+// with the module.
+func (db *DB) SetModuleZipKey(ctx context.Context, modulePath, version, key string) (err error) {
+	defer derrors.Wrap(&err, "DB.SetModuleZipKey(ctx, %q, %q, %q)", modulePath, version, key)
+
+	res, err := db.db.Exec(ctx, `
+		UPDATE modules SET zip_blob_key = $3
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, version, key)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("result.RowsAffected(): %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("module %s@%s: %w", modulePath, version, derrors.NotFound)
+	}
+	return nil
+}
+
+// blobKeys returns the keys of the blobs of the module with the given ID,
+// path and version.
+func (db *DB) blobKeys(ctx context.Context, moduleID int, modulePath, version string) ([]string, error) {
+	var keys []string
+	err := db.db.RunQuery(ctx, `
+		SELECT zip_blob_key FROM modules WHERE id = $1 AND zip_blob_key IS NOT NULL
+		UNION
+		SELECT d.html_blob_key
+		FROM documentation d
+		INNER JOIN paths p ON p.id = d.path_id
+		WHERE p.module_id = $1 AND d.html_blob_key IS NOT NULL
+		UNION
+		SELECT documentation_blob_key FROM packages
+		WHERE module_path = $2 AND version = $3 AND documentation_blob_key IS NOT NULL`,
+		func(rows *sql.Rows) error {
+			var k string
+			if err := rows.Scan(&k); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+			return nil
+		}, moduleID, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
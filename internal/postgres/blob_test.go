@@ -0,0 +1,144 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blob"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestBlobDocumentation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx,
+		experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true}))
+
+	defer ResetTestDB(testDB, t)
+
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bucket, err := blob.NewDisk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := New(testDB.db)
+	db.SetBlobBucket(bucket, 100)
+
+	const modulePath, version = "a.com/m", "v1.2.3"
+	m := sample.Module(modulePath, version, "small", "big")
+	bigHTML := "<p>" + strings.Repeat("x", 200) + "</p>"
+	for _, p := range m.LegacyPackages {
+		if strings.HasSuffix(p.Path, "/big") {
+			p.DocumentationHTML = bigHTML
+		}
+	}
+	for _, d := range m.Directories {
+		if strings.HasSuffix(d.Path, "/big") {
+			d.Package.Documentation.HTML = bigHTML
+		}
+	}
+	if err := db.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SetModuleZipKey(ctx, modulePath, version, "zips/a.com/m/@v/v1.2.3.zip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := bucket.Write(ctx, "zips/a.com/m/@v/v1.2.3.zip", []byte("zip")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the large documentation is moved out of the database.
+	var key sql.NullString
+	if err := testDB.db.QueryRow(ctx,
+		`SELECT documentation_blob_key FROM packages WHERE path = $1`, modulePath+"/big").Scan(&key); err != nil {
+		t.Fatal(err)
+	}
+	if !key.Valid {
+		t.Fatal("large documentation is stored in the database")
+	}
+	if err := testDB.db.QueryRow(ctx,
+		`SELECT documentation_blob_key FROM packages WHERE path = $1`, modulePath+"/small").Scan(&key); err != nil {
+		t.Fatal(err)
+	}
+	if key.Valid {
+		t.Errorf("small documentation is stored in blob %q", key.String)
+	}
+
+	// The blob is compressed.
+	data, err := bucket.Read(ctx, key.String)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(key.String, ".gz") || len(data) >= len(bigHTML) {
+		t.Errorf("blob %q has %d bytes, want compressed documentation", key.String, len(data))
+	}
+
+	// LegacyGetPackage leaves the large documentation out, for
+	// LegacyGetPackageDocumentation to read.
+	pkg, err := db.LegacyGetPackage(ctx, modulePath+"/big", modulePath, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.DocumentationHTML != internal.StringFieldMissing {
+		t.Errorf("LegacyGetPackage: got documentation %q, want %q", pkg.DocumentationHTML, internal.StringFieldMissing)
+	}
+	for _, p := range []string{"small", "big"} {
+		want := bigHTML
+		if p == "small" {
+			want = sample.DocumentationHTML
+		}
+		got, err := db.LegacyGetPackageDocumentation(ctx, modulePath+"/"+p, modulePath, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("LegacyGetPackageDocumentation(%q): got %q, want %q", p, got, want)
+		}
+	}
+	um, err := db.GetUnitMeta(ctx, modulePath+"/big", modulePath, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit, err := db.GetUnit(ctx, um, internal.AllFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unit.Package.Documentation.HTML; got != bigHTML {
+		t.Errorf("GetUnit: got documentation %q, want %q", got, bigHTML)
+	}
+
+	// A DB without the bucket cannot read the documentation.
+	if _, err := testDB.LegacyGetPackageDocumentation(ctx, modulePath+"/big", modulePath, version); err == nil {
+		t.Error("LegacyGetPackageDocumentation without a bucket succeeded, want error")
+	}
+
+	// Deleting the module deletes its blobs.
+	if err := db.DeleteModule(ctx, modulePath, version); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{
+		"zips/a.com/m/@v/v1.2.3.zip",
+		documentationKey(modulePath, version, modulePath+"/big", pkg.GOOS, pkg.GOARCH),
+	} {
+		if _, err := bucket.Read(ctx, k); !errors.Is(err, derrors.NotFound) {
+			t.Errorf("blob %q after DeleteModule: got %v, want NotFound", k, err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A BreakingChangeReport lists the exported symbols that were removed in a
+// module version since the previous release of its series, and how many
+// importers of the previous release use them.
+type BreakingChangeReport struct {
+	ModulePath string
+	Version    string
+	// PreviousModulePath and PreviousVersion are the release that the version
+	// is compared against. PreviousModulePath differs from ModulePath when the
+	// version is the first of a new major version. Both are empty if there is
+	// no previous release.
+	PreviousModulePath string
+	PreviousVersion    string
+	// Packages are the packages of the previous release that lost symbols,
+	// ordered by path.
+	Packages []*PackageBreakingChanges
+}
+
+// PackageBreakingChanges are the symbols removed from a package.
+type PackageBreakingChanges struct {
+	// Path is the import path of the package in the previous release.
+	Path string
+	// Removed reports whether the whole package was removed.
+	Removed        bool
+	RemovedSymbols []*RemovedSymbol
+	// NumImporters is the number of packages in other modules that import the
+	// package. NumAffectedImporters is the number of them that use at least
+	// one removed symbol, and NumUnknownImporters the number of them whose
+	// use of symbols is not known because they were processed before it was
+	// recorded.
+	NumImporters         int
+	NumAffectedImporters int
+	NumUnknownImporters  int
+}
+
+// A RemovedSymbol is an exported symbol that was removed from a package.
+type RemovedSymbol struct {
+	// Name is the name of the symbol, as "Type.Method" or "Type.Field" for
+	// methods and fields.
+	Name string
+	// NumAffectedImporters is the number of importers of the package that
+	// use the symbol. For methods and fields, those are the importers that use
+	// the type, since only the package-level symbols used by importers are
+	// known.
+	NumAffectedImporters int
+}
+
+// GetBreakingChangeReport compares the exported symbols of the packages of
+// modulePath@version with those of the previous release in its series, and
+// counts the importers that use the symbols that were removed. Packages whose
+// symbols are not known in either version are skipped.
+func (db *DB) GetBreakingChangeReport(ctx context.Context, modulePath, version string) (_ *BreakingChangeReport, err error) {
+	defer derrors.Wrap(&err, "GetBreakingChangeReport(ctx, %q, %q)", modulePath, version)
+
+	report := &BreakingChangeReport{ModulePath: modulePath, Version: version}
+	err = db.db.QueryRow(ctx, `
+		SELECT p.module_path, p.version
+		FROM modules m
+		LEFT JOIN LATERAL (
+			SELECT module_path, version
+			FROM modules
+			WHERE series_path = m.series_path
+			AND version_type = 'release'
+			AND sort_version < m.sort_version
+			ORDER BY sort_version DESC, module_path
+			LIMIT 1
+		) p ON true
+		WHERE m.module_path = $1 AND m.version = $2`,
+		modulePath, version).Scan(database.NullIsEmpty(&report.PreviousModulePath), database.NullIsEmpty(&report.PreviousVersion))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if report.PreviousVersion == "" {
+		return report, nil
+	}
+
+	oldSymbols, err := packageSymbols(ctx, db, report.PreviousModulePath, report.PreviousVersion)
+	if err != nil {
+		return nil, err
+	}
+	newSymbols, err := packageSymbols(ctx, db, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	for _, old := range oldSymbols {
+		if old.symbols == nil {
+			continue
+		}
+		pc := &PackageBreakingChanges{Path: old.path}
+		var removed []string
+		if cur, ok := newSymbols[old.v1Path]; !ok {
+			pc.Removed = true
+			removed = old.symbols
+		} else if cur.symbols == nil {
+			continue
+		} else {
+			removed = removedSymbols(old.symbols, cur.symbols)
+		}
+		if len(removed) == 0 {
+			continue
+		}
+		if err := countAffectedImporters(ctx, db, pc, removed, report.PreviousModulePath, modulePath); err != nil {
+			return nil, err
+		}
+		report.Packages = append(report.Packages, pc)
+	}
+	sort.Slice(report.Packages, func(i, j int) bool {
+		return report.Packages[i].Path < report.Packages[j].Path
+	})
+	return report, nil
+}
+
+type packageSymbolSet struct {
+	path, v1Path string
+	// symbols is nil if the symbols of the package are not known.
+	symbols []string
+}
+
+// packageSymbols returns the exported symbols of the packages of
+// modulePath@version, by v1 path.
+func packageSymbols(ctx context.Context, db *DB, modulePath, version string) (map[string]*packageSymbolSet, error) {
+	sets := map[string]*packageSymbolSet{}
+	collect := func(rows *sql.Rows) error {
+		var s packageSymbolSet
+		if err := rows.Scan(&s.path, &s.v1Path, pq.Array(&s.symbols)); err != nil {
+			return err
+		}
+		sets[s.v1Path] = &s
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, `
+		SELECT path, v1_path, exported_symbols
+		FROM packages
+		WHERE module_path = $1 AND version = $2`,
+		collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// countAffectedImporters sets the importer counts of pc from the importers of
+// pc.Path outside of the modules being compared, and the removed symbols of pc
+// from removed.
+func countAffectedImporters(ctx context.Context, db *DB, pc *PackageBreakingChanges, removed []string, oldModulePath, newModulePath string) error {
+	affected := make([]int, len(removed))
+	collect := func(rows *sql.Rows) error {
+		var symbols []string
+		if err := rows.Scan(pq.Array(&symbols)); err != nil {
+			return err
+		}
+		pc.NumImporters++
+		if symbols == nil {
+			pc.NumUnknownImporters++
+			return nil
+		}
+		used := map[string]bool{}
+		for _, s := range symbols {
+			used[s] = true
+		}
+		affects := false
+		for i, r := range removed {
+			if usesSymbol(used, r) {
+				affected[i]++
+				affects = true
+			}
+		}
+		if affects {
+			pc.NumAffectedImporters++
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, `
+		SELECT symbols
+		FROM imports_unique
+		WHERE to_path = $1
+		AND from_module_path <> $2
+		AND from_module_path <> $3`,
+		collect, pc.Path, oldModulePath, newModulePath); err != nil {
+		return err
+	}
+	for i, r := range removed {
+		pc.RemovedSymbols = append(pc.RemovedSymbols, &RemovedSymbol{Name: r, NumAffectedImporters: affected[i]})
+	}
+	return nil
+}
+
+// removedSymbols returns the symbols of old that are not in cur, in sorted
+// order.
+func removedSymbols(old, cur []string) []string {
+	inCur := map[string]bool{}
+	for _, s := range cur {
+		inCur[s] = true
+	}
+	var removed []string
+	for _, s := range old {
+		if !inCur[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// usesSymbol reports whether an importer that uses the package-level symbols
+// in used may use the symbol s. A method or field is used by the importers
+// that use its type.
+func usesSymbol(used map[string]bool, s string) bool {
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	return used[s]
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetBreakingChangeReport(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	insert := func(m *internal.Module) {
+		t.Helper()
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	withSymbols := func(modulePath, version string, symbols map[string][]string) *internal.Module {
+		var suffixes []string
+		for s := range symbols {
+			suffixes = append(suffixes, s)
+		}
+		m := sample.Module(modulePath, version, suffixes...)
+		for _, p := range m.LegacyPackages {
+			p.Symbols = symbols[strings.TrimPrefix(p.Path, modulePath+"/")]
+		}
+		return m
+	}
+	importer := func(modulePath, importPath string, symbols []string) *internal.Module {
+		m := sample.Module(modulePath, "v1.0.0", "p")
+		p := m.LegacyPackages[0]
+		p.Imports = []string{importPath}
+		if symbols != nil {
+			p.ImportedSymbols = map[string][]string{importPath: symbols}
+		}
+		return m
+	}
+
+	insert(withSymbols("a.com/m", "v1.0.0", map[string][]string{
+		"foo": {"A", "B", "T", "T.M"},
+		"bar": {"X"},
+	}))
+	insert(withSymbols("a.com/m", "v1.1.0", map[string][]string{
+		"foo": {"A", "T"},
+	}))
+	insert(withSymbols("a.com/m/v2", "v2.0.0", map[string][]string{
+		"foo": {"A"},
+	}))
+	insert(importer("c.com/i", "a.com/m/foo", []string{"T"}))
+	insert(importer("d.com/j", "a.com/m/foo", []string{"A"}))
+	insert(importer("e.com/k", "a.com/m/foo", nil))
+	insert(importer("f.com/l", "a.com/m/bar", []string{"X"}))
+
+	for _, test := range []struct {
+		modulePath, version string
+		want                *BreakingChangeReport
+	}{
+		{
+			"a.com/m", "v1.0.0",
+			&BreakingChangeReport{ModulePath: "a.com/m", Version: "v1.0.0"},
+		},
+		{
+			"a.com/m", "v1.1.0",
+			&BreakingChangeReport{
+				ModulePath:         "a.com/m",
+				Version:            "v1.1.0",
+				PreviousModulePath: "a.com/m",
+				PreviousVersion:    "v1.0.0",
+				Packages: []*PackageBreakingChanges{
+					{
+						Path:                 "a.com/m/bar",
+						Removed:              true,
+						RemovedSymbols:       []*RemovedSymbol{{Name: "X", NumAffectedImporters: 1}},
+						NumImporters:         1,
+						NumAffectedImporters: 1,
+					},
+					{
+						Path: "a.com/m/foo",
+						RemovedSymbols: []*RemovedSymbol{
+							{Name: "B", NumAffectedImporters: 0},
+							{Name: "T.M", NumAffectedImporters: 1},
+						},
+						NumImporters:         3,
+						NumAffectedImporters: 1,
+						NumUnknownImporters:  1,
+					},
+				},
+			},
+		},
+		{
+			"a.com/m/v2", "v2.0.0",
+			&BreakingChangeReport{
+				ModulePath:         "a.com/m/v2",
+				Version:            "v2.0.0",
+				PreviousModulePath: "a.com/m",
+				PreviousVersion:    "v1.1.0",
+				Packages: []*PackageBreakingChanges{
+					{
+						Path:                 "a.com/m/foo",
+						RemovedSymbols:       []*RemovedSymbol{{Name: "T", NumAffectedImporters: 1}},
+						NumImporters:         3,
+						NumAffectedImporters: 1,
+						NumUnknownImporters:  1,
+					},
+				},
+			},
+		},
+	} {
+		got, err := testDB.GetBreakingChangeReport(ctx, test.modulePath, test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s@%s: mismatch (-want +got):\n%s", test.modulePath, test.version, diff)
+		}
+	}
+
+	if _, err := testDB.GetBreakingChangeReport(ctx, "a.com/m", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got error %v, want NotFound", err)
+	}
+}
+
+func TestRemovedSymbols(t *testing.T) {
+	got := removedSymbols([]string{"T", "B", "T.M", "A"}, []string{"A", "T", "C"})
+	if diff := cmp.Diff([]string{"B", "T.M"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestUsesSymbol(t *testing.T) {
+	used := map[string]bool{"T": true}
+	for _, test := range []struct {
+		symbol string
+		want   bool
+	}{
+		{"T", true},
+		{"T.M", true},
+		{"U", false},
+		{"U.M", false},
+	} {
+		if got := usesSymbol(used, test.symbol); got != test.want {
+			t.Errorf("usesSymbol(%q) = %t, want %t", test.symbol, got, test.want)
+		}
+	}
+}
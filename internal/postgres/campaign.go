@@ -0,0 +1,327 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/modstatus"
+)
+
+// States of a reprocessing campaign.
+const (
+	CampaignActive    = "active"
+	CampaignPaused    = "paused"
+	CampaignCancelled = "cancelled"
+	CampaignDone      = "done"
+)
+
+// reprocessableStatuses returns the statuses that have a corresponding
+// reprocess status. They are the statuses a campaign selects by default.
+func reprocessableStatuses() []int {
+	var statuses []int
+	for _, s := range modstatus.Reprocessable() {
+		statuses = append(statuses, int(s))
+	}
+	return statuses
+}
+
+// A ReprocessingCampaign is a named cohort of module versions that are marked
+// for reprocessing in batches.
+type ReprocessingCampaign struct {
+	Name string
+	// The cohort consists of the module versions that were processed by an
+	// app_version before AppVersion, whose status is one of Statuses, and
+	// whose module path begins with ModulePathPrefix.
+	//
+	// If Artifact is set, the module versions whose Artifact was computed by
+	// an app_version before AppVersion are selected instead of those that
+	// were processed before it.
+	AppVersion       string
+	Statuses         []int
+	ModulePathPrefix string
+	Artifact         Artifact
+
+	State     string
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// Progress of the campaign.
+	Total     int // number of module versions in the cohort
+	Queued    int // number marked for reprocessing
+	Completed int // number reprocessed since they were marked
+}
+
+// CreateReprocessingCampaign creates the campaign c, and records the module
+// versions that currently make up its cohort. Nothing is marked for
+// reprocessing until the campaign is advanced.
+//
+// If c.Statuses is empty, every status that has a corresponding reprocess
+// status is selected. On success, c.Total is set to the size of the cohort.
+func (db *DB) CreateReprocessingCampaign(ctx context.Context, c *ReprocessingCampaign) (err error) {
+	defer derrors.Wrap(&err, "DB.CreateReprocessingCampaign(ctx, %q)", c.Name)
+
+	if c.Name == "" || c.AppVersion == "" || c.CreatedBy == "" {
+		return fmt.Errorf("name, app version and creator must be non-empty: %w", derrors.InvalidArgument)
+	}
+	if len(c.Statuses) == 0 {
+		c.Statuses = reprocessableStatuses()
+	}
+	cohort := "app_version < $2"
+	if c.Artifact != "" {
+		stale, err := staleArtifactQuery(c.Artifact, "$2")
+		if err != nil {
+			return err
+		}
+		cohort = "(module_path, version) IN (" + stale + ")"
+	}
+	statuses := pq.Array(c.Statuses)
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO reprocessing_campaigns
+				(name, app_version, statuses, module_path_prefix, artifact, state, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			c.Name, c.AppVersion, statuses, c.ModulePathPrefix, c.Artifact, CampaignActive, c.CreatedBy); err != nil {
+			return err
+		}
+		res, err := tx.Exec(ctx, `
+			INSERT INTO reprocessing_campaign_versions
+				(campaign_name, module_path, version, initial_status)
+			SELECT $1, module_path, version, status
+			FROM module_version_states
+			WHERE
+				`+cohort+`
+				AND status = ANY($3)
+				AND left(module_path, length($4)) = $4`,
+			c.Name, c.AppVersion, statuses, c.ModulePathPrefix)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("result.RowsAffected(): %v", err)
+		}
+		c.State = CampaignActive
+		c.Total = int(n)
+		return nil
+	})
+}
+
+const reprocessingCampaignQuery = `
+	SELECT
+		c.name,
+		c.app_version,
+		c.statuses,
+		c.module_path_prefix,
+		c.artifact,
+		c.state,
+		c.created_by,
+		c.created_at,
+		c.updated_at,
+		COUNT(v.version),
+		COUNT(v.queued_at),
+		COUNT(*) FILTER (WHERE s.last_processed_at >= v.queued_at)
+	FROM reprocessing_campaigns c
+	LEFT JOIN reprocessing_campaign_versions v
+		ON v.campaign_name = c.name
+	LEFT JOIN module_version_states s
+		ON s.module_path = v.module_path
+		AND s.version = v.version
+	%s
+	GROUP BY c.name
+	ORDER BY c.created_at DESC`
+
+func scanReprocessingCampaign(scan func(dest ...interface{}) error) (*ReprocessingCampaign, error) {
+	var (
+		c        ReprocessingCampaign
+		statuses pq.Int64Array
+	)
+	if err := scan(&c.Name, &c.AppVersion, &statuses, &c.ModulePathPrefix, &c.Artifact, &c.State,
+		&c.CreatedBy, &c.CreatedAt, &c.UpdatedAt, &c.Total, &c.Queued, &c.Completed); err != nil {
+		return nil, err
+	}
+	for _, s := range statuses {
+		c.Statuses = append(c.Statuses, int(s))
+	}
+	return &c, nil
+}
+
+// GetReprocessingCampaigns returns all reprocessing campaigns along with their
+// progress, most recently created first.
+func (db *DB) GetReprocessingCampaigns(ctx context.Context) (_ []*ReprocessingCampaign, err error) {
+	defer derrors.Wrap(&err, "DB.GetReprocessingCampaigns(ctx)")
+
+	var campaigns []*ReprocessingCampaign
+	query := fmt.Sprintf(reprocessingCampaignQuery, "")
+	err = db.db.RunQuery(ctx, query, func(rows *sql.Rows) error {
+		c, err := scanReprocessingCampaign(rows.Scan)
+		if err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		campaigns = append(campaigns, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// GetReprocessingCampaign returns the campaign with the given name, along with
+// its progress.
+func (db *DB) GetReprocessingCampaign(ctx context.Context, name string) (_ *ReprocessingCampaign, err error) {
+	defer derrors.Wrap(&err, "DB.GetReprocessingCampaign(ctx, %q)", name)
+
+	query := fmt.Sprintf(reprocessingCampaignQuery, "WHERE c.name = $1")
+	c, err := scanReprocessingCampaign(db.db.QueryRow(ctx, query, name).Scan)
+	switch err {
+	case nil:
+		return c, nil
+	case sql.ErrNoRows:
+		return nil, derrors.NotFound
+	default:
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+}
+
+// campaignTransitions maps each campaign state to the states it can be
+// changed from.
+var campaignTransitions = map[string][]string{
+	CampaignActive:    {CampaignPaused},
+	CampaignPaused:    {CampaignActive},
+	CampaignCancelled: {CampaignActive, CampaignPaused},
+}
+
+// SetReprocessingCampaignState changes the state of the named campaign. Only
+// the following changes are permitted: pausing an active campaign, resuming
+// (activating) a paused campaign, and cancelling an active or paused campaign.
+// Cancelling a campaign does not undo the marking of module versions that have
+// already been queued.
+func (db *DB) SetReprocessingCampaignState(ctx context.Context, name, state string) (err error) {
+	defer derrors.Wrap(&err, "DB.SetReprocessingCampaignState(ctx, %q, %q)", name, state)
+
+	from, ok := campaignTransitions[state]
+	if !ok {
+		return fmt.Errorf("cannot change campaign state to %q: %w", state, derrors.InvalidArgument)
+	}
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		var current string
+		err := tx.QueryRow(ctx, `SELECT state FROM reprocessing_campaigns WHERE name = $1 FOR UPDATE`, name).Scan(&current)
+		switch err {
+		case nil:
+		case sql.ErrNoRows:
+			return derrors.NotFound
+		default:
+			return err
+		}
+		allowed := false
+		for _, f := range from {
+			if f == current {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("campaign is %s, cannot change it to %s: %w", current, state, derrors.InvalidArgument)
+		}
+		_, err = tx.Exec(ctx, `UPDATE reprocessing_campaigns SET state = $2 WHERE name = $1`, name, state)
+		return err
+	})
+}
+
+// AdvanceReprocessingCampaigns marks the next batch of up to limit module
+// versions in each active campaign for reprocessing, so that they will be
+// picked up by the next request to /requeue. A campaign whose versions have all
+// been marked is changed to the done state.
+//
+// It returns the total number of module versions that were marked.
+func (db *DB) AdvanceReprocessingCampaigns(ctx context.Context, limit int) (_ int, err error) {
+	defer derrors.Wrap(&err, "DB.AdvanceReprocessingCampaigns(ctx, %d)", limit)
+
+	var names []string
+	err = db.db.RunQuery(ctx, `SELECT name FROM reprocessing_campaigns WHERE state = $1 ORDER BY created_at`,
+		func(rows *sql.Rows) error {
+			var n string
+			if err := rows.Scan(&n); err != nil {
+				return err
+			}
+			names = append(names, n)
+			return nil
+		}, CampaignActive)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, name := range names {
+		n, err := db.advanceReprocessingCampaign(ctx, name, limit)
+		if err != nil {
+			return total, err
+		}
+		log.Infof(ctx, "AdvanceReprocessingCampaigns: marked %d module versions of campaign %q for reprocessing", n, name)
+		total += n
+	}
+	return total, nil
+}
+
+func (db *DB) advanceReprocessingCampaign(ctx context.Context, name string, limit int) (n int, err error) {
+	defer derrors.Wrap(&err, "advanceReprocessingCampaign(ctx, %q, %d)", name, limit)
+
+	// Map each status to its reprocess status. Statuses without one are left
+	// alone, but are still scheduled to be processed right away.
+	query := fmt.Sprintf(`
+		WITH batch AS (
+			UPDATE reprocessing_campaign_versions
+			SET queued_at = CURRENT_TIMESTAMP
+			WHERE (campaign_name, module_path, version) IN (
+				SELECT campaign_name, module_path, version
+				FROM reprocessing_campaign_versions
+				WHERE campaign_name = $1 AND queued_at IS NULL
+				ORDER BY module_path, version
+				LIMIT $2
+			)
+			RETURNING module_path, version
+		)
+		UPDATE module_version_states s
+		SET
+			status = %s,
+			next_processed_after = CURRENT_TIMESTAMP,
+			last_processed_at = NULL
+		FROM batch
+		WHERE s.module_path = batch.module_path AND s.version = batch.version`,
+		modstatus.ReprocessSQL("s.status"))
+
+	err = db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		res, err := tx.Exec(ctx, query, name, limit)
+		if err != nil {
+			return err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("result.RowsAffected(): %v", err)
+		}
+		n = int(affected)
+		_, err = tx.Exec(ctx, `
+			UPDATE reprocessing_campaigns
+			SET state = $2
+			WHERE name = $1 AND NOT EXISTS (
+				SELECT 1
+				FROM reprocessing_campaign_versions
+				WHERE campaign_name = $1 AND queued_at IS NULL
+			)`, name, CampaignDone)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
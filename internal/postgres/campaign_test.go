@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/modstatus"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestReprocessingCampaign(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	now := sample.NowTruncated()
+	for _, m := range []struct {
+		modulePath, appVersion string
+		status                 int
+	}{
+		{"example.com/a", "2020-01-01t00", http.StatusOK},
+		{"example.com/b", "2020-01-01t00", derrors.ToHTTPStatus(derrors.BadModule)},
+		{"example.com/c", "2020-01-01t00", http.StatusNotFound},
+		{"example.com/d", "2020-06-01t00", http.StatusOK},
+		{"other.com/e", "2020-01-01t00", http.StatusOK},
+	} {
+		if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: m.modulePath, Version: sample.VersionString, Timestamp: now}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := upsertModuleVersionState(ctx, testDB.db, nil, &internal.ModuleVersionStateForUpsert{
+			ModulePath: m.modulePath,
+			Version:    sample.VersionString,
+			AppVersion: m.appVersion,
+			Timestamp:  now,
+			Status:     m.status,
+			GoModPath:  m.modulePath,
+			FetchErr:   derrors.FromHTTPStatus(m.status, "test"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &ReprocessingCampaign{
+		Name:             "redo-docs",
+		AppVersion:       "2020-03-01t00",
+		ModulePathPrefix: "example.com/",
+		CreatedBy:        "someone",
+	}
+	if err := testDB.CreateReprocessingCampaign(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	// a and b, but not c (404), d (newer app_version) or e (prefix).
+	if got, want := c.Total, 2; got != want {
+		t.Errorf("Total = %d, want %d", got, want)
+	}
+
+	checkProgress := func(wantState string, wantQueued int) {
+		t.Helper()
+		got, err := testDB.GetReprocessingCampaign(ctx, c.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.State != wantState || got.Total != 2 || got.Queued != wantQueued || got.Completed != 0 {
+			t.Errorf("got state=%s total=%d queued=%d completed=%d, want state=%s total=2 queued=%d completed=0",
+				got.State, got.Total, got.Queued, got.Completed, wantState, wantQueued)
+		}
+	}
+	checkProgress(CampaignActive, 0)
+
+	if err := testDB.SetReprocessingCampaignState(ctx, c.Name, CampaignPaused); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testDB.AdvanceReprocessingCampaigns(ctx, 1); err != nil || n != 0 {
+		t.Fatalf("AdvanceReprocessingCampaigns on paused campaign = %d, %v; want 0, nil", n, err)
+	}
+	if err := testDB.SetReprocessingCampaignState(ctx, c.Name, CampaignActive); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testDB.AdvanceReprocessingCampaigns(ctx, 1); err != nil || n != 1 {
+		t.Fatalf("AdvanceReprocessingCampaigns = %d, %v; want 1, nil", n, err)
+	}
+	checkProgress(CampaignActive, 1)
+	mvs, err := testDB.GetModuleVersionState(ctx, "example.com/a", sample.VersionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mvs.Status, int(modstatus.ReprocessOK); got != want {
+		t.Errorf("status of example.com/a = %d, want %d", got, want)
+	}
+
+	if n, err := testDB.AdvanceReprocessingCampaigns(ctx, 10); err != nil || n != 1 {
+		t.Fatalf("AdvanceReprocessingCampaigns = %d, %v; want 1, nil", n, err)
+	}
+	checkProgress(CampaignDone, 2)
+
+	if err := testDB.SetReprocessingCampaignState(ctx, c.Name, CampaignCancelled); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("cancelling a done campaign: got %v, want InvalidArgument", err)
+	}
+	if err := testDB.SetReprocessingCampaignState(ctx, "unknown", CampaignPaused); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("pausing an unknown campaign: got %v, want NotFound", err)
+	}
+}
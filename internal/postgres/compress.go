@@ -0,0 +1,98 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+)
+
+// compressedColumns are the bytea columns of UTF-8 text that may be
+// compressed with database.Compress. They must be written with compressText
+// and read with database.Compressed.
+var compressedColumns = []struct{ table, column string }{
+	{"documentation", "html"},
+	{"licenses", "contents"},
+	{"modules", "readme_contents"},
+	{"packages", "documentation"},
+	{"readmes", "contents"},
+}
+
+// compressText returns the value to write to one of the compressedColumns for
+// s. It replaces invalid Unicode in s, and compresses it if the
+// internal.ExperimentCompressText experiment is active.
+func compressText(ctx context.Context, s string) []byte {
+	s = makeValidUnicode(s)
+	if !experiment.IsActive(ctx, internal.ExperimentCompressText) {
+		// Valid UTF-8 never begins with database.CompressedPrefix, so it is
+		// read back unchanged.
+		return []byte(s)
+	}
+	return database.Compress(s)
+}
+
+// CompressText compresses up to limit values in each of the columns of text
+// that can be compressed and are not yet, and returns the number of values it
+// compressed. It is meant to be called repeatedly until it returns zero, to
+// compress the rows that were written before the
+// internal.ExperimentCompressText experiment was active.
+func (db *DB) CompressText(ctx context.Context, limit int) (n int, err error) {
+	defer derrors.Wrap(&err, "DB.CompressText(ctx, %d)", limit)
+
+	for _, c := range compressedColumns {
+		m, err := compressColumn(ctx, db.db, c.table, c.column, limit)
+		if err != nil {
+			return n, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+// compressColumn compresses up to limit values of column in table that are
+// long enough to compress and are not yet compressed.
+func compressColumn(ctx context.Context, db *database.DB, table, column string, limit int) (n int, err error) {
+	defer derrors.Wrap(&err, "compressColumn(ctx, %q, %q, %d)", table, column, limit)
+
+	err = db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		type row struct{ ctid, text string }
+		var rows []row
+		query := fmt.Sprintf(`
+			SELECT ctid::text, %[2]s
+			FROM %[1]s
+			WHERE length(%[2]s) >= $1 AND substring(%[2]s FROM 1 FOR $2) <> $3
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED`, table, column)
+		err := tx.RunQuery(ctx, query, func(rs *sql.Rows) error {
+			var r row
+			if err := rs.Scan(&r.ctid, &r.text); err != nil {
+				return err
+			}
+			rows = append(rows, r)
+			return nil
+		}, database.MinCompressSize, len(database.CompressedPrefix), []byte(database.CompressedPrefix), limit)
+		if err != nil {
+			return err
+		}
+		update := fmt.Sprintf(`UPDATE %s SET %s = $2 WHERE ctid = $1::tid`, table, column)
+		for _, r := range rows {
+			if _, err := tx.Exec(ctx, update, r.ctid, database.Compress(r.text)); err != nil {
+				return err
+			}
+		}
+		n = len(rows)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestCompressText(t *testing.T) {
+	const modulePath, version = "a.com/m", "v1.2.3"
+	long := strings.Repeat("<p>Lorem ipsum dolor sit amet.</p>\n", 100)
+
+	for _, compressOnInsert := range []bool{true, false} {
+		t.Run(fmt.Sprintf("compress-on-insert=%t", compressOnInsert), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+			defer cancel()
+			ctx = experiment.NewContext(ctx,
+				experiment.NewSet(map[string]bool{
+					internal.ExperimentInsertDirectories: true,
+					internal.ExperimentCompressText:      compressOnInsert,
+				}))
+
+			defer ResetTestDB(testDB, t)
+
+			m := sample.Module(modulePath, version, "p")
+			m.LegacyReadmeContents = long
+			m.Licenses = []*licenses.License{{Metadata: sample.LicenseMetadata[0], Contents: []byte(long)}}
+			for _, p := range m.LegacyPackages {
+				p.DocumentationHTML = long
+			}
+			for _, d := range m.Directories {
+				if d.Readme != nil {
+					d.Readme.Contents = long
+				}
+				if d.Package != nil {
+					d.Package.Documentation.HTML = long
+				}
+			}
+			if err := testDB.InsertModule(ctx, m); err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := testDB.CompressText(ctx, 100)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := len(compressedColumns)
+			if compressOnInsert {
+				want = 0
+			}
+			if n != want {
+				t.Errorf("CompressText: got %d, want %d", n, want)
+			}
+			if n, err := testDB.CompressText(ctx, 100); err != nil || n != 0 {
+				t.Errorf("CompressText again: got (%d, %v), want (0, nil)", n, err)
+			}
+			for _, c := range compressedColumns {
+				var s string
+				query := fmt.Sprintf(`SELECT %s FROM %s LIMIT 1`, c.column, c.table)
+				if err := testDB.db.QueryRow(ctx, query).Scan(&s); err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(s, database.CompressedPrefix) || len(s) >= len(long) {
+					t.Errorf("%s.%s is not compressed: %.20q", c.table, c.column, s)
+				}
+			}
+
+			pkg, err := testDB.LegacyGetPackage(ctx, modulePath+"/p", modulePath, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pkg.DocumentationHTML != long || pkg.LegacyReadmeContents != long {
+				t.Error("LegacyGetPackage: documentation or README not decompressed")
+			}
+			lics, err := testDB.LegacyGetModuleLicenses(ctx, modulePath, version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lics) != 1 || string(lics[0].Contents) != long {
+				t.Error("LegacyGetModuleLicenses: contents not decompressed")
+			}
+			for _, path := range []string{modulePath, modulePath + "/p"} {
+				um, err := testDB.GetUnitMeta(ctx, path, modulePath, version)
+				if err != nil {
+					t.Fatal(err)
+				}
+				u, err := testDB.GetUnit(ctx, um, internal.AllFields)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if u.Readme != nil && u.Readme.Contents != long {
+					t.Errorf("GetUnit(%q): README not decompressed", path)
+				}
+				if u.Package != nil && u.Package.Documentation.HTML != long {
+					t.Errorf("GetUnit(%q): documentation not decompressed", path)
+				}
+			}
+		})
+	}
+}
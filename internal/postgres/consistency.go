@@ -0,0 +1,170 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/modstatus"
+)
+
+// An InconsistencyKind is a way in which the rows derived from a module
+// version disagree with the rows they were derived from.
+type InconsistencyKind string
+
+const (
+	// MissingSearchDocument is a package in the latest version of its module
+	// that is not internal, and whose module has no later alternative
+	// version, but that has no search document.
+	MissingSearchDocument InconsistencyKind = "missing-search-document"
+	// OrphanedSearchDocument is a search document whose package version is
+	// not in the packages table, as happens when a module version is deleted
+	// after search_documents was rebuilt, which drops its foreign key.
+	OrphanedSearchDocument InconsistencyKind = "orphaned-search-document"
+	// MissingPaths is a module version that was processed successfully but
+	// has no rows in the paths table, because it was processed while the
+	// insert-directories experiment was off or its insertion was cut short.
+	MissingPaths InconsistencyKind = "missing-paths"
+)
+
+// An Inconsistency is a row that is missing or should not exist.
+type Inconsistency struct {
+	Kind       InconsistencyKind
+	ModulePath string
+	Version    string
+	// Path is the package path, for the kinds that concern a package.
+	Path string
+}
+
+func (inc *Inconsistency) String() string {
+	if inc.Path != "" {
+		return fmt.Sprintf("%s: %s in %s@%s", inc.Kind, inc.Path, inc.ModulePath, inc.Version)
+	}
+	return fmt.Sprintf("%s: %s@%s", inc.Kind, inc.ModulePath, inc.Version)
+}
+
+// consistencyChecks are the queries that find each kind of inconsistency.
+// Each selects the module_path, version and path columns of an Inconsistency,
+// where path may be empty, and has a limit as its only parameter.
+var consistencyChecks = []struct {
+	kind  InconsistencyKind
+	query string
+}{
+	{MissingSearchDocument, fmt.Sprintf(`
+		SELECT DISTINCT ON (p.path) p.module_path, p.version, p.path
+		FROM packages p
+		INNER JOIN modules m ON m.module_path = p.module_path AND m.version = p.version
+		WHERE NOT EXISTS (SELECT 1 FROM search_documents sd WHERE sd.package_path = p.path)
+		AND p.path !~ '(^|/)internal(/|$)'
+		AND m.version = (
+			SELECT l.version FROM modules l
+			WHERE l.module_path = m.module_path
+			ORDER BY l.version_type = 'release' DESC, l.sort_version DESC
+			LIMIT 1
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM module_version_states s
+			WHERE s.module_path = m.module_path AND s.sort_version > m.sort_version AND s.status = %d
+		)
+		ORDER BY p.path, p.module_path
+		LIMIT $1`, modstatus.AlternativeModule)},
+	{OrphanedSearchDocument, `
+		SELECT sd.module_path, sd.version, sd.package_path AS path
+		FROM search_documents sd
+		WHERE NOT EXISTS (
+			SELECT 1 FROM packages p
+			WHERE p.path = sd.package_path AND p.module_path = sd.module_path AND p.version = sd.version
+		)
+		ORDER BY sd.package_path
+		LIMIT $1`},
+	{MissingPaths, fmt.Sprintf(`
+		SELECT m.module_path, m.version, '' AS path
+		FROM modules m
+		INNER JOIN module_version_states s ON s.module_path = m.module_path AND s.version = m.version
+		WHERE %s
+		AND NOT EXISTS (SELECT 1 FROM paths p WHERE p.module_id = m.id)
+		ORDER BY m.module_path, m.sort_version DESC
+		LIMIT $1`, modstatus.InSQL("s.status", modstatus.OK, modstatus.HasIncompletePackages))},
+}
+
+// CheckConsistency returns up to limit inconsistencies of each kind.
+func (db *DB) CheckConsistency(ctx context.Context, limit int) (_ []*Inconsistency, err error) {
+	defer derrors.Wrap(&err, "CheckConsistency(ctx, %d)", limit)
+
+	var incs []*Inconsistency
+	for _, c := range consistencyChecks {
+		var found []*Inconsistency
+		if err := db.db.CollectStructs(ctx, &found, c.query, limit); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.kind, err)
+		}
+		for _, inc := range found {
+			inc.Kind = c.kind
+		}
+		incs = append(incs, found...)
+	}
+	return incs, nil
+}
+
+// RepairInconsistency repairs inc, which was returned by CheckConsistency.
+// Missing search documents are inserted, and orphaned ones are replaced by
+// the search document of the latest remaining version of the package, if
+// any. Module versions with missing paths are marked for reprocessing, so
+// they will be repaired once they are processed again.
+func (db *DB) RepairInconsistency(ctx context.Context, inc *Inconsistency) (err error) {
+	defer derrors.Wrap(&err, "RepairInconsistency(ctx, %s)", inc)
+
+	switch inc.Kind {
+	case MissingSearchDocument, OrphanedSearchDocument:
+		return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+			a, err := latestSearchDocumentArgs(ctx, tx, inc.Path)
+			switch err {
+			case nil:
+				// Upserting keeps the imported-by count of an orphaned
+				// search document.
+				return UpsertSearchDocument(ctx, tx, a)
+			case sql.ErrNoRows:
+				_, err := tx.Exec(ctx, `DELETE FROM search_documents WHERE package_path = $1`, inc.Path)
+				return err
+			default:
+				return err
+			}
+		})
+	case MissingPaths:
+		_, err := db.db.Exec(ctx, `
+			UPDATE module_version_states
+			SET
+				status = `+modstatus.ReprocessSQL("status")+`,
+				next_processed_after = CURRENT_TIMESTAMP,
+				last_processed_at = NULL
+			WHERE module_path = $1 AND version = $2`,
+			inc.ModulePath, inc.Version)
+		return err
+	default:
+		return fmt.Errorf("unknown kind %q: %w", inc.Kind, derrors.InvalidArgument)
+	}
+}
+
+// latestSearchDocumentArgs returns the arguments for upserting the search
+// document of pkgPath from the version of the package that the upsert
+// statement chooses. It returns sql.ErrNoRows if there is no such package.
+func latestSearchDocumentArgs(ctx context.Context, db *database.DB, pkgPath string) (upsertSearchDocumentArgs, error) {
+	a := upsertSearchDocumentArgs{PackagePath: pkgPath}
+	err := db.QueryRow(ctx, `
+		SELECT p.module_path, p.synopsis, m.readme_file_path, m.readme_contents
+		FROM packages p
+		INNER JOIN modules m ON m.module_path = p.module_path AND m.version = p.version
+		WHERE p.path = $1
+		ORDER BY
+			m.version_type = 'release' DESC,
+			m.sort_version DESC,
+			m.module_path DESC
+		LIMIT 1`, pkgPath).Scan(&a.ModulePath, database.NullIsEmpty(&a.Synopsis),
+		database.NullIsEmpty(&a.ReadmeFilePath), database.Compressed(&a.ReadmeContents))
+	return a, err
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/modstatus"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestConsistency(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	const modulePath = "example.com/consistent"
+	m := sample.Module(modulePath, sample.VersionString, "a", "internal/b")
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := upsertModuleVersionState(ctx, testDB.db, nil, &internal.ModuleVersionStateForUpsert{
+		ModulePath: modulePath,
+		Version:    sample.VersionString,
+		Timestamp:  sample.NowTruncated(),
+		Status:     http.StatusOK,
+		GoModPath:  modulePath,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(want []*Inconsistency) {
+		t.Helper()
+		got, err := testDB.CheckConsistency(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("CheckConsistency mismatch (-want +got):\n%s", diff)
+		}
+	}
+	check(nil)
+
+	// Remove the search document of the package and the paths of the module.
+	pkgPath := modulePath + "/a"
+	if _, err := testDB.db.Exec(ctx, `DELETE FROM search_documents WHERE package_path = $1`, pkgPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.db.Exec(ctx, `
+		DELETE FROM paths WHERE module_id = (SELECT id FROM modules WHERE module_path = $1 AND version = $2)`,
+		modulePath, sample.VersionString); err != nil {
+		t.Fatal(err)
+	}
+	want := []*Inconsistency{
+		{Kind: MissingSearchDocument, ModulePath: modulePath, Version: sample.VersionString, Path: pkgPath},
+		{Kind: MissingPaths, ModulePath: modulePath, Version: sample.VersionString},
+	}
+	check(want)
+
+	for _, inc := range want {
+		if err := testDB.RepairInconsistency(ctx, inc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The search document is back, and the module version is marked for
+	// reprocessing rather than reported again.
+	check(nil)
+	vs, err := testDB.GetModuleVersionState(ctx, modulePath, sample.VersionString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := modstatus.Code(vs.Status), modstatus.ReprocessOK; got != want {
+		t.Errorf("status = %s, want %s", got, want)
+	}
+}
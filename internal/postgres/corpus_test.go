@@ -0,0 +1,107 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+// TestCorpus inserts each entry of the golden-module corpus and checks that
+// every module, package and directory reads back as it was inserted.
+func TestCorpus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout*2)
+	defer cancel()
+	ctx = experiment.NewContext(ctx,
+		experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true,
+		}))
+
+	for _, entry := range sample.Corpus() {
+		t.Run(entry.Name, func(t *testing.T) {
+			defer ResetTestDB(testDB, t)
+
+			for _, m := range entry.Modules {
+				if err := testDB.InsertModule(ctx, m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Inserting again changes nothing.
+			for _, m := range entry.Modules {
+				if err := testDB.InsertModule(ctx, m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, m := range entry.Modules {
+				checkCorpusModule(ctx, t, m)
+			}
+		})
+	}
+}
+
+// checkCorpusModule checks that want reads back from testDB as it was
+// inserted.
+func checkCorpusModule(ctx context.Context, t *testing.T, want *internal.Module) {
+	t.Helper()
+
+	// The packages and paths tables only keep the types and file paths of
+	// licenses.
+	licenseOpts := cmpopts.IgnoreFields(licenses.Metadata{}, "Expression", "Coverage")
+
+	gotm, err := testDB.LegacyGetModuleInfo(ctx, want.ModulePath, want.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want.LegacyModuleInfo, *gotm, cmp.AllowUnexported(source.Info{})); diff != "" {
+		t.Errorf("LegacyGetModuleInfo(%q, %q) mismatch (-want +got):\n%s", want.ModulePath, want.Version, diff)
+	}
+
+	for _, wantp := range want.LegacyPackages {
+		got, err := testDB.LegacyGetPackage(ctx, wantp.Path, want.ModulePath, want.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := cmp.Options{
+			cmpopts.IgnoreFields(internal.LegacyPackage{}, "Imports"),
+			licenseOpts,
+			cmpopts.EquateEmpty(),
+		}
+		if diff := cmp.Diff(*wantp, got.LegacyPackage, opts); diff != "" {
+			t.Errorf("LegacyGetPackage(%q, %q) mismatch (-want +got):\n%s", wantp.Path, want.Version, diff)
+		}
+	}
+
+	for _, dir := range want.Directories {
+		got, err := testDB.GetDirectoryNew(ctx, dir.Path, want.ModulePath, want.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Every unit is served with the README of its module.
+		wantd := *dir
+		wantd.Readme = nil
+		if want.LegacyReadmeFilePath != "" {
+			wantd.Readme = &internal.Readme{
+				Filepath: want.LegacyReadmeFilePath,
+				Contents: want.LegacyReadmeContents,
+			}
+		}
+		opts := cmp.Options{
+			licenseOpts,
+			cmp.AllowUnexported(source.Info{}),
+			cmpopts.EquateEmpty(),
+		}
+		if diff := cmp.Diff(internal.VersionedDirectory{DirectoryNew: wantd, ModuleInfo: want.ModuleInfo}, *got, opts); diff != "" {
+			t.Errorf("GetDirectoryNew(%q, %q) mismatch (-want +got):\n%s", dir.Path, want.Version, diff)
+		}
+	}
+}
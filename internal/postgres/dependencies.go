@@ -0,0 +1,153 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
+)
+
+// DependencyStats are the statistics of the requirement graph of a module
+// version.
+type DependencyStats struct {
+	ModulePath string
+	Version    string
+	// DependencyCount is the number of distinct modules that the module
+	// requires, directly or not.
+	DependencyCount int
+	// MaxDepth is the length of the longest chain of requirements from the
+	// module.
+	MaxDepth int
+	// HasCycle reports whether some module in the requirement graph requires
+	// itself, directly or not.
+	HasCycle   bool
+	ComputedAt time.Time
+}
+
+// insertModuleRequirements replaces the module_requirements rows for m with
+// m.Requirements.
+func insertModuleRequirements(ctx context.Context, db *database.DB, m *internal.Module) (err error) {
+	ctx, span := dtrace.StartSpan(ctx, "insertModuleRequirements")
+	ctx = database.WithQueryName(ctx, "insertModuleRequirements")
+	defer span.End()
+	defer derrors.Wrap(&err, "insertModuleRequirements(ctx, %q, %q)", m.ModulePath, m.Version)
+
+	if _, err := db.Exec(ctx, `DELETE FROM module_requirements WHERE module_path = $1 AND version = $2`,
+		m.ModulePath, m.Version); err != nil {
+		return err
+	}
+	var values []interface{}
+	for _, r := range m.Requirements {
+		values = append(values, m.ModulePath, m.Version, r.ModulePath, r.Version)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	cols := []string{"module_path", "version", "required_path", "required_version"}
+	return db.BulkInsert(ctx, "module_requirements", cols, values, "")
+}
+
+// GetModuleRequirements returns the requirements of modulePath at version,
+// ordered by module path. A module version that is not in the database has
+// none.
+func (db *DB) GetModuleRequirements(ctx context.Context, modulePath, version string) (_ []*internal.ModuleRequirement, err error) {
+	defer derrors.Wrap(&err, "GetModuleRequirements(ctx, %q, %q)", modulePath, version)
+
+	var reqs []*internal.ModuleRequirement
+	collect := func(rows *sql.Rows) error {
+		var r internal.ModuleRequirement
+		if err := rows.Scan(&r.ModulePath, &r.Version); err != nil {
+			return err
+		}
+		reqs = append(reqs, &r)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, `
+		SELECT required_path, required_version
+		FROM module_requirements
+		WHERE module_path = $1 AND version = $2
+		ORDER BY required_path`, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// GetModulesForDependencyStats returns up to limit modules whose dependency
+// statistics should be computed, with only their ModulePath and Version set
+// to the latest version of the module. Those are the modules without
+// statistics for their latest version first, then those whose statistics were
+// computed before computedBefore, least recently computed first.
+func (db *DB) GetModulesForDependencyStats(ctx context.Context, computedBefore time.Time, limit int) (_ []*DependencyStats, err error) {
+	defer derrors.Wrap(&err, "GetModulesForDependencyStats(ctx, %s, %d)", computedBefore, limit)
+
+	query := `
+		SELECT l.module_path, l.version
+		FROM (
+			SELECT DISTINCT ON (module_path) module_path, version
+			FROM modules
+			ORDER BY module_path, version_type = 'release' DESC, sort_version DESC
+		) l
+		LEFT JOIN module_dependency_stats s ON s.module_path = l.module_path
+		WHERE s.module_path IS NULL OR s.version <> l.version OR s.computed_at < $1
+		ORDER BY s.version = l.version NULLS FIRST, s.computed_at NULLS FIRST, l.module_path
+		LIMIT $2`
+	var stats []*DependencyStats
+	collect := func(rows *sql.Rows) error {
+		var s DependencyStats
+		if err := rows.Scan(&s.ModulePath, &s.Version); err != nil {
+			return err
+		}
+		stats = append(stats, &s)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, computedBefore, limit); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// UpsertDependencyStats records s as the dependency statistics of the module
+// s.ModulePath, replacing those of other versions of it.
+func (db *DB) UpsertDependencyStats(ctx context.Context, s *DependencyStats) (err error) {
+	defer derrors.Wrap(&err, "UpsertDependencyStats(ctx, %q, %q)", s.ModulePath, s.Version)
+
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO module_dependency_stats (module_path, version, dependency_count, max_depth, has_cycle)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (module_path) DO UPDATE SET
+			version = excluded.version,
+			dependency_count = excluded.dependency_count,
+			max_depth = excluded.max_depth,
+			has_cycle = excluded.has_cycle,
+			computed_at = CURRENT_TIMESTAMP`,
+		s.ModulePath, s.Version, s.DependencyCount, s.MaxDepth, s.HasCycle)
+	return err
+}
+
+// GetDependencyStats returns the dependency statistics of modulePath. It
+// returns a derrors.NotFound error if they have not been computed.
+func (db *DB) GetDependencyStats(ctx context.Context, modulePath string) (_ *DependencyStats, err error) {
+	defer derrors.Wrap(&err, "GetDependencyStats(ctx, %q)", modulePath)
+
+	s := &DependencyStats{}
+	err = db.db.QueryStruct(ctx, s, `
+		SELECT module_path, version, dependency_count, max_depth, has_cycle, computed_at
+		FROM module_dependency_stats
+		WHERE module_path = $1`, modulePath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
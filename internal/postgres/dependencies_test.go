@@ -0,0 +1,104 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestDependencyStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	reqs := []*internal.ModuleRequirement{
+		{ModulePath: "b.com/n", Version: "v1.0.0"},
+		{ModulePath: "c.com/o", Version: "v1.2.0"},
+	}
+	for _, mv := range []struct {
+		path, version string
+		reqs          []*internal.ModuleRequirement
+	}{
+		{"a.com/m", "v1.0.0", nil},
+		{"a.com/m", "v1.1.0", reqs},
+		{"b.com/n", "v1.0.0", nil},
+	} {
+		m := sample.Module(mv.path, mv.version, "p")
+		m.Requirements = mv.reqs
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := testDB.GetModuleRequirements(ctx, "a.com/m", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(reqs, got); diff != "" {
+		t.Errorf("GetModuleRequirements mismatch (-want +got):\n%s", diff)
+	}
+
+	// Only the latest version of each module needs statistics.
+	ignore := cmpopts.IgnoreFields(DependencyStats{}, "ComputedAt")
+	todo, err := testDB.GetModulesForDependencyStats(ctx, time.Now(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*DependencyStats{
+		{ModulePath: "a.com/m", Version: "v1.1.0"},
+		{ModulePath: "b.com/n", Version: "v1.0.0"},
+	}
+	if diff := cmp.Diff(want, todo, ignore); diff != "" {
+		t.Errorf("GetModulesForDependencyStats mismatch (-want +got):\n%s", diff)
+	}
+
+	stats := &DependencyStats{ModulePath: "a.com/m", Version: "v1.1.0", DependencyCount: 2, MaxDepth: 1}
+	if err := testDB.UpsertDependencyStats(ctx, stats); err != nil {
+		t.Fatal(err)
+	}
+	gotStats, err := testDB.GetDependencyStats(ctx, "a.com/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(stats, gotStats, ignore); diff != "" {
+		t.Errorf("GetDependencyStats mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := testDB.GetDependencyStats(ctx, "b.com/n"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetDependencyStats of module without stats: got %v, want NotFound", err)
+	}
+
+	// Fresh statistics are not computed again, but those of an older version
+	// are.
+	todo, err = testDB.GetModulesForDependencyStats(ctx, time.Now().Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want[1:], todo, ignore); diff != "" {
+		t.Errorf("GetModulesForDependencyStats after upsert mismatch (-want +got):\n%s", diff)
+	}
+	if err := testDB.InsertModule(ctx, sample.Module("a.com/m", "v1.2.0", "p")); err != nil {
+		t.Fatal(err)
+	}
+	todo, err = testDB.GetModulesForDependencyStats(ctx, time.Now().Add(-time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []*DependencyStats{
+		{ModulePath: "b.com/n", Version: "v1.0.0"},
+		{ModulePath: "a.com/m", Version: "v1.2.0"},
+	}
+	if diff := cmp.Diff(want, todo, ignore); diff != "" {
+		t.Errorf("GetModulesForDependencyStats after new version mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -7,10 +7,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"reflect"
 	"strings"
 
 	"github.com/lib/pq"
@@ -33,6 +30,7 @@ func (db *DB) LegacyGetPackagesInModule(ctx context.Context, modulePath, version
 		license_paths,
 		redistributable,
 		documentation,
+		documentation_blob_key,
 		goos,
 		goarch
 	FROM
@@ -42,15 +40,19 @@ func (db *DB) LegacyGetPackagesInModule(ctx context.Context, modulePath, version
 		AND version = $2
 	ORDER BY path;`
 
-	var packages []*internal.LegacyPackage
+	var (
+		packages []*internal.LegacyPackage
+		docKeys  []string
+	)
 	collect := func(rows *sql.Rows) error {
 		var (
 			p                          internal.LegacyPackage
 			licenseTypes, licensePaths []string
+			docKey                     string
 		)
 		if err := rows.Scan(&p.Path, &p.Name, &p.Synopsis, &p.V1Path, pq.Array(&licenseTypes),
-			pq.Array(&licensePaths), &p.IsRedistributable, database.NullIsEmpty(&p.DocumentationHTML),
-			&p.GOOS, &p.GOARCH); err != nil {
+			pq.Array(&licensePaths), &p.IsRedistributable, database.Compressed(&p.DocumentationHTML),
+			database.NullIsEmpty(&docKey), &p.GOOS, &p.GOARCH); err != nil {
 			return fmt.Errorf("row.Scan(): %v", err)
 		}
 		lics, err := zipLicenseMetadata(licenseTypes, licensePaths)
@@ -59,12 +61,18 @@ func (db *DB) LegacyGetPackagesInModule(ctx context.Context, modulePath, version
 		}
 		p.Licenses = lics
 		packages = append(packages, &p)
+		docKeys = append(docKeys, docKey)
 		return nil
 	}
 
 	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
 		return nil, fmt.Errorf("DB.LegacyGetPackagesInModule(ctx, %q, %q): %w", modulePath, version, err)
 	}
+	for i, p := range packages {
+		if err := db.blobs.load(ctx, &p.DocumentationHTML, docKeys[i]); err != nil {
+			return nil, fmt.Errorf("DB.LegacyGetPackagesInModule(ctx, %q, %q): %w", modulePath, version, err)
+		}
+	}
 	return packages, nil
 }
 
@@ -92,7 +100,8 @@ func getPackageVersions(ctx context.Context, db *DB, pkgPath string, versionType
 		SELECT
 			p.module_path,
 			p.version,
-			m.commit_time
+			m.commit_time,
+			m.published_at
 		FROM
 			packages p
 		INNER JOIN
@@ -127,7 +136,7 @@ func getPackageVersions(ctx context.Context, db *DB, pkgPath string, versionType
 	var versionHistory []*internal.ModuleInfo
 	for rows.Next() {
 		var mi internal.ModuleInfo
-		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime); err != nil {
+		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime, database.NullIsZero(&mi.PublishedAt)); err != nil {
 			return nil, fmt.Errorf("row.Scan(): %v", err)
 		}
 		versionHistory = append(versionHistory, &mi)
@@ -170,7 +179,7 @@ func getModuleVersions(ctx context.Context, db *DB, modulePath string, versionTy
 
 	baseQuery := `
 	SELECT
-		module_path, version, commit_time
+		module_path, version, commit_time, published_at
     FROM
 		modules
 	WHERE
@@ -189,7 +198,7 @@ func getModuleVersions(ctx context.Context, db *DB, modulePath string, versionTy
 	var vinfos []*internal.ModuleInfo
 	collect := func(rows *sql.Rows) error {
 		var mi internal.ModuleInfo
-		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime); err != nil {
+		if err := rows.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime, database.NullIsZero(&mi.PublishedAt)); err != nil {
 			return err
 		}
 		vinfos = append(vinfos, &mi)
@@ -292,7 +301,8 @@ func (db *DB) LegacyGetModuleInfo(ctx context.Context, modulePath string, versio
 			version_type,
 			source_info,
 			redistributable,
-			has_go_mod
+			has_go_mod,
+			published_at
 		FROM
 			modules`
 
@@ -319,8 +329,8 @@ func (db *DB) LegacyGetModuleInfo(ctx context.Context, modulePath string, versio
 	)
 	row := db.db.QueryRow(ctx, query, args...)
 	if err := row.Scan(&mi.ModulePath, &mi.Version, &mi.CommitTime,
-		database.NullIsEmpty(&mi.LegacyReadmeFilePath), database.NullIsEmpty(&mi.LegacyReadmeContents), &mi.VersionType,
-		jsonbScanner{&mi.SourceInfo}, &mi.IsRedistributable, &hasGoMod); err != nil {
+		database.NullIsEmpty(&mi.LegacyReadmeFilePath), database.Compressed(&mi.LegacyReadmeContents), &mi.VersionType,
+		database.JSONB(&mi.SourceInfo), &mi.IsRedistributable, &hasGoMod, database.NullIsZero(&mi.PublishedAt)); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("module version %s@%s: %w", modulePath, version, derrors.NotFound)
 		}
@@ -338,32 +348,3 @@ func setHasGoMod(mi *internal.ModuleInfo, nb sql.NullBool) {
 		mi.HasGoMod = nb.Bool
 	}
 }
-
-// jsonbScanner scans a jsonb value into a Go value.
-type jsonbScanner struct {
-	ptr interface{} // a pointer to a Go struct or other JSON-serializable value
-}
-
-func (s jsonbScanner) Scan(value interface{}) (err error) {
-	defer derrors.Wrap(&err, "jsonbScanner(%+v)", value)
-
-	vptr := reflect.ValueOf(s.ptr)
-	if value == nil {
-		// *s.ptr = nil
-		vptr.Elem().Set(reflect.Zero(vptr.Elem().Type()))
-		return nil
-	}
-	jsonBytes, ok := value.([]byte)
-	if !ok {
-		return errors.New("not a []byte")
-	}
-	// v := &[type of *s.ptr]
-	v := reflect.New(vptr.Elem().Type())
-	if err := json.Unmarshal(jsonBytes, v.Interface()); err != nil {
-		return err
-	}
-
-	// *s.ptr = *v
-	vptr.Elem().Set(v.Elem())
-	return nil
-}
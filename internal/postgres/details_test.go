@@ -6,7 +6,6 @@ package postgres
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"sort"
@@ -340,31 +339,3 @@ func TestGetPackagesInVersion(t *testing.T) {
 		})
 	}
 }
-
-func TestJSONBScanner(t *testing.T) {
-	type S struct{ A int }
-
-	want := &S{1}
-	val, err := json.Marshal(want)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	var got *S
-	js := jsonbScanner{&got}
-	if err := js.Scan(val); err != nil {
-		t.Fatal(err)
-	}
-	if *got != *want {
-		t.Errorf("got %+v, want %+v", *got, *want)
-	}
-
-	var got2 *S
-	js = jsonbScanner{&got2}
-	if err := js.Scan(nil); err != nil {
-		t.Fatal(err)
-	}
-	if got2 != nil {
-		t.Errorf("got %#v, want nil", got2)
-	}
-}
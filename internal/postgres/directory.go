@@ -21,120 +21,11 @@ import (
 // data associated with that directory, including the package, imports, readme,
 // documentation, and licenses.
 func (db *DB) GetDirectoryNew(ctx context.Context, path, modulePath, version string) (_ *internal.VersionedDirectory, err error) {
-	query := `
-		SELECT
-			m.module_path,
-			m.version,
-			m.commit_time,
-			m.version_type,
-			m.redistributable,
-			m.has_go_mod,
-			m.source_info,
-			p.id,
-			p.path,
-			p.name,
-			p.v1_path,
-			p.redistributable,
-			p.license_types,
-			p.license_paths,
-			d.goos,
-			d.goarch,
-			d.synopsis,
-			d.html
-		FROM modules m
-		INNER JOIN paths p
-		ON p.module_id = m.id
-		LEFT JOIN documentation d
-		ON d.path_id = p.id
-		WHERE
-			p.path = $1
-			AND m.module_path = $2
-			AND m.version = $3;`
-	var (
-		mi                         internal.ModuleInfo
-		dir                        internal.DirectoryNew
-		doc                        internal.Documentation
-		pkg                        internal.PackageNew
-		licenseTypes, licensePaths []string
-		pathID                     int
-	)
-	row := db.db.QueryRow(ctx, query, path, modulePath, version)
-	if err := row.Scan(
-		&mi.ModulePath,
-		&mi.Version,
-		&mi.CommitTime,
-		&mi.VersionType,
-		&mi.IsRedistributable,
-		&mi.HasGoMod,
-		jsonbScanner{&mi.SourceInfo},
-		&pathID,
-		&dir.Path,
-		database.NullIsEmpty(&pkg.Name),
-		&dir.V1Path,
-		&dir.IsRedistributable,
-		pq.Array(&licenseTypes),
-		pq.Array(&licensePaths),
-		database.NullIsEmpty(&doc.GOOS),
-		database.NullIsEmpty(&doc.GOARCH),
-		database.NullIsEmpty(&doc.Synopsis),
-		database.NullIsEmpty(&doc.HTML),
-	); err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("directory %s@%s: %w", path, version, derrors.NotFound)
-		}
-		return nil, fmt.Errorf("row.Scan(): %v", err)
-	}
-
-	lics, err := zipLicenseMetadata(licenseTypes, licensePaths)
+	um, err := db.GetUnitMeta(ctx, path, modulePath, version)
 	if err != nil {
 		return nil, err
 	}
-	dir.Licenses = lics
-	if pkg.Name != "" {
-		dir.Package = &pkg
-		pkg.Path = dir.Path
-		pkg.Documentation = &doc
-		collect := func(rows *sql.Rows) error {
-			var path string
-			if err := rows.Scan(&path); err != nil {
-				return fmt.Errorf("row.Scan(): %v", err)
-			}
-			pkg.Imports = append(pkg.Imports, path)
-			return nil
-		}
-		if err := db.db.RunQuery(ctx, `
-		SELECT to_path
-		FROM package_imports
-		WHERE path_id = $1`, collect, pathID); err != nil {
-			return nil, err
-		}
-	}
-
-	// TODO(golang/go#38513): remove and query the readmes table directly once
-	// we start displaying READMEs for directories instead of the top-level
-	// module.
-	var readme internal.Readme
-	row = db.db.QueryRow(ctx, `
-		SELECT file_path, contents
-		FROM modules m
-		INNER JOIN paths p
-		ON p.module_id = m.id
-		INNER JOIN readmes r
-		ON p.id = r.path_id
-		WHERE
-		    module_path=$1
-			AND m.version=$2
-			AND m.module_path=p.path`, modulePath, version)
-	if err := row.Scan(&readme.Filepath, &readme.Contents); err != nil && err != sql.ErrNoRows {
-		return nil, err
-	}
-	if readme.Filepath != "" {
-		dir.Readme = &readme
-	}
-	return &internal.VersionedDirectory{
-		ModuleInfo:   mi,
-		DirectoryNew: dir,
-	}, nil
+	return db.GetUnit(ctx, um, internal.AllFields)
 }
 
 // LegacyGetDirectory returns the directory corresponding to the provided dirPath,
@@ -199,6 +90,7 @@ func (db *DB) LegacyGetDirectory(ctx context.Context, dirPath, modulePath, versi
 			pkg          = internal.LegacyPackage{DocumentationHTML: internal.StringFieldMissing}
 			licenseTypes []string
 			licensePaths []string
+			docKey       string
 		)
 		scanArgs := []interface{}{
 			&pkg.Path,
@@ -207,7 +99,7 @@ func (db *DB) LegacyGetDirectory(ctx context.Context, dirPath, modulePath, versi
 			&pkg.V1Path,
 		}
 		if fields&internal.WithDocumentationHTML != 0 {
-			scanArgs = append(scanArgs, database.NullIsEmpty(&pkg.DocumentationHTML))
+			scanArgs = append(scanArgs, database.Compressed(&pkg.DocumentationHTML), database.NullIsEmpty(&docKey))
 		}
 		scanArgs = append(scanArgs,
 			pq.Array(&licenseTypes),
@@ -219,15 +111,16 @@ func (db *DB) LegacyGetDirectory(ctx context.Context, dirPath, modulePath, versi
 			&mi.ModulePath,
 			database.NullIsEmpty(&mi.LegacyReadmeFilePath))
 		if fields&internal.WithReadmeContents != 0 {
-			scanArgs = append(scanArgs, database.NullIsEmpty(&mi.LegacyReadmeContents))
+			scanArgs = append(scanArgs, database.Compressed(&mi.LegacyReadmeContents))
 		}
 		var hasGoMod sql.NullBool
 		scanArgs = append(scanArgs,
 			&mi.CommitTime,
 			&mi.VersionType,
-			jsonbScanner{&mi.SourceInfo},
+			database.JSONB(&mi.SourceInfo),
 			&mi.IsRedistributable,
-			&hasGoMod)
+			&hasGoMod,
+			database.NullIsZero(&mi.PublishedAt))
 		if err := rows.Scan(scanArgs...); err != nil {
 			return fmt.Errorf("row.Scan(): %v", err)
 		}
@@ -237,6 +130,11 @@ func (db *DB) LegacyGetDirectory(ctx context.Context, dirPath, modulePath, versi
 			return err
 		}
 		pkg.Licenses = lics
+		if docKey != "" {
+			// Like LegacyGetPackage, leave documentation in a blob for
+			// LegacyGetPackageDocumentation.
+			pkg.DocumentationHTML = internal.StringFieldMissing
+		}
 		packages = append(packages, &pkg)
 		return nil
 	}
@@ -259,7 +157,7 @@ func (db *DB) LegacyGetDirectory(ctx context.Context, dirPath, modulePath, versi
 func directoryColumns(fields internal.FieldSet) string {
 	var doc, readme string
 	if fields&internal.WithDocumentationHTML != 0 {
-		doc = "p.documentation,"
+		doc = "p.documentation, p.documentation_blob_key,"
 	}
 	if fields&internal.WithReadmeContents != 0 {
 		readme = "m.readme_contents,"
@@ -283,7 +181,8 @@ func directoryColumns(fields internal.FieldSet) string {
 			m.version_type,
 			m.source_info,
 			m.redistributable,
-			m.has_go_mod`
+			m.has_go_mod,
+			m.published_at`
 }
 
 const orderByLatest = `
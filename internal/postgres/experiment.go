@@ -18,14 +18,9 @@ func (db *DB) GetExperiments(ctx context.Context) (_ []*internal.Experiment, err
 
 	query := "SELECT name, rollout, description FROM experiments;"
 	var experiments []*internal.Experiment
-	err = db.db.RunQuery(ctx, query, func(rows *sql.Rows) error {
-		var e internal.Experiment
-		if err := rows.Scan(&e.Name, &e.Rollout, &e.Description); err != nil {
-			return err
-		}
-		experiments = append(experiments, &e)
-		return nil
-	})
+	if err := db.db.CollectStructs(ctx, &experiments, query); err != nil {
+		return nil, err
+	}
 	return experiments, nil
 }
 
@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A FetchQueueEntry is a task of the fetch_queue table, which is the task
+// queue of deployments that do not use Cloud Tasks.
+type FetchQueueEntry struct {
+	ID         int
+	ModulePath string
+	Version    string
+	// Attempts is the number of times the task has been taken, including
+	// by the LeaseFetch that returned it.
+	Attempts int
+}
+
+// EnqueueFetch adds a task to fetch modulePath at version to the fetch queue,
+// unless a task with the same key is already there.
+func (db *DB) EnqueueFetch(ctx context.Context, modulePath, version, key string) (err error) {
+	defer derrors.Wrap(&err, "EnqueueFetch(ctx, %q, %q, %q)", modulePath, version, key)
+
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO fetch_queue (task_key, module_path, version)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (task_key) DO NOTHING`, key, modulePath, version)
+	return err
+}
+
+// LeaseFetch takes the oldest task of the fetch queue that no other worker is
+// processing, leases it for the given duration and counts the attempt. The
+// task should be deleted with DeleteFetch once its fetch has finished; if it
+// is not, it is taken again after the lease expires. LeaseFetch returns nil if
+// there is no task to take.
+//
+// Rows that other transactions have locked are skipped, so that concurrent
+// workers do not wait for each other.
+func (db *DB) LeaseFetch(ctx context.Context, lease time.Duration) (_ *FetchQueueEntry, err error) {
+	defer derrors.Wrap(&err, "LeaseFetch(ctx, %s)", lease)
+
+	var e FetchQueueEntry
+	err = db.db.QueryStruct(ctx, &e, `
+		UPDATE fetch_queue
+		SET leased_until = CURRENT_TIMESTAMP + make_interval(secs => $1),
+			attempts = attempts + 1
+		WHERE id = (
+			SELECT id
+			FROM fetch_queue
+			WHERE leased_until IS NULL OR leased_until < CURRENT_TIMESTAMP
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, module_path, version, attempts`, lease.Seconds())
+	switch err {
+	case nil:
+		return &e, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// DeleteFetch removes the task with the given ID from the fetch queue.
+func (db *DB) DeleteFetch(ctx context.Context, id int) (err error) {
+	defer derrors.Wrap(&err, "DeleteFetch(ctx, %d)", id)
+
+	_, err = db.db.Exec(ctx, `DELETE FROM fetch_queue WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFetchQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, e := range []struct{ modulePath, version, key string }{
+		{"a.com/m", "v1.0.0", "k1"},
+		{"b.com/m", "v1.0.0", "k2"},
+		{"c.com/m", "v1.0.0", "k1"}, // duplicate key: ignored
+	} {
+		if err := testDB.EnqueueFetch(ctx, e.modulePath, e.version, e.key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lease := func(d time.Duration) *FetchQueueEntry {
+		t.Helper()
+		e, err := testDB.LeaseFetch(ctx, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	opt := cmpopts.IgnoreFields(FetchQueueEntry{}, "ID")
+
+	first := lease(time.Hour)
+	if diff := cmp.Diff(&FetchQueueEntry{ModulePath: "a.com/m", Version: "v1.0.0", Attempts: 1}, first, opt); diff != "" {
+		t.Errorf("first lease mismatch (-want +got):\n%s", diff)
+	}
+	// A task whose lease has expired is taken again.
+	second := lease(-time.Second)
+	if diff := cmp.Diff(&FetchQueueEntry{ModulePath: "b.com/m", Version: "v1.0.0", Attempts: 1}, second, opt); diff != "" {
+		t.Errorf("second lease mismatch (-want +got):\n%s", diff)
+	}
+	if got := lease(time.Hour); got == nil || got.ID != second.ID || got.Attempts != 2 {
+		t.Errorf("got %+v, want the task with the expired lease (%+v), on its second attempt", got, second)
+	}
+	if got := lease(time.Hour); got != nil {
+		t.Errorf("got %+v, want nil: every task is leased", got)
+	}
+
+	if err := testDB.DeleteFetch(ctx, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Once its task is deleted, the key can be used again.
+	if err := testDB.EnqueueFetch(ctx, "d.com/m", "v1.0.0", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&FetchQueueEntry{ModulePath: "d.com/m", Version: "v1.0.0", Attempts: 1}, lease(time.Hour), opt); diff != "" {
+		t.Errorf("lease after delete mismatch (-want +got):\n%s", diff)
+	}
+}
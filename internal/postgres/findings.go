@@ -0,0 +1,35 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetPackageFindings returns what analyzers reported about the package at
+// pkgPath in modulePath at version when it was fetched, ordered by analyzer
+// and category. It returns no findings if the package is unknown, if no
+// analyzers ran on it, or if they found nothing.
+func (db *DB) GetPackageFindings(ctx context.Context, pkgPath, modulePath, version string) (_ []*internal.Finding, err error) {
+	defer derrors.Wrap(&err, "DB.GetPackageFindings(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	if pkgPath == "" || version == "" || modulePath == "" {
+		return nil, fmt.Errorf("pkgPath, modulePath and version must all be non-empty: %w", derrors.InvalidArgument)
+	}
+	query := `
+		SELECT analyzer, category, count
+		FROM package_findings
+		WHERE package_path = $1 AND module_path = $2 AND version = $3
+		ORDER BY analyzer, category`
+	var findings []*internal.Finding
+	if err := db.db.CollectStructs(ctx, &findings, query, pkgPath, modulePath, version); err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
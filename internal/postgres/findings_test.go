@@ -0,0 +1,63 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetPackageFindings(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	const (
+		modulePath = "example.com/mod"
+		version    = "v1.0.0"
+		pkgPath    = modulePath + "/pkg"
+	)
+	insert := func(findings []*internal.Finding) {
+		t.Helper()
+		m := sample.Module(modulePath, version, "pkg", "other")
+		for _, p := range m.LegacyPackages {
+			if p.Path == pkgPath {
+				p.Findings = findings
+			}
+		}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(path string, want []*internal.Finding) {
+		t.Helper()
+		got, err := testDB.GetPackageFindings(ctx, path, modulePath, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("GetPackageFindings(%q) mismatch (-want +got):\n%s", path, diff)
+		}
+	}
+
+	findings := []*internal.Finding{
+		{Analyzer: "vet", Category: "unreachable", Count: 2},
+		{Analyzer: "vet", Category: "assign", Count: 1},
+	}
+	insert(findings)
+	check(pkgPath, []*internal.Finding{findings[1], findings[0]})
+	check(modulePath+"/other", nil)
+
+	// Fetching the version again replaces its findings.
+	insert(findings[:1])
+	check(pkgPath, findings[:1])
+	insert(nil)
+	check(pkgPath, nil)
+}
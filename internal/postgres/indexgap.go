@@ -0,0 +1,120 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// An IndexGap is a window [Since, Until) of module index timestamps in which
+// versions may have been missed, and should be compared with
+// module_version_states.
+type IndexGap struct {
+	ID         int
+	Since      time.Time
+	Until      time.Time
+	DetectedAt time.Time
+}
+
+// InsertIndexGap records a window of index timestamps to be reconciled, and
+// returns its ID.
+func (db *DB) InsertIndexGap(ctx context.Context, since, until time.Time) (_ int, err error) {
+	defer derrors.Wrap(&err, "InsertIndexGap(ctx, %s, %s)", since, until)
+
+	var id int
+	err = db.db.QueryRow(ctx, `
+		INSERT INTO index_gaps (since, until)
+		VALUES ($1, $2)
+		RETURNING id`, since, until).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetUnreconciledIndexGaps returns up to limit index gaps that have not been
+// reconciled, oldest first.
+func (db *DB) GetUnreconciledIndexGaps(ctx context.Context, limit int) (_ []*IndexGap, err error) {
+	defer derrors.Wrap(&err, "GetUnreconciledIndexGaps(ctx, %d)", limit)
+
+	query := `
+		SELECT id, since, until, detected_at
+		FROM index_gaps
+		WHERE reconciled_at IS NULL
+		ORDER BY id
+		LIMIT $1`
+	var gaps []*IndexGap
+	if err := db.db.CollectStructs(ctx, &gaps, query, limit); err != nil {
+		return nil, err
+	}
+	return gaps, nil
+}
+
+// ReconcileIndexGap compares versions, the contents of the module index in
+// the window of the gap with the given ID, with module_version_states. In a
+// single transaction, it inserts the versions that are missing into
+// module_version_states and the fetch outbox, and marks the gap reconciled.
+// It returns the missing versions.
+func (db *DB) ReconcileIndexGap(ctx context.Context, id int, versions []*internal.IndexVersion) (_ []*internal.IndexVersion, err error) {
+	defer derrors.Wrap(&err, "ReconcileIndexGap(ctx, %d, %d versions)", id, len(versions))
+
+	var missing []*internal.IndexVersion
+	err = db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		var paths, vers []string
+		for _, v := range versions {
+			paths = append(paths, v.Path)
+			vers = append(vers, v.Version)
+		}
+		present := map[string]bool{}
+		collect := func(rows *sql.Rows) error {
+			var path, version string
+			if err := rows.Scan(&path, &version); err != nil {
+				return err
+			}
+			present[path+"@"+version] = true
+			return nil
+		}
+		if err := tx.RunQuery(ctx, `
+			SELECT module_path, version
+			FROM module_version_states
+			WHERE (module_path, version) IN (
+				SELECT * FROM unnest($1::text[], $2::text[])
+			)`, collect, pq.Array(paths), pq.Array(vers)); err != nil {
+			return err
+		}
+		for _, v := range versions {
+			if !present[v.Path+"@"+v.Version] {
+				missing = append(missing, v)
+			}
+		}
+		if len(missing) > 0 {
+			if err := insertIndexVersions(ctx, tx, missing); err != nil {
+				return err
+			}
+			if err := insertFetchOutbox(ctx, tx, missing); err != nil {
+				return err
+			}
+		}
+		res, err := tx.Exec(ctx, `
+			UPDATE index_gaps
+			SET reconciled_at = CURRENT_TIMESTAMP, num_missing = $2
+			WHERE id = $1`, id, len(missing))
+		if err != nil {
+			return err
+		}
+		return notFoundIfNoRows(res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestReconcileIndexGap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	present := &internal.IndexVersion{Path: "example.com/present", Version: "v1.0.0", Timestamp: now}
+	missing := &internal.IndexVersion{Path: "example.com/missing", Version: "v1.0.0", Timestamp: now.Add(time.Second)}
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{present}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := testDB.InsertIndexGap(ctx, now, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gaps, err := testDB.GetUnreconciledIndexGaps(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 1 || gaps[0].ID != id {
+		t.Fatalf("got gaps %v, want one gap with ID %d", gaps, id)
+	}
+
+	got, err := testDB.ReconcileIndexGap(ctx, id, []*internal.IndexVersion{present, missing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*internal.IndexVersion{missing}, got); diff != "" {
+		t.Errorf("ReconcileIndexGap mismatch (-want +got):\n%s", diff)
+	}
+	if _, err := testDB.GetModuleVersionState(ctx, missing.Path, missing.Version); err != nil {
+		t.Errorf("missing version was not inserted: %v", err)
+	}
+	entries, err := testDB.GetPendingFetchOutbox(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ModulePath != missing.Path {
+		t.Errorf("got outbox entries %v, want one for %s", entries, missing.Path)
+	}
+	gaps, err = testDB.GetUnreconciledIndexGaps(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 0 {
+		t.Errorf("got %d unreconciled gaps, want 0", len(gaps))
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// IndexPollState describes the state of polling the module index.
+type IndexPollState struct {
+	// Cursor is the index timestamp from which the next poll should start.
+	Cursor time.Time
+	// Interval is the current time between polls.
+	Interval time.Duration
+	// NextPollAt is the earliest time at which the index should be polled
+	// again.
+	NextPollAt time.Time
+	// LastPolledAt is the time of the most recent poll, or the zero time if
+	// the index has never been polled.
+	LastPolledAt time.Time
+	// LastNumVersions is the number of versions read by the most recent poll.
+	LastNumVersions int
+}
+
+// GetIndexPollState returns the current state of polling the module index.
+// If the index has never been polled, the state has a zero Interval and
+// NextPollAt, and its Cursor is the latest index timestamp in
+// module_version_states.
+func (db *DB) GetIndexPollState(ctx context.Context) (_ *IndexPollState, err error) {
+	defer derrors.Wrap(&err, "GetIndexPollState(ctx)")
+
+	query := `
+		SELECT cursor, interval_seconds, next_poll_at, last_polled_at, last_num_versions
+		FROM index_poll_state`
+	var (
+		s        IndexPollState
+		interval int
+	)
+	err = db.db.QueryRow(ctx, query).Scan(&s.Cursor, &interval, &s.NextPollAt, &s.LastPolledAt, &s.LastNumVersions)
+	switch err {
+	case sql.ErrNoRows:
+		ts, err := db.LatestIndexTimestamp(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &IndexPollState{Cursor: ts}, nil
+	case nil:
+		s.Interval = time.Duration(interval) * time.Second
+		return &s, nil
+	default:
+		return nil, err
+	}
+}
+
+// InsertIndexVersionsAndPollState inserts the given versions into
+// module_version_states and the fetch outbox, and records the new poll state,
+// in a single transaction. That way the cursor never advances past versions
+// that were not recorded, and every recorded version is eventually published
+// to the task queue.
+func (db *DB) InsertIndexVersionsAndPollState(ctx context.Context, versions []*internal.IndexVersion, state *IndexPollState) (err error) {
+	defer derrors.Wrap(&err, "InsertIndexVersionsAndPollState(ctx, %d versions, %+v)", len(versions), state)
+
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if err := insertIndexVersions(ctx, tx, versions); err != nil {
+			return err
+		}
+		if err := insertFetchOutbox(ctx, tx, versions); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO index_poll_state
+				(cursor, interval_seconds, next_poll_at, last_polled_at, last_num_versions)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (singleton)
+			DO UPDATE SET
+				cursor=excluded.cursor,
+				interval_seconds=excluded.interval_seconds,
+				next_poll_at=excluded.next_poll_at,
+				last_polled_at=excluded.last_polled_at,
+				last_num_versions=excluded.last_num_versions`,
+			state.Cursor, int(state.Interval/time.Second), state.NextPollAt, state.LastPolledAt, state.LastNumVersions)
+		return err
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestIndexPollState(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	timeEqual := cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })
+
+	// Before the first poll, the cursor comes from module_version_states.
+	indexTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{
+		{Path: "m.com/a", Version: "v1.0.0", Timestamp: indexTime},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetIndexPollState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&IndexPollState{Cursor: indexTime}, got, timeEqual); diff != "" {
+		t.Errorf("initial state mismatch (-want +got):\n%s", diff)
+	}
+
+	now := indexTime.Add(time.Hour)
+	versions := []*internal.IndexVersion{
+		{Path: "m.com/b", Version: "v1.0.0", Timestamp: indexTime.Add(time.Minute)},
+		{Path: "m.com/c", Version: "v1.0.0", Timestamp: indexTime.Add(2 * time.Minute)},
+	}
+	want := &IndexPollState{
+		Cursor:          indexTime.Add(2 * time.Minute),
+		Interval:        2 * time.Minute,
+		NextPollAt:      now.Add(2 * time.Minute),
+		LastPolledAt:    now,
+		LastNumVersions: 2,
+	}
+	for i := 0; i < 2; i++ {
+		// Recording the same poll twice updates the single row.
+		if err := testDB.InsertIndexVersionsAndPollState(ctx, versions, want); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err = testDB.GetIndexPollState(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got, timeEqual); diff != "" {
+		t.Errorf("state mismatch (-want +got):\n%s", diff)
+	}
+	for _, v := range versions {
+		if _, err := testDB.GetModuleVersionState(ctx, v.Path, v.Version); err != nil {
+			t.Errorf("GetModuleVersionState(%q, %q): %v", v.Path, v.Version, err)
+		}
+	}
+}
@@ -15,17 +15,20 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/lib/pq"
-	"go.opencensus.io/trace"
 	"golang.org/x/mod/module"
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/modstatus"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/version"
 )
@@ -58,21 +61,51 @@ func (db *DB) InsertModule(ctx context.Context, m *internal.Module) (err error)
 	if err := db.comparePaths(ctx, m); err != nil {
 		return err
 	}
+	// Manual license overrides take precedence over the redistributability
+	// computed from the detected licenses, so apply them before pruning.
+	if err := db.applyLicenseOverrides(ctx, m); err != nil {
+		return err
+	}
 	removeNonDistributableData(m)
-	return db.saveModule(ctx, m)
+	if err := db.saveModule(ctx, m); err != nil {
+		return err
+	}
+	db.runModuleHooks(ctx, m.ModulePath, modulePaths(m))
+	return nil
+}
+
+// modulePaths returns the paths of the packages and directories of m.
+func modulePaths(m *internal.Module) []string {
+	seen := map[string]bool{}
+	var paths []string
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	for _, d := range m.Directories {
+		add(d.Path)
+	}
+	for _, p := range m.LegacyPackages {
+		add(p.Path)
+	}
+	return paths
 }
 
 // saveModule inserts a Module into the database along with its packages,
-// imports, and licenses.  If any of these rows already exist, the module and
-// corresponding will be deleted and reinserted.
+// imports, and licenses.  If any of these rows already exist, they are
+// updated in place, and licenses that are no longer in the module are
+// removed.
 // If the module is malformed then insertion will fail.
 //
 // A derrors.InvalidArgument error will be returned if the given module and
 // licenses are invalid.
 func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 	defer derrors.Wrap(&err, "saveModule(ctx, tx, Module(%q, %q))", m.ModulePath, m.Version)
-	ctx, span := trace.StartSpan(ctx, "saveModule")
-	defer span.End()
+	ctx, span := dtrace.StartSpan(ctx, "saveModule", dtrace.ModulePath(m.ModulePath), dtrace.Version(m.Version))
+	ctx = database.WithQueryName(ctx, "saveModule")
+	defer dtrace.End(span, &err)
 
 	logMemory(ctx, "at start of saveModule")
 	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
@@ -87,13 +120,22 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 		}
 
 		logMemory(ctx, "after insertLicenses")
-		if err := insertPackages(ctx, tx, m); err != nil {
+		if err := insertPackages(ctx, tx, m, db.blobs); err != nil {
 			return err
 		}
 		logMemory(ctx, "after insertPackages")
+		if err := insertLicenseReviews(ctx, tx, m); err != nil {
+			return err
+		}
+		if err := insertModuleRequirements(ctx, tx, m); err != nil {
+			return err
+		}
+		if err := insertVulnMatches(ctx, tx, m.ModulePath, m.Version); err != nil {
+			return err
+		}
 
 		if experiment.IsActive(ctx, internal.ExperimentInsertDirectories) {
-			if err := insertDirectories(ctx, tx, m, moduleID); err != nil {
+			if err := insertDirectories(ctx, tx, m, moduleID, db.blobs); err != nil {
 				return err
 			}
 		}
@@ -132,7 +174,7 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 		// To take an actual example: github.com/sirupsen/logrus@v1.1.0 has a go.mod
 		// file that establishes that path as canonical. But v1.0.6 does not have a
 		// go.mod file. So the miscapitalized path github.com/Sirupsen/logrus at
-		// v1.1.0 is marked as an alternative path (code 491) by
+		// v1.1.0 is marked as an alternative path (modstatus.AlternativeModule) by
 		// internal/fetch.FetchModule and is not inserted into the DB, but at
 		// v1.0.6 it is considered valid, and we end up here. We still insert
 		// github.com/Sirupsen/logrus@v1.0.6 in the modules table and friends so
@@ -141,12 +183,12 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 		//
 		// Note that we end up here only if we first saw the alternative version
 		// (github.com/Sirupsen/logrus@v1.1.0 in the example) and then see the valid
-		// one. The "if code == 491" section of internal/worker.fetchAndUpdateState
+		// one. The alternative module section of internal/worker.fetchAndUpdateState
 		// handles the case where we fetch the versions in the other order.
 		row := tx.QueryRow(ctx, `
 			SELECT 1 FROM module_version_states
-			WHERE module_path = $1 AND sort_version > $2 and status = 491`,
-			m.ModulePath, version.ForSorting(m.Version))
+			WHERE module_path = $1 AND sort_version > $2 and status = $3`,
+			m.ModulePath, version.ForSorting(m.Version), modstatus.AlternativeModule)
 		var x int
 		if err := row.Scan(&x); err != sql.ErrNoRows {
 			log.Infof(ctx, "%s@%s: not inserting into search documents", m.ModulePath, m.Version)
@@ -158,66 +200,112 @@ func (db *DB) saveModule(ctx context.Context, m *internal.Module) (err error) {
 }
 
 func insertModule(ctx context.Context, db *database.DB, m *internal.Module) (_ int, err error) {
-	ctx, span := trace.StartSpan(ctx, "insertModule")
+	ctx, span := dtrace.StartSpan(ctx, "insertModule")
+	ctx = database.WithQueryName(ctx, "insertModule")
 	defer span.End()
 	defer derrors.Wrap(&err, "insertModule(ctx, %q, %q)", m.ModulePath, m.Version)
 	sourceInfoJSON, err := json.Marshal(m.SourceInfo)
 	if err != nil {
 		return 0, err
 	}
-	var moduleID int
-	err = db.QueryRow(ctx,
-		`INSERT INTO modules(
-			module_path,
-			version,
-			commit_time,
-			readme_file_path,
-			readme_contents,
-			sort_version,
-			version_type,
-			series_path,
-			source_info,
-			redistributable,
-			has_go_mod)
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10, $11)
-		ON CONFLICT
-			(module_path, version)
-		DO UPDATE SET
-			readme_file_path=excluded.readme_file_path,
-			readme_contents=excluded.readme_contents,
-			source_info=excluded.source_info,
-			redistributable=excluded.redistributable
-		RETURNING id`,
+	readme := compressText(ctx, m.LegacyReadmeContents)
+	var publishedAt interface{}
+	if !m.PublishedAt.IsZero() {
+		publishedAt = m.PublishedAt
+	}
+	appVersion := nullAppVersion(m)
+	moduleCols := []string{
+		"module_path",
+		"version",
+		"commit_time",
+		"readme_file_path",
+		"readme_contents",
+		"sort_version",
+		"version_type",
+		"series_path",
+		"source_info",
+		"redistributable",
+		"has_go_mod",
+		"published_at",
+		"app_version",
+	}
+	updateCols := []string{
+		"readme_file_path",
+		"readme_contents",
+		"source_info",
+		"redistributable",
+		"app_version",
+	}
+	moduleValues := []interface{}{
 		m.ModulePath,
 		m.Version,
 		m.CommitTime,
 		m.LegacyReadmeFilePath,
-		makeValidUnicode(m.LegacyReadmeContents),
+		readme,
 		version.ForSorting(m.Version),
 		m.VersionType,
 		m.SeriesPath(),
 		sourceInfoJSON,
 		m.IsRedistributable,
 		m.HasGoMod,
-	).Scan(&moduleID)
+		publishedAt,
+		appVersion,
+	}
+	var moduleID int
+	err = db.BulkUpsertReturning(ctx, "modules", moduleCols, moduleValues,
+		[]string{"module_path", "version"}, updateCols, []string{"id"},
+		func(rows *sql.Rows) error { return rows.Scan(&moduleID) })
 	if err != nil {
 		return 0, err
 	}
+	// The module index is usually polled before the module is processed, so
+	// its timestamp for the version may already be known.
+	if _, err := db.Exec(ctx, `
+		UPDATE modules m
+		SET published_at = s.index_timestamp
+		FROM module_version_states s
+		WHERE m.id = $1
+		AND m.published_at IS NULL
+		AND s.module_path = m.module_path
+		AND s.version = m.version
+		AND s.index_timestamp > $2`,
+		moduleID, time.Time{}); err != nil {
+		return 0, err
+	}
 	return moduleID, nil
 }
 
 func insertLicenses(ctx context.Context, db *database.DB, m *internal.Module, moduleID int) (err error) {
-	ctx, span := trace.StartSpan(ctx, "insertLicenses")
+	ctx, span := dtrace.StartSpan(ctx, "insertLicenses")
+	ctx = database.WithQueryName(ctx, "insertLicenses")
 	defer span.End()
 	defer derrors.Wrap(&err, "insertLicenses(ctx, %q, %q)", m.ModulePath, m.Version)
+	appVersion := nullAppVersion(m)
 	var licenseValues []interface{}
+	licensePaths := []string{} // not nil, which pq.Array converts to NULL
 	for _, l := range m.Licenses {
 		covJSON, err := json.Marshal(l.Coverage)
 		if err != nil {
 			return fmt.Errorf("marshalling %+v: %v", l.Coverage, err)
 		}
 		licenseValues = append(licenseValues, m.ModulePath, m.Version,
-			l.FilePath, makeValidUnicode(string(l.Contents)), pq.Array(l.Types), covJSON, moduleID)
+			l.FilePath, compressText(ctx, string(l.Contents)), pq.Array(l.Types), l.Expression, covJSON,
+			l.Coverage.Percent, l.IsLowConfidence(), licenses.KindLicense, moduleID, appVersion)
+		licensePaths = append(licensePaths, l.FilePath)
+	}
+	for _, n := range m.Notices {
+		licenseValues = append(licenseValues, m.ModulePath, m.Version,
+			n.FilePath, compressText(ctx, string(n.Contents)), pq.Array([]string{}), "", "{}",
+			nil, false, n.Kind, moduleID, appVersion)
+		licensePaths = append(licensePaths, n.FilePath)
+	}
+	// Remove licenses from a previous insertion of this module version that
+	// are no longer present; the others are updated in place below.
+	if _, err := db.Exec(ctx, `
+		DELETE FROM licenses
+		WHERE module_path = $1 AND version = $2 AND NOT (file_path = ANY($3))`,
+		m.ModulePath, m.Version, pq.Array(licensePaths)); err != nil {
+		return err
 	}
 	if len(licenseValues) > 0 {
 		licenseCols := []string{
@@ -226,17 +314,23 @@ func insertLicenses(ctx context.Context, db *database.DB, m *internal.Module, mo
 			"file_path",
 			"contents",
 			"types",
+			"expression",
 			"coverage",
+			"coverage_percent",
+			"low_confidence",
+			"kind",
 			"module_id",
+			"app_version",
 		}
 		return db.BulkUpsert(ctx, "licenses", licenseCols, licenseValues,
-			[]string{"module_path", "version", "file_path"})
+			[]string{"module_path", "version", "file_path"}, nil)
 	}
 	return nil
 }
 
-func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (err error) {
-	ctx, span := trace.StartSpan(ctx, "insertPackages")
+func insertPackages(ctx context.Context, db *database.DB, m *internal.Module, blobs *blobStore) (err error) {
+	ctx, span := dtrace.StartSpan(ctx, "insertPackages")
+	ctx = database.WithQueryName(ctx, "insertPackages")
 	defer span.End()
 	defer derrors.Wrap(&err, "insertPackages(ctx, %q, %q)", m.ModulePath, m.Version)
 
@@ -255,7 +349,8 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 	for _, p := range m.LegacyPackages {
 		sort.Strings(p.Imports)
 	}
-	var pkgValues, importValues []interface{}
+	appVersion := nullAppVersion(m)
+	var pkgValues, importValues, findingValues []interface{}
 	for _, p := range m.LegacyPackages {
 		if p.DocumentationHTML == internal.StringFieldMissing {
 			return errors.New("saveModule: package missing DocumentationHTML")
@@ -276,6 +371,10 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 				}
 			}
 		}
+		html, htmlKey, err := blobs.store(ctx, m.ModulePath, m.Version, p.Path, p.GOOS, p.GOARCH, makeValidUnicode(p.DocumentationHTML))
+		if err != nil {
+			return err
+		}
 		pkgValues = append(pkgValues,
 			p.Path,
 			p.Synopsis,
@@ -284,16 +383,23 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 			m.ModulePath,
 			p.V1Path,
 			p.IsRedistributable,
-			makeValidUnicode(p.DocumentationHTML),
+			compressText(ctx, html),
+			htmlKey,
+			p.DocumentationCoverage,
 			pq.Array(licenseTypes),
 			pq.Array(licensePaths),
 			p.GOOS,
 			p.GOARCH,
 			m.CommitTime,
+			pq.Array(p.Symbols),
+			appVersion,
 		)
 		for _, i := range p.Imports {
 			importValues = append(importValues, p.Path, m.ModulePath, m.Version, i)
 		}
+		for _, f := range p.Findings {
+			findingValues = append(findingValues, p.Path, m.ModulePath, m.Version, f.Analyzer, f.Category, f.Count)
+		}
 	}
 	if len(pkgValues) > 0 {
 		uniqueCols := []string{"path", "module_path", "version"}
@@ -306,13 +412,17 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 			"v1_path",
 			"redistributable",
 			"documentation",
+			"documentation_blob_key",
+			"documentation_coverage",
 			"license_types",
 			"license_paths",
 			"goos",
 			"goarch",
 			"commit_time",
+			"exported_symbols",
+			"app_version",
 		}
-		if err := db.BulkUpsert(ctx, "packages", pkgCols, pkgValues, uniqueCols); err != nil {
+		if err := db.BulkUpsert(ctx, "packages", pkgCols, pkgValues, uniqueCols, nil); err != nil {
 			return err
 		}
 	}
@@ -324,7 +434,27 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 			"from_version",
 			"to_path",
 		}
-		if err := db.BulkUpsert(ctx, "imports", importCols, importValues, importCols); err != nil {
+		if err := db.BulkUpsert(ctx, "imports", importCols, importValues, importCols, nil); err != nil {
+			return err
+		}
+	}
+
+	// Replace the findings of earlier fetches of this version, since the
+	// analyzers or their limits may have changed.
+	if _, err := db.Exec(ctx, `DELETE FROM package_findings WHERE module_path = $1 AND version = $2`,
+		m.ModulePath, m.Version); err != nil {
+		return err
+	}
+	if len(findingValues) > 0 {
+		findingCols := []string{
+			"package_path",
+			"module_path",
+			"version",
+			"analyzer",
+			"category",
+			"count",
+		}
+		if err := db.BulkInsert(ctx, "package_findings", findingCols, findingValues, ""); err != nil {
 			return err
 		}
 	}
@@ -334,7 +464,8 @@ func insertPackages(ctx context.Context, db *database.DB, m *internal.Module) (e
 // insertImportsUnique inserts and removes rows from the imports_unique table. It should only
 // be called if the given module's version is the latest.
 func insertImportsUnique(ctx context.Context, tx *database.DB, m *internal.Module) (err error) {
-	ctx, span := trace.StartSpan(ctx, "insertImportsUnique")
+	ctx, span := dtrace.StartSpan(ctx, "insertImportsUnique")
+	ctx = database.WithQueryName(ctx, "insertImportsUnique")
 	defer span.End()
 	defer derrors.Wrap(&err, "insertImportsUnique(%q, %q)", m.ModulePath, m.Version)
 
@@ -349,19 +480,28 @@ func insertImportsUnique(ctx context.Context, tx *database.DB, m *internal.Modul
 	var values []interface{}
 	for _, p := range m.LegacyPackages {
 		for _, i := range p.Imports {
-			values = append(values, p.Path, m.ModulePath, i)
+			// Symbols is NULL if the imported symbols are not known.
+			var symbols []string
+			if p.ImportedSymbols != nil {
+				symbols = p.ImportedSymbols[i]
+				if symbols == nil {
+					symbols = []string{}
+				}
+			}
+			values = append(values, p.Path, m.ModulePath, i, pq.Array(symbols))
 		}
 	}
 	if len(values) == 0 {
 		return nil
 	}
-	cols := []string{"from_path", "from_module_path", "to_path"}
-	return tx.BulkUpsert(ctx, "imports_unique", cols, values, cols)
+	cols := []string{"from_path", "from_module_path", "to_path", "symbols"}
+	return tx.BulkUpsert(ctx, "imports_unique", cols, values, cols[:3], nil)
 }
 
-func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module, moduleID int) (err error) {
+func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module, moduleID int, blobs *blobStore) (err error) {
 	defer derrors.Wrap(&err, "insertDirectories(ctx, tx, %q, %q)", m.ModulePath, m.Version)
-	ctx, span := trace.StartSpan(ctx, "insertDirectories")
+	ctx, span := dtrace.StartSpan(ctx, "insertDirectories")
+	ctx = database.WithQueryName(ctx, "insertDirectories")
 	defer span.End()
 
 	if m.LegacyReadmeContents == internal.StringFieldMissing {
@@ -446,7 +586,7 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 
 		uniqueCols := []string{"path", "module_id"}
 		returningCols := []string{"id", "path"}
-		if err := db.BulkUpsertReturning(ctx, "paths", pathCols, pathValues, uniqueCols, returningCols, func(rows *sql.Rows) error {
+		if err := db.BulkUpsertReturning(ctx, "paths", pathCols, pathValues, uniqueCols, nil, returningCols, func(rows *sql.Rows) error {
 			var (
 				pathID int
 				path   string
@@ -476,10 +616,10 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 				continue
 			}
 			id := pathToID[path]
-			readmeValues = append(readmeValues, id, readme.Filepath, makeValidUnicode(readme.Contents))
+			readmeValues = append(readmeValues, id, readme.Filepath, compressText(ctx, readme.Contents), nullAppVersion(m))
 		}
-		readmeCols := []string{"path_id", "file_path", "contents"}
-		if err := db.BulkUpsert(ctx, "readmes", readmeCols, readmeValues, []string{"path_id"}); err != nil {
+		readmeCols := []string{"path_id", "file_path", "contents", "app_version"}
+		if err := db.BulkUpsert(ctx, "readmes", readmeCols, readmeValues, []string{"path_id"}, nil); err != nil {
 			return err
 		}
 	}
@@ -493,11 +633,15 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 				continue
 			}
 			id := pathToID[path]
-			docValues = append(docValues, id, doc.GOOS, doc.GOARCH, doc.Synopsis, makeValidUnicode(doc.HTML))
+			html, htmlKey, err := blobs.store(ctx, m.ModulePath, m.Version, path, doc.GOOS, doc.GOARCH, makeValidUnicode(doc.HTML))
+			if err != nil {
+				return err
+			}
+			docValues = append(docValues, id, doc.GOOS, doc.GOARCH, doc.Synopsis, compressText(ctx, html), htmlKey, doc.Coverage, nullAppVersion(m))
 		}
 		uniqueCols := []string{"path_id", "goos", "goarch"}
-		docCols := append(uniqueCols, "synopsis", "html")
-		if err := db.BulkUpsert(ctx, "documentation", docCols, docValues, uniqueCols); err != nil {
+		docCols := append(uniqueCols, "synopsis", "html", "html_blob_key", "coverage", "app_version")
+		if err := db.BulkUpsert(ctx, "documentation", docCols, docValues, uniqueCols, nil); err != nil {
 			return err
 		}
 	}
@@ -515,7 +659,7 @@ func insertDirectories(ctx context.Context, db *database.DB, m *internal.Module,
 		}
 	}
 	importCols := []string{"path_id", "to_path"}
-	return db.BulkUpsert(ctx, "package_imports", importCols, importValues, importCols)
+	return db.BulkUpsert(ctx, "package_imports", importCols, importValues, importCols, nil)
 }
 
 // lock obtains an exclusive, transaction-scoped advisory lock on modulePath.
@@ -687,27 +831,113 @@ func removeNonDistributableData(m *internal.Module) {
 	}
 }
 
+// deleteBatchSize is the maximum number of rows that DeleteModule deletes
+// from a table in a single statement.
+// It is a variable for testing.
+var deleteBatchSize = 1000
+
 // DeleteModule deletes a Version from the database.
+//
+// The rows of the largest tables that depend on the module are deleted in
+// batches before the module itself, to keep transactions small. If
+// DeleteModule fails, the module may be left partially deleted; calling it
+// again will finish the job.
 func (db *DB) DeleteModule(ctx context.Context, modulePath, version string) (err error) {
 	defer derrors.Wrap(&err, "DeleteModule(ctx, db, %q, %q)", modulePath, version)
+	ctx = database.WithQueryName(ctx, "DeleteModule")
+
+	var (
+		moduleID int
+		paths    []string
+		blobKeys []string
+	)
+	err = db.db.QueryRow(ctx, `SELECT id FROM modules WHERE module_path=$1 AND version=$2`,
+		modulePath, version).Scan(&moduleID)
+	switch err {
+	case sql.ErrNoRows:
+		// Nothing depends on a module that doesn't exist.
+	case nil:
+		// Collect the paths of the module for the module hooks before
+		// they are deleted.
+		err = db.db.RunQuery(ctx, `
+			SELECT path FROM paths WHERE module_id = $1
+			UNION
+			SELECT path FROM packages WHERE module_path = $2 AND version = $3`,
+			func(rows *sql.Rows) error {
+				var p string
+				if err := rows.Scan(&p); err != nil {
+					return err
+				}
+				paths = append(paths, p)
+				return nil
+			}, moduleID, modulePath, version)
+		if err != nil {
+			return err
+		}
+		blobKeys, err = db.blobKeys(ctx, moduleID, modulePath, version)
+		if err != nil {
+			return err
+		}
+		if err := deleteModuleChildren(ctx, db.db, modulePath, version, moduleID); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+	defer func() {
+		if err == nil {
+			db.deleteBlobs(ctx, blobKeys)
+			db.runModuleHooks(ctx, modulePath, paths)
+		}
+	}()
 	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
-		// We only need to delete from the modules table. Thanks to ON DELETE
-		// CASCADE constraints, that will trigger deletions from all other tables.
+		// ON DELETE CASCADE constraints will delete the remaining rows that
+		// depend on the module, like its licenses.
 		const stmt = `DELETE FROM modules WHERE module_path=$1 AND version=$2`
-		if _, err := db.db.Exec(ctx, stmt, modulePath, version); err != nil {
+		if _, err := tx.Exec(ctx, stmt, modulePath, version); err != nil {
 			return err
 		}
 		var x int
-		err = db.db.QueryRow(ctx, `SELECT 1 FROM modules WHERE module_path=$1 LIMIT 1`, modulePath).Scan(&x)
+		err = tx.QueryRow(ctx, `SELECT 1 FROM modules WHERE module_path=$1 LIMIT 1`, modulePath).Scan(&x)
 		if err != sql.ErrNoRows || err == nil {
 			return err
 		}
 		// No versions of this module exist; remove it from imports_unique.
-		_, err = db.db.Exec(ctx, `DELETE FROM imports_unique WHERE from_module_path = $1`, modulePath)
+		_, err = tx.Exec(ctx, `DELETE FROM imports_unique WHERE from_module_path = $1`, modulePath)
 		return err
 	})
 }
 
+// deleteModuleChildren deletes the rows that depend on the module with the
+// given path, version and ID in batches, children before parents.
+func deleteModuleChildren(ctx context.Context, db *database.DB, modulePath, version string, moduleID int) error {
+	const (
+		byPathID   = `path_id IN (SELECT id FROM paths WHERE module_id = $1)`
+		byModule   = `module_path = $1 AND version = $2`
+		byImporter = `from_path IN (SELECT path FROM packages WHERE module_path = $1 AND version = $2)
+			AND from_module_path = $1 AND from_version = $2`
+	)
+	for _, d := range []struct {
+		table, where string
+		args         []interface{}
+	}{
+		{"documentation", byPathID, []interface{}{moduleID}},
+		{"package_imports", byPathID, []interface{}{moduleID}},
+		{"readmes", byPathID, []interface{}{moduleID}},
+		{"paths", `module_id = $1`, []interface{}{moduleID}},
+		{"imports", byImporter, []interface{}{modulePath, version}},
+		{"package_findings", byModule, []interface{}{modulePath, version}},
+		{"packages", byModule, []interface{}{modulePath, version}},
+	} {
+		n, err := db.BulkDelete(ctx, d.table, d.where, deleteBatchSize, d.args...)
+		if err != nil {
+			return err
+		}
+		log.Debugf(ctx, "DeleteModule(%q, %q): deleted %d rows from %s", modulePath, version, n, d.table)
+	}
+	return nil
+}
+
 // makeValidUnicode removes null runes from a string that will be saved in a
 // column of type TEXT, because pq doesn't like them. It also replaces non-unicode
 // characters with the Unicode replacement character, which is the behavior of
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"path/filepath"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -173,6 +174,40 @@ func TestUpsertModule(t *testing.T) {
 	checkModule(ctx, t, m)
 }
 
+func TestUpsertModuleRemovedLicense(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.DefaultModule()
+	extra := &licenses.License{
+		Metadata: &licenses.Metadata{Types: []string{"MIT"}, FilePath: "dir/LICENSE"},
+		Contents: []byte("extra license"),
+	}
+	m.Licenses = append(m.Licenses, extra)
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	// Re-insert the module without the extra license.
+	m.Licenses = m.Licenses[:len(m.Licenses)-1]
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.LegacyGetModuleLicenses(ctx, m.ModulePath, m.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(m.Licenses) {
+		t.Errorf("got %d licenses, want %d", len(got), len(m.Licenses))
+	}
+	for _, l := range got {
+		if l.FilePath == extra.FilePath {
+			t.Errorf("license %q was not removed", l.FilePath)
+		}
+	}
+}
+
 func TestInsertModuleErrors(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout*2)
 	defer cancel()
@@ -285,12 +320,67 @@ func TestPostgres_ReadAndWriteModuleOtherColumns(t *testing.T) {
 	}
 }
 
+func TestPublishedAt(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	published := time.Date(2020, 3, 4, 5, 6, 7, 0, time.UTC)
+	getPublishedAt := func(modulePath, version string) time.Time {
+		t.Helper()
+		mi, err := testDB.LegacyGetModuleInfo(ctx, modulePath, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mi.PublishedAt
+	}
+
+	// A version reported by the index before it is processed.
+	before := sample.Module("example.com/before", sample.VersionString, "")
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: before.ModulePath, Version: before.Version, Timestamp: published}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.InsertModule(ctx, before); err != nil {
+		t.Fatal(err)
+	}
+	if got := getPublishedAt(before.ModulePath, before.Version); !got.Equal(published) {
+		t.Errorf("reported before processing: got %s, want %s", got, published)
+	}
+
+	// A version processed before the index reports it.
+	after := sample.Module("example.com/after", sample.VersionString, "")
+	if err := testDB.InsertModule(ctx, after); err != nil {
+		t.Fatal(err)
+	}
+	if got := getPublishedAt(after.ModulePath, after.Version); !got.IsZero() {
+		t.Errorf("not reported: got %s, want the zero time", got)
+	}
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: after.ModulePath, Version: after.Version, Timestamp: published}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getPublishedAt(after.ModulePath, after.Version); !got.Equal(published) {
+		t.Errorf("reported after processing: got %s, want %s", got, published)
+	}
+
+	// A later report does not change the publish time.
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: after.ModulePath, Version: after.Version, Timestamp: published.Add(time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := getPublishedAt(after.ModulePath, after.Version); !got.Equal(published) {
+		t.Errorf("reported again: got %s, want %s", got, published)
+	}
+}
+
 func TestDeleteModule(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
 	defer ResetTestDB(testDB, t)
 
-	v := sample.DefaultModule()
+	// Delete one row at a time, to exercise batching.
+	defer func(old int) { deleteBatchSize = old }(deleteBatchSize)
+	deleteBatchSize = 1
+
+	v := sample.Module(sample.ModulePath, sample.VersionString, "a", "b", "b/c")
 	if err := testDB.InsertModule(ctx, v); err != nil {
 		t.Fatal(err)
 	}
@@ -309,9 +399,53 @@ func TestDeleteModule(t *testing.T) {
 	if err != sql.ErrNoRows {
 		t.Errorf("imports_unique: got %v, want ErrNoRows", err)
 	}
+	for _, table := range []string{"packages", "imports", "paths", "documentation", "readmes", "package_imports", "licenses"} {
+		var n int
+		if err := testDB.Underlying().QueryRow(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("%s: got %d rows, want 0", table, n)
+		}
+	}
+	// Deleting a module that doesn't exist is not an error.
+	if err := testDB.DeleteModule(ctx, v.ModulePath, v.Version); err != nil {
+		t.Fatal(err)
+	}
 	// TODO(golang/go#39633): check removal from version_map
 }
 
+func TestModuleHooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	type call struct {
+		ModulePath string
+		Paths      []string
+	}
+	var calls []call
+	// Use a separate DB, so the hook doesn't affect other tests.
+	db := New(testDB.db)
+	db.AddModuleHook(func(_ context.Context, modulePath string, paths []string) {
+		sort.Strings(paths)
+		calls = append(calls, call{modulePath, paths})
+	})
+
+	m := sample.Module(sample.ModulePath, sample.VersionString, "a", "b")
+	if err := db.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteModule(ctx, m.ModulePath, m.Version); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{sample.ModulePath, sample.ModulePath + "/a", sample.ModulePath + "/b"}
+	want := []call{{sample.ModulePath, paths}, {sample.ModulePath, paths}}
+	if diff := cmp.Diff(want, calls); diff != "" {
+		t.Errorf("hook calls mismatch (-want +got):\n%s", diff)
+	}
+}
+
 func TestPostgres_NewerAlternative(t *testing.T) {
 	// Verify that packages are not added to search_documents if the module has a newer
 	// alternative version.
@@ -325,8 +459,15 @@ func TestPostgres_NewerAlternative(t *testing.T) {
 		okVersion  = "v1.0.0"
 	)
 
-	err := testDB.UpsertModuleVersionState(ctx, modulePath, altVersion, "appVersion", time.Now(),
-		derrors.ToHTTPStatus(derrors.AlternativeModule), "example.com/mod", derrors.AlternativeModule, nil)
+	err := testDB.UpsertModuleVersionState(ctx, &internal.ModuleVersionStateForUpsert{
+		ModulePath: modulePath,
+		Version:    altVersion,
+		AppVersion: "appVersion",
+		Timestamp:  time.Now(),
+		Status:     derrors.ToHTTPStatus(derrors.AlternativeModule),
+		GoModPath:  "example.com/mod",
+		FetchErr:   derrors.AlternativeModule,
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
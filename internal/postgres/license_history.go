@@ -0,0 +1,174 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
+)
+
+// UpdateLicenseHistory compares the license types detected in
+// modulePath@version with those of the versions immediately before and after
+// it, and records any differences in the license_history table.
+//
+// Both neighbors are considered because versions are not necessarily
+// processed in order: inserting a version between two others changes what the
+// later one is compared against.
+func (db *DB) UpdateLicenseHistory(ctx context.Context, modulePath, version string) (err error) {
+	defer derrors.Wrap(&err, "UpdateLicenseHistory(ctx, %q, %q)", modulePath, version)
+
+	ctx, span := dtrace.StartSpan(ctx, "UpdateLicenseHistory", dtrace.ModulePath(modulePath), dtrace.Version(version))
+	ctx = database.WithQueryName(ctx, "UpdateLicenseHistory")
+	defer dtrace.End(span, &err)
+
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if err := updateLicenseHistory(ctx, tx, modulePath, version); err != nil {
+			return err
+		}
+		next, err := adjacentVersion(ctx, tx, modulePath, version, false)
+		if err != nil || next == "" {
+			return err
+		}
+		return updateLicenseHistory(ctx, tx, modulePath, next)
+	})
+}
+
+// updateLicenseHistory sets the license_history row for modulePath@version,
+// deleting it if the version's license types are the same as those of the
+// previous version, or if there is no previous version.
+func updateLicenseHistory(ctx context.Context, tx *database.DB, modulePath, version string) error {
+	prev, err := adjacentVersion(ctx, tx, modulePath, version, true)
+	if err != nil {
+		return err
+	}
+	var added, removed []string
+	if prev != "" {
+		cur, err := licenseTypesForVersion(ctx, tx, modulePath, version)
+		if err != nil {
+			return err
+		}
+		old, err := licenseTypesForVersion(ctx, tx, modulePath, prev)
+		if err != nil {
+			return err
+		}
+		added, removed = diffLicenseTypes(old, cur)
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		_, err := tx.Exec(ctx, `
+			DELETE FROM license_history WHERE module_path = $1 AND version = $2`,
+			modulePath, version)
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO license_history (module_path, version, previous_version, added_types, removed_types)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (module_path, version)
+		DO UPDATE SET
+			previous_version = excluded.previous_version,
+			added_types = excluded.added_types,
+			removed_types = excluded.removed_types,
+			created_at = CURRENT_TIMESTAMP`,
+		modulePath, version, prev, pq.Array(added), pq.Array(removed))
+	return err
+}
+
+// adjacentVersion returns the version of modulePath that comes immediately
+// before version if before is true, or immediately after it otherwise. It
+// returns the empty string if there is no such version.
+func adjacentVersion(ctx context.Context, tx *database.DB, modulePath, version string, before bool) (string, error) {
+	cmp, order := ">", "ASC"
+	if before {
+		cmp, order = "<", "DESC"
+	}
+	var adj string
+	err := tx.QueryRow(ctx, `
+		SELECT version
+		FROM modules
+		WHERE module_path = $1
+		AND sort_version `+cmp+` (
+			SELECT sort_version FROM modules WHERE module_path = $1 AND version = $2
+		)
+		ORDER BY sort_version `+order+`
+		LIMIT 1`,
+		modulePath, version).Scan(&adj)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return adj, err
+}
+
+// licenseTypesForVersion returns the sorted set of license types detected in
+// all the license files of modulePath@version.
+func licenseTypesForVersion(ctx context.Context, tx *database.DB, modulePath, version string) ([]string, error) {
+	var types []string
+	err := tx.RunQuery(ctx, `
+		SELECT DISTINCT unnest(types)
+		FROM licenses
+		WHERE module_path = $1 AND version = $2
+		ORDER BY 1`,
+		func(rows *sql.Rows) error {
+			var t string
+			if err := rows.Scan(&t); err != nil {
+				return err
+			}
+			types = append(types, t)
+			return nil
+		}, modulePath, version)
+	return types, err
+}
+
+// diffLicenseTypes returns the elements of cur that are not in old, and
+// those of old that are not in cur, in sorted order.
+func diffLicenseTypes(old, cur []string) (added, removed []string) {
+	// The columns of license_history are NOT NULL, and pq.Array converts a
+	// nil slice to NULL.
+	added, removed = []string{}, []string{}
+	inOld := map[string]bool{}
+	for _, t := range old {
+		inOld[t] = true
+	}
+	inCur := map[string]bool{}
+	for _, t := range cur {
+		inCur[t] = true
+		if !inOld[t] {
+			added = append(added, t)
+		}
+	}
+	for _, t := range old {
+		if !inCur[t] {
+			removed = append(removed, t)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// GetLicenseChanges returns the recorded license changes of modulePath,
+// latest version first.
+func (db *DB) GetLicenseChanges(ctx context.Context, modulePath string) (_ []*internal.LicenseChange, err error) {
+	defer derrors.Wrap(&err, "GetLicenseChanges(ctx, %q)", modulePath)
+
+	query := `
+		SELECT h.module_path, h.version, h.previous_version,
+			h.added_types AS added, h.removed_types AS removed
+		FROM license_history h
+		INNER JOIN modules m
+		ON h.module_path = m.module_path AND h.version = m.version
+		WHERE h.module_path = $1
+		ORDER BY m.sort_version DESC`
+	var changes []*internal.LicenseChange
+	if err := db.db.CollectStructs(ctx, &changes, query, modulePath); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
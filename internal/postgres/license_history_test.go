@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestUpdateLicenseHistory(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	const modulePath = "example.com/lic"
+	insert := func(version string, types ...string) {
+		t.Helper()
+		m := sample.Module(modulePath, version, "pkg")
+		m.Licenses = []*licenses.License{{
+			Metadata: &licenses.Metadata{Types: types, FilePath: "LICENSE"},
+			Contents: []byte("license"),
+		}}
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+		if err := testDB.UpdateLicenseHistory(ctx, modulePath, version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(want []*internal.LicenseChange) {
+		t.Helper()
+		got, err := testDB.GetLicenseChanges(ctx, modulePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("mismatch (-want +got):\n%s", diff)
+		}
+	}
+
+	insert("v1.0.0", "MIT")
+	check(nil)
+	insert("v1.2.0", "Apache-2.0")
+	check([]*internal.LicenseChange{
+		{ModulePath: modulePath, Version: "v1.2.0", PreviousVersion: "v1.0.0", Added: []string{"Apache-2.0"}, Removed: []string{"MIT"}},
+	})
+	// Inserting a version in between changes what v1.2.0 is compared against.
+	insert("v1.1.0", "Apache-2.0", "MIT")
+	check([]*internal.LicenseChange{
+		{ModulePath: modulePath, Version: "v1.2.0", PreviousVersion: "v1.1.0", Removed: []string{"MIT"}},
+		{ModulePath: modulePath, Version: "v1.1.0", PreviousVersion: "v1.0.0", Added: []string{"Apache-2.0"}},
+	})
+	// Reprocessing v1.1.0 with the licenses of v1.2.0 moves the change.
+	insert("v1.1.0", "Apache-2.0")
+	check([]*internal.LicenseChange{
+		{ModulePath: modulePath, Version: "v1.1.0", PreviousVersion: "v1.0.0", Added: []string{"Apache-2.0"}, Removed: []string{"MIT"}},
+	})
+}
+
+func TestDiffLicenseTypes(t *testing.T) {
+	added, removed := diffLicenseTypes([]string{"BSD-3-Clause", "MIT"}, []string{"Apache-2.0", "MIT"})
+	if diff := cmp.Diff([]string{"Apache-2.0"}, added); diff != "" {
+		t.Errorf("added mismatch (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"BSD-3-Clause"}, removed); diff != "" {
+		t.Errorf("removed mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// A LicenseOverride is a manual decision about the redistributability of
+// everything at or below Path. It takes precedence over the decision made
+// from the detected licenses.
+type LicenseOverride struct {
+	// Path is a module path or path prefix.
+	Path string
+	// Version is the version the override applies to. If empty, the
+	// override applies to all versions.
+	Version           string
+	IsRedistributable bool
+	Reason            string
+	CreatedBy         string
+	CreatedAt         time.Time
+}
+
+// SetLicenseOverride creates or replaces the license override for o.Path and
+// o.Version. It does not change modules that have already been inserted; they
+// must be reprocessed for the override to take effect.
+func (db *DB) SetLicenseOverride(ctx context.Context, o *LicenseOverride) (err error) {
+	defer derrors.Wrap(&err, "SetLicenseOverride(ctx, %q, %q)", o.Path, o.Version)
+
+	if o.Path == "" || o.Reason == "" || o.CreatedBy == "" {
+		return fmt.Errorf("path, reason and user must be non-empty: %w", derrors.InvalidArgument)
+	}
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO license_overrides (path, version, is_redistributable, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (path, version) DO UPDATE SET
+			is_redistributable = excluded.is_redistributable,
+			reason = excluded.reason,
+			created_by = excluded.created_by,
+			created_at = CURRENT_TIMESTAMP`,
+		o.Path, o.Version, o.IsRedistributable, o.Reason, o.CreatedBy)
+	return err
+}
+
+// DeleteLicenseOverride deletes the license override for path and version. It
+// returns a derrors.NotFound error if there is no such override.
+func (db *DB) DeleteLicenseOverride(ctx context.Context, path, version string) (err error) {
+	defer derrors.Wrap(&err, "DeleteLicenseOverride(ctx, %q, %q)", path, version)
+
+	res, err := db.db.Exec(ctx, `DELETE FROM license_overrides WHERE path = $1 AND version = $2`, path, version)
+	if err != nil {
+		return err
+	}
+	return notFoundIfNoRows(res)
+}
+
+// GetLicenseOverrides returns all license overrides, ordered by path and
+// version.
+func (db *DB) GetLicenseOverrides(ctx context.Context) (_ []*LicenseOverride, err error) {
+	defer derrors.Wrap(&err, "GetLicenseOverrides(ctx)")
+	return getLicenseOverrides(ctx, db.db, `
+		SELECT path, version, is_redistributable, reason, created_by, created_at
+		FROM license_overrides
+		ORDER BY path, version`)
+}
+
+// GetLicenseOverride returns the most specific license override that applies
+// to path at version, or nil if there is none.
+func (db *DB) GetLicenseOverride(ctx context.Context, path, version string) (_ *LicenseOverride, err error) {
+	defer derrors.Wrap(&err, "GetLicenseOverride(ctx, %q, %q)", path, version)
+	overrides, err := db.licenseOverridesForVersion(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	return matchLicenseOverride(overrides, path), nil
+}
+
+// licenseOverridesForVersion returns the license overrides that apply to
+// version: those for that version and those for all versions.
+func (db *DB) licenseOverridesForVersion(ctx context.Context, version string) ([]*LicenseOverride, error) {
+	// The table is small, so read every override for this version and match
+	// paths here rather than with LIKE, which would treat underscores in
+	// paths as wildcards.
+	return getLicenseOverrides(ctx, db.db, `
+		SELECT path, version, is_redistributable, reason, created_by, created_at
+		FROM license_overrides
+		WHERE version = '' OR version = $1`, version)
+}
+
+func getLicenseOverrides(ctx context.Context, db *database.DB, query string, args ...interface{}) ([]*LicenseOverride, error) {
+	var overrides []*LicenseOverride
+	if err := db.CollectStructs(ctx, &overrides, query, args...); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// applyLicenseOverrides sets the redistributability of m, its packages and its
+// directories from the license overrides that apply to them, replacing the
+// values computed from the detected licenses.
+func (db *DB) applyLicenseOverrides(ctx context.Context, m *internal.Module) (err error) {
+	defer derrors.Wrap(&err, "applyLicenseOverrides(ctx, %q, %q)", m.ModulePath, m.Version)
+
+	overrides, err := db.licenseOverridesForVersion(ctx, m.Version)
+	if err != nil {
+		return err
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	apply := func(path string, isRedist *bool) {
+		if o := matchLicenseOverride(overrides, path); o != nil && o.IsRedistributable != *isRedist {
+			log.Infof(ctx, "%s@%s: license override for %q sets redistributable=%t: %s",
+				path, m.Version, o.Path, o.IsRedistributable, o.Reason)
+			*isRedist = o.IsRedistributable
+		}
+	}
+	apply(m.ModulePath, &m.IsRedistributable)
+	for _, p := range m.LegacyPackages {
+		apply(p.Path, &p.IsRedistributable)
+	}
+	for _, d := range m.Directories {
+		apply(d.Path, &d.IsRedistributable)
+	}
+	return nil
+}
+
+// matchLicenseOverride returns the most specific override in overrides that
+// applies to path, or nil if there is none. An override with a longer path is
+// more specific, and at the same path an override for a single version is more
+// specific than one for all versions. The overrides must already be
+// restricted to the version being matched.
+func matchLicenseOverride(overrides []*LicenseOverride, path string) *LicenseOverride {
+	var best *LicenseOverride
+	for _, o := range overrides {
+		if path != o.Path && !strings.HasPrefix(path, o.Path+"/") {
+			continue
+		}
+		if best == nil || len(o.Path) > len(best.Path) ||
+			(len(o.Path) == len(best.Path) && o.Version != "") {
+			best = o
+		}
+	}
+	return best
+}
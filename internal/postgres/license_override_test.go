@@ -0,0 +1,109 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestLicenseOverrides(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	const modulePath = "example.com/override"
+	for _, o := range []*LicenseOverride{
+		{Path: modulePath, IsRedistributable: false, Reason: "license is not OSI-approved", CreatedBy: "alice"},
+		{Path: modulePath + "/b", Version: "v1.0.0", IsRedistributable: true, Reason: "confirmed MIT", CreatedBy: "bob"},
+	} {
+		if err := testDB.SetLicenseOverride(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := sample.Module(modulePath, "v1.0.0", "a", "b")
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	mi, err := testDB.LegacyGetModuleInfo(ctx, modulePath, "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mi.IsRedistributable {
+		t.Errorf("module: IsRedistributable = true, want false")
+	}
+	for _, test := range []struct {
+		pkg  string
+		want bool
+	}{
+		{modulePath + "/a", false},
+		{modulePath + "/b", true},
+	} {
+		pkg, err := testDB.LegacyGetPackage(ctx, test.pkg, modulePath, "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pkg.IsRedistributable != test.want {
+			t.Errorf("%s: IsRedistributable = %t, want %t", test.pkg, pkg.IsRedistributable, test.want)
+		}
+		o, err := testDB.GetLicenseOverride(ctx, test.pkg+"/internal", "v1.0.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if o == nil || o.IsRedistributable != test.want {
+			t.Errorf("GetLicenseOverride(%q) = %+v, want IsRedistributable %t", test.pkg+"/internal", o, test.want)
+		}
+	}
+	if o, err := testDB.GetLicenseOverride(ctx, "example.com/other", "v1.0.0"); err != nil || o != nil {
+		t.Errorf("GetLicenseOverride for an unrelated path = %+v, %v; want nil, nil", o, err)
+	}
+
+	if err := testDB.DeleteLicenseOverride(ctx, modulePath, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.DeleteLicenseOverride(ctx, modulePath, ""); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("second delete: got %v, want NotFound", err)
+	}
+	got, err := testDB.GetLicenseOverrides(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Path != modulePath+"/b" {
+		t.Errorf("GetLicenseOverrides after delete = %v, want only %s/b", got, modulePath)
+	}
+}
+
+func TestMatchLicenseOverride(t *testing.T) {
+	overrides := []*LicenseOverride{
+		{Path: "example.com/m"},
+		{Path: "example.com/m/internal"},
+		{Path: "example.com/m/internal", Version: "v1.2.3"},
+		{Path: "example.com/m_x"},
+	}
+	for _, test := range []struct {
+		path string
+		want *LicenseOverride
+	}{
+		{"example.com/m", overrides[0]},
+		{"example.com/m/a", overrides[0]},
+		{"example.com/m/internal/b", overrides[2]},
+		{"example.com/mx", nil},
+		{"example.com/m_x/c", overrides[3]},
+		{"example.com", nil},
+	} {
+		got := matchLicenseOverride(overrides, test.path)
+		if diff := cmp.Diff(test.want, got, cmpopts.EquateEmpty()); diff != "" {
+			t.Errorf("matchLicenseOverride(%q) mismatch (-want +got):\n%s", test.path, diff)
+		}
+	}
+}
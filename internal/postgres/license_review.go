@@ -0,0 +1,112 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
+)
+
+// LowConfidenceLicense is a license file whose types were determined from
+// less than 90% coverage by licensecheck.
+type LowConfidenceLicense struct {
+	ModulePath      string
+	Version         string
+	FilePath        string
+	Types           []string
+	CoveragePercent float64
+}
+
+// GetLowConfidenceLicenses returns up to limit low-confidence license files,
+// lowest coverage first.
+func (db *DB) GetLowConfidenceLicenses(ctx context.Context, limit int) (_ []*LowConfidenceLicense, err error) {
+	defer derrors.Wrap(&err, "GetLowConfidenceLicenses(ctx, %d)", limit)
+
+	query := `
+		SELECT module_path, version, file_path, types, coverage_percent
+		FROM licenses
+		WHERE low_confidence
+		ORDER BY coverage_percent, module_path, version, file_path
+		LIMIT $1`
+	var lics []*LowConfidenceLicense
+	if err := db.db.CollectStructs(ctx, &lics, query, limit); err != nil {
+		return nil, err
+	}
+	return lics, nil
+}
+
+// LicenseReview is a package whose redistributability was decided from at
+// least one low-confidence license file.
+type LicenseReview struct {
+	ModulePath         string
+	Version            string
+	PackagePath        string
+	IsRedistributable  bool
+	MinCoveragePercent float64
+	LicenseFilePaths   []string
+	CreatedAt          time.Time
+}
+
+// GetLicenseReviews returns up to limit packages that need their
+// redistributability reviewed, most recent first.
+func (db *DB) GetLicenseReviews(ctx context.Context, limit int) (_ []*LicenseReview, err error) {
+	defer derrors.Wrap(&err, "GetLicenseReviews(ctx, %d)", limit)
+
+	query := `
+		SELECT module_path, version, package_path, is_redistributable,
+			min_coverage_percent, license_file_paths, created_at
+		FROM license_reviews
+		ORDER BY created_at DESC, package_path
+		LIMIT $1`
+	var rs []*LicenseReview
+	if err := db.db.CollectStructs(ctx, &rs, query, limit); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// insertLicenseReviews replaces the license_reviews rows for m with one for
+// each package of m that has a low-confidence license.
+func insertLicenseReviews(ctx context.Context, db *database.DB, m *internal.Module) (err error) {
+	ctx, span := dtrace.StartSpan(ctx, "insertLicenseReviews")
+	ctx = database.WithQueryName(ctx, "insertLicenseReviews")
+	defer span.End()
+	defer derrors.Wrap(&err, "insertLicenseReviews(ctx, %q, %q)", m.ModulePath, m.Version)
+
+	if _, err := db.Exec(ctx, `DELETE FROM license_reviews WHERE module_path = $1 AND version = $2`,
+		m.ModulePath, m.Version); err != nil {
+		return err
+	}
+	var values []interface{}
+	for _, p := range m.LegacyPackages {
+		var (
+			paths []string
+			min   float64
+		)
+		for _, l := range p.Licenses {
+			if !l.IsLowConfidence() {
+				continue
+			}
+			if len(paths) == 0 || l.Coverage.Percent < min {
+				min = l.Coverage.Percent
+			}
+			paths = append(paths, l.FilePath)
+		}
+		if len(paths) > 0 {
+			values = append(values, m.ModulePath, m.Version, p.Path, p.IsRedistributable, min, pq.Array(paths))
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	cols := []string{"module_path", "version", "package_path", "is_redistributable", "min_coverage_percent", "license_file_paths"}
+	return db.BulkInsert(ctx, "license_reviews", cols, values, "")
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/licensecheck"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestLicenseReviews(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("example.com/lowconf", "v1.0.0", "a", "b")
+	low := &licenses.Metadata{
+		Types:    []string{"MIT"},
+		FilePath: "a/LICENSE",
+		Coverage: licensecheck.Coverage{Percent: 80},
+	}
+	m.Licenses = append(m.Licenses, &licenses.License{Metadata: low, Contents: []byte("mostly MIT")})
+	m.LegacyPackages[0].Licenses = append(m.LegacyPackages[0].Licenses, low)
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	gotLics, err := testDB.GetLowConfidenceLicenses(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLics := []*LowConfidenceLicense{{
+		ModulePath:      m.ModulePath,
+		Version:         m.Version,
+		FilePath:        "a/LICENSE",
+		Types:           []string{"MIT"},
+		CoveragePercent: 80,
+	}}
+	if diff := cmp.Diff(wantLics, gotLics); diff != "" {
+		t.Errorf("GetLowConfidenceLicenses mismatch (-want +got):\n%s", diff)
+	}
+
+	gotReviews, err := testDB.GetLicenseReviews(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantReviews := []*LicenseReview{{
+		ModulePath:         m.ModulePath,
+		Version:            m.Version,
+		PackagePath:        m.LegacyPackages[0].Path,
+		IsRedistributable:  m.LegacyPackages[0].IsRedistributable,
+		MinCoveragePercent: 80,
+		LicenseFilePaths:   []string{"a/LICENSE"},
+	}}
+	if diff := cmp.Diff(wantReviews, gotReviews, cmpopts.IgnoreFields(LicenseReview{}, "CreatedAt")); diff != "" {
+		t.Errorf("GetLicenseReviews mismatch (-want +got):\n%s", diff)
+	}
+}
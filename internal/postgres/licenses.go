@@ -8,13 +8,16 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"path"
 	"reflect"
 	"sort"
 	"strings"
 
 	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/stdlib"
 )
 
 // LegacyGetModuleLicenses returns all licenses associated with the given module path and
@@ -28,11 +31,12 @@ func (db *DB) LegacyGetModuleLicenses(ctx context.Context, modulePath, version s
 	}
 	query := `
 	SELECT
-		types, file_path, contents, coverage
+		types, expression, file_path, contents, coverage
 	FROM
 		licenses
 	WHERE
 		module_path = $1 AND version = $2 AND position('/' in file_path) = 0
+		AND kind = 'license'
     `
 	rows, err := db.db.Query(ctx, query, modulePath, version)
 	if err != nil {
@@ -54,6 +58,7 @@ func (db *DB) LegacyGetPackageLicenses(ctx context.Context, pkgPath, modulePath,
 	query := `
 		SELECT
 			l.types,
+			l.expression,
 			l.file_path,
 			l.contents,
 			l.coverage
@@ -84,17 +89,128 @@ func (db *DB) LegacyGetPackageLicenses(ctx context.Context, pkgPath, modulePath,
 	return collectLicenses(rows)
 }
 
+// GetLicensesForPath returns the metadata of the license files that govern
+// fullPath, a package or directory in modulePath@version. These are the
+// license files in the directory of fullPath and in each of its ancestors, up
+// to and including the module root, ordered from the root to the nearest one.
+// It returns an InvalidArgument error if fullPath is not in the module.
+func (db *DB) GetLicensesForPath(ctx context.Context, fullPath, modulePath, version string) (_ []*licenses.Metadata, err error) {
+	defer derrors.Wrap(&err, "GetLicensesForPath(ctx, %q, %q, %q)", fullPath, modulePath, version)
+
+	dir, ok := moduleRelativeDir(fullPath, modulePath)
+	if !ok {
+		return nil, fmt.Errorf("%q is not in module %q: %w", fullPath, modulePath, derrors.InvalidArgument)
+	}
+	query := `
+		SELECT types, expression, file_path, coverage
+		FROM licenses
+		WHERE module_path = $1 AND version = $2 AND kind = 'license'`
+	var mds []*licenses.Metadata
+	collect := func(rows *sql.Rows) error {
+		md := &licenses.Metadata{}
+		if err := rows.Scan(pq.Array(&md.Types), &md.Expression, &md.FilePath, database.JSONB(&md.Coverage)); err != nil {
+			return err
+		}
+		mds = append(mds, md)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	return governingLicenses(dir, mds), nil
+}
+
+// GetNoticesForPath returns the notice files, like NOTICE and PATENTS, that
+// accompany the licenses of fullPath in modulePath@version. As with
+// GetLicensesForPath, these are the files in the directory of fullPath and in
+// each of its ancestors, ordered from the module root to the nearest one.
+func (db *DB) GetNoticesForPath(ctx context.Context, fullPath, modulePath, version string) (_ []*licenses.Notice, err error) {
+	defer derrors.Wrap(&err, "GetNoticesForPath(ctx, %q, %q, %q)", fullPath, modulePath, version)
+
+	dir, ok := moduleRelativeDir(fullPath, modulePath)
+	if !ok {
+		return nil, fmt.Errorf("%q is not in module %q: %w", fullPath, modulePath, derrors.InvalidArgument)
+	}
+	query := `
+		SELECT kind, file_path, contents
+		FROM licenses
+		WHERE module_path = $1 AND version = $2 AND kind != 'license'
+		ORDER BY file_path`
+	var notices []*licenses.Notice
+	collect := func(rows *sql.Rows) error {
+		n := &licenses.Notice{}
+		if err := rows.Scan(&n.Kind, &n.FilePath, database.Compressed(&n.Contents)); err != nil {
+			return err
+		}
+		if inDirOrAncestor(n.FilePath, dir) {
+			notices = append(notices, n)
+		}
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(notices, func(i, j int) bool {
+		return strings.Count(notices[i].FilePath, "/") < strings.Count(notices[j].FilePath, "/")
+	})
+	return notices, nil
+}
+
+// moduleRelativeDir returns the directory of fullPath relative to the root of
+// the module, using "." for the root. It reports false if fullPath is not in
+// the module.
+func moduleRelativeDir(fullPath, modulePath string) (string, bool) {
+	if modulePath == stdlib.ModulePath {
+		return fullPath, true
+	}
+	if fullPath == modulePath {
+		return ".", true
+	}
+	if !strings.HasPrefix(fullPath, modulePath+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(fullPath, modulePath+"/"), true
+}
+
+// governingLicenses returns the licenses in mds that are in dir or one of its
+// ancestors, ordered by the depth of their directory, then by file path.
+func governingLicenses(dir string, mds []*licenses.Metadata) []*licenses.Metadata {
+	var gov []*licenses.Metadata
+	for _, md := range mds {
+		if inDirOrAncestor(md.FilePath, dir) {
+			gov = append(gov, md)
+		}
+	}
+	sort.Slice(gov, func(i, j int) bool {
+		di := strings.Count(gov[i].FilePath, "/")
+		dj := strings.Count(gov[j].FilePath, "/")
+		if di != dj {
+			return di < dj
+		}
+		return gov[i].FilePath < gov[j].FilePath
+	})
+	return gov
+}
+
+// inDirOrAncestor reports whether the file at filePath is in dir or one of
+// its ancestors. Both paths are relative to the module root.
+func inDirOrAncestor(filePath, dir string) bool {
+	fdir := path.Dir(filePath)
+	// Append a slash so that a/b does not match a/bc.
+	return fdir == "." || strings.HasPrefix(dir+"/", fdir+"/")
+}
+
 // collectLicenses converts the sql rows to a list of licenses. The columns
-// must be types, file_path and contents, in that order.
+// must be types, expression, file_path, contents and coverage, in that order.
 func collectLicenses(rows *sql.Rows) ([]*licenses.License, error) {
-	mustHaveColumns(rows, "types", "file_path", "contents", "coverage")
+	mustHaveColumns(rows, "types", "expression", "file_path", "contents", "coverage")
 	var lics []*licenses.License
 	for rows.Next() {
 		var (
 			lic          = &licenses.License{Metadata: &licenses.Metadata{}}
 			licenseTypes []string
 		)
-		if err := rows.Scan(pq.Array(&licenseTypes), &lic.FilePath, &lic.Contents, jsonbScanner{&lic.Coverage}); err != nil {
+		if err := rows.Scan(pq.Array(&licenseTypes), &lic.Expression, &lic.FilePath, database.Compressed(&lic.Contents), database.JSONB(&lic.Coverage)); err != nil {
 			return nil, fmt.Errorf("row.Scan(): %v", err)
 		}
 		lic.Types = licenseTypes
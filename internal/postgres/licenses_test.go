@@ -6,9 +6,11 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
@@ -88,3 +90,112 @@ func TestLegacyGetPackageLicenses(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLicensesForPath(t *testing.T) {
+	modulePath := "test.module"
+	testModule := sample.Module(modulePath, "v1.2.3", "", "foo", "foo/bar", "foobar")
+	mds := []*licenses.Metadata{
+		{Types: []string{"MIT"}, FilePath: "LICENSE"},
+		{Types: []string{"BSD-3-Clause"}, FilePath: "foo/LICENSE"},
+		{Types: []string{"ISC"}, FilePath: "foobar/COPYING"},
+	}
+	testModule.Licenses = nil
+	for _, md := range mds {
+		testModule.Licenses = append(testModule.Licenses, &licenses.License{Metadata: md, Contents: []byte(`Lorem Ipsum`)})
+	}
+
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := testDB.InsertModule(ctx, testModule); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path string
+		want []*licenses.Metadata
+	}{
+		{"test.module", mds[:1]},
+		{"test.module/foo", mds[:2]},
+		{"test.module/foo/bar", mds[:2]},
+		{"test.module/foobar", []*licenses.Metadata{mds[0], mds[2]}},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := testDB.GetLicensesForPath(ctx, test.path, modulePath, testModule.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got, sample.LicenseCmpOpts...); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := testDB.GetLicensesForPath(ctx, "other.module/foo", modulePath, testModule.Version); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("got error %v, want InvalidArgument", err)
+	}
+}
+
+func TestGoverningLicenses(t *testing.T) {
+	mds := []*licenses.Metadata{
+		{FilePath: "a/b/LICENSE"},
+		{FilePath: "a/LICENSE"},
+		{FilePath: "COPYING"},
+		{FilePath: "LICENSE"},
+		{FilePath: "ab/LICENSE"},
+	}
+	var got []string
+	for _, md := range governingLicenses("a/b/c", mds) {
+		got = append(got, md.FilePath)
+	}
+	want := []string{"COPYING", "LICENSE", "a/LICENSE", "a/b/LICENSE"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestGetNoticesForPath(t *testing.T) {
+	modulePath := "test.module"
+	testModule := sample.Module(modulePath, "v1.2.3", "", "foo", "bar")
+	testModule.Notices = []*licenses.Notice{
+		{Kind: licenses.KindNotice, FilePath: "NOTICE", Contents: []byte("root notice")},
+		{Kind: licenses.KindPatents, FilePath: "foo/PATENTS", Contents: []byte("foo patents")},
+	}
+
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	if err := testDB.InsertModule(ctx, testModule); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		path string
+		want []*licenses.Notice
+	}{
+		{"test.module", testModule.Notices[:1]},
+		{"test.module/foo", testModule.Notices},
+		{"test.module/bar", testModule.Notices[:1]},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := testDB.GetNoticesForPath(ctx, test.path, modulePath, testModule.Version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	// Notices are stored with licenses, but are not licenses.
+	lics, err := testDB.LegacyGetModuleLicenses(ctx, modulePath, testModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(sample.Licenses, lics, sample.LicenseCmpOpts...); diff != "" {
+		t.Errorf("LegacyGetModuleLicenses mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A ModuleRedirect records that the module at FromPath moved to ToPath, for
+// instance because the project was renamed or changed hands. Maintainers ask
+// for redirects, and they take effect once an administrator approves them.
+type ModuleRedirect struct {
+	FromPath    string
+	ToPath      string
+	Reason      string
+	RequestedBy string
+	RequestedAt time.Time
+	// ApprovedBy and ApprovedAt are set once the redirect is approved.
+	ApprovedBy string `db:",nullempty"`
+	ApprovedAt *time.Time
+}
+
+// Approved reports whether r is approved.
+func (r *ModuleRedirect) Approved() bool {
+	return r.ApprovedAt != nil
+}
+
+// InsertModuleRedirect records a pending redirect from r.FromPath to
+// r.ToPath. If there is already a redirect from r.FromPath, it is replaced,
+// and must be approved again.
+func (db *DB) InsertModuleRedirect(ctx context.Context, r *ModuleRedirect) (err error) {
+	defer derrors.Wrap(&err, "InsertModuleRedirect(ctx, %q, %q)", r.FromPath, r.ToPath)
+
+	if r.FromPath == "" || r.ToPath == "" || r.Reason == "" || r.RequestedBy == "" {
+		return fmt.Errorf("from, to, reason and user must be non-empty: %w", derrors.InvalidArgument)
+	}
+	if r.FromPath == r.ToPath {
+		return fmt.Errorf("from and to must be different: %w", derrors.InvalidArgument)
+	}
+	if err := module.CheckPath(r.ToPath); err != nil {
+		return fmt.Errorf("%v: %w", err, derrors.InvalidArgument)
+	}
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO module_redirects (from_path, to_path, reason, requested_by)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (from_path) DO UPDATE SET
+				to_path = excluded.to_path,
+				reason = excluded.reason,
+				requested_by = excluded.requested_by,
+				requested_at = CURRENT_TIMESTAMP,
+				approved_by = NULL,
+				approved_at = NULL`,
+			r.FromPath, r.ToPath, r.Reason, r.RequestedBy); err != nil {
+			return err
+		}
+		return setModuleMoved(ctx, tx, r.FromPath, false)
+	})
+}
+
+// ApproveModuleRedirect approves the redirect from fromPath on behalf of
+// user, who must not be the one who asked for it. From then on, the packages
+// of the module at fromPath rank lower in search. It returns a
+// derrors.NotFound error if there is no redirect from fromPath.
+func (db *DB) ApproveModuleRedirect(ctx context.Context, fromPath, user string) (err error) {
+	defer derrors.Wrap(&err, "ApproveModuleRedirect(ctx, %q, %q)", fromPath, user)
+
+	if user == "" {
+		return fmt.Errorf("user must be non-empty: %w", derrors.InvalidArgument)
+	}
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		var requestedBy string
+		err := tx.QueryRow(ctx, `SELECT requested_by FROM module_redirects WHERE from_path = $1`,
+			fromPath).Scan(&requestedBy)
+		if errors.Is(err, sql.ErrNoRows) {
+			return derrors.NotFound
+		}
+		if err != nil {
+			return err
+		}
+		if requestedBy == user {
+			return fmt.Errorf("redirect was requested by %q, who cannot approve it: %w", user, derrors.InvalidArgument)
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE module_redirects
+			SET approved_by = $2, approved_at = CURRENT_TIMESTAMP
+			WHERE from_path = $1`,
+			fromPath, user); err != nil {
+			return err
+		}
+		return setModuleMoved(ctx, tx, fromPath, true)
+	})
+}
+
+// DeleteModuleRedirect deletes the redirect from fromPath. It returns a
+// derrors.NotFound error if there is no such redirect.
+func (db *DB) DeleteModuleRedirect(ctx context.Context, fromPath string) (err error) {
+	defer derrors.Wrap(&err, "DeleteModuleRedirect(ctx, %q)", fromPath)
+
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		res, err := tx.Exec(ctx, `DELETE FROM module_redirects WHERE from_path = $1`, fromPath)
+		if err != nil {
+			return err
+		}
+		if err := notFoundIfNoRows(res); err != nil {
+			return err
+		}
+		return setModuleMoved(ctx, tx, fromPath, false)
+	})
+}
+
+// setModuleMoved sets the module_moved column of the search documents of the
+// module at modulePath.
+func setModuleMoved(ctx context.Context, tx *database.DB, modulePath string, moved bool) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE search_documents
+		SET module_moved = $2
+		WHERE module_path = $1 AND module_moved <> $2`,
+		modulePath, moved)
+	return err
+}
+
+// GetModuleRedirects returns all module redirects, pending ones first, then
+// ordered by the path they redirect from.
+func (db *DB) GetModuleRedirects(ctx context.Context) (_ []*ModuleRedirect, err error) {
+	defer derrors.Wrap(&err, "GetModuleRedirects(ctx)")
+
+	var redirects []*ModuleRedirect
+	if err := db.db.CollectStructs(ctx, &redirects, `
+		SELECT from_path, to_path, reason, requested_by, requested_at, approved_by, approved_at
+		FROM module_redirects
+		ORDER BY approved_at IS NOT NULL, from_path`); err != nil {
+		return nil, err
+	}
+	return redirects, nil
+}
+
+// GetModuleRedirect returns the approved redirect from modulePath. It returns
+// a derrors.NotFound error if there is none.
+func (db *DB) GetModuleRedirect(ctx context.Context, modulePath string) (_ *ModuleRedirect, err error) {
+	defer derrors.Wrap(&err, "GetModuleRedirect(ctx, %q)", modulePath)
+
+	r := &ModuleRedirect{}
+	err = db.db.QueryStruct(ctx, r, `
+		SELECT from_path, to_path, reason, requested_by, requested_at, approved_by, approved_at
+		FROM module_redirects
+		WHERE from_path = $1 AND approved_at IS NOT NULL`, modulePath)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestModuleRedirects(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	const (
+		oldPath = "github.com/dgrijalva/jwt-go"
+		newPath = "github.com/golang-jwt/jwt"
+	)
+	for _, p := range []string{oldPath, newPath} {
+		if err := testDB.InsertModule(ctx, sample.Module(p, sample.VersionString, "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The old module is more popular, so it ranks first until it moves.
+	if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET imported_by_count = 10 WHERE module_path = $1`, oldPath); err != nil {
+		t.Fatal(err)
+	}
+	checkTop := func(want string) {
+		t.Helper()
+		results, err := testDB.Search(ctx, "synopsis", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 || results[0].PackagePath != want {
+			var got []string
+			for _, r := range results {
+				got = append(got, r.PackagePath)
+			}
+			t.Errorf("Search: got %v, want 2 results with %s first", got, want)
+		}
+	}
+	checkTop(oldPath)
+
+	for _, r := range []*ModuleRedirect{
+		{FromPath: oldPath, ToPath: newPath, Reason: "renamed"},
+		{FromPath: oldPath, ToPath: oldPath, Reason: "renamed", RequestedBy: "maintainer"},
+		{FromPath: oldPath, ToPath: "not a path", Reason: "renamed", RequestedBy: "maintainer"},
+	} {
+		if err := testDB.InsertModuleRedirect(ctx, r); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("InsertModuleRedirect(%+v): got %v, want InvalidArgument", r, err)
+		}
+	}
+	if err := testDB.InsertModuleRedirect(ctx, &ModuleRedirect{
+		FromPath:    oldPath,
+		ToPath:      newPath,
+		Reason:      "The project moved to a new organization.",
+		RequestedBy: "maintainer",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// A pending redirect has no effect.
+	if _, err := testDB.GetModuleRedirect(ctx, oldPath); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetModuleRedirect of pending redirect: got %v, want NotFound", err)
+	}
+	checkTop(oldPath)
+
+	if err := testDB.ApproveModuleRedirect(ctx, oldPath, "maintainer"); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("ApproveModuleRedirect by requester: got %v, want InvalidArgument", err)
+	}
+	if err := testDB.ApproveModuleRedirect(ctx, "example.com/unknown", "admin"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("ApproveModuleRedirect of unknown path: got %v, want NotFound", err)
+	}
+	if err := testDB.ApproveModuleRedirect(ctx, oldPath, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetModuleRedirect(ctx, oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ToPath != newPath || got.ApprovedBy != "admin" || !got.Approved() {
+		t.Errorf("GetModuleRedirect: got %+v, want approved redirect to %s", got, newPath)
+	}
+	checkTop(newPath)
+
+	// Reinserting the module keeps it ranked as moved.
+	if err := testDB.InsertModule(ctx, sample.Module(oldPath, "v1.1.0", "")); err != nil {
+		t.Fatal(err)
+	}
+	var moved bool
+	if err := testDB.db.QueryRow(ctx, `SELECT module_moved FROM search_documents WHERE package_path = $1`, oldPath).Scan(&moved); err != nil {
+		t.Fatal(err)
+	}
+	if !moved {
+		t.Error("module_moved was reset by inserting a new version")
+	}
+
+	if err := testDB.DeleteModuleRedirect(ctx, oldPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.DeleteModuleRedirect(ctx, oldPath); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("DeleteModuleRedirect of deleted redirect: got %v, want NotFound", err)
+	}
+	redirects, err := testDB.GetModuleRedirects(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redirects) != 0 {
+		t.Errorf("GetModuleRedirects: got %d redirects, want none", len(redirects))
+	}
+	checkTop(oldPath)
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A FetchOutboxEntry is a module version read from the module index that must
+// be published to the fetch task queue.
+type FetchOutboxEntry struct {
+	// Key de-duplicates publications of the entry.
+	Key        string `db:"dedup_key"`
+	ModulePath string
+	Version    string
+	CreatedAt  time.Time
+}
+
+// fetchOutboxKey returns the de-duplication key for v. The key is the same
+// every time v is read from the index, and is a valid Cloud Tasks task ID.
+func fetchOutboxKey(v *internal.IndexVersion) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(v.Path+"@"+v.Version+"@"+v.Timestamp.UTC().Format(time.RFC3339Nano))))
+}
+
+// insertFetchOutbox adds an entry to the fetch outbox for each of versions,
+// skipping versions that are already there.
+func insertFetchOutbox(ctx context.Context, tx *database.DB, versions []*internal.IndexVersion) error {
+	var vals []interface{}
+	for _, v := range versions {
+		vals = append(vals, fetchOutboxKey(v), v.Path, v.Version)
+	}
+	cols := []string{"dedup_key", "module_path", "version"}
+	return tx.BulkInsert(ctx, "fetch_outbox", cols, vals, "ON CONFLICT (dedup_key) DO NOTHING")
+}
+
+// GetPendingFetchOutbox returns up to limit entries of the fetch outbox that
+// have not been dispatched, oldest first.
+func (db *DB) GetPendingFetchOutbox(ctx context.Context, limit int) (_ []*FetchOutboxEntry, err error) {
+	defer derrors.Wrap(&err, "GetPendingFetchOutbox(ctx, %d)", limit)
+
+	query := `
+		SELECT dedup_key, module_path, version, created_at
+		FROM fetch_outbox
+		WHERE dispatched_at IS NULL
+		ORDER BY id
+		LIMIT $1`
+	var entries []*FetchOutboxEntry
+	if err := db.db.CollectStructs(ctx, &entries, query, limit); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// MarkFetchOutboxDispatched records that the fetch outbox entry with the given
+// key has been published to the task queue.
+func (db *DB) MarkFetchOutboxDispatched(ctx context.Context, key string) (err error) {
+	defer derrors.Wrap(&err, "MarkFetchOutboxDispatched(ctx, %q)", key)
+
+	_, err = db.db.Exec(ctx, `
+		UPDATE fetch_outbox
+		SET dispatched_at = CURRENT_TIMESTAMP
+		WHERE dedup_key = $1 AND dispatched_at IS NULL`, key)
+	return err
+}
+
+// DeleteDispatchedFetchOutbox deletes entries of the fetch outbox that were
+// dispatched before the given time, and returns the number deleted.
+func (db *DB) DeleteDispatchedFetchOutbox(ctx context.Context, before time.Time) (_ int64, err error) {
+	defer derrors.Wrap(&err, "DeleteDispatchedFetchOutbox(ctx, %s)", before)
+
+	res, err := db.db.Exec(ctx, `DELETE FROM fetch_outbox WHERE dispatched_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+)
+
+func TestFetchOutbox(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	now := time.Now()
+	versions := []*internal.IndexVersion{
+		{Path: "m.com/a", Version: "v1.0.0", Timestamp: now},
+		{Path: "m.com/b", Version: "v1.0.0", Timestamp: now.Add(time.Second)},
+	}
+	state := &IndexPollState{Cursor: now, Interval: time.Minute, NextPollAt: now, LastPolledAt: now}
+	// Recording the same versions twice adds them to the outbox once.
+	for i := 0; i < 2; i++ {
+		if err := testDB.InsertIndexVersionsAndPollState(ctx, versions, state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pending := func() []string {
+		t.Helper()
+		entries, err := testDB.GetPendingFetchOutbox(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, e := range entries {
+			paths = append(paths, e.ModulePath)
+		}
+		return paths
+	}
+	if diff := cmp.Diff([]string{"m.com/a", "m.com/b"}, pending()); diff != "" {
+		t.Errorf("pending mismatch (-want +got):\n%s", diff)
+	}
+
+	entries, err := testDB.GetPendingFetchOutbox(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.MarkFetchOutboxDispatched(ctx, entries[0].Key); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"m.com/b"}, pending()); diff != "" {
+		t.Errorf("pending after dispatch mismatch (-want +got):\n%s", diff)
+	}
+
+	n, err := testDB.DeleteDispatchedFetchOutbox(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteDispatchedFetchOutbox: got %d, want 1", n)
+	}
+	if diff := cmp.Diff([]string{"m.com/b"}, pending()); diff != "" {
+		t.Errorf("pending after delete mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchOutboxKey(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	v := &internal.IndexVersion{Path: "m.com/a", Version: "v1.0.0", Timestamp: ts}
+	key := fetchOutboxKey(v)
+	if got := fetchOutboxKey(&internal.IndexVersion{Path: v.Path, Version: v.Version, Timestamp: ts.In(time.FixedZone("x", 3600))}); got != key {
+		t.Errorf("key depends on time zone: got %q, want %q", got, key)
+	}
+	for _, other := range []*internal.IndexVersion{
+		{Path: "m.com/b", Version: v.Version, Timestamp: ts},
+		{Path: v.Path, Version: "v1.0.1", Timestamp: ts},
+		{Path: v.Path, Version: v.Version, Timestamp: ts.Add(time.Second)},
+	} {
+		if got := fetchOutboxKey(other); got == key {
+			t.Errorf("fetchOutboxKey(%+v) = fetchOutboxKey(%+v)", other, v)
+		}
+	}
+}
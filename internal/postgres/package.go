@@ -41,6 +41,10 @@ import (
 // The returned error may be checked with
 // errors.Is(err, derrors.InvalidArgument) to determine if it was caused by an
 // invalid path or version.
+//
+// Documentation that is stored in a blob is not read: the DocumentationHTML
+// of the package is internal.StringFieldMissing, and
+// LegacyGetPackageDocumentation reads it when it is shown.
 func (db *DB) LegacyGetPackage(ctx context.Context, pkgPath, modulePath, version string) (_ *internal.LegacyVersionedPackage, err error) {
 	defer derrors.Wrap(&err, "DB.LegacyGetPackage(ctx, %q, %q)", pkgPath, version)
 	if pkgPath == "" || modulePath == "" || version == "" {
@@ -58,6 +62,8 @@ func (db *DB) LegacyGetPackage(ctx context.Context, pkgPath, modulePath, version
 			p.license_paths,
 			p.redistributable,
 			p.documentation,
+			p.documentation_blob_key,
+			p.documentation_coverage,
 			p.goos,
 			p.goarch,
 			m.version,
@@ -68,7 +74,8 @@ func (db *DB) LegacyGetPackage(ctx context.Context, pkgPath, modulePath, version
 			m.version_type,
 		    m.source_info,
 			m.redistributable,
-			m.has_go_mod
+			m.has_go_mod,
+			m.published_at
 		FROM
 			modules m
 		INNER JOIN
@@ -135,20 +142,24 @@ func (db *DB) LegacyGetPackage(ctx context.Context, pkgPath, modulePath, version
 		pkg                        internal.LegacyVersionedPackage
 		licenseTypes, licensePaths []string
 		hasGoMod                   sql.NullBool
+		docKey                     string
 	)
 	row := db.db.QueryRow(ctx, query, args...)
 	err = row.Scan(&pkg.Path, &pkg.Name, &pkg.Synopsis,
 		&pkg.V1Path, pq.Array(&licenseTypes), pq.Array(&licensePaths), &pkg.LegacyPackage.IsRedistributable,
-		database.NullIsEmpty(&pkg.DocumentationHTML), &pkg.GOOS, &pkg.GOARCH, &pkg.Version,
-		&pkg.CommitTime, database.NullIsEmpty(&pkg.LegacyReadmeFilePath), database.NullIsEmpty(&pkg.LegacyReadmeContents),
-		&pkg.ModulePath, &pkg.VersionType, jsonbScanner{&pkg.SourceInfo}, &pkg.LegacyModuleInfo.IsRedistributable,
-		&hasGoMod)
+		database.Compressed(&pkg.DocumentationHTML), database.NullIsEmpty(&docKey), &pkg.DocumentationCoverage, &pkg.GOOS, &pkg.GOARCH, &pkg.Version,
+		&pkg.CommitTime, database.NullIsEmpty(&pkg.LegacyReadmeFilePath), database.Compressed(&pkg.LegacyReadmeContents),
+		&pkg.ModulePath, &pkg.VersionType, database.JSONB(&pkg.SourceInfo), &pkg.LegacyModuleInfo.IsRedistributable,
+		&hasGoMod, database.NullIsZero(&pkg.PublishedAt))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("package %s@%s: %w", pkgPath, version, derrors.NotFound)
 		}
 		return nil, fmt.Errorf("row.Scan(): %v", err)
 	}
+	if docKey != "" {
+		pkg.DocumentationHTML = internal.StringFieldMissing
+	}
 	setHasGoMod(&pkg.ModuleInfo, hasGoMod)
 	lics, err := zipLicenseMetadata(licenseTypes, licensePaths)
 	if err != nil {
@@ -157,3 +168,49 @@ func (db *DB) LegacyGetPackage(ctx context.Context, pkgPath, modulePath, version
 	pkg.Licenses = lics
 	return &pkg, nil
 }
+
+// LegacyGetPackageDocumentation returns the documentation HTML of the package
+// at pkgPath in the module at modulePath and version, reading it from its blob
+// if it is stored in one.
+func (db *DB) LegacyGetPackageDocumentation(ctx context.Context, pkgPath, modulePath, version string) (_ string, err error) {
+	defer derrors.Wrap(&err, "DB.LegacyGetPackageDocumentation(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	var html, key string
+	err = db.db.QueryRow(ctx, `
+		SELECT documentation, documentation_blob_key
+		FROM packages
+		WHERE path = $1 AND module_path = $2 AND version = $3`,
+		pkgPath, modulePath, version).Scan(database.Compressed(&html), database.NullIsEmpty(&key))
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("package %s@%s: %w", pkgPath, version, derrors.NotFound)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := db.blobs.load(ctx, &html, key); err != nil {
+		return "", err
+	}
+	return html, nil
+}
+
+// GetExportedSymbols returns the exported symbols of the package at pkgPath in
+// the module at modulePath and version, with methods and fields as
+// Type.Method and Type.Field. It returns nil if they were not recorded when
+// the package was processed.
+func (db *DB) GetExportedSymbols(ctx context.Context, pkgPath, modulePath, version string) (_ []string, err error) {
+	defer derrors.Wrap(&err, "DB.GetExportedSymbols(ctx, %q, %q, %q)", pkgPath, modulePath, version)
+
+	var symbols []string
+	err = db.db.QueryRow(ctx, `
+		SELECT exported_symbols
+		FROM packages
+		WHERE path = $1 AND module_path = $2 AND version = $3`,
+		pkgPath, modulePath, version).Scan(pq.Array(&symbols))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("package %s@%s: %w", pkgPath, version, derrors.NotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
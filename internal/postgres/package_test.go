@@ -234,3 +234,34 @@ func TestLegacyGetPackageInvalidArguments(t *testing.T) {
 		})
 	}
 }
+
+func TestGetExportedSymbols(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("example.com/symbols", sample.VersionString, "known", "unknown")
+	m.LegacyPackages[0].Symbols = []string{"F", "T", "T.M"}
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		pkgPath string
+		want    []string
+	}{
+		{"example.com/symbols/known", []string{"F", "T", "T.M"}},
+		{"example.com/symbols/unknown", nil},
+	} {
+		got, err := testDB.GetExportedSymbols(ctx, test.pkgPath, m.ModulePath, m.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want, +got):\n%s", test.pkgPath, diff)
+		}
+	}
+	if _, err := testDB.GetExportedSymbols(ctx, "example.com/symbols/missing", m.ModulePath, m.Version); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("missing package: got %v, want NotFound", err)
+	}
+}
@@ -61,6 +61,41 @@ func (db *DB) GetPathInfo(ctx context.Context, path, inModulePath, inVersion str
 	}
 }
 
+// GetModulePathForPath resolves path to the module that provides it, choosing
+// among modulePaths, which should be paths that could be modules containing
+// path. It returns the longest of modulePaths that is the path of a known
+// module containing path, at any version, and reports true; or, if there is no
+// such module, the longest of modulePaths that is the path of any known module,
+// and reports false. It returns derrors.NotFound if none of modulePaths are
+// known.
+//
+// It makes a single query, no matter how many module paths there are.
+func (db *DB) GetModulePathForPath(ctx context.Context, path string, modulePaths []string) (_ string, containsPath bool, err error) {
+	defer derrors.Wrap(&err, "DB.GetModulePathForPath(ctx, %q, %q)", path, modulePaths)
+
+	query := `
+		SELECT m.module_path, bool_or(p.id IS NOT NULL) AS contains_path
+		FROM modules m
+		LEFT JOIN paths p
+		ON p.module_id = m.id AND p.path = $1
+		WHERE m.module_path = ANY($2)
+		GROUP BY m.module_path
+		ORDER BY
+			contains_path DESC,
+			length(m.module_path) DESC
+		LIMIT 1`
+	var modulePath string
+	err = db.db.QueryRow(ctx, query, path, pq.Array(modulePaths)).Scan(&modulePath, &containsPath)
+	switch err {
+	case sql.ErrNoRows:
+		return "", false, derrors.NotFound
+	case nil:
+		return modulePath, containsPath, nil
+	default:
+		return "", false, err
+	}
+}
+
 type dbPath struct {
 	id              int64
 	path            string
@@ -6,12 +6,14 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"path"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/testing/sample"
@@ -163,6 +165,58 @@ func TestGetPathInfo(t *testing.T) {
 	}
 }
 
+func TestGetModulePathForPath(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx, experiment.NewSet(map[string]bool{
+		internal.ExperimentInsertDirectories: true,
+	}))
+
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []*internal.Module{
+		sample.Module("m.com", "v1.0.0", "a/b/c"),
+		sample.Module("m.com", "v1.1.0", "a/b/d"),
+		sample.Module("m.com/a/b", "v1.0.0", "e"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		path             string
+		wantModulePath   string
+		wantContainsPath bool
+		wantErr          error
+	}{
+		// The longest module path that contains the path wins.
+		{"m.com/a/b/e", "m.com/a/b", true, nil},
+		// A module that contains the path, at any version, is preferred
+		// to a longer one that does not.
+		{"m.com/a/b/c", "m.com", true, nil},
+		{"m.com/a/b/d", "m.com", true, nil},
+		// If no module contains the path, the longest module path wins.
+		{"m.com/a/b/f/g", "m.com/a/b", false, nil},
+		{"m.com/x", "m.com", false, nil},
+		{"other.com/a", "", false, derrors.NotFound},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			var modulePaths []string
+			for p := test.path; p != "."; p = path.Dir(p) {
+				modulePaths = append(modulePaths, p)
+			}
+			gotModulePath, gotContainsPath, err := testDB.GetModulePathForPath(ctx, test.path, modulePaths)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+			if gotModulePath != test.wantModulePath || gotContainsPath != test.wantContainsPath {
+				t.Errorf("got (%q, %t), want (%q, %t)",
+					gotModulePath, gotContainsPath, test.wantModulePath, test.wantContainsPath)
+			}
+		})
+	}
+}
+
 func TestGetStdlibPaths(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
 	defer cancel()
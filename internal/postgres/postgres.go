@@ -5,16 +5,40 @@
 package postgres
 
 import (
+	"context"
+
 	"golang.org/x/pkgsite/internal/database"
 )
 
 type DB struct {
-	db *database.DB
+	db          *database.DB
+	moduleHooks []ModuleHook
+	blobs       *blobStore
 }
 
 // New returns a new postgres DB.
 func New(db *database.DB) *DB {
-	return &DB{db}
+	return &DB{db: db}
+}
+
+// A ModuleHook is called after a module version is inserted into or deleted
+// from the database, with its module path and the paths of its packages and
+// directories. It is also called after a removed path is inserted or deleted,
+// with that path and the paths it applies to.
+type ModuleHook func(ctx context.Context, modulePath string, paths []string)
+
+// AddModuleHook arranges for h to be called after every module version that
+// db inserts or deletes, such as to remove the pages that show it from a
+// cache. It is not safe to call AddModuleHook concurrently with changes to
+// modules.
+func (db *DB) AddModuleHook(h ModuleHook) {
+	db.moduleHooks = append(db.moduleHooks, h)
+}
+
+func (db *DB) runModuleHooks(ctx context.Context, modulePath string, paths []string) {
+	for _, h := range db.moduleHooks {
+		h(ctx, modulePath, paths)
+	}
 }
 
 // Close closes a DB.
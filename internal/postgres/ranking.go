@@ -0,0 +1,88 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// RankingSignals are the inputs to the search score of a package that do not
+// depend on the query, as computed by search.
+type RankingSignals struct {
+	PackagePath string
+	// Version is the version of the package in search results.
+	Version         string
+	ImportedByCount int
+	Redistributable bool
+	// HasGoMod is false only if the module is known to have no go.mod file.
+	HasGoMod     bool
+	RepoInactive bool
+	HasSynopsis  bool
+	// HasReadme reports whether the README of the module is part of the
+	// search document of the package, which is only the case for the package
+	// at the root of the module.
+	HasReadme bool
+	// ModuleMoved reports whether the module has an approved redirect to a
+	// new module path.
+	ModuleMoved bool
+	// DocumentationCoverage is the fraction of the identifiers of the package
+	// that have doc comments, or nil if it is not known.
+	DocumentationCoverage *float64
+
+	// The factors of the search score, and their product, computed by the
+	// same expressions as search. The score of the package for a query is its
+	// relevance to the query times Quality.
+	PopularityFactor      float64
+	RedistributableFactor float64
+	GoModFactor           float64
+	RepoFactor            float64
+	DocCoverageFactor     float64
+	MovedFactor           float64
+	Quality               float64
+}
+
+// GetRankingSignals returns the ranking signals of the packages of modulePath
+// in search_documents, ordered by package path. Packages that are not in
+// search results, such as those of excluded modules, have none.
+func (db *DB) GetRankingSignals(ctx context.Context, modulePath string) (_ []*RankingSignals, err error) {
+	defer derrors.Wrap(&err, "DB.GetRankingSignals(ctx, %q)", modulePath)
+
+	query := fmt.Sprintf(`
+		SELECT
+			sd.package_path,
+			sd.version,
+			sd.imported_by_count,
+			sd.redistributable,
+			COALESCE(sd.has_go_mod, true) AS has_go_mod,
+			sd.repo_inactive,
+			COALESCE(sd.synopsis, '') <> '' AS has_synopsis,
+			sd.package_path = sd.module_path AND EXISTS (
+				SELECT 1 FROM modules m
+				WHERE m.module_path = sd.module_path
+				AND m.version = sd.version
+				AND COALESCE(m.readme_contents, '') <> ''
+			) AS has_readme,
+			sd.documentation_coverage,
+			sd.module_moved,
+			%s AS popularity_factor,
+			%s AS redistributable_factor,
+			%s AS go_mod_factor,
+			%s AS repo_factor,
+			%s AS doc_coverage_factor,
+			%s AS moved_factor,
+			%s AS quality
+		FROM search_documents sd
+		WHERE sd.module_path = $1
+		ORDER BY sd.package_path`,
+		popularityFactorExpr, redistributableFactorExpr, goModFactorExpr, repoFactorExpr, docCoverageFactorExpr, movedFactorExpr, qualityExpr)
+	var signals []*RankingSignals
+	if err := db.db.CollectStructs(ctx, &signals, query, modulePath); err != nil {
+		return nil, err
+	}
+	return signals, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetRankingSignals(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	const modulePath = "example.com/mod"
+	m := sample.Module(modulePath, "v1.0.0", "", "pkg")
+	m.HasGoMod = false
+	half := 0.5
+	m.LegacyPackages[1].DocumentationCoverage = &half
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.GetRankingSignals(ctx, modulePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := func(path string, hasReadme bool, cov *float64, covFactor float64) *RankingSignals {
+		return &RankingSignals{
+			PackagePath:           path,
+			Version:               "v1.0.0",
+			Redistributable:       true,
+			HasGoMod:              false,
+			HasSynopsis:           true,
+			HasReadme:             hasReadme,
+			DocumentationCoverage: cov,
+			PopularityFactor:      1,
+			RedistributableFactor: 1,
+			GoModFactor:           noGoModPenalty,
+			RepoFactor:            1,
+			DocCoverageFactor:     covFactor,
+			MovedFactor:           1,
+			Quality:               noGoModPenalty * covFactor,
+		}
+	}
+	want := []*RankingSignals{
+		signals(modulePath, true, nil, 1),
+		signals(modulePath+"/pkg", false, &half, 1-0.5*(1-undocumentedPenalty)),
+	}
+	// The factors are computed in floating point by Postgres.
+	opt := cmp.Comparer(func(a, b float64) bool { return a-b < 1e-6 && b-a < 1e-6 })
+	if diff := cmp.Diff(want, got, opt); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = testDB.GetRankingSignals(ctx, "example.com/unknown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %d signals for unknown module, want none", len(got))
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// Scopes of a removed path.
+const (
+	// RemovedVersion removes one version of the module at the path, and of
+	// the packages in it.
+	RemovedVersion = "version"
+	// RemovedModule removes every version of the module at the path, and of
+	// the packages in it.
+	RemovedModule = "module"
+	// RemovedPrefix removes every path that begins with the path, at every
+	// version, like an excluded prefix.
+	RemovedPrefix = "prefix"
+)
+
+// A RemovedPath is a path that is neither served nor fetched for legal
+// reasons, such as a takedown request.
+type RemovedPath struct {
+	Path  string
+	Scope string
+	// Version is the removed version, if Scope is RemovedVersion. It is
+	// empty otherwise.
+	Version string
+	// Reason is shown to users who ask for the path.
+	Reason    string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// removes reports whether rp removes path at version. The version of
+// RemovedVersion scopes must be resolved by the caller.
+func (rp *RemovedPath) removes(path, version string) bool {
+	if rp.Scope == RemovedPrefix {
+		return strings.HasPrefix(path, rp.Path)
+	}
+	if path != rp.Path && !strings.HasPrefix(path, rp.Path+"/") {
+		return false
+	}
+	return rp.Scope == RemovedModule || version == rp.Version
+}
+
+// InsertRemovedPath creates or replaces the removed path for rp.Path,
+// rp.Scope and rp.Version.
+func (db *DB) InsertRemovedPath(ctx context.Context, rp *RemovedPath) (err error) {
+	defer derrors.Wrap(&err, "InsertRemovedPath(ctx, %q, %q, %q)", rp.Path, rp.Scope, rp.Version)
+
+	if rp.Path == "" || rp.Reason == "" || rp.CreatedBy == "" {
+		return fmt.Errorf("path, reason and user must be non-empty: %w", derrors.InvalidArgument)
+	}
+	switch rp.Scope {
+	case RemovedVersion:
+		if rp.Version == "" {
+			return fmt.Errorf("scope %q needs a version: %w", rp.Scope, derrors.InvalidArgument)
+		}
+	case RemovedModule, RemovedPrefix:
+		if rp.Version != "" {
+			return fmt.Errorf("scope %q does not take a version: %w", rp.Scope, derrors.InvalidArgument)
+		}
+	default:
+		return fmt.Errorf("unknown scope %q: %w", rp.Scope, derrors.InvalidArgument)
+	}
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO removed_paths (path, scope, version, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (path, scope, version) DO UPDATE SET
+			reason = excluded.reason,
+			created_by = excluded.created_by,
+			created_at = CURRENT_TIMESTAMP`,
+		rp.Path, rp.Scope, rp.Version, rp.Reason, rp.CreatedBy)
+	// Arrange for this instance to re-read removed_paths on the next call to
+	// GetRemovedPath. Other instances see the change within
+	// removedPathsExpiration.
+	setRemovedPathsLastFetched(time.Time{})
+	if err != nil {
+		return err
+	}
+	db.runRemovedPathHooks(ctx, rp.Path, rp.Scope)
+	return nil
+}
+
+// DeleteRemovedPath deletes the removed path for path, scope and version. It
+// returns a derrors.NotFound error if there is no such removed path.
+func (db *DB) DeleteRemovedPath(ctx context.Context, path, scope, version string) (err error) {
+	defer derrors.Wrap(&err, "DeleteRemovedPath(ctx, %q, %q, %q)", path, scope, version)
+
+	res, err := db.db.Exec(ctx, `DELETE FROM removed_paths WHERE path = $1 AND scope = $2 AND version = $3`,
+		path, scope, version)
+	setRemovedPathsLastFetched(time.Time{})
+	if err != nil {
+		return err
+	}
+	if err := notFoundIfNoRows(res); err != nil {
+		return err
+	}
+	db.runRemovedPathHooks(ctx, path, scope)
+	return nil
+}
+
+// runRemovedPathHooks runs the module hooks for the paths that a removed path
+// with path and scope applies to, so that the pages that show them are no
+// longer served from a cache once it is inserted or deleted. Pages are tagged
+// with their own path and that of their module, so only a prefix needs the
+// paths below it.
+func (db *DB) runRemovedPathHooks(ctx context.Context, path, scope string) {
+	if len(db.moduleHooks) == 0 {
+		return
+	}
+	paths := []string{path}
+	if scope == RemovedPrefix {
+		// Compare with left rather than LIKE, which would treat underscores
+		// in paths as wildcards.
+		err := db.db.RunQuery(ctx, `
+			SELECT DISTINCT path FROM paths WHERE left(path, length($1)) = $1`,
+			func(rows *sql.Rows) error {
+				var p string
+				if err := rows.Scan(&p); err != nil {
+					return err
+				}
+				paths = append(paths, p)
+				return nil
+			}, path+"/")
+		if err != nil {
+			log.Errorf(ctx, "reading the paths removed by prefix %q: %v", path, err)
+		}
+	}
+	db.runModuleHooks(ctx, path, paths)
+}
+
+// GetRemovedPaths returns all removed paths, ordered by path, scope and
+// version.
+func (db *DB) GetRemovedPaths(ctx context.Context) (_ []*RemovedPath, err error) {
+	defer derrors.Wrap(&err, "GetRemovedPaths(ctx)")
+	return db.readRemovedPaths(ctx)
+}
+
+// GetRemovedPath returns the removed path that removes path at version, or
+// nil if path is not removed. If several do, it returns the one with the
+// longest path.
+//
+// If version is internal.LatestVersion, a removed version applies if it is the
+// latest version of its module.
+func (db *DB) GetRemovedPath(ctx context.Context, path, version string) (_ *RemovedPath, err error) {
+	defer derrors.Wrap(&err, "DB.GetRemovedPath(ctx, %q, %q)", path, version)
+
+	db.ensureRemovedPaths(ctx)
+	removedPaths.mu.Lock()
+	rps, err := removedPaths.paths, removedPaths.err
+	removedPaths.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var best *RemovedPath
+	for _, rp := range rps {
+		if best != nil && len(rp.Path) <= len(best.Path) {
+			continue
+		}
+		v := version
+		if rp.Scope == RemovedVersion && version == internal.LatestVersion && rp.removes(path, rp.Version) {
+			mi, err := db.LegacyGetModuleInfo(ctx, rp.Path, internal.LatestVersion)
+			if err != nil && !errors.Is(err, derrors.NotFound) {
+				return nil, err
+			}
+			if mi != nil {
+				v = mi.Version
+			}
+		}
+		if rp.removes(path, v) {
+			best = rp
+		}
+	}
+	if best != nil {
+		log.Infof(ctx, "%s@%s matched removed path %q (%s)", path, version, best.Path, best.Scope)
+	}
+	return best, nil
+}
+
+// In-memory copy of removed_paths, which is read on every request to the
+// frontend.
+var removedPaths struct {
+	mu          sync.Mutex
+	paths       []*RemovedPath
+	err         error
+	lastFetched time.Time
+}
+
+func setRemovedPathsLastFetched(t time.Time) {
+	removedPaths.mu.Lock()
+	removedPaths.lastFetched = t
+	removedPaths.mu.Unlock()
+}
+
+const removedPathsExpiration = time.Minute
+
+// ensureRemovedPaths makes sure the in-memory copy of the removed_paths table
+// is up to date.
+func (db *DB) ensureRemovedPaths(ctx context.Context) {
+	removedPaths.mu.Lock()
+	lastFetched := removedPaths.lastFetched
+	removedPaths.mu.Unlock()
+	if time.Since(lastFetched) < removedPathsExpiration {
+		return
+	}
+	rps, err := db.readRemovedPaths(ctx)
+	removedPaths.mu.Lock()
+	defer removedPaths.mu.Unlock()
+	removedPaths.paths = rps
+	removedPaths.err = err
+	if err != nil {
+		log.Errorf(ctx, "reading removed_paths: %v", err)
+		return
+	}
+	removedPaths.lastFetched = time.Now()
+}
+
+// readRemovedPaths reads all the removed paths from the database.
+func (db *DB) readRemovedPaths(ctx context.Context) ([]*RemovedPath, error) {
+	var rps []*RemovedPath
+	err := db.db.CollectStructs(ctx, &rps, `
+		SELECT path, scope, version, reason, created_by, created_at
+		FROM removed_paths
+		ORDER BY path, scope, version`)
+	if err != nil {
+		return nil, err
+	}
+	return rps, nil
+}
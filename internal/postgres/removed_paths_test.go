@@ -0,0 +1,121 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestRemovedPaths(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, v := range []string{"v1.0.0", "v1.1.0"} {
+		if err := testDB.InsertModule(ctx, sample.Module("example.com/a", v, "pkg")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rp := range []*RemovedPath{
+		{Path: "example.com/a", Scope: RemovedVersion, Version: "v1.1.0", Reason: "version takedown", CreatedBy: "alice"},
+		{Path: "example.com/b", Scope: RemovedModule, Reason: "module takedown", CreatedBy: "alice"},
+		{Path: "example.com/c", Scope: RemovedPrefix, Reason: "prefix takedown", CreatedBy: "bob"},
+	} {
+		if err := testDB.InsertRemovedPath(ctx, rp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		path, version string
+		want          string // reason, or "" if not removed
+	}{
+		{"example.com/a", "v1.1.0", "version takedown"},
+		{"example.com/a/pkg", "v1.1.0", "version takedown"},
+		{"example.com/a/pkg", internal.LatestVersion, "version takedown"},
+		{"example.com/a/pkg", "v1.0.0", ""},
+		{"example.com/b/pkg", "v2.0.0", "module takedown"},
+		{"example.com/bb", "v1.0.0", ""},
+		{"example.com/cc/pkg", "v1.0.0", "prefix takedown"},
+		{"example.com/d", internal.LatestVersion, ""},
+	} {
+		rp, err := testDB.GetRemovedPath(ctx, test.path, test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if rp != nil {
+			got = rp.Reason
+		}
+		if got != test.want {
+			t.Errorf("GetRemovedPath(%q, %q): reason = %q, want %q", test.path, test.version, got, test.want)
+		}
+	}
+
+	err := testDB.InsertRemovedPath(ctx, &RemovedPath{Path: "example.com/e", Scope: RemovedModule, Version: "v1.0.0", Reason: "r", CreatedBy: "u"})
+	if !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("InsertRemovedPath with module scope and version: got %v, want InvalidArgument", err)
+	}
+
+	if err := testDB.DeleteRemovedPath(ctx, "example.com/b", RemovedModule, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := testDB.DeleteRemovedPath(ctx, "example.com/b", RemovedModule, ""); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("deleting twice: got %v, want NotFound", err)
+	}
+	rps, err := testDB.GetRemovedPaths(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rps) != 2 {
+		t.Errorf("got %d removed paths after delete, want 2", len(rps))
+	}
+	if rp, err := testDB.GetRemovedPath(ctx, "example.com/b", "v1.0.0"); err != nil || rp != nil {
+		t.Errorf("GetRemovedPath after delete = %v, %v; want nil, nil", rp, err)
+	}
+}
+
+func TestRemovedPathHooks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	if err := testDB.InsertModule(ctx, sample.Module("example.com/c/m", sample.VersionString, "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	// Use a separate DB, so the hook doesn't affect other tests.
+	db := New(testDB.db)
+	db.AddModuleHook(func(_ context.Context, _ string, paths []string) {
+		sort.Strings(paths)
+		got = append(got, paths)
+	})
+	if err := db.InsertRemovedPath(ctx, &RemovedPath{Path: "example.com/b", Scope: RemovedModule, Reason: "r", CreatedBy: "u"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.InsertRemovedPath(ctx, &RemovedPath{Path: "example.com/c", Scope: RemovedPrefix, Reason: "r", CreatedBy: "u"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DeleteRemovedPath(ctx, "example.com/b", RemovedModule, ""); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"example.com/b"},
+		{"example.com/c", "example.com/c/m", "example.com/c/m/pkg"},
+		{"example.com/b"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("hook calls mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/source"
+)
+
+// RepoStatus is the status of a source repository, as last reported by its
+// hosting site. Status is one of the source.Repo* constants.
+type RepoStatus struct {
+	RepoURL   string
+	Status    string
+	CheckedAt time.Time
+}
+
+// IsInactive reports whether the repo is archived or deleted.
+func (s *RepoStatus) IsInactive() bool {
+	return s.Status == source.RepoArchived || s.Status == source.RepoDeleted
+}
+
+// GetRepoStatus returns the status of the repo at repoURL. It returns a
+// derrors.NotFound error if the repo has not been checked.
+func (db *DB) GetRepoStatus(ctx context.Context, repoURL string) (_ *RepoStatus, err error) {
+	defer derrors.Wrap(&err, "GetRepoStatus(ctx, %q)", repoURL)
+
+	s := &RepoStatus{RepoURL: repoURL}
+	err = db.db.QueryRow(ctx, `SELECT status, checked_at FROM repo_status WHERE repo_url = $1`,
+		repoURL).Scan(&s.Status, &s.CheckedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, derrors.NotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetReposToCheck returns up to limit repos of the latest versions of
+// modules, whose status has not been checked since checkedBefore. Repos that
+// have never been checked come first, followed by those that were checked
+// longest ago.
+func (db *DB) GetReposToCheck(ctx context.Context, checkedBefore time.Time, limit int) (_ []string, err error) {
+	defer derrors.Wrap(&err, "GetReposToCheck(ctx, %s, %d)", checkedBefore, limit)
+
+	query := `
+		SELECT u.repo_url
+		FROM (
+			SELECT DISTINCT m.source_info->>'RepoURL' AS repo_url
+			FROM search_documents sd
+			INNER JOIN modules m
+			ON sd.module_path = m.module_path AND sd.version = m.version
+			WHERE m.source_info->>'RepoURL' <> ''
+		) u
+		LEFT JOIN repo_status r ON r.repo_url = u.repo_url
+		WHERE r.checked_at IS NULL OR r.checked_at < $1
+		ORDER BY r.checked_at NULLS FIRST, u.repo_url
+		LIMIT $2`
+	var urls []string
+	collect := func(rows *sql.Rows) error {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return err
+		}
+		urls = append(urls, u)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, checkedBefore, limit); err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// UpdateRepoStatus records the status of the repo at repoURL, and updates the
+// search documents of the modules in it so that inactive repos are ranked
+// lower.
+func (db *DB) UpdateRepoStatus(ctx context.Context, repoURL, status string) (err error) {
+	defer derrors.Wrap(&err, "UpdateRepoStatus(ctx, %q, %q)", repoURL, status)
+
+	s := &RepoStatus{RepoURL: repoURL, Status: status}
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO repo_status (repo_url, status)
+			VALUES ($1, $2)
+			ON CONFLICT (repo_url) DO UPDATE SET
+				status = excluded.status,
+				checked_at = CURRENT_TIMESTAMP`,
+			repoURL, status); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE search_documents sd
+			SET repo_inactive = $2
+			FROM modules m
+			WHERE sd.module_path = m.module_path
+			AND sd.version = m.version
+			AND m.source_info->>'RepoURL' = $1
+			AND sd.repo_inactive <> $2`,
+			repoURL, s.IsInactive())
+		return err
+	})
+}
+
+// MarkRepoStatusChecked records that the repo at repoURL was checked without
+// learning its status, as when the check failed, so that it is not checked
+// again too soon. The status recorded by the last successful check is kept;
+// a repo that was never checked is recorded with an unknown status.
+func (db *DB) MarkRepoStatusChecked(ctx context.Context, repoURL string) (err error) {
+	defer derrors.Wrap(&err, "MarkRepoStatusChecked(ctx, %q)", repoURL)
+
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO repo_status (repo_url, status)
+		VALUES ($1, $2)
+		ON CONFLICT (repo_url) DO UPDATE SET
+			checked_at = CURRENT_TIMESTAMP`,
+		repoURL, source.RepoUnknown)
+	return err
+}
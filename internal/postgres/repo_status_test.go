@@ -0,0 +1,93 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestRepoStatus(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, path := range []string{"github.com/a/active", "github.com/a/archived"} {
+		if err := testDB.InsertModule(ctx, sample.Module(path, sample.VersionString, "p")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const archivedURL = "https://github.com/a/archived"
+	if _, err := testDB.GetRepoStatus(ctx, archivedURL); !errors.Is(err, derrors.NotFound) {
+		t.Fatalf("GetRepoStatus before check: got %v, want NotFound", err)
+	}
+
+	checkedBefore := time.Now().Add(-time.Hour)
+	got, err := testDB.GetReposToCheck(ctx, checkedBefore, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://github.com/a/active", archivedURL}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetReposToCheck mismatch (-want +got):\n%s", diff)
+	}
+
+	if err := testDB.UpdateRepoStatus(ctx, archivedURL, source.RepoArchived); err != nil {
+		t.Fatal(err)
+	}
+	st, err := testDB.GetRepoStatus(ctx, archivedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Status != source.RepoArchived || !st.IsInactive() {
+		t.Errorf("GetRepoStatus: got status %q, want %q", st.Status, source.RepoArchived)
+	}
+	got, err = testDB.GetReposToCheck(ctx, checkedBefore, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"https://github.com/a/active"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("GetReposToCheck after check mismatch (-want +got):\n%s", diff)
+	}
+
+	var inactive bool
+	if err := testDB.db.QueryRow(ctx, `SELECT repo_inactive FROM search_documents WHERE module_path = $1`,
+		"github.com/a/archived").Scan(&inactive); err != nil {
+		t.Fatal(err)
+	}
+	if !inactive {
+		t.Error("search_documents.repo_inactive = false, want true")
+	}
+
+	// A failed check keeps the last status, but counts as a check.
+	if err := testDB.MarkRepoStatusChecked(ctx, archivedURL); err != nil {
+		t.Fatal(err)
+	}
+	st2, err := testDB.GetRepoStatus(ctx, archivedURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st2.Status != source.RepoArchived || !st2.CheckedAt.After(st.CheckedAt) {
+		t.Errorf("after MarkRepoStatusChecked: got status %q checked at %s, want %q checked after %s",
+			st2.Status, st2.CheckedAt, source.RepoArchived, st.CheckedAt)
+	}
+	// A repo that was never checked is recorded as unknown.
+	const activeURL = "https://github.com/a/active"
+	if err := testDB.MarkRepoStatusChecked(ctx, activeURL); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := testDB.GetRepoStatus(ctx, activeURL); err != nil || st.Status != source.RepoUnknown {
+		t.Errorf("GetRepoStatus after MarkRepoStatusChecked: got %+v, %v; want status %q", st, err, source.RepoUnknown)
+	}
+}
@@ -6,14 +6,13 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
-	"net/http"
 	"strconv"
 
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/modstatus"
 )
 
 // UpdateModuleVersionStatesForReprocessing marks modules to be reprocessed
@@ -21,12 +20,7 @@ import (
 func (db *DB) UpdateModuleVersionStatesForReprocessing(ctx context.Context, appVersion string) (err error) {
 	defer derrors.Wrap(&err, "UpdateModuleVersionStatesForReprocessing(ctx, %q)", appVersion)
 
-	for _, status := range []int{
-		http.StatusOK,
-		derrors.ToHTTPStatus(derrors.HasIncompletePackages),
-		derrors.ToHTTPStatus(derrors.BadModule),
-		derrors.ToHTTPStatus(derrors.AlternativeModule),
-	} {
+	for _, status := range modstatus.Reprocessable() {
 		query := `UPDATE module_version_states
 			SET
 				status = $2,
@@ -36,7 +30,7 @@ func (db *DB) UpdateModuleVersionStatesForReprocessing(ctx context.Context, appV
 				app_version < $1
 				AND status = $3;`
 		result, err := db.db.Exec(ctx, query, appVersion,
-			derrors.ToReprocessStatus(status), status)
+			status.Reprocess(), status)
 		if err != nil {
 			return err
 		}
@@ -46,7 +40,7 @@ func (db *DB) UpdateModuleVersionStatesForReprocessing(ctx context.Context, appV
 		}
 		log.Infof(ctx,
 			"Updated module_version_states with status=%d and app_version < %q to status=%d; %d affected",
-			status, appVersion, derrors.ToReprocessStatus(status), affected)
+			int(status), appVersion, int(status.Reprocess()), affected)
 	}
 	return nil
 }
@@ -80,47 +74,35 @@ func (db *DB) GetNextModulesToFetch(ctx context.Context, limit int) (_ []*intern
 	}{
 		{
 			message: "latest version of modules with ReprocessStatusOK or ReprocessHasIncompletePackages",
-			query: constructRequeueQuery(getLatestModuleVersionStates, []error{
-				derrors.ReprocessStatusOK, derrors.ReprocessHasIncompletePackages,
-			}),
+			query: constructRequeueQuery(getLatestModuleVersionStates,
+				modstatus.ReprocessOK, modstatus.ReprocessHasIncompletePackages),
 		},
 		{
 			message: "latest version of modules with ReprocessBadModule or ReprocessAlternative",
-			query: constructRequeueQuery(getLatestModuleVersionStates, []error{
-				derrors.ReprocessBadModule, derrors.ReprocessAlternative,
-			}),
+			query: constructRequeueQuery(getLatestModuleVersionStates,
+				modstatus.ReprocessBadModule, modstatus.ReprocessAlternative),
 		},
 		{
 			message: "non-latest version of modules with ReprocessStatusOK or ReprocessHasIncompletePackages",
-			query: constructRequeueQuery(getModuleVersionStates, []error{
-				derrors.ReprocessStatusOK, derrors.ReprocessHasIncompletePackages,
-			}),
+			query: constructRequeueQuery(getModuleVersionStates,
+				modstatus.ReprocessOK, modstatus.ReprocessHasIncompletePackages),
 		},
 		{
 			message: "non-latest version of modules with ReprocessBadModule or ReprocessAlternative",
-			query: constructRequeueQuery(getModuleVersionStates, []error{
-				derrors.ReprocessBadModule, derrors.ReprocessAlternative,
-			}),
+			query: constructRequeueQuery(getModuleVersionStates,
+				modstatus.ReprocessBadModule, modstatus.ReprocessAlternative),
 		},
 		{
 			message: fmt.Sprintf("modules with status=0 or status=500 or num_packages > %d",
 				largeModulePackageThreshold),
-			query: fmt.Sprintf(getModuleVersionStatesRemainder, moduleVersionStateColumns),
+			query: getModuleVersionStatesRemainder(),
 			limit: largeModulesLimit,
 		},
 	} {
 		if next.limit == 0 {
 			next.limit = limit
 		}
-		collect := func(rows *sql.Rows) error {
-			mv, err := scanModuleVersionState(rows.Scan)
-			if err != nil {
-				return err
-			}
-			mvs = append(mvs, mv)
-			return nil
-		}
-		if err := db.db.RunQuery(ctx, next.query, collect, next.limit); err != nil {
+		if err := db.db.CollectStructs(ctx, &mvs, next.query, next.limit); err != nil {
 			return nil, err
 		}
 		if len(mvs) > 0 {
@@ -144,17 +126,10 @@ func (db *DB) GetNextModulesToFetch(ctx context.Context, limit int) (_ []*intern
 	return mvs, nil
 }
 
-func constructRequeueQuery(baseQuery string, statusErrors []error) string {
-	where := "WHERE next_processed_after < CURRENT_TIMESTAMP"
+func constructRequeueQuery(baseQuery string, statuses ...modstatus.Code) string {
+	where := "WHERE next_processed_after < CURRENT_TIMESTAMP AND dead_lettered_at IS NULL"
 	where += fmt.Sprintf(" AND COALESCE(num_packages, 0) < %d", largeModulePackageThreshold)
-	var s string
-	for i, serr := range statusErrors {
-		s += fmt.Sprintf("status=%d", derrors.ToHTTPStatus(serr))
-		if i < len(statusErrors)-1 {
-			s += " OR "
-		}
-	}
-	where += fmt.Sprintf(" AND (%s)", s)
+	where += " AND " + modstatus.InSQL("status", statuses...)
 	return fmt.Sprintf(baseQuery, moduleVersionStateColumns, where)
 }
 
@@ -214,17 +189,26 @@ ORDER BY
     module_path
 LIMIT $1`
 
-const getModuleVersionStatesRemainder = `
+// getModuleVersionStatesRemainder returns the query for the module versions
+// that need processing and were not selected by the other queries of
+// GetNextModulesToFetch.
+func getModuleVersionStatesRemainder() string {
+	return fmt.Sprintf(`
 SELECT %s
 FROM module_version_states
 WHERE next_processed_after < CURRENT_TIMESTAMP
-AND (status >= 500 OR status=0)
+AND dead_lettered_at IS NULL
+AND (status >= %d OR status = %d)
 ORDER BY
-    CASE WHEN status=0 THEN 0
-         WHEN (status=520 OR status=521) THEN 1
-         WHEN (status=540 OR status=541) THEN 2
+    CASE WHEN status = %[3]d THEN 0
+         WHEN %[4]s THEN 1
+         WHEN %[5]s THEN 2
          ELSE 3 END,
     COALESCE(num_packages, 0),
     sort_version DESC,
     module_path
-LIMIT $1`
+LIMIT $1`,
+		moduleVersionStateColumns, modstatus.InternalError, modstatus.Unprocessed,
+		modstatus.InSQL("status", modstatus.ReprocessOK, modstatus.ReprocessHasIncompletePackages),
+		modstatus.InSQL("status", modstatus.ReprocessBadModule, modstatus.ReprocessAlternative))
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/modstatus"
 	"golang.org/x/pkgsite/internal/version"
 )
 
@@ -91,7 +92,7 @@ func TestGetNextModulesToFetchAndUpdateModuleVersionStatesForReprocessing(t *tes
 			m := &internal.ModuleVersionState{
 				ModulePath: data.modulePath,
 				Version:    data.version,
-				Status:     derrors.ToReprocessStatus(data.status),
+				Status:     int(modstatus.Code(data.status).Reprocess()),
 			}
 			if data.numPackages != 0 {
 				m.NumPackages = &data.numPackages
@@ -118,8 +119,15 @@ func TestGetNextModulesToFetchAndUpdateModuleVersionStatesForReprocessing(t *tes
 	}
 	updateStates := func(wantData []*testData) {
 		for _, m := range wantData {
-			if err := upsertModuleVersionState(ctx, testDB.db, m.modulePath, m.version, "2020-04-29t14", &m.numPackages, now, m.status,
-				m.modulePath, derrors.FromHTTPStatus(m.status, "test string")); err != nil {
+			if err := upsertModuleVersionState(ctx, testDB.db, &m.numPackages, &internal.ModuleVersionStateForUpsert{
+				ModulePath: m.modulePath,
+				Version:    m.version,
+				AppVersion: "2020-04-29t14",
+				Timestamp:  now,
+				Status:     m.status,
+				GoModPath:  m.modulePath,
+				FetchErr:   derrors.FromHTTPStatus(m.status, "test string"),
+			}); err != nil {
 				t.Fatal(err)
 			}
 		}
@@ -146,7 +154,15 @@ func TestGetNextModulesToFetchAndUpdateModuleVersionStatesForReprocessing(t *tes
 
 	// Mark all modules for reprocessing.
 	for _, m := range mods {
-		if err := upsertModuleVersionState(ctx, testDB.db, m.modulePath, m.version, "2020-04-29t14", &m.numPackages, now, m.status, m.modulePath, derrors.FromHTTPStatus(m.status, "test string")); err != nil {
+		if err := upsertModuleVersionState(ctx, testDB.db, &m.numPackages, &internal.ModuleVersionStateForUpsert{
+			ModulePath: m.modulePath,
+			Version:    m.version,
+			AppVersion: "2020-04-29t14",
+			Timestamp:  now,
+			Status:     m.status,
+			GoModPath:  m.modulePath,
+			FetchErr:   derrors.FromHTTPStatus(m.status, "test string"),
+		}); err != nil {
 			t.Fatal(err)
 		}
 	}
@@ -18,11 +18,11 @@ import (
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
-	"go.opencensus.io/trace"
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/stdlib"
 )
@@ -53,6 +53,28 @@ var (
 		Description: "Search count, by result source query type.",
 		TagKeys:     []tag.Key{keySearchSource},
 	}
+
+	// keySearcherOutcome counts the responses of the searchers of a hedged
+	// search.
+	keySearcherOutcome = stats.Int64(
+		"go-discovery/search/searcher_outcome",
+		"Outcome of a searcher in a hedged search.",
+		stats.UnitDimensionless,
+	)
+	// keySearchOutcome is a census tag for the outcome of a searcher: "won"
+	// if its results were used, "lost" if they arrived too late or were not
+	// counted, "cancelled" if it failed after another searcher won, or
+	// "error".
+	keySearchOutcome = tag.MustNewKey("search.outcome")
+	// SearcherOutcomeCount counts searcher responses by search query type and
+	// outcome, to show which searcher wins for how many searches.
+	SearcherOutcomeCount = &view.View{
+		Name:        "go-discovery/search/searcher_outcome_count",
+		Measure:     keySearcherOutcome,
+		Aggregation: view.Count(),
+		Description: "Searcher responses, by result source query type and outcome.",
+		TagKeys:     []tag.Key{keySearchSource, keySearchOutcome},
+	}
 )
 
 // searchResponse is used for internal bookkeeping when fanning-out search
@@ -119,24 +141,137 @@ var searchers = map[string]searcher{
 // the penalty of a deep search that scans nearly every package.
 func (db *DB) Search(ctx context.Context, q string, limit, offset int) (_ []*internal.SearchResult, err error) {
 	defer derrors.Wrap(&err, "DB.Search(ctx, %q, %d, %d)", q, limit, offset)
-	resp, err := db.hedgedSearch(ctx, q, limit, offset, searchers, nil)
+	q, filter := parseSearchFilters(q)
+	ss := searchers
+	if filter != "" {
+		// Popular search is a stored procedure that cannot apply filters, so
+		// filtered searches only use deep search.
+		ss = map[string]searcher{
+			"deep": func(db *DB, ctx context.Context, q string, limit, offset int) searchResponse {
+				return db.filteredDeepSearch(ctx, q, filter, limit, offset)
+			},
+		}
+	}
+	resp, err := db.hedgedSearch(ctx, q, limit, offset, ss, nil)
+	if err != nil {
+		return nil, err
+	}
+	return db.filterSearchResults(ctx, resp.results)
+}
+
+// SearchGrouped is like Search, but groups the results by module. It returns
+// the results for up to limit modules, starting at offset in the list of
+// matching modules ordered by the score of their best package. Each module
+// has up to perModule results, the best first. The NumModuleResults of a
+// result is the number of packages of its module that matched, and its
+// NumResults is the number of modules that matched.
+//
+// Grouping needs the score of every matching package, so SearchGrouped only
+// uses deep search.
+func (db *DB) SearchGrouped(ctx context.Context, q string, limit, offset, perModule int) (_ []*internal.SearchResult, err error) {
+	defer derrors.Wrap(&err, "DB.SearchGrouped(ctx, %q, %d, %d, %d)", q, limit, offset, perModule)
+	q, filter := parseSearchFilters(q)
+	results, err := db.groupedDeepSearch(ctx, q, filter, limit, offset, perModule)
 	if err != nil {
 		return nil, err
 	}
-	// Filter out excluded paths.
+	if err := db.addPackageDataToSearchResults(ctx, results); err != nil {
+		return nil, err
+	}
+	return db.filterSearchResults(ctx, results)
+}
+
+// filterSearchResults removes the results whose paths are not served,
+// excluded or removed.
+func (db *DB) filterSearchResults(ctx context.Context, rs []*internal.SearchResult) ([]*internal.SearchResult, error) {
 	var results []*internal.SearchResult
-	for _, r := range resp.results {
+	for _, r := range rs {
+		if !internal.IsServed(r.PackagePath) {
+			continue
+		}
 		ex, err := db.IsExcluded(ctx, r.PackagePath)
 		if err != nil {
 			return nil, err
 		}
-		if !ex {
+		if ex {
+			continue
+		}
+		rp, err := db.GetRemovedPath(ctx, r.PackagePath, r.Version)
+		if err != nil {
+			return nil, err
+		}
+		if rp == nil {
 			results = append(results, r)
 		}
 	}
 	return results, nil
 }
 
+// Search filters, which select packages by whether advisories apply to the
+// version in search_documents, that is, their latest version.
+const (
+	vulnerableFilter    = "is:vulnerable"
+	notVulnerableFilter = "-is:vulnerable"
+)
+
+// ownerFilterPrefix begins the search filter that selects packages by the
+// owner of their module, like owner:spf13 or owner:gitlab.com/gitlab-org.
+const ownerFilterPrefix = "owner:"
+
+// ownerHosts are the code hosting sites that search documents have owners
+// for. They must match the search_owner SQL function.
+var ownerHosts = []string{"github.com", "gitlab.com", "bitbucket.org"}
+
+// parseSearchFilters removes the search filters from q. It returns the rest of
+// the query, and an SQL condition on search_documents for the filters, or the
+// empty string if there are none. If several filters of the same kind are
+// given, the last one wins.
+func parseSearchFilters(q string) (query, filter string) {
+	var (
+		words                   []string
+		vulnFilter, ownerFilter string
+	)
+	for _, w := range strings.Fields(q) {
+		lw := strings.ToLower(w)
+		switch {
+		case lw == vulnerableFilter:
+			vulnFilter = "num_vulns > 0"
+		case lw == notVulnerableFilter:
+			vulnFilter = "num_vulns = 0"
+		case strings.HasPrefix(lw, ownerFilterPrefix) && len(lw) > len(ownerFilterPrefix):
+			ownerFilter = ownerCondition(lw[len(ownerFilterPrefix):])
+		default:
+			words = append(words, w)
+		}
+	}
+	var conds []string
+	for _, c := range []string{vulnFilter, ownerFilter} {
+		if c != "" {
+			conds = append(conds, c)
+		}
+	}
+	if len(conds) == 0 {
+		return q, ""
+	}
+	return strings.Join(words, " "), strings.Join(conds, " AND ")
+}
+
+// ownerCondition returns the SQL condition on search_documents for the owner
+// filter with value owner. An owner with a host, like github.com/spf13,
+// selects that owner; one without selects the owners with that name on all
+// of the ownerHosts.
+func ownerCondition(owner string) string {
+	owner = strings.Trim(owner, "/")
+	if strings.Contains(owner, "/") {
+		return "owner = " + pq.QuoteLiteral(owner)
+	}
+	var owners []string
+	for _, h := range ownerHosts {
+		owners = append(owners, pq.QuoteLiteral(h+"/"+owner))
+	}
+	return fmt.Sprintf("owner IN (%s)", strings.Join(owners, ", "))
+}
+
 // Penalties to search scores, applied as multipliers to the score.
 const (
 	// Module license is non-redistributable.
@@ -145,29 +280,70 @@ const (
 	// Start this off gently (close to 1), but consider lowering
 	// it as time goes by and more of the ecosystem converts to modules.
 	noGoModPenalty = 0.8
+	// Module's repository is archived or deleted.
+	inactiveRepoPenalty = 0.5
+	// Package has no doc comments. Packages with some are penalized in
+	// proportion to the fraction of their identifiers without them.
+	undocumentedPenalty = 0.8
+	// Module has an approved redirect to a new module path.
+	movedModulePenalty = 0.25
 )
 
+// The factors of the search score that do not depend on the query, as
+// expressions on search_documents. Their product is qualityExpr.
+var (
+	// The log of the module's popularity, estimated by the number of importing packages.
+	// The log factor contains exp(1) so that it is always >= 1. Taking the log
+	// of imported_by_count instead of using it directly makes the effect less
+	// dramatic: being 2x as popular only has an additive effect.
+	popularityFactorExpr = `ln(exp(1)+imported_by_count)`
+	// A penalty factor for non-redistributable modules, since a lot of
+	// details cannot be displayed.
+	redistributableFactorExpr = fmt.Sprintf(`CASE WHEN redistributable THEN 1 ELSE %f END`, nonRedistributablePenalty)
+	// A penalty factor for modules without a go.mod file.
+	goModFactorExpr = fmt.Sprintf(`CASE WHEN COALESCE(has_go_mod, true) THEN 1 ELSE %f END`, noGoModPenalty)
+	// A penalty factor for modules whose repository is archived or deleted,
+	// since they are unlikely to be maintained.
+	repoFactorExpr = fmt.Sprintf(`CASE WHEN repo_inactive THEN %f ELSE 1 END`, inactiveRepoPenalty)
+	// A penalty factor for packages whose identifiers lack doc comments.
+	// Packages whose coverage is not known are not penalized.
+	docCoverageFactorExpr = fmt.Sprintf(`CASE WHEN documentation_coverage IS NULL THEN 1
+		ELSE 1 - (1 - documentation_coverage) * (1 - %f) END`, undocumentedPenalty)
+	// A penalty factor for modules that moved to a new module path, so that
+	// the new path ranks above them.
+	movedFactorExpr = fmt.Sprintf(`CASE WHEN module_moved THEN %f ELSE 1 END`, movedModulePenalty)
+
+	qualityExpr = strings.Join([]string{
+		popularityFactorExpr,
+		redistributableFactorExpr,
+		goModFactorExpr,
+		repoFactorExpr,
+		docCoverageFactorExpr,
+		movedFactorExpr,
+	}, " *\n\t\t")
+)
+
+// searchTSQuery is the SQL expression for the text-search query of the search
+// query $1, expanded with the synonyms in search_synonyms. The subquery makes
+// Postgres compute it once per statement, rather than once per row.
+const searchTSQuery = "(SELECT search_tsquery($1))"
+
 // scoreExpr is the expression that computes the search score.
-// It is the product of:
-// - The Postgres ts_rank score, based the relevance of the document to the query.
-// - The log of the module's popularity, estimated by the number of importing packages.
-//   The log factor contains exp(1) so that it is always >= 1. Taking the log
-//   of imported_by_count instead of using it directly makes the effect less
-//   dramatic: being 2x as popular only has an additive effect.
-// - A penalty factor for non-redistributable modules, since a lot of
-//   details cannot be displayed.
+// It is the product of the Postgres ts_rank score, based the relevance of the
+// document to the query, and qualityExpr.
 // The first argument to ts_rank is an array of weights for the four tsvector sections,
 // in the order D, C, B, A.
 // The weights below match the defaults except for B.
-var scoreExpr = fmt.Sprintf(`
-		ts_rank('{0.1, 0.2, 1.0, 1.0}', tsv_search_tokens, websearch_to_tsquery($1)) *
-		ln(exp(1)+imported_by_count) *
-		CASE WHEN redistributable THEN 1 ELSE %f END *
-		CASE WHEN COALESCE(has_go_mod, true) THEN 1 ELSE %f END
-	`, nonRedistributablePenalty, noGoModPenalty)
+// The popular_search stored procedure computes the same score.
+var scoreExpr = `
+		ts_rank('{0.1, 0.2, 1.0, 1.0}', tsv_search_tokens, ` + searchTSQuery + `) *
+		` + qualityExpr + `
+	`
 
 // hedgedSearch executes multiple search methods and returns the first
-// available result.
+// available result. A searcher that fails does not fail the search while
+// other searchers may still succeed. Once a result is chosen, the other
+// searchers are cancelled.
 // The optional guardTestResult func may be used to allow tests to control the
 // order in which search results are returned.
 func (db *DB) hedgedSearch(ctx context.Context, q string, limit, offset int, searchers map[string]searcher, guardTestResult func(string) func()) (*searchResponse, error) {
@@ -211,12 +387,22 @@ func (db *DB) hedgedSearch(ctx context.Context, q string, limit, offset int, sea
 			responses <- resp
 		}()
 	}
-	// Note for future readers: in previous iterations of this code we kept
-	// reading responses if the first one had an error, with the goal to minimize
-	// error ratio. That didn't behave well if Postgres was overloaded.
-	resp := <-responses
-	if resp.err != nil {
-		return nil, fmt.Errorf("%q search failed: %v", resp.source, resp.err)
+	// Use the first response without an error. The searchers are bounded by
+	// the deadline of ctx, so waiting for the others after an error does not
+	// keep them running any longer than they would run anyway.
+	pending := len(searchers)
+	var resp searchResponse
+	for {
+		resp = <-responses
+		pending--
+		if resp.err == nil {
+			break
+		}
+		recordSearcherOutcome(ctx, resp.source, "error")
+		if pending == 0 {
+			return nil, fmt.Errorf("%q search failed: %v", resp.source, resp.err)
+		}
+		log.Infof(ctx, "%q search failed, waiting for other searchers: %v", resp.source, resp.err)
 	}
 	if resp.uncounted {
 		// Since the response is uncounted, we should wait for either the count
@@ -225,17 +411,20 @@ func (db *DB) hedgedSearch(ctx context.Context, q string, limit, offset int, sea
 		for {
 			select {
 			case nextResp := <-responses:
+				pending--
 				switch {
 				case nextResp.err != nil:
-					// There are alternatives here: we could continue waiting for the
-					// estimate. But on the principle that errors are most likely to be
-					// caused by Postgres overload, we exit early to cancel the estimate.
-					return nil, fmt.Errorf("while waiting for count, got error from searcher %q: %v", nextResp.source, nextResp.err)
+					// We already have results, so keep waiting for the estimate.
+					recordSearcherOutcome(ctx, nextResp.source, "error")
+					log.Infof(ctx, "while waiting for count, got error from searcher %q: %v", nextResp.source, nextResp.err)
 				case !nextResp.uncounted:
 					log.Infof(ctx, "using counted search results from searcher %s", nextResp.source)
 					// use this response since it is counted.
+					recordSearcherOutcome(ctx, resp.source, "lost")
 					resp = nextResp
 					break loop
+				default:
+					recordSearcherOutcome(ctx, nextResp.source, "lost")
 				}
 			case estr := <-estimateChan:
 				if estr.err != nil {
@@ -256,6 +445,17 @@ func (db *DB) hedgedSearch(ctx context.Context, q string, limit, offset int, sea
 	}
 	// cancel proactively here: we've got the search result we need.
 	cancel()
+	recordSearcherOutcome(ctx, resp.source, "won")
+	go func(pending int) {
+		for ; pending > 0; pending-- {
+			r := <-responses
+			if r.err != nil {
+				recordSearcherOutcome(ctx, r.source, "cancelled")
+			} else {
+				recordSearcherOutcome(ctx, r.source, "lost")
+			}
+		}
+	}(pending)
 	// latency is only recorded for valid search results, as fast failures could
 	// skew the latency distribution.
 	// Note that this latency measurement might differ meaningfully from the
@@ -274,6 +474,14 @@ func (db *DB) hedgedSearch(ctx context.Context, q string, limit, offset int, sea
 	return &resp, nil
 }
 
+// recordSearcherOutcome records the outcome of the searcher for source in a
+// hedged search.
+func recordSearcherOutcome(ctx context.Context, source, outcome string) {
+	stats.RecordWithTags(ctx,
+		[]tag.Mutator{tag.Upsert(keySearchSource, source), tag.Upsert(keySearchOutcome, outcome)},
+		keySearcherOutcome.M(1))
+}
+
 const hllRegisterCount = 128
 
 // hllQuery estimates search result counts using the hyperloglog algorithm.
@@ -309,7 +517,7 @@ var hllQuery = fmt.Sprintf(`
 				FROM search_documents
 				WHERE (
 					%[2]s *
-					CASE WHEN tsv_search_tokens @@ websearch_to_tsquery($1) THEN 1 ELSE 0 END
+					CASE WHEN tsv_search_tokens @@ %[3]s THEN 1 ELSE 0 END
 				) > 0.1
 				AND hll_register=generate_series
 				ORDER BY hll_leading_zeros DESC
@@ -335,7 +543,7 @@ var hllQuery = fmt.Sprintf(`
 			)::int AS result_count,
 			%[1]d - count(1) AS empty_register_count
 		FROM nonempty_registers
-	) d`, hllRegisterCount, scoreExpr)
+	) d`, hllRegisterCount, scoreExpr, searchTSQuery)
 
 type estimateResponse struct {
 	estimate uint64
@@ -358,6 +566,16 @@ func (db *DB) estimateResultsCount(ctx context.Context, q string) estimateRespon
 // deepSearch searches all packages for the query. It is slower, but results
 // are always valid.
 func (db *DB) deepSearch(ctx context.Context, q string, limit, offset int) searchResponse {
+	return db.filteredDeepSearch(ctx, q, "", limit, offset)
+}
+
+// filteredDeepSearch is like deepSearch, but only returns packages whose
+// search documents satisfy the SQL condition filter, if it is not empty.
+func (db *DB) filteredDeepSearch(ctx context.Context, q, filter string, limit, offset int) searchResponse {
+	ctx = database.WithQueryName(ctx, "deepSearch")
+	if filter != "" {
+		filter = "AND " + filter
+	}
 	query := fmt.Sprintf(`
 		SELECT *, COUNT(*) OVER() AS total
 		FROM (
@@ -370,7 +588,8 @@ func (db *DB) deepSearch(ctx context.Context, q string, limit, offset int) searc
 				(%s) AS score
 				FROM
 					search_documents
-				WHERE tsv_search_tokens @@ websearch_to_tsquery($1)
+				WHERE tsv_search_tokens @@ %s
+				%s
 				ORDER BY
 					score DESC,
 					commit_time DESC,
@@ -378,7 +597,7 @@ func (db *DB) deepSearch(ctx context.Context, q string, limit, offset int) searc
 		) r
 		WHERE r.score > 0.1
 		LIMIT $2
-		OFFSET $3`, scoreExpr)
+		OFFSET $3`, scoreExpr, searchTSQuery, filter)
 	var results []*internal.SearchResult
 	collect := func(rows *sql.Rows) error {
 		var r internal.SearchResult
@@ -400,7 +619,77 @@ func (db *DB) deepSearch(ctx context.Context, q string, limit, offset int) searc
 	}
 }
 
+// groupedDeepSearch is like filteredDeepSearch, but groups the results by
+// module as described in SearchGrouped.
+func (db *DB) groupedDeepSearch(ctx context.Context, q, filter string, limit, offset, perModule int) ([]*internal.SearchResult, error) {
+	ctx = database.WithQueryName(ctx, "groupedDeepSearch")
+	if filter != "" {
+		filter = "AND " + filter
+	}
+	query := fmt.Sprintf(`
+		WITH matches AS (
+			SELECT
+				package_path,
+				version,
+				module_path,
+				commit_time,
+				imported_by_count,
+				(%s) AS score
+			FROM search_documents
+			WHERE tsv_search_tokens @@ %s
+			%s
+		), ranked AS (
+			SELECT
+				*,
+				row_number() OVER (
+					PARTITION BY module_path
+					ORDER BY score DESC, commit_time DESC, package_path
+				) AS module_rank,
+				COUNT(*) OVER (PARTITION BY module_path) AS module_total
+			FROM matches
+			WHERE score > 0.1
+		), modules AS (
+			SELECT
+				module_path,
+				row_number() OVER (ORDER BY score DESC, commit_time DESC, module_path) AS module_order,
+				COUNT(*) OVER () AS total
+			FROM ranked
+			WHERE module_rank = 1
+			ORDER BY module_order
+			LIMIT $2
+			OFFSET $3
+		)
+		SELECT
+			r.package_path,
+			r.version,
+			r.module_path,
+			r.commit_time,
+			r.imported_by_count,
+			r.score,
+			r.module_total,
+			m.total
+		FROM ranked r
+		INNER JOIN modules m ON m.module_path = r.module_path
+		WHERE r.module_rank <= $4
+		ORDER BY m.module_order, r.module_rank`, scoreExpr, searchTSQuery, filter)
+	var results []*internal.SearchResult
+	collect := func(rows *sql.Rows) error {
+		var r internal.SearchResult
+		if err := rows.Scan(&r.PackagePath, &r.Version, &r.ModulePath, &r.CommitTime,
+			&r.NumImportedBy, &r.Score, &r.NumModuleResults, &r.NumResults); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		results = append(results, &r)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, q, limit, offset, perModule); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
 func (db *DB) popularSearch(ctx context.Context, searchQuery string, limit, offset int) searchResponse {
+	ctx = database.WithQueryName(ctx, "popularSearch")
 	query := `
 		SELECT
 			package_path,
@@ -409,7 +698,7 @@ func (db *DB) popularSearch(ctx context.Context, searchQuery string, limit, offs
 			commit_time,
 			imported_by_count,
 			score
-		FROM popular_search($1, $2, $3, $4, $5)`
+		FROM popular_search($1, $2, $3, $4, $5, $6, $7, $8)`
 	var results []*internal.SearchResult
 	collect := func(rows *sql.Rows) error {
 		var r internal.SearchResult
@@ -420,7 +709,8 @@ func (db *DB) popularSearch(ctx context.Context, searchQuery string, limit, offs
 		results = append(results, &r)
 		return nil
 	}
-	err := db.db.RunQuery(ctx, query, collect, searchQuery, limit, offset, nonRedistributablePenalty, noGoModPenalty)
+	err := db.db.RunQuery(ctx, query, collect, searchQuery, limit, offset,
+		nonRedistributablePenalty, noGoModPenalty, inactiveRepoPenalty, undocumentedPenalty, movedModulePenalty)
 	if err != nil {
 		results = nil
 	}
@@ -453,20 +743,29 @@ func (db *DB) addPackageDataToSearchResults(ctx context.Context, results []*inte
 	}
 	query := fmt.Sprintf(`
 		SELECT
-			path,
-			name,
-			synopsis,
-			license_types
+			p.path,
+			p.name,
+			p.synopsis,
+			p.license_types,
+			COALESCE(sd.num_vulns, 0),
+			sd.owner
 		FROM
-			packages
+			packages p
+		LEFT JOIN
+			search_documents sd
+		ON
+			p.path = sd.package_path
+			AND p.version = sd.version
 		WHERE
-			(path, version, module_path) IN (%s)`, strings.Join(keys, ","))
+			(p.path, p.version, p.module_path) IN (%s)`, strings.Join(keys, ","))
 	collect := func(rows *sql.Rows) error {
 		var (
 			path, name, synopsis string
 			licenseTypes         []string
+			numVulns             int
+			owner                string
 		)
-		if err := rows.Scan(&path, &name, &synopsis, pq.Array(&licenseTypes)); err != nil {
+		if err := rows.Scan(&path, &name, &synopsis, pq.Array(&licenseTypes), &numVulns, database.NullIsEmpty(&owner)); err != nil {
 			return fmt.Errorf("rows.Scan(): %v", err)
 		}
 		r, ok := resultMap[path]
@@ -475,6 +774,8 @@ func (db *DB) addPackageDataToSearchResults(ctx context.Context, results []*inte
 		}
 		r.Name = name
 		r.Synopsis = synopsis
+		r.NumVulns = numVulns
+		r.Owner = owner
 		for _, l := range licenseTypes {
 			if l != "" {
 				r.Licenses = append(r.Licenses, l)
@@ -485,8 +786,13 @@ func (db *DB) addPackageDataToSearchResults(ctx context.Context, results []*inte
 	return db.db.RunQuery(ctx, query, collect)
 }
 
-var upsertSearchStatement = fmt.Sprintf(`
-	INSERT INTO search_documents (
+var upsertSearchStatement = upsertSearchStatementFor("search_documents")
+
+// upsertSearchStatementFor returns the statement that upserts a search
+// document into table, which is search_documents or a table like it.
+func upsertSearchStatementFor(table string) string {
+	return fmt.Sprintf(`
+	INSERT INTO %[2]s (
 		package_path,
 		version,
 		module_path,
@@ -497,9 +803,15 @@ var upsertSearchStatement = fmt.Sprintf(`
 		version_updated_at,
 		commit_time,
 		has_go_mod,
+		repo_inactive,
+		module_moved,
+		num_vulns,
+		documentation_coverage,
+		owner,
 		tsv_search_tokens,
 		hll_register,
-		hll_leading_zeros
+		hll_leading_zeros,
+		app_version
 	)
 	SELECT
 		p.path,
@@ -512,6 +824,23 @@ var upsertSearchStatement = fmt.Sprintf(`
 		CURRENT_TIMESTAMP,
 		m.commit_time,
 		m.has_go_mod,
+		EXISTS (
+			SELECT 1 FROM repo_status r
+			WHERE r.repo_url = m.source_info->>'RepoURL'
+			AND r.status IN ('archived', 'deleted')
+		),
+		EXISTS (
+			SELECT 1 FROM module_redirects mr
+			WHERE mr.from_path = m.module_path
+			AND mr.approved_at IS NOT NULL
+		),
+		(
+			SELECT count(*) FROM vuln_affected_versions a
+			WHERE a.module_path = m.module_path
+			AND a.version = m.version
+		),
+		p.documentation_coverage,
+		search_owner(m.module_path, m.source_info->>'RepoURL'),
 		(
 			SETWEIGHT(TO_TSVECTOR('path_tokens', $2), 'A') ||
 			SETWEIGHT(TO_TSVECTOR($3), 'B') ||
@@ -519,7 +848,8 @@ var upsertSearchStatement = fmt.Sprintf(`
 			SETWEIGHT(TO_TSVECTOR($5), 'D')
 		),
 		hll_hash(p.path) & (%[1]d - 1),
-		hll_zeros(hll_hash(p.path))
+		hll_zeros(hll_hash(p.path)),
+		p.app_version
 	FROM
 		packages p
 	INNER JOIN
@@ -549,20 +879,28 @@ var upsertSearchStatement = fmt.Sprintf(`
 		redistributable=excluded.redistributable,
 		commit_time=excluded.commit_time,
 		has_go_mod=excluded.has_go_mod,
+		repo_inactive=excluded.repo_inactive,
+		module_moved=excluded.module_moved,
+		num_vulns=excluded.num_vulns,
+		documentation_coverage=excluded.documentation_coverage,
+		owner=excluded.owner,
 		tsv_search_tokens=excluded.tsv_search_tokens,
+		app_version=excluded.app_version,
 		-- the hll fields are functions of path, so they don't change
 		version_updated_at=(
-			CASE WHEN excluded.version = search_documents.version
-			THEN search_documents.version_updated_at
+			CASE WHEN excluded.version = %[2]s.version
+			THEN %[2]s.version_updated_at
 			ELSE CURRENT_TIMESTAMP
 			END)
-	;`, hllRegisterCount)
+	;`, hllRegisterCount, table)
+}
 
 // UpsertSearchDocuments adds search information for mod ot the search_documents table.
 func UpsertSearchDocuments(ctx context.Context, db *database.DB, mod *internal.Module) (err error) {
 	defer derrors.Wrap(&err, "UpsertSearchDocuments(ctx, %q)", mod.ModulePath)
-	ctx, span := trace.StartSpan(ctx, "UpsertSearchDocuments")
-	defer span.End()
+	ctx, span := dtrace.StartSpan(ctx, "UpsertSearchDocuments", dtrace.ModulePath(mod.ModulePath), dtrace.Version(mod.Version))
+	ctx = database.WithQueryName(ctx, "UpsertSearchDocuments")
+	defer dtrace.End(span, &err)
 	for _, pkg := range mod.LegacyPackages {
 		if isInternalPackage(pkg.Path) {
 			continue
@@ -596,7 +934,12 @@ type upsertSearchDocumentArgs struct {
 // validateModule.
 func UpsertSearchDocument(ctx context.Context, db *database.DB, args upsertSearchDocumentArgs) (err error) {
 	defer derrors.Wrap(&err, "UpsertSearchDocument(ctx, db, %q, %q)", args.PackagePath, args.ModulePath)
+	return upsertSearchDocument(ctx, db, upsertSearchStatement, args)
+}
 
+// upsertSearchDocument is like UpsertSearchDocument, but executes the given
+// upsert statement, from upsertSearchStatementFor.
+func upsertSearchDocument(ctx context.Context, db *database.DB, statement string, args upsertSearchDocumentArgs) error {
 	// Only summarize the README if the package and module have the same path.
 	if args.PackagePath != args.ModulePath {
 		args.ReadmeFilePath = ""
@@ -604,7 +947,7 @@ func UpsertSearchDocument(ctx context.Context, db *database.DB, args upsertSearc
 	}
 	pathTokens := strings.Join(GeneratePathTokens(args.PackagePath), " ")
 	sectionB, sectionC, sectionD := SearchDocumentSections(args.Synopsis, args.ReadmeFilePath, args.ReadmeContents)
-	_, err = db.Exec(ctx, upsertSearchStatement, args.PackagePath, pathTokens, sectionB, sectionC, sectionD)
+	_, err := db.Exec(ctx, statement, args.PackagePath, pathTokens, sectionB, sectionC, sectionD)
 	return err
 }
 
@@ -623,7 +966,7 @@ func (db *DB) GetPackagesForSearchDocumentUpsert(ctx context.Context, before tim
 
 	collect := func(rows *sql.Rows) error {
 		var a upsertSearchDocumentArgs
-		if err := rows.Scan(&a.PackagePath, &a.ModulePath, &a.Synopsis, &a.ReadmeFilePath, &a.ReadmeContents); err != nil {
+		if err := rows.Scan(&a.PackagePath, &a.ModulePath, &a.Synopsis, &a.ReadmeFilePath, database.Compressed(&a.ReadmeContents)); err != nil {
 			return err
 		}
 		argsList = append(argsList, a)
@@ -23,6 +23,7 @@ import (
 	"go.opencensus.io/stats/view"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
@@ -208,6 +209,29 @@ func resultGuard(resultOrder []string) func(string) func() {
 	return guardTestResult
 }
 
+func TestParseSearchFilters(t *testing.T) {
+	for _, test := range []struct {
+		q, wantQuery, wantFilter string
+	}{
+		{"yaml json", "yaml json", ""},
+		{"yaml is:vulnerable", "yaml", "num_vulns > 0"},
+		{"-is:vulnerable  yaml", "yaml", "num_vulns = 0"},
+		{"IS:Vulnerable", "", "num_vulns > 0"},
+		{"is:vulnerable -is:vulnerable yaml", "yaml", "num_vulns = 0"},
+		{"is:other", "is:other", ""},
+		{"cobra owner:spf13", "cobra", "owner IN ('github.com/spf13', 'gitlab.com/spf13', 'bitbucket.org/spf13')"},
+		{"Owner:GitLab.com/Gitlab-Org/ api", "api", "owner = 'gitlab.com/gitlab-org'"},
+		{"owner:x' is:vulnerable yaml", "yaml", "num_vulns > 0 AND owner IN ('github.com/x''', 'gitlab.com/x''', 'bitbucket.org/x''')"},
+		{"owner: yaml", "owner: yaml", ""},
+	} {
+		gotQuery, gotFilter := parseSearchFilters(test.q)
+		if gotQuery != test.wantQuery || gotFilter != test.wantFilter {
+			t.Errorf("parseSearchFilters(%q) = %q, %q; want %q, %q",
+				test.q, gotQuery, gotFilter, test.wantQuery, test.wantFilter)
+		}
+	}
+}
+
 func TestSearch(t *testing.T) {
 	tests := []struct {
 		label       string
@@ -263,8 +287,8 @@ func TestSearch(t *testing.T) {
 		// doesn't add much additional value.
 	}
 
-	view.Register(SearchResponseCount)
-	defer view.Unregister(SearchResponseCount)
+	view.Register(SearchResponseCount, SearcherOutcomeCount)
+	defer view.Unregister(SearchResponseCount, SearcherOutcomeCount)
 	responses := make(map[string]int64)
 	// responseDelta captures the change in the SearchResponseCount metric.
 	responseDelta := func() map[string]int64 {
@@ -285,6 +309,36 @@ func TestSearch(t *testing.T) {
 		}
 		return delta
 	}
+	prevWins := make(map[string]int64)
+	// wins captures the change in the number of wins in the
+	// SearcherOutcomeCount metric.
+	wins := func() map[string]int64 {
+		rows, err := view.RetrieveData(SearcherOutcomeCount.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		delta := make(map[string]int64)
+		for _, row := range rows {
+			var source, outcome string
+			for _, tg := range row.Tags {
+				switch tg.Key {
+				case keySearchSource:
+					source = tg.Value
+				case keySearchOutcome:
+					outcome = tg.Value
+				}
+			}
+			if outcome != "won" {
+				continue
+			}
+			count := row.Data.(*view.CountData).Value
+			if d := count - prevWins[source]; d != 0 {
+				delta[source] = d
+			}
+			prevWins[source] = count
+		}
+		return delta
+	}
 	for _, test := range tests {
 		t.Run(test.label, func(t *testing.T) {
 			defer ResetTestDB(testDB, t)
@@ -322,17 +376,26 @@ func TestSearch(t *testing.T) {
 			if diff := cmp.Diff(wantDelta, gotDelta); diff != "" {
 				t.Errorf("SearchResponseCount: unexpected delta (-want +got):\n%s", diff)
 			}
+			// The outcome of the losing searcher is recorded asynchronously, so
+			// only check the winner.
+			if got := wins(); got[test.wantSource] != 1 {
+				t.Errorf("SearcherOutcomeCount: got wins %v, want one win for %q", got, test.wantSource)
+			}
 		})
 	}
 }
 
 func TestSearchErrors(t *testing.T) {
-	// errorIn returns a copy of searchers for which searcherName returns an
-	// error.
-	errorIn := func(searcherName string) map[string]searcher {
+	// errorIn returns a copy of searchers for which the searchers named
+	// searcherNames return an error.
+	errorIn := func(searcherNames ...string) map[string]searcher {
+		failing := make(map[string]bool)
+		for _, name := range searcherNames {
+			failing[name] = true
+		}
 		newSearchers := make(map[string]searcher)
 		for name, search := range searchers {
-			if name == searcherName {
+			if failing[name] {
 				name := name
 				newSearchers[name] = func(*DB, context.Context, string, int, int) searchResponse {
 					return searchResponse{
@@ -358,7 +421,7 @@ func TestSearchErrors(t *testing.T) {
 			label:       "error in first result",
 			searchers:   errorIn("popular"),
 			resultOrder: []string{"popular", "estimate", "deep"},
-			wantErr:     true,
+			wantSource:  "deep",
 		},
 		{
 			label:       "return before error",
@@ -370,7 +433,7 @@ func TestSearchErrors(t *testing.T) {
 			label:       "error waiting for count",
 			searchers:   errorIn("deep"),
 			resultOrder: []string{"popular", "deep", "estimate"},
-			wantErr:     true,
+			wantSource:  "popular",
 		},
 		{
 			label:       "counted result before error",
@@ -378,6 +441,12 @@ func TestSearchErrors(t *testing.T) {
 			resultOrder: []string{"deep", "popular", "estimate"},
 			wantSource:  "deep",
 		},
+		{
+			label:       "errors in all searchers",
+			searchers:   errorIn("popular", "deep"),
+			resultOrder: []string{"popular", "deep", "estimate"},
+			wantErr:     true,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.label, func(t *testing.T) {
@@ -570,6 +639,134 @@ func TestInsertSearchDocumentAndSearch(t *testing.T) {
 	}
 }
 
+func TestSearchGrouped(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []*internal.Module{
+		sample.Module("a.com/many", sample.VersionString, "p1", "p2", "p3", "p4", "p5"),
+		sample.Module("b.com/one", sample.VersionString, "p"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type group struct {
+		n, total int
+	}
+	groups := func(results []*internal.SearchResult) map[string]group {
+		got := map[string]group{}
+		for _, r := range results {
+			g := got[r.ModulePath]
+			g.n++
+			g.total = int(r.NumModuleResults)
+			got[r.ModulePath] = g
+			if r.NumResults != 2 {
+				t.Errorf("%s: NumResults = %d, want 2", r.PackagePath, r.NumResults)
+			}
+		}
+		return got
+	}
+
+	results, err := testDB.SearchGrouped(ctx, "synopsis", 10, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]group{
+		"a.com/many": {n: 3, total: 5},
+		"b.com/one":  {n: 1, total: 1},
+	}
+	if diff := cmp.Diff(want, groups(results), cmp.AllowUnexported(group{})); diff != "" {
+		t.Errorf("SearchGrouped mismatch (-want +got):\n%s", diff)
+	}
+	// Results of the same module are adjacent.
+	seen := map[string]bool{}
+	for i, r := range results {
+		if i > 0 && r.ModulePath == results[i-1].ModulePath {
+			continue
+		}
+		if seen[r.ModulePath] {
+			t.Errorf("results of %s are not adjacent", r.ModulePath)
+		}
+		seen[r.ModulePath] = true
+	}
+
+	// Limit and offset apply to modules.
+	var got []string
+	for offset := 0; offset < 3; offset++ {
+		results, err := testDB.SearchGrouped(ctx, "synopsis", 1, offset, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for m := range groups(results) {
+			got = append(got, m)
+		}
+	}
+	sort.Strings(got)
+	if diff := cmp.Diff([]string{"a.com/many", "b.com/one"}, got); diff != "" {
+		t.Errorf("SearchGrouped pages mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSearchOwner(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	yaml := sample.Module("gopkg.in/yaml.v2", "v2.0.0", "")
+	yaml.SourceInfo = source.NewGitHubInfo("https://github.com/go-yaml/yaml", "", "v2.0.0")
+	for _, m := range []*internal.Module{
+		sample.Module("github.com/Spf13/cobra", sample.VersionString, "doc"),
+		sample.Module("gitlab.com/spf13/x", sample.VersionString, "p"),
+		sample.Module("example.com/m", sample.VersionString, "p"),
+		yaml,
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		query string
+		want  map[string]string // package path to owner
+	}{
+		{
+			"synopsis",
+			map[string]string{
+				"github.com/Spf13/cobra/doc": "github.com/spf13",
+				"gitlab.com/spf13/x/p":       "gitlab.com/spf13",
+				"example.com/m/p":            "",
+				"gopkg.in/yaml.v2":           "github.com/go-yaml",
+			},
+		},
+		{
+			"synopsis owner:spf13",
+			map[string]string{
+				"github.com/Spf13/cobra/doc": "github.com/spf13",
+				"gitlab.com/spf13/x/p":       "gitlab.com/spf13",
+			},
+		},
+		{
+			"synopsis owner:github.com/go-yaml",
+			map[string]string{"gopkg.in/yaml.v2": "github.com/go-yaml"},
+		},
+	} {
+		results, err := testDB.Search(ctx, test.query, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := map[string]string{}
+		for _, r := range results {
+			got[r.PackagePath] = r.Owner
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("Search(%q) mismatch (-want +got):\n%s", test.query, diff)
+		}
+	}
+}
+
 func TestSearchPenalties(t *testing.T) {
 	// Verify that the penalties for non-redistributable modules and modules without
 	// go.mod files are applied correctly.
@@ -652,6 +849,35 @@ func TestExcludedFromSearch(t *testing.T) {
 	}
 }
 
+func TestUnservedFromSearch(t *testing.T) {
+	// Verify that paths outside the served prefixes are omitted from search
+	// results.
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, modulePath := range []string{"corp.example.com/search", "public.com/search"} {
+		if err := testDB.InsertModule(ctx, sample.Module(modulePath, "v1.2.3", "pkg")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	internal.SetServedPrefixes([]string{"corp.example.com/"})
+	defer internal.SetServedPrefixes(nil)
+
+	gotResults, err := testDB.Search(ctx, "search", 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, g := range gotResults {
+		got = append(got, g.PackagePath)
+	}
+	want := []string{"corp.example.com/search/pkg"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
 type searchDocument struct {
 	packagePath              string
 	modulePath               string
@@ -886,8 +1112,13 @@ func TestUpdateSearchDocumentsImportedByCount(t *testing.T) {
 		// We add that information to module_version_states.
 		alternativeModulePath := strings.ToLower(canonicalModule.ModulePath)
 		alternativeStatus := derrors.ToHTTPStatus(derrors.AlternativeModule)
-		err := testDB.UpsertModuleVersionState(ctx, alternativeModulePath, "v1.2.0", "",
-			time.Now(), alternativeStatus, canonicalModule.ModulePath, nil, nil)
+		err := testDB.UpsertModuleVersionState(ctx, &internal.ModuleVersionStateForUpsert{
+			ModulePath: alternativeModulePath,
+			Version:    "v1.2.0",
+			Timestamp:  time.Now(),
+			Status:     alternativeStatus,
+			GoModPath:  canonicalModule.ModulePath,
+		})
 		if err != nil {
 			t.Fatal(err)
 		}
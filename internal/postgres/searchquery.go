@@ -0,0 +1,166 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// searchQueryRetention is how long search queries are kept in the
+// search_queries table.
+const searchQueryRetention = 30 * 24 * time.Hour
+
+// maxSearchQueryLength is the length in bytes above which recorded search
+// queries are truncated.
+const maxSearchQueryLength = 100
+
+// SearchQueryStats are the statistics of a search query over some days.
+type SearchQueryStats struct {
+	Query string
+	// Count is the number of recorded searches for the query, and
+	// ZeroResultCount the number of them that had no results.
+	Count           int
+	ZeroResultCount int
+	LastSeen        time.Time
+}
+
+// RecordSearchQuery counts a search for query that had numResults results in
+// the search_queries table, and deletes the counts of days older than
+// searchQueryRetention. The query is scrubbed with scrubSearchQuery first, and
+// not recorded at all if it may hold personal information.
+func (db *DB) RecordSearchQuery(ctx context.Context, query string, numResults int) (err error) {
+	defer derrors.Wrap(&err, "RecordSearchQuery(ctx, %d)", numResults)
+
+	query, ok := scrubSearchQuery(query)
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	zero := 0
+	if numResults == 0 {
+		zero = 1
+	}
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO search_queries (day, query, count, zero_result_count, last_seen)
+			VALUES ($1::date, $2, 1, $3, $4)
+			ON CONFLICT (day, query) DO UPDATE SET
+				count = search_queries.count + 1,
+				zero_result_count = search_queries.zero_result_count + excluded.zero_result_count,
+				last_seen = excluded.last_seen`,
+			now, query, zero, now); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM search_queries WHERE day < $1::date`, now.Add(-searchQueryRetention))
+		return err
+	})
+}
+
+// GetTopSearchQueries returns up to limit of the search queries recorded since
+// the given time, with the most searched first. If zeroResultsOnly is true,
+// only queries that had no results are returned, ordered by the number of
+// searches that had none.
+func (db *DB) GetTopSearchQueries(ctx context.Context, since time.Time, limit int, zeroResultsOnly bool) (_ []*SearchQueryStats, err error) {
+	defer derrors.Wrap(&err, "GetTopSearchQueries(ctx, %s, %d, %t)", since, limit, zeroResultsOnly)
+
+	order := "count"
+	having := ""
+	if zeroResultsOnly {
+		order = "zero_result_count"
+		having = "HAVING SUM(zero_result_count) > 0"
+	}
+	query := `
+		SELECT query, SUM(count) AS count, SUM(zero_result_count) AS zero_result_count, MAX(last_seen) AS last_seen
+		FROM search_queries
+		WHERE day >= $1::date
+		GROUP BY query
+		` + having + `
+		ORDER BY ` + order + ` DESC, query
+		LIMIT $2`
+	var stats []*SearchQueryStats
+	if err := db.db.CollectStructs(ctx, &stats, query, since, limit); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+var (
+	emailRegexp  = regexp.MustCompile(`[^@\s]+@[^@\s]+\.[a-z]{2,}`)
+	digitsRegexp = regexp.MustCompile(`[0-9]{7,}`)
+)
+
+// scrubSearchQuery returns the form of query that is recorded in the
+// search_queries table, and reports whether it may be recorded. The query is
+// lowercased, its spaces are collapsed and it is truncated to
+// maxSearchQueryLength. The credentials, query strings and fragments of URLs
+// are removed. Queries that look like they hold personal information or
+// secrets, like email addresses, long numbers or long random tokens, are not
+// recorded.
+func scrubSearchQuery(query string) (string, bool) {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(query)) {
+		if strings.Contains(w, "://") {
+			u, err := url.Parse(w)
+			if err != nil {
+				return "", false
+			}
+			u.User = nil
+			u.RawQuery = ""
+			u.Fragment = ""
+			w = u.String()
+		}
+		if emailRegexp.MatchString(w) {
+			return "", false
+		}
+		// Pseudo-versions have long numbers but are not personal.
+		v := w
+		if i := strings.LastIndexByte(w, '@'); i >= 0 {
+			v = w[i+1:]
+		}
+		if digitsRegexp.MatchString(w) && !semver.IsValid(v) {
+			return "", false
+		}
+		if looksLikeSecret(w) {
+			return "", false
+		}
+		words = append(words, w)
+	}
+	q := strings.Join(words, " ")
+	if q == "" {
+		return "", false
+	}
+	if len(q) > maxSearchQueryLength {
+		q = strings.TrimSpace(strings.ToValidUTF8(q[:maxSearchQueryLength], ""))
+	}
+	return q, true
+}
+
+// looksLikeSecret reports whether w looks like a token or key: a long word of
+// letters and digits without the separators of paths.
+func looksLikeSecret(w string) bool {
+	if len(w) < 24 || strings.ContainsAny(w, "/.") {
+		return false
+	}
+	var letters, digits bool
+	for _, r := range w {
+		switch {
+		case unicode.IsLetter(r):
+			letters = true
+		case unicode.IsDigit(r):
+			digits = true
+		}
+	}
+	return letters && digits
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrubSearchQuery(t *testing.T) {
+	for _, test := range []struct {
+		query  string
+		want   string
+		wantOK bool
+	}{
+		{"  YAML   Parser ", "yaml parser", true},
+		{"https://user:pw@github.com/a/b?token=x#frag", "https://github.com/a/b", true},
+		{"contact me@example.com", "", false},
+		{"call 5551234567", "", false},
+		{"golang.org/x/net@v0.0.0-20200114155413-6afb5195e5aa", "golang.org/x/net@v0.0.0-20200114155413-6afb5195e5aa", true},
+		{"github.com/a/b@12345678", "", false},
+		{"v0.0.0-20200114155413-6afb5195e5aa", "v0.0.0-20200114155413-6afb5195e5aa", true},
+		{"ghp_1a2b3c4d5e6f7g8h9i0jklmnop", "", false},
+		{"golang.org/x/tools/internal/lsp/protocol", "golang.org/x/tools/internal/lsp/protocol", true},
+		{"   ", "", false},
+		{strings.Repeat("a ", 100), strings.TrimSpace(strings.Repeat("a ", 50)), true},
+	} {
+		got, ok := scrubSearchQuery(test.query)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("scrubSearchQuery(%q) = (%q, %t), want (%q, %t)", test.query, got, ok, test.want, test.wantOK)
+		}
+	}
+}
+
+func TestRecordSearchQuery(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, s := range []struct {
+		query      string
+		numResults int
+	}{
+		{"yaml", 10},
+		{"YAML", 0},
+		{"yaml", 3},
+		{"frobnicate", 0},
+		{"frobnicate", 0},
+		{"me@example.com", 0},
+	} {
+		if err := testDB.RecordSearchQuery(ctx, s.query, s.numResults); err != nil {
+			t.Fatal(err)
+		}
+	}
+	since := time.Now().Add(-24 * time.Hour)
+
+	check := func(zeroResultsOnly bool, want []SearchQueryStats) {
+		t.Helper()
+		got, err := testDB.GetTopSearchQueries(ctx, since, 10, zeroResultsOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("GetTopSearchQueries(zeroResultsOnly=%t): got %d queries, want %d", zeroResultsOnly, len(got), len(want))
+		}
+		for i, w := range want {
+			g := got[i]
+			if g.Query != w.Query || g.Count != w.Count || g.ZeroResultCount != w.ZeroResultCount {
+				t.Errorf("GetTopSearchQueries(zeroResultsOnly=%t)[%d] = %+v, want %+v", zeroResultsOnly, i, g, w)
+			}
+		}
+	}
+	check(false, []SearchQueryStats{
+		{Query: "yaml", Count: 3, ZeroResultCount: 1},
+		{Query: "frobnicate", Count: 2, ZeroResultCount: 2},
+	})
+	check(true, []SearchQueryStats{
+		{Query: "frobnicate", Count: 2, ZeroResultCount: 2},
+		{Query: "yaml", Count: 3, ZeroResultCount: 1},
+	})
+}
@@ -0,0 +1,543 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/modstatus"
+)
+
+const (
+	// shadowSearchTable is the table that RebuildSearchDocuments builds.
+	shadowSearchTable = "search_documents_shadow"
+	// oldSearchTable holds the search documents that were replaced by the
+	// last rebuild, until the next one, so that a bad swap can be undone by
+	// hand.
+	oldSearchTable = "search_documents_old"
+	// searchDeletionsTable records the packages deleted from
+	// search_documents while a rebuild is in progress, so that they can be
+	// deleted from the shadow table before it is swapped in.
+	searchDeletionsTable = "search_documents_deleted"
+	// rebuildModuleBatchSize is the number of modules whose packages are
+	// read at a time while rebuilding.
+	rebuildModuleBatchSize = 1000
+	// spotCheckLimit is the number of top results compared for each
+	// spot-check query.
+	spotCheckLimit = 10
+)
+
+// DefaultSpotCheckQueries are the queries whose top results are compared
+// between the old and new search documents when no others are given.
+var DefaultSpotCheckQueries = []string{"http", "json", "yaml", "logging", "database", "testing", "uuid", "grpc"}
+
+// SearchRebuildOptions controls RebuildSearchDocuments.
+type SearchRebuildOptions struct {
+	// MaxCountChange is the largest fraction by which the number of search
+	// documents may change for the rebuilt table to be swapped in.
+	MaxCountChange float64
+	// Queries are the queries whose top results are spot-checked.
+	Queries []string
+	// MinOverlap is the smallest average fraction of top results that the
+	// spot-check queries must have in common for the rebuilt table to be
+	// swapped in. Zero disables the check, for when rankings are meant to
+	// change.
+	MinOverlap float64
+	// DryRun builds and validates the table without swapping it in.
+	DryRun bool
+}
+
+// SearchRebuild describes a rebuild of the search documents.
+type SearchRebuild struct {
+	OldCount, NewCount int
+	SpotChecks         []*SearchSpotCheck
+	// Problems are the reasons the rebuilt table was not swapped in.
+	Problems []string
+	Swapped  bool
+}
+
+// SearchSpotCheck compares the top results of a query before and after a
+// rebuild.
+type SearchSpotCheck struct {
+	Query    string
+	Old, New []string // package paths, best first
+	// Overlap is the fraction of Old that is also in New, or 1 if Old is
+	// empty.
+	Overlap float64
+}
+
+// RebuildSearchDocuments rebuilds search_documents from the modules and
+// packages tables into a shadow table, compares the shadow table with
+// search_documents, and replaces search_documents with it if they are close
+// enough according to opts. Imported-by counts are copied from
+// search_documents, since they are computed by a separate job.
+//
+// Writes to search_documents can continue while the shadow table is built;
+// the documents they changed are rebuilt again, and the documents they
+// deleted are deleted from the shadow table, in the transaction that swaps
+// the tables. The replaced table is kept as search_documents_old until the
+// next rebuild.
+func (db *DB) RebuildSearchDocuments(ctx context.Context, opts SearchRebuildOptions) (_ *SearchRebuild, err error) {
+	defer derrors.Wrap(&err, "DB.RebuildSearchDocuments(ctx, %+v)", opts)
+
+	start := time.Now()
+	indexes, err := searchIndexes(ctx, db.db)
+	if err != nil {
+		return nil, err
+	}
+	if err := createShadowSearchTable(ctx, db.db, indexes); err != nil {
+		return nil, err
+	}
+	// The deletions are recorded only until the rebuild is over. The trigger
+	// is created after the shadow table, so that it is not copied to it.
+	if err := recordSearchDeletions(ctx, db.db); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err2 := stopRecordingSearchDeletions(ctx, db.db); err2 != nil && err == nil {
+			err = err2
+		}
+	}()
+	statement := upsertSearchStatementFor(shadowSearchTable)
+	n, err := loadShadowSearchTable(ctx, db.db, statement)
+	if err != nil {
+		return nil, err
+	}
+	afterLoadingShadowSearchTable(ctx)
+	log.Infof(ctx, "RebuildSearchDocuments: upserted %d search documents in %s", n, time.Since(start))
+	for _, ix := range indexes {
+		if ix.primary {
+			continue
+		}
+		if _, err := db.db.Exec(ctx, ix.definition(shadowSearchTable, ix.name+"_new")); err != nil {
+			return nil, err
+		}
+	}
+	if err := copyImportedByCounts(ctx, db.db, time.Time{}); err != nil {
+		return nil, err
+	}
+
+	rb, err := validateShadowSearchTable(ctx, db.db, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(rb.Problems) > 0 || opts.DryRun {
+		return rb, nil
+	}
+	err = db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		// Block writes to search_documents, then rebuild the documents that
+		// were written since the rebuild started.
+		if _, err := tx.Exec(ctx, `LOCK TABLE search_documents IN EXCLUSIVE MODE`); err != nil {
+			return err
+		}
+		var paths []string
+		if err := tx.RunQuery(ctx, `SELECT package_path FROM search_documents WHERE updated_at >= $1`,
+			func(rows *sql.Rows) error {
+				var p string
+				if err := rows.Scan(&p); err != nil {
+					return err
+				}
+				paths = append(paths, p)
+				return nil
+			}, start); err != nil {
+			return err
+		}
+		for _, p := range paths {
+			args, err := searchDocumentArgs(ctx, tx, p)
+			if err != nil {
+				return err
+			}
+			if err := upsertSearchDocument(ctx, tx, statement, args); err != nil {
+				return err
+			}
+		}
+		if err := copyImportedByCounts(ctx, tx, start); err != nil {
+			return err
+		}
+		res, err := tx.Exec(ctx, fmt.Sprintf(`
+			DELETE FROM %s s
+			USING %s d
+			WHERE s.package_path = d.package_path
+			AND NOT EXISTS (SELECT 1 FROM search_documents sd WHERE sd.package_path = s.package_path)`,
+			shadowSearchTable, searchDeletionsTable))
+		if err != nil {
+			return err
+		}
+		deleted, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		log.Infof(ctx, "RebuildSearchDocuments: rebuilt %d and deleted %d search documents that changed during the rebuild", len(paths), deleted)
+		return swapSearchTables(ctx, tx, indexes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	rb.Swapped = true
+	return rb, nil
+}
+
+// searchIndex is an index of search_documents.
+type searchIndex struct {
+	name    string
+	def     string // from pg_get_indexdef
+	primary bool
+}
+
+var indexDefRegexp = regexp.MustCompile(`^(CREATE (?:UNIQUE )?INDEX )\S+( ON (?:ONLY )?)\S+`)
+
+// definition returns the statement that creates the index on table, with the
+// given name.
+func (ix searchIndex) definition(table, name string) string {
+	return indexDefRegexp.ReplaceAllString(ix.def, "${1}"+name+"${2}"+table)
+}
+
+func searchIndexes(ctx context.Context, db *database.DB) ([]searchIndex, error) {
+	var indexes []searchIndex
+	err := db.RunQuery(ctx, `
+		SELECT i.relname, pg_get_indexdef(i.oid), x.indisprimary
+		FROM pg_index x
+		INNER JOIN pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = 'search_documents'::regclass
+		ORDER BY i.relname`,
+		func(rows *sql.Rows) error {
+			var ix searchIndex
+			if err := rows.Scan(&ix.name, &ix.def, &ix.primary); err != nil {
+				return err
+			}
+			indexes = append(indexes, ix)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+var triggerTableRegexp = regexp.MustCompile(` ON (?:\S+\.)?search_documents `)
+
+// createShadowSearchTable creates an empty shadow table with the columns,
+// primary key, foreign keys and triggers of search_documents. Its other
+// indexes are created after it is loaded. It drops the table replaced by the
+// last rebuild.
+func createShadowSearchTable(ctx context.Context, db *database.DB, indexes []searchIndex) error {
+	if _, err := db.Exec(ctx, fmt.Sprintf(`
+		DROP TABLE IF EXISTS %[1]s;
+		DROP TABLE IF EXISTS %[2]s;
+		CREATE TABLE %[1]s (LIKE search_documents INCLUDING DEFAULTS INCLUDING CONSTRAINTS INCLUDING COMMENTS)`,
+		shadowSearchTable, oldSearchTable)); err != nil {
+		return err
+	}
+	var stmts []string
+	for _, ix := range indexes {
+		if ix.primary {
+			stmts = append(stmts,
+				ix.definition(shadowSearchTable, ix.name+"_new"),
+				fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s_new PRIMARY KEY USING INDEX %[2]s_new`, shadowSearchTable, ix.name))
+		}
+	}
+	err := db.RunQuery(ctx, `
+		SELECT conname, pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE conrelid = 'search_documents'::regclass AND contype = 'f'`,
+		func(rows *sql.Rows) error {
+			var name, def string
+			if err := rows.Scan(&name, &def); err != nil {
+				return err
+			}
+			stmts = append(stmts, fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s %s`, shadowSearchTable, name, def))
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	err = db.RunQuery(ctx, `
+		SELECT pg_get_triggerdef(oid)
+		FROM pg_trigger
+		WHERE tgrelid = 'search_documents'::regclass AND NOT tgisinternal`,
+		func(rows *sql.Rows) error {
+			var def string
+			if err := rows.Scan(&def); err != nil {
+				return err
+			}
+			stmts = append(stmts, triggerTableRegexp.ReplaceAllString(def, " ON "+shadowSearchTable+" "))
+			return nil
+		})
+	if err != nil {
+		return err
+	}
+	for _, s := range stmts {
+		if _, err := db.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// afterLoadingShadowSearchTable is called after the shadow table is loaded.
+// It is a variable for testing.
+var afterLoadingShadowSearchTable = func(context.Context) {}
+
+// recordSearchDeletions creates searchDeletionsTable, and a trigger that
+// records in it the package path of each row deleted from search_documents,
+// including by cascading deletes of modules.
+func recordSearchDeletions(ctx context.Context, db *database.DB) error {
+	if err := stopRecordingSearchDeletions(ctx, db); err != nil {
+		return err
+	}
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		CREATE TABLE %[1]s (package_path text PRIMARY KEY);
+		CREATE FUNCTION trigger_record_search_document_deletion() RETURNS trigger
+			LANGUAGE plpgsql
+			AS $$
+		BEGIN
+			INSERT INTO %[1]s (package_path) VALUES (OLD.package_path) ON CONFLICT DO NOTHING;
+			RETURN OLD;
+		END;
+		$$;
+		CREATE TRIGGER record_deletion AFTER DELETE ON search_documents
+			FOR EACH ROW EXECUTE PROCEDURE trigger_record_search_document_deletion()`,
+		searchDeletionsTable))
+	return err
+}
+
+// stopRecordingSearchDeletions drops the trigger and table created by
+// recordSearchDeletions, if they exist. Dropping the function drops the
+// trigger, whether it is on search_documents or, after a swap, on the old
+// table.
+func stopRecordingSearchDeletions(ctx context.Context, db *database.DB) error {
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		DROP FUNCTION IF EXISTS trigger_record_search_document_deletion() CASCADE;
+		DROP TABLE IF EXISTS %s`, searchDeletionsTable))
+	return err
+}
+
+// loadShadowSearchTable upserts into the shadow table the search documents
+// of the non-internal packages in the latest version of each module, except
+// for modules whose later versions have an alternative module path, as
+// InsertModule does. It returns the number of documents it upserted.
+func loadShadowSearchTable(ctx context.Context, db *database.DB, statement string) (int, error) {
+	var (
+		n     int
+		after string
+	)
+	for {
+		var modulePaths, versions []string
+		err := db.RunQuery(ctx, `
+			SELECT DISTINCT ON (module_path) module_path, version
+			FROM modules
+			WHERE module_path > $1
+			ORDER BY module_path, version_type = 'release' DESC, sort_version DESC
+			LIMIT $2`,
+			func(rows *sql.Rows) error {
+				var m, v string
+				if err := rows.Scan(&m, &v); err != nil {
+					return err
+				}
+				modulePaths = append(modulePaths, m)
+				versions = append(versions, v)
+				return nil
+			}, after, rebuildModuleBatchSize)
+		if err != nil {
+			return n, err
+		}
+		if len(modulePaths) == 0 {
+			return n, nil
+		}
+		after = modulePaths[len(modulePaths)-1]
+
+		var argsList []upsertSearchDocumentArgs
+		err = db.RunQuery(ctx, `
+			SELECT p.path, p.module_path, p.synopsis, m.readme_file_path,
+				CASE WHEN p.path = m.module_path THEN m.readme_contents END
+			FROM unnest($1::text[], $2::text[]) AS l(module_path, version)
+			INNER JOIN modules m ON m.module_path = l.module_path AND m.version = l.version
+			INNER JOIN packages p ON p.module_path = m.module_path AND p.version = m.version
+			WHERE NOT EXISTS (
+				SELECT 1 FROM module_version_states s
+				WHERE s.module_path = m.module_path AND s.sort_version > m.sort_version AND s.status = $3
+			)
+			ORDER BY p.module_path, p.path`,
+			func(rows *sql.Rows) error {
+				var a upsertSearchDocumentArgs
+				if err := rows.Scan(&a.PackagePath, &a.ModulePath, database.NullIsEmpty(&a.Synopsis),
+					database.NullIsEmpty(&a.ReadmeFilePath), database.Compressed(&a.ReadmeContents)); err != nil {
+					return err
+				}
+				argsList = append(argsList, a)
+				return nil
+			}, pq.Array(modulePaths), pq.Array(versions), modstatus.AlternativeModule)
+		if err != nil {
+			return n, err
+		}
+		for _, a := range argsList {
+			if isInternalPackage(a.PackagePath) {
+				continue
+			}
+			if err := upsertSearchDocument(ctx, db, statement, a); err != nil {
+				return n, err
+			}
+			n++
+		}
+		log.Debugf(ctx, "RebuildSearchDocuments: upserted %d search documents, through module %s", n, after)
+	}
+}
+
+// searchDocumentArgs returns the arguments for upserting the search document
+// of pkgPath, from the latest version of the module that search_documents
+// has for it.
+func searchDocumentArgs(ctx context.Context, db *database.DB, pkgPath string) (upsertSearchDocumentArgs, error) {
+	a := upsertSearchDocumentArgs{PackagePath: pkgPath}
+	err := db.QueryRow(ctx, `
+		SELECT sd.module_path, sd.synopsis, m.readme_file_path, m.readme_contents
+		FROM search_documents sd
+		INNER JOIN modules m USING (module_path, version)
+		WHERE sd.package_path = $1`, pkgPath).Scan(&a.ModulePath, database.NullIsEmpty(&a.Synopsis),
+		database.NullIsEmpty(&a.ReadmeFilePath), database.Compressed(&a.ReadmeContents))
+	return a, err
+}
+
+// copyImportedByCounts copies the imported-by counts of the search documents
+// that were updated since the given time from search_documents to the shadow
+// table, along with the time the version of each document last changed, if
+// it is the same version.
+func copyImportedByCounts(ctx context.Context, db *database.DB, since time.Time) error {
+	_, err := db.Exec(ctx, fmt.Sprintf(`
+		UPDATE %s s
+		SET
+			imported_by_count = d.imported_by_count,
+			imported_by_count_updated_at = d.imported_by_count_updated_at,
+			version_updated_at = CASE WHEN s.version = d.version
+				THEN d.version_updated_at
+				ELSE s.version_updated_at
+				END
+		FROM search_documents d
+		WHERE s.package_path = d.package_path AND d.updated_at >= $1`, shadowSearchTable), since)
+	return err
+}
+
+// validateShadowSearchTable compares the shadow table with search_documents,
+// and reports the differences that keep it from being swapped in according
+// to opts.
+func validateShadowSearchTable(ctx context.Context, db *database.DB, opts SearchRebuildOptions) (*SearchRebuild, error) {
+	rb := &SearchRebuild{}
+	if err := db.QueryRow(ctx, `SELECT count(*) FROM search_documents`).Scan(&rb.OldCount); err != nil {
+		return nil, err
+	}
+	if err := db.QueryRow(ctx, `SELECT count(*) FROM `+shadowSearchTable).Scan(&rb.NewCount); err != nil {
+		return nil, err
+	}
+	if rb.OldCount > 0 {
+		change := float64(rb.NewCount-rb.OldCount) / float64(rb.OldCount)
+		if change < 0 {
+			change = -change
+		}
+		if change > opts.MaxCountChange {
+			rb.Problems = append(rb.Problems, fmt.Sprintf("number of search documents changed from %d to %d, more than %g%%",
+				rb.OldCount, rb.NewCount, 100*opts.MaxCountChange))
+		}
+	} else if rb.NewCount == 0 {
+		rb.Problems = append(rb.Problems, "there are no search documents")
+	}
+
+	var total float64
+	for _, q := range opts.Queries {
+		sc := &SearchSpotCheck{Query: q}
+		var err error
+		if sc.Old, err = topSearchResults(ctx, db, "search_documents", q); err != nil {
+			return nil, err
+		}
+		if sc.New, err = topSearchResults(ctx, db, shadowSearchTable, q); err != nil {
+			return nil, err
+		}
+		sc.Overlap = overlap(sc.Old, sc.New)
+		total += sc.Overlap
+		rb.SpotChecks = append(rb.SpotChecks, sc)
+	}
+	if len(opts.Queries) > 0 && opts.MinOverlap > 0 {
+		if avg := total / float64(len(opts.Queries)); avg < opts.MinOverlap {
+			rb.Problems = append(rb.Problems, fmt.Sprintf("top results of spot-check queries overlap by %.2f on average, less than %.2f",
+				avg, opts.MinOverlap))
+		}
+	}
+	return rb, nil
+}
+
+// topSearchResults returns the paths of the best-scoring packages for q in
+// table, scored as by deep search.
+func topSearchResults(ctx context.Context, db *database.DB, table, q string) ([]string, error) {
+	var paths []string
+	query := fmt.Sprintf(`
+		SELECT package_path
+		FROM %s
+		WHERE tsv_search_tokens @@ %s
+		ORDER BY (%s) DESC, commit_time DESC, package_path
+		LIMIT $2`, table, searchTSQuery, scoreExpr)
+	err := db.RunQuery(ctx, query, func(rows *sql.Rows) error {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	}, q, spotCheckLimit)
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+// overlap returns the fraction of old that is in new, or 1 if old is empty.
+func overlap(old, new []string) float64 {
+	if len(old) == 0 {
+		return 1
+	}
+	in := map[string]bool{}
+	for _, p := range new {
+		in[p] = true
+	}
+	var n int
+	for _, p := range old {
+		if in[p] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(old))
+}
+
+// swapSearchTables replaces search_documents with the shadow table, keeping
+// the replaced table as oldSearchTable. The indexes of the shadow table take
+// the names of those of search_documents, so that migrations can keep
+// referring to them.
+func swapSearchTables(ctx context.Context, tx *database.DB, indexes []searchIndex) error {
+	if len(indexes) == 0 {
+		return errors.New("search_documents has no indexes")
+	}
+	var stmts []string
+	for _, ix := range indexes {
+		stmts = append(stmts, fmt.Sprintf(`ALTER INDEX %s RENAME TO %[1]s_old`, ix.name))
+	}
+	stmts = append(stmts,
+		`ALTER TABLE search_documents RENAME TO `+oldSearchTable,
+		fmt.Sprintf(`ALTER TABLE %s RENAME TO search_documents`, shadowSearchTable))
+	for _, ix := range indexes {
+		stmts = append(stmts, fmt.Sprintf(`ALTER INDEX %s_new RENAME TO %[1]s`, ix.name))
+	}
+	for _, s := range stmts {
+		if _, err := tx.Exec(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
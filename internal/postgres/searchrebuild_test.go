@@ -0,0 +1,203 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestRebuildSearchDocuments(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []struct{ path, version string }{
+		{"a.com/m", "v1.0.0"},
+		{"a.com/m", "v1.1.0"},
+		{"b.com/n", "v2.0.0"},
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(m.path, m.version, "foo", "internal/bar")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	indexesBefore := searchIndexNames(ctx, t)
+
+	// Damage the index: drop a document and point another at an old version.
+	if _, err := testDB.db.Exec(ctx, `DELETE FROM search_documents WHERE package_path = 'b.com/n/foo'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET version = 'v1.0.0' WHERE package_path = 'a.com/m/foo'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testDB.db.Exec(ctx, `UPDATE search_documents SET imported_by_count = 7 WHERE package_path = 'a.com/m/foo'`); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run, or a rebuild that changes the count too much, leaves the
+	// index alone.
+	for _, opts := range []SearchRebuildOptions{
+		{MaxCountChange: 1, DryRun: true},
+		{MaxCountChange: 0.1},
+	} {
+		rb, err := testDB.RebuildSearchDocuments(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rb.Swapped {
+			t.Errorf("RebuildSearchDocuments(%+v) swapped", opts)
+		}
+		if _, _, found := GetFromSearchDocuments(ctx, t, testDB, "b.com/n/foo"); found {
+			t.Fatalf("RebuildSearchDocuments(%+v) changed search_documents", opts)
+		}
+	}
+
+	rb, err := testDB.RebuildSearchDocuments(ctx, SearchRebuildOptions{MaxCountChange: 1, Queries: []string{"foo"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rb.Swapped || rb.OldCount != 1 || rb.NewCount != 2 || len(rb.SpotChecks) != 1 {
+		t.Fatalf("RebuildSearchDocuments: got %+v, want 1 document replaced by 2 and one spot check", rb)
+	}
+	for _, want := range []struct{ path, modulePath, version string }{
+		{"a.com/m/foo", "a.com/m", "v1.1.0"},
+		{"b.com/n/foo", "b.com/n", "v2.0.0"},
+	} {
+		gotModulePath, gotVersion, found := GetFromSearchDocuments(ctx, t, testDB, want.path)
+		if !found || gotModulePath != want.modulePath || gotVersion != want.version {
+			t.Errorf("%s: got (%q, %q, %t), want (%q, %q, true)", want.path, gotModulePath, gotVersion, found, want.modulePath, want.version)
+		}
+	}
+	if _, _, found := GetFromSearchDocuments(ctx, t, testDB, "a.com/m/internal/bar"); found {
+		t.Error("internal package was added to search_documents")
+	}
+	var count int
+	if err := testDB.db.QueryRow(ctx, `SELECT imported_by_count FROM search_documents WHERE package_path = 'a.com/m/foo'`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 7 {
+		t.Errorf("imported_by_count: got %d, want 7", count)
+	}
+	if diff := cmp.Diff(indexesBefore, searchIndexNames(ctx, t)); diff != "" {
+		t.Errorf("indexes mismatch (-before, +after):\n%s", diff)
+	}
+
+	// The new table works like the old one: its triggers and foreign keys
+	// are in place.
+	if err := testDB.InsertModule(ctx, sample.Module("c.com/o", "v1.0.0", "foo")); err != nil {
+		t.Fatal(err)
+	}
+	var hasParents bool
+	if err := testDB.db.QueryRow(ctx, `
+		SELECT tsv_parent_directories IS NOT NULL FROM search_documents WHERE package_path = 'c.com/o/foo'`).Scan(&hasParents); err != nil {
+		t.Fatal(err)
+	}
+	if !hasParents {
+		t.Error("tsv_parent_directories was not set by trigger")
+	}
+	if err := testDB.DeleteModule(ctx, "b.com/n", "v2.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, found := GetFromSearchDocuments(ctx, t, testDB, "b.com/n/foo"); found {
+		t.Error("deleting the module did not delete its search documents")
+	}
+}
+
+func TestRebuildSearchDocumentsDeletion(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []string{"a.com/m", "b.com/n", "c.com/o"} {
+		if err := testDB.InsertModule(ctx, sample.Module(m, "v1.0.0", "foo")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Delete a module and a search document after the shadow table has
+	// been loaded with them.
+	defer func(f func(context.Context)) { afterLoadingShadowSearchTable = f }(afterLoadingShadowSearchTable)
+	afterLoadingShadowSearchTable = func(ctx context.Context) {
+		if err := testDB.DeleteModule(ctx, "b.com/n", "v1.0.0"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := testDB.db.Exec(ctx, `DELETE FROM search_documents WHERE package_path = 'c.com/o/foo'`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The shadow table is compared before the deletions are applied to it.
+	rb, err := testDB.RebuildSearchDocuments(ctx, SearchRebuildOptions{MaxCountChange: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rb.Swapped {
+		t.Fatalf("RebuildSearchDocuments: got %+v, want swapped", rb)
+	}
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"a.com/m/foo", true},
+		{"b.com/n/foo", false},
+		{"c.com/o/foo", false},
+	} {
+		if _, _, found := GetFromSearchDocuments(ctx, t, testDB, test.path); found != test.want {
+			t.Errorf("%s: found = %t, want %t", test.path, found, test.want)
+		}
+	}
+	var exists bool
+	if err := testDB.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, searchDeletionsTable).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("%s was not dropped", searchDeletionsTable)
+	}
+}
+
+func searchIndexNames(ctx context.Context, t *testing.T) []string {
+	t.Helper()
+	indexes, err := searchIndexes(ctx, testDB.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, ix := range indexes {
+		names = append(names, ix.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestSearchIndexDefinition(t *testing.T) {
+	for _, test := range []struct {
+		ix   searchIndex
+		want string
+	}{
+		{
+			searchIndex{
+				name: "search_documents_pkey",
+				def:  "CREATE UNIQUE INDEX search_documents_pkey ON public.search_documents USING btree (package_path)",
+			},
+			"CREATE UNIQUE INDEX search_documents_pkey_new ON search_documents_shadow USING btree (package_path)",
+		},
+		{
+			searchIndex{
+				name: "idx_imported_by_count_desc",
+				def:  "CREATE INDEX idx_imported_by_count_desc ON public.search_documents USING btree (imported_by_count DESC)",
+			},
+			"CREATE INDEX idx_imported_by_count_desc_new ON search_documents_shadow USING btree (imported_by_count DESC)",
+		},
+	} {
+		got := test.ix.definition(shadowSearchTable, test.ix.name+"_new")
+		if got != test.want {
+			t.Errorf("definition(%q):\ngot  %q\nwant %q", test.ix.def, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// slowQueryRetention is how long slow queries are kept in the slow_queries
+// table.
+const slowQueryRetention = 7 * 24 * time.Hour
+
+// RecordSlowQuery inserts sq into the slow_queries table, and deletes slow
+// queries older than a week. It logs errors instead of returning them, so
+// that it can be used as the Record function of database.SlowQueryOptions.
+func (db *DB) RecordSlowQuery(ctx context.Context, sq *database.SlowQuery) {
+	if err := db.insertSlowQuery(ctx, sq); err != nil {
+		log.Error(ctx, err)
+	}
+}
+
+func (db *DB) insertSlowQuery(ctx context.Context, sq *database.SlowQuery) (err error) {
+	defer derrors.Wrap(&err, "insertSlowQuery(ctx, %q)", sq.Query)
+
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO slow_queries (recorded_at, query, args, duration_ms, error, plan)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			sq.RecordedAt, sq.Query, sq.Args, float64(sq.Duration)/float64(time.Millisecond), sq.Error, sq.Plan); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM slow_queries WHERE recorded_at < $1`, sq.RecordedAt.Add(-slowQueryRetention))
+		return err
+	})
+}
+
+// GetSlowQueries returns up to limit of the most recently recorded slow
+// queries.
+func (db *DB) GetSlowQueries(ctx context.Context, limit int) (_ []*database.SlowQuery, err error) {
+	defer derrors.Wrap(&err, "GetSlowQueries(ctx, %d)", limit)
+
+	query := `
+		SELECT recorded_at, query, args, duration_ms, error, plan
+		FROM slow_queries
+		ORDER BY recorded_at DESC
+		LIMIT $1`
+	var sqs []*database.SlowQuery
+	collect := func(rows *sql.Rows) error {
+		var (
+			sq database.SlowQuery
+			ms float64
+		)
+		if err := rows.Scan(&sq.RecordedAt, &sq.Query, &sq.Args, &ms, &sq.Error, &sq.Plan); err != nil {
+			return err
+		}
+		sq.Duration = time.Duration(ms * float64(time.Millisecond))
+		sqs = append(sqs, &sq)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, limit); err != nil {
+		return nil, err
+	}
+	return sqs, nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/database"
+)
+
+func TestSlowQueries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	old := &database.SlowQuery{
+		Query:      "SELECT 1",
+		Duration:   2 * time.Second,
+		RecordedAt: now.Add(-2 * slowQueryRetention),
+	}
+	recent := &database.SlowQuery{
+		Query:      "SELECT * FROM modules WHERE module_path = $1",
+		Args:       "<string len=10>",
+		Duration:   1500 * time.Millisecond,
+		Plan:       "Seq Scan on modules",
+		RecordedAt: now,
+	}
+	testDB.RecordSlowQuery(ctx, old)
+	testDB.RecordSlowQuery(ctx, recent)
+
+	got, err := testDB.GetSlowQueries(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The old query should have been deleted when the recent one was recorded.
+	want := []*database.SlowQuery{recent}
+	if diff := cmp.Diff(want, got, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Errorf("GetSlowQueries mismatch (-want +got):\n%s", diff)
+	}
+}
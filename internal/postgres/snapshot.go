@@ -0,0 +1,190 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/licenses"
+)
+
+// ExportModule reads everything that is stored about modulePath at version
+// back into an internal.Module, in the form that InsertModule accepts, so
+// that the module can be copied to another database with ImportModule. The
+// version may be internal.LatestVersion.
+//
+// The module keeps the app version that processed it, so that importing it
+// does not make its rows look stale to reprocessing campaigns. Data that is
+// derived from other modules, like imported-by counts, is not part of the
+// module; the importing database computes it again.
+func (db *DB) ExportModule(ctx context.Context, modulePath, version string) (_ *internal.Module, err error) {
+	defer derrors.Wrap(&err, "DB.ExportModule(ctx, %q, %q)", modulePath, version)
+
+	mi, err := db.LegacyGetModuleInfo(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	m := &internal.Module{LegacyModuleInfo: *mi}
+	version = mi.Version
+	if err := db.db.QueryRow(ctx, `
+		SELECT app_version FROM modules WHERE module_path = $1 AND version = $2`,
+		modulePath, version).Scan(database.NullIsEmpty(&m.AppVersion)); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.db.Query(ctx, `
+		SELECT types, expression, file_path, contents, coverage
+		FROM licenses
+		WHERE module_path = $1 AND version = $2 AND kind = 'license'`, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if m.Licenses, err = collectLicenses(rows); err != nil {
+		return nil, err
+	}
+	if m.Notices, err = exportNotices(ctx, db.db, modulePath, version); err != nil {
+		return nil, err
+	}
+	if m.Requirements, err = db.GetModuleRequirements(ctx, modulePath, version); err != nil {
+		return nil, err
+	}
+	if m.LegacyPackages, err = exportPackages(ctx, db.db, db.blobs, modulePath, version); err != nil {
+		return nil, err
+	}
+	for _, p := range m.LegacyPackages {
+		if p.Imports, err = db.GetImports(ctx, p.Path, modulePath, version); err != nil {
+			return nil, err
+		}
+		if p.Findings, err = db.GetPackageFindings(ctx, p.Path, modulePath, version); err != nil {
+			return nil, err
+		}
+	}
+
+	paths, err := db.getPathsInModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		um, err := db.GetUnitMeta(ctx, p.path, modulePath, version)
+		if err != nil {
+			return nil, err
+		}
+		vd, err := db.GetUnit(ctx, um, internal.AllFields)
+		if err != nil {
+			return nil, err
+		}
+		d := vd.DirectoryNew
+		if d.Path != modulePath {
+			// GetUnit returns the README of the module for every unit, but
+			// only the module root has one.
+			d.Readme = nil
+		}
+		m.Directories = append(m.Directories, &d)
+	}
+	return m, nil
+}
+
+// exportNotices returns all the notice files of modulePath at version,
+// ordered by file path.
+func exportNotices(ctx context.Context, db *database.DB, modulePath, version string) ([]*licenses.Notice, error) {
+	var notices []*licenses.Notice
+	collect := func(rows *sql.Rows) error {
+		n := &licenses.Notice{}
+		if err := rows.Scan(&n.Kind, &n.FilePath, database.Compressed(&n.Contents)); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		notices = append(notices, n)
+		return nil
+	}
+	if err := db.RunQuery(ctx, `
+		SELECT kind, file_path, contents
+		FROM licenses
+		WHERE module_path = $1 AND version = $2 AND kind != 'license'
+		ORDER BY file_path`, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
+
+// exportPackages returns the packages of modulePath at version, ordered by
+// path, with every column of the packages table. Unlike
+// LegacyGetPackagesInModule, it also reads the documentation coverage.
+func exportPackages(ctx context.Context, db *database.DB, blobs *blobStore, modulePath, version string) ([]*internal.LegacyPackage, error) {
+	var (
+		packages []*internal.LegacyPackage
+		docKeys  []string
+	)
+	collect := func(rows *sql.Rows) error {
+		var (
+			p                          internal.LegacyPackage
+			licenseTypes, licensePaths []string
+			docKey                     string
+		)
+		if err := rows.Scan(&p.Path, &p.Name, database.NullIsEmpty(&p.Synopsis), &p.V1Path,
+			pq.Array(&licenseTypes), pq.Array(&licensePaths), &p.IsRedistributable,
+			database.Compressed(&p.DocumentationHTML), database.NullIsEmpty(&docKey), &p.DocumentationCoverage, &p.GOOS, &p.GOARCH, pq.Array(&p.Symbols)); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		lics, err := zipLicenseMetadata(licenseTypes, licensePaths)
+		if err != nil {
+			return err
+		}
+		p.Licenses = lics
+		packages = append(packages, &p)
+		docKeys = append(docKeys, docKey)
+		return nil
+	}
+	if err := db.RunQuery(ctx, `
+		SELECT
+			path,
+			name,
+			synopsis,
+			v1_path,
+			license_types,
+			license_paths,
+			redistributable,
+			documentation,
+			documentation_blob_key,
+			documentation_coverage,
+			goos,
+			goarch,
+			exported_symbols
+		FROM packages
+		WHERE module_path = $1 AND version = $2
+		ORDER BY path`, collect, modulePath, version); err != nil {
+		return nil, err
+	}
+	for i, p := range packages {
+		if err := blobs.load(ctx, &p.DocumentationHTML, docKeys[i]); err != nil {
+			return nil, err
+		}
+	}
+	return packages, nil
+}
+
+// ImportModule inserts m, which was read from another database by
+// ExportModule. Since an exported module carries its directories, they are
+// written even if the experiment that makes InsertModule write them is not
+// active.
+func (db *DB) ImportModule(ctx context.Context, m *internal.Module) (err error) {
+	defer derrors.Wrap(&err, "DB.ImportModule(ctx, Module(%q, %q))", m.ModulePath, m.Version)
+
+	if len(m.Directories) > 0 && !experiment.IsActive(ctx, internal.ExperimentInsertDirectories) {
+		active := map[string]bool{internal.ExperimentInsertDirectories: true}
+		for _, e := range experiment.FromContext(ctx).Active() {
+			active[e] = true
+		}
+		ctx = experiment.NewContext(ctx, experiment.NewSet(active))
+	}
+	return db.InsertModule(ctx, m)
+}
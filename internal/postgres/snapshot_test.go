@@ -0,0 +1,74 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestExportImportModule(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	insertCtx := experiment.NewContext(ctx,
+		experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true}))
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("a.com/m", "v1.2.3", "dir/p", "dir/q")
+	m.Notices = []*licenses.Notice{{Kind: "notice", FilePath: "NOTICE", Contents: []byte("notice")}}
+	m.Requirements = []*internal.ModuleRequirement{{ModulePath: "b.com/n", Version: "v1.0.0"}}
+	m.LegacyPackages[0].Findings = []*internal.Finding{{Analyzer: "vet", Category: "assign", Count: 1}}
+	m.LegacyPackages[0].Symbols = []string{"F", "T", "T.M"}
+	m.AppVersion = "2020-06-01t00"
+	if err := testDB.InsertModule(insertCtx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testDB.ExportModule(ctx, "a.com/m", "v9.9.9"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("ExportModule for a missing version: got %v, want %v", err, derrors.NotFound)
+	}
+	exported, err := testDB.ExportModule(ctx, "a.com/m", internal.LatestVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := exported.Version, m.Version; got != want {
+		t.Errorf("got version %q, want %q", got, want)
+	}
+	if got, want := exported.AppVersion, m.AppVersion; got != want {
+		t.Errorf("got app version %q, want %q", got, want)
+	}
+	if got, want := len(exported.LegacyPackages), len(m.LegacyPackages); got != want {
+		t.Errorf("got %d packages, want %d", got, want)
+	}
+	if got, want := len(exported.Directories), len(m.Directories); got != want {
+		t.Errorf("got %d directories, want %d", got, want)
+	}
+
+	// Importing the module into an empty database and exporting it again
+	// gives back the same module. ImportModule writes the directories
+	// without the experiment.
+	ResetTestDB(testDB, t)
+	if err := testDB.ImportModule(ctx, exported); err != nil {
+		t.Fatal(err)
+	}
+	got, err := testDB.ExportModule(ctx, "a.com/m", "v1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(exported, got, cmp.AllowUnexported(source.Info{})); diff != "" {
+		t.Errorf("ExportModule after ImportModule mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/stdlib"
+)
+
+// PathSuggestions are packages that a user who asked for a path that does not
+// exist may have meant. A package appears in at most one list.
+type PathSuggestions struct {
+	// SameModule are other packages in the module that the path would be in.
+	SameModule []*PathSuggestion
+	// OtherMajorVersions are the packages at the same path in other major
+	// versions of the module.
+	OtherMajorVersions []*PathSuggestion
+	// Similar are packages whose paths are spelled like the path.
+	Similar []*PathSuggestion
+}
+
+// A PathSuggestion is the latest version of a package suggested for a path
+// that does not exist.
+type PathSuggestion struct {
+	PackagePath string
+	ModulePath  string
+	Synopsis    string
+}
+
+// minSimilarity is the lowest trigram similarity, between 0 and 1, of the
+// path of a package suggested for being spelled like a path that does not
+// exist.
+const minSimilarity = 0.5
+
+// GetPathSuggestions returns at most limit suggestions of each kind for
+// fullPath, which is not in the database. If modulePath is
+// internal.UnknownModulePath, the module that fullPath would be in is the
+// longest module in the database whose path is a prefix of fullPath, if any.
+func (db *DB) GetPathSuggestions(ctx context.Context, fullPath, modulePath string, limit int) (_ *PathSuggestions, err error) {
+	defer derrors.Wrap(&err, "DB.GetPathSuggestions(ctx, %q, %q, %d)", fullPath, modulePath, limit)
+
+	if modulePath == internal.UnknownModulePath {
+		modulePath, err = db.longestModulePrefix(ctx, fullPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	seen := map[string]bool{fullPath: true}
+	query := func(q string, args ...interface{}) ([]*PathSuggestion, error) {
+		var sugs []*PathSuggestion
+		collect := func(rows *sql.Rows) error {
+			var (
+				s        PathSuggestion
+				synopsis sql.NullString
+			)
+			if err := rows.Scan(&s.PackagePath, &s.ModulePath, &synopsis); err != nil {
+				return err
+			}
+			if seen[s.PackagePath] || len(sugs) == limit {
+				return nil
+			}
+			seen[s.PackagePath] = true
+			s.Synopsis = synopsis.String
+			sugs = append(sugs, &s)
+			return nil
+		}
+		// Ask for more rows than needed, since some may have been suggested
+		// already.
+		if err := db.db.RunQuery(ctx, q, collect, append(args, 2*limit)...); err != nil {
+			return nil, err
+		}
+		return sugs, nil
+	}
+
+	var sugs PathSuggestions
+	if modulePath != internal.UnknownModulePath {
+		sugs.SameModule, err = query(`
+			SELECT package_path, module_path, synopsis
+			FROM search_documents
+			WHERE module_path = $1
+			ORDER BY similarity(package_path, $2) DESC, package_path
+			LIMIT $3`, modulePath, fullPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sugs.OtherMajorVersions, err = query(`
+		SELECT s.package_path, s.module_path, s.synopsis
+		FROM search_documents s
+		INNER JOIN packages p
+		ON p.path = s.package_path
+		AND p.module_path = s.module_path
+		AND p.version = s.version
+		WHERE p.v1_path = $1
+		ORDER BY s.module_path
+		LIMIT $2`, v1PathForSuggestions(fullPath, modulePath))
+	if err != nil {
+		return nil, err
+	}
+	// The % operator uses the trigram index, with the threshold set by
+	// pg_trgm.similarity_threshold, which is 0.3 by default.
+	sugs.Similar, err = query(`
+		SELECT package_path, module_path, synopsis
+		FROM (
+			SELECT package_path, module_path, synopsis, imported_by_count,
+				similarity(package_path, $1) AS sim
+			FROM search_documents
+			WHERE package_path % $1
+		) s
+		WHERE sim >= $2
+		ORDER BY sim DESC, imported_by_count DESC, package_path
+		LIMIT $3`, fullPath, minSimilarity)
+	if err != nil {
+		return nil, err
+	}
+	return &sugs, nil
+}
+
+// longestModulePrefix returns the longest path of a module in the database
+// that is fullPath or a prefix of it, or internal.UnknownModulePath if there
+// is none.
+func (db *DB) longestModulePrefix(ctx context.Context, fullPath string) (string, error) {
+	if stdlib.Contains(fullPath) {
+		return stdlib.ModulePath, nil
+	}
+	var prefixes []string
+	for p := fullPath; p != "." && p != "/"; p = path.Dir(p) {
+		prefixes = append(prefixes, p)
+	}
+	var modulePath string
+	err := db.db.QueryRow(ctx, `
+		SELECT module_path
+		FROM modules
+		WHERE module_path = ANY($1)
+		ORDER BY length(module_path) DESC
+		LIMIT 1`, pq.Array(prefixes)).Scan(&modulePath)
+	if err == sql.ErrNoRows {
+		return internal.UnknownModulePath, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return modulePath, nil
+}
+
+// majorVersionElem matches the major version element of an import path, as
+// in example.com/mod/v2/pkg.
+var majorVersionElem = regexp.MustCompile(`/v[0-9]+(/|$)`)
+
+// v1PathForSuggestions returns the path of fullPath at major version 1, the
+// v1_path of the packages at the same path in the other major versions of its
+// module. The path may be in a major version that does not exist, as in
+// example.com/mod/v3/pkg when only example.com/mod is known. If the module is
+// not known, its major version is guessed from the first element of fullPath
+// that looks like one.
+func v1PathForSuggestions(fullPath, modulePath string) string {
+	if modulePath == stdlib.ModulePath {
+		return fullPath
+	}
+	if modulePath == internal.UnknownModulePath {
+		if loc := majorVersionElem.FindStringSubmatchIndex(fullPath); loc != nil {
+			return fullPath[:loc[0]] + fullPath[loc[2]:]
+		}
+		return fullPath
+	}
+	suffix := strings.TrimPrefix(fullPath, modulePath)
+	if loc := majorVersionElem.FindStringSubmatchIndex(suffix); loc != nil && loc[0] == 0 {
+		suffix = suffix[loc[2]:]
+	}
+	return internal.SeriesPathForModule(modulePath) + suffix
+}
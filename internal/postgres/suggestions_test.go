@@ -0,0 +1,113 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetPathSuggestions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []*internal.Module{
+		sample.Module("example.com/suggest", "v1.2.0", "alpha", "beta"),
+		sample.Module("example.com/suggest/v2", "v2.0.0", "alpha", "gamma"),
+		sample.Module("example.com/unrelated", "v1.0.0", "zzz"),
+	} {
+		if err := testDB.InsertModule(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := func(sugs []*PathSuggestion) []string {
+		var ps []string
+		for _, s := range sugs {
+			ps = append(ps, s.PackagePath)
+		}
+		return ps
+	}
+	for _, test := range []struct {
+		name, fullPath, modulePath      string
+		wantSameModule, wantOtherMajors []string
+		wantSimilar                     string // a path among the similar suggestions
+	}{
+		{
+			name:            "missing package in a module",
+			fullPath:        "example.com/suggest/v2/beta",
+			modulePath:      internal.UnknownModulePath,
+			wantSameModule:  []string{"example.com/suggest/v2/alpha", "example.com/suggest/v2/gamma"},
+			wantOtherMajors: []string{"example.com/suggest/beta"},
+		},
+		{
+			name:            "missing major version",
+			fullPath:        "example.com/suggest/v3/alpha",
+			modulePath:      internal.UnknownModulePath,
+			wantSameModule:  []string{"example.com/suggest/alpha", "example.com/suggest/beta"},
+			wantOtherMajors: []string{"example.com/suggest/v2/alpha"},
+		},
+		{
+			name:        "misspelled path",
+			fullPath:    "example.com/sugest/alpah",
+			modulePath:  internal.UnknownModulePath,
+			wantSimilar: "example.com/suggest/alpha",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testDB.GetPathSuggestions(ctx, test.fullPath, test.modulePath, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sortStrings := cmp.Transformer("sort", func(in []string) []string {
+				out := append([]string(nil), in...)
+				sort.Strings(out)
+				return out
+			})
+			if diff := cmp.Diff(test.wantSameModule, paths(got.SameModule), sortStrings); diff != "" {
+				t.Errorf("SameModule mismatch (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(test.wantOtherMajors, paths(got.OtherMajorVersions)); diff != "" {
+				t.Errorf("OtherMajorVersions mismatch (-want, +got):\n%s", diff)
+			}
+			if test.wantSimilar != "" {
+				found := false
+				for _, p := range paths(got.Similar) {
+					found = found || p == test.wantSimilar
+				}
+				if !found {
+					t.Errorf("Similar = %v, want it to contain %q", paths(got.Similar), test.wantSimilar)
+				}
+			}
+		})
+	}
+}
+
+func TestV1PathForSuggestions(t *testing.T) {
+	for _, test := range []struct {
+		fullPath, modulePath, want string
+	}{
+		{"example.com/mod/pkg", "example.com/mod", "example.com/mod/pkg"},
+		{"example.com/mod/v2/pkg", "example.com/mod/v2", "example.com/mod/pkg"},
+		{"example.com/mod/v2", "example.com/mod/v2", "example.com/mod"},
+		{"gopkg.in/yaml.v2/sub", "gopkg.in/yaml.v2", "gopkg.in/yaml/sub"},
+		{"example.com/mod/v3/pkg", "example.com/mod", "example.com/mod/pkg"},
+		{"example.com/mod/pkg/v3", "example.com/mod", "example.com/mod/pkg/v3"},
+		{"net/htp", "std", "net/htp"},
+		{"example.com/mod/v3/pkg", internal.UnknownModulePath, "example.com/mod/pkg"},
+		{"example.com/mod/v3", internal.UnknownModulePath, "example.com/mod"},
+		{"example.com/mod/pkg", internal.UnknownModulePath, "example.com/mod/pkg"},
+	} {
+		if got := v1PathForSuggestions(test.fullPath, test.modulePath); got != test.want {
+			t.Errorf("v1PathForSuggestions(%q, %q) = %q, want %q", test.fullPath, test.modulePath, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A SearchSynonym makes search queries for Term also match Synonym.
+type SearchSynonym struct {
+	Term      string
+	Synonym   string
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// InsertSearchSynonym adds a synonym to the search_synonyms table. Searches
+// expand a term with its synonyms as soon as the synonym is inserted. The
+// term and the synonym must be lowercase, different, and not made only of
+// words that text search ignores, like "the".
+func (db *DB) InsertSearchSynonym(ctx context.Context, s *SearchSynonym) (err error) {
+	defer derrors.Wrap(&err, "InsertSearchSynonym(ctx, %q, %q)", s.Term, s.Synonym)
+
+	if s.Term == "" || s.Synonym == "" || s.CreatedBy == "" {
+		return fmt.Errorf("term, synonym and user must be non-empty: %w", derrors.InvalidArgument)
+	}
+	if s.Term != strings.ToLower(s.Term) || s.Synonym != strings.ToLower(s.Synonym) {
+		return fmt.Errorf("term and synonym must be lowercase: %w", derrors.InvalidArgument)
+	}
+	if s.Term == s.Synonym {
+		return fmt.Errorf("term and synonym must be different: %w", derrors.InvalidArgument)
+	}
+	var termOK, synonymOK bool
+	if err := db.db.QueryRow(ctx, `
+		SELECT numnode(plainto_tsquery($1)) > 0, numnode(plainto_tsquery($2)) > 0`,
+		s.Term, s.Synonym).Scan(&termOK, &synonymOK); err != nil {
+		return err
+	}
+	if !termOK || !synonymOK {
+		return fmt.Errorf("term and synonym must not be ignored by text search: %w", derrors.InvalidArgument)
+	}
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO search_synonyms (term, synonym, created_by)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (term, synonym) DO UPDATE SET
+			created_by = excluded.created_by,
+			created_at = CURRENT_TIMESTAMP`,
+		s.Term, s.Synonym, s.CreatedBy)
+	return err
+}
+
+// DeleteSearchSynonym deletes synonym as a synonym of term. It returns a
+// derrors.NotFound error if there is no such synonym.
+func (db *DB) DeleteSearchSynonym(ctx context.Context, term, synonym string) (err error) {
+	defer derrors.Wrap(&err, "DeleteSearchSynonym(ctx, %q, %q)", term, synonym)
+
+	res, err := db.db.Exec(ctx, `DELETE FROM search_synonyms WHERE term = $1 AND synonym = $2`, term, synonym)
+	if err != nil {
+		return err
+	}
+	return notFoundIfNoRows(res)
+}
+
+// GetSearchSynonyms returns all search synonyms, ordered by term and synonym.
+func (db *DB) GetSearchSynonyms(ctx context.Context) (_ []*SearchSynonym, err error) {
+	defer derrors.Wrap(&err, "GetSearchSynonyms(ctx)")
+
+	var synonyms []*SearchSynonym
+	if err := db.db.CollectStructs(ctx, &synonyms, `
+		SELECT term, synonym, created_by, created_at
+		FROM search_synonyms
+		ORDER BY term, synonym`); err != nil {
+		return nil, err
+	}
+	return synonyms, nil
+}
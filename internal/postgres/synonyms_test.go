@@ -0,0 +1,135 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestSearchSynonyms(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, m := range []struct{ path, pkg string }{
+		{"example.com/kubernetes", "client"},
+		{"example.com/k8s", "util"},
+		{"example.com/postgresql", "driver"},
+		{"example.com/other", "client"},
+	} {
+		if err := testDB.InsertModule(ctx, sample.Module(m.path, sample.VersionString, m.pkg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := func(query string) []string {
+		t.Helper()
+		results, err := testDB.Search(ctx, query, 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r.PackagePath)
+		}
+		sort.Strings(paths)
+		return paths
+	}
+
+	synonyms := map[string]string{"k8s": "kubernetes", "postgres": "postgresql"}
+	queries := []string{"k8s", "k8s client", "kubernetes", "postgres", "postgres driver", "client"}
+	// Search without synonyms, for the query and for the query with the term
+	// replaced by its synonym.
+	without := map[string][]string{}
+	for _, q := range queries {
+		without[q] = search(q)
+		for term, syn := range synonyms {
+			if r := strings.ReplaceAll(q, term, syn); r != q {
+				without[r] = search(r)
+			}
+		}
+	}
+	for term, syn := range synonyms {
+		if err := testDB.InsertSearchSynonym(ctx, &SearchSynonym{Term: term, Synonym: syn, CreatedBy: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		query string
+		want  []string
+	}{
+		{"k8s", []string{"example.com/k8s/util", "example.com/kubernetes/client"}},
+		{"k8s client", []string{"example.com/kubernetes/client"}},
+		// Synonyms apply in one direction only.
+		{"kubernetes", []string{"example.com/kubernetes/client"}},
+		{"postgres driver", []string{"example.com/postgresql/driver"}},
+	} {
+		if diff := cmp.Diff(test.want, search(test.query)); diff != "" {
+			t.Errorf("Search(%q) mismatch (-want +got):\n%s", test.query, diff)
+		}
+	}
+
+	// Expanding a query must not cost precision: it only finds what the
+	// query or its expansion finds on their own.
+	for _, q := range queries {
+		allowed := map[string]bool{}
+		for _, p := range without[q] {
+			allowed[p] = true
+		}
+		for term, syn := range synonyms {
+			for _, p := range without[strings.ReplaceAll(q, term, syn)] {
+				allowed[p] = true
+			}
+		}
+		for _, p := range search(q) {
+			if !allowed[p] {
+				t.Errorf("Search(%q) with synonyms found %s, which neither the query nor its expansion finds", q, p)
+			}
+		}
+	}
+
+	if err := testDB.DeleteSearchSynonym(ctx, "k8s", "kubernetes"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(without["k8s"], search("k8s")); diff != "" {
+		t.Errorf("Search(%q) after deleting synonym mismatch (-want +got):\n%s", "k8s", diff)
+	}
+	if err := testDB.DeleteSearchSynonym(ctx, "k8s", "kubernetes"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("DeleteSearchSynonym of missing synonym: got %v, want NotFound", err)
+	}
+}
+
+func TestInsertSearchSynonymInvalid(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	for _, s := range []*SearchSynonym{
+		{Term: "", Synonym: "kubernetes", CreatedBy: "test"},
+		{Term: "k8s", Synonym: "kubernetes"},
+		{Term: "K8s", Synonym: "kubernetes", CreatedBy: "test"},
+		{Term: "k8s", Synonym: "k8s", CreatedBy: "test"},
+		{Term: "the", Synonym: "kubernetes", CreatedBy: "test"},
+	} {
+		if err := testDB.InsertSearchSynonym(ctx, s); !errors.Is(err, derrors.InvalidArgument) {
+			t.Errorf("InsertSearchSynonym(%+v): got %v, want InvalidArgument", s, err)
+		}
+	}
+	got, err := testDB.GetSearchSynonyms(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetSearchSynonyms: got %d synonyms, want none", len(got))
+	}
+}
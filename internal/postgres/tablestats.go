@@ -0,0 +1,129 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// tableStatsRetention is how long snapshots are kept in the table_stats
+// table.
+const tableStatsRetention = 30 * 24 * time.Hour
+
+// TableStats is a snapshot of the size and vacuuming of a table.
+type TableStats struct {
+	RecordedAt  time.Time
+	TableName   string
+	RowEstimate int64
+	TableBytes  int64 // size of the table, including TOAST
+	IndexBytes  int64 // size of all the indexes of the table
+	LiveTuples  int64
+	DeadTuples  int64
+	// The times of the last manual vacuum, automatic vacuum and automatic
+	// analyze of the table. They are zero if it never happened.
+	LastVacuum      time.Time
+	LastAutovacuum  time.Time
+	LastAutoanalyze time.Time
+	AutovacuumCount int64
+}
+
+// DeadTupleRatio returns the fraction of the tuples of the table that are
+// dead.
+func (ts *TableStats) DeadTupleRatio() float64 {
+	if ts.LiveTuples+ts.DeadTuples == 0 {
+		return 0
+	}
+	return float64(ts.DeadTuples) / float64(ts.LiveTuples+ts.DeadTuples)
+}
+
+// LastVacuumed returns the time the table was last vacuumed, manually or
+// not, or the zero time if it never was.
+func (ts *TableStats) LastVacuumed() time.Time {
+	if ts.LastAutovacuum.After(ts.LastVacuum) {
+		return ts.LastAutovacuum
+	}
+	return ts.LastVacuum
+}
+
+const tableStatsColumns = `
+	recorded_at,
+	table_name,
+	row_estimate,
+	table_bytes,
+	index_bytes,
+	live_tuples,
+	dead_tuples,
+	last_vacuum,
+	last_autovacuum,
+	last_autoanalyze,
+	autovacuum_count`
+
+// RecordTableStats records a snapshot of the statistics of every table of the
+// database in the table_stats table, and deletes snapshots older than 30
+// days. It returns the new snapshot, like GetTableStats.
+func (db *DB) RecordTableStats(ctx context.Context) (_ []*TableStats, err error) {
+	defer derrors.Wrap(&err, "RecordTableStats(ctx)")
+
+	err = db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		// reltuples is -1 for tables that were never vacuumed or analyzed.
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO table_stats (`+tableStatsColumns+`)
+			SELECT
+				CURRENT_TIMESTAMP,
+				s.relname,
+				GREATEST(c.reltuples, 0)::bigint,
+				pg_table_size(s.relid),
+				pg_indexes_size(s.relid),
+				s.n_live_tup,
+				s.n_dead_tup,
+				s.last_vacuum,
+				s.last_autovacuum,
+				s.last_autoanalyze,
+				s.autovacuum_count
+			FROM pg_stat_user_tables s
+			INNER JOIN pg_class c ON c.oid = s.relid
+			WHERE s.schemaname = 'public'`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM table_stats WHERE recorded_at < $1`, time.Now().Add(-tableStatsRetention))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetTableStats(ctx)
+}
+
+// GetTableStats returns the most recent snapshot of the statistics of the
+// tables of the database, largest tables first.
+func (db *DB) GetTableStats(ctx context.Context) (_ []*TableStats, err error) {
+	defer derrors.Wrap(&err, "GetTableStats(ctx)")
+
+	query := `
+		SELECT ` + tableStatsColumns + `
+		FROM table_stats
+		WHERE recorded_at = (SELECT MAX(recorded_at) FROM table_stats)
+		ORDER BY table_bytes + index_bytes DESC, table_name`
+	var stats []*TableStats
+	collect := func(rows *sql.Rows) error {
+		var ts TableStats
+		if err := rows.Scan(&ts.RecordedAt, &ts.TableName, &ts.RowEstimate, &ts.TableBytes, &ts.IndexBytes,
+			&ts.LiveTuples, &ts.DeadTuples, database.NullIsZero(&ts.LastVacuum), database.NullIsZero(&ts.LastAutovacuum),
+			database.NullIsZero(&ts.LastAutoanalyze), &ts.AutovacuumCount); err != nil {
+			return err
+		}
+		stats = append(stats, &ts)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordTableStats(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	defer ResetTestDB(testDB, t)
+
+	if got, err := testDB.GetTableStats(ctx); err != nil || len(got) != 0 {
+		t.Fatalf("GetTableStats before recording = %v, %v; want none", got, err)
+	}
+	recorded, err := testDB.RecordTableStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, ts := range recorded {
+		if ts.TableName == "modules" {
+			found = true
+		}
+		if ts.RecordedAt != recorded[0].RecordedAt {
+			t.Errorf("%s: recorded at %s, want %s", ts.TableName, ts.RecordedAt, recorded[0].RecordedAt)
+		}
+	}
+	if !found {
+		t.Error("no stats for the modules table")
+	}
+	got, err := testDB.GetTableStats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(recorded) {
+		t.Errorf("GetTableStats returned %d tables, want %d", len(got), len(recorded))
+	}
+}
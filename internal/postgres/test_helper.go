@@ -112,13 +112,32 @@ func ResetTestDB(db *DB, t *testing.T) {
 			TRUNCATE experiments;`); err != nil {
 			return err
 		}
+		if _, err := tx.Exec(ctx, `TRUNCATE reprocessing_campaigns CASCADE;`); err != nil {
+			return err
+		}
 		if _, err := tx.Exec(ctx, `TRUNCATE module_version_states CASCADE;`); err != nil {
 			return err
 		}
 		if _, err := tx.Exec(ctx, `TRUNCATE excluded_prefixes;`); err != nil {
 			return err
 		}
+		if _, err := tx.Exec(ctx, `TRUNCATE index_poll_state;`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `TRUNCATE index_gaps;`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `TRUNCATE fetch_queue;`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `TRUNCATE slow_queries, search_queries, search_synonyms, table_stats;`); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `TRUNCATE license_overrides, repo_status, removed_paths, module_redirects, audit_log, vulns, vuln_affected_versions;`); err != nil {
+			return err
+		}
 		setExcludedPrefixesLastFetched(time.Time{})
+		setRemovedPathsLastFetched(time.Time{})
 		return nil
 	}); err != nil {
 		t.Fatalf("error resetting test DB: %v", err)
@@ -127,11 +146,14 @@ func ResetTestDB(db *DB, t *testing.T) {
 
 // RunDBTests is a wrapper that runs the given testing suite in a test database
 // named dbName.  The given *DB reference will be set to the instantiated test
-// database.
+// database. If GO_DISCOVERY_TESTDB_TEMP is "true", the database is created in
+// a temporary Postgres cluster that is stopped when the tests are done.
 func RunDBTests(dbName string, m *testing.M, testDB **DB) {
 	database.QueryLoggingDisabled = true
+	stop := dbtest.StartClusterIfRequested()
 	db, err := SetupTestDB(dbName)
 	if err != nil {
+		stop()
 		if errors.Is(err, derrors.NotFound) && os.Getenv("GO_DISCOVERY_TESTDB") != "true" {
 			log.Printf("SKIPPING: could not connect to DB (see doc/postgres.md to set up): %v", err)
 			return
@@ -140,7 +162,9 @@ func RunDBTests(dbName string, m *testing.M, testDB **DB) {
 	}
 	*testDB = db
 	code := m.Run()
-	if err := db.Close(); err != nil {
+	err = db.Close()
+	stop()
+	if err != nil {
 		log.Fatal(err)
 	}
 	os.Exit(code)
@@ -0,0 +1,222 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// GetUnitMeta returns the metadata of the unit at path in modulePath at
+// version: the information about the module version and the path that every
+// page about the unit shows. The module version must be known.
+func (db *DB) GetUnitMeta(ctx context.Context, path, modulePath, version string) (_ *internal.UnitMeta, err error) {
+	defer derrors.Wrap(&err, "DB.GetUnitMeta(ctx, %q, %q, %q)", path, modulePath, version)
+
+	query := `
+		SELECT
+			m.module_path,
+			m.version,
+			m.commit_time,
+			m.version_type,
+			m.redistributable,
+			m.has_go_mod,
+			m.source_info,
+			m.published_at,
+			p.path,
+			p.name,
+			p.v1_path,
+			p.redistributable,
+			p.license_types,
+			p.license_paths
+		FROM modules m
+		INNER JOIN paths p
+		ON p.module_id = m.id
+		WHERE
+			p.path = $1
+			AND m.module_path = $2
+			AND m.version = $3`
+	var (
+		um                         internal.UnitMeta
+		licenseTypes, licensePaths []string
+	)
+	row := db.db.QueryRow(ctx, query, path, modulePath, version)
+	if err := row.Scan(
+		&um.ModulePath,
+		&um.Version,
+		&um.CommitTime,
+		&um.VersionType,
+		&um.ModuleInfo.IsRedistributable,
+		&um.HasGoMod,
+		database.JSONB(&um.SourceInfo),
+		database.NullIsZero(&um.PublishedAt),
+		&um.Path,
+		database.NullIsEmpty(&um.Name),
+		&um.V1Path,
+		&um.DirectoryMeta.IsRedistributable,
+		pq.Array(&licenseTypes),
+		pq.Array(&licensePaths),
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("unit %s@%s: %w", path, version, derrors.NotFound)
+		}
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	lics, err := zipLicenseMetadata(licenseTypes, licensePaths)
+	if err != nil {
+		return nil, err
+	}
+	um.Licenses = lics
+	return &um, nil
+}
+
+// GetUnit returns the unit described by um, which must come from
+// GetUnitMeta, with the fields in fields. Handlers should ask only for the
+// fields that they render, since READMEs and documentation can be large.
+//
+// If fields does not include internal.WithDocumentationHTML, the HTML of
+// the documentation is internal.StringFieldMissing; its other fields, like
+// the synopsis, are always read. Similarly, the contents of the README are
+// internal.StringFieldMissing unless fields includes
+// internal.WithReadmeContents. The imports of a package are read only if
+// fields includes internal.WithImports.
+func (db *DB) GetUnit(ctx context.Context, um *internal.UnitMeta, fields internal.FieldSet) (_ *internal.VersionedDirectory, err error) {
+	defer derrors.Wrap(&err, "DB.GetUnit(ctx, %q, %q, %q, %d)", um.Path, um.ModulePath, um.Version, fields)
+
+	dir := internal.DirectoryNew{DirectoryMeta: um.DirectoryMeta}
+	if um.IsPackage() {
+		pkg, err := getUnitPackage(ctx, db.db, db.blobs, um, fields)
+		if err != nil {
+			return nil, err
+		}
+		dir.Package = pkg
+	}
+	readme, err := getModuleReadme(ctx, db.db, um.ModulePath, um.Version, fields)
+	if err != nil {
+		return nil, err
+	}
+	dir.Readme = readme
+	return &internal.VersionedDirectory{
+		ModuleInfo:   um.ModuleInfo,
+		DirectoryNew: dir,
+	}, nil
+}
+
+// getUnitPackage returns the package of the unit described by um, with its
+// documentation, and with its imports if fields includes
+// internal.WithImports.
+func getUnitPackage(ctx context.Context, db *database.DB, blobs *blobStore, um *internal.UnitMeta, fields internal.FieldSet) (*internal.PackageNew, error) {
+	html := "NULL, NULL"
+	if fields&internal.WithDocumentationHTML != 0 {
+		html = "d.html, d.html_blob_key"
+	}
+	query := `
+		SELECT
+			p.id,
+			d.goos,
+			d.goarch,
+			d.synopsis,
+			` + html + `,
+			d.coverage
+		FROM modules m
+		INNER JOIN paths p
+		ON p.module_id = m.id
+		LEFT JOIN documentation d
+		ON d.path_id = p.id
+		WHERE
+			p.path = $1
+			AND m.module_path = $2
+			AND m.version = $3`
+	var (
+		pathID  int
+		doc     internal.Documentation
+		htmlKey string
+	)
+	row := db.QueryRow(ctx, query, um.Path, um.ModulePath, um.Version)
+	if err := row.Scan(
+		&pathID,
+		database.NullIsEmpty(&doc.GOOS),
+		database.NullIsEmpty(&doc.GOARCH),
+		database.NullIsEmpty(&doc.Synopsis),
+		database.Compressed(&doc.HTML),
+		database.NullIsEmpty(&htmlKey),
+		&doc.Coverage,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("package %s@%s: %w", um.Path, um.Version, derrors.NotFound)
+		}
+		return nil, fmt.Errorf("row.Scan(): %v", err)
+	}
+	if fields&internal.WithDocumentationHTML == 0 {
+		doc.HTML = internal.StringFieldMissing
+	} else if err := blobs.load(ctx, &doc.HTML, htmlKey); err != nil {
+		return nil, err
+	}
+	pkg := &internal.PackageNew{
+		Name:          um.Name,
+		Path:          um.Path,
+		Documentation: &doc,
+	}
+	if fields&internal.WithImports == 0 {
+		return pkg, nil
+	}
+	collect := func(rows *sql.Rows) error {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return fmt.Errorf("row.Scan(): %v", err)
+		}
+		pkg.Imports = append(pkg.Imports, path)
+		return nil
+	}
+	if err := db.RunQuery(ctx, `
+		SELECT to_path
+		FROM package_imports
+		WHERE path_id = $1
+		ORDER BY to_path`, collect, pathID); err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+// getModuleReadme returns the README at the root of modulePath at version, or
+// nil if it has none. Its contents are read only if fields includes
+// internal.WithReadmeContents.
+//
+// TODO(golang/go#38513): query the README of the unit instead of the module
+// once we start displaying READMEs for directories.
+func getModuleReadme(ctx context.Context, db *database.DB, modulePath, version string, fields internal.FieldSet) (*internal.Readme, error) {
+	contents := "NULL"
+	if fields&internal.WithReadmeContents != 0 {
+		contents = "r.contents"
+	}
+	var readme internal.Readme
+	row := db.QueryRow(ctx, `
+		SELECT r.file_path, `+contents+`
+		FROM modules m
+		INNER JOIN paths p
+		ON p.module_id = m.id
+		INNER JOIN readmes r
+		ON p.id = r.path_id
+		WHERE
+			m.module_path = $1
+			AND m.version = $2
+			AND m.module_path = p.path`, modulePath, version)
+	if err := row.Scan(&readme.Filepath, database.Compressed(&readme.Contents)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if fields&internal.WithReadmeContents == 0 {
+		readme.Contents = internal.StringFieldMissing
+	}
+	return &readme, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/source"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetUnit(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	ctx = experiment.NewContext(ctx,
+		experiment.NewSet(map[string]bool{
+			internal.ExperimentInsertDirectories: true}))
+
+	defer ResetTestDB(testDB, t)
+
+	m := sample.Module("a.com/m", "v1.2.3", "dir/p")
+	if err := testDB.InsertModule(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := testDB.GetUnitMeta(ctx, "a.com/m/nope", "a.com/m", "v1.2.3"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetUnitMeta for a missing path: got %v, want %v", err, derrors.NotFound)
+	}
+
+	um, err := testDB.GetUnitMeta(ctx, "a.com/m/dir/p", "a.com/m", "v1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMeta := &internal.UnitMeta{
+		DirectoryMeta: internal.DirectoryMeta{
+			Path:              "a.com/m/dir/p",
+			V1Path:            "a.com/m/dir/p",
+			IsRedistributable: true,
+			Licenses:          sample.LicenseMetadata,
+		},
+		ModuleInfo: *sample.ModuleInfo("a.com/m", "v1.2.3"),
+		Name:       "p",
+	}
+	opts := []cmp.Option{
+		cmp.AllowUnexported(source.Info{}),
+		// The paths table only includes partial license information; it omits the Coverage field.
+		cmpopts.IgnoreFields(licenses.Metadata{}, "Coverage"),
+	}
+	if diff := cmp.Diff(wantMeta, um, opts...); diff != "" {
+		t.Errorf("GetUnitMeta mismatch (-want +got):\n%s", diff)
+	}
+
+	wantPackage := func(html string, imports []string) *internal.PackageNew {
+		return &internal.PackageNew{
+			Name: "p",
+			Path: "a.com/m/dir/p",
+			Documentation: &internal.Documentation{
+				GOOS:     sample.GOOS,
+				GOARCH:   sample.GOARCH,
+				Synopsis: sample.Synopsis,
+				HTML:     html,
+			},
+			Imports: imports,
+		}
+	}
+	imports := []string{"fmt", "path/to/bar"}
+	for _, test := range []struct {
+		name        string
+		fields      internal.FieldSet
+		wantPackage *internal.PackageNew
+		wantReadme  *internal.Readme
+	}{
+		{
+			name:        "minimal",
+			fields:      internal.MinimalFields,
+			wantPackage: wantPackage(internal.StringFieldMissing, nil),
+			wantReadme:  &internal.Readme{Filepath: sample.ReadmeFilePath, Contents: internal.StringFieldMissing},
+		},
+		{
+			name:        "documentation",
+			fields:      internal.WithDocumentationHTML,
+			wantPackage: wantPackage(sample.DocumentationHTML, nil),
+			wantReadme:  &internal.Readme{Filepath: sample.ReadmeFilePath, Contents: internal.StringFieldMissing},
+		},
+		{
+			name:        "readme and imports",
+			fields:      internal.WithReadmeContents | internal.WithImports,
+			wantPackage: wantPackage(internal.StringFieldMissing, imports),
+			wantReadme:  &internal.Readme{Filepath: sample.ReadmeFilePath, Contents: sample.ReadmeContents},
+		},
+		{
+			name:        "all",
+			fields:      internal.AllFields,
+			wantPackage: wantPackage(sample.DocumentationHTML, imports),
+			wantReadme:  &internal.Readme{Filepath: sample.ReadmeFilePath, Contents: sample.ReadmeContents},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := testDB.GetUnit(ctx, um, test.fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &internal.VersionedDirectory{
+				ModuleInfo: um.ModuleInfo,
+				DirectoryNew: internal.DirectoryNew{
+					DirectoryMeta: um.DirectoryMeta,
+					Readme:        test.wantReadme,
+					Package:       test.wantPackage,
+				},
+			}
+			if diff := cmp.Diff(want, got, opts...); diff != "" {
+				t.Errorf("GetUnit(%d) mismatch (-want +got):\n%s", test.fields, diff)
+			}
+		})
+	}
+}
@@ -85,9 +85,7 @@ func (db *DB) GetVersionMap(ctx context.Context, modulePath, requestedVersion st
 			module_path=$1
 			AND requested_version=$2;`
 	var vm internal.VersionMap
-	err = db.db.QueryRow(ctx, query, modulePath, requestedVersion).Scan(
-		&vm.ModulePath, &vm.RequestedVersion, &vm.ResolvedVersion, &vm.GoModPath, &vm.Status, &vm.Error)
-	switch err {
+	switch err := db.db.QueryStruct(ctx, &vm, query, modulePath, requestedVersion); err {
 	case nil:
 		return &vm, nil
 	case sql.ErrNoRows:
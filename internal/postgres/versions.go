@@ -0,0 +1,153 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// VersionsOrder is the order of the versions on a VersionsPage.
+type VersionsOrder string
+
+const (
+	// OrderBySemver orders versions by semantic version, highest first.
+	OrderBySemver VersionsOrder = "semver"
+	// OrderByDate orders versions by commit time, latest first.
+	OrderByDate VersionsOrder = "date"
+)
+
+// A VersionsPage is a page of tagged versions. Versions are paginated by minor
+// version, so that the versions of a module path that share a major and a
+// minor version are never split across pages.
+type VersionsPage struct {
+	Minors []*MinorVersions
+	// NumMinors is the number of minor versions on all pages.
+	NumMinors int
+}
+
+// MinorVersions are the versions of a module path with the same major and
+// minor version.
+type MinorVersions struct {
+	ModulePath string
+	// Minor is the major and minor version, like "v1.2".
+	Minor    string
+	Versions []*internal.ModuleInfo
+}
+
+// GetModuleVersionsPage returns a page of at most limit minor versions,
+// starting at offset, of the tagged versions of the modules in the series of
+// modulePath.
+//
+// Minor versions are ordered by their highest version for OrderBySemver, and
+// by their latest commit for OrderByDate. The versions of a minor version are
+// ordered the same way.
+func (db *DB) GetModuleVersionsPage(ctx context.Context, modulePath string, order VersionsOrder, limit, offset int) (_ *VersionsPage, err error) {
+	defer derrors.Wrap(&err, "GetModuleVersionsPage(ctx, %q, %q, %d, %d)", modulePath, order, limit, offset)
+
+	const versions = `
+		SELECT module_path, version, commit_time, published_at, sort_version
+		FROM modules
+		WHERE series_path = $1
+		AND version_type IN ('release', 'prerelease')`
+	return getVersionsPage(ctx, db, versions, internal.SeriesPathForModule(modulePath), order, limit, offset)
+}
+
+// GetPackageVersionsPage is like GetModuleVersionsPage, for the tagged
+// versions of the modules that contain a package with the same v1 path as
+// pkgPath.
+func (db *DB) GetPackageVersionsPage(ctx context.Context, pkgPath string, order VersionsOrder, limit, offset int) (_ *VersionsPage, err error) {
+	defer derrors.Wrap(&err, "GetPackageVersionsPage(ctx, %q, %q, %d, %d)", pkgPath, order, limit, offset)
+
+	const versions = `
+		SELECT m.module_path, m.version, m.commit_time, m.published_at, m.sort_version
+		FROM packages p
+		INNER JOIN modules m
+		ON p.module_path = m.module_path AND p.version = m.version
+		WHERE p.v1_path = (
+			SELECT v1_path
+			FROM packages
+			WHERE path = $1
+			LIMIT 1
+		)
+		AND m.version_type IN ('release', 'prerelease')`
+	return getVersionsPage(ctx, db, versions, pkgPath, order, limit, offset)
+}
+
+// minorVersionExpr is the major and minor version of a version column.
+const minorVersionExpr = `substring(%s from '^v[0-9]+\.[0-9]+')`
+
+// getVersionsPage returns a page of the versions selected by the query
+// versions, which has a single argument arg and selects the module_path,
+// version, commit_time, published_at and sort_version columns.
+func getVersionsPage(ctx context.Context, db *DB, versions, arg string, order VersionsOrder, limit, offset int) (*VersionsPage, error) {
+	// The columns of minorOrder are those of the page table, which is aliased
+	// as p in the outer query.
+	var minorOrder, versionOrder string
+	switch order {
+	case OrderBySemver:
+		minorOrder = "%[1]smax_sort_version DESC, %[1]smodule_path"
+		versionOrder = "v.sort_version DESC"
+	case OrderByDate:
+		minorOrder = "%[1]smax_commit_time DESC, %[1]smax_sort_version DESC, %[1]smodule_path"
+		versionOrder = "v.commit_time DESC, v.sort_version DESC"
+	default:
+		return nil, fmt.Errorf("unknown order %q: %w", order, derrors.InvalidArgument)
+	}
+	if limit <= 0 || offset < 0 {
+		return nil, fmt.Errorf("limit must be positive and offset not negative: %w", derrors.InvalidArgument)
+	}
+	query := `
+		WITH versions AS (` + versions + `),
+		minors AS (
+			SELECT
+				module_path,
+				` + fmt.Sprintf(minorVersionExpr, "version") + ` AS minor,
+				MAX(sort_version) AS max_sort_version,
+				MAX(commit_time) AS max_commit_time
+			FROM versions
+			GROUP BY 1, 2
+		),
+		page AS (
+			SELECT *, COUNT(*) OVER () AS num_minors
+			FROM minors
+			ORDER BY ` + fmt.Sprintf(minorOrder, "") + `
+			LIMIT $2 OFFSET $3
+		)
+		SELECT p.module_path, p.minor, v.version, v.commit_time, v.published_at, p.num_minors
+		FROM page p
+		INNER JOIN versions v
+		ON v.module_path = p.module_path
+		AND ` + fmt.Sprintf(minorVersionExpr, "v.version") + ` = p.minor
+		ORDER BY ` + fmt.Sprintf(minorOrder, "p.") + `, ` + versionOrder
+
+	page := &VersionsPage{}
+	var cur *MinorVersions
+	collect := func(rows *sql.Rows) error {
+		var (
+			modulePath, minor string
+			mi                internal.ModuleInfo
+		)
+		if err := rows.Scan(&modulePath, &minor, &mi.Version, &mi.CommitTime, database.NullIsZero(&mi.PublishedAt), &page.NumMinors); err != nil {
+			return err
+		}
+		mi.ModulePath = modulePath
+		if cur == nil || cur.ModulePath != modulePath || cur.Minor != minor {
+			cur = &MinorVersions{ModulePath: modulePath, Minor: minor}
+			page.Minors = append(page.Minors, cur)
+		}
+		cur.Versions = append(cur.Versions, &mi)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, arg, limit, offset); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/testing/sample"
+)
+
+func TestGetVersionsPage(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	// The versions are committed in the order of this list, which is not
+	// their semver order.
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, m := range []struct{ path, version string }{
+		{"a.com/m", "v1.0.0"},
+		{"a.com/m", "v1.1.0"},
+		{"a.com/m", "v1.0.1"},
+		{"a.com/m/v2", "v2.0.0"},
+		{"a.com/m", "v1.1.1-rc.1"},
+		{"a.com/m", "v0.0.0-20200101000000-123456789abc"},
+	} {
+		mod := sample.Module(m.path, m.version, "foo")
+		mod.CommitTime = start.Add(time.Duration(i) * time.Hour)
+		if err := testDB.InsertModule(ctx, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// minors returns a summary of page: each minor version as
+	// "module_path minor" followed by its versions.
+	minors := func(page *VersionsPage) [][]string {
+		var got [][]string
+		for _, mv := range page.Minors {
+			s := []string{mv.ModulePath + " " + mv.Minor}
+			for _, v := range mv.Versions {
+				s = append(s, v.Version)
+			}
+			got = append(got, s)
+		}
+		return got
+	}
+	for _, test := range []struct {
+		name          string
+		order         VersionsOrder
+		limit, offset int
+		want          [][]string
+	}{
+		{
+			name:  "semver",
+			order: OrderBySemver,
+			limit: 10,
+			want: [][]string{
+				{"a.com/m/v2 v2.0", "v2.0.0"},
+				{"a.com/m v1.1", "v1.1.1-rc.1", "v1.1.0"},
+				{"a.com/m v1.0", "v1.0.1", "v1.0.0"},
+			},
+		},
+		{
+			name:  "date",
+			order: OrderByDate,
+			limit: 10,
+			want: [][]string{
+				{"a.com/m v1.1", "v1.1.1-rc.1", "v1.1.0"},
+				{"a.com/m/v2 v2.0", "v2.0.0"},
+				{"a.com/m v1.0", "v1.0.1", "v1.0.0"},
+			},
+		},
+		{
+			name:   "second page",
+			order:  OrderBySemver,
+			limit:  2,
+			offset: 2,
+			want: [][]string{
+				{"a.com/m v1.0", "v1.0.1", "v1.0.0"},
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for _, get := range []func() (*VersionsPage, error){
+				func() (*VersionsPage, error) {
+					return testDB.GetModuleVersionsPage(ctx, "a.com/m/v2", test.order, test.limit, test.offset)
+				},
+				func() (*VersionsPage, error) {
+					return testDB.GetPackageVersionsPage(ctx, "a.com/m/foo", test.order, test.limit, test.offset)
+				},
+			} {
+				page, err := get()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if page.NumMinors != 3 {
+					t.Errorf("got %d minor versions, want 3", page.NumMinors)
+				}
+				if diff := cmp.Diff(test.want, minors(page)); diff != "" {
+					t.Errorf("mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+
+	if _, err := testDB.GetModuleVersionsPage(ctx, "a.com/m", "size", 10, 0); !errors.Is(err, derrors.InvalidArgument) {
+		t.Errorf("unknown order: got error %v, want InvalidArgument", err)
+	}
+}
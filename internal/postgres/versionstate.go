@@ -13,10 +13,11 @@ import (
 	"time"
 
 	"github.com/lib/pq"
-	"go.opencensus.io/trace"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/database"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
+	"golang.org/x/pkgsite/internal/modstatus"
 	"golang.org/x/pkgsite/internal/version"
 )
 
@@ -25,9 +26,20 @@ import (
 func (db *DB) InsertIndexVersions(ctx context.Context, versions []*internal.IndexVersion) (err error) {
 	defer derrors.Wrap(&err, "InsertIndexVersions(ctx, %v)", versions)
 
-	var vals []interface{}
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		return insertIndexVersions(ctx, tx, versions)
+	})
+}
+
+func insertIndexVersions(ctx context.Context, tx *database.DB, versions []*internal.IndexVersion) error {
+	var (
+		vals        []interface{}
+		paths, vers []string
+	)
 	for _, v := range versions {
 		vals = append(vals, v.Path, v.Version, version.ForSorting(v.Version), v.Timestamp, 0, "", "")
+		paths = append(paths, v.Path)
+		vers = append(vers, v.Version)
 	}
 	cols := []string{"module_path", "version", "sort_version", "index_timestamp", "status", "error", "go_mod_path"}
 	conflictAction := `
@@ -36,47 +48,118 @@ func (db *DB) InsertIndexVersions(ctx context.Context, versions []*internal.Inde
 		DO UPDATE SET
 			index_timestamp=excluded.index_timestamp,
 			next_processed_after=CURRENT_TIMESTAMP`
-	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
-		return tx.BulkInsert(ctx, "module_version_states", cols, vals, conflictAction)
-	})
+	if err := tx.BulkInsert(ctx, "module_version_states", cols, vals, conflictAction); err != nil {
+		return err
+	}
+	// Versions that were processed before the index reported them get their
+	// publish time now.
+	_, err := tx.Exec(ctx, `
+		UPDATE modules m
+		SET published_at = s.index_timestamp
+		FROM module_version_states s
+		WHERE m.published_at IS NULL
+		AND s.module_path = m.module_path
+		AND s.version = m.version
+		AND (s.module_path, s.version) IN (
+			SELECT * FROM unnest($1::text[], $2::text[])
+		)
+		AND s.index_timestamp > $3`,
+		pq.Array(paths), pq.Array(vers), time.Time{})
+	return err
 }
 
 // UpsertModuleVersionState inserts or updates the module_version_state table with
 // the results of a fetch operation for a given module version.
-func (db *DB) UpsertModuleVersionState(ctx context.Context, modulePath, vers, appVersion string, timestamp time.Time, status int, goModPath string, fetchErr error, packageVersionStates []*internal.PackageVersionState) (err error) {
-	defer derrors.Wrap(&err, "UpsertModuleVersionState(ctx, %q, %q, %q, %s, %d, %q, %v",
-		modulePath, vers, appVersion, timestamp, status, goModPath, fetchErr)
-	ctx, span := trace.StartSpan(ctx, "UpsertModuleVersionState")
-	defer span.End()
-
+func (db *DB) UpsertModuleVersionState(ctx context.Context, mvs *internal.ModuleVersionStateForUpsert) (err error) {
+	defer derrors.Wrap(&err, "UpsertModuleVersionState(ctx, %q, %q, %q, %s, %d, %q, %q, %q, %q, %v",
+		mvs.ModulePath, mvs.Version, mvs.AppVersion, mvs.Timestamp, mvs.Status, mvs.GoModPath,
+		mvs.ZipHash, mvs.ChecksumResult, mvs.Proxy, mvs.FetchErr)
+	ctx, span := dtrace.StartSpan(ctx, "UpsertModuleVersionState", dtrace.ModulePath(mvs.ModulePath), dtrace.Version(mvs.Version))
+	ctx = database.WithQueryName(ctx, "UpsertModuleVersionState")
+	defer dtrace.End(span, &err)
+
+	if !modstatus.Code(mvs.Status).IsResult() {
+		return fmt.Errorf("status %d is not a result of processing: %w", mvs.Status, derrors.InvalidArgument)
+	}
 	var numPackages *int
-	if !(status >= http.StatusBadRequest && status <= http.StatusNotFound) {
+	if !(mvs.Status >= http.StatusBadRequest && mvs.Status <= http.StatusNotFound) {
 		// If a module was fetched a 40x error in this range, we won't know how
 		// many packages it has.
-		n := len(packageVersionStates)
+		n := len(mvs.PackageVersionStates)
 		numPackages = &n
 	}
 
 	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
-		if err := upsertModuleVersionState(ctx, tx, modulePath, vers, appVersion, numPackages, timestamp, status, goModPath, fetchErr); err != nil {
+		if err := upsertModuleVersionState(ctx, tx, numPackages, mvs); err != nil {
 			return err
 		}
-		if len(packageVersionStates) == 0 {
+		if len(mvs.PackageVersionStates) == 0 {
 			return nil
 		}
-		return upsertPackageVersionStates(ctx, tx, packageVersionStates)
+		return upsertPackageVersionStates(ctx, tx, mvs.PackageVersionStates)
 	})
 }
 
-func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath, vers, appVersion string, numPackages *int, timestamp time.Time, status int, goModPath string, fetchErr error) (err error) {
-	defer derrors.Wrap(&err, "upsertModuleVersionState(ctx, %q, %q, %q, %s, %d, %q, %v",
-		modulePath, vers, appVersion, timestamp, status, goModPath, fetchErr)
-	ctx, span := trace.StartSpan(ctx, "upsertModuleVersionState")
+// BumpModuleVersionState records that the given module version was processed
+// again without any change in its result, by updating its processing
+// timestamps and leaving the rest of its state alone.
+func (db *DB) BumpModuleVersionState(ctx context.Context, modulePath, version string) (err error) {
+	defer derrors.Wrap(&err, "BumpModuleVersionState(ctx, %q, %q)", modulePath, version)
+
+	res, err := db.db.Exec(ctx, `
+		UPDATE module_version_states
+		SET
+			last_processed_at=CURRENT_TIMESTAMP,
+			next_processed_after=CASE
+				WHEN last_processed_at IS NULL THEN
+					CURRENT_TIMESTAMP + INTERVAL '1 minute'
+				WHEN 2*(next_processed_after - last_processed_at) < INTERVAL '1 hour' THEN
+					CURRENT_TIMESTAMP + 2*(next_processed_after - last_processed_at)
+				ELSE
+					CURRENT_TIMESTAMP + INTERVAL '1 hour'
+				END
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, version)
+	if err != nil {
+		return err
+	}
+	return notFoundIfNoRows(res)
+}
+
+// MarkModuleVersionInterrupted records that a fetch of the given module
+// version was interrupted before it could record its result, such as by the
+// worker shutting down, so that it is requeued the next time a request to
+// /requeue is made instead of waiting out its retry backoff.
+func (db *DB) MarkModuleVersionInterrupted(ctx context.Context, modulePath, version string) (err error) {
+	defer derrors.Wrap(&err, "MarkModuleVersionInterrupted(ctx, %q, %q)", modulePath, version)
+
+	res, err := db.db.Exec(ctx, `
+		UPDATE module_version_states
+		SET next_processed_after = CURRENT_TIMESTAMP
+		WHERE module_path = $1 AND version = $2`,
+		modulePath, version)
+	if err != nil {
+		return err
+	}
+	return notFoundIfNoRows(res)
+}
+
+// deadLetterThreshold is the number of consecutive failed attempts (with a
+// status of 500 or above) after which a module version is moved to the
+// dead-letter queue. It is a variable for testing.
+var deadLetterThreshold = 10
+
+// upsertModuleVersionState upserts the module version state of mvs, except
+// for its package version states.
+func upsertModuleVersionState(ctx context.Context, db *database.DB, numPackages *int, mvs *internal.ModuleVersionStateForUpsert) (err error) {
+	defer derrors.Wrap(&err, "upsertModuleVersionState(ctx, %q, %q)", mvs.ModulePath, mvs.Version)
+	ctx, span := dtrace.StartSpan(ctx, "upsertModuleVersionState")
+	ctx = database.WithQueryName(ctx, "upsertModuleVersionState")
 	defer span.End()
 
 	var sqlErrorMsg string
-	if fetchErr != nil {
-		sqlErrorMsg = fetchErr.Error()
+	if mvs.FetchErr != nil {
+		sqlErrorMsg = mvs.FetchErr.Error()
 	}
 
 	result, err := db.Exec(ctx, `
@@ -89,8 +172,13 @@ func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath,
 				status,
 				go_mod_path,
 				error,
-				num_packages)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+				num_packages,
+				zip_hash,
+				checksum_result,
+				proxy,
+				consecutive_failures)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $11, $12, $13,
+				CASE WHEN $6 >= 500 THEN 1 ELSE 0 END)
 			ON CONFLICT (module_path, version)
 			DO UPDATE
 			SET
@@ -99,7 +187,14 @@ func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath,
 				go_mod_path=excluded.go_mod_path,
 				error=excluded.error,
 				num_packages=excluded.num_packages,
+				zip_hash=excluded.zip_hash,
+				checksum_result=excluded.checksum_result,
+				proxy=excluded.proxy,
 				try_count=mvs.try_count+1,
+				consecutive_failures=CASE
+					WHEN excluded.status < 500 THEN 0
+					ELSE mvs.consecutive_failures+1
+					END,
 				last_processed_at=CURRENT_TIMESTAMP,
 			    -- back off exponentially until 1 hour, then at constant 1-hour intervals
 				next_processed_after=CASE
@@ -109,9 +204,24 @@ func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath,
 						CURRENT_TIMESTAMP + 2*(mvs.next_processed_after - mvs.last_processed_at)
 					ELSE
 						CURRENT_TIMESTAMP + INTERVAL '1 hour'
+					END,
+				-- move versions that keep failing to the dead-letter queue
+				dead_lettered_at=CASE
+					WHEN excluded.status < 500 THEN NULL
+					WHEN mvs.dead_lettered_at IS NOT NULL THEN mvs.dead_lettered_at
+					WHEN mvs.consecutive_failures+1 >= $10 THEN CURRENT_TIMESTAMP
+					ELSE NULL
+					END,
+				dead_letter_reason=CASE
+					WHEN excluded.status < 500 THEN ''
+					WHEN mvs.dead_lettered_at IS NOT NULL THEN mvs.dead_letter_reason
+					WHEN mvs.consecutive_failures+1 >= $10 THEN
+						'failed ' || (mvs.consecutive_failures+1) || ' times in a row with status ' || excluded.status || ': ' || excluded.error
+					ELSE ''
 					END;`,
-		modulePath, vers, version.ForSorting(vers),
-		appVersion, timestamp, status, goModPath, sqlErrorMsg, numPackages)
+		mvs.ModulePath, mvs.Version, version.ForSorting(mvs.Version),
+		mvs.AppVersion, mvs.Timestamp, mvs.Status, mvs.GoModPath, sqlErrorMsg, numPackages, deadLetterThreshold,
+		mvs.ZipHash, mvs.ChecksumResult, mvs.Proxy)
 	if err != nil {
 		return err
 	}
@@ -127,7 +237,8 @@ func upsertModuleVersionState(ctx context.Context, db *database.DB, modulePath,
 
 func upsertPackageVersionStates(ctx context.Context, db *database.DB, packageVersionStates []*internal.PackageVersionState) (err error) {
 	defer derrors.Wrap(&err, "upsertPackageVersionStates")
-	ctx, span := trace.StartSpan(ctx, "upsertPackageVersionStates")
+	ctx, span := dtrace.StartSpan(ctx, "upsertPackageVersionStates")
+	ctx = database.WithQueryName(ctx, "upsertPackageVersionStates")
 	defer span.End()
 
 	sort.Slice(packageVersionStates, func(i, j int) bool {
@@ -190,51 +301,22 @@ const moduleVersionStateColumns = `
 			next_processed_after,
 			app_version,
 			go_mod_path,
-			num_packages`
-
-// scanModuleVersionState constructs an *internal.ModuleModuleVersionState from the given
-// scanner. It expects columns to be in the order of moduleVersionStateColumns.
-func scanModuleVersionState(scan func(dest ...interface{}) error) (*internal.ModuleVersionState, error) {
-	var (
-		v               internal.ModuleVersionState
-		lastProcessedAt pq.NullTime
-		numPackages     sql.NullInt64
-	)
-	if err := scan(&v.ModulePath, &v.Version, &v.IndexTimestamp, &v.CreatedAt, &v.Status, &v.Error,
-		&v.TryCount, &v.LastProcessedAt, &v.NextProcessedAfter, &v.AppVersion, &v.GoModPath, &numPackages); err != nil {
-		return nil, err
-	}
-	if lastProcessedAt.Valid {
-		lp := lastProcessedAt.Time
-		v.LastProcessedAt = &lp
-	}
-	if numPackages.Valid {
-		n := int(numPackages.Int64)
-		v.NumPackages = &n
-	}
-	return &v, nil
-}
+			num_packages,
+			dead_lettered_at,
+			dead_letter_reason,
+			zip_hash,
+			checksum_result,
+			proxy`
 
 // queryModuleVersionStates executes a query for ModuleModuleVersionState rows. It expects the
 // given queryFormat be a format specifier with exactly one argument: a %s verb
 // for the query columns.
 func (db *DB) queryModuleVersionStates(ctx context.Context, queryFormat string, args ...interface{}) ([]*internal.ModuleVersionState, error) {
 	query := fmt.Sprintf(queryFormat, moduleVersionStateColumns)
-	rows, err := db.db.Query(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
 	var versions []*internal.ModuleVersionState
-	for rows.Next() {
-		v, err := scanModuleVersionState(rows.Scan)
-		if err != nil {
-			return nil, fmt.Errorf("rows.Scan(): %v", err)
-		}
-		versions = append(versions, v)
+	if err := db.db.CollectStructs(ctx, &versions, query, args...); err != nil {
+		return nil, err
 	}
-
 	return versions, nil
 }
 
@@ -265,6 +347,20 @@ func (db *DB) GetRecentVersions(ctx context.Context, limit int) (_ []*internal.M
 	return db.queryModuleVersionStates(ctx, queryFormat, limit)
 }
 
+// GetModuleVersionStatesForModule returns the current state of every version
+// of modulePath, highest version first.
+func (db *DB) GetModuleVersionStatesForModule(ctx context.Context, modulePath string) (_ []*internal.ModuleVersionState, err error) {
+	defer derrors.Wrap(&err, "GetModuleVersionStatesForModule(ctx, %q)", modulePath)
+
+	queryFormat := `
+		SELECT %s
+		FROM
+			module_version_states
+		WHERE module_path = $1
+		ORDER BY sort_version DESC`
+	return db.queryModuleVersionStates(ctx, queryFormat, modulePath)
+}
+
 // GetModuleVersionState returns the current module version state for
 // modulePath and version.
 func (db *DB) GetModuleVersionState(ctx context.Context, modulePath, version string) (_ *internal.ModuleVersionState, err error) {
@@ -278,15 +374,14 @@ func (db *DB) GetModuleVersionState(ctx context.Context, modulePath, version str
 			module_path = $1
 			AND version = $2;`, moduleVersionStateColumns)
 
-	row := db.db.QueryRow(ctx, query, modulePath, version)
-	v, err := scanModuleVersionState(row.Scan)
-	switch err {
+	var v internal.ModuleVersionState
+	switch err := db.db.QueryStruct(ctx, &v, query, modulePath, version); err {
 	case nil:
-		return v, nil
+		return &v, nil
 	case sql.ErrNoRows:
 		return nil, derrors.NotFound
 	default:
-		return nil, fmt.Errorf("row.Scan(): %v", err)
+		return nil, err
 	}
 }
 
@@ -309,16 +404,7 @@ func (db *DB) GetPackageVersionStatesForModule(ctx context.Context, modulePath,
 			AND version = $2;`
 
 	var states []*internal.PackageVersionState
-	collect := func(rows *sql.Rows) error {
-		var s internal.PackageVersionState
-		if err := rows.Scan(&s.PackagePath, &s.ModulePath, &s.Version,
-			&s.Status, &s.Error); err != nil {
-			return fmt.Errorf("rows.Scan(): %v", err)
-		}
-		states = append(states, &s)
-		return nil
-	}
-	if err := db.db.RunQuery(ctx, query, collect, modulePath, version); err != nil {
+	if err := db.db.CollectStructs(ctx, &states, query, modulePath, version); err != nil {
 		return nil, err
 	}
 	return states, nil
@@ -344,16 +430,13 @@ func (db *DB) GetPackageVersionState(ctx context.Context, pkgPath, modulePath, v
 			AND version = $3;`
 
 	var pvs internal.PackageVersionState
-	err = db.db.QueryRow(ctx, query, pkgPath, modulePath, version).Scan(
-		&pvs.PackagePath, &pvs.ModulePath, &pvs.Version,
-		&pvs.Status, &pvs.Error)
-	switch err {
+	switch err := db.db.QueryStruct(ctx, &pvs, query, pkgPath, modulePath, version); err {
 	case nil:
 		return &pvs, nil
 	case sql.ErrNoRows:
 		return nil, derrors.NotFound
 	default:
-		return nil, fmt.Errorf("row.Scan(): %v", err)
+		return nil, err
 	}
 }
 
@@ -402,3 +485,133 @@ func (db *DB) GetVersionStats(ctx context.Context) (_ *VersionStats, err error)
 	}
 	return stats, nil
 }
+
+// A FetchAttempt is the result of a single attempt by the worker to fetch a
+// module version.
+type FetchAttempt struct {
+	ModulePath  string
+	Version     string
+	AppVersion  string
+	Status      int
+	Error       string
+	Duration    time.Duration
+	AttemptedAt time.Time
+}
+
+// InsertFetchAttempt records the result of an attempt to fetch
+// modulePath@version in the module_version_fetch_attempts table. The module
+// version must already be present in module_version_states.
+func (db *DB) InsertFetchAttempt(ctx context.Context, modulePath, version, appVersion string, status int, fetchErr error, duration time.Duration) (err error) {
+	defer derrors.Wrap(&err, "InsertFetchAttempt(ctx, %q, %q, %q, %d, %v, %s)",
+		modulePath, version, appVersion, status, fetchErr, duration)
+
+	var errMsg string
+	if fetchErr != nil {
+		errMsg = fetchErr.Error()
+	}
+	_, err = db.db.Exec(ctx, `
+		INSERT INTO module_version_fetch_attempts
+			(module_path, version, app_version, status, error, duration_seconds)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		modulePath, version, appVersion, status, errMsg, duration.Seconds())
+	return err
+}
+
+// fetchAttemptRetention is how long attempts are kept in the
+// module_version_fetch_attempts table.
+const fetchAttemptRetention = 30 * 24 * time.Hour
+
+// DeleteOldFetchAttempts deletes the fetch attempts older than
+// fetchAttemptRetention, and returns the number it deleted.
+func (db *DB) DeleteOldFetchAttempts(ctx context.Context) (_ int64, err error) {
+	defer derrors.Wrap(&err, "DeleteOldFetchAttempts(ctx)")
+
+	res, err := db.db.Exec(ctx, `DELETE FROM module_version_fetch_attempts WHERE attempted_at < $1`,
+		time.Now().Add(-fetchAttemptRetention))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// GetFetchAttemptsForModule returns the most recent attempts to fetch any
+// version of modulePath, most recent first.
+func (db *DB) GetFetchAttemptsForModule(ctx context.Context, modulePath string, limit int) (_ []*FetchAttempt, err error) {
+	defer derrors.Wrap(&err, "GetFetchAttemptsForModule(ctx, %q, %d)", modulePath, limit)
+
+	query := `
+		SELECT
+			module_path,
+			version,
+			app_version,
+			status,
+			error,
+			duration_seconds,
+			attempted_at
+		FROM
+			module_version_fetch_attempts
+		WHERE module_path = $1
+		ORDER BY attempted_at DESC, id DESC
+		LIMIT $2`
+
+	var attempts []*FetchAttempt
+	collect := func(rows *sql.Rows) error {
+		var (
+			a       FetchAttempt
+			seconds float64
+		)
+		if err := rows.Scan(&a.ModulePath, &a.Version, &a.AppVersion, &a.Status,
+			&a.Error, &seconds, &a.AttemptedAt); err != nil {
+			return fmt.Errorf("rows.Scan(): %v", err)
+		}
+		a.Duration = time.Duration(seconds * float64(time.Second))
+		attempts = append(attempts, &a)
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, query, collect, modulePath, limit); err != nil {
+		return nil, err
+	}
+	return attempts, nil
+}
+
+// GetDeadLetteredVersions returns the module versions in the dead-letter
+// queue, most recently dead-lettered first.
+func (db *DB) GetDeadLetteredVersions(ctx context.Context, limit int) (_ []*internal.ModuleVersionState, err error) {
+	defer derrors.Wrap(&err, "GetDeadLetteredVersions(ctx, %d)", limit)
+
+	queryFormat := `
+		SELECT %s
+		FROM
+			module_version_states
+		WHERE dead_lettered_at IS NOT NULL
+		ORDER BY dead_lettered_at DESC
+		LIMIT $1`
+	return db.queryModuleVersionStates(ctx, queryFormat, limit)
+}
+
+// ResurrectDeadLetteredVersion removes modulePath@version from the
+// dead-letter queue and resets its retry state, so that it will be scheduled
+// the next time a request to /requeue is made. It returns derrors.NotFound if
+// the version is not in the dead-letter queue.
+func (db *DB) ResurrectDeadLetteredVersion(ctx context.Context, modulePath, version string) (err error) {
+	defer derrors.Wrap(&err, "ResurrectDeadLetteredVersion(ctx, %q, %q)", modulePath, version)
+
+	res, err := db.db.Exec(ctx, `
+		UPDATE module_version_states
+		SET
+			dead_lettered_at = NULL,
+			dead_letter_reason = '',
+			try_count = 0,
+			consecutive_failures = 0,
+			last_processed_at = NULL,
+			next_processed_after = CURRENT_TIMESTAMP
+		WHERE
+			module_path = $1
+			AND version = $2
+			AND dead_lettered_at IS NOT NULL`,
+		modulePath, version)
+	if err != nil {
+		return err
+	}
+	return notFoundIfNoRows(res)
+}
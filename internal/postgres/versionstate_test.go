@@ -13,6 +13,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/testing/sample"
 )
 
@@ -74,6 +75,7 @@ func TestModuleVersionState(t *testing.T) {
 		statusCode      = 500
 		fetchErr        = errors.New("bad request")
 		goModPath       = "goModPath"
+		proxyURL        = "https://proxy.golang.org"
 		pkgVersionState = &internal.PackageVersionState{
 			ModulePath:  "foo.com/bar",
 			PackagePath: "foo.com/bar/foo",
@@ -81,7 +83,16 @@ func TestModuleVersionState(t *testing.T) {
 			Status:      500,
 		}
 	)
-	if err := testDB.UpsertModuleVersionState(ctx, fooVersion.Path, fooVersion.Version, "", fooVersion.Timestamp, statusCode, goModPath, fetchErr, []*internal.PackageVersionState{pkgVersionState}); err != nil {
+	if err := testDB.UpsertModuleVersionState(ctx, &internal.ModuleVersionStateForUpsert{
+		ModulePath:           fooVersion.Path,
+		Version:              fooVersion.Version,
+		Timestamp:            fooVersion.Timestamp,
+		Status:               statusCode,
+		GoModPath:            goModPath,
+		Proxy:                proxyURL,
+		FetchErr:             fetchErr,
+		PackageVersionStates: []*internal.PackageVersionState{pkgVersionState},
+	}); err != nil {
 		t.Fatal(err)
 	}
 	errString := fetchErr.Error()
@@ -95,6 +106,7 @@ func TestModuleVersionState(t *testing.T) {
 		Error:          errString,
 		Status:         statusCode,
 		NumPackages:    &numPackages,
+		Proxy:          proxyURL,
 	}
 	gotFooState, err := testDB.GetModuleVersionState(ctx, wantFooState.ModulePath, wantFooState.Version)
 	if err != nil {
@@ -136,3 +148,201 @@ func TestModuleVersionState(t *testing.T) {
 		t.Errorf("testDB.GetVersionStats(ctx) mismatch (-want +got):\n%s", diff)
 	}
 }
+
+func TestFetchAttempts(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const modulePath = "example.com/mod"
+	now := sample.NowTruncated()
+	var versions []*internal.IndexVersion
+	for _, v := range []string{"v1.0.0", "v1.1.0"} {
+		versions = append(versions, &internal.IndexVersion{Path: modulePath, Version: v, Timestamp: now})
+	}
+	if err := testDB.InsertIndexVersions(ctx, versions); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range []struct {
+		version string
+		status  int
+	}{
+		{"v1.0.0", 500},
+		{"v1.0.0", 200},
+		{"v1.1.0", 404},
+	} {
+		var fetchErr error
+		if a.status != 200 {
+			fetchErr = errors.New("failed")
+		}
+		if err := testDB.InsertFetchAttempt(ctx, modulePath, a.version, "app", a.status, fetchErr, 2*time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	states, err := testDB.GetModuleVersionStatesForModule(ctx, modulePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotVersions []string
+	for _, s := range states {
+		gotVersions = append(gotVersions, s.Version)
+	}
+	if diff := cmp.Diff([]string{"v1.1.0", "v1.0.0"}, gotVersions); diff != "" {
+		t.Errorf("GetModuleVersionStatesForModule mismatch (-want +got):\n%s", diff)
+	}
+
+	attempts, err := testDB.GetFetchAttemptsForModule(ctx, modulePath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*FetchAttempt{
+		{ModulePath: modulePath, Version: "v1.1.0", AppVersion: "app", Status: 404, Error: "failed", Duration: 2 * time.Second},
+		{ModulePath: modulePath, Version: "v1.0.0", AppVersion: "app", Status: 200, Duration: 2 * time.Second},
+		{ModulePath: modulePath, Version: "v1.0.0", AppVersion: "app", Status: 500, Error: "failed", Duration: 2 * time.Second},
+	}
+	if diff := cmp.Diff(want, attempts, cmpopts.IgnoreFields(FetchAttempt{}, "AttemptedAt")); diff != "" {
+		t.Errorf("GetFetchAttemptsForModule mismatch (-want +got):\n%s", diff)
+	}
+
+	// Only attempts older than the retention period are deleted.
+	if _, err := testDB.db.Exec(ctx, `
+		UPDATE module_version_fetch_attempts SET attempted_at = $1 WHERE status = 500`,
+		time.Now().Add(-fetchAttemptRetention-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := testDB.DeleteOldFetchAttempts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteOldFetchAttempts deleted %d attempts, want 1", n)
+	}
+	attempts, err = testDB.GetFetchAttemptsForModule(ctx, modulePath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want[:2], attempts, cmpopts.IgnoreFields(FetchAttempt{}, "AttemptedAt")); diff != "" {
+		t.Errorf("GetFetchAttemptsForModule after deletion mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer func(n int) { deadLetterThreshold = n }(deadLetterThreshold)
+	deadLetterThreshold = 2
+
+	const modulePath, version = "example.com/failing", "v1.0.0"
+	now := sample.NowTruncated()
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: modulePath, Version: version, Timestamp: now}}); err != nil {
+		t.Fatal(err)
+	}
+	fail := func() {
+		t.Helper()
+		if err := testDB.UpsertModuleVersionState(ctx, &internal.ModuleVersionStateForUpsert{
+			ModulePath: modulePath,
+			Version:    version,
+			AppVersion: "app",
+			Timestamp:  now,
+			Status:     500,
+			FetchErr:   errors.New("boom"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		// Make the version eligible for requeuing right away.
+		if _, err := testDB.db.Exec(ctx, `UPDATE module_version_states SET next_processed_after = CURRENT_TIMESTAMP - INTERVAL '1 minute'`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	isRequeued := func() bool {
+		t.Helper()
+		next, err := testDB.GetNextModulesToFetch(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(next) == 1
+	}
+
+	fail()
+	if !isRequeued() {
+		t.Fatal("version was not requeued after the first failure")
+	}
+	// A status below 500 resets the count of consecutive failures.
+	if err := testDB.UpsertModuleVersionState(ctx, &internal.ModuleVersionStateForUpsert{
+		ModulePath: modulePath,
+		Version:    version,
+		AppVersion: "app",
+		Timestamp:  now,
+		Status:     404,
+		FetchErr:   errors.New("not found"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fail()
+	if !isRequeued() {
+		t.Fatal("version was not requeued after a failure that followed a 404")
+	}
+	fail()
+	if isRequeued() {
+		t.Fatal("dead-lettered version was requeued")
+	}
+	dl, err := testDB.GetDeadLetteredVersions(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dl) != 1 || dl[0].DeadLetteredAt == nil || dl[0].DeadLetterReason != "failed 2 times in a row with status 500: boom" {
+		t.Fatalf("GetDeadLetteredVersions: got %+v, want one version with a reason", dl)
+	}
+
+	if err := testDB.ResurrectDeadLetteredVersion(ctx, modulePath, version); err != nil {
+		t.Fatal(err)
+	}
+	if !isRequeued() {
+		t.Fatal("resurrected version was not requeued")
+	}
+	if err := testDB.ResurrectDeadLetteredVersion(ctx, modulePath, version); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("resurrecting a live version: got %v, want NotFound", err)
+	}
+}
+
+func TestMarkModuleVersionInterrupted(t *testing.T) {
+	defer ResetTestDB(testDB, t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	const modulePath, version = "example.com/interrupted", "v1.0.0"
+	now := sample.NowTruncated()
+	if err := testDB.InsertIndexVersions(ctx, []*internal.IndexVersion{{Path: modulePath, Version: version, Timestamp: now}}); err != nil {
+		t.Fatal(err)
+	}
+	// The version was retried recently, so it is backing off.
+	if _, err := testDB.db.Exec(ctx, `UPDATE module_version_states SET status = 500, next_processed_after = CURRENT_TIMESTAMP + INTERVAL '1 hour'`); err != nil {
+		t.Fatal(err)
+	}
+	next, err := testDB.GetNextModulesToFetch(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 0 {
+		t.Fatalf("got %d versions to fetch before the interruption, want 0", len(next))
+	}
+
+	if err := testDB.MarkModuleVersionInterrupted(ctx, modulePath, version); err != nil {
+		t.Fatal(err)
+	}
+	// next_processed_after must be in the past for the version to be requeued.
+	time.Sleep(10 * time.Millisecond)
+	next, err = testDB.GetNextModulesToFetch(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 1 {
+		t.Fatalf("got %d versions to fetch after the interruption, want 1", len(next))
+	}
+	if err := testDB.MarkModuleVersionInterrupted(ctx, "example.com/unknown", version); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("unknown version: got %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/vuln"
+)
+
+// UpsertVulns replaces the advisories for modulePath with entries, and records
+// which of the versions of the module in the database they apply to.
+func (db *DB) UpsertVulns(ctx context.Context, modulePath string, entries []*vuln.Entry) (err error) {
+	defer derrors.Wrap(&err, "DB.UpsertVulns(ctx, %q, [%d entries])", modulePath, len(entries))
+
+	return db.db.Transact(ctx, sql.LevelDefault, func(tx *database.DB) error {
+		// Deleting the advisories also deletes their affected versions.
+		if _, err := tx.Exec(ctx, `DELETE FROM vulns WHERE module_path = $1`, modulePath); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		var values []interface{}
+		for _, e := range entries {
+			b, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			values = append(values, e.ID, modulePath, e.Modified, b)
+		}
+		if err := tx.BulkInsert(ctx, "vulns", []string{"id", "module_path", "modified", "entry"}, values, ""); err != nil {
+			return err
+		}
+		var versions []string
+		collect := func(rows *sql.Rows) error {
+			var v string
+			if err := rows.Scan(&v); err != nil {
+				return err
+			}
+			versions = append(versions, v)
+			return nil
+		}
+		if err := tx.RunQuery(ctx, `SELECT version FROM modules WHERE module_path = $1`, collect, modulePath); err != nil {
+			return err
+		}
+		if err := insertVulnAffectedVersions(ctx, tx, modulePath, versions, entries); err != nil {
+			return err
+		}
+		return updateSearchDocumentsNumVulns(ctx, tx, modulePath)
+	})
+}
+
+// updateSearchDocumentsNumVulns updates the counts of advisories in the search
+// documents of modulePath, so that search filters and annotations reflect the
+// latest sync.
+func updateSearchDocumentsNumVulns(ctx context.Context, tx *database.DB, modulePath string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE search_documents sd
+		SET num_vulns = (
+			SELECT count(*) FROM vuln_affected_versions a
+			WHERE a.module_path = sd.module_path
+			AND a.version = sd.version
+		)
+		WHERE sd.module_path = $1`, modulePath)
+	return err
+}
+
+// insertVulnMatches records which of the advisories in the database apply to
+// modulePath at version. It is called when the module version is inserted.
+func insertVulnMatches(ctx context.Context, tx *database.DB, modulePath, version string) (err error) {
+	defer derrors.Wrap(&err, "insertVulnMatches(ctx, tx, %q, %q)", modulePath, version)
+
+	entries, err := getVulns(ctx, tx, `SELECT entry FROM vulns WHERE module_path = $1`, modulePath)
+	if err != nil {
+		return err
+	}
+	return insertVulnAffectedVersions(ctx, tx, modulePath, []string{version}, entries)
+}
+
+// insertVulnAffectedVersions records which of entries apply to each of the
+// versions of modulePath.
+func insertVulnAffectedVersions(ctx context.Context, tx *database.DB, modulePath string, versions []string, entries []*vuln.Entry) error {
+	var values []interface{}
+	for _, e := range entries {
+		for _, v := range versions {
+			if e.AffectsVersion(modulePath, v) {
+				values = append(values, e.ID, modulePath, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return tx.BulkInsert(ctx, "vuln_affected_versions", []string{"vuln_id", "module_path", "version"}, values, database.OnConflictDoNothing)
+}
+
+// GetVulnModifiedTimes returns the module paths that have advisories, with the
+// time their advisories were last modified.
+func (db *DB) GetVulnModifiedTimes(ctx context.Context) (_ map[string]time.Time, err error) {
+	defer derrors.Wrap(&err, "DB.GetVulnModifiedTimes(ctx)")
+
+	times := map[string]time.Time{}
+	collect := func(rows *sql.Rows) error {
+		var (
+			path string
+			t    time.Time
+		)
+		if err := rows.Scan(&path, &t); err != nil {
+			return err
+		}
+		times[path] = t
+		return nil
+	}
+	if err := db.db.RunQuery(ctx, `SELECT module_path, max(modified) FROM vulns GROUP BY module_path`, collect); err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+// GetVulnsForModule returns the advisories for modulePath, ordered by ID.
+func (db *DB) GetVulnsForModule(ctx context.Context, modulePath string) (_ []*vuln.Entry, err error) {
+	defer derrors.Wrap(&err, "DB.GetVulnsForModule(ctx, %q)", modulePath)
+	return getVulns(ctx, db.db, `SELECT entry FROM vulns WHERE module_path = $1 ORDER BY id`, modulePath)
+}
+
+// GetVulnsForVersion returns the advisories that apply to modulePath at
+// version, ordered by ID.
+func (db *DB) GetVulnsForVersion(ctx context.Context, modulePath, version string) (_ []*vuln.Entry, err error) {
+	defer derrors.Wrap(&err, "DB.GetVulnsForVersion(ctx, %q, %q)", modulePath, version)
+
+	query := `
+		SELECT v.entry
+		FROM vulns v
+		INNER JOIN vuln_affected_versions a
+		ON v.id = a.vuln_id AND v.module_path = a.module_path
+		WHERE a.module_path = $1 AND a.version = $2
+		ORDER BY v.id`
+	return getVulns(ctx, db.db, query, modulePath, version)
+}
+
+// getVulns returns the advisories whose JSON entries are selected by query.
+func getVulns(ctx context.Context, db *database.DB, query string, args ...interface{}) ([]*vuln.Entry, error) {
+	var entries []*vuln.Entry
+	collect := func(rows *sql.Rows) error {
+		var b []byte
+		if err := rows.Scan(&b); err != nil {
+			return err
+		}
+		var e vuln.Entry
+		if err := json.Unmarshal(b, &e); err != nil {
+			return err
+		}
+		entries = append(entries, &e)
+		return nil
+	}
+	if err := db.RunQuery(ctx, query, collect, args...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/testing/sample"
+	"golang.org/x/pkgsite/internal/vuln"
+)
+
+func TestVulns(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer ResetTestDB(testDB, t)
+
+	const modulePath = "example.com/mod"
+	for _, v := range []string{"v1.0.0", "v1.2.0"} {
+		if err := testDB.InsertModule(ctx, sample.Module(modulePath, v, "pkg")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	modified := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(id, fixed string) *vuln.Entry {
+		return &vuln.Entry{
+			ID:       id,
+			Modified: modified,
+			Details:  id + " details",
+			Affected: []vuln.Affected{{
+				Package: vuln.Package{Name: modulePath, Ecosystem: "Go"},
+				Ranges: []vuln.Range{{
+					Type:   vuln.RangeTypeSemver,
+					Events: []vuln.RangeEvent{{Introduced: "0"}, {Fixed: fixed}},
+				}},
+			}},
+		}
+	}
+	// GO-2020-0001 affects v1.0.0 and v1.2.0, GO-2020-0002 only v1.0.0.
+	e1, e2 := entry("GO-2020-0001", "1.3.0"), entry("GO-2020-0002", "1.1.0")
+	if err := testDB.UpsertVulns(ctx, modulePath, []*vuln.Entry{e2, e1}); err != nil {
+		t.Fatal(err)
+	}
+	// A version inserted after the advisories is matched on insert.
+	if err := testDB.InsertModule(ctx, sample.Module(modulePath, "v1.2.5", "pkg")); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(version string, want []*vuln.Entry) {
+		t.Helper()
+		got, err := testDB.GetVulnsForVersion(ctx, modulePath, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("GetVulnsForVersion(%q) mismatch (-want +got):\n%s", version, diff)
+		}
+	}
+	check("v1.0.0", []*vuln.Entry{e1, e2})
+	check("v1.2.0", []*vuln.Entry{e1})
+	check("v1.2.5", []*vuln.Entry{e1})
+	checkNumVulns := func(want int) {
+		t.Helper()
+		var got int
+		err := testDB.db.QueryRow(ctx, `
+			SELECT num_vulns FROM search_documents WHERE package_path = $1`,
+			modulePath+"/pkg").Scan(&got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("num_vulns = %d, want %d", got, want)
+		}
+	}
+	checkNumVulns(1)
+
+	got, err := testDB.GetVulnsForModule(ctx, modulePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]*vuln.Entry{e1, e2}, got); diff != "" {
+		t.Errorf("GetVulnsForModule mismatch (-want +got):\n%s", diff)
+	}
+	times, err := testDB.GetVulnModifiedTimes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := times[modulePath]; !got.Equal(modified) {
+		t.Errorf("GetVulnModifiedTimes()[%q] = %s, want %s", modulePath, got, modified)
+	}
+
+	// Syncing again replaces the advisories and their matches.
+	if err := testDB.UpsertVulns(ctx, modulePath, []*vuln.Entry{e2}); err != nil {
+		t.Fatal(err)
+	}
+	check("v1.0.0", []*vuln.Entry{e2})
+	check("v1.2.0", nil)
+	checkNumVulns(0)
+}
@@ -20,48 +20,143 @@ import (
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
+	"go.opentelemetry.io/otel/label"
 	"golang.org/x/mod/module"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blob"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
+	"golang.org/x/pkgsite/internal/log"
 )
 
-// A Client is used by the fetch service to communicate with a module
-// proxy. It handles all methods defined by go help goproxy.
+// A Client is used by the fetch service to communicate with one or more
+// module proxies. It handles all methods defined by go help goproxy.
 type Client struct {
-	// URL of the module proxy web server
-	url string
+	// URLs of the module proxy web servers, in the order they are tried.
+	urls []string
 
 	// client used for HTTP requests. It is mutable for testing purposes.
 	httpClient *http.Client
+
+	// timeouts holds the timeout for requests to each endpoint, if any.
+	timeouts map[string]time.Duration
+
+	// latencies tracks the latency of requests to the endpoints whose
+	// requests are hedged.
+	latencies map[string]*latencyTracker
+
+	// cache holds responses on disk, if it is non-nil.
+	cache *diskCache
+
+	// private describes the proxy for private modules, if it is non-nil.
+	private *privateProxy
+
+	// zips stores the zips of modules, if it is non-nil.
+	zips blob.Bucket
 }
 
+// The endpoints of the proxy protocol, as used in SetTimeouts and metrics.
+const (
+	infoEndpoint = "info"
+	modEndpoint  = "mod"
+	zipEndpoint  = "zip"
+	listEndpoint = "list"
+)
+
 // A VersionInfo contains metadata about a given version of a module.
 type VersionInfo struct {
 	Version string
 	Time    time.Time
+	// Proxy is the URL of the proxy that served the info, even if it was
+	// served from the disk cache. It is not part of the proxy's response.
+	Proxy string `json:"-"`
 }
 
-// New constructs a *Client using the provided rawurl, which is expected to
-// be an absolute URI that can be directly passed to http.Get.
+// New constructs a *Client using the provided rawurl, which is a
+// comma-separated list of proxy URLs in the style of GOPROXY. Each URL is
+// expected to be an absolute URI that can be directly passed to http.Get.
+//
+// Requests are made to each proxy in turn. A request falls back to the next
+// proxy if the proxy responds with a 404 or 410, or times out. The special
+// GOPROXY values "direct" and "off" are not supported.
 func New(rawurl string) (_ *Client, err error) {
 	defer derrors.Wrap(&err, "proxy.New(%q)", rawurl)
-	url, err := url.Parse(rawurl)
+	var urls []string
+	for _, raw := range strings.Split(rawurl, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "direct" || raw == "off" {
+			return nil, fmt.Errorf("%q is not supported", raw)
+		}
+		url, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("url.Parse: %v", err)
+		}
+		if url.Scheme != "https" {
+			return nil, fmt.Errorf("scheme must be https (got %s)", url.Scheme)
+		}
+		urls = append(urls, strings.TrimRight(raw, "/"))
+	}
+	return &Client{
+		urls:       urls,
+		httpClient: &http.Client{Transport: dtrace.Transport(&ochttp.Transport{})},
+		timeouts:   map[string]time.Duration{},
+		latencies: map[string]*latencyTracker{
+			infoEndpoint: {},
+			modEndpoint:  {},
+		},
+	}, nil
+}
+
+// SetTimeouts sets the timeouts for requests to each endpoint from spec, a
+// comma-separated list of endpoint=duration pairs such as "info=5s,zip=5m".
+// The endpoints are "info", "mod", "zip" and "list". A request that times out
+// falls back to the next proxy. SetTimeouts must be called before the Client
+// is used.
+func (c *Client) SetTimeouts(spec string) (err error) {
+	defer derrors.Wrap(&err, "SetTimeouts(%q)", spec)
+	if spec == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("bad pair %q: want endpoint=duration", pair)
+		}
+		switch parts[0] {
+		case infoEndpoint, modEndpoint, zipEndpoint, listEndpoint:
+		default:
+			return fmt.Errorf("unknown endpoint %q", parts[0])
+		}
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return err
+		}
+		c.timeouts[parts[0]] = d
+	}
+	return nil
+}
+
+// Ping checks that the first proxy of c can be reached. Any response counts,
+// except for a server error.
+func (c *Client) Ping(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "proxy.Client.Ping")
+	r, err := ctxhttp.Get(ctx, c.httpClient, c.urls[0]+"/")
 	if err != nil {
-		return nil, fmt.Errorf("url.Parse: %v", err)
+		return err
 	}
-	if url.Scheme != "https" {
-		return nil, fmt.Errorf("scheme must be https (got %s)", url.Scheme)
+	r.Body.Close()
+	if r.StatusCode >= 500 {
+		return fmt.Errorf("unexpected status %d %s", r.StatusCode, r.Status)
 	}
-	cleanURL := strings.TrimRight(rawurl, "/")
-	return &Client{url: cleanURL, httpClient: &http.Client{Transport: &ochttp.Transport{}}}, nil
+	return nil
 }
 
 // GetInfo makes a request to $GOPROXY/<module>/@v/<requestedVersion>.info and
 // transforms that data into a *VersionInfo.
 func (c *Client) GetInfo(ctx context.Context, modulePath, requestedVersion string) (_ *VersionInfo, err error) {
 	defer derrors.Wrap(&err, "proxy.Client.GetInfo(%q, %q)", modulePath, requestedVersion)
-	data, err := c.readBody(ctx, modulePath, requestedVersion, "info")
+	data, proxyURL, err := c.readBody(ctx, modulePath, requestedVersion, "info")
 	if err != nil {
 		return nil, err
 	}
@@ -69,13 +164,15 @@ func (c *Client) GetInfo(ctx context.Context, modulePath, requestedVersion strin
 	if err := json.Unmarshal(data, &v); err != nil {
 		return nil, err
 	}
+	v.Proxy = proxyURL
 	return &v, nil
 }
 
 // GetMod makes a request to $GOPROXY/<module>/@v/<resolvedVersion>.mod and returns the raw data.
 func (c *Client) GetMod(ctx context.Context, modulePath, resolvedVersion string) (_ []byte, err error) {
 	defer derrors.Wrap(&err, "proxy.Client.GetMod(%q, %q)", modulePath, resolvedVersion)
-	return c.readBody(ctx, modulePath, resolvedVersion, "mod")
+	data, _, err := c.readBody(ctx, modulePath, resolvedVersion, "mod")
+	return data, err
 }
 
 // GetZip makes a request to $GOPROXY/<path>/@v/<resolvedVersion>.zip and transforms
@@ -89,7 +186,7 @@ func (c *Client) GetZip(ctx context.Context, requestedPath, requestedVersion str
 	if err != nil {
 		return nil, err
 	}
-	bodyBytes, err := c.readBody(ctx, requestedPath, info.Version, "zip")
+	bodyBytes, err := c.readZip(ctx, requestedPath, info.Version)
 	if err != nil {
 		return nil, err
 	}
@@ -100,9 +197,96 @@ func (c *Client) GetZip(ctx context.Context, requestedPath, requestedVersion str
 	return zipReader, nil
 }
 
-func (c *Client) escapedURL(modulePath, version, suffix string) (_ string, err error) {
+// SetZipBucket makes the Client read the zips of modules from b, and store
+// there the zips it downloads. SetZipBucket must be called before the Client
+// is used.
+func (c *Client) SetZipBucket(b blob.Bucket) {
+	c.zips = b
+}
+
+// ZipKey returns the key of the blob that holds the zip of modulePath at
+// resolvedVersion, and whether the Client stores zips.
+func (c *Client) ZipKey(modulePath, resolvedVersion string) (string, bool) {
+	if c.zips == nil {
+		return "", false
+	}
+	p, err := escapedPath(modulePath, resolvedVersion, zipEndpoint)
+	if err != nil {
+		return "", false
+	}
+	return "zips/" + p, true
+}
+
+// readZip returns the zip of modulePath at resolvedVersion, from the zip
+// bucket if it is there.
+func (c *Client) readZip(ctx context.Context, modulePath, resolvedVersion string) ([]byte, error) {
+	key, ok := c.ZipKey(modulePath, resolvedVersion)
+	if !ok {
+		data, _, err := c.readBody(ctx, modulePath, resolvedVersion, zipEndpoint)
+		return data, err
+	}
+	data, err := c.zips.Read(ctx, key)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, derrors.NotFound) {
+		log.Error(ctx, err)
+	}
+	data, _, err = c.readBody(ctx, modulePath, resolvedVersion, zipEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	// The zip is still usable if it could not be stored.
+	if err := c.zips.Write(ctx, key, data); err != nil {
+		log.Error(ctx, err)
+	}
+	return data, nil
+}
+
+// GetZipSize makes a HEAD request to $GOPROXY/<path>/@v/<resolvedVersion>.zip
+// and returns the size of the zip in bytes, without downloading it.
+func (c *Client) GetZipSize(ctx context.Context, modulePath, resolvedVersion string) (_ int64, err error) {
+	defer derrors.Wrap(&err, "proxy.Client.GetZipSize(ctx, %q, %q)", modulePath, resolvedVersion)
+
+	if c.cache != nil {
+		if size, ok := c.cache.stat(cacheKey(modulePath, resolvedVersion, zipEndpoint)); ok {
+			return size, nil
+		}
+	}
+
+	p, err := escapedPath(modulePath, resolvedVersion, zipEndpoint)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	_, err = c.tryProxies(ctx, modulePath, resolvedVersion, zipEndpoint, p, func(ctx context.Context, u string) error {
+		ctx, cancel := c.withTimeout(ctx, zipEndpoint)
+		defer cancel()
+		r, err := ctxhttp.Head(ctx, c.httpClient, u)
+		if err != nil {
+			return fmt.Errorf("ctxhttp.Head(ctx, client, %q): %w", u, err)
+		}
+		defer r.Body.Close()
+		if err := responseError(r); err != nil {
+			return fmt.Errorf("ctxhttp.Head(ctx, client, %q): %w", u, err)
+		}
+		if r.ContentLength < 0 {
+			return fmt.Errorf("ctxhttp.Head(ctx, client, %q): unknown content length", u)
+		}
+		size = r.ContentLength
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// escapedPath returns the path of the request for the given module version
+// and suffix, relative to the proxy URL.
+func escapedPath(modulePath, version, suffix string) (_ string, err error) {
 	defer func() {
-		derrors.Wrap(&err, "Client.escapedURL(%q, %q, %q)", modulePath, version, suffix)
+		derrors.Wrap(&err, "escapedPath(%q, %q, %q)", modulePath, version, suffix)
 	}()
 
 	if suffix != "info" && suffix != "mod" && suffix != "zip" {
@@ -116,32 +300,57 @@ func (c *Client) escapedURL(modulePath, version, suffix string) (_ string, err e
 		if suffix != "info" {
 			return "", fmt.Errorf("cannot ask for latest with suffix %q", suffix)
 		}
-		return fmt.Sprintf("%s/%s/@latest", c.url, escapedPath), nil
+		return fmt.Sprintf("%s/@latest", escapedPath), nil
 	}
 	escapedVersion, err := module.EscapeVersion(version)
 	if err != nil {
 		return "", fmt.Errorf("version: %v: %w", err, derrors.InvalidArgument)
 	}
-	return fmt.Sprintf("%s/%s/@v/%s.%s", c.url, escapedPath, escapedVersion, suffix), nil
+	return fmt.Sprintf("%s/@v/%s.%s", escapedPath, escapedVersion, suffix), nil
 }
 
-func (c *Client) readBody(ctx context.Context, modulePath, version, suffix string) (_ []byte, err error) {
+// readBody returns the body of the response for the given module version and
+// suffix, and the URL of the proxy that served it. Only .info responses
+// remember their proxy in the disk cache, so for other responses served from
+// it the URL is empty.
+func (c *Client) readBody(ctx context.Context, modulePath, version, suffix string) (_ []byte, proxyURL string, err error) {
 	defer derrors.Wrap(&err, "Client.readBody(%q, %q, %q)", modulePath, version, suffix)
 
-	u, err := c.escapedURL(modulePath, version, suffix)
+	p, err := escapedPath(modulePath, version, suffix)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	var key string
+	if c.cache != nil {
+		key = cacheKey(modulePath, version, suffix)
+	}
+	if key != "" {
+		data, ok := c.cache.get(key)
+		recordCacheResult(ctx, suffix, ok)
+		if ok {
+			if suffix == infoEndpoint {
+				data, proxyURL = splitCachedProxy(data)
+			}
+			return data, proxyURL, nil
+		}
 	}
 	var data []byte
-	err = c.executeRequest(ctx, u, func(body io.Reader) error {
+	proxyURL, err = c.tryProxies(ctx, modulePath, version, suffix, p, func(ctx context.Context, u string) error {
 		var err error
-		data, err = ioutil.ReadAll(body)
+		data, err = c.get(ctx, suffix, u)
 		return err
 	})
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	return data, nil
+	if key != "" {
+		cached := data
+		if suffix == infoEndpoint {
+			cached = withCachedProxy(proxyURL, data)
+		}
+		c.cache.put(ctx, key, cached)
+	}
+	return data, proxyURL, nil
 }
 
 // ListVersions makes a request to $GOPROXY/<path>/@v/list and returns the
@@ -151,39 +360,134 @@ func (c *Client) ListVersions(ctx context.Context, modulePath string) ([]string,
 	if err != nil {
 		return nil, fmt.Errorf("module.EscapePath(%q): %w", modulePath, derrors.InvalidArgument)
 	}
-	u := fmt.Sprintf("%s/%s/@v/list", c.url, escapedPath)
+	p := fmt.Sprintf("%s/@v/list", escapedPath)
 	var versions []string
 	collect := func(body io.Reader) error {
+		// Discard versions read from a proxy that failed partway through.
+		versions = nil
 		scanner := bufio.NewScanner(body)
 		for scanner.Scan() {
 			versions = append(versions, scanner.Text())
 		}
 		return scanner.Err()
 	}
-	if err := c.executeRequest(ctx, u, collect); err != nil {
+	if _, err := c.executeRequest(ctx, modulePath, listEndpoint, p, collect); err != nil {
 		return nil, err
 	}
 	return versions, nil
 }
 
-// executeRequest executes an HTTP GET request for u, then calls the bodyFunc
-// on the response body, if no error occurred.
-func (c *Client) executeRequest(ctx context.Context, u string, bodyFunc func(body io.Reader) error) error {
+// executeRequest executes an HTTP GET request for path on the given
+// endpoint of the proxies for modulePath, then calls the bodyFunc on the
+// response body, if no error occurred. It returns the URL of the proxy that
+// served the request.
+func (c *Client) executeRequest(ctx context.Context, modulePath, endpoint, path string, bodyFunc func(body io.Reader) error) (string, error) {
+	return c.tryProxies(ctx, modulePath, "", endpoint, path, func(ctx context.Context, u string) error {
+		return c.doRequest(ctx, endpoint, u, bodyFunc)
+	})
+}
+
+// get executes an HTTP GET request for u on the given endpoint and returns
+// the response body. Requests to endpoints with a latency tracker are hedged
+// once enough latencies have been observed.
+func (c *Client) get(ctx context.Context, endpoint, u string) ([]byte, error) {
+	if t := c.latencies[endpoint]; t != nil {
+		if delay, ok := t.percentile(hedgePercentile); ok {
+			return c.hedgedGet(ctx, endpoint, u, delay)
+		}
+	}
+	return c.getOnce(ctx, endpoint, u)
+}
+
+// getOnce is like get, but never hedges the request.
+func (c *Client) getOnce(ctx context.Context, endpoint, u string) ([]byte, error) {
+	var data []byte
+	err := c.doRequest(ctx, endpoint, u, func(body io.Reader) error {
+		var err error
+		data, err = ioutil.ReadAll(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// doRequest executes an HTTP GET request for u, subject to the timeout for
+// endpoint, then calls the bodyFunc on the response body, if no error
+// occurred.
+func (c *Client) doRequest(ctx context.Context, endpoint, u string, bodyFunc func(body io.Reader) error) error {
+	ctx, cancel := c.withTimeout(ctx, endpoint)
+	defer cancel()
+
+	start := time.Now()
 	r, err := ctxhttp.Get(ctx, c.httpClient, u)
 	if err != nil {
-		return fmt.Errorf("ctxhttp.Get(ctx, client, %q): %v", u, err)
+		return fmt.Errorf("ctxhttp.Get(ctx, client, %q): %w", u, err)
 	}
 	defer r.Body.Close()
+	if err := responseError(r); err != nil {
+		return fmt.Errorf("ctxhttp.Get(ctx, client, %q): %w", u, err)
+	}
+	if err := bodyFunc(r.Body); err != nil {
+		return err
+	}
+	if t := c.latencies[endpoint]; t != nil {
+		t.record(time.Since(start))
+	}
+	return nil
+}
+
+// withTimeout returns a context that is done after the timeout for endpoint,
+// if it has one.
+func (c *Client) withTimeout(ctx context.Context, endpoint string) (context.Context, context.CancelFunc) {
+	if d := c.timeouts[endpoint]; d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// tryProxies calls f with the URL of path on each proxy for modulePath in
+// turn, until f succeeds or fails with an error that should not fall back to
+// the next proxy. It returns the URL of the last proxy tried, and the error
+// from f. The version is only used to trace the requests, and may be empty.
+// The context passed to f carries the span for the requests.
+func (c *Client) tryProxies(ctx context.Context, modulePath, version, endpoint, path string, f func(ctx context.Context, u string) error) (proxyURL string, err error) {
+	attrs := []label.KeyValue{dtrace.ModulePath(modulePath), label.String("pkgsite.proxy.endpoint", endpoint)}
+	if version != "" {
+		attrs = append(attrs, dtrace.Version(version))
+	}
+	ctx, span := dtrace.StartSpan(ctx, "proxy."+endpoint, attrs...)
+	defer dtrace.End(span, &err)
+	for _, proxyURL = range c.proxiesFor(modulePath) {
+		start := time.Now()
+		err = f(ctx, proxyURL+"/"+path)
+		result := requestResult(err)
+		recordProxyRequest(ctx, proxyURL, endpoint, result, time.Since(start))
+		span.AddEvent(ctx, "proxy request", label.String("pkgsite.proxy.url", proxyURL), label.String("pkgsite.proxy.result", result))
+		if result != resultNotFound && result != resultTimeout {
+			break
+		}
+		if ctx.Err() != nil {
+			// There is no time left to try another proxy.
+			break
+		}
+	}
+	return proxyURL, err
+}
+
+// responseError returns an error describing an unsuccessful response from the
+// proxy, or nil if the response was successful.
+func responseError(r *http.Response) error {
 	switch {
 	case 200 <= r.StatusCode && r.StatusCode < 300:
-		// OK.
+		return nil
 	case r.StatusCode == http.StatusNotFound,
 		r.StatusCode == http.StatusGone:
 		// Treat both 404 Not Found and 410 Gone responses
 		// from the proxy as a "not found" error category.
-		return fmt.Errorf("ctxhttp.Get(ctx, client, %q): %w", u, derrors.NotFound)
+		return derrors.NotFound
 	default:
-		return fmt.Errorf("ctxhttp.Get(ctx, client, %q): unexpected status %d %s", u, r.StatusCode, r.Status)
+		return fmt.Errorf("unexpected status %d %s", r.StatusCode, r.Status)
 	}
-	return bodyFunc(r.Body)
 }
@@ -5,13 +5,21 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/blob"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/testing/testhelper"
 )
@@ -243,15 +251,85 @@ func TestGetZipNonExist(t *testing.T) {
 	}
 }
 
-func TestEncodedURL(t *testing.T) {
-	c := &Client{url: "u"}
+func TestGetZipBucket(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "zips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bucket, err := blob.NewDisk(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var numZipRequests int32
+	proxyMux := TestProxy([]*TestModule{cleanTestModule(t, sampleModule)})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".zip") {
+			atomic.AddInt32(&numZipRequests, 1)
+		}
+		proxyMux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.httpClient = srv.Client()
+	c.SetZipBucket(bucket)
+
+	key, ok := c.ZipKey(sampleModule.ModulePath, sampleModule.Version)
+	if want := "zips/github.com/my/module/@v/v1.0.0.zip"; !ok || key != want {
+		t.Errorf("ZipKey = %q, %t; want %q, true", key, ok, want)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetZip(ctx, sampleModule.ModulePath, sampleModule.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt32(&numZipRequests); got != 1 {
+		t.Errorf("got %d zip requests, want 1", got)
+	}
+	data, err := bucket.Read(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, sampleModule.zip) {
+		t.Error("stored zip differs from the proxy's")
+	}
+}
+
+func TestGetZipSize(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	client, teardownProxy := SetupTestProxy(t, []*TestModule{sampleModule})
+	defer teardownProxy()
+
+	got, err := client.GetZipSize(ctx, sampleModule.ModulePath, sampleModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(sampleModule.zip)); got != want {
+		t.Errorf("GetZipSize(ctx, %q, %q) = %d, want %d", sampleModule.ModulePath, sampleModule.Version, got, want)
+	}
+
+	if _, err := client.GetZipSize(ctx, "my.mod/nonexistmodule", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got %v, want %v", err, derrors.NotFound)
+	}
+}
+
+func TestEscapedPath(t *testing.T) {
 	for _, test := range []struct {
 		path, version, suffix string
 		want                  string // empty => error
 	}{
 		{
 			"mod.com", "v1.0.0", "info",
-			"u/mod.com/@v/v1.0.0.info",
+			"mod.com/@v/v1.0.0.info",
 		},
 		{
 			"mod", "v1.0.0", "info",
@@ -259,11 +337,11 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", "v1.0.0-rc1", "info",
-			"u/mod.com/@v/v1.0.0-rc1.info",
+			"mod.com/@v/v1.0.0-rc1.info",
 		},
 		{
 			"mod.com/Foo", "v1.0.0-RC1", "info",
-			"u/mod.com/!foo/@v/v1.0.0-!r!c1.info",
+			"mod.com/!foo/@v/v1.0.0-!r!c1.info",
 		},
 		{
 			"mod.com", ".", "info",
@@ -271,7 +349,7 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", "v1.0.0", "zip",
-			"u/mod.com/@v/v1.0.0.zip",
+			"mod.com/@v/v1.0.0.zip",
 		},
 		{
 			"mod", "v1.0.0", "zip",
@@ -279,11 +357,11 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", "v1.0.0-rc1", "zip",
-			"u/mod.com/@v/v1.0.0-rc1.zip",
+			"mod.com/@v/v1.0.0-rc1.zip",
 		},
 		{
 			"mod.com/Foo", "v1.0.0-RC1", "zip",
-			"u/mod.com/!foo/@v/v1.0.0-!r!c1.zip",
+			"mod.com/!foo/@v/v1.0.0-!r!c1.zip",
 		},
 		{
 			"mod.com", ".", "zip",
@@ -291,7 +369,7 @@ func TestEncodedURL(t *testing.T) {
 		},
 		{
 			"mod.com", internal.LatestVersion, "info",
-			"u/mod.com/@latest",
+			"mod.com/@latest",
 		},
 		{
 			"mod.com", internal.LatestVersion, "zip",
@@ -302,9 +380,174 @@ func TestEncodedURL(t *testing.T) {
 			"", // only "info" or "zip"
 		},
 	} {
-		got, err := c.escapedURL(test.path, test.version, test.suffix)
+		got, err := escapedPath(test.path, test.version, test.suffix)
 		if got != test.want || (err != nil) != (test.want == "") {
 			t.Errorf("%s, %s, %s: got (%q, %v), want %q", test.path, test.version, test.suffix, got, err, test.want)
 		}
 	}
 }
+
+func TestNew(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string // nil => error
+	}{
+		{"https://proxy.golang.org", []string{"https://proxy.golang.org"}},
+		{"https://a.com/, https://b.com/x", []string{"https://a.com", "https://b.com/x"}},
+		{"http://a.com", nil},
+		{"https://a.com,direct", nil},
+		{"off", nil},
+		{"https://a.com,,https://b.com", nil},
+	} {
+		c, err := New(test.in)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("New(%q): got nil error, want error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New(%q): %v", test.in, err)
+			continue
+		}
+		if diff := cmp.Diff(test.want, c.urls); diff != "" {
+			t.Errorf("New(%q) urls mismatch (-want +got):\n%s", test.in, diff)
+		}
+	}
+}
+
+func TestFallback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	empty := httptest.NewTLSServer(TestProxy([]*TestModule{}))
+	defer empty.Close()
+	full := httptest.NewTLSServer(TestProxy([]*TestModule{cleanTestModule(t, sampleModule)}))
+	defer full.Close()
+	broken := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	newClient := func(urls ...string) *Client {
+		t.Helper()
+		c, err := New(strings.Join(urls, ","))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// All httptest servers share a certificate.
+		c.httpClient = empty.Client()
+		return c
+	}
+
+	// A 404 from the first proxy falls back to the second.
+	c := newClient(empty.URL, full.URL)
+	info, err := c.GetInfo(ctx, sampleModule.ModulePath, sampleModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Proxy != full.URL {
+		t.Errorf("GetInfo: got proxy %q, want %q", info.Proxy, full.URL)
+	}
+	if _, err := c.GetZip(ctx, sampleModule.ModulePath, sampleModule.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	// A version that no proxy has is not found.
+	if _, err := c.GetInfo(ctx, "my.mod/nonexistmodule", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got %v, want %v", err, derrors.NotFound)
+	}
+
+	// Other errors do not fall back.
+	c = newClient(broken.URL, full.URL)
+	if _, err := c.GetInfo(ctx, sampleModule.ModulePath, sampleModule.Version); err == nil || errors.Is(err, derrors.NotFound) {
+		t.Errorf("got %v, want a non-NotFound error", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// A proxy that has nothing at its root is still reachable.
+	empty := httptest.NewTLSServer(TestProxy([]*TestModule{}))
+	defer empty.Close()
+	broken := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer broken.Close()
+
+	for _, test := range []struct {
+		url     string
+		wantErr bool
+	}{
+		{empty.URL, false},
+		{broken.URL, true},
+	} {
+		c, err := New(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// All httptest servers share a certificate.
+		c.httpClient = empty.Client()
+		if err := c.Ping(ctx); (err != nil) != test.wantErr {
+			t.Errorf("Ping(%s) = %v, want error: %t", test.url, err, test.wantErr)
+		}
+	}
+}
+
+func TestSetTimeouts(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want map[string]time.Duration // nil => error
+	}{
+		{"", map[string]time.Duration{}},
+		{"info=5s, zip=5m", map[string]time.Duration{"info": 5 * time.Second, "zip": 5 * time.Minute}},
+		{"info", nil},
+		{"foo=5s", nil},
+		{"mod=five", nil},
+	} {
+		c, err := New("https://proxy.golang.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.SetTimeouts(test.in)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("SetTimeouts(%q): got nil error, want error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SetTimeouts(%q): %v", test.in, err)
+			continue
+		}
+		if diff := cmp.Diff(test.want, c.timeouts); diff != "" {
+			t.Errorf("SetTimeouts(%q) mismatch (-want +got):\n%s", test.in, diff)
+		}
+	}
+}
+
+func TestTimeoutFallback(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	slow := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer slow.Close()
+	full := httptest.NewTLSServer(TestProxy([]*TestModule{cleanTestModule(t, sampleModule)}))
+	defer full.Close()
+
+	c, err := New(slow.URL + "," + full.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.httpClient = slow.Client()
+	if err := c.SetTimeouts("mod=50ms"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetMod(ctx, sampleModule.ModulePath, sampleModule.Version); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+var (
+	keyCacheHit  = tag.MustNewKey("proxy_cache.hit")
+	cacheResults = stats.Int64(
+		"go-discovery/proxy/cache_result_count",
+		"The result of a lookup in the proxy disk cache.",
+		stats.UnitDimensionless,
+	)
+
+	// ProxyCacheResultCount is a counter of lookups in the proxy disk cache,
+	// by endpoint and whether it was a hit.
+	ProxyCacheResultCount = &view.View{
+		Name:        "go-discovery/proxy/cache_result_count",
+		Measure:     cacheResults,
+		Aggregation: view.Count(),
+		Description: "proxy disk cache results, by endpoint and whether it was a hit",
+		TagKeys:     []tag.Key{keyProxyEndpoint, keyCacheHit},
+	}
+)
+
+func recordCacheResult(ctx context.Context, endpoint string, hit bool) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyProxyEndpoint, endpoint),
+		tag.Upsert(keyCacheHit, strconv.FormatBool(hit)),
+	}, cacheResults.M(1))
+}
+
+// EnableDiskCache makes the Client cache responses in files under dir, using
+// at most maxBytes of disk. When the limit is exceeded, the least recently
+// used responses are evicted. Only responses that cannot change are cached:
+// the .mod and .zip files, and the .info files of semantic versions.
+// EnableDiskCache must be called before the Client is used.
+func (c *Client) EnableDiskCache(dir string, maxBytes int64) (err error) {
+	defer derrors.Wrap(&err, "EnableDiskCache(%q, %d)", dir, maxBytes)
+	c.cache, err = newDiskCache(dir, maxBytes)
+	return err
+}
+
+// cacheKey returns the key of the cached response for the given module
+// version and endpoint, or the empty string if the response should not be
+// cached.
+func cacheKey(modulePath, version, endpoint string) string {
+	if endpoint == infoEndpoint && !semver.IsValid(version) {
+		// The version may be a branch name or "latest", which can resolve to
+		// a different version later.
+		return ""
+	}
+	return fmt.Sprintf("%s@%s.%s", modulePath, version, endpoint)
+}
+
+// withCachedProxy returns the data to cache for an .info response served by
+// proxyURL: the response, preceded by a line holding the URL, so that the
+// proxy is still known when the response is served from the cache.
+func withCachedProxy(proxyURL string, data []byte) []byte {
+	return append([]byte(proxyURL+"\n"), data...)
+}
+
+// splitCachedProxy reverses withCachedProxy. Files cached before the proxy
+// was recorded start directly with the JSON response; for them, the URL is
+// empty.
+func splitCachedProxy(cached []byte) (data []byte, proxyURL string) {
+	i := bytes.IndexByte(cached, '\n')
+	if i < 0 || bytes.HasPrefix(cached, []byte("{")) {
+		return cached, ""
+	}
+	return cached[i+1:], string(cached[:i])
+}
+
+// A diskCache stores data in files under a directory, evicting the least
+// recently used files when their total size exceeds a limit.
+type diskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	size    int64                    // total size of the files in entries
+	lru     *list.List               // of *cacheEntry, most recently used first
+	entries map[string]*list.Element // by file name
+}
+
+// tempFilePrefix is the prefix of the names of files that are being written.
+const tempFilePrefix = ".tmp-"
+
+type cacheEntry struct {
+	name string
+	size int64
+}
+
+// newDiskCache returns a diskCache that stores files in dir, which is created
+// if necessary. Files already in dir are part of the cache, with the most
+// recently modified treated as the most recently used.
+func newDiskCache(dir string, maxBytes int64) (*diskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ModTime().After(infos[j].ModTime()) })
+	c := &diskCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  map[string]*list.Element{},
+	}
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		if strings.HasPrefix(info.Name(), tempFilePrefix) {
+			// Remove temporary files left by interrupted writes.
+			os.Remove(filepath.Join(dir, info.Name()))
+			continue
+		}
+		c.entries[info.Name()] = c.lru.PushBack(&cacheEntry{info.Name(), info.Size()})
+		c.size += info.Size()
+	}
+	c.evict()
+	return c, nil
+}
+
+// fileName returns the name of the file holding the data for key.
+func fileName(key string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
+}
+
+// get returns the data stored for key, if any.
+func (c *diskCache) get(key string) ([]byte, bool) {
+	name := fileName(key)
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	if ok {
+		c.lru.MoveToFront(e)
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		// The file may have been evicted since it was looked up.
+		return nil, false
+	}
+	return data, true
+}
+
+// stat returns the size of the data stored for key, if any.
+func (c *diskCache) stat(key string) (int64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[fileName(key)]
+	if !ok {
+		return 0, false
+	}
+	return e.Value.(*cacheEntry).size, true
+}
+
+// put stores data for key. Errors are logged, since the cache is only an
+// optimization.
+func (c *diskCache) put(ctx context.Context, key string, data []byte) {
+	size := int64(len(data))
+	if size > c.maxBytes {
+		return
+	}
+	name := fileName(key)
+	// Write to a temporary file and rename it, so that readers never see a
+	// partial file.
+	f, err := ioutil.TempFile(c.dir, tempFilePrefix)
+	if err != nil {
+		log.Errorf(ctx, "proxy disk cache: %v", err)
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(c.dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Errorf(ctx, "proxy disk cache: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[name]; ok {
+		// Another put stored the same data.
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[name] = c.lru.PushFront(&cacheEntry{name, size})
+	c.size += size
+	c.evict()
+}
+
+// evict removes the least recently used files until the cache is within its
+// size limit. It must be called with c.mu held, except during construction.
+func (c *diskCache) evict() {
+	for c.size > c.maxBytes {
+		e := c.lru.Back()
+		ce := e.Value.(*cacheEntry)
+		c.lru.Remove(e)
+		delete(c.entries, ce.name)
+		c.size -= ce.size
+		if err := os.Remove(filepath.Join(c.dir, ce.name)); err != nil && !os.IsNotExist(err) {
+			log.Errorf(context.Background(), "proxy disk cache: %v", err)
+		}
+	}
+}
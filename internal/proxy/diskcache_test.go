@@ -0,0 +1,125 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c, err := newDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(c *diskCache, key, want string) {
+		t.Helper()
+		got, ok := c.get(key)
+		if want == "" {
+			if ok {
+				t.Errorf("get(%q) = %q, want miss", key, got)
+			}
+			return
+		}
+		if !ok || string(got) != want {
+			t.Errorf("get(%q) = %q, %t; want %q, true", key, got, ok, want)
+		}
+	}
+
+	c.put(ctx, "a", []byte("aaaa"))
+	c.put(ctx, "b", []byte("bbbb"))
+	check(c, "a", "aaaa") // a is now more recently used than b
+	c.put(ctx, "c", []byte("cccc"))
+	check(c, "a", "aaaa")
+	check(c, "b", "") // evicted
+	check(c, "c", "cccc")
+	c.put(ctx, "big", []byte("too big to cache"))
+	check(c, "big", "")
+	if size, ok := c.stat("c"); !ok || size != 4 {
+		t.Errorf("stat(%q) = %d, %t; want 4, true", "c", size, ok)
+	}
+
+	// A new cache picks up the files in the directory.
+	c2, err := newDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(c2, "a", "aaaa")
+	check(c2, "c", "cccc")
+	if c2.size != 8 {
+		t.Errorf("size = %d, want 8", c2.size)
+	}
+}
+
+func TestClientDiskCache(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "diskcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var numZipRequests int32
+	proxyMux := TestProxy([]*TestModule{cleanTestModule(t, sampleModule)})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".zip") {
+			atomic.AddInt32(&numZipRequests, 1)
+		}
+		proxyMux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.httpClient = srv.Client()
+	if err := c.EnableDiskCache(dir, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetZip(ctx, sampleModule.ModulePath, sampleModule.Version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt32(&numZipRequests); got != 1 {
+		t.Errorf("got %d zip requests, want 1", got)
+	}
+	size, err := c.GetZipSize(ctx, sampleModule.ModulePath, sampleModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(sampleModule.zip)); size != want {
+		t.Errorf("GetZipSize = %d, want %d", size, want)
+	}
+	// The proxy that served the info is remembered with it.
+	c.httpClient = nil
+	info, err := c.GetInfo(ctx, sampleModule.ModulePath, sampleModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Proxy != srv.URL {
+		t.Errorf("GetInfo from the cache: got proxy %q, want %q", info.Proxy, srv.URL)
+	}
+	// The info for a branch is not cached.
+	if key := cacheKey(sampleModule.ModulePath, "master", infoEndpoint); key != "" {
+		t.Errorf("cacheKey for master = %q, want empty", key)
+	}
+}
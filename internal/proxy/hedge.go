@@ -0,0 +1,102 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// hedgePercentile is the percentile of recent latencies after which a
+	// second, hedged request is sent.
+	hedgePercentile = 0.95
+	// numLatencySamples is the number of recent latencies kept for each
+	// endpoint.
+	numLatencySamples = 100
+	// minLatencySamples is the number of latencies that must be observed
+	// before requests are hedged.
+	minLatencySamples = 20
+)
+
+// A latencyTracker records the latencies of recent successful requests to an
+// endpoint.
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int // index of the sample to overwrite when samples is full
+}
+
+func (t *latencyTracker) record(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) < numLatencySamples {
+		t.samples = append(t.samples, d)
+		return
+	}
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % numLatencySamples
+}
+
+// percentile returns the pth percentile of the recorded latencies, where p
+// is between 0 and 1. It returns false if too few latencies have been
+// recorded.
+func (t *latencyTracker) percentile(p float64) (time.Duration, bool) {
+	t.mu.Lock()
+	if len(t.samples) < minLatencySamples {
+		t.mu.Unlock()
+		return 0, false
+	}
+	sorted := append([]time.Duration(nil), t.samples...)
+	t.mu.Unlock()
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], true
+}
+
+// hedgedGet is like getOnce, but if the request has not completed after
+// delay, it sends a second request for u and returns the first successful
+// response.
+func (c *Client) hedgedGet(ctx context.Context, endpoint, u string, delay time.Duration) ([]byte, error) {
+	// Cancel the outstanding request when the other one succeeds.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	results := make(chan result, 2)
+	send := func() {
+		data, err := c.getOnce(ctx, endpoint, u)
+		results <- result{data, err}
+	}
+	go send()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		return r.data, r.err
+	case <-timer.C:
+	}
+	recordHedgedRequest(ctx, endpoint)
+	go send()
+
+	var r result
+	for i := 0; i < 2; i++ {
+		r = <-results
+		if r.err == nil {
+			return r.data, nil
+		}
+	}
+	return nil, r.err
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLatencyTrackerPercentile(t *testing.T) {
+	var lt latencyTracker
+	for i := 1; i < minLatencySamples; i++ {
+		lt.record(time.Duration(i) * time.Millisecond)
+	}
+	if _, ok := lt.percentile(hedgePercentile); ok {
+		t.Fatalf("got ok with %d samples, want !ok", minLatencySamples-1)
+	}
+	// Record more than numLatencySamples, so that the first ones are
+	// overwritten.
+	for i := 1; i <= 2*numLatencySamples; i++ {
+		lt.record(time.Duration(i) * time.Millisecond)
+	}
+	got, ok := lt.percentile(hedgePercentile)
+	if want := 196 * time.Millisecond; !ok || got != want {
+		t.Errorf("percentile(%g) = %s, %t; want %s, true", hedgePercentile, got, ok, want)
+	}
+}
+
+func TestHedgedGet(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// The first request for the .info file stalls until it is canceled.
+	var numInfoRequests int32
+	proxyMux := TestProxy([]*TestModule{cleanTestModule(t, sampleModule)})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".info") && atomic.AddInt32(&numInfoRequests, 1) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		proxyMux.ServeHTTP(w, r)
+	})
+	srv := httptest.NewTLSServer(handler)
+	defer srv.Close()
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.httpClient = srv.Client()
+	for i := 0; i < minLatencySamples; i++ {
+		c.latencies[infoEndpoint].record(time.Millisecond)
+	}
+
+	info, err := c.GetInfo(ctx, sampleModule.ModulePath, sampleModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != sampleModule.Version {
+		t.Errorf("got version %q, want %q", info.Version, sampleModule.Version)
+	}
+	if got := atomic.LoadInt32(&numInfoRequests); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+var (
+	keyProxyURL      = tag.MustNewKey("proxy.url")
+	keyProxyEndpoint = tag.MustNewKey("proxy.endpoint")
+	keyProxyResult   = tag.MustNewKey("proxy.result")
+	proxyLatency     = stats.Float64(
+		"go-discovery/proxy/latency",
+		"Latency of a request to a module proxy.",
+		stats.UnitMilliseconds,
+	)
+	proxyHedges = stats.Int64(
+		"go-discovery/proxy/hedged_count",
+		"A hedged request to a module proxy.",
+		stats.UnitDimensionless,
+	)
+
+	// ProxyRequestCount is a counter of requests to module proxies, by proxy,
+	// endpoint and result.
+	ProxyRequestCount = &view.View{
+		Name:        "go-discovery/proxy/count",
+		Measure:     proxyLatency,
+		Aggregation: view.Count(),
+		Description: "module proxy requests, by proxy, endpoint and result",
+		TagKeys:     []tag.Key{keyProxyURL, keyProxyEndpoint, keyProxyResult},
+	}
+	// ProxyLatencyDistribution aggregates the latency of requests to module
+	// proxies, by proxy, endpoint and result.
+	ProxyLatencyDistribution = &view.View{
+		Name:        "go-discovery/proxy/latency",
+		Measure:     proxyLatency,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+		Description: "module proxy request latency, by proxy, endpoint and result",
+		TagKeys:     []tag.Key{keyProxyURL, keyProxyEndpoint, keyProxyResult},
+	}
+	// ProxyHedgedRequestCount is a counter of hedged requests to module
+	// proxies, by endpoint.
+	ProxyHedgedRequestCount = &view.View{
+		Name:        "go-discovery/proxy/hedged_count",
+		Measure:     proxyHedges,
+		Aggregation: view.Count(),
+		Description: "hedged module proxy requests, by endpoint",
+		TagKeys:     []tag.Key{keyProxyEndpoint},
+	}
+
+	// ProxyViews are the views for module proxy requests.
+	ProxyViews = []*view.View{ProxyRequestCount, ProxyLatencyDistribution, ProxyHedgedRequestCount, ProxyCacheResultCount}
+)
+
+// Values for the keyProxyResult tag.
+const (
+	resultOK       = "ok"
+	resultNotFound = "not_found"
+	resultTimeout  = "timeout"
+	resultError    = "error"
+)
+
+// requestResult classifies the error from a request to a proxy.
+func requestResult(err error) string {
+	var nerr net.Error
+	switch {
+	case err == nil:
+		return resultOK
+	case errors.Is(err, derrors.NotFound):
+		return resultNotFound
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &nerr) && nerr.Timeout():
+		return resultTimeout
+	default:
+		return resultError
+	}
+}
+
+func recordProxyRequest(ctx context.Context, proxyURL, endpoint, result string, latency time.Duration) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyProxyURL, proxyURL),
+		tag.Upsert(keyProxyEndpoint, endpoint),
+		tag.Upsert(keyProxyResult, result),
+	}, proxyLatency.M(float64(latency)/float64(time.Millisecond)))
+}
+
+func recordHedgedRequest(ctx context.Context, endpoint string) {
+	stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(keyProxyEndpoint, endpoint),
+	}, proxyHedges.M(1))
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/globs"
+)
+
+// PrivateConfig describes how to fetch private modules.
+type PrivateConfig struct {
+	// Patterns is a comma-separated list of glob patterns of module path
+	// prefixes, in the style of GOPRIVATE. Modules matching Patterns are
+	// fetched only from ProxyURL.
+	Patterns string
+	// ProxyURL is the URL of the proxy that serves private modules.
+	ProxyURL string
+	// NetrcFile, if non-empty, is the path of a .netrc file holding the
+	// credentials for ProxyURL.
+	NetrcFile string
+	// Header, if non-empty, is a header of the form "Name: value" that is
+	// sent with each request to ProxyURL, like "Authorization: Bearer xyz".
+	Header string
+}
+
+// privateProxy is the proxy for private modules.
+type privateProxy struct {
+	patterns string
+	url      string
+}
+
+// SetPrivate makes the Client fetch modules matching cfg.Patterns from
+// cfg.ProxyURL, authenticating with the credentials in cfg. It does nothing
+// if cfg.Patterns is empty. SetPrivate must be called before the Client is
+// used.
+func (c *Client) SetPrivate(cfg PrivateConfig) (err error) {
+	defer derrors.Wrap(&err, "SetPrivate(%q, %q)", cfg.Patterns, cfg.ProxyURL)
+
+	if cfg.Patterns == "" {
+		return nil
+	}
+	u, err := url.Parse(cfg.ProxyURL)
+	if err != nil {
+		return fmt.Errorf("url.Parse: %v", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("scheme must be https (got %s)", u.Scheme)
+	}
+	t := &authTransport{base: c.httpClient.Transport, host: u.Host}
+	if t.base == nil {
+		t.base = http.DefaultTransport
+	}
+	if cfg.NetrcFile != "" {
+		data, err := ioutil.ReadFile(cfg.NetrcFile)
+		if err != nil {
+			return err
+		}
+		t.login, t.password = netrcCredentials(string(data), u.Host)
+	}
+	if cfg.Header != "" {
+		parts := strings.SplitN(cfg.Header, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("header must have the form \"Name: value\"")
+		}
+		t.headerName, t.headerValue = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+	c.httpClient = &http.Client{Transport: t, Timeout: c.httpClient.Timeout}
+	c.private = &privateProxy{
+		patterns: cfg.Patterns,
+		url:      strings.TrimRight(cfg.ProxyURL, "/"),
+	}
+	return nil
+}
+
+// proxiesFor returns the URLs of the proxies for modulePath, in the order
+// they should be tried. Private modules are only requested from the private
+// proxy, so that their paths are not revealed to public proxies.
+func (c *Client) proxiesFor(modulePath string) []string {
+	if c.private != nil && globs.MatchPrefixPatterns(c.private.patterns, modulePath) {
+		return []string{c.private.url}
+	}
+	return c.urls
+}
+
+// authTransport adds credentials to requests for the private proxy.
+type authTransport struct {
+	base http.RoundTripper
+	// host of the private proxy
+	host                    string
+	login, password         string
+	headerName, headerValue string
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.host || (t.login == "" && t.headerName == "") {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	if t.login != "" {
+		req.SetBasicAuth(t.login, t.password)
+	}
+	if t.headerName != "" {
+		req.Header.Set(t.headerName, t.headerValue)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// netrcCredentials returns the login and password for host in the given
+// .netrc file contents. The host matches a "machine" entry with its name,
+// or else the "default" entry.
+func netrcCredentials(data, host string) (login, password string) {
+	type entry struct {
+		machine, login, password string
+		isDefault                bool
+	}
+	var (
+		entries []*entry
+		cur     *entry
+	)
+	fields := strings.Fields(data)
+parse:
+	for i := 0; i < len(fields); i++ {
+		var next string
+		if i+1 < len(fields) {
+			next = fields[i+1]
+		}
+		switch fields[i] {
+		case "machine":
+			cur = &entry{machine: next}
+			entries = append(entries, cur)
+			i++
+		case "default":
+			cur = &entry{isDefault: true}
+			entries = append(entries, cur)
+		case "login", "password":
+			if cur != nil {
+				if fields[i] == "login" {
+					cur.login = next
+				} else {
+					cur.password = next
+				}
+			}
+			i++
+		case "macdef":
+			// Macro definitions are not supported, and nothing that follows
+			// one can be parsed reliably.
+			break parse
+		}
+	}
+	for _, e := range entries {
+		if !e.isDefault && (e.machine == host || e.machine == hostname(host)) {
+			return e.login, e.password
+		}
+	}
+	for _, e := range entries {
+		if e.isDefault {
+			return e.login, e.password
+		}
+	}
+	return "", ""
+}
+
+// hostname returns host without its port, if any.
+func hostname(host string) string {
+	return (&url.URL{Host: host}).Hostname()
+}
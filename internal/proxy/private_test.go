@@ -0,0 +1,116 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestNetrcCredentials(t *testing.T) {
+	const netrc = `
+machine other.com login o password op
+machine proxy.corp.com
+	login u
+	password p
+default login d password dp
+`
+	for _, test := range []struct {
+		host, wantLogin, wantPassword string
+	}{
+		{"proxy.corp.com", "u", "p"},
+		{"proxy.corp.com:443", "u", "p"},
+		{"other.com", "o", "op"},
+		{"unknown.com", "d", "dp"},
+	} {
+		login, password := netrcCredentials(netrc, test.host)
+		if login != test.wantLogin || password != test.wantPassword {
+			t.Errorf("netrcCredentials(%q) = %q, %q; want %q, %q", test.host, login, password, test.wantLogin, test.wantPassword)
+		}
+	}
+}
+
+func TestPrivateProxy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	privateModule := &TestModule{
+		ModulePath: "private.corp.com/mod",
+		Version:    "v1.0.0",
+		Files:      map[string]string{"foo.go": "package foo"},
+	}
+	var publicRequests int32
+	public := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&publicRequests, 1)
+		http.NotFound(w, r)
+	}))
+	defer public.Close()
+	privateMux := TestProxy([]*TestModule{cleanTestModule(t, privateModule)})
+	private := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "u" || password != "p" || r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		privateMux.ServeHTTP(w, r)
+	}))
+	defer private.Close()
+
+	dir, err := ioutil.TempDir("", "private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	u, err := url.Parse(private.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	netrcFile := filepath.Join(dir, ".netrc")
+	if err := ioutil.WriteFile(netrcFile, []byte("machine "+u.Hostname()+" login u password p\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(public.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// All httptest servers share a certificate.
+	c.httpClient = public.Client()
+	if err := c.SetPrivate(PrivateConfig{
+		Patterns:  "*.corp.com",
+		ProxyURL:  private.URL,
+		NetrcFile: netrcFile,
+		Header:    "X-Token: secret",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := c.GetInfo(ctx, privateModule.ModulePath, privateModule.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Proxy != private.URL {
+		t.Errorf("got proxy %q, want %q", info.Proxy, private.URL)
+	}
+	if got := atomic.LoadInt32(&publicRequests); got != 0 {
+		t.Errorf("got %d requests to the public proxy, want 0", got)
+	}
+	if _, err := c.GetInfo(ctx, "example.com/public", "v1.0.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("got %v, want %v", err, derrors.NotFound)
+	}
+	if got := atomic.LoadInt32(&publicRequests); got != 1 {
+		t.Errorf("got %d requests to the public proxy, want 1", got)
+	}
+}
@@ -107,6 +107,53 @@ func (ds *DataSource) GetDirectoryNew(ctx context.Context, dirPath, modulePath,
 	}, nil
 }
 
+// GetUnitMeta returns the metadata of the unit at path, from the directories
+// of the module zip.
+func (ds *DataSource) GetUnitMeta(ctx context.Context, path, modulePath, version string) (_ *internal.UnitMeta, err error) {
+	defer derrors.Wrap(&err, "GetUnitMeta(%q, %q, %q)", path, modulePath, version)
+	m, d, err := ds.getDirectory(ctx, path, modulePath, version)
+	if err != nil {
+		return nil, err
+	}
+	um := &internal.UnitMeta{
+		DirectoryMeta: d.DirectoryMeta,
+		ModuleInfo:    m.ModuleInfo,
+	}
+	if d.Package != nil {
+		um.Name = d.Package.Name
+	}
+	return um, nil
+}
+
+// GetUnit returns the unit described by um, as extracted from the module
+// zip. All of its fields are available, so fields is ignored.
+func (ds *DataSource) GetUnit(ctx context.Context, um *internal.UnitMeta, _ internal.FieldSet) (_ *internal.VersionedDirectory, err error) {
+	defer derrors.Wrap(&err, "GetUnit(%q, %q, %q)", um.Path, um.ModulePath, um.Version)
+	m, d, err := ds.getDirectory(ctx, um.Path, um.ModulePath, um.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &internal.VersionedDirectory{
+		ModuleInfo:   m.ModuleInfo,
+		DirectoryNew: *d,
+	}, nil
+}
+
+// getDirectory returns the module at modulePath and version, and its
+// directory at path.
+func (ds *DataSource) getDirectory(ctx context.Context, path, modulePath, version string) (*internal.Module, *internal.DirectoryNew, error) {
+	m, err := ds.getModule(ctx, modulePath, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range m.Directories {
+		if d.Path == path {
+			return m, d, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("%q missing from module %s: %w", path, m.ModulePath, derrors.NotFound)
+}
+
 // GetImports returns package imports as extracted from the module zip.
 func (ds *DataSource) GetImports(ctx context.Context, pkgPath, modulePath, version string) (_ []string, err error) {
 	defer derrors.Wrap(&err, "GetImports(%q, %q, %q)", pkgPath, modulePath, version)
@@ -6,12 +6,14 @@ package proxydatasource
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/licenses"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/testing/sample"
@@ -49,16 +51,20 @@ func setup(t *testing.T) (context.Context, *DataSource, func()) {
 var (
 	wantLicenseMD = sample.LicenseMetadata[0]
 	wantLicense   = &licenses.License{Metadata: wantLicenseMD}
+	wantCoverage  = 0.0
 	wantPackage   = internal.LegacyPackage{
-		Path:              "foo.com/bar/baz",
-		Name:              "baz",
-		Imports:           []string{"net/http"},
-		Synopsis:          "Package baz provides a helpful constant.",
-		V1Path:            "foo.com/bar/baz",
-		Licenses:          []*licenses.Metadata{wantLicenseMD},
-		IsRedistributable: true,
-		GOOS:              "linux",
-		GOARCH:            "amd64",
+		Path:                  "foo.com/bar/baz",
+		Name:                  "baz",
+		Imports:               []string{"net/http"},
+		Synopsis:              "Package baz provides a helpful constant.",
+		V1Path:                "foo.com/bar/baz",
+		Licenses:              []*licenses.Metadata{wantLicenseMD},
+		IsRedistributable:     true,
+		DocumentationCoverage: &wantCoverage,
+		GOOS:                  "linux",
+		GOARCH:                "amd64",
+		Symbols:               []string{"OK"},
+		ImportedSymbols:       map[string][]string{"net/http": {"StatusOK"}},
 	}
 	wantModuleInfo = internal.ModuleInfo{
 		ModulePath:        "foo.com/bar",
@@ -292,3 +298,28 @@ func TestDataSource_GetPathInfo(t *testing.T) {
 		}
 	}
 }
+
+func TestDataSource_GetUnitMeta(t *testing.T) {
+	ctx, ds, teardown := setup(t)
+	defer teardown()
+	want := &internal.UnitMeta{
+		DirectoryMeta: internal.DirectoryMeta{
+			Path:              "foo.com/bar/baz",
+			V1Path:            "foo.com/bar/baz",
+			IsRedistributable: true,
+			Licenses:          []*licenses.Metadata{wantLicenseMD},
+		},
+		ModuleInfo: wantModuleInfo,
+		Name:       "baz",
+	}
+	got, err := ds.GetUnitMeta(ctx, "foo.com/bar/baz", "foo.com/bar", "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got, cmpOpts...); diff != "" {
+		t.Errorf("GetUnitMeta diff (-want +got):\n%s", diff)
+	}
+	if _, err := ds.GetUnitMeta(ctx, "foo.com/bar/nope", "foo.com/bar", "v1.2.0"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("GetUnitMeta for a missing path: got %v, want %v", err, derrors.NotFound)
+	}
+}
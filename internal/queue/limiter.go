@@ -0,0 +1,164 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"sync"
+)
+
+// A Limiter limits the number of tasks that a queue processes at once. Its
+// limit can be changed while the queue runs, for instance by a controller
+// that adjusts it to the load of the process.
+//
+// A queue that processes its own tasks runs a fixed number of workers, each
+// of which holds a slot of the Limiter while it takes and processes a task.
+// Fetches that do not come from a queue, like those of the worker's /fetch
+// handler, can share the Limiter with TryAcquire.
+type Limiter struct {
+	workers int
+
+	mu     sync.Mutex
+	limit  int
+	held   int           // slots held, by workers with or without a task
+	active int           // tasks being processed
+	peak   int           // the most tasks processed at once since the last TakePeak
+	freed  chan struct{} // closed when a slot may have become available
+}
+
+// NewLimiter returns a Limiter for a queue with the given number of workers.
+// Its limit starts at the number of workers.
+func NewLimiter(workers int) *Limiter {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Limiter{
+		workers: workers,
+		limit:   workers,
+		freed:   make(chan struct{}),
+	}
+}
+
+// Workers returns the number of workers of l, which bounds its limit.
+func (l *Limiter) Workers() int {
+	return l.workers
+}
+
+// Limit returns the number of tasks that may be processed at once.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// SetLimit sets the number of tasks that may be processed at once, between 1
+// and the number of workers. Tasks in progress are not interrupted if the
+// limit is lowered below their number.
+func (l *Limiter) SetLimit(n int) {
+	if n < 1 {
+		n = 1
+	}
+	if n > l.workers {
+		n = l.workers
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n > l.limit {
+		l.wake()
+	}
+	l.limit = n
+}
+
+// TakePeak returns the most tasks that were processed at once since the last
+// call to TakePeak, and starts measuring again from the tasks in progress.
+func (l *Limiter) TakePeak() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	p := l.peak
+	l.peak = l.active
+	return p
+}
+
+// TryAcquire starts a task if the limit allows it. If it returns true, the
+// caller must call the returned function once the task is done.
+func (l *Limiter) TryAcquire() (done func(), ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held >= l.limit {
+		return nil, false
+	}
+	l.held++
+	l.start()
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.finish()
+		l.release()
+	}, true
+}
+
+// acquire waits for a slot for a worker. It returns false if ctx is done
+// first. Otherwise the caller must call release once it is done with the
+// slot.
+func (l *Limiter) acquire(ctx context.Context) bool {
+	for {
+		l.mu.Lock()
+		if l.held < l.limit {
+			l.held++
+			l.mu.Unlock()
+			return true
+		}
+		freed := l.freed
+		l.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-freed:
+		}
+	}
+}
+
+// startTask records the start of a task by a worker that holds a slot. The
+// caller must call the returned function once the task is done.
+func (l *Limiter) startTask() (done func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.start()
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.finish()
+	}
+}
+
+// releaseSlot releases a slot obtained with acquire.
+func (l *Limiter) releaseSlot() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.release()
+}
+
+// The following methods must be called with l.mu held.
+
+func (l *Limiter) start() {
+	l.active++
+	if l.active > l.peak {
+		l.peak = l.active
+	}
+}
+
+func (l *Limiter) finish() {
+	l.active--
+}
+
+func (l *Limiter) release() {
+	l.held--
+	l.wake()
+}
+
+func (l *Limiter) wake() {
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
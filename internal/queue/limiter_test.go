@@ -0,0 +1,105 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterTryAcquire(t *testing.T) {
+	l := NewLimiter(3)
+	l.SetLimit(2)
+	acquire := func() func() {
+		t.Helper()
+		done, ok := l.TryAcquire()
+		if !ok {
+			t.Fatalf("TryAcquire with limit %d: got false, want true", l.Limit())
+		}
+		return done
+	}
+	reject := func() {
+		t.Helper()
+		if _, ok := l.TryAcquire(); ok {
+			t.Fatalf("TryAcquire with limit %d: got true, want false", l.Limit())
+		}
+	}
+
+	done1 := acquire()
+	done2 := acquire()
+	reject()
+	l.SetLimit(3)
+	done3 := acquire()
+	// Lowering the limit does not interrupt tasks, but keeps new ones from
+	// starting until enough are done.
+	l.SetLimit(1)
+	done1()
+	done2()
+	reject()
+	done3()
+	acquire()()
+
+	if got, want := l.TakePeak(), 3; got != want {
+		t.Errorf("TakePeak() = %d, want %d", got, want)
+	}
+	if got, want := l.TakePeak(), 0; got != want {
+		t.Errorf("second TakePeak() = %d, want %d", got, want)
+	}
+}
+
+func TestLimiterSetLimitBounds(t *testing.T) {
+	l := NewLimiter(4)
+	for _, test := range []struct {
+		n, want int
+	}{
+		{2, 2},
+		{0, 1},
+		{-1, 1},
+		{4, 4},
+		{10, 4},
+	} {
+		l.SetLimit(test.n)
+		if got := l.Limit(); got != test.want {
+			t.Errorf("SetLimit(%d): Limit() = %d, want %d", test.n, got, test.want)
+		}
+	}
+}
+
+func TestLimiterAcquireWaits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	l := NewLimiter(2)
+	l.SetLimit(1)
+	if !l.acquire(ctx) {
+		t.Fatal("acquire: got false, want true")
+	}
+	acquired := make(chan bool)
+	go func() { acquired <- l.acquire(ctx) }()
+	select {
+	case <-acquired:
+		t.Fatal("acquire returned while the limit was reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Raising the limit lets the waiting worker proceed.
+	l.SetLimit(2)
+	if !<-acquired {
+		t.Fatal("acquire after raising the limit: got false, want true")
+	}
+
+	// So does releasing a slot.
+	go func() { acquired <- l.acquire(ctx) }()
+	l.releaseSlot()
+	if !<-acquired {
+		t.Fatal("acquire after releasing a slot: got false, want true")
+	}
+
+	cctx, ccancel := context.WithCancel(ctx)
+	ccancel()
+	if l.acquire(cctx) {
+		t.Error("acquire with canceled context: got true, want false")
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/api/trace"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+const (
+	// postgresLease is how long a worker of a Postgres queue holds a task
+	// before other workers may take it. It is longer than the time a fetch
+	// may take.
+	postgresLease = 2 * fetchTimeout
+
+	// postgresPollInterval is how long a worker of a Postgres queue waits
+	// before looking for tasks again when the queue is empty.
+	postgresPollInterval = 5 * time.Second
+
+	// maxAttempts is the number of times a queue that processes its own
+	// tasks tries a fetch that does not finish before it gives up.
+	maxAttempts = 5
+)
+
+// Postgres is a Queue implementation backed by the fetch_queue table of the
+// database, for deployments that run outside of Google Cloud. Any number of
+// processes can share the queue; each task is processed by one of them. A
+// task that is not finished because its worker stopped is processed again
+// once its lease expires, as is a task whose fetch did not finish, up to
+// maxAttempts times. Unlike the GCP task queue, the queue does not
+// de-duplicate tasks with the key of a task that was already processed.
+type Postgres struct {
+	db      *postgres.DB
+	fetcher *fetcher
+}
+
+// NewPostgres returns a Postgres queue that stores its tasks in db. It runs
+// the workers of limiter, which process the tasks with processFunc. With a nil
+// limiter, the queue only schedules tasks, for other processes to run.
+func NewPostgres(ctx context.Context, db *postgres.DB, limiter *Limiter, processFunc ProcessFunc, experiments *experiment.Set) *Postgres {
+	q := &Postgres{
+		db: db,
+		fetcher: &fetcher{
+			process:     processFunc,
+			experiments: experiments,
+			limiter:     limiter,
+		},
+	}
+	if limiter == nil {
+		return q
+	}
+	for i := 0; i < limiter.Workers(); i++ {
+		go q.work(ctx)
+	}
+	return q
+}
+
+// ScheduleFetch adds a task to fetch modulePath at version to the queue. Like
+// GCP, it de-duplicates tasks that are scheduled within taskIDChangeInterval
+// of each other, unless their suffixes differ.
+func (q *Postgres) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (err error) {
+	defer derrors.Wrap(&err, "queue.Postgres.ScheduleFetch(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	key := newTaskID(modulePath, version, time.Now(), taskIDChangeInterval)
+	if suffix != "" {
+		key += "-" + suffix
+	}
+	return q.add(ctx, modulePath, version, key)
+}
+
+// ScheduleFetchWithKey adds a task to fetch modulePath at version to the
+// queue, unless a task with the same key is waiting to be processed.
+func (q *Postgres) ScheduleFetchWithKey(ctx context.Context, modulePath, version, key string) (err error) {
+	defer derrors.Wrap(&err, "queue.Postgres.ScheduleFetchWithKey(%q, %q, %q)", modulePath, version, key)
+	return q.add(ctx, modulePath, version, key)
+}
+
+func (q *Postgres) add(ctx context.Context, modulePath, version, key string) error {
+	if !internal.IsServed(modulePath) {
+		log.Infof(ctx, "not scheduling %s@%s because it is not under a served prefix", modulePath, version)
+		return nil
+	}
+	return q.db.EnqueueFetch(ctx, modulePath, version, key)
+}
+
+// work processes tasks until ctx is done. It looks for a task only when the
+// limiter allows another one to be processed.
+func (q *Postgres) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		if !q.fetcher.limiter.acquire(ctx) {
+			return
+		}
+		found := q.workOne(ctx)
+		q.fetcher.limiter.releaseSlot()
+		if !found {
+			sleep(ctx, postgresPollInterval)
+		}
+	}
+}
+
+// workOne processes a task, if there is one, and reports whether there was.
+func (q *Postgres) workOne(ctx context.Context) bool {
+	e, err := q.db.LeaseFetch(ctx, postgresLease)
+	if err != nil {
+		log.Error(ctx, err)
+	}
+	if e == nil {
+		return false
+	}
+	log.Infof(ctx, "Fetch requested: %q %q (attempt %d)", e.ModulePath, e.Version, e.Attempts)
+	status := q.fetcher.fetch(ctx, e.ModulePath, e.Version, trace.SpanContext{})
+	if !isTerminal(status) {
+		if e.Attempts < maxAttempts {
+			// Leave the task in the queue, to be taken again once its
+			// lease expires.
+			return true
+		}
+		log.Errorf(ctx, "giving up on %s@%s after %d attempts", e.ModulePath, e.Version, e.Attempts)
+	}
+	if err := q.db.DeleteFetch(ctx, e.ID); err != nil {
+		log.Error(ctx, err)
+	}
+	return true
+}
+
+// sleep waits for d, or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
+}
@@ -10,17 +10,19 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	cloudtasks "cloud.google.com/go/cloudtasks/apiv2"
+	"go.opentelemetry.io/otel/api/trace"
+	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/log"
-	"golang.org/x/pkgsite/internal/postgres"
-	"golang.org/x/pkgsite/internal/proxy"
-	"golang.org/x/pkgsite/internal/source"
 	taskspb "google.golang.org/genproto/googleapis/cloud/tasks/v2"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -29,6 +31,10 @@ import (
 // A Queue provides an interface for asynchronous scheduling of fetch actions.
 type Queue interface {
 	ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error
+	// ScheduleFetchWithKey is like ScheduleFetch, but uses key to
+	// de-duplicate tasks instead of a key derived from the current time.
+	// Scheduling the same key more than once results in at most one fetch.
+	ScheduleFetchWithKey(ctx context.Context, modulePath, version, key string) error
 }
 
 // GCP provides a Queue implementation backed by the Google Cloud Tasks
@@ -58,10 +64,40 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 	defer derrors.Wrap(&err, "queue.ScheduleFetch(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	taskID := newTaskID(modulePath, version, time.Now(), taskIDChangeInterval)
+	// If suffix is non-empty, append it to the task name. This lets us force reprocessing
+	// of tasks that would normally be de-duplicated.
+	if suffix != "" {
+		taskID += "-" + suffix
+	}
+	return q.createTask(ctx, modulePath, version, taskID)
+}
+
+// ScheduleFetchWithKey enqueues a task on GCP to fetch the given modulePath
+// and version, using key as the task ID. Cloud Tasks rejects a task whose ID
+// was used recently, so scheduling the same key again has no effect.
+func (q *GCP) ScheduleFetchWithKey(ctx context.Context, modulePath, version, key string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	defer derrors.Wrap(&err, "queue.ScheduleFetchWithKey(%q, %q, %q)", modulePath, version, key)
+	return q.createTask(ctx, modulePath, version, key)
+}
+
+// createTask creates a task with the given ID to fetch modulePath@version,
+// unless modulePath is not served by this deployment. A task that already
+// exists is not an error. The task carries the trace
+// context of ctx, so that the fetch is traced as part of the same trace.
+func (q *GCP) createTask(ctx context.Context, modulePath, version, taskID string) (err error) {
+	ctx, span := dtrace.StartSpanKind(ctx, "queue.createTask", trace.SpanKindProducer,
+		dtrace.ModulePath(modulePath), dtrace.Version(version))
+	defer dtrace.End(span, &err)
+	if !internal.IsServed(modulePath) {
+		log.Infof(ctx, "not scheduling %s@%s because it is not under a served prefix", modulePath, version)
+		return nil
+	}
 	queueName := fmt.Sprintf("projects/%s/locations/%s/queues/%s", q.cfg.ProjectID, q.cfg.LocationID, q.queueID)
 	mod := fmt.Sprintf("%s/@v/%s", modulePath, version)
 	u := fmt.Sprintf("/fetch/" + mod)
-	taskID := newTaskID(modulePath, version, time.Now(), taskIDChangeInterval)
 	req := &taskspb.CreateTaskRequest{
 		Parent: queueName,
 		Task: &taskspb.Task{
@@ -70,6 +106,7 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 				AppEngineHttpRequest: &taskspb.AppEngineHttpRequest{
 					HttpMethod:  taskspb.HttpMethod_POST,
 					RelativeUri: u,
+					Headers:     dtrace.TaskHeaders(ctx),
 					AppEngineRouting: &taskspb.AppEngineRouting{
 						Service: os.Getenv("GAE_SERVICE"),
 					},
@@ -77,12 +114,6 @@ func (q *GCP) ScheduleFetch(ctx context.Context, modulePath, version, suffix str
 			},
 		},
 	}
-	// If suffix is non-empty, append it to the task name. This lets us force reprocessing
-	// of tasks that would normally be de-duplicated.
-	if suffix != "" {
-		req.Task.Name += "-" + suffix
-	}
-
 	if _, err := q.client.CreateTask(ctx, req); err != nil {
 		if status.Code(err) == codes.AlreadyExists {
 			log.Infof(ctx, "ignoring duplicate task ID %s: %q", taskID, mod)
@@ -106,8 +137,57 @@ func newTaskID(modulePath, version string, now time.Time, taskIDChangeInterval t
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(modulePath+"@"+version+"-"+t.String())))
 }
 
+// A ProcessFunc fetches modulePath at version and records the result. It
+// returns the HTTP status of the fetch. As with the worker's /fetch handler, a
+// status of 500 or 503 means that the fetch did not finish, and may succeed if
+// it is tried again.
+type ProcessFunc func(ctx context.Context, modulePath, version string) (int, error)
+
+// isTerminal reports whether a fetch that returned the given status is done,
+// so that its task need not be processed again.
+func isTerminal(status int) bool {
+	return status != http.StatusInternalServerError && status != http.StatusServiceUnavailable
+}
+
+// fetchTimeout is how long a queue that processes its own tasks allows each
+// fetch to take.
+const fetchTimeout = 5 * time.Minute
+
+// A fetcher processes the tasks of the queues that run fetches in-process,
+// instead of handing them to the worker over HTTP like GCP does.
+type fetcher struct {
+	process     ProcessFunc
+	experiments *experiment.Set
+	limiter     *Limiter
+}
+
+// fetch processes the task to fetch modulePath at version, in a span that is
+// a child of spanContext, the span that scheduled it, if it is valid, and
+// returns the status of the fetch. The caller must hold a slot of the
+// limiter.
+func (f *fetcher) fetch(ctx context.Context, modulePath, version string, spanContext trace.SpanContext) int {
+	done := f.limiter.startTask()
+	defer done()
+
+	fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	fetchCtx = experiment.NewContext(fetchCtx, f.experiments)
+	defer cancel()
+	fetchCtx = trace.ContextWithRemoteSpanContext(fetchCtx, spanContext)
+	fetchCtx, span := dtrace.StartSpanKind(fetchCtx, "queue.process", trace.SpanKindConsumer,
+		dtrace.ModulePath(modulePath), dtrace.Version(version))
+	defer span.End()
+
+	status, err := f.process(fetchCtx, modulePath, version)
+	if err != nil {
+		log.Error(fetchCtx, err)
+	}
+	return status
+}
+
 type moduleVersion struct {
 	modulePath, version string
+	// spanContext is the span that scheduled the fetch.
+	spanContext trace.SpanContext
 }
 
 // InMemory is a Queue implementation that schedules in-process fetch
@@ -116,57 +196,47 @@ type moduleVersion struct {
 //
 // This should only be used for local development.
 type InMemory struct {
-	proxyClient  *proxy.Client
-	sourceClient *source.Client
-	db           *postgres.DB
+	fetcher *fetcher
 
-	queue           chan moduleVersion
-	sem             chan struct{}
-	experiments     *experiment.Set
-	appVersionLabel string
+	queue chan moduleVersion
+
+	mu         sync.Mutex
+	keys       map[string]time.Time // keys passed to ScheduleFetchWithKey, with their expiry
+	lastPruned time.Time
 }
 
-// NewInMemory creates a new InMemory that asynchronously fetches
-// from proxyClient and stores in db. It uses workerCount parallelism to
-// execute these fetches.
-func NewInMemory(ctx context.Context, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, workerCount int,
-	processFunc func(context.Context, string, string, *proxy.Client, *source.Client, *postgres.DB, string) (int, error), experiments *experiment.Set, appVersionLabel string) *InMemory {
+// NewInMemory creates a new InMemory that asynchronously processes its tasks
+// with processFunc. It executes as many of these fetches at once as limiter
+// allows.
+func NewInMemory(ctx context.Context, limiter *Limiter, processFunc ProcessFunc, experiments *experiment.Set) *InMemory {
 	q := &InMemory{
-		proxyClient:     proxyClient,
-		sourceClient:    sourceClient,
-		db:              db,
-		queue:           make(chan moduleVersion, 1000),
-		sem:             make(chan struct{}, workerCount),
-		experiments:     experiments,
-		appVersionLabel: appVersionLabel,
+		fetcher: &fetcher{
+			process:     processFunc,
+			experiments: experiments,
+			limiter:     limiter,
+		},
+		queue: make(chan moduleVersion, 1000),
+		keys:  map[string]time.Time{},
 	}
-	go q.process(ctx, processFunc)
+	go q.process(ctx)
 	return q
 }
 
-func (q *InMemory) process(ctx context.Context, processFunc func(context.Context, string, string, *proxy.Client, *source.Client, *postgres.DB, string) (int, error)) {
+func (q *InMemory) process(ctx context.Context) {
 
+	limiter := q.fetcher.limiter
 	for v := range q.queue {
-		select {
-		case <-ctx.Done():
+		if !limiter.acquire(ctx) {
 			return
-		case q.sem <- struct{}{}:
 		}
 
 		// If a worker is available, make a request to the fetch service inside a
 		// goroutine and wait for it to finish.
 		go func(v moduleVersion) {
-			defer func() { <-q.sem }()
-
-			log.Infof(ctx, "Fetch requested: %q %q (workerCount = %d)", v.modulePath, v.version, cap(q.sem))
+			defer limiter.releaseSlot()
 
-			fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-			fetchCtx = experiment.NewContext(fetchCtx, q.experiments)
-			defer cancel()
-
-			if _, err := processFunc(fetchCtx, v.modulePath, v.version, q.proxyClient, q.sourceClient, q.db, q.appVersionLabel); err != nil {
-				log.Error(fetchCtx, err)
-			}
+			log.Infof(ctx, "Fetch requested: %q %q (limit = %d)", v.modulePath, v.version, limiter.Limit())
+			q.fetcher.fetch(ctx, v.modulePath, v.version, v.spanContext)
 		}(v)
 	}
 }
@@ -174,18 +244,64 @@ func (q *InMemory) process(ctx context.Context, processFunc func(context.Context
 // ScheduleFetch pushes a fetch task into the local queue to be processed
 // asynchronously.
 func (q *InMemory) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) error {
-	q.queue <- moduleVersion{modulePath, version}
+	q.enqueue(ctx, modulePath, version)
+	return nil
+}
+
+// ScheduleFetchWithKey pushes a fetch task into the local queue, unless a
+// task with the same key was scheduled within the last redisKeyTTL, like the
+// other queues.
+func (q *InMemory) ScheduleFetchWithKey(ctx context.Context, modulePath, version, key string) error {
+	if q.seenKey(key, time.Now()) {
+		log.Infof(ctx, "ignoring duplicate task key %s: %s@%s", key, modulePath, version)
+		return nil
+	}
+	q.enqueue(ctx, modulePath, version)
 	return nil
 }
 
+// seenKey reports whether key was passed to ScheduleFetchWithKey less than
+// redisKeyTTL before now, and records it otherwise. It forgets expired keys
+// once per redisKeyTTL, so that the keys of the last two periods at most are
+// kept.
+func (q *InMemory) seenKey(key string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if now.Sub(q.lastPruned) >= redisKeyTTL {
+		for k, exp := range q.keys {
+			if !now.Before(exp) {
+				delete(q.keys, k)
+			}
+		}
+		q.lastPruned = now
+	}
+	if exp, ok := q.keys[key]; ok && now.Before(exp) {
+		return true
+	}
+	q.keys[key] = now.Add(redisKeyTTL)
+	return false
+}
+
+// enqueue pushes a fetch task into the local queue, in a span that is the
+// parent of the span that processes it. Modules that this deployment does not
+// serve are skipped.
+func (q *InMemory) enqueue(ctx context.Context, modulePath, version string) {
+	if !internal.IsServed(modulePath) {
+		log.Infof(ctx, "not scheduling %s@%s because it is not under a served prefix", modulePath, version)
+		return
+	}
+	_, span := dtrace.StartSpanKind(ctx, "queue.enqueue", trace.SpanKindProducer,
+		dtrace.ModulePath(modulePath), dtrace.Version(version))
+	defer span.End()
+	q.queue <- moduleVersion{modulePath, version, span.SpanContext()}
+}
+
 // WaitForTesting waits for all queued requests to finish. It should only be
 // used by test code.
-func (q InMemory) WaitForTesting(ctx context.Context) {
-	for i := 0; i < cap(q.sem); i++ {
-		select {
-		case <-ctx.Done():
+func (q *InMemory) WaitForTesting(ctx context.Context) {
+	for i := 0; i < q.fetcher.limiter.Limit(); i++ {
+		if !q.fetcher.limiter.acquire(ctx) {
 			return
-		case q.sem <- struct{}{}:
 		}
 	}
 	close(q.queue)
@@ -5,8 +5,12 @@
 package queue
 
 import (
+	"context"
+	"reflect"
 	"testing"
 	"time"
+
+	"golang.org/x/pkgsite/internal"
 )
 
 func TestNewTaskID(t *testing.T) {
@@ -28,3 +32,135 @@ func TestNewTaskID(t *testing.T) {
 		t.Error("wanted different task ID, got same")
 	}
 }
+
+func TestInMemoryScheduleFetchWithKey(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fetches := make(chan string, 10)
+	q := NewInMemory(ctx, NewLimiter(1), sendFetches(fetches), nil)
+	for _, key := range []string{"k1", "k1", "k2"} {
+		if err := q.ScheduleFetchWithKey(ctx, "m.com", "v1.0.0", key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"m.com@v1.0.0", "m.com@v1.0.0"}
+	if got := receiveFetches(ctx, t, q, fetches, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got fetches %v, want %v", got, want)
+	}
+}
+
+// sendFetches returns a ProcessFunc that sends the module version of each
+// fetch on fetches.
+func sendFetches(fetches chan<- string) ProcessFunc {
+	return func(ctx context.Context, modulePath, version string) (int, error) {
+		fetches <- modulePath + "@" + version
+		return 200, nil
+	}
+}
+
+// receiveFetches waits for n fetches from the process function of q, which
+// sends them on fetches, then waits for q to finish and returns them along
+// with any other fetches it made.
+func receiveFetches(ctx context.Context, t *testing.T, q *InMemory, fetches chan string, n int) []string {
+	t.Helper()
+	var got []string
+	for len(got) < n {
+		select {
+		case f := <-fetches:
+			got = append(got, f)
+		case <-ctx.Done():
+			t.Fatalf("got fetches %v, then %v", got, ctx.Err())
+		}
+	}
+	q.WaitForTesting(ctx)
+	for len(fetches) > 0 {
+		got = append(got, <-fetches)
+	}
+	return got
+}
+
+func TestInMemorySeenKey(t *testing.T) {
+	q := &InMemory{keys: map[string]time.Time{}}
+	now := time.Now()
+	for _, test := range []struct {
+		key   string
+		after time.Duration
+		want  bool
+	}{
+		{"k1", 0, false},
+		{"k1", time.Minute, true},
+		{"k2", time.Minute, false},
+		{"k1", redisKeyTTL, false},
+		{"k1", redisKeyTTL + time.Minute, true},
+	} {
+		if got := q.seenKey(test.key, now.Add(test.after)); got != test.want {
+			t.Errorf("seenKey(%q) after %s = %t, want %t", test.key, test.after, got, test.want)
+		}
+	}
+	// Expired keys are forgotten.
+	q.seenKey("k3", now.Add(3*redisKeyTTL))
+	if got, want := len(q.keys), 1; got != want {
+		t.Errorf("got %d keys, want %d", got, want)
+	}
+}
+
+func TestInMemorySkipsUnservedModules(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	internal.SetServedPrefixes([]string{"corp.example.com/"})
+	defer internal.SetServedPrefixes(nil)
+
+	fetches := make(chan string, 10)
+	q := NewInMemory(ctx, NewLimiter(1), sendFetches(fetches), nil)
+	for _, modulePath := range []string{"corp.example.com/m", "github.com/a/m"} {
+		if err := q.ScheduleFetch(ctx, modulePath, "v1.0.0", "", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.ScheduleFetchWithKey(ctx, "github.com/b/m", "v1.0.0", "k"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"corp.example.com/m@v1.0.0"}
+	if got := receiveFetches(ctx, t, q, fetches, len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got fetches %v, want %v", got, want)
+	}
+}
+
+func TestScheduleFetchSkipsUnserved(t *testing.T) {
+	ctx := context.Background()
+	internal.SetServedPrefixes([]string{"corp.example.com/"})
+	defer internal.SetServedPrefixes(nil)
+
+	// The queues have no database or Redis client, so they would panic if
+	// they tried to add the tasks.
+	pq := NewPostgres(ctx, nil, nil, nil, nil)
+	rq, err := NewRedis(ctx, nil, "test", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range []Queue{pq, rq} {
+		if err := q.ScheduleFetch(ctx, "github.com/a/m", "v1.0.0", "", time.Hour); err != nil {
+			t.Errorf("%T.ScheduleFetch: %v", q, err)
+		}
+		if err := q.ScheduleFetchWithKey(ctx, "github.com/a/m", "v1.0.0", "k"); err != nil {
+			t.Errorf("%T.ScheduleFetchWithKey: %v", q, err)
+		}
+	}
+}
+
+func TestTaskValues(t *testing.T) {
+	modulePath, version, attempts, err := parseTaskValues(taskValues("m.com", "v1.2.3", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modulePath != "m.com" || version != "v1.2.3" || attempts != 2 {
+		t.Errorf("got %q, %q, %d, want %q, %q, 2", modulePath, version, attempts, "m.com", "v1.2.3")
+	}
+	if _, _, attempts, err := parseTaskValues(map[string]interface{}{"module_path": "m.com", "version": "v1.2.3"}); err != nil || attempts != 0 {
+		t.Errorf("task without attempts: got %d, %v, want 0, nil", attempts, err)
+	}
+	if _, _, _, err := parseTaskValues(map[string]interface{}{"module_path": "m.com"}); err == nil {
+		t.Error("got nil error for a task without a version")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"go.opentelemetry.io/otel/api/trace"
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/experiment"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+const (
+	// redisStream is the Redis stream that holds the tasks of a Redis queue.
+	redisStream = "fetch-queue"
+
+	// redisGroup is the consumer group of the workers of a Redis queue.
+	redisGroup = "workers"
+
+	// redisKeyPrefix prefixes the Redis keys that de-duplicate tasks.
+	redisKeyPrefix = "fetch-queue-key:"
+
+	// redisKeyTTL is how long the key of a task prevents another task with
+	// the same key from being scheduled. It matches how long Cloud Tasks
+	// keeps the IDs of tasks.
+	redisKeyTTL = time.Hour
+
+	// redisBlock is how long a worker of a Redis queue waits for a task
+	// before asking again.
+	redisBlock = 5 * time.Second
+)
+
+// Redis is a Queue implementation backed by a Redis stream, for deployments
+// that run outside of Google Cloud. Any number of processes can share the
+// queue; each task is delivered to one of the workers of the consumer group.
+// A worker that restarts with the same consumer name first processes the
+// tasks it had received but not finished. A task whose fetch did not finish
+// is added to the stream again, up to maxAttempts times.
+type Redis struct {
+	client   *redis.Client
+	consumer string
+	fetcher  *fetcher
+}
+
+// NewRedis returns a Redis queue that stores its tasks in client. It runs the
+// workers of limiter, which process the tasks with processFunc, as members of
+// the consumer group under the given name, which should be unique to the
+// process. With a nil limiter, the queue only schedules tasks, for other
+// processes to run.
+func NewRedis(ctx context.Context, client *redis.Client, consumer string, limiter *Limiter,
+	processFunc ProcessFunc, experiments *experiment.Set) (_ *Redis, err error) {
+	defer derrors.Wrap(&err, "queue.NewRedis(ctx, client, %q)", consumer)
+
+	q := &Redis{
+		client:   client,
+		consumer: consumer,
+		fetcher: &fetcher{
+			process:     processFunc,
+			experiments: experiments,
+			limiter:     limiter,
+		},
+	}
+	if limiter == nil {
+		return q, nil
+	}
+	if err := client.XGroupCreateMkStream(redisStream, redisGroup, "0").Err(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+	for i := 0; i < limiter.Workers(); i++ {
+		go q.work(ctx, fmt.Sprintf("%s-%d", consumer, i))
+	}
+	return q, nil
+}
+
+// ScheduleFetch adds a task to fetch modulePath at version to the stream.
+// Like GCP, it de-duplicates tasks that are scheduled within
+// taskIDChangeInterval of each other, unless their suffixes differ.
+func (q *Redis) ScheduleFetch(ctx context.Context, modulePath, version, suffix string, taskIDChangeInterval time.Duration) (err error) {
+	defer derrors.Wrap(&err, "queue.Redis.ScheduleFetch(%q, %q, %q, %d)", modulePath, version, suffix, taskIDChangeInterval)
+	key := newTaskID(modulePath, version, time.Now(), taskIDChangeInterval)
+	if suffix != "" {
+		key += "-" + suffix
+	}
+	return q.add(ctx, modulePath, version, key)
+}
+
+// ScheduleFetchWithKey adds a task to fetch modulePath at version to the
+// stream, unless a task with the same key was scheduled recently.
+func (q *Redis) ScheduleFetchWithKey(ctx context.Context, modulePath, version, key string) (err error) {
+	defer derrors.Wrap(&err, "queue.Redis.ScheduleFetchWithKey(%q, %q, %q)", modulePath, version, key)
+	return q.add(ctx, modulePath, version, key)
+}
+
+func (q *Redis) add(ctx context.Context, modulePath, version, key string) error {
+	if !internal.IsServed(modulePath) {
+		log.Infof(ctx, "not scheduling %s@%s because it is not under a served prefix", modulePath, version)
+		return nil
+	}
+	client := q.client.WithContext(ctx)
+	added, err := client.SetNX(redisKeyPrefix+key, modulePath+"@"+version, redisKeyTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !added {
+		log.Infof(ctx, "ignoring duplicate task key %s: %s@%s", key, modulePath, version)
+		return nil
+	}
+	return client.XAdd(&redis.XAddArgs{
+		Stream: redisStream,
+		Values: taskValues(modulePath, version, 0),
+	}).Err()
+}
+
+// work processes tasks as the given consumer until ctx is done. It starts
+// with the tasks that were delivered to the consumer but not acknowledged. It
+// reads a task only when the limiter allows another one to be processed.
+func (q *Redis) work(ctx context.Context, consumer string) {
+	client := q.client.WithContext(ctx)
+	// "0" reads the pending tasks of the consumer, ">" new ones.
+	id := "0"
+	for ctx.Err() == nil {
+		if !q.fetcher.limiter.acquire(ctx) {
+			return
+		}
+		var err error
+		id, err = q.workOne(ctx, client, consumer, id)
+		q.fetcher.limiter.releaseSlot()
+		if err != nil {
+			log.Error(ctx, err)
+			sleep(ctx, redisBlock)
+		}
+	}
+}
+
+// workOne reads a task of consumer from the stream, starting at id, and
+// processes it if there is one. It returns the id to read from next.
+func (q *Redis) workOne(ctx context.Context, client *redis.Client, consumer, id string) (string, error) {
+	streams, err := client.XReadGroup(&redis.XReadGroupArgs{
+		Group:    redisGroup,
+		Consumer: consumer,
+		Streams:  []string{redisStream, id},
+		Count:    1,
+		Block:    redisBlock,
+	}).Result()
+	if err != nil && err != redis.Nil {
+		return id, err
+	}
+	var msgs []redis.XMessage
+	for _, s := range streams {
+		msgs = append(msgs, s.Messages...)
+	}
+	if len(msgs) == 0 {
+		return ">", nil
+	}
+	for _, msg := range msgs {
+		if modulePath, version, attempts, err := parseTaskValues(msg.Values); err != nil {
+			log.Errorf(ctx, "dropping task %s: %v", msg.ID, err)
+		} else {
+			attempts++
+			log.Infof(ctx, "Fetch requested: %q %q (attempt %d)", modulePath, version, attempts)
+			status := q.fetcher.fetch(ctx, modulePath, version, trace.SpanContext{})
+			if !isTerminal(status) {
+				q.retry(ctx, client, modulePath, version, attempts)
+			}
+		}
+		if err := client.XAck(redisStream, redisGroup, msg.ID).Err(); err != nil {
+			log.Error(ctx, err)
+		}
+		if err := client.XDel(redisStream, msg.ID).Err(); err != nil {
+			log.Error(ctx, err)
+		}
+	}
+	return id, nil
+}
+
+// retry adds the task to fetch modulePath at version back to the stream,
+// after a fetch that did not finish, unless it has been tried maxAttempts
+// times.
+func (q *Redis) retry(ctx context.Context, client *redis.Client, modulePath, version string, attempts int) {
+	if attempts >= maxAttempts {
+		log.Errorf(ctx, "giving up on %s@%s after %d attempts", modulePath, version, attempts)
+		return
+	}
+	if err := client.XAdd(&redis.XAddArgs{
+		Stream: redisStream,
+		Values: taskValues(modulePath, version, attempts),
+	}).Err(); err != nil {
+		log.Error(ctx, err)
+	}
+}
+
+// taskValues returns the fields of the stream entry of the task to fetch
+// modulePath at version, which has been tried the given number of times.
+func taskValues(modulePath, version string, attempts int) map[string]interface{} {
+	return map[string]interface{}{
+		"module_path": modulePath,
+		"version":     version,
+		"attempts":    strconv.Itoa(attempts),
+	}
+}
+
+// parseTaskValues returns the module path, version and number of attempts of
+// the task with the given stream entry fields. Entries added before attempts
+// were recorded have none.
+func parseTaskValues(values map[string]interface{}) (modulePath, version string, attempts int, err error) {
+	modulePath, _ = values["module_path"].(string)
+	version, _ = values["version"].(string)
+	if modulePath == "" || version == "" {
+		return "", "", 0, fmt.Errorf("missing module path or version in %v", values)
+	}
+	if a, ok := values["attempts"].(string); ok {
+		attempts, err = strconv.Atoi(a)
+		if err != nil {
+			return "", "", 0, fmt.Errorf("bad attempts in %v: %v", values, err)
+		}
+	}
+	return modulePath, version, attempts, nil
+}
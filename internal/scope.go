@@ -0,0 +1,54 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/pkgsite/internal/stdlib"
+)
+
+var (
+	servedMu       sync.Mutex
+	servedPrefixes []string
+)
+
+// SetServedPrefixes restricts the module and package paths that this
+// deployment fetches, indexes and serves to those under prefixes, like
+// "corp.example.com/". A path is under a prefix if it is the prefix, without
+// any trailing slash, or if it starts with the prefix followed by a path
+// element. The standard library is served only if prefixes includes
+// stdlib.ModulePath. If prefixes is empty, every path is served.
+func SetServedPrefixes(prefixes []string) {
+	var ps []string
+	for _, p := range prefixes {
+		if p = strings.TrimSuffix(strings.TrimSpace(p), "/"); p != "" {
+			ps = append(ps, p)
+		}
+	}
+	servedMu.Lock()
+	defer servedMu.Unlock()
+	servedPrefixes = ps
+}
+
+// IsServed reports whether path, which may be a module or package path, is
+// under one of the prefixes set by SetServedPrefixes.
+func IsServed(path string) bool {
+	servedMu.Lock()
+	defer servedMu.Unlock()
+	if len(servedPrefixes) == 0 {
+		return true
+	}
+	for _, p := range servedPrefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+		if p == stdlib.ModulePath && stdlib.Contains(path) {
+			return true
+		}
+	}
+	return false
+}
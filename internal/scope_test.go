@@ -0,0 +1,36 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "testing"
+
+func TestIsServed(t *testing.T) {
+	defer SetServedPrefixes(nil)
+
+	for _, test := range []struct {
+		prefixes []string
+		path     string
+		want     bool
+	}{
+		{nil, "github.com/a/b", true},
+		{nil, "net/http", true},
+		{[]string{"corp.example.com/"}, "corp.example.com", true},
+		{[]string{"corp.example.com/"}, "corp.example.com/team/pkg", true},
+		{[]string{"corp.example.com"}, "corp.example.com/team/pkg", true},
+		{[]string{"corp.example.com/"}, "corp.example.community/pkg", false},
+		{[]string{"corp.example.com/"}, "github.com/a/b", false},
+		{[]string{"corp.example.com/team/"}, "corp.example.com/other", false},
+		{[]string{"corp.example.com/", " git.corp.example.com "}, "git.corp.example.com/x", true},
+		{[]string{"corp.example.com/"}, "net/http", false},
+		{[]string{"corp.example.com/", "std"}, "net/http", true},
+		{[]string{"corp.example.com/", "std"}, "std", true},
+		{[]string{"corp/"}, "corp/pkg", true},
+	} {
+		SetServedPrefixes(test.prefixes)
+		if got := IsServed(test.path); got != test.want {
+			t.Errorf("with prefixes %q, IsServed(%q) = %t, want %t", test.prefixes, test.path, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package snapshot reads and writes snapshots: portable archives of module
+// versions that can be copied from one discovery database to another, for
+// instance to serve a mirror that has no access to a module proxy.
+//
+// A snapshot is a gzip-compressed stream of JSON values. The first is a
+// header that records the format of the snapshot; each of the others is an
+// internal.Module.
+package snapshot
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// Format is the version of the snapshot format that this package writes.
+// It must be incremented whenever a change to internal.Module makes older
+// snapshots unreadable.
+const Format = 1
+
+// A Header describes a snapshot.
+type Header struct {
+	Format  int
+	Created time.Time
+}
+
+// A Writer writes a snapshot.
+type Writer struct {
+	zw  *gzip.Writer
+	enc *json.Encoder
+}
+
+// NewWriter returns a Writer that writes a snapshot to w, starting with its
+// header. The caller must call Close when done.
+func NewWriter(w io.Writer) (_ *Writer, err error) {
+	defer derrors.Wrap(&err, "snapshot.NewWriter")
+
+	zw := gzip.NewWriter(w)
+	sw := &Writer{zw: zw, enc: json.NewEncoder(zw)}
+	if err := sw.enc.Encode(&Header{Format: Format, Created: time.Now().UTC()}); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// Write adds m to the snapshot.
+func (w *Writer) Write(m *internal.Module) (err error) {
+	defer derrors.Wrap(&err, "snapshot.Writer.Write(Module(%q, %q))", m.ModulePath, m.Version)
+	return w.enc.Encode(m)
+}
+
+// Close flushes the snapshot. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	return w.zw.Close()
+}
+
+// A Reader reads the modules of a snapshot.
+type Reader struct {
+	Header Header
+	dec    *json.Decoder
+}
+
+// NewReader returns a Reader for the snapshot in r, after reading its header.
+// It returns an error if the snapshot was written in a format that this
+// package does not know.
+func NewReader(r io.Reader) (_ *Reader, err error) {
+	defer derrors.Wrap(&err, "snapshot.NewReader")
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	sr := &Reader{dec: json.NewDecoder(zr)}
+	if err := sr.dec.Decode(&sr.Header); err != nil {
+		return nil, fmt.Errorf("reading header: %v", err)
+	}
+	if sr.Header.Format != Format {
+		return nil, fmt.Errorf("unsupported snapshot format %d, want %d", sr.Header.Format, Format)
+	}
+	return sr, nil
+}
+
+// Next returns the next module of the snapshot, or io.EOF when there are no
+// more.
+func (r *Reader) Next() (*internal.Module, error) {
+	var m internal.Module
+	if err := r.dec.Decode(&m); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("snapshot.Reader.Next: %v", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/ghodss/yaml"
+)
+
+// A Host describes how to build source links for the repos on a code
+// hosting site that this package does not otherwise recognize, such as a
+// self-hosted GitLab, Gitea or Gogs instance.
+//
+// Hosts are read from YAML like this:
+//
+//	# A self-hosted GitLab.
+//	- host: git.corp.example.com
+//	  kind: gitlab
+//	# A site with its own URL scheme.
+//	- host: code.example.org
+//	  directory: "{repo}/browse/{dir}?at={commit}"
+//	  file: "{repo}/browse/{file}?at={commit}"
+//	  line: "{repo}/browse/{file}?at={commit}#{line}"
+//	  raw: "{repo}/raw/{file}?at={commit}"
+//	  commit: "{repo}/commits/{commit}"
+type Host struct {
+	// Host is the host name, with an optional port, of the site.
+	Host string `json:"host"`
+	// Kind is the software the site runs: one of "github", "gitlab",
+	// "bitbucket", "gitea" or "gogs". If Kind is set, the URL templates
+	// must be empty.
+	Kind string `json:"kind"`
+	// Directory, File, Line, Raw, Commit and Tag are URL templates, used
+	// when Kind is empty. See urlTemplates for the variables they may use.
+	// Raw, Commit and Tag are optional.
+	Directory string `json:"directory"`
+	File      string `json:"file"`
+	Line      string `json:"line"`
+	Raw       string `json:"raw"`
+	Commit    string `json:"commit"`
+	Tag       string `json:"tag"`
+}
+
+// ParseHosts parses a list of Hosts from YAML.
+func ParseHosts(data []byte) ([]*Host, error) {
+	var hosts []*Host
+	if err := yaml.Unmarshal(data, &hosts); err != nil {
+		return nil, fmt.Errorf("parsing source hosts: %v", err)
+	}
+	for i, h := range hosts {
+		if h.Host == "" || strings.Contains(h.Host, "/") {
+			return nil, fmt.Errorf("source host %d: bad host %q", i, h.Host)
+		}
+		if _, err := h.templates(); err != nil {
+			return nil, fmt.Errorf("source host %d (%s): %v", i, h.Host, err)
+		}
+	}
+	return hosts, nil
+}
+
+// ReadHostsFile reads a list of Hosts in YAML from filename.
+func ReadHostsFile(filename string) ([]*Host, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseHosts(data)
+}
+
+func (h *Host) templates() (urlTemplates, error) {
+	custom := urlTemplates{
+		Directory: h.Directory,
+		File:      h.File,
+		Line:      h.Line,
+		Raw:       h.Raw,
+		Commit:    h.Commit,
+		Tag:       h.Tag,
+	}
+	if h.Kind != "" {
+		if custom != (urlTemplates{}) {
+			return urlTemplates{}, fmt.Errorf("both kind and URL templates are set")
+		}
+		kind := h.Kind
+		if kind == "gogs" {
+			// Gitea is a fork of Gogs, and has kept its URL layout.
+			kind = "gitea"
+		}
+		t, ok := urlTemplatesByKind[kind]
+		if !ok {
+			return urlTemplates{}, fmt.Errorf("unknown kind %q", h.Kind)
+		}
+		return t, nil
+	}
+	if custom.Directory == "" || custom.File == "" || custom.Line == "" {
+		return urlTemplates{}, fmt.Errorf("need a kind, or directory, file and line URL templates")
+	}
+	for _, t := range []string{custom.Directory, custom.File, custom.Line, custom.Raw, custom.Commit, custom.Tag} {
+		if t != "" && !strings.HasPrefix(t, "{repo}") && !strings.Contains(t, "{repoPath}") {
+			return urlTemplates{}, fmt.Errorf("URL template %q does not use {repo} or {repoPath}", t)
+		}
+	}
+	return custom, nil
+}
+
+var (
+	hostsMu         sync.Mutex
+	configuredHosts = map[string]urlTemplates{}
+)
+
+// SetHosts sets the hosts used by ModuleInfo, in addition to the sites it
+// recognizes itself. A host in hosts takes precedence over any other way of
+// determining the URL templates for its repos.
+func SetHosts(hosts []*Host) error {
+	m := map[string]urlTemplates{}
+	for _, h := range hosts {
+		t, err := h.templates()
+		if err != nil {
+			return fmt.Errorf("source host %s: %v", h.Host, err)
+		}
+		m[strings.ToLower(h.Host)] = t
+	}
+	hostsMu.Lock()
+	defer hostsMu.Unlock()
+	configuredHosts = m
+	return nil
+}
+
+// configuredTemplates returns the URL templates of the configured host of
+// repo, which has no scheme. It returns false if the host is not configured.
+func configuredTemplates(repo string) (urlTemplates, bool) {
+	hostsMu.Lock()
+	defer hostsMu.Unlock()
+	t, ok := configuredHosts[strings.ToLower(pathHost(repo))]
+	return t, ok
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseHosts(t *testing.T) {
+	hosts, err := ParseHosts([]byte(`
+- host: git.corp.example.com
+  kind: gitlab
+- host: code.example.org:8443
+  directory: "{repo}/browse/{dir}?at={commit}"
+  file: "{repo}/browse/{file}?at={commit}"
+  line: "{repo}/browse/{file}?at={commit}#{line}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SetHosts(hosts); err != nil {
+		t.Fatal(err)
+	}
+	defer SetHosts(nil)
+
+	for _, test := range []struct {
+		repo   string
+		want   urlTemplates
+		wantOK bool
+	}{
+		{"git.corp.example.com/a/b", gitlabURLTemplates, true},
+		{"GIT.corp.example.com/a/b", gitlabURLTemplates, true},
+		{"code.example.org:8443/a", urlTemplates{
+			Directory: "{repo}/browse/{dir}?at={commit}",
+			File:      "{repo}/browse/{file}?at={commit}",
+			Line:      "{repo}/browse/{file}?at={commit}#{line}",
+		}, true},
+		{"code.example.org/a", urlTemplates{}, false},
+	} {
+		got, ok := configuredTemplates(test.repo)
+		if ok != test.wantOK {
+			t.Errorf("%s: ok = %t, want %t", test.repo, ok, test.wantOK)
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("%s: mismatch (-want +got):\n%s", test.repo, diff)
+		}
+	}
+}
+
+func TestParseHostsErrors(t *testing.T) {
+	for _, in := range []string{
+		`- kind: gitlab`,
+		`- {host: example.com/a, kind: gitlab}`,
+		`- {host: example.com, kind: cvsweb}`,
+		`- {host: example.com, kind: gitlab, file: "{repo}/{file}"}`,
+		`- {host: example.com, directory: "{repo}/{dir}"}`,
+		`- {host: example.com, directory: "https://example.com/{dir}", file: "{repo}/{file}", line: "{repo}/{file}#{line}"}`,
+	} {
+		if _, err := ParseHosts([]byte(in)); err == nil {
+			t.Errorf("ParseHosts(%q) succeeded, want error", in)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/time/rate"
+)
+
+// Statuses of a repository, as reported by its hosting site.
+const (
+	// RepoActive means the repo exists and accepts changes.
+	RepoActive = "active"
+	// RepoArchived means the repo is read-only.
+	RepoArchived = "archived"
+	// RepoDeleted means the hosting site does not know the repo, or it is
+	// private.
+	RepoDeleted = "deleted"
+	// RepoUnknown means the status could not be determined, because the
+	// hosting site has no API that this package knows about.
+	RepoUnknown = "unknown"
+)
+
+// ErrRateLimited is returned by RepoStatusChecker.Check when the hosting site
+// refuses a request because too many have been made.
+var ErrRateLimited = errors.New("rate limited")
+
+// A RepoStatusChecker asks code hosting sites whether repos are archived or
+// deleted. It makes at most qps requests per second to each site.
+type RepoStatusChecker struct {
+	client      *Client
+	githubToken string
+	qps         float64
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter // by API host
+}
+
+// NewRepoStatusChecker returns a RepoStatusChecker that uses client. If
+// githubToken is non-empty, it is used to authenticate with the GitHub API,
+// which has a much higher rate limit for authenticated requests.
+func NewRepoStatusChecker(client *Client, githubToken string, qps float64) *RepoStatusChecker {
+	return &RepoStatusChecker{
+		client:      client,
+		githubToken: githubToken,
+		qps:         qps,
+		limiters:    map[string]*rate.Limiter{},
+	}
+}
+
+// Check returns the status of the repo at repoURL, which should be the value of
+// Info.RepoURL. It supports repos on GitHub, GitLab and Gitea, including
+// self-hosted instances whose URL layout is known, and returns RepoUnknown for
+// others.
+func (c *RepoStatusChecker) Check(ctx context.Context, repoURL string) (_ string, err error) {
+	defer derrors.Wrap(&err, "RepoStatusChecker.Check(ctx, %q)", repoURL)
+
+	apiURL, header := c.apiRequest(repoURL)
+	if apiURL == "" {
+		return RepoUnknown, nil
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil {
+		return "", err
+	}
+	if err := c.limiter(u.Host).Wait(ctx); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", apiURL, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := ctxhttp.Do(ctx, c.client.httpClient, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone, http.StatusUnavailableForLegalReasons:
+		return RepoDeleted, nil
+	case http.StatusTooManyRequests:
+		return "", fmt.Errorf("%s: %w", resp.Status, ErrRateLimited)
+	case http.StatusForbidden:
+		// GitHub uses 403 when the rate limit is exceeded.
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			return "", fmt.Errorf("%s: %w", resp.Status, ErrRateLimited)
+		}
+		return "", fmt.Errorf("%s returned %s", apiURL, resp.Status)
+	default:
+		return "", fmt.Errorf("%s returned %s", apiURL, resp.Status)
+	}
+	// GitHub, GitLab and Gitea all report an "archived" field.
+	var r struct {
+		Archived bool `json:"archived"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return "", fmt.Errorf("decoding response from %s: %v", apiURL, err)
+	}
+	if r.Archived {
+		return RepoArchived, nil
+	}
+	return RepoActive, nil
+}
+
+// apiRequest returns the URL of the API endpoint that describes the repo at
+// repoURL, and the headers to send with it. It returns "" if there is none.
+func (c *RepoStatusChecker) apiRequest(repoURL string) (string, map[string]string) {
+	if !strings.HasPrefix(repoURL, "https://") {
+		return "", nil
+	}
+	repo := strings.TrimSuffix(removeHTTPScheme(repoURL), ".git")
+	host := pathHost(repo)
+	repoPath := strings.TrimPrefix(repo, host+"/")
+	if repoPath == repo || strings.Count(repoPath, "/") < 1 {
+		return "", nil
+	}
+	templates, ok := configuredTemplates(repo)
+	if !ok {
+		_, _, templates, _ = matchStatic(repo)
+	}
+	switch {
+	case host == "gitee.com":
+		// Gitee looks like GitLab, but its API is different.
+		return "", nil
+	case host == "github.com":
+		h := map[string]string{"Accept": "application/vnd.github.v3+json"}
+		if c.githubToken != "" {
+			h["Authorization"] = "token " + c.githubToken
+		}
+		return "https://api.github.com/repos/" + repoPath, h
+	case templates == gitlabURLTemplates:
+		// GitLab identifies a project by its URL-encoded path.
+		return "https://" + host + "/api/v4/projects/" + url.PathEscape(repoPath), nil
+	case templates == giteaURLTemplates:
+		return "https://" + host + "/api/v1/repos/" + repoPath, nil
+	}
+	return "", nil
+}
+
+func (c *RepoStatusChecker) limiter(host string) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := c.limiters[host]
+	if l == nil {
+		l = rate.NewLimiter(rate.Limit(c.qps), 1)
+		c.limiters[host] = l
+	}
+	return l
+}
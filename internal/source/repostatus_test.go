@@ -0,0 +1,55 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package source
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestRepoStatusChecker(t *testing.T) {
+	client := &Client{
+		httpClient: &http.Client{
+			Transport: testTransport(map[string]string{
+				"https://api.github.com/repos/alice/active":                `{"archived": false}`,
+				"https://api.github.com/repos/alice/archived":              `{"archived": true}`,
+				"https://gitlab.com/api/v4/projects/bob%2Farchived":        `{"archived": true}`,
+				"https://codeberg.org/api/v1/repos/carol/active":           `{"archived": false}`,
+				"https://gitea.example.com/api/v1/repos/carol/archived":    `{"archived": true}`,
+				"https://git.corp.example.com/api/v4/projects/dave%2Fbusy": `{"archived": false}`,
+			}),
+			Timeout: testTimeout,
+		},
+	}
+	if err := SetHosts([]*Host{{Host: "git.corp.example.com", Kind: "gitlab"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetHosts(nil)
+
+	c := NewRepoStatusChecker(client, "", 1000)
+	for _, test := range []struct {
+		repoURL, want string
+	}{
+		{"https://github.com/alice/active", RepoActive},
+		{"https://github.com/alice/archived", RepoArchived},
+		{"https://github.com/alice/gone", RepoDeleted},
+		{"https://gitlab.com/bob/archived", RepoArchived},
+		{"https://codeberg.org/carol/active", RepoActive},
+		{"https://gitea.example.com/carol/archived.git", RepoArchived},
+		{"https://git.corp.example.com/dave/busy", RepoActive},
+		{"https://go.googlesource.com/tools", RepoUnknown},
+		{"https://gitee.com/erin/pkg", RepoUnknown},
+		{"http://github.com/alice/active", RepoUnknown},
+	} {
+		got, err := c.Check(context.Background(), test.repoURL)
+		if err != nil {
+			t.Fatalf("%s: %v", test.repoURL, err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.repoURL, got, test.want)
+		}
+	}
+}
@@ -34,9 +34,9 @@ import (
 	"time"
 
 	"go.opencensus.io/plugin/ochttp"
-	"go.opencensus.io/trace"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/stdlib"
 	"golang.org/x/pkgsite/internal/version"
@@ -49,6 +49,10 @@ type Info struct {
 	moduleDir string       // directory of module relative to repo root
 	commit    string       // tag or ID of commit corresponding to version
 	templates urlTemplates // for building URLs
+	// vanityRoot is the import path prefix that the go-import meta tag
+	// mapped to repoURL, if repoURL is on a different host than the module
+	// path, as for k8s.io/client-go or gopkg.in/yaml.v2.
+	vanityRoot string
 }
 
 func (i *Info) RepoURL() string {
@@ -58,6 +62,15 @@ func (i *Info) RepoURL() string {
 	return i.repoURL
 }
 
+// VanityRoot returns the vanity import path prefix that resolves to the
+// module's repo, or "" if the module path is not a vanity path.
+func (i *Info) VanityRoot() string {
+	if i == nil {
+		return ""
+	}
+	return i.vanityRoot
+}
+
 // ModuleURL returns a URL for the home page of the module.
 func (i *Info) ModuleURL() string {
 	return i.DirectoryURL("")
@@ -100,6 +113,41 @@ func (i *Info) LineURL(pathname string, line int) string {
 	})
 }
 
+// Commit returns the tag or the ID of the commit in the repo that corresponds
+// to the module's version. It is a commit ID for pseudo-versions, and a tag
+// otherwise.
+func (i *Info) Commit() string {
+	if i == nil {
+		return ""
+	}
+	return i.commit
+}
+
+// CommitURL returns a URL for the commit of a pseudo-version, or "" if the
+// repo's host is not known to have commit pages.
+func (i *Info) CommitURL() string {
+	if i == nil {
+		return ""
+	}
+	return expand(i.templates.Commit, map[string]string{
+		"repo":   i.repoURL,
+		"commit": i.commit,
+	})
+}
+
+// TagURL returns a URL for the tag of a version that is not a pseudo-version,
+// or "" if the repo's host is not known to have tag pages. Where the host has
+// releases, it links to the release page of the tag, with its release notes.
+func (i *Info) TagURL() string {
+	if i == nil {
+		return ""
+	}
+	return expand(i.templates.Tag, map[string]string{
+		"repo":   i.repoURL,
+		"commit": i.commit,
+	})
+}
+
 // RawURL returns a URL referring to the raw contents of a file relative to the
 // module's home directory. In addition to the usual variables, it supports
 // {repoPath}, which is the repo URL's path.
@@ -141,6 +189,7 @@ var urlTemplatesByKind = map[string]urlTemplates{
 	"github":    githubURLTemplates,
 	"gitlab":    gitlabURLTemplates,
 	"bitbucket": bitbucketURLTemplates,
+	"gitea":     giteaURLTemplates,
 }
 
 // jsonInfo is a Go struct describing the JSON structure of an INFO.
@@ -150,8 +199,9 @@ type jsonInfo struct {
 	Commit    string
 	// Store common templates efficiently by setting this to a short string
 	// we look up in a map. If Kind != "", then Templates == nil.
-	Kind      string        `json:",omitempty"`
-	Templates *urlTemplates `json:",omitempty"`
+	Kind       string        `json:",omitempty"`
+	Templates  *urlTemplates `json:",omitempty"`
+	VanityRoot string        `json:",omitempty"`
 }
 
 // ToJSONForDB returns the Info encoded for storage in the database.
@@ -159,9 +209,10 @@ func (i *Info) MarshalJSON() (_ []byte, err error) {
 	defer derrors.Wrap(&err, "MarshalJSON")
 
 	ji := &jsonInfo{
-		RepoURL:   i.repoURL,
-		ModuleDir: i.moduleDir,
-		Commit:    i.commit,
+		RepoURL:    i.repoURL,
+		ModuleDir:  i.moduleDir,
+		Commit:     i.commit,
+		VanityRoot: i.vanityRoot,
 	}
 	// Store common templates efficiently, by name.
 	for kind, templs := range urlTemplatesByKind {
@@ -186,6 +237,7 @@ func (i *Info) UnmarshalJSON(data []byte) (err error) {
 	i.repoURL = ji.RepoURL
 	i.moduleDir = ji.ModuleDir
 	i.commit = ji.Commit
+	i.vanityRoot = ji.VanityRoot
 	if ji.Kind != "" {
 		i.templates = urlTemplatesByKind[ji.Kind]
 	} else if ji.Templates != nil {
@@ -203,7 +255,7 @@ type Client struct {
 func NewClient(timeout time.Duration) *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Transport: &ochttp.Transport{},
+			Transport: dtrace.Transport(&ochttp.Transport{}),
 			Timeout:   timeout,
 		},
 	}
@@ -240,8 +292,8 @@ func (c *Client) doURL(ctx context.Context, method, url string, only200 bool) (_
 // LegacyModuleInfo may fetch from arbitrary URLs, so it can be slow.
 func ModuleInfo(ctx context.Context, client *Client, modulePath, version string) (info *Info, err error) {
 	defer derrors.Wrap(&err, "source.LegacyModuleInfo(ctx, %q, %q)", modulePath, version)
-	ctx, span := trace.StartSpan(ctx, "source.LegacyModuleInfo")
-	defer span.End()
+	ctx, span := dtrace.StartSpan(ctx, "source.LegacyModuleInfo", dtrace.ModulePath(modulePath), dtrace.Version(version))
+	defer dtrace.End(span, &err)
 
 	if modulePath == stdlib.ModulePath {
 		commit, err := stdlib.TagForVersion(version)
@@ -262,6 +314,9 @@ func ModuleInfo(ctx context.Context, client *Client, modulePath, version string)
 			return nil, err
 		}
 	} else {
+		if t, ok := configuredTemplates(repo); ok {
+			templates = t
+		}
 		info = &Info{
 			repoURL:   "https://" + repo,
 			moduleDir: relativeModulePath,
@@ -354,28 +409,90 @@ func moduleInfoDynamic(ctx context.Context, client *Client, modulePath, version
 	//    in the URL templates, like "https://github.com/go-yaml/yaml/tree/v2.2.3{/dir}". We can observe
 	//    that that template begins with a known pattern--a GitHub repo, ignore the rest of it, and use the
 	//    GitHub URL templates that we know.
-	// 3. TODO(golang/go#39559): implement go-source-v2 meta tag
+	// 3. If neither matches, look at the layout of the go-source URL templates after the repo URL.
+	//    Self-hosted GitLab, Gitea and Gogs instances can be recognized that way, even though
+	//    their host names say nothing about the software they run.
+	// 4. TODO(golang/go#39559): implement go-source-v2 meta tag
+	//
+	// URL templates configured for the repo's host with SetHosts take precedence over all of these.
 	repoURL := sourceMeta.repoURL
-	_, _, templates, _ := matchStatic(removeHTTPScheme(repoURL))
-	// If err != nil, templates will be the zero value, so we can ignore it (same just below).
+	var templates urlTemplates
+	if t, ok := configuredTemplates(removeHTTPScheme(repoURL)); ok {
+		templates = t
+		repoURL = strings.TrimSuffix(repoURL, ".git")
+	} else {
+		_, _, templates, _ = matchStatic(removeHTTPScheme(repoURL))
+		// If err != nil, templates will be the zero value, so we can ignore it (same just below).
+	}
 	if templates == (urlTemplates{}) {
 		var repo string
 		repo, _, templates, _ = matchStatic(removeHTTPScheme(sourceMeta.dirTemplate))
-		if templates == (urlTemplates{}) {
-			log.Infof(ctx, "no templates for repo URL %q from meta tag: err=%v", sourceMeta.repoURL, err)
-		} else {
+		if templates != (urlTemplates{}) {
 			// Use the repo from the template, not the original one.
 			repoURL = "https://" + repo
 		}
 	}
+	if templates == (urlTemplates{}) {
+		if t := templatesFromLayout(repoURL, sourceMeta.dirTemplate); t != (urlTemplates{}) {
+			templates = t
+			repoURL = strings.TrimSuffix(repoURL, ".git")
+		} else {
+			log.Infof(ctx, "no templates for repo URL %q from meta tag", sourceMeta.repoURL)
+		}
+	}
 	dir := strings.TrimPrefix(strings.TrimPrefix(modulePath, sourceMeta.repoRootPrefix), "/")
 	dir = template.HTMLEscapeString(dir)
-	return &Info{
+	info := &Info{
 		repoURL:   template.HTMLEscapeString(strings.TrimSuffix(repoURL, "/")),
 		moduleDir: dir,
 		commit:    commitFromVersion(version, dir),
 		templates: templates,
-	}, nil
+	}
+	// Links are built from the backing repo; keep the vanity prefix so it
+	// can be shown alongside it.
+	if !strings.EqualFold(pathHost(removeHTTPScheme(repoURL)), pathHost(modulePath)) {
+		info.vanityRoot = template.HTMLEscapeString(sourceMeta.repoRootPrefix)
+	}
+	return info, nil
+}
+
+// pathHost returns the host part of p, an import path or a URL without a
+// scheme.
+func pathHost(p string) string {
+	if i := strings.IndexByte(p, '/'); i >= 0 {
+		return p[:i]
+	}
+	return p
+}
+
+// templatesFromLayout guesses the URL templates for the repo at repoURL from
+// dirTemplate, the directory URL template of its go-source meta tag. It
+// recognizes the URL layouts of GitLab and of Gitea and Gogs, and returns the zero
+// urlTemplates if dirTemplate has neither or does not begin with repoURL.
+func templatesFromLayout(repoURL, dirTemplate string) urlTemplates {
+	repo := strings.TrimSuffix(removeHTTPScheme(repoURL), ".git")
+	dir := removeHTTPScheme(dirTemplate)
+	if repo == "" || !strings.HasPrefix(dir, repo+"/") {
+		return urlTemplates{}
+	}
+	rest := dir[len(repo):]
+	for _, l := range []struct {
+		prefix    string
+		templates urlTemplates
+	}{
+		{"/-/tree/", gitlabURLTemplates},
+		{"/tree/", gitlabURLTemplates},
+		{"/src/branch/", giteaURLTemplates},
+		{"/src/tag/", giteaURLTemplates},
+		{"/src/commit/", giteaURLTemplates},
+		// Gogs, and Gitea before version 1.1.
+		{"/src/", giteaURLTemplates},
+	} {
+		if strings.HasPrefix(rest, l.prefix) {
+			return l.templates
+		}
+	}
+	return urlTemplates{}
 }
 
 // adjustVersionedModuleDirectory changes info.moduleDir if necessary to
@@ -463,6 +580,15 @@ var patterns = []struct {
 		regexp.MustCompile(`^(?P<repo>gitee\.com/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)(\.git|$)`),
 		gitlabURLTemplates,
 	},
+	{
+		regexp.MustCompile(`^(?P<repo>(gitea\.com|codeberg\.org)/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)`),
+		giteaURLTemplates,
+	},
+	{
+		// Assume that any site beginning "gitea." or "gogs." runs that software.
+		regexp.MustCompile(`^(?P<repo>(gitea|gogs)\.[a-z0-9A-Z.-]+/[a-z0-9A-Z_.\-]+/[a-z0-9A-Z_.\-]+)(\.git|$)`),
+		giteaURLTemplates,
+	},
 
 	// Patterns that match the general go command pattern, where they must have
 	// a ".git" repo suffix in an import path. If matching a repo URL from a meta tag,
@@ -474,6 +600,8 @@ var patterns = []struct {
 			File:      "{repo}/+/{commit}/{file}",
 			Line:      "{repo}/+/{commit}/{file}#{line}",
 			// no raw support (b/13912564)
+			Commit: "{repo}/+/{commit}",
+			Tag:    "{repo}/+/{commit}",
 		},
 	},
 	{
@@ -511,6 +639,8 @@ type urlTemplates struct {
 	File      string // URL template for a file, with {repo}, {commit} and {file}
 	Line      string // URL template for a line, with {repo}, {commit}, {file} and {line}
 	Raw       string // URL template for the raw contents of a file, with {repo}, {repoPath}, {commit} and {file}
+	Commit    string `json:",omitempty"` // URL template for a commit, with {repo} and {commit}
+	Tag       string `json:",omitempty"` // URL template for a tag, with {repo} and {commit}, which is the tag
 }
 
 var (
@@ -519,6 +649,8 @@ var (
 		File:      "{repo}/blob/{commit}/{file}",
 		Line:      "{repo}/blob/{commit}/{file}#L{line}",
 		Raw:       "https://raw.githubusercontent.com/{repoPath}/{commit}/{file}",
+		Commit:    "{repo}/commit/{commit}",
+		Tag:       "{repo}/releases/tag/{commit}",
 	}
 
 	gitlabURLTemplates = urlTemplates{
@@ -526,6 +658,8 @@ var (
 		File:      "{repo}/blob/{commit}/{file}",
 		Line:      "{repo}/blob/{commit}/{file}#L{line}",
 		Raw:       "{repo}/raw/{commit}/{file}",
+		Commit:    "{repo}/-/commit/{commit}",
+		Tag:       "{repo}/-/tags/{commit}",
 	}
 
 	bitbucketURLTemplates = urlTemplates{
@@ -533,6 +667,18 @@ var (
 		File:      "{repo}/src/{commit}/{file}",
 		Line:      "{repo}/src/{commit}/{file}#lines-{line}",
 		Raw:       "{repo}/raw/{commit}/{file}",
+		// Bitbucket has no page for a tag.
+		Commit: "{repo}/commits/{commit}",
+	}
+
+	// Gitea and Gogs accept a tag or commit hash after "src" and "raw".
+	giteaURLTemplates = urlTemplates{
+		Directory: "{repo}/src/{commit}/{dir}",
+		File:      "{repo}/src/{commit}/{file}",
+		Line:      "{repo}/src/{commit}/{file}#L{line}",
+		Raw:       "{repo}/raw/{commit}/{file}",
+		Commit:    "{repo}/commit/{commit}",
+		Tag:       "{repo}/releases/tag/{commit}",
 	}
 )
 
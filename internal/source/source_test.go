@@ -337,6 +337,9 @@ func TestMatchStatic(t *testing.T) {
 		{"mercurial.com/repo.hg", "mercurial.com/repo", ""},
 		{"mercurial.com/repo.hg/dir", "mercurial.com/repo", "dir"},
 		{"github.com/a/b/c/>$", "github.com/a/b", "c/&gt;$"},
+		{"codeberg.org/a/b/c", "codeberg.org/a/b", "c"},
+		{"gitea.example.com/a/b.git/c", "gitea.example.com/a/b", "c"},
+		{"gogs.example.com/a/b", "gogs.example.com/a/b", ""},
 	} {
 		t.Run(test.in, func(t *testing.T) {
 			gotRepo, gotSuffix, _, err := matchStatic(test.in)
@@ -359,6 +362,11 @@ func TestModuleInfoDynamic(t *testing.T) {
 			Timeout:   testTimeout,
 		},
 	}
+	if err := SetHosts([]*Host{{Host: "forge.carol.dev", Kind: "gogs"}}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetHosts(nil)
+
 	// The version doesn't figure into the interesting work and we test versions to commits
 	// elsewhere, so use the same version throughout.
 	const version = "v1.2.3"
@@ -369,28 +377,31 @@ func TestModuleInfoDynamic(t *testing.T) {
 		{
 			"alice.org/pkg",
 			&Info{
-				repoURL:   "https://github.com/alice/pkg",
-				moduleDir: "",
-				commit:    "v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://github.com/alice/pkg",
+				moduleDir:  "",
+				commit:     "v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "alice.org/pkg",
 			},
 		},
 		{
 			"alice.org/pkg/sub",
 			&Info{
-				repoURL:   "https://github.com/alice/pkg",
-				moduleDir: "sub",
-				commit:    "sub/v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://github.com/alice/pkg",
+				moduleDir:  "sub",
+				commit:     "sub/v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "alice.org/pkg",
 			},
 		},
 		{
 			"alice.org/pkg/http",
 			&Info{
-				repoURL:   "https://github.com/alice/pkg",
-				moduleDir: "http",
-				commit:    "http/v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://github.com/alice/pkg",
+				moduleDir:  "http",
+				commit:     "http/v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "alice.org/pkg",
 			},
 		},
 		{
@@ -425,6 +436,7 @@ func TestModuleInfoDynamic(t *testing.T) {
 				moduleDir: "",
 				commit:    "v1.2.3",
 				// empty templates
+				vanityRoot: "bob.com/pkg",
 			},
 		},
 		{
@@ -434,6 +446,7 @@ func TestModuleInfoDynamic(t *testing.T) {
 				moduleDir: "sub",
 				commit:    "sub/v1.2.3",
 				// empty templates
+				vanityRoot: "bob.com/pkg",
 			},
 		},
 		{
@@ -441,48 +454,95 @@ func TestModuleInfoDynamic(t *testing.T) {
 			// The go-source tag has a template that is handled incorrectly by godoc; but we
 			// ignore the templates.
 			&Info{
-				repoURL:   "https://github.com/azul3d/examples",
-				moduleDir: "abs",
-				commit:    "abs/v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://github.com/azul3d/examples",
+				moduleDir:  "abs",
+				commit:     "abs/v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "azul3d.org/examples",
 			},
 		},
 		{
 			"myitcv.io/blah2",
 			// Ignore the "mod" vcs type.
 			&Info{
-				repoURL:   "https://github.com/myitcv/x",
-				moduleDir: "",
-				commit:    "v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://github.com/myitcv/x",
+				moduleDir:  "",
+				commit:     "v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "myitcv.io/blah2",
 			},
 		},
 		{
 			"alice.org/pkg/default",
 			&Info{
-				repoURL:   "https://github.com/alice/pkg",
-				moduleDir: "default",
-				commit:    "default/v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://github.com/alice/pkg",
+				moduleDir:  "default",
+				commit:     "default/v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "alice.org/pkg",
 			},
 		},
 		{
 			"bob.com/bad/github",
 			&Info{
-				repoURL:   "https://github.com/bob/bad/&#34;&gt;$",
-				moduleDir: "",
-				commit:    "v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://github.com/bob/bad/&#34;&gt;$",
+				moduleDir:  "",
+				commit:     "v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "bob.com/bad/github",
+			},
+		},
+		{
+			"carol.dev/gitea",
+			// Self-hosted Gitea, recognized from the go-source directory template.
+			&Info{
+				repoURL:    "https://code.carol.dev/carol/gitea",
+				moduleDir:  "",
+				commit:     "v1.2.3",
+				templates:  giteaURLTemplates,
+				vanityRoot: "carol.dev/gitea",
+			},
+		},
+		{
+			"carol.dev/gogs/sub",
+			&Info{
+				repoURL:    "https://code.carol.dev/carol/gogs",
+				moduleDir:  "sub",
+				commit:     "sub/v1.2.3",
+				templates:  giteaURLTemplates,
+				vanityRoot: "carol.dev/gogs",
+			},
+		},
+		{
+			"carol.dev/gitlab",
+			&Info{
+				repoURL:    "https://git.carol.dev/carol/gitlab",
+				moduleDir:  "",
+				commit:     "v1.2.3",
+				templates:  gitlabURLTemplates,
+				vanityRoot: "carol.dev/gitlab",
+			},
+		},
+		{
+			"carol.dev/configured",
+			// Only a go-import tag, but the host is configured.
+			&Info{
+				repoURL:    "https://forge.carol.dev/carol/configured",
+				moduleDir:  "",
+				commit:     "v1.2.3",
+				templates:  giteaURLTemplates,
+				vanityRoot: "carol.dev/configured",
 			},
 		},
 		{
 
 			"bob.com/bad/apache",
 			&Info{
-				repoURL:   "https://git.apache.org/&gt;$",
-				moduleDir: "",
-				commit:    "v1.2.3",
-				templates: githubURLTemplates,
+				repoURL:    "https://git.apache.org/&gt;$",
+				moduleDir:  "",
+				commit:     "v1.2.3",
+				templates:  githubURLTemplates,
+				vanityRoot: "bob.com/bad/apache",
 			},
 		},
 	} {
@@ -652,6 +712,43 @@ func TestCommitFromVersion(t *testing.T) {
 	}
 }
 
+func TestCommitAndTagURL(t *testing.T) {
+	for _, test := range []struct {
+		info                   *Info
+		wantCommit, wantTagURL string
+	}{
+		{
+			nil, "", "",
+		},
+		{
+			&Info{repoURL: "https://github.com/a/b", commit: "3a9541ec9974", templates: githubURLTemplates},
+			"https://github.com/a/b/commit/3a9541ec9974",
+			"https://github.com/a/b/releases/tag/3a9541ec9974",
+		},
+		{
+			&Info{repoURL: "https://gitlab.com/a/b", commit: "foo/v1.2.3", templates: gitlabURLTemplates},
+			"https://gitlab.com/a/b/-/commit/foo/v1.2.3",
+			"https://gitlab.com/a/b/-/tags/foo/v1.2.3",
+		},
+		{
+			&Info{repoURL: "https://bitbucket.org/a/b", commit: "v1.2.3", templates: bitbucketURLTemplates},
+			"https://bitbucket.org/a/b/commits/v1.2.3",
+			"",
+		},
+		{
+			&Info{repoURL: "https://example.com/a/b", commit: "v1.2.3"},
+			"", "",
+		},
+	} {
+		if got := test.info.CommitURL(); got != test.wantCommit {
+			t.Errorf("%v: CommitURL() = %q, want %q", test.info, got, test.wantCommit)
+		}
+		if got := test.info.TagURL(); got != test.wantTagURL {
+			t.Errorf("%v: TagURL() = %q, want %q", test.info, got, test.wantTagURL)
+		}
+	}
+}
+
 type testTransport map[string]string
 
 func (t testTransport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -721,6 +818,17 @@ var testWeb = map[string]string{
 		<head><meta name="go-import" content="bob.com/bad/github git https://github.com/bob/bad/&quot;&gt;$">`,
 	"https://bob.com/bad/apache": `
 		<head><meta name="go-import" content="bob.com/bad/apache git https://git.apache.org/&gt;$">`,
+	// Self-hosted forges whose URL layout is visible in the go-source tag.
+	"https://carol.dev/gitea": `<head>` +
+		`<meta name="go-import" content="carol.dev/gitea git https://code.carol.dev/carol/gitea.git">` +
+		`<meta name="go-source" content="carol.dev/gitea https://code.carol.dev/carol/gitea https://code.carol.dev/carol/gitea/src/branch/main{/dir} https://code.carol.dev/carol/gitea/src/branch/main{/dir}/{file}#L{line}">`,
+	"https://carol.dev/gogs/sub": `<head>` +
+		`<meta name="go-import" content="carol.dev/gogs git https://code.carol.dev/carol/gogs">` +
+		`<meta name="go-source" content="carol.dev/gogs _ https://code.carol.dev/carol/gogs/src/master{/dir} https://code.carol.dev/carol/gogs/src/master{/dir}/{file}#L{line}">`,
+	"https://carol.dev/gitlab": `<head>` +
+		`<meta name="go-import" content="carol.dev/gitlab git https://git.carol.dev/carol/gitlab.git">` +
+		`<meta name="go-source" content="carol.dev/gitlab https://git.carol.dev/carol/gitlab https://git.carol.dev/carol/gitlab/-/tree/master{/dir} https://git.carol.dev/carol/gitlab/-/blob/master{/dir}/{file}#L{line}">`,
+	"https://carol.dev/configured": `<head> <meta name="go-import" content="carol.dev/configured git https://forge.carol.dev/carol/configured.git">`,
 	// Package with go-source meta tag, where {file} appears on the right of '#' in the file field URL template.
 	"https://azul3d.org/examples/abs": `<!DOCTYPE html><html><head>` +
 		`<meta http-equiv="Content-Type" content="text/html; charset=utf-8"/>` +
@@ -758,6 +866,10 @@ func TestJSON(t *testing.T) {
 			&Info{repoURL: "r", moduleDir: "m", commit: "c", templates: urlTemplates{File: "f"}},
 			`{"RepoURL":"r","ModuleDir":"m","Commit":"c","Templates":{"Directory":"","File":"f","Line":"","Raw":""}}`,
 		},
+		{
+			&Info{repoURL: "r", moduleDir: "m", commit: "c", templates: giteaURLTemplates, vanityRoot: "v"},
+			`{"RepoURL":"r","ModuleDir":"m","Commit":"c","Kind":"gitea","VanityRoot":"v"}`,
+		},
 	} {
 		bytes, err := json.Marshal(&test.in)
 		if err != nil {
@@ -23,6 +23,7 @@ import (
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/testing/testhelper"
+	"golang.org/x/pkgsite/internal/version"
 
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v5"
@@ -36,6 +37,14 @@ import (
 // ModulePath is the name of the module for the standard library.
 const ModulePath = "std"
 
+// MasterVersion is the version that denotes the tip of the Go repo: the head
+// of its master branch. It has the same value as internal.MasterVersion.
+//
+// Zip resolves MasterVersion to a pseudo-version of the form
+// v0.0.0-yyyymmddhhmmss-abcdefabcdef. Like betas and release candidates, such
+// versions are never the latest version of the standard library.
+const MasterVersion = "master"
+
 var (
 	// Regexp for matching go tags. The groups are:
 	// 1  the major.minor version
@@ -53,7 +62,13 @@ var (
 //   "go1.2" => "v1.2.0"
 //   "go1.13beta1" => "v1.13.0-beta.1"
 //   "go1.9rc2" => "v1.9.0-rc.2"
+//
+// Tip has no tags, so MasterVersion and the pseudo-versions that it resolves
+// to are returned unchanged.
 func VersionForTag(tag string) string {
+	if tag == MasterVersion || version.IsPseudo(tag) {
+		return tag
+	}
 	// Special cases for go1.
 	if tag == "go1" {
 		return "v1.0.0"
@@ -80,17 +95,26 @@ func VersionForTag(tag string) string {
 // TagForVersion returns the Go standard library repository tag corresponding
 // to semver. The Go tags differ from standard semantic versions in a few ways,
 // such as beginning with "go" instead of "v".
-func TagForVersion(version string) (_ string, err error) {
-	defer derrors.Wrap(&err, "TagForVersion(%q)", version)
+//
+// Tip has no tags, so for MasterVersion it returns the name of the branch,
+// and for a pseudo-version it returns the ID of the commit.
+func TagForVersion(v string) (_ string, err error) {
+	defer derrors.Wrap(&err, "TagForVersion(%q)", v)
 
+	if v == MasterVersion {
+		return v, nil
+	}
+	if version.IsPseudo(v) {
+		return v[strings.LastIndex(v, "-")+1:], nil
+	}
 	// Special case: v1.0.0 => go1.
-	if version == "v1.0.0" {
+	if v == "v1.0.0" {
 		return "go1", nil
 	}
-	if !semver.IsValid(version) {
-		return "", fmt.Errorf("%w: requested version is not a valid semantic version: %q ", derrors.InvalidArgument, version)
+	if !semver.IsValid(v) {
+		return "", fmt.Errorf("%w: requested version is not a valid semantic version: %q ", derrors.InvalidArgument, v)
 	}
-	goVersion := semver.Canonical(version)
+	goVersion := semver.Canonical(v)
 	prerelease := semver.Prerelease(goVersion)
 	versionWithoutPrerelease := strings.TrimSuffix(goVersion, prerelease)
 	patch := strings.TrimPrefix(versionWithoutPrerelease, semver.MajorMinor(goVersion)+".")
@@ -117,11 +141,15 @@ func TagForVersion(version string) (_ string, err error) {
 }
 
 // MajorVersionForVersion returns the Go major version for version.
-// E.g. "v1.13.3" => "go1".
-func MajorVersionForVersion(version string) (_ string, err error) {
-	defer derrors.Wrap(&err, "MajorVersionForVersion(%q)", version)
+// E.g. "v1.13.3" => "go1". For tip, which is not part of any major version
+// yet, it returns MasterVersion.
+func MajorVersionForVersion(v string) (_ string, err error) {
+	defer derrors.Wrap(&err, "MajorVersionForVersion(%q)", v)
 
-	tag, err := TagForVersion(version)
+	if v == MasterVersion || version.IsPseudo(v) {
+		return MasterVersion, nil
+	}
+	tag, err := TagForVersion(v)
 	if err != nil {
 		return "", err
 	}
@@ -135,6 +163,47 @@ func MajorVersionForVersion(version string) (_ string, err error) {
 	return tag[:i], nil
 }
 
+// ReleaseForVersion returns the Go release that version belongs to: the tag
+// of the release whose minor revisions, betas and release candidates share
+// its release notes.
+// E.g. "v1.13.3" => "go1.13", "v1.14.0-rc.1" => "go1.14", "v1.0.2" => "go1".
+// For tip, which is not part of any release yet, it returns MasterVersion.
+func ReleaseForVersion(v string) (_ string, err error) {
+	defer derrors.Wrap(&err, "ReleaseForVersion(%q)", v)
+
+	if v == MasterVersion || version.IsPseudo(v) {
+		return MasterVersion, nil
+	}
+	if !semver.IsValid(v) {
+		return "", fmt.Errorf("%w: requested version is not a valid semantic version: %q ", derrors.InvalidArgument, v)
+	}
+	// Special case: v1.0.x => go1.
+	mm := semver.MajorMinor(v)
+	if mm == "v1.0" {
+		return "go1", nil
+	}
+	return "go" + strings.TrimPrefix(mm, "v"), nil
+}
+
+// ReleaseNotesURL returns the URL of the release notes for version: the page
+// for its release, or for a minor revision, its entry in the release history.
+// It returns the empty string for tip, which has no release notes.
+func ReleaseNotesURL(v string) string {
+	release, err := ReleaseForVersion(v)
+	if err != nil || release == MasterVersion {
+		return ""
+	}
+	if semver.Prerelease(v) == "" && !strings.HasSuffix(semver.Canonical(v), ".0") {
+		return releaseHistoryURL + "#" + release + ".minor"
+	}
+	return releaseNotesURL + release
+}
+
+const (
+	releaseNotesURL   = "https://golang.org/doc/"
+	releaseHistoryURL = "https://golang.org/doc/devel/release.html"
+)
+
 // finalDigitsIndex returns the index of the first digit in the sequence of digits ending s.
 // If s doesn't end in digits, it returns -1.
 func finalDigitsIndex(s string) int {
@@ -165,21 +234,34 @@ var TestCommitTime = time.Date(2019, 9, 4, 1, 2, 3, 0, time.UTC)
 
 // getGoRepo returns a repo object for the Go repo at version.
 func getGoRepo(version string) (_ *git.Repository, err error) {
-	tag, err := TagForVersion(version)
-	if err != nil {
-		return nil, err
+	var ref plumbing.ReferenceName
+	if version == MasterVersion {
+		ref = plumbing.NewBranchReferenceName(MasterVersion)
+	} else {
+		tag, err := TagForVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		ref = plumbing.NewTagReferenceName(tag)
 	}
 	return git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
 		URL:           GoRepoURL,
-		ReferenceName: plumbing.NewTagReferenceName(tag),
+		ReferenceName: ref,
 		SingleBranch:  true,
 		Depth:         1,
 		Tags:          git.NoTags,
 	})
 }
 
+// TestMasterVersion is the version in the testdata directory that is used for
+// MasterVersion when UseTestData is true.
+const TestMasterVersion = "v1.12.5"
+
 // getTestGoRepo gets a Go repo for testing.
 func getTestGoRepo(version string) (_ *git.Repository, err error) {
+	if version == MasterVersion {
+		version = TestMasterVersion
+	}
 	fs := osfs.New(filepath.Join(testhelper.TestDataPath("testdata"), version))
 	repo, err := git.Init(memory.NewStorage(), fs)
 	if err != nil {
@@ -241,92 +323,108 @@ func Versions() (_ []string, err error) {
 }
 
 // Directory returns the directory of the standard library relative to the repo root.
-func Directory(version string) string {
+func Directory(v string) string {
 	// For versions older than v1.4.0-beta.1, the stdlib is in src/pkg.
-	if semver.Compare(version, "v1.4.0-beta.1") == -1 {
+	// Tip is newer than every version.
+	if v != MasterVersion && !version.IsPseudo(v) && semver.Compare(v, "v1.4.0-beta.1") == -1 {
 		return "src/pkg"
 	}
 	return "src"
 }
 
 // Zip creates a module zip representing the entire Go standard library at the
-// given version and returns a reader to it. It also returns the time of the
-// commit for that version. The zip file is in module form, with each path
-// prefixed by ModuleName + "@" + version.
+// given version and returns a reader to it. It also returns the version that
+// requestedVersion resolves to, and the time of the commit for that version.
+// The zip file is in module form, with each path prefixed by ModuleName + "@"
+// + resolvedVersion.
 //
 // Zip reads the standard library at the Go repository tag corresponding to to
-// the given semantic version.
+// the given semantic version. If requestedVersion is MasterVersion, it reads
+// the head of the master branch instead, and resolves it to a pseudo-version
+// for that commit.
 //
 // Zip ignores go.mod files in the standard library, treating it as if it were a
 // single module named "std" at the given version.
-func Zip(version string) (_ *zip.Reader, commitTime time.Time, err error) {
+func Zip(requestedVersion string) (_ *zip.Reader, resolvedVersion string, commitTime time.Time, err error) {
 	// This code taken, with modifications, from
 	// https://github.com/shurcooL/play/blob/master/256/moduleproxy/std/std.go.
-	defer derrors.Wrap(&err, "stdlib.Zip(%q)", version)
+	defer derrors.Wrap(&err, "stdlib.Zip(%q)", requestedVersion)
 
-	knownVersions, err := Versions()
-	if err != nil {
-		return nil, time.Time{}, err
-	}
-	found := false
-	for _, v := range knownVersions {
-		if v == version {
-			found = true
-			break
+	if requestedVersion != MasterVersion {
+		knownVersions, err := Versions()
+		if err != nil {
+			return nil, "", time.Time{}, err
+		}
+		found := false
+		for _, v := range knownVersions {
+			if v == requestedVersion {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, "", time.Time{}, fmt.Errorf("%w: requested version unknown: %q", derrors.InvalidArgument, requestedVersion)
 		}
-	}
-	if !found {
-		return nil, time.Time{}, fmt.Errorf("%w: requested version unknown: %q", derrors.InvalidArgument, version)
 	}
 
 	var repo *git.Repository
 	if UseTestData {
-		repo, err = getTestGoRepo(version)
+		repo, err = getTestGoRepo(requestedVersion)
 	} else {
-		repo, err = getGoRepo(version)
+		repo, err = getGoRepo(requestedVersion)
 	}
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
 	}
 	var buf bytes.Buffer
 	z := zip.NewWriter(&buf)
 	head, err := repo.Head()
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
 	}
 	commit, err := repo.CommitObject(head.Hash())
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
+	}
+	resolvedVersion = requestedVersion
+	if requestedVersion == MasterVersion {
+		resolvedVersion = pseudoVersion(commit.Committer.When, commit.Hash.String())
 	}
 	root, err := repo.TreeObject(commit.TreeHash)
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
 	}
-	prefixPath := ModulePath + "@" + version
+	prefixPath := ModulePath + "@" + resolvedVersion
 	// Add top-level files.
 	if err := addFiles(z, repo, root, prefixPath, false); err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
 	}
 	// Add files from the stdlib directory.
 	libdir := root
-	for _, d := range strings.Split(Directory(version), "/") {
+	for _, d := range strings.Split(Directory(resolvedVersion), "/") {
 		libdir, err = subTree(repo, libdir, d)
 		if err != nil {
-			return nil, time.Time{}, err
+			return nil, "", time.Time{}, err
 		}
 	}
 	if err := addFiles(z, repo, libdir, prefixPath, true); err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
 	}
 	if err := z.Close(); err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
 	}
 	br := bytes.NewReader(buf.Bytes())
 	zr, err := zip.NewReader(br, int64(br.Len()))
 	if err != nil {
-		return nil, time.Time{}, err
+		return nil, "", time.Time{}, err
 	}
-	return zr, commit.Committer.When, nil
+	return zr, resolvedVersion, commit.Committer.When, nil
+}
+
+// pseudoVersion returns the pseudo-version for the commit of tip with the given
+// time and ID.
+func pseudoVersion(t time.Time, commitID string) string {
+	return fmt.Sprintf("v0.0.0-%s-%s", t.UTC().Format("20060102150405"), commitID[:12])
 }
 
 // addFiles adds the files in t to z, using dirpath as the path prefix.
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"golang.org/x/mod/semver"
+	"golang.org/x/pkgsite/internal/version"
 )
 
 func TestTagForVersion(t *testing.T) {
@@ -55,6 +56,16 @@ func TestTagForVersion(t *testing.T) {
 			version: "v1.13.0",
 			want:    "go1.13",
 		},
+		{
+			name:    "master",
+			version: "master",
+			want:    "master",
+		},
+		{
+			name:    "pseudo-version of tip",
+			version: "v0.0.0-20201014123456-0123456789ab",
+			want:    "0123456789ab",
+		},
 		{
 			name:    "bad std semver",
 			version: "v1.x",
@@ -100,6 +111,8 @@ func TestMajorVersionForVersion(t *testing.T) {
 		{"v1.13.3", "go1"},
 		{"v1.9.0-rc.2", "go1"},
 		{"v2.1.3", "go2"},
+		{"master", "master"},
+		{"v0.0.0-20201014123456-0123456789ab", "master"},
 	} {
 		got, err := MajorVersionForVersion(test.in)
 		if (err != nil) != (test.want == "") {
@@ -111,16 +124,62 @@ func TestMajorVersionForVersion(t *testing.T) {
 	}
 }
 
+func TestReleaseForVersion(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string // empty => error
+	}{
+		{"", ""},
+		{"garbage", ""},
+		{"v1.0.0", "go1"},
+		{"v1.0.3", "go1"},
+		{"v1.13.0", "go1.13"},
+		{"v1.13.3", "go1.13"},
+		{"v1.9.0-rc.2", "go1.9"},
+		{"v1.14.0-beta.1", "go1.14"},
+		{"master", "master"},
+		{"v0.0.0-20201014123456-0123456789ab", "master"},
+	} {
+		got, err := ReleaseForVersion(test.in)
+		if (err != nil) != (test.want == "") {
+			t.Errorf("%q: err: got %v, wanted error: %t", test.in, err, test.want == "")
+		}
+		if err == nil && got != test.want {
+			t.Errorf("%q: got %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestReleaseNotesURL(t *testing.T) {
+	for _, test := range []struct {
+		in, want string
+	}{
+		{"v1.0.0", "https://golang.org/doc/go1"},
+		{"v1.13.0", "https://golang.org/doc/go1.13"},
+		{"v1.13.3", "https://golang.org/doc/devel/release.html#go1.13.minor"},
+		{"v1.14.0-rc.1", "https://golang.org/doc/go1.14"},
+		{"v0.0.0-20201014123456-0123456789ab", ""},
+		{"garbage", ""},
+	} {
+		if got := ReleaseNotesURL(test.in); got != test.want {
+			t.Errorf("ReleaseNotesURL(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
 func TestZip(t *testing.T) {
 	UseTestData = true
 	defer func() { UseTestData = false }()
 
 	for _, version := range []string{"v1.12.5", "v1.3.2"} {
 		t.Run(version, func(t *testing.T) {
-			zr, gotTime, err := Zip(version)
+			zr, gotVersion, gotTime, err := Zip(version)
 			if err != nil {
 				t.Fatal(err)
 			}
+			if gotVersion != version {
+				t.Errorf("resolved version: got %s, want %s", gotVersion, version)
+			}
 			if !gotTime.Equal(TestCommitTime) {
 				t.Errorf("commit time: got %s, want %s", gotTime, TestCommitTime)
 			}
@@ -167,6 +226,35 @@ func TestZip(t *testing.T) {
 	}
 }
 
+func TestZipMaster(t *testing.T) {
+	UseTestData = true
+	defer func() { UseTestData = false }()
+
+	zr, gotVersion, gotTime, err := Zip(MasterVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !version.IsPseudo(gotVersion) || !strings.HasPrefix(gotVersion, "v0.0.0-20190904010203-") {
+		t.Errorf("resolved version: got %s, want a pseudo-version for %s", gotVersion, TestCommitTime)
+	}
+	if !gotTime.Equal(TestCommitTime) {
+		t.Errorf("commit time: got %s, want %s", gotTime, TestCommitTime)
+	}
+	wantPrefix := "std@" + gotVersion + "/"
+	found := false
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, wantPrefix) {
+			t.Errorf("filename %q missing prefix %q", f.Name, wantPrefix)
+		}
+		if f.Name == wantPrefix+"errors/errors.go" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("zip missing errors/errors.go")
+	}
+}
+
 func TestVersions(t *testing.T) {
 	UseTestData = true
 	defer func() { UseTestData = false }()
@@ -208,6 +296,8 @@ func TestVersionForTag(t *testing.T) {
 		{"go1.1beta", ""},
 		{"go1.0", ""},
 		{"weekly.2012-02-14", ""},
+		{"master", "master"},
+		{"v0.0.0-20201014123456-0123456789ab", "v0.0.0-20201014123456-0123456789ab"},
 	} {
 		got := VersionForTag(tc.in)
 		if got != tc.want {
@@ -0,0 +1,147 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dbtest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// A Cluster is a temporary Postgres cluster, run from the Postgres server
+// binaries installed on the machine, without a container. Its data lives in
+// a temporary directory that is removed when it stops.
+type Cluster struct {
+	Port  int
+	dir   string
+	pgCtl string
+}
+
+// StartCluster initializes a temporary cluster, whose superuser is postgres,
+// and starts it on a free port of localhost. Durability is traded for speed:
+// the cluster does not sync its writes to disk.
+//
+// The initdb and pg_ctl binaries are looked up in the directory
+// $GO_DISCOVERY_POSTGRES_BIN if it is set, and otherwise in $PATH and in the
+// directories where Debian and Homebrew install them.
+func StartCluster() (_ *Cluster, err error) {
+	initdb, err := findPostgresBinary("initdb")
+	if err != nil {
+		return nil, err
+	}
+	pgCtl, err := findPostgresBinary("pg_ctl")
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "discovery-testdb")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	data := filepath.Join(dir, "data")
+	if out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "-A", "trust",
+		"-E", "UTF8", "--locale=C", "--no-sync").CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("initdb: %v\n%s", err, out)
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	// -F turns off fsync; -k puts the Unix socket in the temporary directory,
+	// so that clusters do not collide with each other or with a system server.
+	opts := fmt.Sprintf("-p %d -h localhost -k %s -F", port, dir)
+	if out, err := exec.Command(pgCtl, "-D", data, "-l", filepath.Join(dir, "postgres.log"),
+		"-o", opts, "-w", "start").CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("pg_ctl start: %v\n%s", err, out)
+	}
+	return &Cluster{Port: port, dir: dir, pgCtl: pgCtl}, nil
+}
+
+// Setenv points the GO_DISCOVERY_DATABASE_TEST_ variables, and so DBConnURI,
+// at c.
+func (c *Cluster) Setenv() {
+	os.Setenv("GO_DISCOVERY_DATABASE_TEST_HOST", "localhost")
+	os.Setenv("GO_DISCOVERY_DATABASE_TEST_PORT", strconv.Itoa(c.Port))
+	os.Setenv("GO_DISCOVERY_DATABASE_TEST_USER", "postgres")
+	os.Setenv("GO_DISCOVERY_DATABASE_TEST_PASSWORD", "")
+}
+
+// Stop stops c without waiting for its clients to disconnect, and removes its
+// data.
+func (c *Cluster) Stop() error {
+	out, err := exec.Command(c.pgCtl, "-D", filepath.Join(c.dir, "data"), "-m", "immediate", "-w", "stop").CombinedOutput()
+	if err != nil {
+		err = fmt.Errorf("pg_ctl stop: %v\n%s", err, out)
+	}
+	if err2 := os.RemoveAll(c.dir); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// StartClusterIfRequested starts a temporary cluster and points DBConnURI at
+// it if GO_DISCOVERY_TESTDB_TEMP is "true", so that tests can run without a
+// Postgres server. It returns a function that stops the cluster, which does
+// nothing if no cluster was started. Errors are fatal, since the tests were
+// asked to run against a database.
+func StartClusterIfRequested() (stop func()) {
+	if os.Getenv("GO_DISCOVERY_TESTDB_TEMP") != "true" {
+		return func() {}
+	}
+	c, err := StartCluster()
+	if err != nil {
+		log.Fatalf("starting a temporary Postgres cluster: %v", err)
+	}
+	c.Setenv()
+	return func() {
+		if err := c.Stop(); err != nil {
+			log.Printf("stopping the temporary Postgres cluster: %v", err)
+		}
+	}
+}
+
+// findPostgresBinary returns the path of the Postgres binary named name.
+func findPostgresBinary(name string) (string, error) {
+	if dir := os.Getenv("GO_DISCOVERY_POSTGRES_BIN"); dir != "" {
+		return exec.LookPath(filepath.Join(dir, name))
+	}
+	if p, err := exec.LookPath(name); err == nil {
+		return p, nil
+	}
+	// Debian does not put the server binaries in $PATH. Prefer the latest
+	// installed version.
+	for _, pattern := range []string{"/usr/lib/postgresql/*/bin", "/usr/local/opt/postgresql*/bin"} {
+		dirs, _ := filepath.Glob(pattern)
+		sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+		for _, dir := range dirs {
+			if p, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+				return p, nil
+			}
+		}
+	}
+	return "", errors.New(name + " not found; install the Postgres server or set GO_DISCOVERY_POSTGRES_BIN")
+}
+
+// freePort returns a TCP port of localhost that is free at the time of the
+// call.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -74,12 +74,15 @@ func TestEndToEndProcessing(t *testing.T) {
 	defer redisHA.Close()
 	redisHAClient := redis.NewClient(&redis.Options{Addr: redisHA.Addr()})
 
-	// TODO: it would be better if InMemory made http requests
-	// back to worker, rather than calling fetch itself.
-	queue := queue.NewInMemory(ctx, proxyClient, source.NewClient(1*time.Second), testDB, 10,
-		worker.FetchAndUpdateState, nil, "test")
+	// The queue processes its tasks through the worker server, as the worker
+	// binary does. No task is scheduled before the server is created.
+	var workerServer *worker.Server
+	process := func(ctx context.Context, modulePath, version string) (int, error) {
+		return workerServer.ProcessFetch(ctx, modulePath, version)
+	}
+	queue := queue.NewInMemory(ctx, queue.NewLimiter(10), process, nil)
 
-	workerServer, err := worker.NewServer(&config.Config{}, worker.ServerConfig{
+	workerServer, err = worker.NewServer(&config.Config{}, worker.ServerConfig{
 		DB:                   testDB,
 		IndexClient:          indexClient,
 		ProxyClient:          proxyClient,
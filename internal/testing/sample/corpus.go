@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sample
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/licenses"
+)
+
+// A CorpusEntry is a named group of modules with one of the shapes that
+// modules take in practice. The modules of an entry are meant to be inserted
+// together, in order.
+type CorpusEntry struct {
+	Name    string
+	Modules []*internal.Module
+}
+
+// Corpus returns the golden-module corpus: entries covering multi-package
+// modules, packages at the module root, licenses in subdirectories, nested
+// modules and directories that are not redistributable. Tests that insert and
+// read back modules can range over it instead of building modules by hand.
+//
+// Every call returns new modules, so callers may modify them.
+func Corpus() []*CorpusEntry {
+	return []*CorpusEntry{
+		{
+			Name:    "single-package",
+			Modules: []*internal.Module{DefaultModule()},
+		},
+		{
+			Name:    "multi-package",
+			Modules: []*internal.Module{Module("example.com/multi", "v1.2.3", "a", "a/b", "c/d")},
+		},
+		{
+			Name:    "root-package",
+			Modules: []*internal.Module{Module("example.com/root", "v1.0.0", "", "sub")},
+		},
+		{
+			Name:    "prerelease",
+			Modules: []*internal.Module{Module("example.com/pre", "v2.0.0-beta.1", "p")},
+		},
+		{
+			Name: "multi-license",
+			Modules: []*internal.Module{
+				AddLicense(Module("example.com/licenses", "v1.0.0", "a", "b", "b/c"),
+					&licenses.License{Metadata: ApacheLicenseMetadata("b/LICENSE"), Contents: []byte("Apache")}),
+			},
+		},
+		{
+			Name: "nested-modules",
+			Modules: []*internal.Module{
+				Module("example.com/nest", "v1.0.0", "a"),
+				Module("example.com/nest/inner", "v1.1.0", "", "c"),
+			},
+		},
+		{
+			Name: "non-redistributable",
+			Modules: []*internal.Module{
+				AddLicense(Module("example.com/mixed", "v1.0.0", "open", "closed", "closed/sub"),
+					&licenses.License{Metadata: UnknownLicenseMetadata("closed/LICENSE"), Contents: []byte("All rights reserved.")}),
+			},
+		},
+	}
+}
+
+// ApacheLicenseMetadata returns the metadata of an Apache-2.0 license file
+// at filePath, relative to the module root.
+func ApacheLicenseMetadata(filePath string) *licenses.Metadata {
+	return &licenses.Metadata{
+		Types:      []string{"Apache-2.0"},
+		Expression: "Apache-2.0",
+		FilePath:   filePath,
+	}
+}
+
+// UnknownLicenseMetadata returns the metadata of a license file at filePath,
+// relative to the module root, whose license is not recognized, and so does
+// not allow redistribution.
+func UnknownLicenseMetadata(filePath string) *licenses.Metadata {
+	return &licenses.Metadata{
+		Types:    []string{"UNKNOWN"},
+		FilePath: filePath,
+	}
+}
+
+// AddLicense adds lic to m, and to the directories and packages of m that
+// are in the directory of its file. Their licenses stay ordered as the
+// database returns them: deepest files first, then by file path. If the
+// license does not allow redistribution, those directories and packages
+// become non-redistributable and lose their synopsis and documentation, as
+// they would when fetched. The directory of the license must be in m.
+func AddLicense(m *internal.Module, lic *licenses.License) *internal.Module {
+	dir := m.ModulePath
+	if d := path.Dir(lic.FilePath); d != "." {
+		dir = path.Join(m.ModulePath, d)
+		if !hasDirectory(m, dir) {
+			panic(fmt.Sprintf("module %q has no directory %q", m.ModulePath, dir))
+		}
+	} else if !licenses.Redistributable(lic.Types) {
+		m.IsRedistributable = false
+		m.LegacyReadmeFilePath = ""
+		m.LegacyReadmeContents = ""
+	}
+	m.Licenses = append(append([]*licenses.License(nil), m.Licenses...), lic)
+	redist := licenses.Redistributable(lic.Types)
+	inDir := func(p string) bool {
+		return p == dir || strings.HasPrefix(p, dir+"/")
+	}
+	for _, d := range m.Directories {
+		if !inDir(d.Path) {
+			continue
+		}
+		d.Licenses = addLicenseMetadata(d.Licenses, lic.Metadata)
+		if !redist {
+			d.IsRedistributable = false
+			d.Readme = nil
+			if d.Package != nil {
+				d.Package.Documentation.Synopsis = ""
+				d.Package.Documentation.HTML = ""
+			}
+		}
+	}
+	for _, p := range m.LegacyPackages {
+		if !inDir(p.Path) {
+			continue
+		}
+		p.Licenses = addLicenseMetadata(p.Licenses, lic.Metadata)
+		if !redist {
+			p.IsRedistributable = false
+			p.Synopsis = ""
+			p.DocumentationHTML = ""
+		}
+	}
+	return m
+}
+
+// addLicenseMetadata returns a new slice with the elements of mds and md,
+// deepest file paths first.
+func addLicenseMetadata(mds []*licenses.Metadata, md *licenses.Metadata) []*licenses.Metadata {
+	mds = append(append([]*licenses.Metadata(nil), mds...), md)
+	sort.SliceStable(mds, func(i, j int) bool {
+		di, dj := strings.Count(mds[i].FilePath, "/"), strings.Count(mds[j].FilePath, "/")
+		if di != dj {
+			return di > dj
+		}
+		return mds[i].FilePath < mds[j].FilePath
+	})
+	return mds
+}
+
+func hasDirectory(m *internal.Module, dirPath string) bool {
+	for _, d := range m.Directories {
+		if d.Path == dirPath {
+			return true
+		}
+	}
+	return false
+}
@@ -31,8 +31,9 @@ var (
 	CommitTime      = NowTruncated()
 	LicenseMetadata = []*licenses.Metadata{
 		{
-			Types:    []string{"MIT"},
-			FilePath: "LICENSE",
+			Types:      []string{"MIT"},
+			Expression: "MIT",
+			FilePath:   "LICENSE",
 			Coverage: licensecheck.Coverage{
 				Percent: 100,
 				Match:   []licensecheck.Match{{Name: "MIT", Type: licensecheck.MIT, Percent: 100}},
@@ -0,0 +1,87 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opencensus.io/plugin/ochttp"
+	"golang.org/x/net/context/ctxhttp"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+// A Client reads a vulnerability database.
+type Client struct {
+	// URL of the database.
+	url string
+
+	// client used for HTTP requests. It is mutable for testing purposes.
+	httpClient *http.Client
+}
+
+// NewClient constructs a *Client for the database at rawurl, which must be an
+// absolute https URL.
+func NewClient(rawurl string) (_ *Client, err error) {
+	defer derrors.Add(&err, "vuln.NewClient(%q)", rawurl)
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse(%q): %v", rawurl, err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be https (got %s)", u.Scheme)
+	}
+	return &Client{url: strings.TrimRight(rawurl, "/"), httpClient: &http.Client{Transport: &ochttp.Transport{}}}, nil
+}
+
+// Index returns the module paths that have advisories, with the times their
+// advisories were last modified.
+func (c *Client) Index(ctx context.Context) (_ map[string]time.Time, err error) {
+	defer derrors.Wrap(&err, "vuln.Client.Index(ctx)")
+
+	var index map[string]time.Time
+	if err := c.get(ctx, "index.json", &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// ByModule returns the advisories for modulePath. It returns a
+// derrors.NotFound error if the module has none.
+func (c *Client) ByModule(ctx context.Context, modulePath string) (_ []*Entry, err error) {
+	defer derrors.Wrap(&err, "vuln.Client.ByModule(ctx, %q)", modulePath)
+
+	var entries []*Entry
+	if err := c.get(ctx, modulePath+".json", &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// get decodes the JSON file at path in the database into v.
+func (c *Client) get(ctx context.Context, path string, v interface{}) error {
+	u := c.url + "/" + path
+	r, err := ctxhttp.Get(ctx, c.httpClient, u)
+	if err != nil {
+		return fmt.Errorf("ctxhttp.Get(ctx, nil, %q): %v", u, err)
+	}
+	defer r.Body.Close()
+	switch {
+	case r.StatusCode == http.StatusNotFound:
+		return fmt.Errorf("%q: %w", u, derrors.NotFound)
+	case r.StatusCode != http.StatusOK:
+		return fmt.Errorf("%q: %s", u, r.Status)
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding JSON from %q: %v", u, err)
+	}
+	return nil
+}
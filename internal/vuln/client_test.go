@@ -0,0 +1,73 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/derrors"
+)
+
+func TestClient(t *testing.T) {
+	modified := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	files := map[string]string{
+		"/index.json":           `{"example.com/mod": "2020-10-01T00:00:00Z"}`,
+		"/example.com/mod.json": `[{"id": "GO-2020-0001", "modified": "2020-10-01T00:00:00Z", "details": "bad", "affected": [{"package": {"name": "example.com/mod", "ecosystem": "Go"}, "ranges": [{"type": "SEMVER", "events": [{"introduced": "0"}, {"fixed": "1.2.0"}]}]}]}]`,
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(f))
+	}))
+	defer ts.Close()
+
+	if _, err := NewClient("http://example.com"); err == nil {
+		t.Error("NewClient with http URL: got nil, want error")
+	}
+	c, err := NewClient(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.httpClient = ts.Client()
+
+	ctx := context.Background()
+	index, err := c.Index(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]time.Time{"example.com/mod": modified}, index); diff != "" {
+		t.Errorf("Index mismatch (-want +got):\n%s", diff)
+	}
+
+	entries, err := c.ByModule(ctx, "example.com/mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Entry{{
+		ID:       "GO-2020-0001",
+		Modified: modified,
+		Details:  "bad",
+		Affected: []Affected{{
+			Package: Package{Name: "example.com/mod", Ecosystem: "Go"},
+			Ranges:  []Range{{Type: RangeTypeSemver, Events: []RangeEvent{{Introduced: "0"}, {Fixed: "1.2.0"}}}},
+		}},
+	}}
+	if diff := cmp.Diff(want, entries); diff != "" {
+		t.Errorf("ByModule mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := c.ByModule(ctx, "example.com/none"); !errors.Is(err, derrors.NotFound) {
+		t.Errorf("ByModule for unknown module: got %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package vuln reads advisories from a Go vulnerability database, and matches
+// them to module versions.
+//
+// Advisories are in the OSV format (https://ossf.github.io/osv-schema). A
+// database serves an index, index.json, that maps each module path to the
+// time its advisories were last modified, and for each module path, a file
+// MODULE_PATH.json with the list of its advisories.
+package vuln
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// An Entry is an advisory in the OSV format. Only the fields used by pkgsite
+// are decoded.
+type Entry struct {
+	ID        string    `json:"id"`
+	Published time.Time `json:"published"`
+	Modified  time.Time `json:"modified"`
+	// Withdrawn is the time the advisory was withdrawn, if it was.
+	Withdrawn  *time.Time  `json:"withdrawn,omitempty"`
+	Aliases    []string    `json:"aliases,omitempty"`
+	Summary    string      `json:"summary,omitempty"`
+	Details    string      `json:"details"`
+	Affected   []Affected  `json:"affected"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Affected describes the versions of a module that an advisory applies to.
+type Affected struct {
+	Package Package `json:"package"`
+	Ranges  []Range `json:"ranges,omitempty"`
+}
+
+// Package identifies a module. For the Go ecosystem, Name is the module
+// path.
+type Package struct {
+	Name      string `json:"name"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+// RangeTypeSemver is the only type of Range that pkgsite understands.
+const RangeTypeSemver = "SEMVER"
+
+// A Range is a list of events in the history of a module: versions that
+// introduced the vulnerability, and versions it was fixed in.
+type Range struct {
+	Type   string       `json:"type"`
+	Events []RangeEvent `json:"events"`
+}
+
+// A RangeEvent is a version that introduced or fixed a vulnerability. Only one
+// of its fields is set. Versions are semantic versions without the "v" prefix;
+// the introduced version "0" means the first version.
+type RangeEvent struct {
+	Introduced string `json:"introduced,omitempty"`
+	Fixed      string `json:"fixed,omitempty"`
+}
+
+// A Reference is a link to more information about an advisory.
+type Reference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// AffectsVersion reports whether e applies to modulePath at version.
+// Withdrawn advisories apply to nothing.
+func (e *Entry) AffectsVersion(modulePath, version string) bool {
+	if e.Withdrawn != nil {
+		return false
+	}
+	for _, a := range e.Affected {
+		if a.Package.Name == modulePath && a.affectsVersion(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// affectsVersion reports whether version is in one of the ranges of a. If a
+// has no ranges, it affects every version.
+func (a *Affected) affectsVersion(version string) bool {
+	if len(a.Ranges) == 0 {
+		return true
+	}
+	for _, r := range a.Ranges {
+		if r.Type == RangeTypeSemver && r.contains(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether version is in r: whether the last event at or
+// before version, in semantic version order, is an introduction. At the same
+// version, a fix comes after an introduction.
+func (r *Range) contains(version string) bool {
+	type event struct {
+		version    string // "" for the first version
+		introduced bool
+	}
+	var events []event
+	for _, e := range r.Events {
+		ev := event{version: e.Fixed}
+		if e.Introduced != "" {
+			ev = event{version: e.Introduced, introduced: true}
+		}
+		if ev.version == "0" {
+			ev.version = ""
+		} else {
+			ev.version = canonicalVersion(ev.version)
+		}
+		events = append(events, ev)
+	}
+	// semver.Compare orders the empty string before every valid version.
+	sort.SliceStable(events, func(i, j int) bool {
+		if c := semver.Compare(events[i].version, events[j].version); c != 0 {
+			return c < 0
+		}
+		return events[i].introduced && !events[j].introduced
+	})
+	affected := false
+	for _, e := range events {
+		if semver.Compare(e.version, version) > 0 {
+			break
+		}
+		affected = e.introduced
+	}
+	return affected
+}
+
+// canonicalVersion adds the "v" prefix that OSV versions lack.
+func canonicalVersion(v string) string {
+	if strings.HasPrefix(v, "v") {
+		return v
+	}
+	return "v" + v
+}
+
+// FixedVersions returns the versions that fix e in modulePath, in the order
+// they appear in e.
+func (e *Entry) FixedVersions(modulePath string) []string {
+	var fixed []string
+	for _, a := range e.Affected {
+		if a.Package.Name != modulePath {
+			continue
+		}
+		for _, r := range a.Ranges {
+			for _, ev := range r.Events {
+				if ev.Fixed != "" {
+					fixed = append(fixed, canonicalVersion(ev.Fixed))
+				}
+			}
+		}
+	}
+	return fixed
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package vuln
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAffectsVersion(t *testing.T) {
+	const mod = "example.com/mod"
+	e := &Entry{
+		ID: "GO-2020-0001",
+		Affected: []Affected{{
+			Package: Package{Name: mod, Ecosystem: "Go"},
+			Ranges: []Range{{
+				Type: RangeTypeSemver,
+				Events: []RangeEvent{
+					{Introduced: "0"},
+					{Fixed: "1.2.0"},
+					{Introduced: "1.3.0"},
+					{Fixed: "1.3.4"},
+				},
+			}},
+		}},
+	}
+	for _, test := range []struct {
+		modulePath, version string
+		want                bool
+	}{
+		{mod, "v1.0.0", true},
+		{mod, "v1.1.9", true},
+		{mod, "v1.2.0", false},
+		{mod, "v1.2.5", false},
+		{mod, "v1.3.0", true},
+		{mod, "v1.3.3", true},
+		{mod, "v1.3.4", false},
+		{mod, "v2.0.0", false},
+		{mod, "v0.0.0-20200101000000-abcdefabcdef", true},
+		{"example.com/other", "v1.0.0", false},
+	} {
+		if got := e.AffectsVersion(test.modulePath, test.version); got != test.want {
+			t.Errorf("AffectsVersion(%q, %q) = %t, want %t", test.modulePath, test.version, got, test.want)
+		}
+	}
+
+	// The order of the events does not matter.
+	e.Affected[0].Ranges[0].Events = []RangeEvent{{Fixed: "1.3.4"}, {Introduced: "1.3.0"}, {Fixed: "1.2.0"}, {Introduced: "0"}}
+	if !e.AffectsVersion(mod, "v1.3.1") || e.AffectsVersion(mod, "v1.2.1") {
+		t.Error("unordered events: got wrong result")
+	}
+
+	// Ranges of other types are ignored.
+	e.Affected[0].Ranges[0].Type = "GIT"
+	if e.AffectsVersion(mod, "v1.0.0") {
+		t.Error("GIT range: got true, want false")
+	}
+
+	// An advisory without ranges affects every version.
+	e.Affected[0].Ranges = nil
+	if !e.AffectsVersion(mod, "v3.0.0") {
+		t.Error("no ranges: got false, want true")
+	}
+
+	// A withdrawn advisory affects nothing.
+	w := time.Now()
+	e.Withdrawn = &w
+	if e.AffectsVersion(mod, "v1.0.0") {
+		t.Error("withdrawn: got true, want false")
+	}
+}
+
+func TestFixedVersions(t *testing.T) {
+	e := &Entry{
+		Affected: []Affected{
+			{
+				Package: Package{Name: "example.com/mod"},
+				Ranges:  []Range{{Type: RangeTypeSemver, Events: []RangeEvent{{Introduced: "0"}, {Fixed: "1.2.0"}, {Introduced: "1.3.0"}, {Fixed: "v1.3.4"}}}},
+			},
+			{
+				Package: Package{Name: "example.com/other"},
+				Ranges:  []Range{{Type: RangeTypeSemver, Events: []RangeEvent{{Introduced: "0"}, {Fixed: "0.1.0"}}}},
+			},
+		},
+	}
+	got := e.FixedVersions("example.com/mod")
+	if diff := cmp.Diff([]string{"v1.2.0", "v1.3.4"}, got); diff != "" {
+		t.Errorf("mismatch (-want +got):\n%s", diff)
+	}
+}
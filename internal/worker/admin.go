@@ -0,0 +1,93 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// adminUser returns the name of the admin user of ctx, as authenticated by
+// middleware.AdminAuth. Changes are attributed to it rather than to a name
+// given by the client.
+func adminUser(ctx context.Context) string {
+	if u := middleware.AdminUserFromContext(ctx); u != nil {
+		return u.Name
+	}
+	return "unknown"
+}
+
+// audit records a change made by the admin user of ctx in the audit log,
+// with the state of target before and after it. Failures are logged rather
+// than returned, because the change has already been made.
+func (s *Server) audit(ctx context.Context, action, target string, before, after interface{}) {
+	if err := s.db.Audit(ctx, adminUser(ctx), action, target, before, after); err != nil {
+		log.Errorf(ctx, "audit: %v", err)
+	}
+}
+
+// auditTarget returns the target in the audit log of a change to path at
+// version, or to every version of path if version is empty.
+func auditTarget(path, version string) string {
+	if version == "" {
+		return path
+	}
+	return path + "@" + version
+}
+
+// handleListAuditLog lists the most recent administrative changes and admin
+// requests, most recent first. The "actor", "action" and "target" query
+// parameters select the entries by a user, of a kind, or to a target; a
+// module path target also selects the changes to its versions and packages.
+func (s *Server) handleListAuditLog(w http.ResponseWriter, r *http.Request) error {
+	f := postgres.AuditFilter{
+		Actor:  r.FormValue("actor"),
+		Action: r.FormValue("action"),
+		Target: r.FormValue("target"),
+	}
+	entries, err := s.db.GetAuditEntries(r.Context(), f, parseIntParam(r, "limit", 100))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, e := range entries {
+		if e.Action == postgres.AuditRequest {
+			fmt.Fprintf(w, "%s\t%s (%s)\t%s\t%s?%s\tstatus=%d\n",
+				e.CreatedAt.Format("2006-01-02 15:04:05"), e.Actor, e.AuthMethod, e.Action,
+				e.Target, url.Values(e.Params).Encode(), e.Status)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\tbefore=%s\tafter=%s\n",
+			e.CreatedAt.Format("2006-01-02 15:04:05"), e.Actor, e.Action, e.Target,
+			auditState(e.Before), auditState(e.After))
+	}
+	return nil
+}
+
+// auditState formats the state of a target in the audit log.
+func auditState(b []byte) string {
+	if b == nil {
+		return "none"
+	}
+	return string(b)
+}
+
+// recordAdminRequest adds the admin request described by e to the audit log.
+func (s *Server) recordAdminRequest(ctx context.Context, e *middleware.AuditEntry) error {
+	return s.db.InsertAuditEntry(ctx, &postgres.AuditEntry{
+		Actor:      e.User,
+		AuthMethod: e.AuthMethod,
+		Action:     postgres.AuditRequest,
+		Target:     e.Path,
+		Params:     e.Params,
+		Status:     e.Status,
+	})
+}
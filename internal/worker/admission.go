@@ -0,0 +1,131 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/proxy"
+	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	// largeModuleZipSize is the zip size, in bytes, at or above which a module
+	// is processed in the large-module lane.
+	largeModuleZipSize = 20 * megabyte
+
+	// zipMemoryFactor is the ratio between the memory used to process a
+	// module and the size of its zip. It is a rough estimate based on the
+	// memory profiles of large modules.
+	zipMemoryFactor = 10
+
+	megabyte = 1000 * 1000
+)
+
+// errResourcesExhausted is returned by admit when there are not enough
+// resources available to process a module version right now.
+var errResourcesExhausted = errors.New("insufficient resources")
+
+// An admissionController decides whether the worker has enough memory to
+// process a module version, based on the size of the module zip.
+//
+// Every fetch reserves its estimated memory cost from a fixed budget. In
+// addition, large modules must acquire one of a small number of slots in the
+// large-module lane, so that several of them can never be processed at once.
+// A fetch that cannot be admitted is deferred to a later retry, instead of
+// risking an out-of-memory restart that would lose all in-flight work.
+type admissionController struct {
+	budgetMB  int64
+	budget    *semaphore.Weighted
+	largeLane chan struct{}
+}
+
+// newAdmissionController returns an admissionController with a memory budget
+// of budgetMB megabytes that admits at most largeConcurrency large modules at
+// a time. It returns nil if budgetMB is not positive, which disables
+// admission control.
+func newAdmissionController(budgetMB, largeConcurrency int) *admissionController {
+	if budgetMB <= 0 {
+		return nil
+	}
+	if largeConcurrency < 1 {
+		largeConcurrency = 1
+	}
+	return &admissionController{
+		budgetMB:  int64(budgetMB),
+		budget:    semaphore.NewWeighted(int64(budgetMB)),
+		largeLane: make(chan struct{}, largeConcurrency),
+	}
+}
+
+// estimateMB returns the estimated memory cost, in megabytes, of processing a
+// module whose zip has the given size. The estimate is capped at the budget,
+// so that any module can be processed when the worker is otherwise idle.
+func (a *admissionController) estimateMB(zipSize int64) int64 {
+	mb := zipSize * zipMemoryFactor / megabyte
+	if mb < 1 {
+		mb = 1
+	}
+	if mb > a.budgetMB {
+		mb = a.budgetMB
+	}
+	return mb
+}
+
+// admit reserves resources for processing modulePath@version. If it succeeds,
+// the caller must call the returned function once processing is done. If the
+// resources are not available, it returns an error wrapping
+// errResourcesExhausted.
+//
+// If the size of the module cannot be determined, the module is admitted
+// without a reservation, so that the fetch itself can report the error.
+func (a *admissionController) admit(ctx context.Context, proxyClient *proxy.Client, modulePath, version string) (release func(), err error) {
+	noop := func() {}
+	if modulePath == stdlib.ModulePath {
+		return noop, nil
+	}
+	info, err := proxyClient.GetInfo(ctx, modulePath, version)
+	if err != nil {
+		log.Infof(ctx, "admit(%q, %q): %v; admitting without reservation", modulePath, version, err)
+		return noop, nil
+	}
+	size, err := proxyClient.GetZipSize(ctx, modulePath, info.Version)
+	if err != nil {
+		log.Infof(ctx, "admit(%q, %q): %v; admitting without reservation", modulePath, version, err)
+		return noop, nil
+	}
+	return a.reserve(modulePath, version, size)
+}
+
+// reserve reserves resources for a module whose zip has the given size.
+func (a *admissionController) reserve(modulePath, version string, zipSize int64) (release func(), err error) {
+	large := zipSize >= largeModuleZipSize
+	if large {
+		select {
+		case a.largeLane <- struct{}{}:
+		default:
+			return nil, fmt.Errorf("%s@%s (zip size %d): large-module lane is full: %w",
+				modulePath, version, zipSize, errResourcesExhausted)
+		}
+	}
+	cost := a.estimateMB(zipSize)
+	if !a.budget.TryAcquire(cost) {
+		if large {
+			<-a.largeLane
+		}
+		return nil, fmt.Errorf("%s@%s (zip size %d): need %dMB of memory budget: %w",
+			modulePath, version, zipSize, cost, errResourcesExhausted)
+	}
+	return func() {
+		a.budget.Release(cost)
+		if large {
+			<-a.largeLane
+		}
+	}, nil
+}
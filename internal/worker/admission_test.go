@@ -0,0 +1,72 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAdmissionController(t *testing.T) {
+	if a := newAdmissionController(0, 1); a != nil {
+		t.Fatal("newAdmissionController(0, 1): got non-nil, want nil")
+	}
+
+	const budgetMB = 1000
+	a := newAdmissionController(budgetMB, 1)
+
+	admit := func(size int64) func() {
+		t.Helper()
+		release, err := a.reserve("m.com", "v1.0.0", size)
+		if err != nil {
+			t.Fatalf("reserve(%d): %v", size, err)
+		}
+		return release
+	}
+	reject := func(size int64) {
+		t.Helper()
+		if _, err := a.reserve("m.com", "v1.0.0", size); !errors.Is(err, errResourcesExhausted) {
+			t.Fatalf("reserve(%d): got %v, want errResourcesExhausted", size, err)
+		}
+	}
+
+	// Only one large module at a time.
+	releaseLarge := admit(largeModuleZipSize)
+	reject(largeModuleZipSize)
+	// Small modules can still be admitted while the large-module lane is busy.
+	releaseSmall := admit(1 * megabyte)
+	releaseLarge()
+	releaseLarge = admit(largeModuleZipSize)
+	releaseLarge()
+	releaseSmall()
+
+	// A module whose estimated cost exceeds the budget is admitted only when
+	// nothing else is running.
+	releaseSmall = admit(1)
+	reject(10 * budgetMB * megabyte)
+	releaseSmall()
+	releaseHuge := admit(10 * budgetMB * megabyte)
+	reject(1)
+	releaseHuge()
+	admit(1)()
+}
+
+func TestEstimateMB(t *testing.T) {
+	a := newAdmissionController(500, 1)
+	for _, test := range []struct {
+		size int64
+		want int64
+	}{
+		{0, 1},
+		{1, 1},
+		{megabyte, zipMemoryFactor},
+		{10 * megabyte, 10 * zipMemoryFactor},
+		{1000 * megabyte, 500},
+	} {
+		if got := a.estimateMB(test.size); got != test.want {
+			t.Errorf("estimateMB(%d) = %d, want %d", test.size, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// handleCreateCampaign creates a reprocessing campaign from the request's
+// query parameters.
+func (s *Server) handleCreateCampaign(w http.ResponseWriter, r *http.Request) error {
+	c := &postgres.ReprocessingCampaign{
+		Name:             r.FormValue("name"),
+		AppVersion:       r.FormValue("app_version"),
+		ModulePathPrefix: r.FormValue("prefix"),
+		Artifact:         postgres.Artifact(r.FormValue("artifact")),
+		CreatedBy:        adminUser(r.Context()),
+	}
+	if c.Name == "" {
+		return &serverError{http.StatusBadRequest, errors.New("name was not specified")}
+	}
+	if c.AppVersion == "" {
+		return &serverError{http.StatusBadRequest, errors.New("app_version was not specified")}
+	}
+	if err := config.ValidateAppVersion(c.AppVersion); err != nil {
+		return &serverError{http.StatusBadRequest, fmt.Errorf("config.ValidateAppVersion(%q): %v", c.AppVersion, err)}
+	}
+	statuses, err := parseStatuses(r.FormValue("statuses"))
+	if err != nil {
+		return &serverError{http.StatusBadRequest, err}
+	}
+	c.Statuses = statuses
+
+	if err := s.db.CreateReprocessingCampaign(r.Context(), c); err != nil {
+		return campaignError(err)
+	}
+	s.audit(r.Context(), "campaign.create", c.Name, nil, c)
+	log.Infof(r.Context(), "created reprocessing campaign %q with %d module versions", c.Name, c.Total)
+	fmt.Fprintf(w, "Created campaign %q with %d module versions.", c.Name, c.Total)
+	return nil
+}
+
+// handleSetCampaignState returns a handler that changes the state of the
+// campaign named by the "name" query parameter to state.
+func (s *Server) handleSetCampaignState(state string) func(http.ResponseWriter, *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		name := r.FormValue("name")
+		if name == "" {
+			return &serverError{http.StatusBadRequest, errors.New("name was not specified")}
+		}
+		ctx := r.Context()
+		old, err := s.db.GetReprocessingCampaign(ctx, name)
+		if err != nil {
+			return campaignError(err)
+		}
+		if err := s.db.SetReprocessingCampaignState(ctx, name, state); err != nil {
+			return campaignError(err)
+		}
+		s.audit(ctx, "campaign.set-state", name, map[string]string{"state": old.State}, map[string]string{"state": state})
+		fmt.Fprintf(w, "Campaign %q is now %s.", name, state)
+		return nil
+	}
+}
+
+// handleAdvanceCampaigns marks the next batch of module versions in each
+// active campaign for reprocessing.
+func (s *Server) handleAdvanceCampaigns(w http.ResponseWriter, r *http.Request) error {
+	limit := parseIntParam(r, "limit", 1000)
+	n, err := s.db.AdvanceReprocessingCampaigns(r.Context(), limit)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Marked %d module versions for reprocessing.", n)
+	return nil
+}
+
+// parseStatuses parses a comma-separated list of status codes.
+func parseStatuses(param string) ([]int, error) {
+	if param == "" {
+		return nil, nil
+	}
+	var statuses []int
+	for _, f := range strings.Split(param, ",") {
+		s, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, fmt.Errorf("invalid status %q: %v", f, err)
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// campaignError converts an error from a campaign operation into a
+// serverError with an appropriate status.
+func campaignError(err error) error {
+	switch {
+	case errors.Is(err, derrors.InvalidArgument):
+		return &serverError{http.StatusBadRequest, err}
+	case errors.Is(err, derrors.NotFound):
+		return &serverError{http.StatusNotFound, err}
+	default:
+		return err
+	}
+}
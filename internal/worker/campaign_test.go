@@ -0,0 +1,33 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseStatuses(t *testing.T) {
+	for _, test := range []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"200", []int{200}, false},
+		{"200, 290,490", []int{200, 290, 490}, false},
+		{"200,ok", nil, true},
+	} {
+		got, err := parseStatuses(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseStatuses(%q): got error %v, want error: %t", test.in, err, test.wantErr)
+			continue
+		}
+		if diff := cmp.Diff(test.want, got); diff != "" {
+			t.Errorf("parseStatuses(%q) mismatch (-want +got):\n%s", test.in, diff)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"golang.org/x/pkgsite/internal/database"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/queue"
+)
+
+const (
+	// concurrencyInterval is how often a ConcurrencyController adjusts the
+	// number of concurrent fetches.
+	concurrencyInterval = 15 * time.Second
+
+	// highPoolWait and lowPoolWait are the time that queries spend waiting
+	// for a database connection, added up, as a fraction of the interval.
+	// Above highPoolWait, the pool is a bottleneck and the number of
+	// concurrent fetches is lowered; it is raised only below lowPoolWait.
+	highPoolWait = 1.0
+	lowPoolWait  = 0.1
+
+	// highRSS and lowRSS are the resident set size of the process, as a
+	// fraction of its limit. Above highRSS, the number of concurrent fetches
+	// is lowered; it is raised only below lowRSS.
+	highRSS = 0.85
+	lowRSS  = 0.65
+)
+
+var (
+	fetchLimit = stats.Int64(
+		"go-discovery/worker/fetch_limit",
+		"The number of fetches that the worker runs at once.",
+		stats.UnitDimensionless,
+	)
+
+	// FetchLimit is the number of fetches that the worker runs at once, as
+	// adjusted by a ConcurrencyController.
+	FetchLimit = &view.View{
+		Name:        "go-discovery/worker/fetch_limit",
+		Measure:     fetchLimit,
+		Aggregation: view.LastValue(),
+		Description: "number of concurrent fetches",
+	}
+)
+
+// A ConcurrencyController adjusts the number of fetches that the worker runs
+// at once to the pressure on its database connection pool and its memory.
+//
+// It is a feedback controller that increases the limit additively and
+// decreases it multiplicatively. At each interval, it lowers the limit by a
+// quarter if queries waited too long for database connections or the
+// process uses too much memory. Otherwise, if both are comfortably low and
+// the fetches in progress reached the limit during the interval, it raises
+// the limit by one. The limit stays between a minimum and the number of
+// workers of the limiter.
+type ConcurrencyController struct {
+	limiter    *queue.Limiter
+	minFetches int
+	rssLimit   int64 // bytes; 0 means no limit
+
+	poolWait func() time.Duration // cumulative time spent waiting for connections
+	rss      func() (int64, error)
+}
+
+// NewConcurrencyController returns a ConcurrencyController that adjusts the
+// limit of limiter between minFetches and the number of its workers, based
+// on the waits for connections of db and on the resident set size of the
+// process, which should stay below rssLimitMB megabytes. If rssLimitMB is
+// zero, memory is not taken into account. The limit starts at minFetches.
+func NewConcurrencyController(limiter *queue.Limiter, db *database.DB, minFetches, rssLimitMB int) *ConcurrencyController {
+	if minFetches < 1 {
+		minFetches = 1
+	}
+	if minFetches > limiter.Workers() {
+		minFetches = limiter.Workers()
+	}
+	limiter.SetLimit(minFetches)
+	return &ConcurrencyController{
+		limiter:    limiter,
+		minFetches: minFetches,
+		rssLimit:   int64(rssLimitMB) * megabyte,
+		poolWait:   func() time.Duration { return db.PoolStats().WaitDuration },
+		rss:        readRSS,
+	}
+}
+
+// Run adjusts the limit every interval until ctx is done.
+func (c *ConcurrencyController) Run(ctx context.Context) {
+	stats.Record(ctx, fetchLimit.M(int64(c.limiter.Limit())))
+	c.limiter.TakePeak()
+	prevWait := c.poolWait()
+
+	ticker := time.NewTicker(concurrencyInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		wait := c.poolWait()
+		var rss int64
+		if c.rssLimit > 0 {
+			var err error
+			rss, err = c.rss()
+			if err != nil {
+				log.Errorf(ctx, "ConcurrencyController: %v; ignoring memory from now on", err)
+				c.rssLimit = 0
+			}
+		}
+		c.adjust(ctx, wait-prevWait, rss)
+		prevWait = wait
+	}
+}
+
+// adjust sets the limit for the next interval, given the time that queries
+// spent waiting for connections and the resident set size of the process at
+// the end of the last one.
+func (c *ConcurrencyController) adjust(ctx context.Context, poolWait time.Duration, rss int64) {
+	limit := c.limiter.Limit()
+	peak := c.limiter.TakePeak()
+	next, reason := c.nextLimit(limit, peak, poolWait, rss)
+	if next == limit {
+		return
+	}
+	log.Infof(ctx, "ConcurrencyController: changing the number of concurrent fetches from %d to %d: %s", limit, next, reason)
+	c.limiter.SetLimit(next)
+	stats.Record(ctx, fetchLimit.M(int64(next)))
+}
+
+// nextLimit returns the limit for the next interval, and the reason for a
+// change, given the current limit, the most fetches that ran at once during
+// the interval, the time that queries spent waiting for connections and the
+// resident set size of the process.
+func (c *ConcurrencyController) nextLimit(limit, peak int, poolWait time.Duration, rss int64) (int, string) {
+	waitFrac := float64(poolWait) / float64(concurrencyInterval)
+	var rssFrac float64
+	if c.rssLimit > 0 {
+		rssFrac = float64(rss) / float64(c.rssLimit)
+	}
+	next, reason := limit, ""
+	switch {
+	case rssFrac >= highRSS:
+		next = limit - decrement(limit)
+		reason = fmt.Sprintf("using %dMB of memory, %.0f%% of the limit", rss/megabyte, rssFrac*100)
+	case waitFrac >= highPoolWait:
+		next = limit - decrement(limit)
+		reason = fmt.Sprintf("queries waited %s for database connections", poolWait.Round(time.Millisecond))
+	case peak >= limit && rssFrac < lowRSS && waitFrac < lowPoolWait:
+		next = limit + 1
+		reason = "the fetches in progress reached the limit without pressure"
+	}
+	if next < c.minFetches {
+		next = c.minFetches
+	}
+	if next > c.limiter.Workers() {
+		next = c.limiter.Workers()
+	}
+	return next, reason
+}
+
+// decrement returns the amount by which a limit is lowered: a quarter of it,
+// and at least one.
+func decrement(limit int) int {
+	if d := limit / 4; d > 1 {
+		return d
+	}
+	return 1
+}
+
+// readRSS returns the resident set size of the process, in bytes. It only
+// works on Linux.
+func readRSS() (int64, error) {
+	data, err := ioutil.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("readRSS: unexpected contents of /proc/self/statm: %q", data)
+	}
+	pages, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("readRSS: %v", err)
+	}
+	return pages * int64(os.Getpagesize()), nil
+}
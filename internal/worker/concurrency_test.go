@@ -0,0 +1,87 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal/queue"
+)
+
+func TestConcurrencyControllerNextLimit(t *testing.T) {
+	const rssLimitMB = 1000
+	c := &ConcurrencyController{
+		limiter:    queue.NewLimiter(10),
+		minFetches: 2,
+		rssLimit:   rssLimitMB * megabyte,
+	}
+	for _, test := range []struct {
+		name     string
+		limit    int
+		peak     int
+		poolWait time.Duration
+		rssMB    int64
+		want     int
+	}{
+		{"idle", 4, 1, 0, 100, 4},
+		{"saturated", 4, 4, 0, 100, 5},
+		{"saturated at max", 10, 10, 0, 100, 10},
+		{"high memory", 8, 8, 0, 900, 6},
+		{"high memory at min", 2, 2, 0, 900, 2},
+		{"high memory small limit", 3, 3, 0, 900, 2},
+		{"pool waits", 8, 8, 2 * concurrencyInterval, 100, 6},
+		{"some pool waits", 4, 4, concurrencyInterval / 2, 100, 4},
+		{"some memory", 4, 4, 0, 700, 4},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, _ := c.nextLimit(test.limit, test.peak, test.poolWait, test.rssMB*megabyte)
+			if got != test.want {
+				t.Errorf("nextLimit(%d, %d, %s, %dMB) = %d, want %d",
+					test.limit, test.peak, test.poolWait, test.rssMB, got, test.want)
+			}
+		})
+	}
+
+	// Without an RSS limit, memory is ignored.
+	c.rssLimit = 0
+	if got, _ := c.nextLimit(4, 4, 0, 1e12); got != 5 {
+		t.Errorf("nextLimit without an RSS limit = %d, want 5", got)
+	}
+}
+
+func TestConcurrencyControllerAdjust(t *testing.T) {
+	ctx := context.Background()
+	limiter := queue.NewLimiter(5)
+	c := &ConcurrencyController{limiter: limiter, minFetches: 1}
+	limiter.SetLimit(1)
+
+	// The limit is raised only while the fetches in progress reach it.
+	done, _ := limiter.TryAcquire()
+	done()
+	c.adjust(ctx, 0, 0)
+	if got, want := limiter.Limit(), 2; got != want {
+		t.Fatalf("after a busy interval: limit = %d, want %d", got, want)
+	}
+	c.adjust(ctx, 0, 0)
+	if got, want := limiter.Limit(), 2; got != want {
+		t.Fatalf("after an idle interval: limit = %d, want %d", got, want)
+	}
+	c.adjust(ctx, 2*concurrencyInterval, 0)
+	if got, want := limiter.Limit(), 1; got != want {
+		t.Fatalf("after pool waits: limit = %d, want %d", got, want)
+	}
+}
+
+func TestReadRSS(t *testing.T) {
+	rss, err := readRSS()
+	if err != nil {
+		t.Skipf("readRSS: %v", err)
+	}
+	if rss <= 0 {
+		t.Errorf("readRSS() = %d, want positive", rss)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"fmt"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// handleCheckConsistency lists up to "limit" inconsistencies of each kind
+// between the modules, packages, search_documents and paths tables.
+func (s *Server) handleCheckConsistency(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	incs, err := s.db.CheckConsistency(ctx, parseIntParam(r, "limit", 100))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, inc := range incs {
+		fmt.Fprintln(w, inc)
+	}
+	fmt.Fprintf(w, "Found %d inconsistencies.\n", len(incs))
+	return nil
+}
+
+// handleRepairConsistency repairs up to "limit" inconsistencies of each kind,
+// and lists them.
+func (s *Server) handleRepairConsistency(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	incs, err := s.db.CheckConsistency(ctx, parseIntParam(r, "limit", 100))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, inc := range incs {
+		if err := s.db.RepairInconsistency(ctx, inc); err != nil {
+			return err
+		}
+		s.audit(ctx, "consistency.repair", auditTarget(inc.ModulePath, inc.Version), nil, inc)
+		fmt.Fprintf(w, "repaired %s\n", inc)
+	}
+	log.Infof(ctx, "repaired %d inconsistencies", len(incs))
+	fmt.Fprintf(w, "Repaired %d inconsistencies.\n", len(incs))
+	return nil
+}
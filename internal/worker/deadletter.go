@@ -0,0 +1,48 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// handleListDeadLetter lists the module versions in the dead-letter queue.
+func (s *Server) handleListDeadLetter(w http.ResponseWriter, r *http.Request) error {
+	limit := parseIntParam(r, "limit", 100)
+	versions, err := s.db.GetDeadLetteredVersions(r.Context(), limit)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, v := range versions {
+		fmt.Fprintf(w, "%s@%s\t%s\t%s\n", v.ModulePath, v.Version, formatTime(v.DeadLetteredAt), v.DeadLetterReason)
+	}
+	return nil
+}
+
+// handleResurrectDeadLetter removes the module version given by the "module"
+// and "version" query parameters from the dead-letter queue.
+func (s *Server) handleResurrectDeadLetter(w http.ResponseWriter, r *http.Request) error {
+	modulePath := r.FormValue("module")
+	version := r.FormValue("version")
+	if modulePath == "" || version == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'module' and 'version' query params")}
+	}
+	if err := s.db.ResurrectDeadLetteredVersion(r.Context(), modulePath, version); err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	s.audit(r.Context(), "dead-letter.resurrect", auditTarget(modulePath, version), nil, nil)
+	log.Infof(r.Context(), "resurrected %s@%s from the dead-letter queue", modulePath, version)
+	fmt.Fprintf(w, "Removed %s@%s from the dead-letter queue.", modulePath, version)
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// dependencyStatsInterval is how long the dependency statistics of a module
+// are trusted before they are computed again. The requirement graphs of
+// modules change as the versions they require are fetched.
+const dependencyStatsInterval = 7 * 24 * time.Hour
+
+// handleComputeDependencyStats computes the dependency statistics of up to
+// "limit" modules from their stored requirement graphs, and records them.
+func (s *Server) handleComputeDependencyStats(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	limit := parseIntParam(r, "limit", 100)
+	todo, err := s.db.GetModulesForDependencyStats(ctx, time.Now().Add(-dependencyStatsInterval), limit)
+	if err != nil {
+		return err
+	}
+	g := newDependencyGraph(s.db.GetModuleRequirements)
+	cycles := 0
+	for _, st := range todo {
+		if err := g.computeStats(ctx, st); err != nil {
+			return err
+		}
+		if err := s.db.UpsertDependencyStats(ctx, st); err != nil {
+			return err
+		}
+		if st.HasCycle {
+			cycles++
+		}
+	}
+	log.Infof(ctx, "computed dependency stats of %d modules, %d with cycles", len(todo), cycles)
+	fmt.Fprintf(w, "Computed dependency stats of %d modules; %d have cycles.\n", len(todo), cycles)
+	return nil
+}
+
+// A modver is a module path and a version of it.
+type modver struct {
+	path, version string
+}
+
+// A dependencyGraph is the requirement graph of module versions, read from
+// the go.mod files of the versions as it is explored. Module versions whose
+// requirements are not known, because they were not fetched, have none.
+type dependencyGraph struct {
+	requirements func(ctx context.Context, modulePath, version string) ([]*internal.ModuleRequirement, error)
+	// edges caches the results of requirements.
+	edges map[modver][]modver
+}
+
+func newDependencyGraph(requirements func(ctx context.Context, modulePath, version string) ([]*internal.ModuleRequirement, error)) *dependencyGraph {
+	return &dependencyGraph{
+		requirements: requirements,
+		edges:        map[modver][]modver{},
+	}
+}
+
+// requires returns the module versions that mv requires.
+func (g *dependencyGraph) requires(ctx context.Context, mv modver) ([]modver, error) {
+	if e, ok := g.edges[mv]; ok {
+		return e, nil
+	}
+	reqs, err := g.requirements(ctx, mv.path, mv.version)
+	if err != nil {
+		return nil, err
+	}
+	e := []modver{}
+	for _, r := range reqs {
+		e = append(e, modver{r.ModulePath, r.Version})
+	}
+	g.edges[mv] = e
+	return e, nil
+}
+
+// computeStats sets the DependencyCount, MaxDepth and HasCycle fields of st
+// from the requirement graph of st.ModulePath at st.Version.
+//
+// The graph is explored depth first. A requirement on a module that is
+// already on the current chain of requirements, at any version, is a cycle;
+// it is not followed, so that every chain has distinct modules.
+func (g *dependencyGraph) computeStats(ctx context.Context, st *postgres.DependencyStats) error {
+	var (
+		paths   = map[string]bool{}
+		onChain = map[string]bool{}
+		depths  = map[modver]int{}
+	)
+	var visit func(mv modver) (int, error)
+	visit = func(mv modver) (int, error) {
+		if d, ok := depths[mv]; ok {
+			return d, nil
+		}
+		onChain[mv.path] = true
+		defer delete(onChain, mv.path)
+		reqs, err := g.requires(ctx, mv)
+		if err != nil {
+			return 0, err
+		}
+		depth := 0
+		for _, r := range reqs {
+			paths[r.path] = true
+			if onChain[r.path] {
+				st.HasCycle = true
+				continue
+			}
+			d, err := visit(r)
+			if err != nil {
+				return 0, err
+			}
+			if d+1 > depth {
+				depth = d + 1
+			}
+		}
+		depths[mv] = depth
+		return depth, nil
+	}
+	st.HasCycle = false
+	depth, err := visit(modver{st.ModulePath, st.Version})
+	if err != nil {
+		return err
+	}
+	delete(paths, st.ModulePath)
+	st.DependencyCount = len(paths)
+	st.MaxDepth = depth
+	return nil
+}
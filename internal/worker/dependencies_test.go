@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+func TestComputeDependencyStats(t *testing.T) {
+	// graph maps module versions to the module versions they require.
+	graph := map[string][]string{
+		"a@v1": {"b@v1", "c@v1"},
+		"b@v1": {"c@v1", "d@v2"},
+		"c@v1": {"d@v1"},
+		"d@v2": {"e@v1"},
+		// e@v1 requires nothing; f@v1 was not fetched.
+		"x@v1": {"y@v1"},
+		"y@v1": {"x@v0", "f@v1"},
+		"z@v1": {},
+	}
+	reads := map[string]int{}
+	requirements := func(_ context.Context, modulePath, version string) ([]*internal.ModuleRequirement, error) {
+		reads[modulePath+"@"+version]++
+		var reqs []*internal.ModuleRequirement
+		for _, r := range graph[modulePath+"@"+version] {
+			i := strings.IndexByte(r, '@')
+			reqs = append(reqs, &internal.ModuleRequirement{ModulePath: r[:i], Version: r[i+1:]})
+		}
+		return reqs, nil
+	}
+	g := newDependencyGraph(requirements)
+	for _, test := range []struct {
+		module, version string
+		wantCount       int
+		wantDepth       int
+		wantCycle       bool
+	}{
+		// a -> b -> d@v2 -> e is the longest chain; d counts once.
+		{"a", "v1", 4, 3, false},
+		{"c", "v1", 1, 1, false},
+		// y requires an older version of x, which requires x.
+		{"x", "v1", 2, 2, true},
+		{"z", "v1", 0, 0, false},
+		{"unknown", "v1", 0, 0, false},
+	} {
+		st := &postgres.DependencyStats{ModulePath: test.module, Version: test.version}
+		if err := g.computeStats(context.Background(), st); err != nil {
+			t.Fatal(err)
+		}
+		if st.DependencyCount != test.wantCount || st.MaxDepth != test.wantDepth || st.HasCycle != test.wantCycle {
+			t.Errorf("%s@%s: got (count %d, depth %d, cycle %t), want (%d, %d, %t)",
+				test.module, test.version, st.DependencyCount, st.MaxDepth, st.HasCycle,
+				test.wantCount, test.wantDepth, test.wantCycle)
+		}
+	}
+	for mv, n := range reads {
+		if n != 1 {
+			t.Errorf("requirements of %s read %d times, want once", mv, n)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// handleListExperiments lists the experiments, with their rollout
+// percentages.
+func (s *Server) handleListExperiments(w http.ResponseWriter, r *http.Request) error {
+	exps, err := s.db.GetExperiments(r.Context())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, e := range exps {
+		fmt.Fprintf(w, "%s\t%d%%\t%s\n", e.Name, e.Rollout, e.Description)
+	}
+	return nil
+}
+
+// handleSetExperiment creates the experiment given by the "name" query
+// parameter, or changes it, to roll it out to the percentage of requests in
+// the "rollout" parameter. The "description" parameter is required for new
+// experiments, and replaces the description of existing ones if present.
+//
+// The frontend and worker pick up the change the next time they poll for
+// experiments, about once a minute.
+func (s *Server) handleSetExperiment(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	name := r.FormValue("name")
+	if name == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'name' query param")}
+	}
+	rollout, err := strconv.ParseUint(r.FormValue("rollout"), 10, 0)
+	if err != nil || rollout > 100 {
+		return &serverError{http.StatusBadRequest, fmt.Errorf("'rollout' must be a percentage from 0 to 100, got %q", r.FormValue("rollout"))}
+	}
+	old, err := s.experiment(ctx, name)
+	if err != nil {
+		return err
+	}
+	e := &internal.Experiment{Name: name, Rollout: uint(rollout), Description: r.FormValue("description")}
+	if old == nil {
+		if e.Description == "" {
+			return &serverError{http.StatusBadRequest, errors.New("must provide 'description' query param for a new experiment")}
+		}
+		if err := s.db.InsertExperiment(ctx, e); err != nil {
+			return err
+		}
+		s.audit(ctx, "experiment.set", e.Name, nil, e)
+		log.Infof(ctx, "created experiment %q with rollout %d%%", e.Name, e.Rollout)
+		fmt.Fprintf(w, "Created experiment %q with rollout %d%%.\n", e.Name, e.Rollout)
+		return nil
+	}
+	if e.Description == "" {
+		e.Description = old.Description
+	}
+	if err := s.db.UpdateExperiment(ctx, e); err != nil {
+		return err
+	}
+	s.audit(ctx, "experiment.set", e.Name, old, e)
+	log.Infof(ctx, "changed rollout of experiment %q from %d%% to %d%%", e.Name, old.Rollout, e.Rollout)
+	fmt.Fprintf(w, "Changed rollout of experiment %q from %d%% to %d%%.\n", e.Name, old.Rollout, e.Rollout)
+	return nil
+}
+
+// handleDeleteExperiment deletes the experiment given by the "name" query
+// parameter.
+func (s *Server) handleDeleteExperiment(w http.ResponseWriter, r *http.Request) error {
+	name := r.FormValue("name")
+	if name == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'name' query param")}
+	}
+	ctx := r.Context()
+	old, err := s.experiment(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err := s.db.RemoveExperiment(ctx, name); err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	s.audit(ctx, "experiment.delete", name, old, nil)
+	log.Infof(ctx, "deleted experiment %q", name)
+	fmt.Fprintf(w, "Deleted experiment %q.\n", name)
+	return nil
+}
+
+// experiment returns the experiment with the given name, or nil if there is
+// none.
+func (s *Server) experiment(ctx context.Context, name string) (*internal.Experiment, error) {
+	exps, err := s.db.GetExperiments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exps {
+		if e.Name == name {
+			return e, nil
+		}
+	}
+	return nil, nil
+}
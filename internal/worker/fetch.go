@@ -6,57 +6,87 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
 	"strings"
 	"time"
 
-	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/label"
 	"golang.org/x/mod/semver"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/checksum"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/experiment"
 	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/modstatus"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/source"
 )
 
-const (
-	// Indicates that although we have a valid module, some packages could not be processed.
-	hasIncompletePackagesCode = 290
-	hasIncompletePackagesDesc = "incomplete packages"
-)
-
 // ProxyRemoved is a set of module@version that have been removed from the proxy,
 // even though they are still in the index.
 var ProxyRemoved = map[string]bool{}
 
+// SumDB, if non-nil, is used to verify module versions against the checksum
+// database before they are inserted. Versions that fail verification are not
+// inserted.
+var SumDB *checksum.Client
+
 // fetchTask represents the result of a fetch task that was processed.
 type fetchTask struct {
 	fetch.FetchResult
-	timings map[string]time.Duration
+	checksumResult checksum.Result
+	timings        map[string]time.Duration
 }
 
 // FetchAndUpdateState fetches and processes a module version, and then updates
 // the module_version_states table according to the result. It returns an HTTP
 // status code representing the result of the fetch operation, and a non-nil
 // error if this status code is not 200.
-func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, appVersionLabel string) (_ int, err error) {
+func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, appVersionLabel string) (status int, err error) {
 	defer derrors.Wrap(&err, "FetchAndUpdateState(%q, %q)", modulePath, requestedVersion)
 
-	tctx, span := trace.StartSpan(ctx, "FetchAndUpdateState")
+	tctx, span := dtrace.StartSpan(ctx, "FetchAndUpdateState",
+		dtrace.ModulePath(modulePath), dtrace.Version(requestedVersion))
 	ctx = experiment.NewContext(tctx, experiment.FromContext(ctx))
 	ctx = log.NewContextWithLabel(ctx, "fetch", modulePath+"@"+requestedVersion)
-	span.AddAttributes(
-		trace.StringAttribute("modulePath", modulePath),
-		trace.StringAttribute("version", requestedVersion))
-	defer span.End()
+	defer dtrace.End(span, &err)
 
-	ft := fetchAndInsertModule(ctx, modulePath, requestedVersion, proxyClient, sourceClient, db)
-	span.AddAttributes(trace.Int64Attribute("numPackages", int64(len(ft.PackageVersionStates))))
+	fetchStart := time.Now()
+	ft := fetchAndInsertModule(ctx, modulePath, requestedVersion, proxyClient, sourceClient, db, appVersionLabel, processedZipHash(ctx, db, modulePath, requestedVersion, appVersionLabel))
+	defer func() {
+		// Recording the attempt is for debugging only, so a failure here does
+		// not affect the result of the fetch. Attempts can only be recorded
+		// for versions in module_version_states.
+		if !semver.IsValid(ft.ResolvedVersion) {
+			return
+		}
+		if err2 := db.InsertFetchAttempt(ctx, ft.ModulePath, ft.ResolvedVersion, appVersionLabel,
+			status, err, time.Since(fetchStart)); err2 != nil {
+			log.Error(ctx, err2)
+		}
+	}()
+	if ft.Unchanged {
+		// The same zip was already processed by this app version, so the
+		// result would be the same. Just record that the version was seen.
+		prev, err := db.GetModuleVersionState(ctx, ft.ModulePath, ft.ResolvedVersion)
+		if err == nil {
+			err = db.BumpModuleVersionState(ctx, ft.ModulePath, ft.ResolvedVersion)
+		}
+		if err != nil {
+			log.Error(ctx, err)
+			return http.StatusInternalServerError, err
+		}
+		ft.Status = prev.Status
+		logTaskResult(ctx, ft, "Module zip unchanged")
+		return prev.Status, nil
+	}
+	span.SetAttributes(label.Int("pkgsite.num_packages", len(ft.PackageVersionStates)))
 	dbErr := updateVersionMapAndDeleteModulesWithErrors(ctx, db, ft)
 	if dbErr != nil {
 		log.Error(ctx, dbErr)
@@ -74,8 +104,18 @@ func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion strin
 	// TODO(golang/go#39628): Split UpsertModuleVersionState into
 	// InsertModuleVersionState and UpdateModuleVersionState.
 	start := time.Now()
-	err = db.UpsertModuleVersionState(ctx, ft.ModulePath, ft.ResolvedVersion, appVersionLabel,
-		time.Time{}, ft.Status, ft.GoModPath, ft.Error, ft.PackageVersionStates)
+	err = db.UpsertModuleVersionState(ctx, &internal.ModuleVersionStateForUpsert{
+		ModulePath:           ft.ModulePath,
+		Version:              ft.ResolvedVersion,
+		AppVersion:           appVersionLabel,
+		Status:               ft.Status,
+		GoModPath:            ft.GoModPath,
+		ZipHash:              ft.ZipHash,
+		ChecksumResult:       string(ft.checksumResult),
+		Proxy:                ft.Proxy,
+		FetchErr:             ft.Error,
+		PackageVersionStates: ft.PackageVersionStates,
+	})
 	ft.timings["db.UpsertModuleVersionState"] = time.Since(start)
 	if err != nil {
 		log.Error(ctx, err)
@@ -90,14 +130,31 @@ func FetchAndUpdateState(ctx context.Context, modulePath, requestedVersion strin
 	return ft.Status, ft.Error
 }
 
+// processedZipHash returns the hash of the zip of modulePath@version that was
+// successfully processed by appVersion, or the empty string if there is none.
+func processedZipHash(ctx context.Context, db *postgres.DB, modulePath, version, appVersion string) string {
+	vs, err := db.GetModuleVersionState(ctx, modulePath, version)
+	if err != nil {
+		if !errors.Is(err, derrors.NotFound) {
+			log.Error(ctx, err)
+		}
+		return ""
+	}
+	if vs.AppVersion != appVersion || !modstatus.Code(vs.Status).Succeeded() {
+		return ""
+	}
+	return vs.ZipHash
+}
+
 // fetchAndInsertModule fetches the given module version from the module proxy
 // or (in the case of the standard library) from the Go repo and writes the
-// resulting data to the database.
+// resulting data to the database, recording appVersion as the version that
+// computed it. If the module zip has the given zipHash, nothing is written.
 //
 // The given parentCtx is used for tracing, but fetches actually execute in a
 // detached context with fixed timeout, so that fetches are allowed to complete
 // even for short-lived requests.
-func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB) *fetchTask {
+func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion string, proxyClient *proxy.Client, sourceClient *source.Client, db *postgres.DB, appVersion, zipHash string) *fetchTask {
 	ft := &fetchTask{
 		FetchResult: fetch.FetchResult{
 			ModulePath:       modulePath,
@@ -119,6 +176,12 @@ func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion stri
 		return ft
 	}
 
+	if !internal.IsServed(modulePath) {
+		log.Infof(ctx, "not fetching %s@%s because it is not under a served prefix", modulePath, requestedVersion)
+		ft.Error = derrors.Excluded
+		return ft
+	}
+
 	exc, err := db.IsExcluded(ctx, modulePath)
 	if err != nil {
 		ft.Error = err
@@ -129,8 +192,19 @@ func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion stri
 		return ft
 	}
 
+	rp, err := db.GetRemovedPath(ctx, modulePath, requestedVersion)
+	if err != nil {
+		ft.Error = err
+		return ft
+	}
+	if rp != nil {
+		log.Infof(ctx, "not fetching %s@%s because it was removed: %s", modulePath, requestedVersion, rp.Reason)
+		ft.Error = derrors.Removed
+		return ft
+	}
+
 	start := time.Now()
-	fr := fetch.FetchModule(ctx, modulePath, requestedVersion, proxyClient, sourceClient)
+	fr := fetch.FetchModuleIfChanged(ctx, modulePath, requestedVersion, proxyClient, sourceClient, zipHash)
 	if fr == nil {
 		panic("fetch.FetchModule should never return a nil FetchResult")
 	}
@@ -138,14 +212,33 @@ func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion stri
 	ft.timings["fetch.FetchModule"] = time.Since(start)
 	if ft.Error != nil {
 		logf := log.Errorf
-		if ft.Status < 500 {
+		if !modstatus.Code(ft.Status).IsFailure() {
 			logf = log.Infof
 		}
 		logf(ctx, "Error executing fetch: %v (code %d)", ft.Error, ft.Status)
 		return ft
 	}
 	log.Infof(ctx, "fetch.FetchVersion succeeded for %s@%s", ft.ModulePath, ft.RequestedVersion)
+	if ft.Unchanged {
+		return ft
+	}
+	if SumDB != nil && ft.ZipHash != "" {
+		start = time.Now()
+		ft.checksumResult, err = SumDB.Verify(ft.ModulePath, ft.ResolvedVersion, ft.ZipHash, ft.GoModHash)
+		ft.timings["checksum.Verify"] = time.Since(start)
+		switch ft.checksumResult {
+		case checksum.Mismatch:
+			log.Errorf(ctx, "Not inserting %s@%s: %v", ft.ModulePath, ft.ResolvedVersion, err)
+			ft.Error = fmt.Errorf("%v: %w", err, derrors.ChecksumMismatch)
+			return ft
+		case checksum.Unverified:
+			// The checksum database may not know about the version yet, so
+			// don't hold up processing.
+			log.Infof(ctx, "Could not verify %s@%s: %v", ft.ModulePath, ft.ResolvedVersion, err)
+		}
+	}
 
+	ft.Module.AppVersion = appVersion
 	start = time.Now()
 	err = db.InsertModule(ctx, ft.Module)
 	ft.timings["db.InsertModule"] = time.Since(start)
@@ -157,6 +250,21 @@ func fetchAndInsertModule(ctx context.Context, modulePath, requestedVersion stri
 		return ft
 	}
 	log.Infof(ctx, "db.InsertModule succeeded for %s@%s", ft.ModulePath, ft.RequestedVersion)
+
+	// The license history is informational, so a failure to update it does
+	// not fail the fetch.
+	start = time.Now()
+	if err := db.UpdateLicenseHistory(ctx, ft.ModulePath, ft.ResolvedVersion); err != nil {
+		log.Error(ctx, err)
+	}
+	ft.timings["db.UpdateLicenseHistory"] = time.Since(start)
+
+	// Record where the zip is stored, so that it is deleted with the module.
+	if key, ok := proxyClient.ZipKey(ft.ModulePath, ft.ResolvedVersion); ok {
+		if err := db.SetModuleZipKey(ctx, ft.ModulePath, ft.ResolvedVersion, key); err != nil {
+			log.Error(ctx, err)
+		}
+	}
 	return ft
 }
 
@@ -164,7 +272,7 @@ func updateVersionMapAndDeleteModulesWithErrors(ctx context.Context, db *postgre
 	defer derrors.Wrap(&err, "updateVersionMapAndDeleteModulesWithErrors(%q, %q, %q, %d, %v)",
 		ft.ModulePath, ft.RequestedVersion, ft.ResolvedVersion, ft.Status, ft.Error)
 
-	ctx, span := trace.StartSpan(ctx, "worker.updateFetchResult")
+	ctx, span := dtrace.StartSpan(ctx, "worker.updateFetchResult")
 	defer span.End()
 
 	var errMsg string
@@ -194,7 +302,7 @@ func updateVersionMapAndDeleteModulesWithErrors(ctx context.Context, db *postgre
 
 	// If there were any errors processing the module then we didn't insert it.
 	// Delete it in case we are reprocessing an existing module.
-	if vm.Status > 400 {
+	if !modstatus.Code(vm.Status).HasModule() {
 		log.Infof(ctx, "%s@%s: code=%d, deleting", vm.ModulePath, vm.ResolvedVersion, vm.Status)
 		start = time.Now()
 		err = db.DeleteModule(ctx, vm.ModulePath, vm.ResolvedVersion)
@@ -204,7 +312,7 @@ func updateVersionMapAndDeleteModulesWithErrors(ctx context.Context, db *postgre
 		}
 	}
 
-	// If this was an alternative path (ft.Status == 491) and there is an older
+	// If this was an alternative path (modstatus.AlternativeModule) and there is an older
 	// version in search_documents, delete it. This is the case where a module's
 	// canonical path was changed by the addition of a go.mod file. For example,
 	// versions of logrus before it acquired a go.mod file could have the path
@@ -212,8 +320,8 @@ func updateVersionMapAndDeleteModulesWithErrors(ctx context.Context, db *postgre
 	// path is all lower-case, the old versions should not show up in search. We
 	// still leave their pages in the database so users of those old versions
 	// can still view documentation.
-	if vm.Status == derrors.ToHTTPStatus(derrors.AlternativeModule) {
-		log.Infof(ctx, "%s@%s: code=491, deleting older version from search", vm.ModulePath, vm.ResolvedVersion)
+	if modstatus.Code(vm.Status) == modstatus.AlternativeModule {
+		log.Infof(ctx, "%s@%s: code=%d, deleting older version from search", vm.ModulePath, vm.ResolvedVersion, vm.Status)
 		start = time.Now()
 		err = db.DeleteOlderVersionFromSearchDocuments(ctx, vm.ModulePath, vm.ResolvedVersion)
 		ft.timings["db.DeleteOlderVersionFromSearchDocuments"] = time.Since(start)
@@ -235,6 +343,6 @@ func logTaskResult(ctx context.Context, ft *fetchTask, prefix string) {
 	if ft.Status == http.StatusInternalServerError {
 		logf = log.Errorf
 	}
-	logf(ctx, "%s for %s@%s: code=%d, num_packages=%d, err=%v; timings: %s",
-		prefix, ft.ModulePath, ft.ResolvedVersion, ft.Status, len(ft.PackageVersionStates), ft.Error, msg)
+	logf(ctx, "%s for %s@%s: code=%d, num_packages=%d, proxy=%q, err=%v; timings: %s",
+		prefix, ft.ModulePath, ft.ResolvedVersion, ft.Status, len(ft.PackageVersionStates), ft.Proxy, ft.Error, msg)
 }
@@ -6,10 +6,12 @@ package worker
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"sort"
 	"strings"
 	"testing"
@@ -17,10 +19,14 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.org/x/mod/sumdb"
+	"golang.org/x/mod/sumdb/note"
 	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/checksum"
 	"golang.org/x/pkgsite/internal/derrors"
 	"golang.org/x/pkgsite/internal/fetch"
 	"golang.org/x/pkgsite/internal/licenses"
+	"golang.org/x/pkgsite/internal/modstatus"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/source"
@@ -186,6 +192,23 @@ func TestFetchAndUpdateState_Excluded(t *testing.T) {
 	checkModuleNotFound(t, ctx, modulePath, version, proxyClient, sourceClient, http.StatusForbidden, derrors.Excluded)
 }
 
+func TestFetchAndUpdateState_NotServed(t *testing.T) {
+	// Check that a module outside the served prefixes is not processed, and
+	// is marked excluded in module_version_states.
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer postgres.ResetTestDB(testDB, t)
+	internal.SetServedPrefixes([]string{"corp.example.com/"})
+	defer internal.SetServedPrefixes(nil)
+
+	proxyClient, teardownProxy := proxy.SetupTestProxy(t, nil)
+	defer teardownProxy()
+	sourceClient := source.NewClient(sourceTimeout)
+
+	checkModuleNotFound(t, ctx, "github.com/my/module", "v1.0.0", proxyClient, sourceClient, http.StatusForbidden, derrors.Excluded)
+}
+
 func checkModuleNotFound(t *testing.T, ctx context.Context, modulePath, version string, proxyClient *proxy.Client, sourceClient *source.Client, wantCode int, wantErr error) {
 	t.Helper()
 	code, err := FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, testDB, "appVersionLabel")
@@ -259,7 +282,7 @@ func TestFetchAndUpdateState_Incomplete(t *testing.T) {
 	const (
 		modulePath = "build.constraints/module"
 		version    = "v1.0.0"
-		want       = hasIncompletePackagesCode
+		want       = int(modstatus.HasIncompletePackages)
 	)
 
 	code, err := FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, testDB, "appVersionLabel")
@@ -309,6 +332,117 @@ func TestFetchAndUpdateState_Incomplete(t *testing.T) {
 	}
 }
 
+func TestFetchAndUpdateState_Unchanged(t *testing.T) {
+	// Check that re-fetching a zip that was already processed by the same app
+	// version only bumps the timestamps.
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer postgres.ResetTestDB(testDB, t)
+
+	proxyClient, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{buildConstraintsMod})
+	defer teardownProxy()
+	sourceClient := source.NewClient(sourceTimeout)
+
+	const (
+		modulePath = "build.constraints/module"
+		version    = "v1.0.0"
+		want       = int(modstatus.HasIncompletePackages)
+	)
+	fetchAndCheck := func(appVersion string, wantTryCount int) *internal.ModuleVersionState {
+		t.Helper()
+		code, err := FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, testDB, appVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Fatalf("got code %d, want %d", code, want)
+		}
+		vs, err := testDB.GetModuleVersionState(ctx, modulePath, version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vs.Status != want || vs.TryCount != wantTryCount || vs.ZipHash == "" {
+			t.Fatalf("got status=%d, try_count=%d, zip_hash=%q; want %d, %d, non-empty",
+				vs.Status, vs.TryCount, vs.ZipHash, want, wantTryCount)
+		}
+		return vs
+	}
+
+	first := fetchAndCheck("app1", 1)
+	second := fetchAndCheck("app1", 1)
+	if !second.LastProcessedAt.After(*first.LastProcessedAt) {
+		t.Errorf("last_processed_at was not updated: got %s, want after %s", second.LastProcessedAt, first.LastProcessedAt)
+	}
+	// A new app version processes the module again.
+	fetchAndCheck("app2", 2)
+
+	// Every fetch was recorded as an attempt, including the one that found
+	// the zip unchanged.
+	attempts, err := testDB.GetFetchAttemptsForModule(ctx, modulePath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotApps []string
+	for _, a := range attempts {
+		if a.Status != want {
+			t.Errorf("attempt by %s: got status %d, want %d", a.AppVersion, a.Status, want)
+		}
+		gotApps = append(gotApps, a.AppVersion)
+	}
+	if diff := cmp.Diff([]string{"app2", "app1", "app1"}, gotApps); diff != "" {
+		t.Errorf("app versions of attempts mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchAndUpdateState_ChecksumMismatch(t *testing.T) {
+	// Check that a module whose hashes do not match the checksum database is
+	// not inserted, and that the result is recorded.
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	defer postgres.ResetTestDB(testDB, t)
+
+	const (
+		modulePath = "github.com/my/module"
+		version    = "v1.0.0"
+	)
+	proxyClient, teardownProxy := proxy.SetupTestProxy(t, []*proxy.TestModule{
+		{
+			ModulePath: modulePath,
+			Version:    version,
+			Files: map[string]string{
+				"foo/foo.go": "// Package foo\npackage foo\n\nconst Foo = 42",
+				"LICENSE":    testhelper.MITLicense,
+			},
+		},
+	})
+	defer teardownProxy()
+	sourceClient := source.NewClient(sourceTimeout)
+
+	skey, vkey, err := note.GenerateKey(rand.Reader, "sumdb.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gosum := func(path, vers string) ([]byte, error) {
+		return []byte(fmt.Sprintf("%s %s h1:bad=\n%[1]s %[2]s/go.mod h1:bad=\n", path, vers)), nil
+	}
+	sumDBServer := httptest.NewServer(sumdb.NewServer(sumdb.NewTestServer(skey, gosum)))
+	defer sumDBServer.Close()
+	defer func(c *checksum.Client) { SumDB = c }(SumDB)
+	SumDB = checksum.New(sumDBServer.URL, vkey, sumDBServer.Client())
+
+	checkModuleNotFound(t, ctx, modulePath, version, proxyClient, sourceClient,
+		derrors.ToHTTPStatus(derrors.ChecksumMismatch), derrors.ChecksumMismatch)
+	vs, err := testDB.GetModuleVersionState(ctx, modulePath, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := vs.ChecksumResult, string(checksum.Mismatch); got != want {
+		t.Errorf("ChecksumResult = %q, want %q", got, want)
+	}
+}
+
 func TestFetchAndUpdateState_Mismatch(t *testing.T) {
 	// Check that an excluded module is not processed, and is marked excluded in module_version_states.
 	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
@@ -454,7 +588,7 @@ func TestSkipIncompletePackage(t *testing.T) {
 	if err != nil {
 		t.Fatalf("FetchAndUpdateState(%q, %q, %v, %v, %v): %v", modulePath, version, proxyClient, sourceClient, testDB, err)
 	}
-	if code != hasIncompletePackagesCode {
+	if code != int(modstatus.HasIncompletePackages) {
 		t.Errorf("FetchAndUpdateState(%q, %q, %v, %v, %v): hasIncompletePackages=false, want true",
 			modulePath, version, proxyClient, sourceClient, testDB)
 	}
@@ -522,7 +656,7 @@ func TestTrimLargeCode(t *testing.T) {
 	if err != nil {
 		t.Fatalf("FetchAndUpdateState(%q, %q, %v, %v, %v): %v", modulePath, version, proxyClient, sourceClient, testDB, err)
 	}
-	if code == hasIncompletePackagesCode {
+	if code == int(modstatus.HasIncompletePackages) {
 		t.Errorf("FetchAndUpdateState(%q, %q, %v, %v, %v): hasIncompletePackages=true, want false",
 			modulePath, version, proxyClient, sourceClient, testDB)
 	}
@@ -995,7 +1129,7 @@ func TestFetchAndInsertModule(t *testing.T) {
 			},
 			moreWantDoc: []string{
 				"Example (CustomMarshalJSON)",
-				`<summary class="Documentation-exampleDetailsHeader">Example (CustomMarshalJSON) <a href="#example-package-CustomMarshalJSON">¶</a></summary>`,
+				`<summary class="Documentation-exampleDetailsHeader">Example (CustomMarshalJSON) <a href="#example-package-CustomMarshalJSON" aria-label="Go to package example (CustomMarshalJSON)">¶</a></summary>`,
 				"Package (CustomMarshalJSON)",
 				`<li><a href="#example-package-CustomMarshalJSON">Package (CustomMarshalJSON)</a></li>`,
 				"Decoder.Decode (Stream)",
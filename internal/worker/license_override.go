@@ -0,0 +1,128 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// handleListLicenseOverrides lists the manual license overrides, followed by
+// the most recent changes made to them.
+func (s *Server) handleListLicenseOverrides(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	overrides, err := s.db.GetLicenseOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	changes, err := s.db.GetAuditEntries(ctx, postgres.AuditFilter{Action: "license-override"}, parseIntParam(r, "limit", 100))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, o := range overrides {
+		fmt.Fprintf(w, "%s\tredistributable=%t\t%s\t%s\t%s\n", overridePath(o.Path, o.Version),
+			o.IsRedistributable, o.CreatedBy, formatTime(&o.CreatedAt), o.Reason)
+	}
+	fmt.Fprintln(w, "\nRecent changes:")
+	for _, e := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\tbefore=%s\tafter=%s\n", formatTime(&e.CreatedAt), e.Actor,
+			e.Action, e.Target, auditState(e.Before), auditState(e.After))
+	}
+	return nil
+}
+
+// handleSetLicenseOverride creates or replaces the license override for the
+// "path" and optional "version" query parameters. The "redistributable"
+// parameter gives the decision, and "reason" is recorded with it, along with
+// the admin user.
+func (s *Server) handleSetLicenseOverride(w http.ResponseWriter, r *http.Request) error {
+	o := &postgres.LicenseOverride{
+		Path:      r.FormValue("path"),
+		Version:   r.FormValue("version"),
+		Reason:    r.FormValue("reason"),
+		CreatedBy: adminUser(r.Context()),
+	}
+	if o.Path == "" || o.Reason == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'path' and 'reason' query params")}
+	}
+	isRedist, err := strconv.ParseBool(r.FormValue("redistributable"))
+	if err != nil {
+		return &serverError{http.StatusBadRequest, fmt.Errorf("'redistributable' must be true or false: %v", err)}
+	}
+	o.IsRedistributable = isRedist
+	ctx := r.Context()
+	old, err := s.licenseOverride(ctx, o.Path, o.Version)
+	if err != nil {
+		return err
+	}
+	if err := s.db.SetLicenseOverride(ctx, o); err != nil {
+		return err
+	}
+	s.audit(ctx, "license-override.set", auditTarget(o.Path, o.Version), old, o)
+	log.Infof(r.Context(), "%s set license override for %s: redistributable=%t: %s",
+		o.CreatedBy, overridePath(o.Path, o.Version), o.IsRedistributable, o.Reason)
+	fmt.Fprintf(w, "Set license override for %s to redistributable=%t.\n", overridePath(o.Path, o.Version), o.IsRedistributable)
+	fmt.Fprintln(w, "Module versions that were already processed must be reprocessed for the override to take effect.")
+	return nil
+}
+
+// handleDeleteLicenseOverride deletes the license override for the "path" and
+// optional "version" query parameters. The "reason" parameter is recorded in
+// the audit log, along with the admin user.
+func (s *Server) handleDeleteLicenseOverride(w http.ResponseWriter, r *http.Request) error {
+	path := r.FormValue("path")
+	version := r.FormValue("version")
+	reason := r.FormValue("reason")
+	if path == "" || reason == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'path' and 'reason' query params")}
+	}
+	ctx := r.Context()
+	user := adminUser(ctx)
+	old, err := s.licenseOverride(ctx, path, version)
+	if err != nil {
+		return err
+	}
+	if err := s.db.DeleteLicenseOverride(ctx, path, version); err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	s.audit(ctx, "license-override.delete", auditTarget(path, version), old, nil)
+	log.Infof(r.Context(), "%s deleted license override for %s: %s", user, overridePath(path, version), reason)
+	fmt.Fprintf(w, "Deleted license override for %s.", overridePath(path, version))
+	return nil
+}
+
+// licenseOverride returns the license override for path and version, or nil
+// if there is none.
+func (s *Server) licenseOverride(ctx context.Context, path, version string) (*postgres.LicenseOverride, error) {
+	overrides, err := s.db.GetLicenseOverrides(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		if o.Path == path && o.Version == version {
+			return o, nil
+		}
+	}
+	return nil, nil
+}
+
+// overridePath formats the path and version of a license override.
+func overridePath(path, version string) string {
+	if version == "" {
+		return path + " (all versions)"
+	}
+	return path + "@" + version
+}
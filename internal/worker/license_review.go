@@ -0,0 +1,42 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// handleListLowConfidenceLicenses lists the license files whose types were
+// determined from low coverage, lowest coverage first.
+func (s *Server) handleListLowConfidenceLicenses(w http.ResponseWriter, r *http.Request) error {
+	limit := parseIntParam(r, "limit", 100)
+	lics, err := s.db.GetLowConfidenceLicenses(r.Context(), limit)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, l := range lics {
+		fmt.Fprintf(w, "%5.1f%%\t%s@%s/%s\t%s\n", l.CoveragePercent, l.ModulePath, l.Version, l.FilePath, strings.Join(l.Types, ","))
+	}
+	return nil
+}
+
+// handleListLicenseReviews lists the packages whose redistributability was
+// decided from a low-confidence license, most recent first.
+func (s *Server) handleListLicenseReviews(w http.ResponseWriter, r *http.Request) error {
+	limit := parseIntParam(r, "limit", 100)
+	reviews, err := s.db.GetLicenseReviews(r.Context(), limit)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, rv := range reviews {
+		fmt.Fprintf(w, "%s@%s\t%s\tredistributable=%t\t%5.1f%%\t%s\n", rv.ModulePath, rv.Version, rv.PackagePath,
+			rv.IsRedistributable, rv.MinCoveragePercent, strings.Join(rv.LicenseFilePaths, ","))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/postgres"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxFetchAttempts is the maximum number of fetch attempts shown on the
+// module page.
+const maxFetchAttempts = 200
+
+// handleModulePage serves the page describing a single module.
+func (s *Server) handleModulePage(w http.ResponseWriter, r *http.Request) {
+	msg, code, err := s.doModulePage(w, r)
+	if err != nil {
+		log.Errorf(r.Context(), "doModulePage(%q): %v", r.URL.Path, err)
+		http.Error(w, msg, code)
+	}
+}
+
+// doModulePage writes the page for the module whose path is r.URL.Path. On
+// error it returns the error, along with a short string and a status code to
+// be written back to the client.
+func (s *Server) doModulePage(w http.ResponseWriter, r *http.Request) (_ string, _ int, err error) {
+	defer derrors.Wrap(&err, "doModulePage")
+
+	modulePath := strings.Trim(r.URL.Path, "/")
+	if modulePath == "" {
+		return "missing module path", http.StatusBadRequest, errors.New("missing module path")
+	}
+	var (
+		versions []*internal.ModuleVersionState
+		attempts []*postgres.FetchAttempt
+	)
+	g, ctx := errgroup.WithContext(r.Context())
+	g.Go(func() error {
+		var err error
+		versions, err = s.db.GetModuleVersionStatesForModule(ctx, modulePath)
+		return err
+	})
+	g.Go(func() error {
+		var err error
+		attempts, err = s.db.GetFetchAttemptsForModule(ctx, modulePath, maxFetchAttempts)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return "error fetching module history", http.StatusInternalServerError, err
+	}
+	if len(versions) == 0 {
+		return fmt.Sprintf("module %q not found", modulePath), http.StatusNotFound, derrors.NotFound
+	}
+
+	// Group the attempts by version, preserving their order.
+	attemptsByVersion := map[string][]*postgres.FetchAttempt{}
+	for _, a := range attempts {
+		attemptsByVersion[a.Version] = append(attemptsByVersion[a.Version], a)
+	}
+	type versionHistory struct {
+		*internal.ModuleVersionState
+		Attempts []*postgres.FetchAttempt
+	}
+	page := struct {
+		ModulePath string
+		Versions   []*versionHistory
+		CSRFToken  string
+	}{
+		ModulePath: modulePath,
+		CSRFToken:  middleware.CSRFToken(s.csrfKey, r),
+	}
+	for _, v := range versions {
+		page.Versions = append(page.Versions, &versionHistory{v, attemptsByVersion[v.Version]})
+	}
+
+	var buf bytes.Buffer
+	if err := s.moduleTemplate.Execute(&buf, page); err != nil {
+		return "error rendering template", http.StatusInternalServerError, err
+	}
+	if _, err := io.Copy(w, &buf); err != nil {
+		log.Errorf(ctx, "Error copying buffer to ResponseWriter: %v", err)
+	}
+	return "", 0, nil
+}
+
+// handleEnqueue schedules a single module version to be fetched.
+func (s *Server) handleEnqueue(w http.ResponseWriter, r *http.Request) error {
+	modulePath := r.FormValue("module")
+	version := r.FormValue("version")
+	if modulePath == "" || version == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'module' and 'version' query params")}
+	}
+	if err := s.queue.ScheduleFetch(r.Context(), modulePath, version, r.FormValue("suffix"), s.taskIDChangeInterval); err != nil {
+		return err
+	}
+	s.audit(r.Context(), "enqueue", auditTarget(modulePath, version), nil, nil)
+	fmt.Fprintf(w, "Scheduled %s@%s to be fetched.", modulePath, version)
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// handleListModuleRedirects lists the module redirects, pending ones first.
+func (s *Server) handleListModuleRedirects(w http.ResponseWriter, r *http.Request) error {
+	redirects, err := s.db.GetModuleRedirects(r.Context())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, mr := range redirects {
+		approval := "pending"
+		if mr.Approved() {
+			approval = fmt.Sprintf("approved by %s %s", mr.ApprovedBy, formatTime(mr.ApprovedAt))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", mr.FromPath, mr.ToPath,
+			mr.RequestedBy, formatTime(&mr.RequestedAt), approval, mr.Reason)
+	}
+	return nil
+}
+
+// handleAddModuleRedirect records that the admin user asked for the module at
+// the "from" query parameter to redirect to the "to" parameter, for the
+// "reason" parameter. The redirect is pending until it is approved.
+func (s *Server) handleAddModuleRedirect(w http.ResponseWriter, r *http.Request) error {
+	mr := &postgres.ModuleRedirect{
+		FromPath:    r.FormValue("from"),
+		ToPath:      r.FormValue("to"),
+		Reason:      r.FormValue("reason"),
+		RequestedBy: adminUser(r.Context()),
+	}
+	if mr.FromPath == "" || mr.ToPath == "" || mr.Reason == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'from', 'to' and 'reason' query params")}
+	}
+	ctx := r.Context()
+	old, err := s.moduleRedirect(ctx, mr.FromPath)
+	if err != nil {
+		return err
+	}
+	if err := s.db.InsertModuleRedirect(ctx, mr); err != nil {
+		if errors.Is(err, derrors.InvalidArgument) {
+			return &serverError{http.StatusBadRequest, err}
+		}
+		return err
+	}
+	s.audit(ctx, "module-redirect.add", mr.FromPath, old, mr)
+	log.Infof(ctx, "%s asked to redirect %s to %s: %s", mr.RequestedBy, mr.FromPath, mr.ToPath, mr.Reason)
+	fmt.Fprintf(w, "Recorded the redirect from %s to %s. It takes effect once it is approved.\n", mr.FromPath, mr.ToPath)
+	return nil
+}
+
+// handleApproveModuleRedirect approves the redirect from the "from" query
+// parameter on behalf of the admin user, who must not be the one who asked for
+// it.
+func (s *Server) handleApproveModuleRedirect(w http.ResponseWriter, r *http.Request) error {
+	from := r.FormValue("from")
+	if from == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'from' query param")}
+	}
+	ctx := r.Context()
+	user := adminUser(ctx)
+	old, err := s.moduleRedirect(ctx, from)
+	if err != nil {
+		return err
+	}
+	if err := s.db.ApproveModuleRedirect(ctx, from, user); err != nil {
+		switch {
+		case errors.Is(err, derrors.NotFound):
+			return &serverError{http.StatusNotFound, err}
+		case errors.Is(err, derrors.InvalidArgument):
+			return &serverError{http.StatusBadRequest, err}
+		}
+		return err
+	}
+	updated, err := s.moduleRedirect(ctx, from)
+	if err != nil {
+		return err
+	}
+	s.audit(ctx, "module-redirect.approve", from, old, updated)
+	log.Infof(ctx, "%s approved the redirect from %s to %s", user, from, updated.ToPath)
+	fmt.Fprintf(w, "Approved the redirect from %s to %s.\n", from, updated.ToPath)
+	return nil
+}
+
+// handleDeleteModuleRedirect deletes the redirect from the "from" query
+// parameter.
+func (s *Server) handleDeleteModuleRedirect(w http.ResponseWriter, r *http.Request) error {
+	from := r.FormValue("from")
+	if from == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'from' query param")}
+	}
+	ctx := r.Context()
+	user := adminUser(ctx)
+	old, err := s.moduleRedirect(ctx, from)
+	if err != nil {
+		return err
+	}
+	if err := s.db.DeleteModuleRedirect(ctx, from); err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	s.audit(ctx, "module-redirect.delete", from, old, nil)
+	log.Infof(ctx, "%s deleted the redirect from %s", user, from)
+	fmt.Fprintf(w, "Deleted the redirect from %s.\n", from)
+	return nil
+}
+
+// moduleRedirect returns the redirect from fromPath, approved or not, or nil
+// if there is none.
+func (s *Server) moduleRedirect(ctx context.Context, fromPath string) (*postgres.ModuleRedirect, error) {
+	redirects, err := s.db.GetModuleRedirects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, mr := range redirects {
+		if mr.FromPath == fromPath {
+			return mr, nil
+		}
+	}
+	return nil, nil
+}
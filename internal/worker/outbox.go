@@ -0,0 +1,80 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+const (
+	// maxOutboxDispatch is the default maximum number of fetch outbox entries
+	// that are published by a single dispatch.
+	maxOutboxDispatch = 1000
+
+	// outboxRetention is how long dispatched entries are kept in the fetch
+	// outbox.
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// dispatchFetchOutbox publishes up to limit pending entries of the fetch
+// outbox to the task queue, and returns the entries that were published. If
+// suffix is non-empty, it is appended to the de-duplication key of each entry,
+// so that a pending entry whose task was created but which was not marked as
+// dispatched is published again. Entries that were marked as dispatched are
+// never published again, whatever the suffix.
+//
+// An entry is marked as dispatched only after it has been published, so an
+// entry may be published more than once if the worker stops in between. The
+// queue de-duplicates those publications using the entry's key.
+func (s *Server) dispatchFetchOutbox(ctx context.Context, limit int, suffix string) (_ []*postgres.FetchOutboxEntry, err error) {
+	defer derrors.Wrap(&err, "dispatchFetchOutbox(ctx, %d, %q)", limit, suffix)
+
+	entries, err := s.db.GetPendingFetchOutbox(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range entries {
+		key := e.Key
+		if suffix != "" {
+			key += "-" + suffix
+		}
+		if err := s.queue.ScheduleFetchWithKey(ctx, e.ModulePath, e.Version, key); err != nil {
+			return entries[:i], err
+		}
+		if err := s.db.MarkFetchOutboxDispatched(ctx, e.Key); err != nil {
+			return entries[:i], err
+		}
+	}
+	return entries, nil
+}
+
+// handleDispatchOutbox publishes pending entries of the fetch outbox to the
+// task queue, and deletes old entries that have already been published.
+func (s *Server) handleDispatchOutbox(w http.ResponseWriter, r *http.Request) (err error) {
+	defer derrors.Wrap(&err, "handleDispatchOutbox(%q)", r.URL.Path)
+	ctx := r.Context()
+	limit := parseIntParam(r, "limit", maxOutboxDispatch)
+	entries, err := s.dispatchFetchOutbox(ctx, limit, r.FormValue("suffix"))
+	if err != nil {
+		return err
+	}
+	n, err := s.db.DeleteDispatchedFetchOutbox(ctx, time.Now().Add(-outboxRetention))
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "dispatched %d fetch outbox entries, deleted %d old entries", len(entries), n)
+	w.Header().Set("Content-Type", "text/plain")
+	for _, e := range entries {
+		fmt.Fprintf(w, "scheduled %s@%s\n", e.ModulePath, e.Version)
+	}
+	return nil
+}
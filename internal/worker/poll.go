@@ -0,0 +1,104 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// minPollInterval and maxPollInterval bound the interval between polls
+	// of the module index. minPollInterval should match the schedule of the
+	// Cloud Scheduler job that invokes /poll-and-queue.
+	minPollInterval = time.Minute
+	maxPollInterval = 16 * time.Minute
+)
+
+// nextPollInterval returns the interval to wait before the next poll of the
+// module index, given the previous interval and the number of versions n
+// returned by a poll with the given limit.
+//
+// If the poll was full, there are more versions waiting, so the interval is
+// reset to the minimum. Otherwise, it is halved if any new versions appeared
+// and doubled if none did.
+func nextPollInterval(prev time.Duration, n, limit int) time.Duration {
+	var next time.Duration
+	switch {
+	case prev == 0 || n >= limit:
+		next = minPollInterval
+	case n > 0:
+		next = prev / 2
+	default:
+		next = prev * 2
+	}
+	if next < minPollInterval {
+		next = minPollInterval
+	}
+	if next > maxPollInterval {
+		next = maxPollInterval
+	}
+	return next
+}
+
+var (
+	keyPollResult = tag.MustNewKey("index_poll.result")
+	pollResults   = stats.Int64(
+		"go-discovery/worker/index_poll_count",
+		"The result of a request to poll the module index.",
+		stats.UnitDimensionless,
+	)
+	pollVersions = stats.Int64(
+		"go-discovery/worker/index_poll_versions",
+		"The number of versions read from the module index by a poll.",
+		stats.UnitDimensionless,
+	)
+	pollInterval = stats.Float64(
+		"go-discovery/worker/index_poll_interval",
+		"The interval until the next poll of the module index.",
+		stats.UnitSeconds,
+	)
+
+	// IndexPollCount is a counter of requests to poll the index, by whether
+	// the poll was performed or skipped.
+	IndexPollCount = &view.View{
+		Name:        "go-discovery/worker/index_poll_count",
+		Measure:     pollResults,
+		Aggregation: view.Count(),
+		Description: "index poll requests, by whether the index was polled or skipped",
+		TagKeys:     []tag.Key{keyPollResult},
+	}
+	// IndexPollVersionCount is the total number of versions read from the
+	// index.
+	IndexPollVersionCount = &view.View{
+		Name:        "go-discovery/worker/index_poll_versions",
+		Measure:     pollVersions,
+		Aggregation: view.Sum(),
+		Description: "versions read from the module index",
+	}
+	// IndexPollInterval is the current interval between polls of the index.
+	IndexPollInterval = &view.View{
+		Name:        "go-discovery/worker/index_poll_interval",
+		Measure:     pollInterval,
+		Aggregation: view.LastValue(),
+		Description: "interval until the next poll of the module index",
+	}
+
+	// IndexPollViews are the views for polling the module index.
+	IndexPollViews = []*view.View{IndexPollCount, IndexPollVersionCount, IndexPollInterval}
+)
+
+func recordPollSkipped(ctx context.Context) {
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(keyPollResult, "skipped")}, pollResults.M(1))
+}
+
+func recordPoll(ctx context.Context, numVersions int, interval time.Duration) {
+	stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(keyPollResult, "polled")},
+		pollResults.M(1), pollVersions.M(int64(numVersions)), pollInterval.M(interval.Seconds()))
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextPollInterval(t *testing.T) {
+	const limit = 10
+	for _, test := range []struct {
+		prev time.Duration
+		n    int
+		want time.Duration
+	}{
+		{0, 0, minPollInterval},
+		{0, 5, minPollInterval},
+		{8 * time.Minute, limit, minPollInterval},
+		{8 * time.Minute, 3, 4 * time.Minute},
+		{minPollInterval, 3, minPollInterval},
+		{4 * time.Minute, 0, 8 * time.Minute},
+		{maxPollInterval, 0, maxPollInterval},
+	} {
+		if got := nextPollInterval(test.prev, test.n, limit); got != test.want {
+			t.Errorf("nextPollInterval(%s, %d, %d) = %s, want %s", test.prev, test.n, limit, got, test.want)
+		}
+	}
+}
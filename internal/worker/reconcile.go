@@ -0,0 +1,99 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+const (
+	// maxIndexGap is the longest time the worker can go without polling the
+	// module index, or the largest jump in index timestamps between polls,
+	// before the window is recorded as a gap to be reconciled.
+	maxIndexGap = time.Hour
+
+	// reconcilePageSize is the number of versions requested from the index
+	// per page when reconciling a gap.
+	reconcilePageSize = 2000
+)
+
+// detectIndexGap reports whether the poll at now, which read versions from
+// the index starting at state.Cursor and advanced the cursor to nextCursor,
+// may have missed versions. If so, it returns the window to be reconciled.
+//
+// A gap is detected if the previous poll was longer than maxIndexGap ago (for
+// example, because the worker was down), or if the first version returned is
+// more than maxIndexGap after the cursor.
+func detectIndexGap(state *postgres.IndexPollState, now time.Time, versions []*internal.IndexVersion, nextCursor time.Time) (since, until time.Time, ok bool) {
+	if !state.LastPolledAt.IsZero() && now.Sub(state.LastPolledAt) > maxIndexGap {
+		return state.Cursor, nextCursor, true
+	}
+	if len(versions) > 0 && !state.Cursor.IsZero() && versions[0].Timestamp.Sub(state.Cursor) > maxIndexGap {
+		return state.Cursor, versions[0].Timestamp, true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// handleReconcileIndex compares windows of the module index in which versions
+// may have been missed with module_version_states, and schedules the missing
+// versions to be fetched.
+//
+// If the "since" and "until" query parameters are provided, as RFC 3339
+// timestamps, that window is recorded as a gap before reconciling.
+func (s *Server) handleReconcileIndex(w http.ResponseWriter, r *http.Request) (err error) {
+	defer derrors.Wrap(&err, "handleReconcileIndex(%q)", r.URL.Path)
+	ctx := r.Context()
+	limit := parseIntParam(r, "limit", 10)
+	if sinceParam, untilParam := r.FormValue("since"), r.FormValue("until"); sinceParam != "" || untilParam != "" {
+		since, err := time.Parse(time.RFC3339, sinceParam)
+		if err != nil {
+			return &serverError{http.StatusBadRequest, fmt.Errorf("invalid since: %v", err)}
+		}
+		until, err := time.Parse(time.RFC3339, untilParam)
+		if err != nil {
+			return &serverError{http.StatusBadRequest, fmt.Errorf("invalid until: %v", err)}
+		}
+		if until.Before(since) {
+			return &serverError{http.StatusBadRequest, fmt.Errorf("until %s is before since %s", untilParam, sinceParam)}
+		}
+		if _, err := s.db.InsertIndexGap(ctx, since, until); err != nil {
+			return err
+		}
+	}
+	gaps, err := s.db.GetUnreconciledIndexGaps(ctx, limit)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	for _, g := range gaps {
+		versions, err := s.indexClient.GetVersionsBetween(ctx, g.Since, g.Until, reconcilePageSize)
+		if err != nil {
+			return err
+		}
+		missing, err := s.db.ReconcileIndexGap(ctx, g.ID, servedVersions(versions))
+		if err != nil {
+			return err
+		}
+		log.Infof(ctx, "reconciled index gap %d [%s, %s]: %d versions, %d missing",
+			g.ID, g.Since.Format(time.RFC3339), g.Until.Format(time.RFC3339), len(versions), len(missing))
+		fmt.Fprintf(w, "gap %d [%s, %s]: %d versions, %d missing\n",
+			g.ID, g.Since.Format(time.RFC3339), g.Until.Format(time.RFC3339), len(versions), len(missing))
+	}
+	entries, err := s.dispatchFetchOutbox(ctx, maxOutboxDispatch, r.FormValue("suffix"))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		fmt.Fprintf(w, "scheduled %s@%s\n", e.ModulePath, e.Version)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/pkgsite/internal"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+func TestDetectIndexGap(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	cursor := now.Add(-10 * time.Minute)
+	next := now.Add(-time.Minute)
+	at := func(ts time.Time) []*internal.IndexVersion {
+		return []*internal.IndexVersion{{Path: "m.com", Version: "v1.0.0", Timestamp: ts}}
+	}
+	for _, test := range []struct {
+		name      string
+		state     *postgres.IndexPollState
+		versions  []*internal.IndexVersion
+		wantOK    bool
+		wantSince time.Time
+		wantUntil time.Time
+	}{
+		{
+			name:     "never polled",
+			state:    &postgres.IndexPollState{},
+			versions: at(cursor),
+		},
+		{
+			name:     "recent poll",
+			state:    &postgres.IndexPollState{Cursor: cursor, LastPolledAt: now.Add(-5 * time.Minute)},
+			versions: at(cursor.Add(time.Second)),
+		},
+		{
+			name:      "outage",
+			state:     &postgres.IndexPollState{Cursor: cursor, LastPolledAt: now.Add(-2 * time.Hour)},
+			versions:  at(cursor.Add(time.Second)),
+			wantOK:    true,
+			wantSince: cursor,
+			wantUntil: next,
+		},
+		{
+			name:      "cursor jump",
+			state:     &postgres.IndexPollState{Cursor: cursor.Add(-3 * time.Hour), LastPolledAt: now.Add(-5 * time.Minute)},
+			versions:  at(cursor),
+			wantOK:    true,
+			wantSince: cursor.Add(-3 * time.Hour),
+			wantUntil: cursor,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			since, until, ok := detectIndexGap(test.state, now, test.versions, next)
+			if ok != test.wantOK || !since.Equal(test.wantSince) || !until.Equal(test.wantUntil) {
+				t.Errorf("got (%s, %s, %t), want (%s, %s, %t)", since, until, ok, test.wantSince, test.wantUntil, test.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// handleListRemovedPaths lists the paths that were removed for legal reasons.
+func (s *Server) handleListRemovedPaths(w http.ResponseWriter, r *http.Request) error {
+	rps, err := s.db.GetRemovedPaths(r.Context())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, rp := range rps {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", removedPathString(rp.Path, rp.Scope, rp.Version),
+			rp.Scope, rp.CreatedBy, formatTime(&rp.CreatedAt), rp.Reason)
+	}
+	return nil
+}
+
+// handleAddRemovedPath removes the "path" query parameter from the site for
+// legal reasons. The "scope" parameter is one of "version" (with the
+// "version" parameter), "module" or "prefix". The "reason" parameter is shown
+// to users who ask for the path, and is recorded with it along with the admin
+// user.
+func (s *Server) handleAddRemovedPath(w http.ResponseWriter, r *http.Request) error {
+	rp := &postgres.RemovedPath{
+		Path:      r.FormValue("path"),
+		Scope:     r.FormValue("scope"),
+		Version:   r.FormValue("version"),
+		Reason:    r.FormValue("reason"),
+		CreatedBy: adminUser(r.Context()),
+	}
+	if rp.Path == "" || rp.Scope == "" || rp.Reason == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'path', 'scope' and 'reason' query params")}
+	}
+	ctx := r.Context()
+	old, err := s.removedPath(ctx, rp.Path, rp.Scope, rp.Version)
+	if err != nil {
+		return err
+	}
+	if err := s.db.InsertRemovedPath(ctx, rp); err != nil {
+		if errors.Is(err, derrors.InvalidArgument) {
+			return &serverError{http.StatusBadRequest, err}
+		}
+		return err
+	}
+	s.audit(ctx, "removed-path.add", auditTarget(rp.Path, rp.Version), old, rp)
+	log.Infof(r.Context(), "%s removed %s (%s): %s", rp.CreatedBy, removedPathString(rp.Path, rp.Scope, rp.Version), rp.Scope, rp.Reason)
+	fmt.Fprintf(w, "Removed %s. It will no longer be fetched, and the frontend will stop serving it within a few minutes.\n",
+		removedPathString(rp.Path, rp.Scope, rp.Version))
+	return nil
+}
+
+// handleDeleteRemovedPath restores the removed path given by the "path",
+// "scope" and "version" query parameters.
+func (s *Server) handleDeleteRemovedPath(w http.ResponseWriter, r *http.Request) error {
+	path := r.FormValue("path")
+	scope := r.FormValue("scope")
+	version := r.FormValue("version")
+	if path == "" || scope == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'path' and 'scope' query params")}
+	}
+	ctx := r.Context()
+	user := adminUser(ctx)
+	old, err := s.removedPath(ctx, path, scope, version)
+	if err != nil {
+		return err
+	}
+	if err := s.db.DeleteRemovedPath(ctx, path, scope, version); err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	s.audit(ctx, "removed-path.delete", auditTarget(path, version), old, nil)
+	log.Infof(r.Context(), "%s restored %s (%s)", user, removedPathString(path, scope, version), scope)
+	fmt.Fprintf(w, "Restored %s.", removedPathString(path, scope, version))
+	return nil
+}
+
+// removedPath returns the removed path for path, scope and version, or nil if
+// there is none.
+func (s *Server) removedPath(ctx context.Context, path, scope, version string) (*postgres.RemovedPath, error) {
+	rps, err := s.db.GetRemovedPaths(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, rp := range rps {
+		if rp.Path == path && rp.Scope == scope && rp.Version == version {
+			return rp, nil
+		}
+	}
+	return nil, nil
+}
+
+// removedPathString formats the path, scope and version of a removed path.
+func removedPathString(path, scope, version string) string {
+	switch scope {
+	case postgres.RemovedVersion:
+		return path + "@" + version
+	case postgres.RemovedPrefix:
+		return path + "*"
+	default:
+		return path + " (all versions)"
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/source"
+)
+
+const (
+	// repoStatusQPS is the maximum rate of requests to each code hosting
+	// site when checking the status of repos.
+	repoStatusQPS = 1
+	// repoStatusCheckInterval is how long the status of a repo is trusted
+	// before it is checked again.
+	repoStatusCheckInterval = 7 * 24 * time.Hour
+)
+
+// handleCheckRepoStatus checks whether the repos of up to "limit" modules are
+// archived or deleted, and records the results. It stops early if a hosting
+// site limits the rate of requests.
+func (s *Server) handleCheckRepoStatus(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	limit := parseIntParam(r, "limit", 100)
+	repos, err := s.db.GetReposToCheck(ctx, time.Now().Add(-repoStatusCheckInterval), limit)
+	if err != nil {
+		return err
+	}
+	var (
+		checked int
+		counts  = map[string]int{}
+	)
+	for _, repoURL := range repos {
+		status, err := s.repoStatusChecker.Check(ctx, repoURL)
+		if errors.Is(err, source.ErrRateLimited) {
+			log.Infof(ctx, "stopping repo status checks: %v", err)
+			break
+		}
+		checked++
+		if err != nil {
+			// Keep the status from the last successful check, since the error
+			// may be transient, but record the check, so that a repo that
+			// always fails does not keep others from being checked.
+			log.Errorf(ctx, "checking repo status: %v", err)
+			counts["error"]++
+			if err := s.db.MarkRepoStatusChecked(ctx, repoURL); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.db.UpdateRepoStatus(ctx, repoURL, status); err != nil {
+			return err
+		}
+		counts[status]++
+	}
+	log.Infof(ctx, "checked status of %d of %d repos: %v", checked, len(repos), counts)
+	fmt.Fprintf(w, "Checked %d repos: %d active, %d archived, %d deleted, %d unknown, %d errors.\n",
+		checked, counts[source.RepoActive], counts[source.RepoArchived], counts[source.RepoDeleted],
+		counts[source.RepoUnknown], counts["error"])
+	return nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// handleRebuildSearchDocuments rebuilds the search_documents table from the
+// modules and packages tables, and swaps the rebuilt table in if it passes
+// validation. The optional query parameters are:
+//   - max_change: the largest fraction by which the number of search
+//     documents may change (default 0.05);
+//   - queries: comma-separated spot-check queries;
+//   - min_overlap: the smallest average fraction of the top results of the
+//     spot-check queries that must stay the same (default 0, no check);
+//   - dry_run: if "true", build and validate without swapping.
+func (s *Server) handleRebuildSearchDocuments(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	opts := postgres.SearchRebuildOptions{
+		Queries: postgres.DefaultSpotCheckQueries,
+		DryRun:  r.FormValue("dry_run") == "true",
+	}
+	var err error
+	if opts.MaxCountChange, err = parseFloatParam(r, "max_change", 0.05); err != nil {
+		return &serverError{http.StatusBadRequest, err}
+	}
+	if opts.MinOverlap, err = parseFloatParam(r, "min_overlap", 0); err != nil {
+		return &serverError{http.StatusBadRequest, err}
+	}
+	if q := r.FormValue("queries"); q != "" {
+		opts.Queries = strings.Split(q, ",")
+	}
+	rb, err := s.db.RebuildSearchDocuments(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if rb.Swapped {
+		s.audit(ctx, "search-documents.rebuild", "search_documents",
+			map[string]int{"count": rb.OldCount}, map[string]int{"count": rb.NewCount})
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Search documents: %d before, %d rebuilt.\n", rb.OldCount, rb.NewCount)
+	for _, sc := range rb.SpotChecks {
+		fmt.Fprintf(w, "%q: %.0f%% of top results kept\n", sc.Query, 100*sc.Overlap)
+		fmt.Fprintf(w, "\tbefore: %s\n\tafter:  %s\n", strings.Join(sc.Old, " "), strings.Join(sc.New, " "))
+	}
+	for _, p := range rb.Problems {
+		fmt.Fprintf(w, "Not swapped: %s.\n", p)
+	}
+	switch {
+	case rb.Swapped:
+		fmt.Fprintln(w, "Swapped in the rebuilt search documents.")
+	case opts.DryRun && len(rb.Problems) == 0:
+		fmt.Fprintln(w, "Dry run: not swapped.")
+	}
+	return nil
+}
+
+func parseFloatParam(r *http.Request, name string, defaultValue float64) (float64, error) {
+	param := r.FormValue(name)
+	if param == "" {
+		return defaultValue, nil
+	}
+	val, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing query parameter %q: %v", name, err)
+	}
+	return val, nil
+}
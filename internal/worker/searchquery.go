@@ -0,0 +1,45 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// handleSearchQueriesPage serves the page listing the search queries that
+// were searched most often, and those that most often had no results.
+func (s *Server) handleSearchQueriesPage(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	days := parseIntParam(r, "days", 7)
+	limit := parseIntParam(r, "limit", 100)
+	since := time.Now().AddDate(0, 0, -days)
+	top, err := s.db.GetTopSearchQueries(ctx, since, limit, false)
+	if err != nil {
+		return err
+	}
+	zero, err := s.db.GetTopSearchQueries(ctx, since, limit, true)
+	if err != nil {
+		return err
+	}
+	page := struct {
+		Days        int
+		Top         []*postgres.SearchQueryStats
+		ZeroResults []*postgres.SearchQueryStats
+	}{days, top, zero}
+	var buf bytes.Buffer
+	if err := s.searchQueriesTemplate.Execute(&buf, page); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, &buf); err != nil {
+		log.Errorf(ctx, "Error copying buffer to ResponseWriter: %v", err)
+	}
+	return nil
+}
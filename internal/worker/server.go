@@ -20,18 +20,21 @@ import (
 
 	"cloud.google.com/go/errorreporting"
 	"github.com/go-redis/redis/v7"
-	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/label"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/dtrace"
 	"golang.org/x/pkgsite/internal/index"
 	"golang.org/x/pkgsite/internal/log"
 	"golang.org/x/pkgsite/internal/middleware"
+	"golang.org/x/pkgsite/internal/modstatus"
 	"golang.org/x/pkgsite/internal/postgres"
 	"golang.org/x/pkgsite/internal/proxy"
 	"golang.org/x/pkgsite/internal/queue"
 	"golang.org/x/pkgsite/internal/source"
 	"golang.org/x/pkgsite/internal/stdlib"
+	"golang.org/x/pkgsite/internal/vuln"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -39,6 +42,7 @@ import (
 type Server struct {
 	cfg                  *config.Config
 	indexClient          *index.Client
+	vulnClient           *vuln.Client
 	proxyClient          *proxy.Client
 	sourceClient         *source.Client
 	redisHAClient        *redis.Client
@@ -47,14 +51,25 @@ type Server struct {
 	queue                queue.Queue
 	reportingClient      *errorreporting.Client
 	taskIDChangeInterval time.Duration
+	admission            *admissionController
+	fetchLimiter         *queue.Limiter
+	repoStatusChecker    *source.RepoStatusChecker
+	fetches              *fetchTracker
+	adminAuth            middleware.AdminAuthConfig
+	csrfKey              []byte
 
-	indexTemplate *template.Template
+	indexTemplate         *template.Template
+	moduleTemplate        *template.Template
+	slowQueriesTemplate   *template.Template
+	searchQueriesTemplate *template.Template
+	tableStatsTemplate    *template.Template
 }
 
 // ServerConfig contains everything needed by a Server.
 type ServerConfig struct {
 	DB                   *postgres.DB
 	IndexClient          *index.Client
+	VulnClient           *vuln.Client
 	ProxyClient          *proxy.Client
 	SourceClient         *source.Client
 	RedisHAClient        *redis.Client
@@ -63,29 +78,74 @@ type ServerConfig struct {
 	ReportingClient      *errorreporting.Client
 	TaskIDChangeInterval time.Duration
 	StaticPath           string
+
+	// MemoryBudgetMB is the amount of memory, in megabytes, that concurrent
+	// fetches may use, as estimated from the sizes of their module zips. If
+	// it is zero, fetches are not subject to admission control.
+	MemoryBudgetMB int
+	// LargeModuleConcurrency is the maximum number of large modules that are
+	// fetched concurrently.
+	LargeModuleConcurrency int
+	// FetchLimiter limits the number of fetches that run at once, both those
+	// of the /fetch handler and those of Queue, if it runs them in-process.
+	// Its limit is adjusted by a ConcurrencyController. If it is nil, the
+	// fetches of the /fetch handler are not limited.
+	FetchLimiter *queue.Limiter
+	// AdminAuth configures the authentication of the endpoints used by
+	// people and scripts, such as /requeue. See middleware.AdminAuth.
+	AdminAuth middleware.AdminAuthConfig
+	// CSRFKey signs the CSRF tokens of the forms on the worker's pages.
+	CSRFKey []byte
 }
 
 // NewServer creates a new Server with the given dependencies.
 func NewServer(cfg *config.Config, scfg ServerConfig) (_ *Server, err error) {
 	defer derrors.Wrap(&err, "NewServer(db, %+v)", scfg)
 
-	indexTemplate, err := parseTemplate(scfg.StaticPath)
+	indexTemplate, err := parseTemplate(scfg.StaticPath, "index.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	moduleTemplate, err := parseTemplate(scfg.StaticPath, "module.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	slowQueriesTemplate, err := parseTemplate(scfg.StaticPath, "slowqueries.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	searchQueriesTemplate, err := parseTemplate(scfg.StaticPath, "searchqueries.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	tableStatsTemplate, err := parseTemplate(scfg.StaticPath, "tablestats.tmpl")
 	if err != nil {
 		return nil, err
 	}
 
 	return &Server{
-		cfg:                  cfg,
-		db:                   scfg.DB,
-		indexClient:          scfg.IndexClient,
-		proxyClient:          scfg.ProxyClient,
-		sourceClient:         scfg.SourceClient,
-		redisHAClient:        scfg.RedisHAClient,
-		redisCacheClient:     scfg.RedisCacheClient,
-		queue:                scfg.Queue,
-		reportingClient:      scfg.ReportingClient,
-		indexTemplate:        indexTemplate,
-		taskIDChangeInterval: scfg.TaskIDChangeInterval,
+		cfg:                   cfg,
+		db:                    scfg.DB,
+		indexClient:           scfg.IndexClient,
+		vulnClient:            scfg.VulnClient,
+		proxyClient:           scfg.ProxyClient,
+		sourceClient:          scfg.SourceClient,
+		redisHAClient:         scfg.RedisHAClient,
+		redisCacheClient:      scfg.RedisCacheClient,
+		queue:                 scfg.Queue,
+		reportingClient:       scfg.ReportingClient,
+		indexTemplate:         indexTemplate,
+		moduleTemplate:        moduleTemplate,
+		slowQueriesTemplate:   slowQueriesTemplate,
+		searchQueriesTemplate: searchQueriesTemplate,
+		tableStatsTemplate:    tableStatsTemplate,
+		taskIDChangeInterval:  scfg.TaskIDChangeInterval,
+		admission:             newAdmissionController(scfg.MemoryBudgetMB, scfg.LargeModuleConcurrency),
+		fetchLimiter:          scfg.FetchLimiter,
+		repoStatusChecker:     source.NewRepoStatusChecker(scfg.SourceClient, cfg.GitHubToken, repoStatusQPS),
+		fetches:               newFetchTracker(),
+		adminAuth:             scfg.AdminAuth,
+		csrfKey:               scfg.CSRFKey,
 	}, nil
 }
 
@@ -98,20 +158,93 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	if s.reportingClient != nil {
 		rmw = middleware.ErrorReporting(s.reportingClient.Report)
 	}
+	// admin authenticates the users of the manual endpoints. Those that
+	// change state are also recorded in the audit log, and must be POSTs
+	// that carry a CSRF token if they come from a browser.
+	admin := middleware.AdminAuth(s.adminAuth)
+	mutate := middleware.Chain(
+		admin,
+		middleware.Audit(s.recordAdminRequest),
+		middleware.AcceptMethods(http.MethodPost),
+		middleware.CSRF(s.csrfKey))
 	// cloud-scheduler: poll-and-queue polls the Module Index for new versions
 	// that have been published and inserts that metadata into
 	// module_version_states. It also inserts the version into the task-queue
-	// to to be fetched and processed.
+	// to to be fetched and processed. The interval between polls adapts to
+	// the rate of new versions; requests that arrive early do nothing unless
+	// the "force" query parameter is provided.
 	// This endpoint is invoked by a Cloud Scheduler job.
 	// See the note about duplicate tasks for "/requeue" below.
 	handle("/poll-and-queue", rmw(s.errorHandler(s.handleIndexAndQueue)))
 
+	// cloud-scheduler: dispatch-outbox publishes module versions in the fetch
+	// outbox that have not yet been published to the task queue, for example
+	// because the worker restarted during /poll-and-queue. Each version is
+	// published with a fixed de-duplication key, so it is fetched only once
+	// no matter how often it is dispatched.
+	// This endpoint is invoked by a Cloud Scheduler job.
+	handle("/dispatch-outbox", rmw(s.errorHandler(s.handleDispatchOutbox)))
+
+	// cloud-scheduler: reconcile-index compares windows of the Module Index
+	// in which versions may have been missed, such as during a worker outage,
+	// with module_version_states, and schedules the missing versions to be
+	// fetched. A window can also be given with the "since" and "until" query
+	// parameters.
+	// This endpoint is invoked by a Cloud Scheduler job.
+	handle("/reconcile-index", rmw(s.errorHandler(s.handleReconcileIndex)))
+
 	// cloud-scheduler: update-imported-by-count updates the imported_by_count for packages
 	// in search_documents where imported_by_count_updated_at is null or
 	// imported_by_count_updated_at < version_updated_at.
 	// This endpoint is invoked by a Cloud Scheduler job.
 	handle("/update-imported-by-count", rmw(s.errorHandler(s.handleUpdateImportedByCount)))
 
+	// cloud-scheduler: check-repo-status asks code hosting sites whether the
+	// repositories of up to "limit" modules are archived or deleted, starting
+	// with those that have gone longest without a check.
+	// This endpoint is invoked by a Cloud Scheduler job, if enabled.
+	handle("/check-repo-status", rmw(s.errorHandler(s.handleCheckRepoStatus)))
+
+	// cloud-scheduler: compute-dependency-stats computes the number of
+	// modules that the latest versions of up to "limit" modules require,
+	// directly or not, the depth of their requirement graphs, and whether
+	// those have cycles, starting with modules that have none yet.
+	// This endpoint is invoked by a Cloud Scheduler job.
+	handle("/compute-dependency-stats", rmw(s.errorHandler(s.handleComputeDependencyStats)))
+
+	// cloud-scheduler: sync-vulns copies the advisories of the modules that
+	// changed in the Go vulnerability database into the discovery database,
+	// and matches them to the versions of those modules.
+	// This endpoint is invoked by a Cloud Scheduler job, if enabled.
+	handle("/sync-vulns", rmw(s.errorHandler(s.handleSyncVulns)))
+
+	// cloud-scheduler: fetch-stdlib-tip schedules the tip of the Go repo, the
+	// head of its master branch, to be fetched, so that the documentation of
+	// the standard library at tip stays current. Tip is stored at a
+	// pseudo-version, so it never becomes the latest version of the standard
+	// library.
+	// This endpoint is invoked by a Cloud Scheduler job, if enabled.
+	handle("/fetch-stdlib-tip", rmw(s.errorHandler(s.handleFetchStdlibTip)))
+
+	// cloud-scheduler: compress-text compresses up to "limit" values in each
+	// column of documentation, README and license text that were written
+	// before the compress-text experiment was active. It reports how many it
+	// compressed, so it can be stopped once that is zero.
+	// This endpoint is invoked by a Cloud Scheduler job, if enabled.
+	handle("/compress-text", rmw(s.errorHandler(s.handleCompressText)))
+
+	// cloud-scheduler: record-table-stats records the sizes, dead tuples and
+	// vacuuming of the tables of the database in table_stats, and logs an
+	// error starting with "table stats threshold exceeded" for each table
+	// over the thresholds of the configuration.
+	// This endpoint is invoked by a Cloud Scheduler job.
+	handle("/record-table-stats", rmw(s.errorHandler(s.handleRecordTableStats)))
+
+	// cloud-scheduler: delete-old-fetch-attempts deletes the records of
+	// fetch attempts that are more than 30 days old.
+	// This endpoint is invoked by a Cloud Scheduler job.
+	handle("/delete-old-fetch-attempts", rmw(s.errorHandler(s.handleDeleteOldFetchAttempts)))
+
 	// cloud-scheduler: download search document data and update the redis sorted
 	// set(s) used in auto-completion.
 	handle("/update-redis-indexes", rmw(s.errorHandler(s.handleUpdateRedisIndexes)))
@@ -120,7 +253,9 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	// processes the contents, and inserts it into the database. If a fetch
 	// request fails for any reason other than an http.StatusInternalServerError,
 	// it will return an http.StatusOK so that the task queue does not retry
-	// fetching module versions that have a terminal error.
+	// fetching module versions that have a terminal error. If the worker does
+	// not have enough memory to process the module right now, it returns an
+	// http.StatusServiceUnavailable so that the task is retried later.
 	// This endpoint is invoked by a Cloud Tasks queue.
 	handle("/fetch/", http.StripPrefix("/fetch", http.HandlerFunc(s.handleFetch)))
 
@@ -132,31 +267,188 @@ func (s *Server) Install(handle func(string, http.Handler)) {
 	// https://cloud.google.com/tasks/docs/reference/rpc/google.cloud.tasks.v2#createtaskrequest,
 	// under "Task De-duplication"). If you cannot wait an hour, you can force
 	// duplicate tasks by providing any string as the "suffix" query parameter.
-	handle("/requeue", rmw(s.errorHandler(s.handleRequeue)))
+	handle("/requeue", mutate(rmw(s.errorHandler(s.handleRequeue))))
 
 	// manual: reprocess sets a reprocess status for all records in the
 	// module_version_states table that were processed by an app_version that
 	// occurred after the provided app_version param, so that they will be
 	// scheduled for reprocessing the next time a request to /requeue is made.
-	handle("/reprocess", rmw(s.errorHandler(s.handleReprocess)))
+	handle("/reprocess", mutate(rmw(s.errorHandler(s.handleReprocess))))
+
+	// manual: rebuild-search-documents rebuilds the search_documents table
+	// from the modules and packages tables into a shadow table, validates it
+	// by its number of rows and the top results of spot-check queries, and
+	// atomically swaps it in, to recover from a corrupt index or to apply a
+	// major ranking change. The replaced table is kept as
+	// search_documents_old until the next rebuild.
+	handle("/rebuild-search-documents", mutate(rmw(s.errorHandler(s.handleRebuildSearchDocuments))))
+
+	// manual: campaigns/create defines a reprocessing campaign: a named
+	// cohort of module versions that were processed by an app_version before
+	// the "app_version" query parameter, optionally restricted by the
+	// comma-separated "statuses" and by "prefix". Nothing is reprocessed
+	// until the campaign is advanced.
+	handle("/campaigns/create", mutate(rmw(s.errorHandler(s.handleCreateCampaign))))
+
+	// manual: campaigns/pause, campaigns/resume and campaigns/cancel change
+	// the state of the campaign named by the "name" query parameter.
+	handle("/campaigns/pause", mutate(rmw(s.errorHandler(s.handleSetCampaignState(postgres.CampaignPaused)))))
+	handle("/campaigns/resume", mutate(rmw(s.errorHandler(s.handleSetCampaignState(postgres.CampaignActive)))))
+	handle("/campaigns/cancel", mutate(rmw(s.errorHandler(s.handleSetCampaignState(postgres.CampaignCancelled)))))
+
+	// cloud-scheduler: campaigns/advance marks the next batch of module
+	// versions in each active campaign for reprocessing, so that they will be
+	// scheduled the next time a request to /requeue is made.
+	handle("/campaigns/advance", rmw(s.errorHandler(s.handleAdvanceCampaigns)))
+
+	// manual: dead-letter lists the module versions that failed too many
+	// times in a row and are no longer requeued.
+	handle("/dead-letter", admin(rmw(s.errorHandler(s.handleListDeadLetter))))
+
+	// manual: dead-letter/resurrect removes the module version given by the
+	// "module" and "version" query parameters from the dead-letter queue, so
+	// that it will be scheduled the next time a request to /requeue is made.
+	handle("/dead-letter/resurrect", mutate(rmw(s.errorHandler(s.handleResurrectDeadLetter))))
+
+	// manual: low-confidence-licenses lists the license files whose types
+	// were determined from less than 90% coverage, lowest coverage first.
+	handle("/low-confidence-licenses", admin(rmw(s.errorHandler(s.handleListLowConfidenceLicenses))))
+
+	// manual: license-reviews lists the packages whose redistributability
+	// was decided from a low-confidence license, for manual review.
+	handle("/license-reviews", admin(rmw(s.errorHandler(s.handleListLicenseReviews))))
+
+	// manual: license-overrides lists the manual license overrides and the
+	// recent changes made to them.
+	handle("/license-overrides", admin(rmw(s.errorHandler(s.handleListLicenseOverrides))))
+
+	// manual: license-overrides/set marks everything at or below the "path"
+	// query parameter, at the optional "version", as redistributable or not,
+	// taking precedence over detected licenses. The "reason" parameter is
+	// required and recorded for auditing, along with the admin user.
+	handle("/license-overrides/set", mutate(rmw(s.errorHandler(s.handleSetLicenseOverride))))
+
+	// manual: license-overrides/delete removes the license override given by
+	// the "path" and "version" query parameters.
+	handle("/license-overrides/delete", mutate(rmw(s.errorHandler(s.handleDeleteLicenseOverride))))
+
+	// manual: removed-paths lists the paths that were removed for legal
+	// reasons.
+	handle("/removed-paths", admin(rmw(s.errorHandler(s.handleListRemovedPaths))))
+
+	// manual: removed-paths/add stops the "path" query parameter from being
+	// fetched, and makes the frontend serve an HTTP 451 page with the
+	// "reason" parameter for it. The "scope" parameter is "version" (with
+	// the "version" parameter), "module" or "prefix". The admin user is
+	// recorded with it.
+	handle("/removed-paths/add", mutate(rmw(s.errorHandler(s.handleAddRemovedPath))))
+
+	// manual: removed-paths/delete restores the removed path given by the
+	// "path", "scope" and "version" query parameters.
+	handle("/removed-paths/delete", mutate(rmw(s.errorHandler(s.handleDeleteRemovedPath))))
+
+	// manual: module-redirects lists the module redirects, pending ones
+	// first.
+	handle("/module-redirects", admin(rmw(s.errorHandler(s.handleListModuleRedirects))))
+
+	// manual: module-redirects/add records that the admin user asked for the
+	// module at the "from" query parameter to redirect to the "to" parameter,
+	// for the "reason" parameter. The redirect takes effect once it is
+	// approved.
+	handle("/module-redirects/add", mutate(rmw(s.errorHandler(s.handleAddModuleRedirect))))
+
+	// manual: module-redirects/approve approves the redirect from the "from"
+	// query parameter on behalf of the admin user. The frontend then
+	// shows a banner on the pages of the old module, and search ranks it
+	// lower.
+	handle("/module-redirects/approve", mutate(rmw(s.errorHandler(s.handleApproveModuleRedirect))))
+
+	// manual: module-redirects/delete deletes the redirect from the "from"
+	// query parameter.
+	handle("/module-redirects/delete", mutate(rmw(s.errorHandler(s.handleDeleteModuleRedirect))))
+
+	// manual: experiments lists the experiments and their rollout
+	// percentages.
+	handle("/experiments", admin(rmw(s.errorHandler(s.handleListExperiments))))
+
+	// manual: experiments/set creates or changes the experiment given by the
+	// "name" query parameter, to enroll the percentage of clients in the
+	// "rollout" parameter. New experiments need a "description".
+	handle("/experiments/set", mutate(rmw(s.errorHandler(s.handleSetExperiment))))
+
+	// manual: experiments/delete deletes the experiment given by the "name"
+	// query parameter.
+	handle("/experiments/delete", mutate(rmw(s.errorHandler(s.handleDeleteExperiment))))
+
+	// manual: module/<path> shows the state and fetch history of every
+	// version of the module <path>.
+	handle("/module/", admin(http.StripPrefix("/module", http.HandlerFunc(s.handleModulePage))))
+
+	// manual: slow-queries shows the most recent database queries that took
+	// longer than the slow query threshold, with their plans if captured.
+	handle("/slow-queries", admin(rmw(s.errorHandler(s.handleSlowQueriesPage))))
+
+	// manual: table-stats shows the most recently recorded statistics of
+	// the tables of the database, and those over the thresholds.
+	handle("/table-stats", admin(rmw(s.errorHandler(s.handleTableStatsPage))))
+
+	// manual: search-queries shows the most frequent search queries of the
+	// last "days" days (7 by default) that the frontend sampled, and those
+	// that most often had no results.
+	handle("/search-queries", admin(rmw(s.errorHandler(s.handleSearchQueriesPage))))
+
+	// manual: search-synonyms lists the synonyms that search queries are
+	// expanded with.
+	handle("/search-synonyms", admin(rmw(s.errorHandler(s.handleListSearchSynonyms))))
+
+	// manual: search-synonyms/add makes search queries for the "term" query
+	// parameter also match the "synonym" parameter. The admin user is
+	// recorded with the synonym.
+	handle("/search-synonyms/add", mutate(rmw(s.errorHandler(s.handleAddSearchSynonym))))
+
+	// manual: search-synonyms/delete deletes the synonym given by the
+	// "term" and "synonym" query parameters.
+	handle("/search-synonyms/delete", mutate(rmw(s.errorHandler(s.handleDeleteSearchSynonym))))
+
+	// manual: consistency lists up to "limit" (100 by default) of each kind
+	// of inconsistency between the derived tables: packages without search
+	// documents, search documents of deleted packages, and processed module
+	// versions without paths.
+	handle("/consistency", admin(rmw(s.errorHandler(s.handleCheckConsistency))))
+
+	// manual: consistency/repair repairs the inconsistencies that
+	// /consistency lists, by upserting or deleting search documents and by
+	// marking module versions without paths for reprocessing.
+	handle("/consistency/repair", mutate(rmw(s.errorHandler(s.handleRepairConsistency))))
+
+	// manual: enqueue schedules the module version given by the "module" and
+	// "version" query parameters to be fetched. See the note about duplicate
+	// tasks for "/requeue" above.
+	handle("/enqueue", mutate(rmw(s.errorHandler(s.handleEnqueue))))
 
 	// manual: populate-stdlib inserts all versions of the Go standard
 	// library into the tasks queue to be processed and inserted into the
 	// database. handlePopulateStdLib should be updated whenever a new
 	// version of Go is released.
 	// see the comments on duplicate tasks for "/requeue", above.
-	handle("/populate-stdlib", rmw(s.errorHandler(s.handlePopulateStdLib)))
+	handle("/populate-stdlib", mutate(rmw(s.errorHandler(s.handlePopulateStdLib))))
 
 	// manual: populate-search-documents repopulates every row in the
 	// search_documents table that was last updated before the time in the
 	// "before" query parameter.
-	handle("/repopulate-search-documents", rmw(s.errorHandler(s.handleRepopulateSearchDocuments)))
+	handle("/repopulate-search-documents", mutate(rmw(s.errorHandler(s.handleRepopulateSearchDocuments))))
 
 	// manual: clear-cache clears the redis cache.
-	handle("/clear-cache", rmw(s.errorHandler(s.clearCache)))
+	handle("/clear-cache", mutate(rmw(s.errorHandler(s.clearCache))))
+
+	// manual: audit-log lists the most recent administrative changes, with
+	// who made them and what they changed, and the requests to the endpoints
+	// above that change state, with their statuses. It takes "actor",
+	// "action", "target" and "limit" query parameters.
+	handle("/audit-log", admin(rmw(s.errorHandler(s.handleListAuditLog))))
 
 	// returns the Worker homepage.
-	handle("/", http.HandlerFunc(s.handleStatusPage))
+	handle("/", admin(http.HandlerFunc(s.handleStatusPage)))
 }
 
 // handleUpdateImportedByCount updates imported_by_count for all packages.
@@ -169,6 +461,32 @@ func (s *Server) handleUpdateImportedByCount(w http.ResponseWriter, r *http.Requ
 	return nil
 }
 
+// handleDeleteOldFetchAttempts deletes the records of old fetch attempts.
+func (s *Server) handleDeleteOldFetchAttempts(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	n, err := s.db.DeleteOldFetchAttempts(ctx)
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "handleDeleteOldFetchAttempts: deleted %d fetch attempts", n)
+	fmt.Fprintf(w, "Deleted %d fetch attempts.\n", n)
+	return nil
+}
+
+// handleCompressText compresses the text that was stored before the
+// compress-text experiment was active.
+func (s *Server) handleCompressText(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	limit := parseIntParam(r, "limit", 100)
+	n, err := s.db.CompressText(ctx, limit)
+	if err != nil {
+		return err
+	}
+	log.Infof(ctx, "handleCompressText: compressed %d values", n)
+	fmt.Fprintf(w, "Compressed %d values.\n", n)
+	return nil
+}
+
 // handleRepopulateSearchDocuments repopulates every row in the search_documents table
 // that was last updated before the given time.
 func (s *Server) handleRepopulateSearchDocuments(w http.ResponseWriter, r *http.Request) error {
@@ -201,8 +519,9 @@ func (s *Server) handleRepopulateSearchDocuments(w http.ResponseWriter, r *http.
 }
 
 // handleFetch executes a fetch request and returns a http.StatusOK if the
-// status is not http.StatusInternalServerError, so that the task queue does
-// not retry fetching module versions that have a terminal error.
+// status is not http.StatusInternalServerError or
+// http.StatusServiceUnavailable, so that the task queue does not retry
+// fetching module versions that have a terminal error.
 func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/" {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -215,7 +534,7 @@ func (s *Server) handleFetch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	msg, code := s.doFetch(r)
-	if code == http.StatusInternalServerError {
+	if code == http.StatusInternalServerError || code == http.StatusServiceUnavailable {
 		log.Infof(r.Context(), "doFetch of %s returned %d; returning that code to retry task", r.URL.Path, code)
 		http.Error(w, http.StatusText(code), code)
 		return
@@ -237,14 +556,50 @@ func (s *Server) doFetch(r *http.Request) (string, int) {
 	if err != nil {
 		return err.Error(), http.StatusBadRequest
 	}
-
-	code, err := FetchAndUpdateState(r.Context(), modulePath, version, s.proxyClient, s.sourceClient, s.db, s.cfg.AppVersionLabel())
+	dtrace.SetAttributes(r.Context(), dtrace.ModulePath(modulePath), dtrace.Version(version))
+	code, err := s.fetch(r.Context(), modulePath, version, s.fetchLimiter)
 	if err != nil {
 		return err.Error(), code
 	}
 	return fmt.Sprintf("fetched and updated %s@%s", modulePath, version), code
 }
 
+// ProcessFetch is the queue.ProcessFunc of the queues whose tasks the worker
+// processes itself. It fetches modulePath at version in the same way as the
+// /fetch handler, except that it does not take a slot of the fetch limiter:
+// the queue already holds one.
+func (s *Server) ProcessFetch(ctx context.Context, modulePath, version string) (int, error) {
+	return s.fetch(ctx, modulePath, version, nil)
+}
+
+// fetch fetches modulePath at version and updates its state. If the worker is
+// shutting down, limiter has no slot for the fetch, or the worker doesn't
+// have the resources to process the module now, it returns a 503, so that
+// the task is retried later, presumably by another instance for a shutdown.
+// A nil limiter does not limit the fetch.
+func (s *Server) fetch(ctx context.Context, modulePath, version string, limiter *queue.Limiter) (int, error) {
+	ctx, done, err := s.fetches.start(ctx, modulePath, version)
+	if err != nil {
+		return http.StatusServiceUnavailable, err
+	}
+	defer done()
+	if limiter != nil {
+		fetchDone, ok := limiter.TryAcquire()
+		if !ok {
+			return http.StatusServiceUnavailable, fmt.Errorf("%s@%s: %d fetches in progress: %w", modulePath, version, limiter.Limit(), errResourcesExhausted)
+		}
+		defer fetchDone()
+	}
+	if s.admission != nil {
+		release, err := s.admission.admit(ctx, s.proxyClient, modulePath, version)
+		if err != nil {
+			return http.StatusServiceUnavailable, err
+		}
+		defer release()
+	}
+	return FetchAndUpdateState(ctx, modulePath, version, s.proxyClient, s.sourceClient, s.db, s.cfg.AppVersionLabel())
+}
+
 // parseModulePathAndVersion returns the module and version specified by p. p
 // is assumed to have either of the following two structures:
 //   - <module>/@v/<version>
@@ -269,37 +624,87 @@ func parseModulePathAndVersion(requestPath string) (string, string, error) {
 	return parts[0], parts[1], nil
 }
 
+// handleIndexAndQueue polls the module index for new versions, records them in
+// module_version_states and schedules them to be fetched.
+//
+// The interval between polls adapts to the rate at which new versions appear.
+// Requests that arrive before the next poll is due do nothing, unless the
+// "force" query parameter is set.
 func (s *Server) handleIndexAndQueue(w http.ResponseWriter, r *http.Request) (err error) {
 	defer derrors.Wrap(&err, "handleIndexAndQueue(%q)", r.URL.Path)
 	ctx := r.Context()
 	limit := parseIntParam(r, "limit", 10)
 	suffixParam := r.FormValue("suffix")
-	since, err := s.db.LatestIndexTimestamp(ctx)
+	state, err := s.db.GetIndexPollState(ctx)
 	if err != nil {
 		return err
 	}
-	versions, err := s.indexClient.GetVersions(ctx, since, limit)
+	now := time.Now()
+	if r.FormValue("force") == "" && now.Before(state.NextPollAt) {
+		recordPollSkipped(ctx)
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "skipping poll: next poll at %s (interval %s)\n", state.NextPollAt.Format(time.RFC3339), state.Interval)
+		return nil
+	}
+	versions, err := s.indexClient.GetVersions(ctx, state.Cursor, limit)
 	if err != nil {
 		return err
 	}
-	if err := s.db.InsertIndexVersions(ctx, versions); err != nil {
+	next := &postgres.IndexPollState{
+		Cursor:          state.Cursor,
+		Interval:        nextPollInterval(state.Interval, len(versions), limit),
+		LastPolledAt:    now,
+		LastNumVersions: len(versions),
+	}
+	next.NextPollAt = now.Add(next.Interval)
+	for _, v := range versions {
+		if v.Timestamp.After(next.Cursor) {
+			next.Cursor = v.Timestamp
+		}
+	}
+	// Record the versions, add them to the fetch outbox and advance the
+	// cursor together. If dispatching fails below, the versions stay in the
+	// outbox until the next dispatch. The cursor advances past versions that
+	// are not served, but they are not recorded.
+	if err := s.db.InsertIndexVersionsAndPollState(ctx, servedVersions(versions), next); err != nil {
 		return err
 	}
-	log.Infof(ctx, "Scheduling modules to be fetched: %d new modules from index.golang.org", len(versions))
-	for _, version := range versions {
-		if err := s.queue.ScheduleFetch(ctx, version.Path, version.Version, suffixParam, s.taskIDChangeInterval); err != nil {
-			return err
+	recordPoll(ctx, len(versions), next.Interval)
+	if since, until, ok := detectIndexGap(state, now, versions, next.Cursor); ok {
+		// The gap is reconciled later by /reconcile-index; failing to record
+		// it should not fail the poll.
+		if id, err := s.db.InsertIndexGap(ctx, since, until); err != nil {
+			log.Errorf(ctx, "recording index gap [%s, %s]: %v", since.Format(time.RFC3339), until.Format(time.RFC3339), err)
+		} else {
+			log.Infof(ctx, "recorded index gap %d [%s, %s]", id, since.Format(time.RFC3339), until.Format(time.RFC3339))
 		}
 	}
+	log.Infof(ctx, "Scheduling modules to be fetched: %d new modules from index.golang.org", len(versions))
+	entries, err := s.dispatchFetchOutbox(ctx, maxOutboxDispatch, suffixParam)
+	if err != nil {
+		return err
+	}
 	log.Infof(ctx, "Successfully scheduled modules to be fetched: %d new modules from index.golang.org", len(versions))
 
 	w.Header().Set("Content-Type", "text/plain")
-	for _, v := range versions {
-		fmt.Fprintf(w, "scheduled %s@%s\n", v.Path, v.Version)
+	for _, e := range entries {
+		fmt.Fprintf(w, "scheduled %s@%s\n", e.ModulePath, e.Version)
 	}
 	return nil
 }
 
+// servedVersions returns the versions of modules that this deployment serves,
+// as reported by internal.IsServed.
+func servedVersions(versions []*internal.IndexVersion) []*internal.IndexVersion {
+	var served []*internal.IndexVersion
+	for _, v := range versions {
+		if internal.IsServed(v.Path) {
+			served = append(served, v)
+		}
+	}
+	return served
+}
+
 // handleRequeue queries the module_version_states table for the next
 // batch of module versions to process, and enqueues them for processing.  Note
 // that this may cause duplicate processing.
@@ -308,14 +713,13 @@ func (s *Server) handleRequeue(w http.ResponseWriter, r *http.Request) (err erro
 	ctx := r.Context()
 	limit := parseIntParam(r, "limit", 10)
 	suffixParam := r.FormValue("suffix") // append to task name to avoid deduplication
-	span := trace.FromContext(r.Context())
-	span.Annotate([]trace.Attribute{trace.Int64Attribute("limit", int64(limit))}, "processed limit")
+	dtrace.SetAttributes(ctx, label.Int("pkgsite.requeue.limit", limit))
 	versions, err := s.db.GetNextModulesToFetch(ctx, limit)
 	if err != nil {
 		return err
 	}
 
-	span.Annotate([]trace.Attribute{trace.Int64Attribute("versions to fetch", int64(len(versions)))}, "processed limit")
+	dtrace.SetAttributes(ctx, label.Int("pkgsite.requeue.versions", len(versions)))
 	w.Header().Set("Content-Type", "text/plain")
 	log.Infof(ctx, "Scheduling modules to be fetched: requeuing %d modules", len(versions))
 	for _, v := range versions {
@@ -324,6 +728,7 @@ func (s *Server) handleRequeue(w http.ResponseWriter, r *http.Request) (err erro
 		}
 	}
 	log.Infof(ctx, "Successfully scheduled modules to be fetched: %d modules requeued", len(versions))
+	s.audit(ctx, "requeue", "", nil, map[string]int{"limit": limit, "versions": len(versions)})
 
 	return nil
 }
@@ -343,7 +748,9 @@ func (s *Server) doStatusPage(w http.ResponseWriter, r *http.Request) (_ string,
 	const pageSize = 20
 	var (
 		next, failures, recents []*internal.ModuleVersionState
+		deadLettered            []*internal.ModuleVersionState
 		stats                   *postgres.VersionStats
+		campaigns               []*postgres.ReprocessingCampaign
 	)
 	type annotation struct {
 		error
@@ -382,6 +789,22 @@ func (s *Server) doStatusPage(w http.ResponseWriter, r *http.Request) (_ string,
 		}
 		return nil
 	})
+	g.Go(func() error {
+		var err error
+		campaigns, err = s.db.GetReprocessingCampaigns(ctx)
+		if err != nil {
+			return annotation{err, "error fetching reprocessing campaigns"}
+		}
+		return nil
+	})
+	g.Go(func() error {
+		var err error
+		deadLettered, err = s.db.GetDeadLetteredVersions(ctx, pageSize)
+		if err != nil {
+			return annotation{err, "error fetching dead-lettered versions"}
+		}
+		return nil
+	})
 	if err := g.Wait(); err != nil {
 		var e annotation
 		if errors.As(err, &e) {
@@ -397,11 +820,7 @@ func (s *Server) doStatusPage(w http.ResponseWriter, r *http.Request) (_ string,
 	}
 	var counts []*count
 	for code, n := range stats.VersionCounts {
-		c := &count{Code: code, Count: n}
-		if e := derrors.FromHTTPStatus(code, ""); e != nil && e != derrors.Unknown {
-			c.Desc = e.Error()
-		}
-		counts = append(counts, c)
+		counts = append(counts, &count{Code: code, Desc: modstatus.Code(code).Description(), Count: n})
 	}
 	sort.Slice(counts, func(i, j int) bool { return counts[i].Code < counts[j].Code })
 
@@ -423,6 +842,10 @@ func (s *Server) doStatusPage(w http.ResponseWriter, r *http.Request) (_ string,
 		LatestTimestamp              *time.Time
 		Counts                       []*count
 		Next, Recent, RecentFailures []*internal.ModuleVersionState
+		DeadLettered                 []*internal.ModuleVersionState
+		Campaigns                    []*postgres.ReprocessingCampaign
+		Artifacts                    []postgres.Artifact
+		CSRFToken                    string
 	}{
 		Config:          s.cfg,
 		Env:             env,
@@ -432,6 +855,10 @@ func (s *Server) doStatusPage(w http.ResponseWriter, r *http.Request) (_ string,
 		Next:            next,
 		Recent:          recents,
 		RecentFailures:  failures,
+		DeadLettered:    deadLettered,
+		Campaigns:       campaigns,
+		Artifacts:       postgres.Artifacts(),
+		CSRFToken:       middleware.CSRFToken(s.csrfKey, r),
 	}
 	var buf bytes.Buffer
 	if err := s.indexTemplate.Execute(&buf, page); err != nil {
@@ -451,6 +878,7 @@ func (s *Server) handlePopulateStdLib(w http.ResponseWriter, r *http.Request) er
 		return fmt.Errorf("handlePopulateStdLib: %v", err)
 	}
 	log.Infof(r.Context(), "handlePopulateStdLib: %s", msg)
+	s.audit(r.Context(), "populate-stdlib", "", nil, nil)
 	_, _ = io.WriteString(w, msg)
 	return nil
 }
@@ -468,6 +896,16 @@ func (s *Server) doPopulateStdLib(ctx context.Context, suffix string) (string, e
 	return fmt.Sprintf("Scheduling modules to be fetched: %s.\n", strings.Join(versions, ", ")), nil
 }
 
+func (s *Server) handleFetchStdlibTip(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	if err := s.queue.ScheduleFetch(ctx, stdlib.ModulePath, stdlib.MasterVersion, "", s.taskIDChangeInterval); err != nil {
+		return fmt.Errorf("error scheduling fetch for %s@%s: %w", stdlib.ModulePath, stdlib.MasterVersion, err)
+	}
+	log.Infof(ctx, "handleFetchStdlibTip: scheduled %s@%s", stdlib.ModulePath, stdlib.MasterVersion)
+	fmt.Fprintf(w, "Scheduling %s@%s to be fetched.\n", stdlib.ModulePath, stdlib.MasterVersion)
+	return nil
+}
+
 func (s *Server) handleReprocess(w http.ResponseWriter, r *http.Request) error {
 	appVersion := r.FormValue("app_version")
 	if appVersion == "" {
@@ -479,6 +917,7 @@ func (s *Server) handleReprocess(w http.ResponseWriter, r *http.Request) error {
 	if err := s.db.UpdateModuleVersionStatesForReprocessing(r.Context(), appVersion); err != nil {
 		return err
 	}
+	s.audit(r.Context(), "reprocess", appVersion, nil, nil)
 	return nil
 }
 
@@ -490,22 +929,30 @@ func (s *Server) clearCache(w http.ResponseWriter, r *http.Request) error {
 	if status.Err() != nil {
 		return status.Err()
 	}
+	s.audit(r.Context(), "clear-cache", "", nil, nil)
 	fmt.Fprint(w, "Cache cleared.")
 	return nil
 }
 
-// Parse the template for the status page.
-func parseTemplate(staticPath string) (*template.Template, error) {
+// Parse the named worker template.
+func parseTemplate(staticPath, name string) (*template.Template, error) {
 	if staticPath == "" {
 		return nil, nil
 	}
-	templatePath := filepath.Join(staticPath, "html/worker/index.tmpl")
-	return template.New("index.tmpl").Funcs(template.FuncMap{
+	templatePath := filepath.Join(staticPath, "html/worker", name)
+	return template.New(name).Funcs(template.FuncMap{
 		"truncate": truncate,
 		"timefmt":  formatTime,
+		"status":   formatStatus,
+		"bytes":    formatBytes,
 	}).ParseFiles(templatePath)
 }
 
+// formatStatus returns the module version status code with its description.
+func formatStatus(code int) string {
+	return modstatus.Code(code).String()
+}
+
 func truncate(length int, text *string) *string {
 
 	if text == nil {
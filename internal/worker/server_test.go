@@ -16,7 +16,9 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
-	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/api/global"
+	exporttrace "go.opentelemetry.io/otel/sdk/export/trace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/pkgsite/internal"
 	"golang.org/x/pkgsite/internal/config"
 	"golang.org/x/pkgsite/internal/derrors"
@@ -44,13 +46,19 @@ type debugExporter struct {
 	t *testing.T
 }
 
-func (e debugExporter) ExportSpan(s *trace.SpanData) {
-	e.t.Logf("⚡ %s: %v", s.Name, s.EndTime.Sub(s.StartTime))
+func (e debugExporter) ExportSpans(_ context.Context, spans []*exporttrace.SpanData) error {
+	for _, s := range spans {
+		e.t.Logf("⚡ %s: %v", s.Name, s.EndTime.Sub(s.StartTime))
+	}
+	return nil
 }
 
+func (debugExporter) Shutdown(context.Context) error { return nil }
+
 func setupTraceDebugging(t *testing.T) {
-	trace.RegisterExporter(debugExporter{t})
-	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	global.SetTracerProvider(sdktrace.NewTracerProvider(
+		sdktrace.WithConfig(sdktrace.Config{DefaultSampler: sdktrace.AlwaysSample()}),
+		sdktrace.WithSyncer(debugExporter{t})))
 }
 
 func TestWorker(t *testing.T) {
@@ -139,6 +147,15 @@ func TestWorker(t *testing.T) {
 				httptest.NewRequest("POST", "/poll-and-queue?limit=1", nil),
 			},
 			wantFoo: fooState(http.StatusOK, 1),
+		}, {
+			label: "early poll is skipped",
+			index: []*internal.IndexVersion{fooIndex, barIndex},
+			proxy: []*proxy.TestModule{fooProxy, barProxy},
+			requests: []*http.Request{
+				httptest.NewRequest("POST", "/poll-and-queue?limit=1", nil),
+				httptest.NewRequest("POST", "/poll-and-queue?limit=1", nil),
+			},
+			wantFoo: fooState(http.StatusOK, 1),
 		}, {
 			label: "fetch with errors",
 			index: []*internal.IndexVersion{fooIndex, barIndex},
@@ -162,7 +179,10 @@ func TestWorker(t *testing.T) {
 			defer postgres.ResetTestDB(testDB, t)
 
 			// Use 10 workers to have parallelism consistent with the worker binary.
-			q := queue.NewInMemory(ctx, proxyClient, sourceClient, testDB, 10, FetchAndUpdateState, nil, "")
+			process := func(ctx context.Context, modulePath, version string) (int, error) {
+				return FetchAndUpdateState(ctx, modulePath, version, proxyClient, sourceClient, testDB, "")
+			}
+			q := queue.NewInMemory(ctx, queue.NewLimiter(10), process, nil)
 
 			s, err := NewServer(&config.Config{}, ServerConfig{
 				DB:                   testDB,
@@ -311,3 +331,19 @@ type fakeTransport struct{}
 func (fakeTransport) RoundTrip(*http.Request) (*http.Response, error) {
 	return nil, errors.New("bad")
 }
+
+func TestServedVersions(t *testing.T) {
+	internal.SetServedPrefixes([]string{"corp.example.com/"})
+	defer internal.SetServedPrefixes(nil)
+
+	versions := []*internal.IndexVersion{
+		{Path: "corp.example.com/a", Version: "v1.0.0"},
+		{Path: "github.com/b/c", Version: "v1.0.0"},
+		{Path: "corp.example.com/d", Version: "v2.0.0"},
+	}
+	got := servedVersions(versions)
+	want := []*internal.IndexVersion{versions[0], versions[2]}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("servedVersions mismatch (-want +got):\n%s", diff)
+	}
+}
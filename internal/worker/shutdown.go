@@ -0,0 +1,140 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// errShuttingDown is returned for fetch tasks that arrive after the worker
+// has started to shut down.
+var errShuttingDown = errors.New("worker is shutting down")
+
+const (
+	// interruptedWaitTimeout bounds the time spent waiting for canceled
+	// fetches to return once the shutdown deadline has passed.
+	interruptedWaitTimeout = 5 * time.Second
+
+	// markInterruptedTimeout bounds the time spent recording interrupted
+	// fetches after that.
+	markInterruptedTimeout = 5 * time.Second
+)
+
+// A fetchTracker keeps track of the fetches in progress, so that they can be
+// drained when the worker shuts down.
+type fetchTracker struct {
+	mu       sync.Mutex
+	draining bool
+	fetches  map[*inflightFetch]bool
+	idle     chan struct{} // closed when draining and there are no fetches
+}
+
+type inflightFetch struct {
+	modulePath, version string
+	cancel              context.CancelFunc
+}
+
+func newFetchTracker() *fetchTracker {
+	return &fetchTracker{fetches: map[*inflightFetch]bool{}}
+}
+
+// start records the start of a fetch of modulePath@version. It returns a
+// context for the fetch, which is canceled if the fetch is interrupted by a
+// shutdown, and a function that the caller must call when the fetch is done.
+// It returns errShuttingDown if the tracker is draining.
+func (t *fetchTracker) start(ctx context.Context, modulePath, version string) (context.Context, func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return nil, nil, errShuttingDown
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	f := &inflightFetch{modulePath: modulePath, version: version, cancel: cancel}
+	t.fetches[f] = true
+	return ctx, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		cancel()
+		delete(t.fetches, f)
+		if t.draining && len(t.fetches) == 0 {
+			close(t.idle)
+		}
+	}, nil
+}
+
+// drain stops the tracker from starting fetches, and waits for the fetches in
+// progress to finish until ctx is done. It cancels any fetches that are still
+// running then, waits up to wait for them to return, and returns them.
+//
+// Waiting matters because a canceled fetch usually still records its failure
+// in module_version_states, which must happen before the fetch is marked
+// interrupted, or it would undo the mark.
+func (t *fetchTracker) drain(ctx context.Context, wait time.Duration) []*inflightFetch {
+	t.mu.Lock()
+	if !t.draining {
+		t.draining = true
+		t.idle = make(chan struct{})
+		if len(t.fetches) == 0 {
+			close(t.idle)
+		}
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+	t.mu.Lock()
+	var interrupted []*inflightFetch
+	for f := range t.fetches {
+		f.cancel()
+		interrupted = append(interrupted, f)
+	}
+	t.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+	}
+	return interrupted
+}
+
+// Shutdown stops the server from accepting fetch tasks, and waits for the
+// fetches in progress to finish until ctx is done. The fetches that are still
+// running then are canceled, and once they have returned, or after a short
+// wait, their module versions are marked to be requeued right away, so that
+// they are not left behind by the retry backoff of their tasks.
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+	defer derrors.Wrap(&err, "Shutdown")
+
+	interrupted := s.fetches.drain(ctx, interruptedWaitTimeout)
+	if len(interrupted) == 0 {
+		log.Infof(ctx, "all fetches finished")
+		return nil
+	}
+	// ctx is done, so use a new context to record the interrupted fetches.
+	mctx, cancel := context.WithTimeout(context.Background(), markInterruptedTimeout)
+	defer cancel()
+	var n int
+	for _, f := range interrupted {
+		if err := s.db.MarkModuleVersionInterrupted(mctx, f.modulePath, f.version); err != nil && !errors.Is(err, derrors.NotFound) {
+			log.Errorf(mctx, "marking %s@%s interrupted: %v", f.modulePath, f.version, err)
+			continue
+		}
+		n++
+	}
+	log.Infof(mctx, "interrupted %d fetches; marked %d for retry", len(interrupted), n)
+	return nil
+}
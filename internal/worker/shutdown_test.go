@@ -0,0 +1,101 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFetchTrackerDrain(t *testing.T) {
+	ctx := context.Background()
+	ft := newFetchTracker()
+
+	// A fetch that finishes before the deadline is not interrupted.
+	_, done, err := ft.start(ctx, "example.com/fast", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		done()
+	}()
+	// A fetch that is still running at the deadline is canceled, and drain
+	// waits for it to return.
+	slowCtx, slowDone, err := ft.start(ctx, "example.com/slow", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowReturned := make(chan struct{})
+	go func() {
+		<-slowCtx.Done()
+		time.Sleep(10 * time.Millisecond)
+		close(slowReturned)
+		slowDone()
+	}()
+
+	dctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	interrupted := ft.drain(dctx, time.Minute)
+	if len(interrupted) != 1 || interrupted[0].modulePath != "example.com/slow" {
+		t.Fatalf("got %d interrupted fetches, want only example.com/slow", len(interrupted))
+	}
+	select {
+	case <-slowReturned:
+	default:
+		t.Error("drain returned before the interrupted fetch")
+	}
+	if _, _, err := ft.start(ctx, "example.com/late", "v1.0.0"); !errors.Is(err, errShuttingDown) {
+		t.Errorf("start after drain: got %v, want errShuttingDown", err)
+	}
+}
+
+func TestFetchTrackerDrainIdle(t *testing.T) {
+	ft := newFetchTracker()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if interrupted := ft.drain(ctx, time.Minute); len(interrupted) != 0 {
+		t.Errorf("got %d interrupted fetches, want 0", len(interrupted))
+	}
+	if ctx.Err() != nil {
+		t.Error("drain of an idle tracker waited for the deadline")
+	}
+}
+
+func TestFetchTrackerDrainWaitBound(t *testing.T) {
+	ctx := context.Background()
+	ft := newFetchTracker()
+	// A fetch that ignores cancellation does not hold up the shutdown for
+	// more than the wait.
+	_, done, err := ft.start(ctx, "example.com/stuck", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer done()
+	dctx, cancel := context.WithCancel(ctx)
+	cancel()
+	start := time.Now()
+	if interrupted := ft.drain(dctx, 50*time.Millisecond); len(interrupted) != 1 {
+		t.Errorf("got %d interrupted fetches, want 1", len(interrupted))
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("drain took %s, want about the wait", d)
+	}
+}
+
+func TestProcessFetchWhileDraining(t *testing.T) {
+	ctx := context.Background()
+	s := &Server{fetches: newFetchTracker()}
+	s.fetches.drain(ctx, time.Minute)
+	// Tasks of the queues that the worker processes itself are turned away
+	// during a shutdown, like requests to the /fetch handler.
+	code, err := s.ProcessFetch(ctx, "example.com/m", "v1.0.0")
+	if code != http.StatusServiceUnavailable || !errors.Is(err, errShuttingDown) {
+		t.Errorf("ProcessFetch: got %d, %v; want %d, errShuttingDown", code, err, http.StatusServiceUnavailable)
+	}
+}
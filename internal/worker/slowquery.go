@@ -0,0 +1,31 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// handleSlowQueriesPage serves the page listing recent slow queries.
+func (s *Server) handleSlowQueriesPage(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	limit := parseIntParam(r, "limit", 100)
+	queries, err := s.db.GetSlowQueries(ctx, limit)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := s.slowQueriesTemplate.Execute(&buf, queries); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, &buf); err != nil {
+		log.Errorf(ctx, "Error copying buffer to ResponseWriter: %v", err)
+	}
+	return nil
+}
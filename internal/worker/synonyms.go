@@ -0,0 +1,100 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// handleListSearchSynonyms lists the synonyms that search queries are
+// expanded with.
+func (s *Server) handleListSearchSynonyms(w http.ResponseWriter, r *http.Request) error {
+	synonyms, err := s.db.GetSearchSynonyms(r.Context())
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, syn := range synonyms {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", syn.Term, syn.Synonym, syn.CreatedBy, formatTime(&syn.CreatedAt))
+	}
+	return nil
+}
+
+// handleAddSearchSynonym makes search queries for the "term" query parameter
+// also match the "synonym" parameter. The admin user is recorded with the
+// synonym.
+func (s *Server) handleAddSearchSynonym(w http.ResponseWriter, r *http.Request) error {
+	syn := &postgres.SearchSynonym{
+		Term:      r.FormValue("term"),
+		Synonym:   r.FormValue("synonym"),
+		CreatedBy: adminUser(r.Context()),
+	}
+	if syn.Term == "" || syn.Synonym == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'term' and 'synonym' query params")}
+	}
+	ctx := r.Context()
+	old, err := s.searchSynonym(ctx, syn.Term, syn.Synonym)
+	if err != nil {
+		return err
+	}
+	if err := s.db.InsertSearchSynonym(ctx, syn); err != nil {
+		if errors.Is(err, derrors.InvalidArgument) {
+			return &serverError{http.StatusBadRequest, err}
+		}
+		return err
+	}
+	s.audit(ctx, "search-synonym.add", syn.Term, old, syn)
+	log.Infof(ctx, "%s added search synonym %q for %q", syn.CreatedBy, syn.Synonym, syn.Term)
+	fmt.Fprintf(w, "Searches for %q now also match %q.\n", syn.Term, syn.Synonym)
+	return nil
+}
+
+// handleDeleteSearchSynonym deletes the "synonym" query parameter as a
+// synonym of the "term" parameter.
+func (s *Server) handleDeleteSearchSynonym(w http.ResponseWriter, r *http.Request) error {
+	term := r.FormValue("term")
+	synonym := r.FormValue("synonym")
+	if term == "" || synonym == "" {
+		return &serverError{http.StatusBadRequest, errors.New("must provide 'term' and 'synonym' query params")}
+	}
+	ctx := r.Context()
+	user := adminUser(ctx)
+	old, err := s.searchSynonym(ctx, term, synonym)
+	if err != nil {
+		return err
+	}
+	if err := s.db.DeleteSearchSynonym(ctx, term, synonym); err != nil {
+		if errors.Is(err, derrors.NotFound) {
+			return &serverError{http.StatusNotFound, err}
+		}
+		return err
+	}
+	s.audit(ctx, "search-synonym.delete", term, old, nil)
+	log.Infof(ctx, "%s deleted search synonym %q for %q", user, synonym, term)
+	fmt.Fprintf(w, "Searches for %q no longer match %q.\n", term, synonym)
+	return nil
+}
+
+// searchSynonym returns the search synonym for term and synonym, or nil if
+// there is none.
+func (s *Server) searchSynonym(ctx context.Context, term, synonym string) (*postgres.SearchSynonym, error) {
+	synonyms, err := s.db.GetSearchSynonyms(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, syn := range synonyms {
+		if syn.Term == term && syn.Synonym == synonym {
+			return syn, nil
+		}
+	}
+	return nil, nil
+}
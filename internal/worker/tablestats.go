@@ -0,0 +1,117 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/log"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+// tableStatsAlert starts the log entries of tables whose statistics exceed
+// the thresholds, so that log-based alerts can match them.
+const tableStatsAlert = "table stats threshold exceeded"
+
+// handleRecordTableStats records the statistics of the tables of the database,
+// and logs an error for each table that exceeds the thresholds of the
+// configuration.
+func (s *Server) handleRecordTableStats(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	stats, err := s.db.RecordTableStats(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	n := 0
+	for _, ts := range stats {
+		if problems := tableStatsProblems(ts, s.cfg.TableStats, now); len(problems) > 0 {
+			log.Errorf(ctx, "%s: %s: %v", tableStatsAlert, ts.TableName, problems)
+			n++
+		}
+	}
+	log.Infof(ctx, "recorded stats of %d tables, %d over thresholds", len(stats), n)
+	fmt.Fprintf(w, "Recorded stats of %d tables; %d are over thresholds.\n", len(stats), n)
+	return nil
+}
+
+// tableStatsProblems returns descriptions of the ways in which ts exceeds the
+// thresholds of settings at the time now. Tables with fewer than
+// settings.MinDeadTuples dead tuples have none.
+func tableStatsProblems(ts *postgres.TableStats, settings config.TableStatsSettings, now time.Time) []string {
+	if ts.DeadTuples < settings.MinDeadTuples {
+		return nil
+	}
+	var problems []string
+	if r := ts.DeadTupleRatio(); settings.MaxDeadTupleRatio > 0 && r > settings.MaxDeadTupleRatio {
+		problems = append(problems, fmt.Sprintf("%.0f%% of tuples are dead (threshold %.0f%%)", 100*r, 100*settings.MaxDeadTupleRatio))
+	}
+	if settings.MaxVacuumAge > 0 {
+		last := ts.LastVacuumed()
+		switch {
+		case last.IsZero():
+			problems = append(problems, "never vacuumed")
+		case now.Sub(last) > settings.MaxVacuumAge:
+			problems = append(problems, fmt.Sprintf("last vacuumed %s ago (threshold %s)",
+				now.Sub(last).Round(time.Hour), settings.MaxVacuumAge))
+		}
+	}
+	return problems
+}
+
+// handleTableStatsPage serves the page showing the most recent statistics of
+// the tables of the database.
+func (s *Server) handleTableStatsPage(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	stats, err := s.db.GetTableStats(ctx)
+	if err != nil {
+		return err
+	}
+	type row struct {
+		*postgres.TableStats
+		DeadPercent float64
+		Problems    []string
+	}
+	page := struct {
+		Thresholds     config.TableStatsSettings
+		MaxDeadPercent float64
+		Tables         []row
+	}{
+		Thresholds:     s.cfg.TableStats,
+		MaxDeadPercent: 100 * s.cfg.TableStats.MaxDeadTupleRatio,
+	}
+	now := time.Now()
+	for _, ts := range stats {
+		page.Tables = append(page.Tables, row{ts, 100 * ts.DeadTupleRatio(), tableStatsProblems(ts, s.cfg.TableStats, now)})
+	}
+	var buf bytes.Buffer
+	if err := s.tableStatsTemplate.Execute(&buf, page); err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, &buf); err != nil {
+		log.Errorf(ctx, "Error copying buffer to ResponseWriter: %v", err)
+	}
+	return nil
+}
+
+// formatBytes returns n bytes in the largest binary unit that keeps the
+// value at least 1, like "1.5 GiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
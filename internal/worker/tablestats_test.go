@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/pkgsite/internal/config"
+	"golang.org/x/pkgsite/internal/postgres"
+)
+
+func TestTableStatsProblems(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	settings := config.TableStatsSettings{
+		MaxDeadTupleRatio: 0.2,
+		MaxVacuumAge:      7 * 24 * time.Hour,
+		MinDeadTuples:     100,
+	}
+	recent := now.Add(-time.Hour)
+	for _, test := range []struct {
+		name string
+		ts   postgres.TableStats
+		want []string
+	}{
+		{
+			name: "healthy",
+			ts:   postgres.TableStats{LiveTuples: 1000, DeadTuples: 100, LastAutovacuum: recent},
+		},
+		{
+			name: "few dead tuples",
+			ts:   postgres.TableStats{LiveTuples: 10, DeadTuples: 90},
+		},
+		{
+			name: "bloated",
+			ts:   postgres.TableStats{LiveTuples: 500, DeadTuples: 500, LastVacuum: recent},
+			want: []string{"50% of tuples are dead (threshold 20%)"},
+		},
+		{
+			name: "never vacuumed",
+			ts:   postgres.TableStats{LiveTuples: 1000, DeadTuples: 100},
+			want: []string{"never vacuumed"},
+		},
+		{
+			name: "not vacuumed lately",
+			ts: postgres.TableStats{LiveTuples: 1000, DeadTuples: 100,
+				LastVacuum: now.Add(-10 * 24 * time.Hour), LastAutovacuum: now.Add(-8 * 24 * time.Hour)},
+			want: []string{"last vacuumed 192h0m0s ago (threshold 168h0m0s)"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got := tableStatsProblems(&test.ts, settings, now)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for _, test := range []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+	} {
+		if got := formatBytes(test.in); got != test.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"golang.org/x/pkgsite/internal/derrors"
+	"golang.org/x/pkgsite/internal/log"
+)
+
+// handleSyncVulns copies the advisories of the modules whose advisories have
+// changed in the vulnerability database since they were last synced. It syncs
+// at most "limit" modules, in order of module path, so that a large change is
+// spread over several runs.
+func (s *Server) handleSyncVulns(w http.ResponseWriter, r *http.Request) error {
+	if s.vulnClient == nil {
+		return errors.New("vulnerability database client is not configured")
+	}
+	ctx := r.Context()
+	limit := parseIntParam(r, "limit", 100)
+	index, err := s.vulnClient.Index(ctx)
+	if err != nil {
+		return err
+	}
+	synced, err := s.db.GetVulnModifiedTimes(ctx)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for modulePath, modified := range index {
+		if t, ok := synced[modulePath]; !ok || modified.After(t) {
+			changed = append(changed, modulePath)
+		}
+	}
+	sort.Strings(changed)
+	if len(changed) > limit {
+		changed = changed[:limit]
+	}
+	for _, modulePath := range changed {
+		entries, err := s.vulnClient.ByModule(ctx, modulePath)
+		if err != nil && !errors.Is(err, derrors.NotFound) {
+			return err
+		}
+		if err := s.db.UpsertVulns(ctx, modulePath, entries); err != nil {
+			return err
+		}
+	}
+	log.Infof(ctx, "synced advisories of %d modules", len(changed))
+	fmt.Fprintf(w, "Synced advisories of %d modules.\n", len(changed))
+	return nil
+}
@@ -0,0 +1,47 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE reprocessing_campaigns (
+    name               text NOT NULL PRIMARY KEY,
+    app_version        text NOT NULL,
+    statuses           integer[] NOT NULL,
+    module_path_prefix text DEFAULT ''::text NOT NULL,
+    state              text DEFAULT 'active'::text NOT NULL,
+    created_by         text NOT NULL,
+    created_at         timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at         timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CHECK (state IN ('active', 'paused', 'cancelled', 'done'))
+);
+COMMENT ON TABLE reprocessing_campaigns IS
+'TABLE reprocessing_campaigns contains named cohorts of module versions that are scheduled for reprocessing.';
+
+CREATE TRIGGER set_updated_at BEFORE INSERT OR UPDATE ON reprocessing_campaigns
+    FOR EACH ROW EXECUTE PROCEDURE trigger_modify_updated_at();
+COMMENT ON TRIGGER set_updated_at ON reprocessing_campaigns IS
+'TRIGGER set_updated_at updates the value of the updated_at column to the current timestamp whenever a row is inserted or updated to the table.';
+
+CREATE TABLE reprocessing_campaign_versions (
+    campaign_name  text NOT NULL REFERENCES reprocessing_campaigns (name) ON DELETE CASCADE,
+    module_path    text NOT NULL,
+    version        text NOT NULL,
+    initial_status integer NOT NULL,
+    queued_at      timestamp with time zone,
+
+    PRIMARY KEY (campaign_name, module_path, version),
+    FOREIGN KEY (module_path, version)
+        REFERENCES module_version_states (module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE reprocessing_campaign_versions IS
+'TABLE reprocessing_campaign_versions contains the module versions that belong to each reprocessing campaign.';
+COMMENT ON COLUMN reprocessing_campaign_versions.queued_at IS
+'COLUMN queued_at is the time the module version was marked for reprocessing, or NULL if it has not been yet.';
+
+CREATE INDEX idx_reprocessing_campaign_versions_queued_at ON reprocessing_campaign_versions (campaign_name, queued_at);
+COMMENT ON INDEX idx_reprocessing_campaign_versions_queued_at IS
+'INDEX idx_reprocessing_campaign_versions_queued_at is used to find the next module versions of a campaign to reprocess.';
+
+END;
@@ -0,0 +1,31 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE module_version_fetch_attempts (
+    id               INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    module_path      text NOT NULL,
+    version          text NOT NULL,
+    app_version      text NOT NULL,
+    status           integer NOT NULL,
+    error            text DEFAULT ''::text NOT NULL,
+    duration_seconds double precision NOT NULL,
+    attempted_at     timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    FOREIGN KEY (module_path, version)
+        REFERENCES module_version_states (module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE module_version_fetch_attempts IS
+'TABLE module_version_fetch_attempts records the result of every attempt by the worker to fetch a module version.';
+
+CREATE INDEX idx_module_version_fetch_attempts_module_path ON module_version_fetch_attempts (module_path, attempted_at DESC);
+COMMENT ON INDEX idx_module_version_fetch_attempts_module_path IS
+'INDEX idx_module_version_fetch_attempts_module_path is used to get the fetch history of all versions of a module.';
+
+CREATE INDEX idx_module_version_fetch_attempts_attempted_at ON module_version_fetch_attempts (attempted_at);
+COMMENT ON INDEX idx_module_version_fetch_attempts_attempted_at IS
+'INDEX idx_module_version_fetch_attempts_attempted_at is used to delete old fetch attempts.';
+
+END;
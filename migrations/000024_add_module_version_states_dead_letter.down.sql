@@ -0,0 +1,12 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE module_version_states
+    DROP COLUMN dead_lettered_at,
+    DROP COLUMN dead_letter_reason,
+    DROP COLUMN consecutive_failures;
+
+END;
@@ -0,0 +1,23 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE module_version_states
+    ADD COLUMN dead_lettered_at timestamp with time zone,
+    ADD COLUMN dead_letter_reason text DEFAULT ''::text NOT NULL,
+    ADD COLUMN consecutive_failures integer DEFAULT 0 NOT NULL;
+COMMENT ON COLUMN module_version_states.dead_lettered_at IS
+'COLUMN dead_lettered_at is the time the module version was moved to the dead-letter queue after failing repeatedly, or NULL if it is scheduled normally.';
+COMMENT ON COLUMN module_version_states.dead_letter_reason IS
+'COLUMN dead_letter_reason describes why the module version was moved to the dead-letter queue.';
+COMMENT ON COLUMN module_version_states.consecutive_failures IS
+'COLUMN consecutive_failures is the number of attempts in a row that failed with a status of 500 or above. It is reset by any other status.';
+
+CREATE INDEX idx_module_version_states_dead_lettered_at ON module_version_states (dead_lettered_at)
+    WHERE dead_lettered_at IS NOT NULL;
+COMMENT ON INDEX idx_module_version_states_dead_lettered_at IS
+'INDEX idx_module_version_states_dead_lettered_at is used to list the module versions in the dead-letter queue.';
+
+END;
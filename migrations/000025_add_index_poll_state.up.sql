@@ -0,0 +1,30 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE index_poll_state (
+    singleton         boolean DEFAULT true NOT NULL PRIMARY KEY,
+    cursor            timestamp with time zone NOT NULL,
+    interval_seconds  integer NOT NULL,
+    next_poll_at      timestamp with time zone NOT NULL,
+    last_polled_at    timestamp with time zone NOT NULL,
+    last_num_versions integer NOT NULL,
+    updated_at        timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CHECK (singleton)
+);
+COMMENT ON TABLE index_poll_state IS
+'TABLE index_poll_state contains a single row describing the state of polling the module index.';
+COMMENT ON COLUMN index_poll_state.cursor IS
+'COLUMN cursor is the index timestamp after which versions have not yet been read from the module index.';
+COMMENT ON COLUMN index_poll_state.interval_seconds IS
+'COLUMN interval_seconds is the current interval between polls, which adapts to the rate at which new versions appear.';
+
+CREATE TRIGGER set_updated_at BEFORE INSERT OR UPDATE ON index_poll_state
+    FOR EACH ROW EXECUTE PROCEDURE trigger_modify_updated_at();
+COMMENT ON TRIGGER set_updated_at ON index_poll_state IS
+'TRIGGER set_updated_at updates the value of the updated_at column to the current timestamp whenever a row is inserted or updated to the table.';
+
+END;
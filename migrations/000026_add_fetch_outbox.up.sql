@@ -0,0 +1,29 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE fetch_outbox (
+    id            INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    dedup_key     text NOT NULL UNIQUE,
+    module_path   text NOT NULL,
+    version       text NOT NULL,
+    created_at    timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    dispatched_at timestamp with time zone,
+
+    FOREIGN KEY (module_path, version)
+        REFERENCES module_version_states (module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE fetch_outbox IS
+'TABLE fetch_outbox contains module versions read from the module index that must be published to the fetch task queue. Rows are written in the same transaction as module_version_states.';
+COMMENT ON COLUMN fetch_outbox.dedup_key IS
+'COLUMN dedup_key is used as the task ID when publishing the row, so that publishing it more than once results in a single task.';
+COMMENT ON COLUMN fetch_outbox.dispatched_at IS
+'COLUMN dispatched_at is the time the row was published to the task queue, or NULL if it has not been published.';
+
+CREATE INDEX idx_fetch_outbox_pending ON fetch_outbox (id) WHERE dispatched_at IS NULL;
+COMMENT ON INDEX idx_fetch_outbox_pending IS
+'INDEX idx_fetch_outbox_pending is used to find rows that have not yet been published.';
+
+END;
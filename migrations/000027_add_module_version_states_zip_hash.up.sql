@@ -0,0 +1,15 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE module_version_states
+    ADD COLUMN zip_hash text DEFAULT ''::text NOT NULL,
+    ADD COLUMN proxy text DEFAULT ''::text NOT NULL;
+COMMENT ON COLUMN module_version_states.zip_hash IS
+'COLUMN zip_hash is the go.sum hash of the module zip that was last processed, or empty if it is not known.';
+COMMENT ON COLUMN module_version_states.proxy IS
+'COLUMN proxy is the URL of the module proxy that served the module version when it was last processed, or empty if it is not known.';
+
+END;
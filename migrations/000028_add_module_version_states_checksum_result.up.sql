@@ -0,0 +1,12 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE module_version_states
+    ADD COLUMN checksum_result text DEFAULT ''::text NOT NULL;
+COMMENT ON COLUMN module_version_states.checksum_result IS
+'COLUMN checksum_result is the result of verifying the module version against the checksum database: "verified", "unverified", "mismatch", or empty if it was not checked.';
+
+END;
@@ -0,0 +1,28 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE index_gaps (
+    id            INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    since         timestamp with time zone NOT NULL,
+    until         timestamp with time zone NOT NULL,
+    detected_at   timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    reconciled_at timestamp with time zone,
+    num_missing   integer,
+
+    CHECK (since <= until)
+);
+COMMENT ON TABLE index_gaps IS
+'TABLE index_gaps contains windows of module index timestamps in which versions may have been missed, for example because of an outage or a jump in the poll cursor.';
+COMMENT ON COLUMN index_gaps.reconciled_at IS
+'COLUMN reconciled_at is the time the window was compared with module_version_states, or NULL if it has not been.';
+COMMENT ON COLUMN index_gaps.num_missing IS
+'COLUMN num_missing is the number of versions in the window that were missing from module_version_states when it was reconciled.';
+
+CREATE INDEX idx_index_gaps_pending ON index_gaps (id) WHERE reconciled_at IS NULL;
+COMMENT ON INDEX idx_index_gaps_pending IS
+'INDEX idx_index_gaps_pending is used to find windows that have not yet been reconciled.';
+
+END;
@@ -0,0 +1,27 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE slow_queries (
+    id          INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    recorded_at timestamp with time zone NOT NULL,
+    query       text NOT NULL,
+    args        text NOT NULL,
+    duration_ms double precision NOT NULL,
+    error       text NOT NULL,
+    plan        text NOT NULL
+);
+COMMENT ON TABLE slow_queries IS
+'TABLE slow_queries contains queries that took longer than the slow query threshold, for diagnosis from the worker.';
+COMMENT ON COLUMN slow_queries.args IS
+'COLUMN args describes the arguments of the query, with the contents of strings redacted.';
+COMMENT ON COLUMN slow_queries.plan IS
+'COLUMN plan is the output of EXPLAIN (ANALYZE, BUFFERS) for the query, or empty if it was not captured.';
+
+CREATE INDEX idx_slow_queries_recorded_at ON slow_queries (recorded_at);
+COMMENT ON INDEX idx_slow_queries_recorded_at IS
+'INDEX idx_slow_queries_recorded_at is used to show the most recent slow queries and to delete old ones.';
+
+END;
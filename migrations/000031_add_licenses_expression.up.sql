@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE licenses ADD COLUMN expression text DEFAULT ''::text NOT NULL;
+COMMENT ON COLUMN licenses.expression IS
+'COLUMN expression is the SPDX license expression for the license file, such as "MIT OR Apache-2.0", or empty if it is not known.';
+
+END;
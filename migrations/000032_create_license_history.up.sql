@@ -0,0 +1,26 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE license_history (
+    module_path      text NOT NULL,
+    version          text NOT NULL,
+    previous_version text NOT NULL,
+    added_types      text[] NOT NULL,
+    removed_types    text[] NOT NULL,
+    created_at       timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (module_path, version),
+    FOREIGN KEY (module_path, version) REFERENCES modules(module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE license_history IS
+'TABLE license_history records the versions of a module whose set of license types differs from that of the preceding version.';
+COMMENT ON COLUMN license_history.previous_version IS
+'COLUMN previous_version is the version of the module that immediately precedes version in semver order.';
+COMMENT ON COLUMN license_history.added_types IS
+'COLUMN added_types are the license types detected in version but not in previous_version.';
+COMMENT ON COLUMN license_history.removed_types IS
+'COLUMN removed_types are the license types detected in previous_version but not in version.';
+
+END;
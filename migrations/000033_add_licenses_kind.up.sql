@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE licenses ADD COLUMN kind text DEFAULT 'license' NOT NULL;
+COMMENT ON COLUMN licenses.kind IS
+'COLUMN kind is the kind of file: ''license'' for a license file, or ''notice'' or ''patents'' for a file that accompanies the licenses, like NOTICE or PATENTS. Only license files have types.';
+
+END;
@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+DROP TABLE license_reviews;
+ALTER TABLE licenses DROP COLUMN low_confidence;
+ALTER TABLE licenses DROP COLUMN coverage_percent;
+
+END;
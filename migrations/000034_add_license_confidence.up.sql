@@ -0,0 +1,44 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE licenses ADD COLUMN coverage_percent double precision;
+COMMENT ON COLUMN licenses.coverage_percent IS
+'COLUMN coverage_percent is the percentage of the file covered by known license text, as reported by licensecheck. It is a copy of coverage->Percent that can be queried efficiently. It is NULL for files that are not licenses.';
+
+ALTER TABLE licenses ADD COLUMN low_confidence boolean DEFAULT false NOT NULL;
+COMMENT ON COLUMN licenses.low_confidence IS
+'COLUMN low_confidence is true if the types of the license file were determined from less than 90% coverage. See licenses.Metadata.IsLowConfidence.';
+
+UPDATE licenses
+SET
+    coverage_percent = (coverage->>'Percent')::double precision,
+    low_confidence = (coverage->>'Percent')::double precision < 90
+        AND ((coverage->>'Percent')::double precision > 0 OR 'UNKNOWN' = ANY(types) OR cardinality(types) = 0)
+WHERE kind = 'license';
+
+CREATE INDEX idx_licenses_low_confidence ON licenses (coverage_percent) WHERE low_confidence;
+COMMENT ON INDEX idx_licenses_low_confidence IS
+'INDEX idx_licenses_low_confidence is used to list low-confidence licenses for review.';
+
+CREATE TABLE license_reviews (
+    module_path          text NOT NULL,
+    version              text NOT NULL,
+    package_path         text NOT NULL,
+    is_redistributable   boolean NOT NULL,
+    min_coverage_percent double precision NOT NULL,
+    license_file_paths   text[] NOT NULL,
+    created_at           timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (module_path, version, package_path),
+    FOREIGN KEY (module_path, version) REFERENCES modules(module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE license_reviews IS
+'TABLE license_reviews lists the packages whose redistributability was decided from at least one low-confidence license, so that the decision can be reviewed manually.';
+COMMENT ON COLUMN license_reviews.is_redistributable IS
+'COLUMN is_redistributable is the decision that was made for the package.';
+COMMENT ON COLUMN license_reviews.license_file_paths IS
+'COLUMN license_file_paths are the low-confidence license files that apply to the package.';
+
+END;
@@ -0,0 +1,27 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE license_overrides (
+    path               text NOT NULL,
+    version            text DEFAULT ''::text NOT NULL,
+    is_redistributable boolean NOT NULL,
+    reason             text NOT NULL,
+    created_by         text NOT NULL,
+    created_at         timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (path, version),
+    CHECK (path <> ''::text),
+    CHECK (reason <> ''::text),
+    CHECK (created_by <> ''::text)
+);
+COMMENT ON TABLE license_overrides IS
+'TABLE license_overrides contains manual decisions about the redistributability of modules and packages, which take precedence over license detection.';
+COMMENT ON COLUMN license_overrides.path IS
+'COLUMN path is a module path or path prefix. The override applies to the path and every path below it.';
+COMMENT ON COLUMN license_overrides.version IS
+'COLUMN version is the version the override applies to, or the empty string if it applies to all versions.';
+
+END;
@@ -0,0 +1,80 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE repo_status (
+    repo_url   text NOT NULL PRIMARY KEY,
+    status     text NOT NULL,
+    checked_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    CHECK (status IN ('active', 'archived', 'deleted', 'unknown'))
+);
+COMMENT ON TABLE repo_status IS
+'TABLE repo_status contains the status of source repositories, as last reported by their hosting sites.';
+COMMENT ON COLUMN repo_status.repo_url IS
+'COLUMN repo_url is the RepoURL field of modules.source_info.';
+
+ALTER TABLE search_documents ADD COLUMN repo_inactive boolean DEFAULT false NOT NULL;
+COMMENT ON COLUMN search_documents.repo_inactive IS
+'COLUMN repo_inactive records whether the repository of the module is archived or deleted. Such packages are ranked lower in search.';
+
+-- Redefine popular_search to apply a penalty to packages whose repository
+-- is archived or deleted.
+DROP FUNCTION popular_search(rawquery text, lim integer, off integer, redist_factor real, go_mod_factor real);
+CREATE FUNCTION popular_search(rawquery text, lim integer, off integer, redist_factor real, go_mod_factor real, inactive_factor real) RETURNS SETOF search_result
+    LANGUAGE plpgsql
+    AS $$
+	DECLARE cur CURSOR(query TSQUERY) FOR
+		SELECT
+			package_path,
+			module_path,
+			version,
+			commit_time,
+			imported_by_count,
+			(
+				-- default D, C, B, A weights are {0.1, 0.2, 0.4, 1.0}
+				ts_rank('{0.1, 0.2, 1.0, 1.0}', tsv_search_tokens, query) *
+				ln(exp(1)+imported_by_count) *
+				CASE WHEN redistributable THEN 1 ELSE redist_factor END *
+				CASE WHEN COALESCE(has_go_mod, true) THEN 1 ELSE go_mod_factor END *
+				CASE WHEN repo_inactive THEN inactive_factor ELSE 1 END *
+				CASE WHEN tsv_search_tokens @@ query THEN 1 ELSE 0 END
+			) score
+			FROM search_documents
+			ORDER BY imported_by_count DESC;
+	top search_result[];
+	res search_result;
+	last_idx INT;
+BEGIN
+	last_idx := lim+off;
+	top := array_fill(NULL::search_result, array[last_idx]);
+	OPEN cur(query := websearch_to_tsquery(rawquery));
+	FETCH cur INTO res;
+	WHILE found LOOP
+		IF top[last_idx] IS NULL OR res.score >= top[last_idx].score THEN
+			FOR i IN 1..last_idx LOOP
+				IF top[i] IS NULL OR
+					(res.score > top[i].score) OR
+					(res.score = top[i].score AND res.commit_time > top[i].commit_time) OR
+					(res.score = top[i].score AND res.commit_time = top[i].commit_time AND
+					 res.package_path < top[i].package_path) THEN
+					top := (top[1:i-1] || res) || top[i:last_idx-1];
+					EXIT;
+				END IF;
+			END LOOP;
+		END IF;
+		IF top[last_idx].score > ln(exp(1)+res.imported_by_count) THEN
+			EXIT;
+		END IF;
+		FETCH cur INTO res;
+	END LOOP;
+	CLOSE cur;
+	RETURN QUERY SELECT * FROM UNNEST(top[off+1:last_idx])
+		WHERE package_path IS NOT NULL AND score > 0.1;
+END; $$;
+COMMENT ON FUNCTION popular_search(rawquery text, lim integer, off integer, redist_factor real, go_mod_factor real, inactive_factor real) IS
+'FUNCTION popular_search is used to generate results for search. It is implemented as a stored function, so that we can use a cursor to scan search documents procedurally, and stop scanning early, whenever our search results are provably correct.';
+
+END;
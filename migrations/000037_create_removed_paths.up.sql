@@ -0,0 +1,31 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE removed_paths (
+    path       text NOT NULL,
+    scope      text NOT NULL,
+    version    text DEFAULT ''::text NOT NULL,
+    reason     text NOT NULL,
+    created_by text NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    PRIMARY KEY (path, scope, version),
+    CHECK (path <> ''::text),
+    CHECK (scope IN ('version', 'module', 'prefix')),
+    CHECK ((scope = 'version') = (version <> ''::text)),
+    CHECK (reason <> ''::text),
+    CHECK (created_by <> ''::text)
+);
+COMMENT ON TABLE removed_paths IS
+'TABLE removed_paths contains paths that are not served or fetched for legal reasons, such as a takedown request.';
+COMMENT ON COLUMN removed_paths.scope IS
+'COLUMN scope is what is removed: with "version", one version of the module at path; with "module", every version of it; with "prefix", every path that begins with path.';
+COMMENT ON COLUMN removed_paths.version IS
+'COLUMN version is the removed version, for the scope "version", and the empty string otherwise.';
+COMMENT ON COLUMN removed_paths.reason IS
+'COLUMN reason is shown to users who ask for a removed path.';
+
+END;
@@ -0,0 +1,40 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE audit_log (
+    id          bigserial PRIMARY KEY,
+    actor       text NOT NULL,
+    action      text NOT NULL,
+    auth_method text DEFAULT ''::text NOT NULL,
+    target      text NOT NULL,
+    before      jsonb,
+    after       jsonb,
+    params      jsonb,
+    status      integer,
+    created_at  timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+COMMENT ON TABLE audit_log IS
+'TABLE audit_log records every administrative change, such as an exclusion, a license override or an experiment change, and every request to the admin endpoints of the worker that change state, with who made it and what it changed or returned.';
+COMMENT ON COLUMN audit_log.action IS
+'COLUMN action names the kind of change, such as "experiment.set", or is "request" for a request to an admin endpoint.';
+COMMENT ON COLUMN audit_log.auth_method IS
+'COLUMN auth_method is how the actor of a request was authenticated: "iap", "token" or "insecure". It is empty for changes.';
+COMMENT ON COLUMN audit_log.target IS
+'COLUMN target identifies what was changed, such as the name of an experiment or a module path and version. It is empty for changes without one, like clearing the cache.';
+COMMENT ON COLUMN audit_log.before IS
+'COLUMN before holds the state of the target before the change, or NULL if it did not exist.';
+COMMENT ON COLUMN audit_log.after IS
+'COLUMN after holds the state of the target after the change, or NULL if it was deleted.';
+COMMENT ON COLUMN audit_log.params IS
+'COLUMN params holds the query and form parameters of a request, as a JSON object from names to arrays of values. It is NULL for changes.';
+COMMENT ON COLUMN audit_log.status IS
+'COLUMN status is the HTTP status of the response to a request. It is NULL for changes.';
+
+CREATE INDEX idx_audit_log_target ON audit_log(target);
+CREATE INDEX idx_audit_log_actor ON audit_log(actor);
+CREATE INDEX idx_audit_log_action ON audit_log(action);
+
+END;
@@ -0,0 +1,34 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE vulns (
+    id          text NOT NULL,
+    module_path text NOT NULL,
+    modified    timestamp with time zone NOT NULL,
+    entry       jsonb NOT NULL,
+    PRIMARY KEY (id, module_path)
+);
+COMMENT ON TABLE vulns IS
+'TABLE vulns holds the advisories of the Go vulnerability database, in the OSV format, for each module they affect.';
+COMMENT ON COLUMN vulns.modified IS
+'COLUMN modified is when the advisory was last modified in the vulnerability database.';
+
+CREATE INDEX idx_vulns_module_path ON vulns(module_path);
+
+CREATE TABLE vuln_affected_versions (
+    vuln_id     text NOT NULL,
+    module_path text NOT NULL,
+    version     text NOT NULL,
+    PRIMARY KEY (vuln_id, module_path, version),
+    FOREIGN KEY (vuln_id, module_path) REFERENCES vulns(id, module_path) ON DELETE CASCADE,
+    FOREIGN KEY (module_path, version) REFERENCES modules(module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE vuln_affected_versions IS
+'TABLE vuln_affected_versions records the module versions that each advisory in vulns applies to. It is updated when advisories are synced and when module versions are inserted.';
+
+CREATE INDEX idx_vuln_affected_versions_module_path_version ON vuln_affected_versions(module_path, version);
+
+END;
@@ -0,0 +1,20 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE search_documents ADD COLUMN num_vulns integer DEFAULT 0 NOT NULL;
+COMMENT ON COLUMN search_documents.num_vulns IS
+'COLUMN num_vulns is the number of advisories in vulns that apply to the version of the document. It is kept up to date when advisories are synced.';
+
+UPDATE search_documents sd
+SET num_vulns = a.n
+FROM (
+    SELECT module_path, version, count(*) AS n
+    FROM vuln_affected_versions
+    GROUP BY module_path, version
+) a
+WHERE sd.module_path = a.module_path AND sd.version = a.version;
+
+END;
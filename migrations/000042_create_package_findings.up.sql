@@ -0,0 +1,23 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE package_findings (
+    package_path text NOT NULL,
+    module_path  text NOT NULL,
+    version      text NOT NULL,
+    analyzer     text NOT NULL,
+    category     text NOT NULL,
+    count        integer NOT NULL,
+    PRIMARY KEY (package_path, module_path, version, analyzer, category),
+    FOREIGN KEY (package_path, module_path, version)
+        REFERENCES packages(path, module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE package_findings IS
+'TABLE package_findings holds the number of problems of each kind (category) that an analyzer, such as vet, reported in the source of a package when it was fetched. Categories without problems have no rows. The counts are quality signals of packages.';
+
+CREATE INDEX idx_package_findings_module_path_version ON package_findings(module_path, version);
+
+END;
@@ -0,0 +1,25 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE fetch_queue (
+    id           INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    task_key     text NOT NULL UNIQUE,
+    module_path  text NOT NULL,
+    version      text NOT NULL,
+    created_at   timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    leased_until timestamp with time zone,
+    attempts     integer DEFAULT 0 NOT NULL
+);
+COMMENT ON TABLE fetch_queue IS
+'TABLE fetch_queue holds the fetch tasks of deployments that use Postgres as their task queue instead of Cloud Tasks. A row is deleted once its fetch has finished, or has been tried too many times.';
+COMMENT ON COLUMN fetch_queue.task_key IS
+'COLUMN task_key de-duplicates tasks: a task whose key is already in the queue is not added again.';
+COMMENT ON COLUMN fetch_queue.leased_until IS
+'COLUMN leased_until is the time until which a worker is processing the task, or NULL if no worker has taken it. A task whose lease has expired is taken again.';
+COMMENT ON COLUMN fetch_queue.attempts IS
+'COLUMN attempts is the number of times a worker has taken the task.';
+
+END;
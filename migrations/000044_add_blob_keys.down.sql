@@ -0,0 +1,11 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules DROP COLUMN zip_blob_key;
+ALTER TABLE packages DROP COLUMN documentation_blob_key;
+ALTER TABLE documentation DROP COLUMN html_blob_key;
+
+END;
@@ -0,0 +1,19 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN zip_blob_key text;
+COMMENT ON COLUMN modules.zip_blob_key IS
+'COLUMN zip_blob_key is the key of the blob that holds the zip of the module, or NULL if the zip is not stored.';
+
+ALTER TABLE packages ADD COLUMN documentation_blob_key text;
+COMMENT ON COLUMN packages.documentation_blob_key IS
+'COLUMN documentation_blob_key is the key of the blob that holds the documentation of the package, or NULL if the documentation is stored in the documentation column.';
+
+ALTER TABLE documentation ADD COLUMN html_blob_key text;
+COMMENT ON COLUMN documentation.html_blob_key IS
+'COLUMN html_blob_key is the key of the blob that holds the HTML of the documentation, or NULL if the HTML is stored in the html column.';
+
+END;
@@ -0,0 +1,30 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+-- The hosts must match ownerHosts in internal/postgres/search.go.
+CREATE FUNCTION search_owner(module_path text, repo_url text) RETURNS text
+    LANGUAGE sql IMMUTABLE
+    AS $$
+	SELECT lower(COALESCE(
+		substring(module_path FROM '^((?:github\.com|gitlab\.com|bitbucket\.org)/[^/]+)/'),
+		substring(repo_url FROM '^https?://((?:github\.com|gitlab\.com|bitbucket\.org)/[^/]+)/')
+	))
+$$;
+COMMENT ON FUNCTION search_owner(module_path text, repo_url text) IS
+'FUNCTION search_owner returns the owner of a module on a code hosting site, like github.com/owner, from its module path or else from the URL of its repository. It returns NULL if neither is on a known site.';
+
+ALTER TABLE search_documents ADD COLUMN owner text;
+COMMENT ON COLUMN search_documents.owner IS
+'COLUMN owner is the owner of the module of the document on a code hosting site, like github.com/owner, or NULL if it is not known. Search results can be filtered by owner.';
+
+UPDATE search_documents sd
+SET owner = search_owner(m.module_path, m.source_info->>'RepoURL')
+FROM modules m
+WHERE m.module_path = sd.module_path AND m.version = sd.version;
+
+CREATE INDEX idx_search_documents_owner ON search_documents (owner);
+
+END;
@@ -0,0 +1,20 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE search_queries (
+    day               date NOT NULL,
+    query             text NOT NULL,
+    count             integer NOT NULL,
+    zero_result_count integer NOT NULL,
+    last_seen         timestamp with time zone NOT NULL,
+    PRIMARY KEY (day, query)
+);
+COMMENT ON TABLE search_queries IS
+'TABLE search_queries counts a sample of the search queries of each day, scrubbed of personal information, to tune synonyms and ranking.';
+COMMENT ON COLUMN search_queries.zero_result_count IS
+'COLUMN zero_result_count is the number of the searches counted in count that had no results.';
+
+END;
@@ -0,0 +1,94 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE search_synonyms (
+    term       text NOT NULL,
+    synonym    text NOT NULL,
+    created_by text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (term, synonym)
+);
+COMMENT ON TABLE search_synonyms IS
+'TABLE search_synonyms holds the synonyms that search queries are expanded with: a query for term also matches synonym.';
+
+INSERT INTO search_synonyms (term, synonym, created_by) VALUES
+    ('k8s', 'kubernetes', 'migration'),
+    ('postgres', 'postgresql', 'migration'),
+    ('mongo', 'mongodb', 'migration'),
+    ('gql', 'graphql', 'migration'),
+    ('otel', 'opentelemetry', 'migration');
+
+CREATE FUNCTION search_tsquery(rawquery text) RETURNS tsquery
+    LANGUAGE sql STABLE
+    AS $$
+	SELECT ts_rewrite(websearch_to_tsquery(rawquery), $q$
+		SELECT plainto_tsquery(term), plainto_tsquery(term) || plainto_tsquery(synonym)
+		FROM search_synonyms
+		WHERE numnode(plainto_tsquery(term)) > 0 AND numnode(plainto_tsquery(synonym)) > 0
+	$q$)
+$$;
+COMMENT ON FUNCTION search_tsquery(rawquery text) IS
+'FUNCTION search_tsquery parses a search query like websearch_to_tsquery, and expands its terms with their synonyms from search_synonyms.';
+
+-- Redefine popular_search to expand queries with synonyms.
+DROP FUNCTION popular_search(rawquery text, lim integer, off integer, redist_factor real, go_mod_factor real, inactive_factor real, undocumented_factor real);
+CREATE FUNCTION popular_search(rawquery text, lim integer, off integer, redist_factor real, go_mod_factor real, inactive_factor real, undocumented_factor real) RETURNS SETOF search_result
+    LANGUAGE plpgsql
+    AS $$
+	DECLARE cur CURSOR(query TSQUERY) FOR
+		SELECT
+			package_path,
+			module_path,
+			version,
+			commit_time,
+			imported_by_count,
+			(
+				-- default D, C, B, A weights are {0.1, 0.2, 0.4, 1.0}
+				ts_rank('{0.1, 0.2, 1.0, 1.0}', tsv_search_tokens, query) *
+				ln(exp(1)+imported_by_count) *
+				CASE WHEN redistributable THEN 1 ELSE redist_factor END *
+				CASE WHEN COALESCE(has_go_mod, true) THEN 1 ELSE go_mod_factor END *
+				CASE WHEN repo_inactive THEN inactive_factor ELSE 1 END *
+				CASE WHEN documentation_coverage IS NULL THEN 1
+					ELSE 1 - (1 - documentation_coverage) * (1 - undocumented_factor) END *
+				CASE WHEN tsv_search_tokens @@ query THEN 1 ELSE 0 END
+			) score
+			FROM search_documents
+			ORDER BY imported_by_count DESC;
+	top search_result[];
+	res search_result;
+	last_idx INT;
+BEGIN
+	last_idx := lim+off;
+	top := array_fill(NULL::search_result, array[last_idx]);
+	OPEN cur(query := search_tsquery(rawquery));
+	FETCH cur INTO res;
+	WHILE found LOOP
+		IF top[last_idx] IS NULL OR res.score >= top[last_idx].score THEN
+			FOR i IN 1..last_idx LOOP
+				IF top[i] IS NULL OR
+					(res.score > top[i].score) OR
+					(res.score = top[i].score AND res.commit_time > top[i].commit_time) OR
+					(res.score = top[i].score AND res.commit_time = top[i].commit_time AND
+					 res.package_path < top[i].package_path) THEN
+					top := (top[1:i-1] || res) || top[i:last_idx-1];
+					EXIT;
+				END IF;
+			END LOOP;
+		END IF;
+		IF top[last_idx].score > ln(exp(1)+res.imported_by_count) THEN
+			EXIT;
+		END IF;
+		FETCH cur INTO res;
+	END LOOP;
+	CLOSE cur;
+	RETURN QUERY SELECT * FROM UNNEST(top[off+1:last_idx])
+		WHERE package_path IS NOT NULL AND score > 0.1;
+END; $$;
+COMMENT ON FUNCTION popular_search(rawquery text, lim integer, off integer, redist_factor real, go_mod_factor real, inactive_factor real, undocumented_factor real) IS
+'FUNCTION popular_search is used to generate results for search. It is implemented as a stored function, so that we can use a cursor to scan search documents procedurally, and stop scanning early, whenever our search results are provably correct.';
+
+END;
@@ -0,0 +1,38 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE module_requirements (
+    module_path      text NOT NULL,
+    version          text NOT NULL,
+    required_path    text NOT NULL,
+    required_version text NOT NULL,
+
+    PRIMARY KEY (module_path, version, required_path),
+    FOREIGN KEY (module_path, version) REFERENCES modules(module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE module_requirements IS
+'TABLE module_requirements contains the requirements of the go.mod files of module versions.';
+
+CREATE TABLE module_dependency_stats (
+    module_path      text NOT NULL PRIMARY KEY,
+    version          text NOT NULL,
+    dependency_count integer NOT NULL,
+    max_depth        integer NOT NULL,
+    has_cycle        boolean NOT NULL,
+    computed_at      timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (module_path, version) REFERENCES modules(module_path, version) ON DELETE CASCADE
+);
+COMMENT ON TABLE module_dependency_stats IS
+'TABLE module_dependency_stats contains statistics of the requirement graph of the latest version of each module, computed from module_requirements by the worker.';
+COMMENT ON COLUMN module_dependency_stats.dependency_count IS
+'COLUMN dependency_count is the number of distinct modules that the module requires, directly or not.';
+COMMENT ON COLUMN module_dependency_stats.max_depth IS
+'COLUMN max_depth is the length of the longest chain of requirements from the module.';
+COMMENT ON COLUMN module_dependency_stats.has_cycle IS
+'COLUMN has_cycle records whether some module in the requirement graph of the module requires itself, directly or not.';
+
+END;
@@ -0,0 +1,15 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE packages ADD COLUMN exported_symbols text[];
+COMMENT ON COLUMN packages.exported_symbols IS
+'COLUMN exported_symbols is the exported API of the package, with methods and fields as Type.Method and Type.Field. It is NULL for packages that were processed before it was recorded.';
+
+ALTER TABLE imports_unique ADD COLUMN symbols text[];
+COMMENT ON COLUMN imports_unique.symbols IS
+'COLUMN symbols is the package-level symbols of to_path that from_path refers to. It is NULL for packages that were processed before it was recorded.';
+
+END;
@@ -0,0 +1,19 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN published_at timestamp with time zone;
+COMMENT ON COLUMN modules.published_at IS
+'COLUMN published_at is the time the module index reported the version, as opposed to commit_time, the time of its commit. It is NULL if the version was not reported by the index, like versions of the standard library.';
+
+-- Versions fetched outside of the index have the zero time as their index_timestamp.
+UPDATE modules m
+SET published_at = s.index_timestamp
+FROM module_version_states s
+WHERE s.module_path = m.module_path
+AND s.version = m.version
+AND s.index_timestamp > '0001-01-01 00:00:00+00';
+
+END;
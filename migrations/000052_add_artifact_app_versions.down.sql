@@ -0,0 +1,15 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE reprocessing_campaigns DROP COLUMN artifact;
+ALTER TABLE search_documents DROP COLUMN app_version;
+ALTER TABLE licenses DROP COLUMN app_version;
+ALTER TABLE readmes DROP COLUMN app_version;
+ALTER TABLE documentation DROP COLUMN app_version;
+ALTER TABLE packages DROP COLUMN app_version;
+ALTER TABLE modules DROP COLUMN app_version;
+
+END;
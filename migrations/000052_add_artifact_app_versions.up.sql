@@ -0,0 +1,35 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ADD COLUMN app_version text;
+COMMENT ON COLUMN modules.app_version IS
+'COLUMN app_version is the version of the worker that rendered the README of the module version, or NULL if it is not known.';
+
+ALTER TABLE packages ADD COLUMN app_version text;
+COMMENT ON COLUMN packages.app_version IS
+'COLUMN app_version is the version of the worker that rendered the documentation of the package, or NULL if it is not known.';
+
+ALTER TABLE documentation ADD COLUMN app_version text;
+COMMENT ON COLUMN documentation.app_version IS
+'COLUMN app_version is the version of the worker that rendered the documentation, or NULL if it is not known.';
+
+ALTER TABLE readmes ADD COLUMN app_version text;
+COMMENT ON COLUMN readmes.app_version IS
+'COLUMN app_version is the version of the worker that rendered the README, or NULL if it is not known.';
+
+ALTER TABLE licenses ADD COLUMN app_version text;
+COMMENT ON COLUMN licenses.app_version IS
+'COLUMN app_version is the version of the worker that detected the license, or NULL if it is not known.';
+
+ALTER TABLE search_documents ADD COLUMN app_version text;
+COMMENT ON COLUMN search_documents.app_version IS
+'COLUMN app_version is the version of the worker that processed the package the search document was built from, or NULL if it is not known.';
+
+ALTER TABLE reprocessing_campaigns ADD COLUMN artifact text DEFAULT ''::text NOT NULL;
+COMMENT ON COLUMN reprocessing_campaigns.artifact IS
+'COLUMN artifact is the kind of derived data, like documentation, whose versions older than app_version make up the cohort of the campaign. If it is empty, the cohort is the module versions processed before app_version.';
+
+END;
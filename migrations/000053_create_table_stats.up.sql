@@ -0,0 +1,31 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE TABLE table_stats (
+    recorded_at      timestamp with time zone NOT NULL,
+    table_name       text NOT NULL,
+    row_estimate     bigint NOT NULL,
+    table_bytes      bigint NOT NULL,
+    index_bytes      bigint NOT NULL,
+    live_tuples      bigint NOT NULL,
+    dead_tuples      bigint NOT NULL,
+    last_vacuum      timestamp with time zone,
+    last_autovacuum  timestamp with time zone,
+    last_autoanalyze timestamp with time zone,
+    autovacuum_count bigint NOT NULL,
+
+    PRIMARY KEY (recorded_at, table_name)
+);
+COMMENT ON TABLE table_stats IS
+'TABLE table_stats contains snapshots of the size, dead tuples and vacuuming of the tables of the database, recorded periodically by the worker to monitor bloat.';
+COMMENT ON COLUMN table_stats.row_estimate IS
+'COLUMN row_estimate is the number of rows in the table estimated by the planner, from pg_class.reltuples.';
+COMMENT ON COLUMN table_stats.table_bytes IS
+'COLUMN table_bytes is the size of the table on disk, including TOAST but not indexes.';
+COMMENT ON COLUMN table_stats.dead_tuples IS
+'COLUMN dead_tuples is the estimated number of dead tuples in the table, from pg_stat_user_tables.';
+
+END;
@@ -0,0 +1,14 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_search_documents_package_path_trgm ON search_documents
+    USING gin (package_path gin_trgm_ops);
+COMMENT ON INDEX idx_search_documents_package_path_trgm IS
+'INDEX idx_search_documents_package_path_trgm is used to find packages with paths similar to a path that is not found, to suggest them on the 404 page.';
+
+END;
@@ -0,0 +1,21 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+-- Compressed values are not valid UTF-8, so this fails unless none of the
+-- columns hold any.
+
+BEGIN;
+
+ALTER TABLE modules ALTER COLUMN readme_contents TYPE text USING convert_from(readme_contents, 'UTF8');
+COMMENT ON COLUMN modules.readme_contents IS NULL;
+ALTER TABLE packages ALTER COLUMN documentation TYPE text USING convert_from(documentation, 'UTF8');
+COMMENT ON COLUMN packages.documentation IS NULL;
+ALTER TABLE licenses ALTER COLUMN contents TYPE text USING convert_from(contents, 'UTF8');
+COMMENT ON COLUMN licenses.contents IS NULL;
+ALTER TABLE readmes ALTER COLUMN contents TYPE text USING convert_from(contents, 'UTF8');
+COMMENT ON COLUMN readmes.contents IS NULL;
+ALTER TABLE documentation ALTER COLUMN html TYPE text USING convert_from(html, 'UTF8');
+COMMENT ON COLUMN documentation.html IS NULL;
+
+END;
@@ -0,0 +1,27 @@
+-- Copyright 2020 The Go Authors. All rights reserved.
+-- Use of this source code is governed by a BSD-style
+-- license that can be found in the LICENSE file.
+
+BEGIN;
+
+ALTER TABLE modules ALTER COLUMN readme_contents TYPE bytea USING convert_to(readme_contents, 'UTF8');
+COMMENT ON COLUMN modules.readme_contents IS
+'COLUMN readme_contents is the text of the README of the module, encoded in UTF-8. It may be compressed with zstd, in which case it begins with the magic number of a zstd frame.';
+
+ALTER TABLE packages ALTER COLUMN documentation TYPE bytea USING convert_to(documentation, 'UTF8');
+COMMENT ON COLUMN packages.documentation IS
+'COLUMN documentation is the HTML of the documentation of the package, encoded in UTF-8. It may be compressed with zstd, in which case it begins with the magic number of a zstd frame.';
+
+ALTER TABLE licenses ALTER COLUMN contents TYPE bytea USING convert_to(contents, 'UTF8');
+COMMENT ON COLUMN licenses.contents IS
+'COLUMN contents is the text of the license file, encoded in UTF-8. It may be compressed with zstd, in which case it begins with the magic number of a zstd frame.';
+
+ALTER TABLE readmes ALTER COLUMN contents TYPE bytea USING convert_to(contents, 'UTF8');
+COMMENT ON COLUMN readmes.contents IS
+'COLUMN contents is the text of the README, encoded in UTF-8. It may be compressed with zstd, in which case it begins with the magic number of a zstd frame.';
+
+ALTER TABLE documentation ALTER COLUMN html TYPE bytea USING convert_to(html, 'UTF8');
+COMMENT ON COLUMN documentation.html IS
+'COLUMN html is the HTML of the documentation, encoded in UTF-8. It may be compressed with zstd, in which case it begins with the magic number of a zstd frame.';
+
+END;
@@ -0,0 +1,15 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package migrations holds the schema migrations of the discovery database,
+// so that they can be embedded in binaries. See internal/migrations for how
+// they are applied.
+package migrations
+
+import "embed"
+
+// FS contains the migration files.
+//
+//go:embed *.sql
+var FS embed.FS